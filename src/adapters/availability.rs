@@ -0,0 +1,227 @@
+//! Which languages this server can debug
+//!
+//! Images are built per language, so a server may lack the adapter a
+//! `debugger_start` needs. Each language's adapter is probed by running it
+//! once, asking for its version, so clients can check up front
+//! (`debugger_info`) instead of failing at `debugger_start`:
+//!
+//! - Go: `dlv version`
+//! - Python: debugpy's version, imported by the interpreter that runs it
+//! - Ruby: `rdbg --version`
+//! - Node.js: vscode-js-debug's `dapDebugServer.js` is located and
+//!   `node --version` run (js-debug has no version flag)
+//! - Rust: `codelldb --version`
+//! - Java: the java-debug launcher is located and `java --version` run
+//!
+//! The server probes at startup and keeps the result (`languages()`); adapters
+//! installed later are only seen by a new server. `probe()` runs a fresh probe.
+
+use super::{nodejs::NodeJsAdapter, supported_modes};
+use serde::Serialize;
+use std::time::Duration;
+use tokio::process::Command;
+use tokio::sync::OnceCell;
+
+/// How long one adapter may take to print its version
+const PROBE_TIMEOUT: Duration = Duration::from_secs(10);
+
+static AVAILABILITY: OnceCell<Vec<LanguageAvailability>> = OnceCell::const_new();
+
+/// Availability of one language's adapter
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct LanguageAvailability {
+    pub language: &'static str,
+    /// Command (or for Node.js, script) the adapter is started with
+    pub adapter: Option<String>,
+    /// Whether the adapter ran and reported a version
+    pub found: bool,
+    /// Version the adapter reported, else `adapters.<language>.version` from
+    /// the server configuration
+    pub version: Option<String>,
+    /// Modes `debugger_start` accepts
+    pub modes: &'static [&'static str],
+    pub launch: bool,
+    pub attach: bool,
+    /// Whether tests can be debugged (Go `test`, Python `pytest`)
+    pub test: bool,
+    /// Why the adapter wasn't found
+    pub error: Option<String>,
+}
+
+/// Availability of every language, probed once per server
+pub async fn languages() -> &'static [LanguageAvailability] {
+    AVAILABILITY
+        .get_or_init(|| async {
+            let (go, python, ruby, nodejs, rust, java) = tokio::join!(
+                probe("go"),
+                probe("python"),
+                probe("ruby"),
+                probe("nodejs"),
+                probe("rust"),
+                probe("java")
+            );
+            vec![go, python, ruby, nodejs, rust, java]
+        })
+        .await
+}
+
+/// Probe `language`'s adapter now
+pub async fn probe(language: &'static str) -> LanguageAvailability {
+    let modes = supported_modes(language);
+    let mut availability = LanguageAvailability {
+        language,
+        adapter: None,
+        found: false,
+        version: None,
+        modes,
+        launch: true,
+        attach: modes.contains(&"attach"),
+        test: modes.contains(&"test") || modes.contains(&"pytest"),
+        error: None,
+    };
+    let probed = match language {
+        "go" => {
+            let dlv = super::golang::GoAdapter::command();
+            availability.adapter = Some(dlv.clone());
+            run(&dlv, &["version"])
+                .await
+                .map(|out| parse_dlv_version(&out))
+        }
+        "python" => {
+            let python = super::python::PythonAdapter::command();
+            availability.adapter = Some(format!("{} -m debugpy.adapter", python));
+            run(
+                &python,
+                &["-c", "import debugpy; print(debugpy.__version__)"],
+            )
+            .await
+            .map(|out| first_line(&out))
+        }
+        "ruby" => {
+            let rdbg = super::ruby::RubyAdapter::command();
+            availability.adapter = Some(rdbg.clone());
+            run(&rdbg, &["--version"])
+                .await
+                .map(|out| parse_rdbg_version(&out))
+        }
+        "nodejs" => match NodeJsAdapter::dap_server_path() {
+            Ok(script) => {
+                availability.adapter = Some(script);
+                run("node", &["--version"])
+                    .await
+                    .map(|out| first_line(&out).map(|v| format!("node {}", v)))
+            }
+            Err(e) => Err(e.to_string()),
+        },
+        "rust" => {
+            let codelldb = super::rust::RustAdapter::command();
+            availability.adapter = Some(codelldb.clone());
+            run(&codelldb, &["--version"])
+                .await
+                .map(|out| first_line(&out))
+        }
+        "java" => match super::java::JavaAdapter::command() {
+            Ok(launcher) => {
+                availability.adapter = Some(launcher);
+                run("java", &["--version"])
+                    .await
+                    .map(|out| first_line(&out))
+            }
+            Err(e) => Err(e.to_string()),
+        },
+        _ => Err(format!("unsupported language '{}'", language)),
+    };
+
+    match probed {
+        Ok(version) => {
+            availability.found = true;
+            availability.version = version;
+        }
+        Err(e) => availability.error = Some(e),
+    }
+    if availability.version.is_none() {
+        availability.version = crate::config::current()
+            .adapters
+            .get(language)
+            .and_then(|adapter| adapter.version.clone());
+    }
+    availability
+}
+
+/// Stdout of `command args`, or why it failed to run or exited unsuccessfully
+async fn run(command: &str, args: &[&str]) -> std::result::Result<String, String> {
+    let output = Command::new(command).args(args).kill_on_drop(true).output();
+    let output = tokio::time::timeout(PROBE_TIMEOUT, output)
+        .await
+        .map_err(|_| format!("{} did not answer within {:?}", command, PROBE_TIMEOUT))?
+        .map_err(|e| format!("failed to run {}: {}", command, e))?;
+    if !output.status.success() {
+        let stderr = String::from_utf8_lossy(&output.stderr);
+        return Err(format!(
+            "{} {} failed ({}): {}",
+            command,
+            args.join(" "),
+            output.status,
+            stderr.lines().last().unwrap_or_default().trim()
+        ));
+    }
+    Ok(String::from_utf8_lossy(&output.stdout).into_owned())
+}
+
+fn first_line(output: &str) -> Option<String> {
+    let line = output.lines().next()?.trim();
+    (!line.is_empty()).then(|| line.to_string())
+}
+
+/// `1.22.1` from dlv's `Delve Debugger\nVersion: 1.22.1\nBuild: ...`
+fn parse_dlv_version(output: &str) -> Option<String> {
+    output
+        .lines()
+        .find_map(|line| line.trim().strip_prefix("Version: "))
+        .map(|version| version.trim().to_string())
+}
+
+/// `1.9.1` from `rdbg 1.9.1`
+fn parse_rdbg_version(output: &str) -> Option<String> {
+    let version = first_line(output)?;
+    Some(
+        version
+            .strip_prefix("rdbg ")
+            .unwrap_or(&version)
+            .to_string(),
+    )
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::adapters::DEFAULT_MODE_ALIAS;
+
+    #[test]
+    fn test_parse_versions() {
+        assert_eq!(
+            parse_dlv_version("Delve Debugger\nVersion: 1.22.1\nBuild: $Id: 0c3470 $\n").as_deref(),
+            Some("1.22.1")
+        );
+        assert_eq!(parse_dlv_version("unknown"), None);
+        assert_eq!(parse_rdbg_version("rdbg 1.9.1\n").as_deref(), Some("1.9.1"));
+        assert_eq!(first_line("\n"), None);
+    }
+
+    #[tokio::test]
+    async fn test_probe_reports_modes() {
+        let go = probe("go").await;
+        assert_eq!(go.adapter.as_deref(), Some("dlv"));
+        assert!(go.launch && go.attach && go.test);
+        assert_eq!(go.found, go.error.is_none());
+
+        let ruby = probe("ruby").await;
+        assert!(!ruby.attach && !ruby.test);
+        assert_eq!(ruby.modes, [DEFAULT_MODE_ALIAS]);
+
+        let unknown = probe("cobol").await;
+        assert!(!unknown.found);
+        assert!(unknown.error.unwrap().contains("unsupported"));
+    }
+}
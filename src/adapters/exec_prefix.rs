@@ -0,0 +1,278 @@
+//! Debuggees that run in another container or namespace
+//!
+//! A service that has to run in its own container can't be launched by an
+//! adapter on the server's host. `debugger_start` takes `targetExecPrefix`
+//! instead: an argv prepended to the adapter's command, such as
+//! `["docker", "exec", "-i", "app"]` or `["nsenter", "-t", "4242", "-m", "-p"]`,
+//! so the adapter (and the program it launches) runs in the target while
+//! the server talks DAP to it as before:
+//!
+//! - debugpy and java-debug speak DAP over stdio, which the prefix passes
+//!   through (`docker exec` needs `-i` for that).
+//! - Delve and rdbg listen on a TCP port on 127.0.0.1, so the target must
+//!   share the server's network namespace (`nsenter` without `-n`, or a
+//!   container started with `--network host` or `--network container:...`).
+//!
+//! The adapter must be installed in the target, and paths (program, cwd,
+//! breakpoints) are those of the target; mount the sources at the same path
+//! or map them with Go's `substitutePath`. Program output still arrives as
+//! DAP output events.
+//!
+//! Ending the prefix command (`docker exec`) doesn't end what it started in
+//! the target, so the adapter is started through `sh` in the target, which
+//! writes the adapter's pid there to a file of the session (`Tracked`).
+//! When a session ends, or fails to start, the adapter and the debuggee
+//! processes are killed by running `kill` through the same prefix. The
+//! target needs `sh`, `cat` and `kill` for that.
+//!
+//! Since the prefix is an arbitrary command, it is refused unless the server
+//! configuration sets `security.allow_target_exec_prefix`.
+
+use crate::{Error, Result};
+use std::time::Duration;
+use tokio::process::Command;
+use tracing::{info, warn};
+use uuid::Uuid;
+
+/// Languages whose adapter can run through a prefix
+pub const LANGUAGES: &[&str] = &["python", "java", "go", "ruby"];
+
+/// How long the `kill` run through the prefix may take
+const KILL_TIMEOUT: Duration = Duration::from_secs(5);
+
+/// Writes its own pid to the file `$0` in the target, then becomes the
+/// adapter
+const RECORD_PID: &str = r#"echo $$ > "$0" && exec "$@""#;
+
+/// Kills the adapter whose pid is in `$0` and the processes `$@`, then
+/// removes the pid file
+const KILL_ADAPTER: &str = r#"kill -KILL $(cat "$0" 2>/dev/null) "$@"; rm -f "$0""#;
+
+/// A prefix whose adapter's pid is recorded in the target
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Tracked {
+    /// The prefix as given
+    pub prefix: Vec<String>,
+    /// The adapter's pid file, in the target's file system
+    pub pid_file: String,
+}
+
+impl Tracked {
+    /// Track the adapter of a session started through `prefix`
+    pub fn new(prefix: &[String]) -> Self {
+        Self {
+            prefix: prefix.to_vec(),
+            pid_file: format!("/tmp/debugger-mcp-adapter-{}.pid", Uuid::new_v4()),
+        }
+    }
+
+    /// The prefix to start the adapter with: `prefix` followed by the
+    /// `sh` that records the adapter's pid
+    pub fn launcher(&self) -> Vec<String> {
+        let mut launcher = self.prefix.clone();
+        launcher.extend([
+            "sh".to_string(),
+            "-c".to_string(),
+            RECORD_PID.to_string(),
+            self.pid_file.clone(),
+        ]);
+        launcher
+    }
+
+    /// Kill the adapter and `pids` in the target
+    ///
+    /// Best effort: processes that already exited make `kill` fail, which
+    /// is only logged.
+    pub async fn kill(&self, pids: &[i64]) {
+        let mut args = vec![
+            "-c".to_string(),
+            KILL_ADAPTER.to_string(),
+            self.pid_file.clone(),
+        ];
+        args.extend(pids.iter().map(i64::to_string));
+        let (command, args) = wrap(&self.prefix, "sh", &args);
+        let output = Command::new(&command)
+            .args(&args)
+            .kill_on_drop(true)
+            .output();
+        match tokio::time::timeout(KILL_TIMEOUT, output).await {
+            Ok(Ok(output)) if output.status.success() => {
+                info!(
+                    "🧹 Killed the adapter and debuggee processes {:?} through {}",
+                    pids, command
+                );
+            }
+            Ok(Ok(output)) => warn!(
+                "⚠️  Killing the adapter and debuggee processes {:?} through {} failed: {}",
+                pids,
+                command,
+                String::from_utf8_lossy(&output.stderr).trim()
+            ),
+            Ok(Err(e)) => warn!(
+                "⚠️  Could not run {} to kill the adapter and {:?}: {}",
+                command, pids, e
+            ),
+            Err(_) => warn!(
+                "⚠️  Killing the adapter and debuggee processes {:?} through {} timed out",
+                pids, command
+            ),
+        }
+    }
+}
+
+/// Check `prefix` for a session of `language`
+pub fn validate(language: &str, prefix: &[String]) -> Result<()> {
+    check(
+        language,
+        prefix,
+        crate::config::current().security.allow_target_exec_prefix,
+    )
+}
+
+fn check(language: &str, prefix: &[String], allowed: bool) -> Result<()> {
+    if !allowed {
+        return Err(Error::InvalidRequest(
+            "targetExecPrefix runs commands on the server, which is disabled; set security.allow_target_exec_prefix in the server configuration to enable it".to_string(),
+        ));
+    }
+    if !LANGUAGES.contains(&language) {
+        return Err(Error::InvalidRequest(format!(
+            "targetExecPrefix is not supported for {} (supported: {})",
+            language,
+            LANGUAGES.join(", ")
+        )));
+    }
+    if prefix.is_empty() || prefix[0].is_empty() {
+        return Err(Error::InvalidRequest(
+            "targetExecPrefix must start with the command to run, e.g. [\"docker\", \"exec\", \"-i\", \"app\"]".to_string(),
+        ));
+    }
+    Ok(())
+}
+
+/// `command` and `args` run through `prefix` (unchanged when it is empty)
+pub fn wrap(prefix: &[String], command: &str, args: &[String]) -> (String, Vec<String>) {
+    let Some((first, rest)) = prefix.split_first() else {
+        return (command.to_string(), args.to_vec());
+    };
+    let mut wrapped = rest.to_vec();
+    wrapped.push(command.to_string());
+    wrapped.extend(args.iter().cloned());
+    (first.clone(), wrapped)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn argv(args: &[&str]) -> Vec<String> {
+        args.iter().map(|a| a.to_string()).collect()
+    }
+
+    #[test]
+    fn test_wrap() {
+        let prefix = argv(&["docker", "exec", "-i", "app"]);
+        let (command, args) = wrap(&prefix, "python", &argv(&["-m", "debugpy.adapter"]));
+        assert_eq!(command, "docker");
+        assert_eq!(
+            args,
+            argv(&["exec", "-i", "app", "python", "-m", "debugpy.adapter"])
+        );
+
+        let (command, args) = wrap(&[], "dlv", &argv(&["dap"]));
+        assert_eq!((command.as_str(), args), ("dlv", argv(&["dap"])));
+    }
+
+    #[test]
+    fn test_check() {
+        let prefix = argv(&["env"]);
+        assert!(check("python", &prefix, true).is_ok());
+        assert!(check("go", &prefix, true).is_ok());
+
+        let err = check("python", &prefix, false).unwrap_err();
+        assert!(err.to_string().contains("allow_target_exec_prefix"));
+        let err = check("nodejs", &prefix, true).unwrap_err();
+        assert!(err.to_string().contains("not supported for nodejs"));
+        assert!(check("python", &[], true).is_err());
+        assert!(check("python", &argv(&[""]), true).is_err());
+    }
+
+    /// Whether process `pid` is gone: exited, or a zombie no one reaped
+    #[cfg(target_os = "linux")]
+    fn gone(pid: i64) -> bool {
+        match std::fs::read_to_string(format!("/proc/{}/stat", pid)) {
+            Ok(stat) => stat
+                .rsplit(')')
+                .next()
+                .is_some_and(|rest| rest.trim_start().starts_with('Z')),
+            Err(_) => true,
+        }
+    }
+
+    /// The adapter's pid, once the launcher has written it
+    #[cfg(target_os = "linux")]
+    async fn recorded_pid(tracked: &Tracked) -> i64 {
+        for _ in 0..50 {
+            if let Ok(pid) = std::fs::read_to_string(&tracked.pid_file) {
+                if let Ok(pid) = pid.trim().parse() {
+                    return pid;
+                }
+            }
+            tokio::time::sleep(Duration::from_millis(100)).await;
+        }
+        panic!("{} was not written", tracked.pid_file);
+    }
+
+    #[cfg(target_os = "linux")]
+    #[tokio::test]
+    async fn test_env_prefix_launches_and_kills_the_adapter() {
+        let tracked = Tracked::new(&argv(&["env"]));
+        let (command, args) = wrap(&tracked.launcher(), "sleep", &argv(&["30"]));
+        assert_eq!(command, "env");
+        assert_eq!(&args[..2], &argv(&["sh", "-c"])[..]);
+        assert_eq!(&args[3..], &argv(&[&tracked.pid_file, "sleep", "30"])[..]);
+
+        let mut launcher = Command::new(&command).args(&args).spawn().unwrap();
+        // env and sh exec into the adapter, so its pid is the launcher's
+        let pid = recorded_pid(&tracked).await;
+        assert_eq!(pid, launcher.id().unwrap() as i64);
+
+        tracked.kill(&[]).await;
+        let status = tokio::time::timeout(Duration::from_secs(5), launcher.wait())
+            .await
+            .unwrap()
+            .unwrap();
+        assert!(!status.success());
+        assert!(!std::path::Path::new(&tracked.pid_file).exists());
+    }
+
+    #[cfg(target_os = "linux")]
+    #[tokio::test]
+    async fn test_kill_reaches_the_adapter_the_launcher_left() {
+        // Like `docker exec`, the launcher runs the adapter as a child that
+        // outlives it
+        let tracked = Tracked::new(&argv(&["sh", "-c", "\"$@\" & wait", "launcher"]));
+        let (command, args) = wrap(&tracked.launcher(), "sleep", &argv(&["30"]));
+        let mut launcher = Command::new(&command).args(&args).spawn().unwrap();
+        let pid = recorded_pid(&tracked).await;
+        assert_ne!(pid, launcher.id().unwrap() as i64);
+
+        launcher.kill().await.unwrap();
+        assert!(!gone(pid), "the adapter outlives its launcher");
+
+        let mut debuggee = Command::new("sleep").arg("30").spawn().unwrap();
+        tracked.kill(&[debuggee.id().unwrap() as i64]).await;
+        for _ in 0..50 {
+            if gone(pid) {
+                break;
+            }
+            tokio::time::sleep(Duration::from_millis(100)).await;
+        }
+        assert!(gone(pid), "the adapter should be killed");
+        let status = tokio::time::timeout(Duration::from_secs(5), debuggee.wait())
+            .await
+            .unwrap()
+            .unwrap();
+        assert!(!status.success());
+    }
+}
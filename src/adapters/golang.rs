@@ -1,11 +1,15 @@
+use super::exec_prefix;
 use super::logging::DebugAdapterLogger;
 use crate::dap::socket_helper;
+use crate::process::private_dir;
 use crate::{Error, Result};
+use serde::{Deserialize, Serialize};
 use serde_json::{json, Value};
+use std::path::{Path, PathBuf};
 use std::time::Duration;
 use tokio::net::TcpStream;
 use tokio::process::{Child, Command};
-use tracing::{error, info};
+use tracing::{error, info, warn};
 
 /// Go Delve debugger adapter configuration
 ///
@@ -23,6 +27,170 @@ use tracing::{error, info};
 /// No special detection or compilation step needed - Delve compiles on-the-fly.
 pub struct GoAdapter;
 
+/// Name of the binary Delve builds (its own default, in a directory of ours)
+const DEBUG_BINARY: &str = "__debug_bin";
+
+/// Build directories are named `<server pid>-<uuid>` in the user's private
+/// `<BUILD_DIRS>-<uid>` in the temp dir (see `private_dir`)
+const BUILD_DIRS: &str = "debugger-mcp-go-builds";
+
+/// Stale build directories are swept once per server run
+static SWEEP_STALE_BUILD_DIRS: std::sync::Once = std::sync::Once::new();
+
+/// Go-specific launch options
+#[derive(Debug, Clone, Default, PartialEq, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct GoLaunchOptions {
+    /// Path rewrites between local sources and the paths recorded in the
+    /// binary's debug info, for binaries built elsewhere (e.g. in CI or a
+    /// container)
+    #[serde(default)]
+    pub substitute_path: Vec<SubstitutePath>,
+    /// Where Delve writes the binary it builds in debug and test mode
+    /// (default: a temporary directory of the session)
+    pub output: Option<String>,
+    /// Follow processes the program starts with fork/exec, so breakpoints
+    /// hit in them too (Delve's `followExec`, Linux only). They are
+    /// debugged within the session, not as sessions of their own.
+    #[serde(default)]
+    pub follow_children: bool,
+}
+
+/// First Delve version whose DAP server accepts `followExec`
+pub const FOLLOW_EXEC_MIN_VERSION: (u32, u32, u32) = (1, 24, 0);
+
+/// One Delve `substitutePath` rule
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct SubstitutePath {
+    /// Local path prefix (where the sources are on this machine)
+    pub from: String,
+    /// Path prefix recorded in the binary's debug info
+    pub to: String,
+}
+
+impl SubstitutePath {
+    /// Where `path`, as recorded in the binary's debug info, is on this
+    /// machine; None when it isn't under `to`
+    pub fn local_path(&self, path: &str) -> Option<String> {
+        let rest = path.strip_prefix(self.to.trim_end_matches('/'))?;
+        (rest.is_empty() || rest.starts_with('/'))
+            .then(|| format!("{}{}", self.from.trim_end_matches('/'), rest))
+    }
+}
+
+/// `path` mapped to this machine by the first rule it is under, or as it is
+pub fn local_source_path(rules: &[SubstitutePath], path: &str) -> String {
+    rules
+        .iter()
+        .find_map(|rule| rule.local_path(path))
+        .unwrap_or_else(|| path.to_string())
+}
+
+impl GoLaunchOptions {
+    /// Add these options to a launch configuration
+    pub fn apply(&self, launch: &mut Value) {
+        if !self.substitute_path.is_empty() {
+            launch["substitutePath"] = json!(self.substitute_path);
+        }
+        if let Some(output) = &self.output {
+            launch["output"] = json!(output);
+        }
+        if self.follow_children {
+            launch["followExec"] = json!(true);
+        }
+    }
+}
+
+/// The version in `dlv version` output (`Version: 1.24.0`)
+pub fn parse_delve_version(output: &str) -> Option<(u32, u32, u32)> {
+    let version = output
+        .lines()
+        .find_map(|line| line.trim().strip_prefix("Version:"))?
+        .trim()
+        .trim_start_matches('v');
+    let mut parts = version
+        .split(|c: char| !c.is_ascii_digit())
+        .map(|part| part.parse::<u32>().ok());
+    Some((
+        parts.next()??,
+        parts.next()??,
+        parts.next().flatten().unwrap_or(0),
+    ))
+}
+
+/// Check that a Delve version can follow child processes
+pub fn check_follow_exec_version(version: Option<(u32, u32, u32)>) -> Result<()> {
+    let (major, minor, patch) = FOLLOW_EXEC_MIN_VERSION;
+    match version {
+        Some(found) if found >= FOLLOW_EXEC_MIN_VERSION => Ok(()),
+        Some((found_major, found_minor, found_patch)) => Err(Error::InvalidRequest(format!(
+            "goOptions.followChildren needs Delve {}.{}.{} or newer; the installed Delve is {}.{}.{}",
+            major, minor, patch, found_major, found_minor, found_patch
+        ))),
+        None => Err(Error::InvalidRequest(format!(
+            "goOptions.followChildren needs Delve {}.{}.{} or newer; could not determine the installed Delve's version",
+            major, minor, patch
+        ))),
+    }
+}
+
+/// Temporary directory holding the binary Delve built, removed on drop
+#[derive(Debug)]
+pub struct BuildDir(PathBuf);
+
+impl BuildDir {
+    pub fn path(&self) -> &Path {
+        &self.0
+    }
+}
+
+impl Drop for BuildDir {
+    fn drop(&mut self) {
+        match std::fs::remove_dir_all(&self.0) {
+            Ok(()) => info!("🧹 [GO] Removed build directory {}", self.0.display()),
+            Err(e) if e.kind() == std::io::ErrorKind::NotFound => {}
+            Err(e) => warn!(
+                "⚠️  [GO] Could not remove build directory {}: {}",
+                self.0.display(),
+                e
+            ),
+        }
+    }
+}
+
+/// Remove build directories in `builds_dir` whose server is no longer
+/// running
+///
+/// Covers servers of this user that were killed before their sessions
+/// ended; directories owned by anyone else (`uid`) are left alone. The pid
+/// in the name is checked against procfs; without procfs nothing is removed.
+fn remove_stale_build_dirs(builds_dir: &Path, uid: u32, proc_root: &Path) -> usize {
+    if !proc_root.join("self").exists() && !proc_root.join("1").exists() {
+        return 0;
+    }
+    let Ok(entries) = std::fs::read_dir(builds_dir) else {
+        return 0;
+    };
+    let mut removed = 0;
+    for entry in entries.filter_map(|e| e.ok()) {
+        let name = entry.file_name();
+        let Some(pid) = name
+            .to_str()
+            .and_then(|n| n.split('-').next())
+            .and_then(|pid| pid.parse::<u32>().ok())
+        else {
+            continue;
+        };
+        if !proc_root.join(pid.to_string()).exists()
+            && private_dir::owned_by(&entry.path(), uid)
+            && std::fs::remove_dir_all(entry.path()).is_ok()
+        {
+            removed += 1;
+        }
+    }
+    removed
+}
+
 /// Result of spawning Go debugger (process + connected socket)
 pub struct GoDebugSession {
     pub process: Child,
@@ -31,8 +199,12 @@ pub struct GoDebugSession {
 }
 
 impl GoAdapter {
+    /// `adapters.go.path` from the server configuration, else `dlv` from PATH
     pub fn command() -> String {
-        "dlv".to_string()
+        crate::config::current()
+            .adapter_path("go")
+            .unwrap_or("dlv")
+            .to_string()
     }
 
     /// Spawn Delve with DAP communication over TCP socket
@@ -48,10 +220,16 @@ impl GoAdapter {
     /// - Module: `program = "/path/to/module/"` (with go.mod)
     ///
     /// Delve determines the type automatically.
+    ///
+    /// With `go_path`, Delve builds with that `go` binary instead of the one
+    /// on PATH (see `toolchain`). With an `exec_prefix`, Delve runs through
+    /// it in another container or namespace (see `exec_prefix`).
     pub async fn spawn(
         _program: &str,
         _program_args: &[String],
         _stop_on_entry: bool,
+        go_path: Option<&Path>,
+        exec_prefix: &[String],
     ) -> Result<GoDebugSession> {
         // 1. Find free port
         let port = socket_helper::find_free_port()?;
@@ -66,10 +244,25 @@ impl GoAdapter {
         info!("Spawning dlv on port {}: dlv {:?}", port, args);
 
         // 3. Spawn dlv process
-        let child = Command::new("dlv")
-            .args(&args)
+        let (executable, args) = exec_prefix::wrap(exec_prefix, &Self::command(), &args);
+        let mut command = Command::new(executable);
+        command.args(&args);
+        if let Some(bin_dir) = go_path.and_then(Path::parent) {
+            let path = std::env::var_os("PATH").unwrap_or_default();
+            let paths = std::iter::once(bin_dir.to_path_buf()).chain(std::env::split_paths(&path));
+            command
+                .env(
+                    "PATH",
+                    std::env::join_paths(paths).map_err(|e| {
+                        Error::Process(format!("Invalid PATH for {}: {}", bin_dir.display(), e))
+                    })?,
+                )
+                .env_remove("GOROOT");
+        }
+        let child = command
             .spawn()
             .map_err(|e| Error::Process(format!("Failed to spawn dlv: {}", e)))?;
+        crate::process::orphans::track(child.id());
 
         // 4. Connect to socket (with 3 second timeout - dlv needs a moment to start)
         let socket = socket_helper::connect_with_retry(port, Duration::from_secs(3))
@@ -89,6 +282,148 @@ impl GoAdapter {
         "delve"
     }
 
+    /// Index of a slice or array element from its variable name (`[3]`)
+    pub fn element_index(name: &str) -> Option<i64> {
+        name.strip_prefix('[')?.strip_suffix(']')?.parse().ok()
+    }
+
+    /// Variable names Delve gives the entry of map key `key`: string keys
+    /// are quoted, other keys are shown as they are
+    pub fn map_key_names(key: &str) -> Vec<String> {
+        vec![format!("\"{}\"", key), key.to_string()]
+    }
+
+    /// A function name in Delve's syntax, from the ways Go names are written
+    ///
+    /// Accepts `Add`, `main.Add`, `(*Calculator).Multiply`,
+    /// `Calculator.Multiply`, `main.(*Calculator).Multiply`, `main.*Calculator.Multiply`
+    /// and import-path qualified names like `example.com/calc.Add`, with
+    /// stray spaces or a trailing `()`. Delve matches receivers by type name
+    /// only (`(*T)` and `T` alike) but doesn't understand `(T)` or `*T`,
+    /// which become `T` and `(*T)`.
+    pub fn function_breakpoint_name(name: &str) -> Result<String> {
+        let invalid = |why: &str| {
+            Error::InvalidRequest(format!(
+                "'{}' is not a Go function name: {}; use 'pkg.Func', 'pkg.(*Type).Method' or '(*Type).Method'",
+                name, why
+            ))
+        };
+        let compact: String = name.split_whitespace().collect();
+        let compact = compact.strip_suffix("()").unwrap_or(&compact);
+        let (path, qualified) = match compact.rfind('/') {
+            Some(slash) => compact.split_at(slash + 1),
+            None => ("", compact),
+        };
+
+        let segments = split_qualified_name(qualified);
+        if segments.iter().any(|s| s.is_empty()) {
+            return Err(invalid("empty name"));
+        }
+        let (package, receiver, function) = match segments.as_slice() {
+            [function] if path.is_empty() => (None, None, *function),
+            [first, function] if is_receiver(first) => (None, Some(*first), *function),
+            [package, function] => (Some(*package), None, *function),
+            [package, receiver, function] => (Some(*package), Some(*receiver), *function),
+            [_] => return Err(invalid("no function after the package path")),
+            _ => return Err(invalid("too many dots")),
+        };
+
+        // Instantiated generics are named `Map[...]`
+        let base = match function.split_once('[') {
+            Some((base, _)) if function.ends_with(']') => base,
+            _ => function,
+        };
+        if !is_identifier(base) {
+            return Err(invalid(&format!("'{}' is not an identifier", function)));
+        }
+        if let Some(package) = package {
+            if !is_identifier(package) {
+                return Err(invalid(&format!("'{}' is not a package name", package)));
+            }
+        }
+        let receiver = match receiver {
+            Some(receiver) => {
+                let (pointer, type_name) = match receiver.strip_prefix("(*") {
+                    Some(rest) => (true, rest.strip_suffix(')')),
+                    None => match receiver.strip_prefix('(') {
+                        Some(rest) => (false, rest.strip_suffix(')')),
+                        None => match receiver.strip_prefix('*') {
+                            Some(rest) => (true, Some(rest)),
+                            None => (false, Some(receiver)),
+                        },
+                    },
+                };
+                match type_name {
+                    Some(type_name) if is_identifier(type_name) => Some(if pointer {
+                        format!("(*{})", type_name)
+                    } else {
+                        type_name.to_string()
+                    }),
+                    _ => return Err(invalid(&format!("'{}' is not a receiver type", receiver))),
+                }
+            }
+            None => None,
+        };
+
+        let mut delve = path.to_string();
+        for part in [package, receiver.as_deref(), Some(function)]
+            .into_iter()
+            .flatten()
+        {
+            if !delve.is_empty() && !delve.ends_with('/') {
+                delve.push('.');
+            }
+            delve.push_str(part);
+        }
+        Ok(delve)
+    }
+
+    /// Why Delve couldn't set a breakpoint on function `name`: a Go program
+    /// loads no code later, so it would never be hit
+    pub fn unresolved_function(name: &str, message: Option<&str>) -> Error {
+        Error::InvalidRequest(format!(
+            "Delve couldn't find function {} in the program ({}); check the package and spelling, and write methods as 'pkg.(*Type).Method' or 'pkg.Type.Method'",
+            name,
+            message.unwrap_or("not found")
+        ))
+    }
+
+    /// Entry breakpoint for `entry: "user_main"`: a function breakpoint on
+    /// `main.main` (None in test mode, where main is the generated test runner)
+    pub fn user_main_entry(mode: &str) -> Option<super::EntryBreakpoint> {
+        (mode != "test").then(|| super::EntryBreakpoint::Function("main.main".to_string()))
+    }
+
+    /// Fail unless the installed Delve can follow child processes
+    ///
+    /// Runs `dlv version`; Delve follows exec'd children on Linux only.
+    pub async fn check_follow_exec() -> Result<()> {
+        if !cfg!(target_os = "linux") {
+            return Err(Error::InvalidRequest(
+                "goOptions.followChildren is only supported by Delve on Linux".to_string(),
+            ));
+        }
+        let output = Command::new(Self::command())
+            .arg("version")
+            .output()
+            .await
+            .map_err(|e| Error::Process(format!("Failed to run dlv version: {}", e)))?;
+        check_follow_exec_version(parse_delve_version(&String::from_utf8_lossy(
+            &output.stdout,
+        )))
+    }
+
+    /// Step filters of a new session: the standard library packages a step
+    /// into a line like `fmt.Printf(...)` would otherwise descend into
+    pub const STEP_FILTERS: &'static [&'static str] = &[
+        "fmt.*",
+        "runtime.*",
+        "reflect.*",
+        "strconv.*",
+        "sync.*",
+        "internal/*",
+    ];
+
     /// Generate DAP launch configuration for Go debugging
     ///
     /// ## Multi-File Support
@@ -112,10 +447,32 @@ impl GoAdapter {
         cwd: Option<&str>,
         stop_on_entry: bool,
     ) -> Value {
+        Self::launch_args_for_mode(program, args, cwd, stop_on_entry, "debug")
+    }
+
+    /// Launch configuration for a specific Delve mode (`debug`, `test`, `exec`)
+    ///
+    /// In `test` mode Delve expects a package, so a `_test.go` file is
+    /// replaced by its directory.
+    pub fn launch_args_for_mode(
+        program: &str,
+        args: &[String],
+        cwd: Option<&str>,
+        stop_on_entry: bool,
+        mode: &str,
+    ) -> Value {
+        let program = match mode {
+            "test" if program.ends_with(".go") => std::path::Path::new(program)
+                .parent()
+                .and_then(|dir| dir.to_str())
+                .unwrap_or(program),
+            _ => program,
+        };
+
         let mut launch = json!({
             "request": "launch",
             "type": "go",
-            "mode": "debug",
+            "mode": mode,
             "program": program,
             "args": args,
             "stopOnEntry": stop_on_entry,
@@ -127,6 +484,65 @@ impl GoAdapter {
 
         launch
     }
+
+    /// Whether Delve builds the program in this mode (rather than running a
+    /// pre-built binary)
+    pub fn builds(mode: &str) -> bool {
+        matches!(mode, "debug" | "test")
+    }
+
+    /// Create a temporary directory for the binary Delve builds and point
+    /// the launch configuration at it
+    ///
+    /// Delve's default, `__debug_bin` in its working directory, fails on
+    /// read-only source trees. It is made in a directory only this user can
+    /// reach, and removed when the returned guard is dropped, which the
+    /// session does when it ends. Returns None (leaving Delve's default) if
+    /// the directory can't be created.
+    pub fn use_build_dir(launch: &mut Value) -> Option<BuildDir> {
+        let builds_dir = match private_dir::private_temp_dir(BUILD_DIRS) {
+            Ok(dir) => dir,
+            Err(e) => {
+                warn!(
+                    "⚠️  [GO] No private directory for builds: {}; Delve builds in its working directory",
+                    e
+                );
+                return None;
+            }
+        };
+        SWEEP_STALE_BUILD_DIRS.call_once(|| {
+            let uid = private_dir::current_uid().unwrap_or_default();
+            let removed = remove_stale_build_dirs(&builds_dir, uid, Path::new("/proc"));
+            if removed > 0 {
+                info!(
+                    "🧹 [GO] Removed {} build directories of exited servers",
+                    removed
+                );
+            }
+        });
+
+        let dir = builds_dir.join(format!("{}-{}", std::process::id(), uuid::Uuid::new_v4()));
+        if let Err(e) = std::fs::create_dir_all(&dir) {
+            warn!(
+                "⚠️  [GO] Could not create build directory {}: {}; Delve builds in its working directory",
+                dir.display(),
+                e
+            );
+            return None;
+        }
+        launch["output"] = json!(dir.join(DEBUG_BINARY));
+        Some(BuildDir(dir))
+    }
+
+    /// Attach arguments for a running Go process (Delve local attach)
+    pub fn attach_args(process_id: u32) -> Value {
+        json!({
+            "request": "attach",
+            "type": "go",
+            "mode": "local",
+            "processId": process_id,
+        })
+    }
 }
 
 // ============================================================================
@@ -241,6 +657,498 @@ impl GoDebugSession {
     }
 }
 
+// ============================================================================
+// Hang Analysis
+// ============================================================================
+
+/// Maximum number of goroutines whose stacks are inspected by a hang analysis
+pub const MAX_ANALYZED_GOROUTINES: usize = 256;
+
+/// Why a goroutine is parked, from the functions on top of its stack
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, PartialOrd, Ord, Serialize)]
+pub enum WaitReason {
+    #[serde(rename = "chan receive")]
+    ChanReceive,
+    #[serde(rename = "chan send")]
+    ChanSend,
+    #[serde(rename = "select")]
+    Select,
+    #[serde(rename = "mutex")]
+    Mutex,
+    #[serde(rename = "waitgroup")]
+    WaitGroup,
+    #[serde(rename = "cond")]
+    Cond,
+    #[serde(rename = "sleep")]
+    Sleep,
+    #[serde(rename = "io wait")]
+    IoWait,
+    /// Parked for a reason not in `WAIT_REASON_FRAMES`
+    #[serde(rename = "other")]
+    Other,
+}
+
+impl WaitReason {
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            WaitReason::ChanReceive => "chan receive",
+            WaitReason::ChanSend => "chan send",
+            WaitReason::Select => "select",
+            WaitReason::Mutex => "mutex",
+            WaitReason::WaitGroup => "waitgroup",
+            WaitReason::Cond => "cond",
+            WaitReason::Sleep => "sleep",
+            WaitReason::IoWait => "io wait",
+            WaitReason::Other => "other",
+        }
+    }
+
+    /// Waits that end on their own (timers, I/O) rather than needing another goroutine
+    fn wakes_by_itself(&self) -> bool {
+        matches!(self, WaitReason::Sleep | WaitReason::IoWait)
+    }
+
+    fn is_channel(&self) -> bool {
+        matches!(
+            self,
+            WaitReason::ChanReceive | WaitReason::ChanSend | WaitReason::Select
+        )
+    }
+}
+
+/// Frame name prefixes identifying a wait, checked from the top of the stack
+const WAIT_REASON_FRAMES: &[(&str, WaitReason)] = &[
+    ("runtime.chanrecv", WaitReason::ChanReceive),
+    ("runtime.chansend", WaitReason::ChanSend),
+    ("runtime.selectgo", WaitReason::Select),
+    ("runtime.block", WaitReason::Select),
+    ("sync.runtime_SemacquireMutex", WaitReason::Mutex),
+    ("sync.runtime_SemacquireRWMutex", WaitReason::Mutex),
+    ("sync.(*Mutex).", WaitReason::Mutex),
+    ("sync.(*RWMutex).", WaitReason::Mutex),
+    ("sync.(*WaitGroup).Wait", WaitReason::WaitGroup),
+    ("sync.(*Cond).Wait", WaitReason::Cond),
+    ("time.Sleep", WaitReason::Sleep),
+    ("runtime.netpollblock", WaitReason::IoWait),
+    ("internal/poll.", WaitReason::IoWait),
+];
+
+/// Frames of the scheduler parking a goroutine
+const PARK_FRAMES: &[&str] = &[
+    "runtime.gopark",
+    "runtime.goparkunlock",
+    "runtime.semacquire",
+];
+
+/// Packages whose frames are not user code. os/signal's goroutine, started by
+/// signal.Notify, forwards signals for the runtime.
+const RUNTIME_PACKAGES: &[&str] = &[
+    "runtime.",
+    "sync.",
+    "time.",
+    "internal/",
+    "syscall.",
+    "os/signal.",
+];
+
+/// One goroutine in a hang analysis
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct GoroutineReport {
+    pub id: i32,
+    /// Innermost user (non-runtime) function
+    pub function: String,
+    pub path: Option<String>,
+    pub line: Option<i32>,
+    /// "blocked" or "running"
+    pub state: &'static str,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub wait_reason: Option<WaitReason>,
+}
+
+/// A likely cause of the hang
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct HangFinding {
+    /// "allBlocked", "channelDeadlock" or "lockDeadlock"
+    pub kind: &'static str,
+    pub goroutines: Vec<i32>,
+    pub message: String,
+}
+
+/// Structured diagnosis of a Go program that appears to hang
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct HangAnalysis {
+    /// Whether the goroutines can no longer make progress on their own
+    pub deadlock: bool,
+    pub summary: String,
+    pub findings: Vec<HangFinding>,
+    /// Goroutine ids per wait reason, plus "running"
+    pub groups: std::collections::BTreeMap<&'static str, Vec<i32>>,
+    /// User goroutines (system goroutines of the runtime are left out)
+    pub goroutines: Vec<GoroutineReport>,
+    pub system_goroutines: usize,
+    /// More goroutines existed than `MAX_ANALYZED_GOROUTINES`
+    pub truncated: bool,
+}
+
+/// `name` split at the dots outside parentheses and brackets
+fn split_qualified_name(name: &str) -> Vec<&str> {
+    let mut segments = Vec::new();
+    let (mut depth, mut start) = (0i32, 0);
+    for (i, c) in name.char_indices() {
+        match c {
+            '(' | '[' => depth += 1,
+            ')' | ']' => depth -= 1,
+            '.' if depth == 0 => {
+                segments.push(&name[start..i]);
+                start = i + 1;
+            }
+            _ => {}
+        }
+    }
+    segments.push(&name[start..]);
+    segments
+}
+
+/// Whether `segment` is written as a receiver: `(*T)` or `(T)`
+fn is_receiver(segment: &str) -> bool {
+    segment.starts_with('(') || segment.starts_with('*')
+}
+
+/// Whether `name` is a Go identifier
+fn is_identifier(name: &str) -> bool {
+    let mut chars = name.chars();
+    chars.next().is_some_and(|c| c.is_alphabetic() || c == '_')
+        && chars.all(|c| c.is_alphanumeric() || c == '_')
+}
+
+fn is_runtime_frame(name: &str) -> bool {
+    RUNTIME_PACKAGES.iter().any(|p| name.starts_with(p))
+}
+
+/// Why a goroutine is parked, or None if it was running when paused
+pub fn classify_wait(frames: &[crate::dap::types::StackFrame]) -> Option<WaitReason> {
+    // Only the runtime frames above the first user frame describe the wait
+    let runtime_top = frames.iter().take_while(|f| is_runtime_frame(&f.name));
+
+    let mut parked = false;
+    for frame in runtime_top {
+        if let Some((_, reason)) = WAIT_REASON_FRAMES
+            .iter()
+            .find(|(prefix, _)| frame.name.starts_with(prefix))
+        {
+            return Some(*reason);
+        }
+        parked |= PARK_FRAMES.iter().any(|p| frame.name.starts_with(p));
+    }
+    parked.then_some(WaitReason::Other)
+}
+
+impl GoAdapter {
+    /// Diagnose a hang from every goroutine's stack (innermost frame first)
+    pub fn analyze_hang(
+        goroutines: &[(
+            crate::dap::types::Thread,
+            Vec<crate::dap::types::StackFrame>,
+        )],
+        truncated: bool,
+    ) -> HangAnalysis {
+        let mut reports = Vec::new();
+        let mut system_goroutines = 0;
+        for (thread, frames) in goroutines {
+            let Some(user_frame) = frames.iter().find(|f| !is_runtime_frame(&f.name)) else {
+                system_goroutines += 1;
+                continue;
+            };
+            let wait_reason = classify_wait(frames);
+            reports.push(GoroutineReport {
+                id: thread.id,
+                function: user_frame.name.clone(),
+                path: user_frame.source.as_ref().and_then(|s| s.path.clone()),
+                line: Some(user_frame.line),
+                state: if wait_reason.is_some() {
+                    "blocked"
+                } else {
+                    "running"
+                },
+                wait_reason,
+            });
+        }
+
+        let mut groups: std::collections::BTreeMap<&'static str, Vec<i32>> = Default::default();
+        for report in &reports {
+            let key = report.wait_reason.map_or("running", |r| r.as_str());
+            groups.entry(key).or_default().push(report.id);
+        }
+
+        let ids = |filter: &dyn Fn(&GoroutineReport) -> bool| -> Vec<i32> {
+            reports.iter().filter(|r| filter(r)).map(|r| r.id).collect()
+        };
+        let all_blocked = !reports.is_empty() && reports.iter().all(|r| r.wait_reason.is_some());
+        let deadlock = all_blocked
+            && reports
+                .iter()
+                .all(|r| r.wait_reason.is_some_and(|w| !w.wakes_by_itself()));
+
+        let mut findings = Vec::new();
+        if deadlock {
+            findings.push(HangFinding {
+                kind: "allBlocked",
+                goroutines: ids(&|_| true),
+                message: format!(
+                    "All {} goroutines are blocked and none waits on a timer or I/O, so nothing can wake them",
+                    reports.len()
+                ),
+            });
+
+            let channel = ids(&|r| r.wait_reason.is_some_and(|w| w.is_channel()));
+            if !channel.is_empty() {
+                findings.push(HangFinding {
+                    kind: "channelDeadlock",
+                    message: format!(
+                        "Goroutines {} wait on channel operations no other goroutine can complete: {} (e.g. sends and receives on unbuffered channels waiting for each other)",
+                        join_ids(&channel),
+                        describe_waits(&reports, &channel)
+                    ),
+                    goroutines: channel,
+                });
+            }
+
+            let locks = ids(&|r| r.wait_reason == Some(WaitReason::Mutex));
+            if !locks.is_empty() {
+                findings.push(HangFinding {
+                    kind: "lockDeadlock",
+                    message: format!(
+                        "Goroutines {} wait on mutexes held by blocked goroutines: {} (check lock ordering and missing Unlock calls)",
+                        join_ids(&locks),
+                        describe_waits(&reports, &locks)
+                    ),
+                    goroutines: locks,
+                });
+            }
+        }
+
+        let summary = if reports.is_empty() {
+            "No user goroutines found".to_string()
+        } else if deadlock {
+            format!(
+                "Likely deadlock: all {} goroutines are blocked ({})",
+                reports.len(),
+                describe_groups(&groups)
+            )
+        } else if all_blocked {
+            format!(
+                "All {} goroutines are blocked, but some wait on timers or I/O ({}); the program may be slow rather than deadlocked",
+                reports.len(),
+                describe_groups(&groups)
+            )
+        } else {
+            format!(
+                "No deadlock detected: {} of {} goroutines are running ({})",
+                groups.get("running").map_or(0, Vec::len),
+                reports.len(),
+                describe_groups(&groups)
+            )
+        };
+
+        HangAnalysis {
+            deadlock,
+            summary,
+            findings,
+            groups,
+            goroutines: reports,
+            system_goroutines,
+            truncated,
+        }
+    }
+}
+
+fn join_ids(ids: &[i32]) -> String {
+    ids.iter()
+        .map(|id| id.to_string())
+        .collect::<Vec<_>>()
+        .join(", ")
+}
+
+/// "main.ping at deadlock.go:9 (chan receive)" for each goroutine
+fn describe_waits(reports: &[GoroutineReport], ids: &[i32]) -> String {
+    reports
+        .iter()
+        .filter(|r| ids.contains(&r.id))
+        .map(|r| {
+            let file = r
+                .path
+                .as_deref()
+                .and_then(|p| p.rsplit('/').next())
+                .unwrap_or("?");
+            format!(
+                "{} at {}:{} ({})",
+                r.function,
+                file,
+                r.line.unwrap_or(0),
+                r.wait_reason.map_or("running", |w| w.as_str())
+            )
+        })
+        .collect::<Vec<_>>()
+        .join("; ")
+}
+
+/// "3 chan receive, 1 running"
+fn describe_groups(groups: &std::collections::BTreeMap<&'static str, Vec<i32>>) -> String {
+    groups
+        .iter()
+        .map(|(reason, ids)| format!("{} {}", ids.len(), reason))
+        .collect::<Vec<_>>()
+        .join(", ")
+}
+
+// ============================================================================
+// Goroutine Listing
+// ============================================================================
+
+/// Goroutines listed per page when the caller doesn't say
+pub const DEFAULT_GOROUTINE_PAGE: usize = 100;
+
+/// Most goroutines listed per page
+pub const MAX_GOROUTINE_PAGE: usize = 1000;
+
+/// Frames loaded per goroutine to tell where it waits
+pub const GOROUTINE_STATE_FRAMES: i32 = 16;
+
+/// Goroutines Delve lists in a `threads` response at most (its
+/// maxGoroutines); programs with more have the rest left out
+pub const DELVE_MAX_THREADS: usize = 1024;
+
+/// What Delve's name for a goroutine thread says: `* [Go 7] main.worker (Thread 4242)`
+///
+/// The function is the goroutine's innermost user function (Delve's
+/// `UserCurrent`), the `*` marks the goroutine the program stopped in, and
+/// goroutines running on an OS thread name it.
+#[derive(Debug, Clone, PartialEq)]
+pub struct DelveThreadName {
+    pub goroutine_id: i64,
+    pub function: String,
+    pub current: bool,
+    pub os_thread: Option<i64>,
+}
+
+/// Parse Delve's name for a goroutine thread; None for names of another
+/// form (older Delve versions, other debuggers)
+pub fn parse_thread_name(name: &str) -> Option<DelveThreadName> {
+    let (current, rest) = match name.strip_prefix("* ") {
+        Some(rest) => (true, rest),
+        None => (false, name),
+    };
+    let (inside, rest) = rest.strip_prefix("[Go ")?.split_once(']')?;
+    // Pprof labels follow the id when Delve shows them
+    let goroutine_id = inside.split_whitespace().next()?.parse().ok()?;
+    let rest = rest.trim();
+    let (function, os_thread) = match rest.rsplit_once(" (Thread ") {
+        Some((function, thread)) => (function, thread.strip_suffix(')')?.parse().ok()),
+        None => (rest, None),
+    };
+    Some(DelveThreadName {
+        goroutine_id,
+        function: function.to_string(),
+        current,
+        os_thread,
+    })
+}
+
+/// One goroutine in a `debugger_list_goroutines` page
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct GoroutineSummary {
+    /// The thread id to pass to other tools (Delve uses the goroutine id)
+    pub id: i32,
+    /// Innermost user function; the top frame's function for goroutines of
+    /// the runtime alone
+    pub function: Option<String>,
+    pub path: Option<String>,
+    pub line: Option<i32>,
+    /// "blocked", "running", or "unknown" when its stack couldn't be read
+    pub state: &'static str,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub wait_reason: Option<WaitReason>,
+    /// The goroutine the program stopped in
+    #[serde(skip_serializing_if = "std::ops::Not::not")]
+    pub current: bool,
+    /// OS thread the goroutine was running on
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub os_thread: Option<i64>,
+    /// Only runtime frames (GC workers, the finalizer goroutine, ...)
+    #[serde(skip_serializing_if = "std::ops::Not::not")]
+    pub system: bool,
+}
+
+/// A page of the goroutine listing
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct GoroutinePage {
+    pub goroutines: Vec<GoroutineSummary>,
+    /// Goroutines listed; only a lower bound when `truncated`
+    pub total: usize,
+    pub offset: usize,
+    /// Offset of the next page, if there is one
+    pub next_offset: Option<usize>,
+    /// Delve listed `DELVE_MAX_THREADS` goroutines, so more may exist that
+    /// no page shows
+    pub truncated: bool,
+    /// Where functions came from: "threadNames" (Delve's names, checked
+    /// against the stacks) or "stackTrace" (names Delve didn't format)
+    pub source: &'static str,
+}
+
+impl GoAdapter {
+    /// Summarize a goroutine from its thread and the top of its stack
+    /// (None when the stack couldn't be read)
+    ///
+    /// The function comes from the stack where there is one, else from
+    /// Delve's thread name.
+    pub fn summarize_goroutine(
+        thread: &crate::dap::types::Thread,
+        frames: Option<&[crate::dap::types::StackFrame]>,
+    ) -> GoroutineSummary {
+        let named = parse_thread_name(&thread.name);
+        let mut summary = GoroutineSummary {
+            id: thread.id,
+            function: named.as_ref().map(|n| n.function.clone()),
+            path: None,
+            line: None,
+            state: "unknown",
+            wait_reason: None,
+            current: named.as_ref().is_some_and(|n| n.current),
+            os_thread: named.as_ref().and_then(|n| n.os_thread),
+            system: false,
+        };
+        let Some(frames) = frames.filter(|frames| !frames.is_empty()) else {
+            return summary;
+        };
+        summary.wait_reason = classify_wait(frames);
+        summary.state = if summary.wait_reason.is_some() {
+            "blocked"
+        } else {
+            "running"
+        };
+        let frame = match frames.iter().find(|f| !is_runtime_frame(&f.name)) {
+            Some(frame) => frame,
+            // Only the top frames are loaded, so user code may be further
+            // down a deep runtime stack; Delve's name knows
+            None if named.is_some_and(|n| !is_runtime_frame(&n.function)) => return summary,
+            None => {
+                summary.system = true;
+                &frames[0]
+            }
+        };
+        summary.function = Some(frame.name.clone());
+        summary.path = frame.source.as_ref().and_then(|s| s.path.clone());
+        summary.line = Some(frame.line);
+        summary
+    }
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -255,6 +1163,46 @@ mod tests {
         assert_eq!(GoAdapter::adapter_id(), "delve");
     }
 
+    #[test]
+    fn test_function_breakpoint_names_in_delve_syntax() {
+        for (name, delve) in [
+            ("Add", "Add"),
+            ("main.Add", "main.Add"),
+            (" main.Add() ", "main.Add"),
+            ("(*Calculator).Multiply", "(*Calculator).Multiply"),
+            ("( *Calculator ).Multiply", "(*Calculator).Multiply"),
+            ("(Calculator).Multiply", "Calculator.Multiply"),
+            ("Calculator.Multiply", "Calculator.Multiply"),
+            ("main.(*Calculator).Multiply", "main.(*Calculator).Multiply"),
+            ("main.*Calculator.Multiply", "main.(*Calculator).Multiply"),
+            ("main.Calculator.Divide", "main.Calculator.Divide"),
+            ("example.com/calc.Add", "example.com/calc.Add"),
+            ("example.com/calc.(*T).M", "example.com/calc.(*T).M"),
+            ("main.Map[...]", "main.Map[...]"),
+        ] {
+            assert_eq!(
+                GoAdapter::function_breakpoint_name(name).unwrap(),
+                delve,
+                "{}",
+                name
+            );
+        }
+
+        for name in [
+            "",
+            "main.",
+            "main..Add",
+            "a.b.c.d",
+            "main.1Add",
+            "main.(*).M",
+            "example.com/calc",
+            "main.Add-x",
+        ] {
+            let err = GoAdapter::function_breakpoint_name(name).unwrap_err();
+            assert!(err.to_string().contains("pkg.(*Type).Method"), "{}", err);
+        }
+    }
+
     #[test]
     fn test_launch_args_without_cwd() {
         let program = "/path/to/main.go";
@@ -302,6 +1250,169 @@ mod tests {
         assert_eq!(launch["mode"], "debug");
     }
 
+    #[test]
+    fn test_launch_args_test_mode_uses_package_dir() {
+        let launch = GoAdapter::launch_args_for_mode(
+            "/workspace/pkg/calc_test.go",
+            &["-test.run".to_string(), "TestAdd".to_string()],
+            None,
+            false,
+            "test",
+        );
+
+        assert_eq!(launch["mode"], "test");
+        assert_eq!(launch["program"], "/workspace/pkg");
+        assert_eq!(launch["args"], json!(["-test.run", "TestAdd"]));
+
+        let launch = GoAdapter::launch_args_for_mode("/workspace/pkg", &[], None, false, "test");
+        assert_eq!(launch["program"], "/workspace/pkg");
+    }
+
+    #[test]
+    fn test_launch_args_exec_mode() {
+        let launch = GoAdapter::launch_args_for_mode("/workspace/bin/app", &[], None, true, "exec");
+
+        assert_eq!(launch["mode"], "exec");
+        assert_eq!(launch["program"], "/workspace/bin/app");
+    }
+
+    #[test]
+    fn test_launch_options_substitute_path() {
+        let options: GoLaunchOptions = serde_json::from_value(json!({
+            "substitutePath": [{"from": "/home/me/src/app", "to": "/build/app"}]
+        }))
+        .unwrap();
+
+        let mut launch =
+            GoAdapter::launch_args_for_mode("/workspace/bin/app", &[], None, false, "exec");
+        options.apply(&mut launch);
+        assert_eq!(
+            launch["substitutePath"],
+            json!([{"from": "/home/me/src/app", "to": "/build/app"}])
+        );
+
+        let mut launch = GoAdapter::launch_args_for_mode("main.go", &[], None, false, "debug");
+        GoLaunchOptions::default().apply(&mut launch);
+        assert!(launch.get("substitutePath").is_none());
+        assert!(launch.get("output").is_none());
+    }
+
+    #[test]
+    fn test_local_source_path() {
+        let rules = vec![
+            SubstitutePath {
+                from: "/home/me/src/app/".to_string(),
+                to: "/build/app".to_string(),
+            },
+            SubstitutePath {
+                from: "/home/me/go".to_string(),
+                to: "/go".to_string(),
+            },
+        ];
+        for (recorded, local) in [
+            ("/build/app/cmd/main.go", "/home/me/src/app/cmd/main.go"),
+            ("/go/pkg/mod/x.go", "/home/me/go/pkg/mod/x.go"),
+            // Only whole path components
+            ("/build/application/main.go", "/build/application/main.go"),
+            ("/gopher/main.go", "/gopher/main.go"),
+            ("relative/main.go", "relative/main.go"),
+        ] {
+            assert_eq!(local_source_path(&rules, recorded), local);
+        }
+        assert_eq!(
+            local_source_path(&[], "/build/app/main.go"),
+            "/build/app/main.go"
+        );
+    }
+
+    #[test]
+    fn test_build_output_location() {
+        let options: GoLaunchOptions =
+            serde_json::from_value(json!({"output": "/tmp/build/app.bin"})).unwrap();
+        let mut launch = GoAdapter::launch_args_for_mode("main.go", &[], None, false, "debug");
+        options.apply(&mut launch);
+        assert_eq!(launch["output"], "/tmp/build/app.bin");
+
+        assert!(GoAdapter::builds("test") && !GoAdapter::builds("exec"));
+        let mut launch = GoAdapter::launch_args_for_mode("main.go", &[], None, false, "debug");
+        let dir = GoAdapter::use_build_dir(&mut launch).unwrap();
+        let path = dir.path().to_path_buf();
+        assert!(path.is_dir());
+        assert_eq!(
+            launch["output"].as_str().map(PathBuf::from),
+            Some(path.join("__debug_bin"))
+        );
+        drop(dir);
+        assert!(!path.exists());
+    }
+
+    #[test]
+    fn test_user_main_entry() {
+        assert_eq!(
+            GoAdapter::user_main_entry("debug"),
+            Some(super::super::EntryBreakpoint::Function(
+                "main.main".to_string()
+            ))
+        );
+        assert!(GoAdapter::user_main_entry("exec").is_some());
+        assert_eq!(GoAdapter::user_main_entry("test"), None);
+    }
+
+    #[test]
+    fn test_follow_children() {
+        let options: GoLaunchOptions =
+            serde_json::from_value(json!({"followChildren": true})).unwrap();
+        let mut launch = GoAdapter::launch_args_for_mode("main.go", &[], None, false, "debug");
+        options.apply(&mut launch);
+        assert_eq!(launch["followExec"], true);
+
+        let output = "Delve Debugger\nVersion: 1.24.2\nBuild: $Id: 8ae6b4a $\n";
+        assert_eq!(parse_delve_version(output), Some((1, 24, 2)));
+        assert_eq!(parse_delve_version("Version: v1.25"), Some((1, 25, 0)));
+        assert_eq!(parse_delve_version("dlv: command not found"), None);
+
+        assert!(check_follow_exec_version(Some((1, 24, 2))).is_ok());
+        let err = check_follow_exec_version(Some((1, 20, 1))).unwrap_err();
+        assert!(err.to_string().contains("the installed Delve is 1.20.1"));
+        let err = check_follow_exec_version(None).unwrap_err();
+        assert!(err.to_string().contains("could not determine"));
+    }
+
+    #[test]
+    fn test_stale_build_dirs_of_exited_servers_removed() {
+        let proc_root = tempfile::tempdir().unwrap();
+        let temp = tempfile::tempdir().unwrap();
+        let uid = private_dir::current_uid().unwrap_or_default();
+        std::fs::create_dir(proc_root.path().join("1")).unwrap();
+        std::fs::create_dir(proc_root.path().join("4242")).unwrap();
+        for name in ["4242-live", "999999-dead", "unrelated"] {
+            std::fs::create_dir(temp.path().join(name)).unwrap();
+        }
+
+        // Only this user's directories are removed
+        assert_eq!(
+            remove_stale_build_dirs(temp.path(), uid + 1, proc_root.path()),
+            0
+        );
+        assert_eq!(
+            remove_stale_build_dirs(temp.path(), uid, proc_root.path()),
+            1
+        );
+        assert!(temp.path().join("4242-live").exists());
+        assert!(!temp.path().join("999999-dead").exists());
+        assert!(temp.path().join("unrelated").exists());
+    }
+
+    #[test]
+    fn test_attach_args() {
+        let attach = GoAdapter::attach_args(4321);
+
+        assert_eq!(attach["request"], "attach");
+        assert_eq!(attach["type"], "go");
+        assert_eq!(attach["mode"], "local");
+        assert_eq!(attach["processId"], 4321);
+    }
+
     #[test]
     fn test_debug_adapter_logger_trait() {
         let adapter = GoAdapter;
@@ -314,4 +1425,247 @@ mod tests {
         assert!(!adapter.requires_workaround()); // Go does NOT use entry breakpoint workaround
         assert_eq!(adapter.workaround_reason(), None);
     }
+
+    mod hang {
+        use super::*;
+        use crate::dap::types::{Source, StackFrame, Thread};
+
+        fn frame(name: &str, path: &str, line: i32) -> StackFrame {
+            StackFrame {
+                id: 0,
+                name: name.to_string(),
+                source: Some(Source {
+                    name: None,
+                    path: Some(path.to_string()),
+                    source_reference: None,
+                    checksums: None,
+                }),
+                line,
+                column: 0,
+                end_line: None,
+                end_column: None,
+                instruction_pointer_reference: None,
+            }
+        }
+
+        fn goroutine(id: i32, frames: &[(&str, &str, i32)]) -> (Thread, Vec<StackFrame>) {
+            (
+                Thread {
+                    id,
+                    name: format!("[Go {}] {}", id, frames.last().unwrap().0),
+                },
+                frames.iter().map(|(n, p, l)| frame(n, p, *l)).collect(),
+            )
+        }
+
+        const FIXTURE: &str = "/workspace/tests/fixtures/go/deadlock.go";
+        const PROC: &str = "/usr/local/go/src/runtime/proc.go";
+        const CHAN: &str = "/usr/local/go/src/runtime/chan.go";
+
+        /// Stacks Delve reports for tests/fixtures/go/deadlock.go
+        fn deadlock_fixture() -> Vec<(Thread, Vec<StackFrame>)> {
+            vec![
+                goroutine(
+                    1,
+                    &[
+                        ("runtime.gopark", PROC, 425),
+                        ("runtime.chanrecv", CHAN, 639),
+                        ("runtime.chanrecv1", CHAN, 489),
+                        ("main.main", FIXTURE, 30),
+                        ("runtime.main", PROC, 283),
+                    ],
+                ),
+                goroutine(
+                    2,
+                    &[
+                        ("runtime.gopark", PROC, 425),
+                        ("runtime.goparkunlock", PROC, 430),
+                        ("runtime.forcegchelper", PROC, 337),
+                        ("runtime.goexit", PROC, 1700),
+                    ],
+                ),
+                goroutine(
+                    5,
+                    &[
+                        (
+                            "runtime.notetsleepg",
+                            "/usr/local/go/src/runtime/lock_futex.go",
+                            227,
+                        ),
+                        (
+                            "os/signal.signal_recv",
+                            "/usr/local/go/src/runtime/sigqueue.go",
+                            152,
+                        ),
+                        (
+                            "os/signal.loop",
+                            "/usr/local/go/src/os/signal/signal_unix.go",
+                            23,
+                        ),
+                        ("runtime.goexit", PROC, 1700),
+                    ],
+                ),
+                goroutine(
+                    6,
+                    &[
+                        ("runtime.gopark", PROC, 425),
+                        ("runtime.chanrecv", CHAN, 639),
+                        ("runtime.chanrecv1", CHAN, 489),
+                        ("main.ping", FIXTURE, 13),
+                        ("runtime.goexit", PROC, 1700),
+                    ],
+                ),
+                goroutine(
+                    7,
+                    &[
+                        ("runtime.gopark", PROC, 425),
+                        ("runtime.chanrecv", CHAN, 639),
+                        ("runtime.chanrecv1", CHAN, 489),
+                        ("main.ping", FIXTURE, 13),
+                        ("runtime.goexit", PROC, 1700),
+                    ],
+                ),
+            ]
+        }
+
+        #[test]
+        fn test_delve_thread_names() {
+            assert_eq!(
+                parse_thread_name("* [Go 1] main.main (Thread 4242)"),
+                Some(DelveThreadName {
+                    goroutine_id: 1,
+                    function: "main.main".to_string(),
+                    current: true,
+                    os_thread: Some(4242),
+                })
+            );
+            let labelled = parse_thread_name("[Go 7 tenant:acme] main.(*Server).handle").unwrap();
+            assert_eq!(labelled.goroutine_id, 7);
+            assert_eq!(labelled.function, "main.(*Server).handle");
+            assert!(!labelled.current && labelled.os_thread.is_none());
+            assert_eq!(parse_thread_name("main"), None);
+        }
+
+        #[test]
+        fn test_goroutine_summaries() {
+            let fixture = deadlock_fixture();
+            let (thread, frames) = &fixture[3];
+            let summary = GoAdapter::summarize_goroutine(thread, Some(frames));
+            assert_eq!(summary.function.as_deref(), Some("main.ping"));
+            assert_eq!((summary.state, summary.line), ("blocked", Some(13)));
+            assert_eq!(summary.wait_reason, Some(WaitReason::ChanReceive));
+            assert!(!summary.system);
+
+            let (thread, frames) = &fixture[1];
+            let summary = GoAdapter::summarize_goroutine(thread, Some(frames));
+            assert!(summary.system);
+            assert_eq!(summary.function.as_deref(), Some("runtime.gopark"));
+
+            let (thread, frames) = &fixture[2];
+            let summary = GoAdapter::summarize_goroutine(thread, Some(frames));
+            assert!(summary.system, "signal.Notify's goroutine is not user code");
+
+            // Without a stack, Delve's name is all there is
+            let thread = Thread {
+                id: 9,
+                name: "* [Go 9] main.worker (Thread 12)".to_string(),
+            };
+            let summary = GoAdapter::summarize_goroutine(&thread, None);
+            assert_eq!(summary.function.as_deref(), Some("main.worker"));
+            assert_eq!(summary.state, "unknown");
+            assert!(summary.current);
+            assert_eq!(summary.os_thread, Some(12));
+        }
+
+        #[test]
+        fn test_unbuffered_channel_deadlock_fixture() {
+            let analysis = GoAdapter::analyze_hang(&deadlock_fixture(), false);
+
+            assert!(analysis.deadlock);
+            // The garbage collector's helper and the signal goroutine
+            assert_eq!(analysis.system_goroutines, 2);
+            assert_eq!(analysis.groups.get("chan receive"), Some(&vec![1, 6, 7]));
+            assert!(analysis
+                .summary
+                .starts_with("Likely deadlock: all 3 goroutines"));
+
+            let kinds: Vec<&str> = analysis.findings.iter().map(|f| f.kind).collect();
+            assert_eq!(kinds, vec!["allBlocked", "channelDeadlock"]);
+            let channel = &analysis.findings[1];
+            assert_eq!(channel.goroutines, vec![1, 6, 7]);
+            assert!(channel
+                .message
+                .contains("main.ping at deadlock.go:13 (chan receive)"));
+
+            let main = &analysis.goroutines[0];
+            assert_eq!(
+                (main.function.as_str(), main.line, main.state),
+                ("main.main", Some(30), "blocked")
+            );
+        }
+
+        #[test]
+        fn test_running_or_sleeping_goroutines_are_not_deadlocked() {
+            let mut goroutines = deadlock_fixture();
+            goroutines.push(goroutine(
+                9,
+                &[
+                    ("runtime.gopark", PROC, 425),
+                    ("time.Sleep", "/usr/local/go/src/runtime/time.go", 195),
+                    ("main.poll", FIXTURE, 30),
+                ],
+            ));
+            let analysis = GoAdapter::analyze_hang(&goroutines, false);
+            assert!(!analysis.deadlock);
+            assert!(analysis.findings.is_empty());
+            assert!(analysis.summary.contains("timers or I/O"));
+
+            goroutines.push(goroutine(10, &[("main.spin", FIXTURE, 40)]));
+            let analysis = GoAdapter::analyze_hang(&goroutines, false);
+            assert_eq!(analysis.groups.get("running"), Some(&vec![10]));
+            assert!(analysis.summary.starts_with("No deadlock detected: 1 of 5"));
+        }
+
+        #[test]
+        fn test_classify_wait() {
+            let stack = |names: &[&str]| -> Vec<StackFrame> {
+                names.iter().map(|n| frame(n, FIXTURE, 1)).collect()
+            };
+            let cases: &[(&[&str], Option<WaitReason>)] = &[
+                (
+                    &["runtime.gopark", "runtime.selectgo", "main.loop"],
+                    Some(WaitReason::Select),
+                ),
+                (
+                    &[
+                        "runtime.gopark",
+                        "runtime.semacquire1",
+                        "sync.runtime_SemacquireMutex",
+                        "sync.(*Mutex).lockSlow",
+                        "main.update",
+                    ],
+                    Some(WaitReason::Mutex),
+                ),
+                (
+                    &[
+                        "runtime.gopark",
+                        "runtime.chansend",
+                        "runtime.chansend1",
+                        "main.ping",
+                    ],
+                    Some(WaitReason::ChanSend),
+                ),
+                (
+                    &["runtime.gopark", "sync.(*WaitGroup).Wait", "main.main"],
+                    Some(WaitReason::WaitGroup),
+                ),
+                (&["runtime.gopark", "main.custom"], Some(WaitReason::Other)),
+                // A user frame above the runtime ends the search
+                (&["main.work", "runtime.chanrecv1"], None),
+            ];
+            for (names, expected) in cases {
+                assert_eq!(classify_wait(&stack(names)), *expected, "{:?}", names);
+            }
+        }
+    }
 }
@@ -0,0 +1,284 @@
+//! pprof labels of Go goroutines
+//!
+//! Services tag goroutines with pprof labels (`pprof.Do(ctx,
+//! pprof.Labels("tenant", "acme"), ...)`), which the runtime keeps behind
+//! the goroutine's `labels` pointer. Delve has no request for them, but its
+//! expressions can read them: `runtime.curg` is the goroutine of the frame
+//! evaluated in, and the pointer is cast to `runtime/pprof`'s `labelMap`.
+//!
+//! How `labelMap` stores the labels changed between Go versions (a map, then
+//! a sorted list of key/value pairs whose field names changed again; see
+//! `LabelLayout`), so reading them tries each layout in turn, and a goroutine
+//! none works for reports its labels as unavailable rather than failing.
+//!
+//! A breakpoint's `goroutineLabel` becomes a Delve condition comparing the
+//! first `MAX_CONDITION_LABELS` labels, for the layout the toolchain's own
+//! `runtime/pprof` source declares.
+
+use super::toolchain;
+use crate::{Error, Result};
+use serde::{Deserialize, Serialize};
+use std::collections::BTreeMap;
+use std::path::Path;
+
+/// Labels a label condition compares; a goroutine rarely has more
+pub const MAX_CONDITION_LABELS: usize = 8;
+
+/// The goroutine's `labels` pointer
+pub const LABELS_POINTER: &str = "runtime.curg.labels";
+
+/// The goroutine's `labelMap`
+const LABEL_MAP: &str = "(*(*\"runtime/pprof.labelMap\")(runtime.curg.labels))";
+
+/// A label to stop goroutines by
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct GoroutineLabel {
+    pub key: String,
+    pub value: String,
+}
+
+/// How the runtime stores a goroutine's labels
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum LabelLayout {
+    /// `labelMap struct{ label.Set }`: a sorted `List` of `Key`/`Value` pairs
+    Set,
+    /// `labelMap struct{ LabelSet }`: a sorted `list` of `key`/`value` pairs
+    LabelSet,
+    /// `labelMap map[string]string` (older Go)
+    Map,
+}
+
+impl LabelLayout {
+    /// Every layout, newest first
+    pub const ALL: [LabelLayout; 3] = [LabelLayout::Set, LabelLayout::LabelSet, LabelLayout::Map];
+
+    /// The layout `runtime/pprof`'s `label.go` declares
+    pub fn from_source(source: &str) -> Option<Self> {
+        let declaration = source.split("type labelMap ").nth(1)?;
+        if declaration.starts_with("map[string]string") {
+            return Some(LabelLayout::Map);
+        }
+        let body = declaration.strip_prefix("struct {")?.split('}').next()?;
+        match body.trim() {
+            "label.Set" => Some(LabelLayout::Set),
+            "LabelSet" => Some(LabelLayout::LabelSet),
+            _ => None,
+        }
+    }
+
+    /// Expression whose value lists the labels of the goroutine evaluated in
+    pub fn labels_expression(self) -> String {
+        match self {
+            LabelLayout::Set => format!("{}.Set.List", LABEL_MAP),
+            LabelLayout::LabelSet => format!("{}.LabelSet.list", LABEL_MAP),
+            LabelLayout::Map => LABEL_MAP.to_string(),
+        }
+    }
+
+    /// Delve condition true in goroutines labelled `label`
+    ///
+    /// The map layout can't be tested for a key: Delve fails the index of a
+    /// missing key, and a failing condition stops like a true one.
+    pub fn condition(self, label: &GoroutineLabel) -> Result<String> {
+        let (key_field, value_field) = match self {
+            LabelLayout::Set => ("Key", "Value"),
+            LabelLayout::LabelSet => ("key", "value"),
+            LabelLayout::Map => {
+                return Err(Error::InvalidRequest(
+                    "goroutineLabel needs a Go version that stores pprof labels as a list; this toolchain stores them as a map, which Delve conditions can't test for a key".to_string(),
+                ))
+            }
+        };
+        let list = self.labels_expression();
+        let (key, value) = (go_quote(&label.key)?, go_quote(&label.value)?);
+        let entries: Vec<String> = (0..MAX_CONDITION_LABELS)
+            .map(|i| {
+                format!(
+                    "len({list}) > {i} && {list}[{i}].{key_field} == {key} && {list}[{i}].{value_field} == {value}"
+                )
+            })
+            .collect();
+        Ok(format!(
+            "{} != nil && ({})",
+            LABELS_POINTER,
+            entries.join(" || ")
+        ))
+    }
+}
+
+/// A Go string literal of `text`; control characters are refused
+fn go_quote(text: &str) -> Result<String> {
+    if text.chars().any(char::is_control) {
+        return Err(Error::InvalidRequest(format!(
+            "Goroutine label {:?} contains control characters",
+            text
+        )));
+    }
+    Ok(format!(
+        "\"{}\"",
+        text.replace('\\', "\\\\").replace('"', "\\\"")
+    ))
+}
+
+/// Whether Delve's value of the `labels` pointer is nil (no labels)
+pub fn is_nil_pointer(value: &str) -> bool {
+    value.contains("(0x0)") || value.trim() == "nil"
+}
+
+/// Labels from Delve's value of a `labels_expression`: its string literals,
+/// taken as key/value pairs in order
+///
+/// `map[string]string ["tenant": "acme", ]` and `[]label len: 1, cap: 1,
+/// [{key: "tenant", value: "acme"}]` both work. None when the literals don't
+/// pair up.
+pub fn parse_labels(value: &str) -> Option<BTreeMap<String, String>> {
+    let strings = string_literals(value)?;
+    if strings.len() % 2 != 0 {
+        return None;
+    }
+    Some(
+        strings
+            .chunks(2)
+            .map(|pair| (pair[0].clone(), pair[1].clone()))
+            .collect(),
+    )
+}
+
+/// The double-quoted string literals of a value, unescaped
+fn string_literals(value: &str) -> Option<Vec<String>> {
+    let mut strings = Vec::new();
+    let mut chars = value.chars();
+    while let Some(c) = chars.next() {
+        if c != '"' {
+            continue;
+        }
+        let mut literal = String::new();
+        loop {
+            match chars.next()? {
+                '"' => break,
+                '\\' => match chars.next()? {
+                    'n' => literal.push('\n'),
+                    't' => literal.push('\t'),
+                    escaped => literal.push(escaped),
+                },
+                c => literal.push(c),
+            }
+        }
+        strings.push(literal);
+    }
+    Some(strings)
+}
+
+/// Layout of the labels in programs built by the `go` binary `go` (a
+/// validated `goPath`, or `go` for the one on PATH), read from its GOROOT's
+/// `runtime/pprof` source
+pub async fn toolchain_layout(go: &Path) -> Result<LabelLayout> {
+    let goroot = toolchain::go_root(go).await?;
+    let source_path = goroot.join("src/runtime/pprof/label.go");
+    let source = tokio::fs::read_to_string(&source_path).await.map_err(|e| {
+        Error::InvalidRequest(format!(
+            "Can't tell how this Go version stores goroutine labels: {}: {}",
+            source_path.display(),
+            e
+        ))
+    })?;
+    LabelLayout::from_source(&source).ok_or_else(|| {
+        Error::InvalidRequest(format!(
+            "Can't tell how this Go version stores goroutine labels: {} declares an unknown labelMap",
+            source_path.display()
+        ))
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_layouts_from_source() {
+        for (source, layout) in [
+            (
+                "type labelMap struct {\n\tlabel.Set\n}\n",
+                Some(LabelLayout::Set),
+            ),
+            (
+                "type labelMap struct {\n\tLabelSet\n}\n",
+                Some(LabelLayout::LabelSet),
+            ),
+            ("type labelMap map[string]string\n", Some(LabelLayout::Map)),
+            ("type labelMap []label\n", None),
+            ("package pprof\n", None),
+        ] {
+            assert_eq!(LabelLayout::from_source(source), layout, "{}", source);
+        }
+    }
+
+    #[cfg(unix)]
+    #[tokio::test]
+    async fn test_layout_of_the_given_toolchain() {
+        use std::os::unix::fs::PermissionsExt;
+
+        // A toolchain whose GOROOT declares the map layout
+        let dir = tempfile::tempdir().unwrap();
+        let pprof = dir.path().join("root/src/runtime/pprof");
+        std::fs::create_dir_all(&pprof).unwrap();
+        std::fs::write(pprof.join("label.go"), "type labelMap map[string]string\n").unwrap();
+        let go = dir.path().join("go");
+        std::fs::write(
+            &go,
+            format!(
+                "#!/bin/sh\n[ \"$1 $2\" = \"env GOROOT\" ] && echo {}/root\n",
+                dir.path().display()
+            ),
+        )
+        .unwrap();
+        std::fs::set_permissions(&go, std::fs::Permissions::from_mode(0o755)).unwrap();
+        assert_eq!(toolchain_layout(&go).await.unwrap(), LabelLayout::Map);
+
+        std::fs::write(&go, "#!/bin/sh\necho 'go: unknown' >&2\nexit 2\n").unwrap();
+        let err = toolchain_layout(&go).await.unwrap_err();
+        assert!(err.to_string().contains("go: unknown"), "{}", err);
+    }
+
+    #[test]
+    fn test_parse_labels() {
+        let labels = parse_labels(
+            "[]internal/runtime/pprof/label.Label len: 2, cap: 2, [{Key: \"req\", Value: \"4\\\"2\"},{Key: \"tenant\", Value: \"acme\"}]",
+        )
+        .unwrap();
+        assert_eq!(labels["tenant"], "acme");
+        assert_eq!(labels["req"], "4\"2");
+        let labels = parse_labels("map[string]string [\"tenant\": \"acme\", ]").unwrap();
+        assert_eq!(labels.len(), 1);
+        assert_eq!(
+            parse_labels("[]label len: 0, cap: 0, []"),
+            Some(BTreeMap::new())
+        );
+        assert_eq!(parse_labels("{key: \"cut"), None);
+        assert!(is_nil_pointer("unsafe.Pointer(0x0)"));
+        assert!(!is_nil_pointer("unsafe.Pointer(0xc000010030)"));
+    }
+
+    #[test]
+    fn test_label_conditions() {
+        let label = GoroutineLabel {
+            key: "tenant".to_string(),
+            value: "a\"cme".to_string(),
+        };
+        let condition = LabelLayout::Set.condition(&label).unwrap();
+        assert!(condition.starts_with("runtime.curg.labels != nil && (len("));
+        assert!(condition.contains(".Set.List[0].Key == \"tenant\" && "));
+        assert!(condition.contains(".Set.List[7].Value == \"a\\\"cme\")"));
+        assert_eq!(condition.matches(" || ").count(), MAX_CONDITION_LABELS - 1);
+        assert!(LabelLayout::LabelSet
+            .condition(&label)
+            .unwrap()
+            .contains(".LabelSet.list[0].key"));
+        assert!(LabelLayout::Map.condition(&label).is_err());
+        let label = GoroutineLabel {
+            key: "k\n".to_string(),
+            value: String::new(),
+        };
+        assert!(LabelLayout::Set.condition(&label).is_err());
+    }
+}
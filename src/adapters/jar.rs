@@ -0,0 +1,416 @@
+//! Reading one entry of a jar (zip) file
+//!
+//! Just enough of the zip format to take `META-INF/MANIFEST.MF` out of a
+//! jar: the central directory is found from its end record, and the entry
+//! is read stored or deflated (RFC 1951). Zip64, encryption and other
+//! compression methods aren't needed for manifests and are refused.
+
+/// Largest entry inflated; a manifest is a few kilobytes
+const MAX_ENTRY_BYTES: usize = 1 << 20;
+
+const END_OF_CENTRAL_DIRECTORY: u32 = 0x0605_4b50;
+const CENTRAL_DIRECTORY_HEADER: u32 = 0x0201_4b50;
+const LOCAL_FILE_HEADER: u32 = 0x0403_4b50;
+
+/// Contents of the entry `name` of the zip archive `archive`; None if it
+/// has no such entry
+pub fn read_entry(archive: &[u8], name: &str) -> Result<Option<Vec<u8>>, String> {
+    let end = find_end_record(archive).ok_or("not a zip file")?;
+    let entries = u16_at(archive, end + 10).ok_or("truncated end record")?;
+    let mut at = u32_at(archive, end + 16).ok_or("truncated end record")? as usize;
+
+    for _ in 0..entries {
+        if u32_at(archive, at) != Some(CENTRAL_DIRECTORY_HEADER) {
+            return Err("corrupt central directory".to_string());
+        }
+        let field = |offset: usize| u16_at(archive, at + offset).map(usize::from);
+        let (Some(method), Some(name_len), Some(extra_len), Some(comment_len)) =
+            (field(10), field(28), field(30), field(32))
+        else {
+            return Err("truncated central directory".to_string());
+        };
+        let compressed = u32_at(archive, at + 20).ok_or("truncated central directory")? as usize;
+        let local = u32_at(archive, at + 42).ok_or("truncated central directory")? as usize;
+        let entry_name = archive
+            .get(at + 46..at + 46 + name_len)
+            .ok_or("truncated central directory")?;
+        if entry_name == name.as_bytes() {
+            return read_local(archive, local, method, compressed).map(Some);
+        }
+        at += 46 + name_len + extra_len + comment_len;
+    }
+    Ok(None)
+}
+
+/// Data of the entry whose local header is at `at`
+fn read_local(archive: &[u8], at: usize, method: usize, size: usize) -> Result<Vec<u8>, String> {
+    if u32_at(archive, at) != Some(LOCAL_FILE_HEADER) {
+        return Err("corrupt local header".to_string());
+    }
+    let name_len = usize::from(u16_at(archive, at + 26).ok_or("truncated local header")?);
+    let extra_len = usize::from(u16_at(archive, at + 28).ok_or("truncated local header")?);
+    let start = at + 30 + name_len + extra_len;
+    let data = archive
+        .get(start..start + size)
+        .ok_or("entry runs past the end of the file")?;
+    match method {
+        0 if size <= MAX_ENTRY_BYTES => Ok(data.to_vec()),
+        0 => Err("entry too large".to_string()),
+        8 => inflate(data),
+        other => Err(format!("unsupported compression method {}", other)),
+    }
+}
+
+/// Offset of the end of central directory record, which ends the file
+/// but for a comment of up to 64 KiB
+fn find_end_record(archive: &[u8]) -> Option<usize> {
+    let last = archive.len().checked_sub(22)?;
+    let first = last.saturating_sub(usize::from(u16::MAX));
+    (first..=last)
+        .rev()
+        .find(|&at| u32_at(archive, at) == Some(END_OF_CENTRAL_DIRECTORY))
+}
+
+fn u16_at(data: &[u8], at: usize) -> Option<u16> {
+    Some(u16::from_le_bytes(data.get(at..at + 2)?.try_into().ok()?))
+}
+
+fn u32_at(data: &[u8], at: usize) -> Option<u32> {
+    Some(u32::from_le_bytes(data.get(at..at + 4)?.try_into().ok()?))
+}
+
+/// Base length and extra bits of length codes 257..=285
+const LENGTHS: [(u16, u8); 29] = [
+    (3, 0),
+    (4, 0),
+    (5, 0),
+    (6, 0),
+    (7, 0),
+    (8, 0),
+    (9, 0),
+    (10, 0),
+    (11, 1),
+    (13, 1),
+    (15, 1),
+    (17, 1),
+    (19, 2),
+    (23, 2),
+    (27, 2),
+    (31, 2),
+    (35, 3),
+    (43, 3),
+    (51, 3),
+    (59, 3),
+    (67, 4),
+    (83, 4),
+    (99, 4),
+    (115, 4),
+    (131, 5),
+    (163, 5),
+    (195, 5),
+    (227, 5),
+    (258, 0),
+];
+
+/// Base distance and extra bits of distance codes 0..=29
+const DISTANCES: [(u16, u8); 30] = [
+    (1, 0),
+    (2, 0),
+    (3, 0),
+    (4, 0),
+    (5, 1),
+    (7, 1),
+    (9, 2),
+    (13, 2),
+    (17, 3),
+    (25, 3),
+    (33, 4),
+    (49, 4),
+    (65, 5),
+    (97, 5),
+    (129, 6),
+    (193, 6),
+    (257, 7),
+    (385, 7),
+    (513, 8),
+    (769, 8),
+    (1025, 9),
+    (1537, 9),
+    (2049, 10),
+    (3073, 10),
+    (4097, 11),
+    (6145, 11),
+    (8193, 12),
+    (12289, 12),
+    (16385, 13),
+    (24577, 13),
+];
+
+/// Order code length code lengths are sent in
+const CODE_LENGTH_ORDER: [usize; 19] = [
+    16, 17, 18, 0, 8, 7, 9, 6, 10, 5, 11, 4, 12, 3, 13, 2, 14, 1, 15,
+];
+
+/// Canonical Huffman code: how many codes have each length, and the
+/// symbols ordered by code
+struct Huffman {
+    counts: [u16; 16],
+    symbols: Vec<u16>,
+}
+
+impl Huffman {
+    fn new(lengths: &[u8]) -> Self {
+        let mut counts = [0u16; 16];
+        for &length in lengths {
+            counts[usize::from(length)] += 1;
+        }
+        counts[0] = 0;
+        let mut offsets = [0usize; 16];
+        for length in 1..15 {
+            offsets[length + 1] = offsets[length] + usize::from(counts[length]);
+        }
+        let mut symbols = vec![0; lengths.len()];
+        for (symbol, &length) in lengths.iter().enumerate() {
+            if length != 0 {
+                symbols[offsets[usize::from(length)]] = symbol as u16;
+                offsets[usize::from(length)] += 1;
+            }
+        }
+        Self { counts, symbols }
+    }
+}
+
+/// Reads a deflate stream, least significant bit first
+struct Bits<'a> {
+    data: &'a [u8],
+    /// Position in bits
+    at: usize,
+}
+
+impl Bits<'_> {
+    fn take(&mut self, count: u8) -> Result<u32, String> {
+        let mut value = 0;
+        for i in 0..count {
+            let byte = self
+                .data
+                .get(self.at / 8)
+                .ok_or("deflate stream ends early")?;
+            value |= u32::from((byte >> (self.at % 8)) & 1) << i;
+            self.at += 1;
+        }
+        Ok(value)
+    }
+
+    fn decode(&mut self, huffman: &Huffman) -> Result<u16, String> {
+        let (mut code, mut first, mut index) = (0i32, 0i32, 0i32);
+        for length in 1..16 {
+            code |= self.take(1)? as i32;
+            let count = i32::from(huffman.counts[length]);
+            if code - count < first {
+                return Ok(huffman.symbols[(index + code - first) as usize]);
+            }
+            index += count;
+            first = (first + count) << 1;
+            code <<= 1;
+        }
+        Err("invalid Huffman code".to_string())
+    }
+}
+
+/// Decompress a raw deflate stream
+fn inflate(data: &[u8]) -> Result<Vec<u8>, String> {
+    let mut bits = Bits { data, at: 0 };
+    let mut out = Vec::new();
+    loop {
+        let last = bits.take(1)? == 1;
+        match bits.take(2)? {
+            0 => {
+                bits.at = bits.at.div_ceil(8) * 8;
+                let len = bits.take(16)? as usize;
+                let _complement = bits.take(16)?;
+                let start = bits.at / 8;
+                let block = data
+                    .get(start..start + len)
+                    .ok_or("deflate stream ends early")?;
+                out.extend_from_slice(block);
+                bits.at += len * 8;
+            }
+            1 => {
+                let mut lengths = [8u8; 288];
+                lengths[144..256].fill(9);
+                lengths[256..280].fill(7);
+                let literals = Huffman::new(&lengths);
+                let distances = Huffman::new(&[5; 30]);
+                inflate_block(&mut bits, &literals, &distances, &mut out)?;
+            }
+            2 => {
+                let (literals, distances) = dynamic_codes(&mut bits)?;
+                inflate_block(&mut bits, &literals, &distances, &mut out)?;
+            }
+            _ => return Err("invalid deflate block type".to_string()),
+        }
+        if out.len() > MAX_ENTRY_BYTES {
+            return Err("entry too large".to_string());
+        }
+        if last {
+            return Ok(out);
+        }
+    }
+}
+
+/// The literal/length and distance codes of a dynamic block
+fn dynamic_codes(bits: &mut Bits) -> Result<(Huffman, Huffman), String> {
+    let literal_count = bits.take(5)? as usize + 257;
+    let distance_count = bits.take(5)? as usize + 1;
+    let code_length_count = bits.take(4)? as usize + 4;
+
+    let mut code_lengths = [0u8; 19];
+    for &symbol in &CODE_LENGTH_ORDER[..code_length_count] {
+        code_lengths[symbol] = bits.take(3)? as u8;
+    }
+    let code_lengths = Huffman::new(&code_lengths);
+
+    let mut lengths = Vec::with_capacity(literal_count + distance_count);
+    while lengths.len() < literal_count + distance_count {
+        let (length, repeat) = match bits.decode(&code_lengths)? {
+            symbol @ 0..=15 => (symbol as u8, 1),
+            16 => {
+                let previous = *lengths.last().ok_or("repeat with no previous length")?;
+                (previous, 3 + bits.take(2)?)
+            }
+            17 => (0, 3 + bits.take(3)?),
+            _ => (0, 11 + bits.take(7)?),
+        };
+        lengths.extend(std::iter::repeat_n(length, repeat as usize));
+    }
+    if lengths.len() != literal_count + distance_count {
+        return Err("code lengths overrun".to_string());
+    }
+    let (literals, distances) = lengths.split_at(literal_count);
+    Ok((Huffman::new(literals), Huffman::new(distances)))
+}
+
+fn inflate_block(
+    bits: &mut Bits,
+    literals: &Huffman,
+    distances: &Huffman,
+    out: &mut Vec<u8>,
+) -> Result<(), String> {
+    loop {
+        let symbol = bits.decode(literals)?;
+        match symbol {
+            0..=255 => out.push(symbol as u8),
+            256 => return Ok(()),
+            _ => {
+                let (base, extra) = *LENGTHS
+                    .get(usize::from(symbol - 257))
+                    .ok_or("invalid length code")?;
+                let length = usize::from(base) + bits.take(extra)? as usize;
+                let (base, extra) = *DISTANCES
+                    .get(usize::from(bits.decode(distances)?))
+                    .ok_or("invalid distance code")?;
+                let distance = usize::from(base) + bits.take(extra)? as usize;
+                let start = out
+                    .len()
+                    .checked_sub(distance)
+                    .ok_or("distance before the start of the output")?;
+                for i in 0..length {
+                    out.push(out[start + i]);
+                }
+                if out.len() > MAX_ENTRY_BYTES {
+                    return Err("entry too large".to_string());
+                }
+            }
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    /// A zip holding `entries` of (name, method, data as stored)
+    fn zip(entries: &[(&str, u16, &[u8])]) -> Vec<u8> {
+        let mut archive = Vec::new();
+        let mut central = Vec::new();
+        for (name, method, data) in entries {
+            let offset = archive.len() as u32;
+            archive.extend_from_slice(&LOCAL_FILE_HEADER.to_le_bytes());
+            archive.extend_from_slice(&[20, 0, 0, 0]);
+            archive.extend_from_slice(&method.to_le_bytes());
+            archive.extend_from_slice(&[0; 16]);
+            archive.extend_from_slice(&(name.len() as u16).to_le_bytes());
+            archive.extend_from_slice(&[0, 0]);
+            archive.extend_from_slice(name.as_bytes());
+            archive.extend_from_slice(data);
+
+            central.extend_from_slice(&CENTRAL_DIRECTORY_HEADER.to_le_bytes());
+            central.extend_from_slice(&[20, 0, 20, 0, 0, 0]);
+            central.extend_from_slice(&method.to_le_bytes());
+            central.extend_from_slice(&[0; 8]);
+            central.extend_from_slice(&(data.len() as u32).to_le_bytes());
+            central.extend_from_slice(&[0; 4]);
+            central.extend_from_slice(&(name.len() as u16).to_le_bytes());
+            central.extend_from_slice(&[0; 12]);
+            central.extend_from_slice(&offset.to_le_bytes());
+            central.extend_from_slice(name.as_bytes());
+        }
+        let central_offset = archive.len() as u32;
+        archive.extend_from_slice(&central);
+        archive.extend_from_slice(&END_OF_CENTRAL_DIRECTORY.to_le_bytes());
+        archive.extend_from_slice(&[0; 6]);
+        archive.extend_from_slice(&(entries.len() as u16).to_le_bytes());
+        archive.extend_from_slice(&(central.len() as u32).to_le_bytes());
+        archive.extend_from_slice(&central_offset.to_le_bytes());
+        archive.extend_from_slice(&[0, 0]);
+        archive
+    }
+
+    #[test]
+    fn test_reads_stored_and_deflated_entries() {
+        // Fixed Huffman codes
+        let manifest = b"Manifest-Version: 1.0\r\nMain-Class: app.Main\r\n\r\n";
+        let fixed: &[u8] = &[
+            0xf3, 0x4d, 0xcc, 0xcb, 0x4c, 0x4b, 0x2d, 0x2e, 0xd1, 0x0d, 0x4b, 0x2d, 0x2a, 0xce,
+            0xcc, 0xcf, 0xb3, 0x52, 0x30, 0xd4, 0x33, 0xe0, 0xe5, 0xf2, 0x4d, 0xcc, 0xcc, 0xd3,
+            0x75, 0xce, 0x49, 0x2c, 0x2e, 0xb6, 0x52, 0x48, 0x2c, 0x28, 0xd0, 0x03, 0xf1, 0x79,
+            0xb9, 0x78, 0xb9, 0x00,
+        ];
+        let archive = zip(&[
+            ("app/Main.class", 0, b"\xca\xfe\xba\xbe"),
+            ("META-INF/MANIFEST.MF", 8, fixed),
+        ]);
+        assert_eq!(
+            read_entry(&archive, "META-INF/MANIFEST.MF").unwrap(),
+            Some(manifest.to_vec())
+        );
+        assert_eq!(
+            read_entry(&archive, "app/Main.class").unwrap(),
+            Some(b"\xca\xfe\xba\xbe".to_vec())
+        );
+        assert_eq!(read_entry(&archive, "missing").unwrap(), None);
+        assert!(read_entry(b"not a jar", "META-INF/MANIFEST.MF").is_err());
+
+        // Dynamic Huffman codes, with back references
+        let mut manifest = b"Manifest-Version: 1.0\r\n".to_vec();
+        for i in 0..17 {
+            manifest.extend_from_slice(format!("Name: app/C{}.class\r\n", i).as_bytes());
+        }
+        manifest.extend_from_slice(b"Main-Class: app.Main\r\n\r\n");
+        let dynamic: &[u8] = &[
+            0x6d, 0xcf, 0x3d, 0x0a, 0x80, 0x30, 0x0c, 0x86, 0xe1, 0xbd, 0xd0, 0x3b, 0x78, 0x81,
+            0xd6, 0xc6, 0x7f, 0x5d, 0x3b, 0xd7, 0xd1, 0x3d, 0x48, 0x85, 0x82, 0x56, 0xb1, 0xde,
+            0x1f, 0xd1, 0x41, 0x10, 0xbe, 0x2d, 0x79, 0xb2, 0xe4, 0x75, 0x1c, 0xc3, 0xe2, 0xd3,
+            0xa5, 0x26, 0x7f, 0xa6, 0xb0, 0xc7, 0x21, 0x23, 0x6d, 0xa4, 0x18, 0x79, 0xf3, 0x43,
+            0xc6, 0xc7, 0x91, 0x5b, 0xa3, 0xe7, 0x95, 0x53, 0xfa, 0x19, 0x01, 0x2b, 0x80, 0x95,
+            0xc0, 0x2a, 0x60, 0x35, 0xb0, 0x06, 0x58, 0x0b, 0xac, 0x03, 0xd6, 0xa3, 0x9f, 0x61,
+            0x08, 0x2a, 0x21, 0x94, 0x42, 0xa8, 0x85, 0x50, 0x0c, 0xa1, 0x1a, 0xfa, 0x72, 0x1c,
+            0x87, 0xa8, 0xec, 0x33, 0xbf, 0x27, 0xfd, 0xec, 0x52, 0x48, 0x71, 0x03,
+        ];
+        let archive = zip(&[("META-INF/MANIFEST.MF", 8, dynamic)]);
+        assert_eq!(
+            read_entry(&archive, "META-INF/MANIFEST.MF").unwrap(),
+            Some(manifest)
+        );
+    }
+}
@@ -0,0 +1,372 @@
+//! Java Debug Adapter (java-debug, standalone)
+//!
+//! Microsoft's java-debug is a DAP server for the JVM. It usually runs inside
+//! the Eclipse JDT language server, which compiles the project and hands it
+//! the classpath; here it runs standalone, so nothing is compiled for it and
+//! the program must already be built:
+//!
+//! - a jar: `program` is the jar, whose manifest names the main class
+//!   (`javaOptions.mainClass` overrides it)
+//! - classes: `program` is the fully qualified main class, and
+//!   `javaOptions.classPath` lists the directories and jars to load it from
+//!
+//! The adapter is started as `java-debug-adapter` (or `adapters.java.path`
+//! from the server configuration) and speaks DAP over stdio. It starts the
+//! JVM itself, with a JDWP agent it connects to.
+//!
+//! java-debug ships no such launcher: it is a plugin of the language
+//! server. Until one exists upstream, Java support is experimental and
+//! needs a launcher of the operator's own; no image or integration test
+//! covers it.
+//!
+//! ## Deferred breakpoints
+//!
+//! The JVM loads classes lazily, so a breakpoint in a class that isn't
+//! loaded yet can't be bound and the adapter answers it unverified. Once the
+//! class loads, the adapter binds it and sends a `breakpoint` event
+//! (reason `changed`), which the session applies to the breakpoint like an
+//! answer to `setBreakpoints`.
+//!
+//! ## Remote JVMs
+//!
+//! Attach mode connects to the JDWP agent of a running JVM instead of
+//! launching one: start it with
+//! `-agentlib:jdwp=transport=dt_socket,server=y,suspend=n,address=5005` and
+//! pass `javaOptions.port` (and `hostName` when it isn't local). Hosts
+//! other than loopback must be listed in `security.jvm_attach_hosts` (see
+//! `security::validate_jvm_attach_host`).
+
+use super::jar;
+use super::logging::DebugAdapterLogger;
+use crate::{Error, Result};
+use serde::Deserialize;
+use serde_json::{json, Value};
+use std::path::Path;
+use tracing::error;
+
+/// Java java-debug adapter configuration
+pub struct JavaAdapter;
+
+/// Java-only `debugger_start` options (`javaOptions`)
+#[derive(Debug, Clone, Default, PartialEq, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct JavaLaunchOptions {
+    /// Fully qualified main class; for a jar, instead of the manifest's
+    pub main_class: Option<String>,
+    /// Directories and jars the program's classes are loaded from
+    #[serde(default)]
+    pub class_path: Vec<String>,
+    /// Arguments for the JVM (e.g. `-Xmx512m`)
+    #[serde(default)]
+    pub vm_args: Vec<String>,
+    /// Host of the JVM to attach to (attach mode; default 127.0.0.1)
+    pub host_name: Option<String>,
+    /// JDWP port of the JVM to attach to (attach mode)
+    pub port: Option<u16>,
+}
+
+impl JavaAdapter {
+    /// Get the path to the adapter launcher
+    ///
+    /// Checks, in order:
+    /// 1. `adapters.java.path` from the server configuration
+    /// 2. /usr/local/lib/java-debug/bin/java-debug-adapter (system install)
+    /// 3. ~/.java-debug/bin/java-debug-adapter (user install)
+    /// 4. `java-debug-adapter` on PATH
+    pub fn command() -> Result<String> {
+        if let Some(path) = crate::config::current().adapter_path("java") {
+            return Ok(shellexpand::tilde(path).to_string());
+        }
+
+        let locations = [
+            "/usr/local/lib/java-debug/bin/java-debug-adapter",
+            "~/.java-debug/bin/java-debug-adapter",
+        ];
+        let on_path = std::env::var_os("PATH")
+            .map(|path| {
+                std::env::split_paths(&path)
+                    .map(|dir| {
+                        dir.join("java-debug-adapter")
+                            .to_string_lossy()
+                            .into_owned()
+                    })
+                    .collect::<Vec<_>>()
+            })
+            .unwrap_or_default();
+        locations
+            .iter()
+            .map(|location| shellexpand::tilde(location).to_string())
+            .chain(on_path)
+            .find(|path| Path::new(path).is_file())
+            .ok_or_else(|| {
+                Error::Process(
+                    "java-debug-adapter not found. Java support needs a launcher serving java-debug over stdio; set adapters.java.path"
+                        .to_string(),
+                )
+            })
+    }
+
+    pub fn args() -> Vec<String> {
+        Vec::new()
+    }
+
+    pub fn adapter_id() -> &'static str {
+        "java"
+    }
+
+    /// JDWP port attach mode connects to when none is given
+    pub const DEFAULT_JDWP_PORT: u16 = 5005;
+
+    /// Whether `program` is a jar rather than a main class
+    pub fn is_jar(program: &str) -> bool {
+        program.ends_with(".jar")
+    }
+
+    /// Check a fully qualified class name (`com.example.App`)
+    pub fn validate_class_name(name: &str) -> Result<()> {
+        let valid = name.split('.').all(|part| {
+            part.starts_with(|c: char| c.is_alphabetic() || c == '_' || c == '$')
+                && part
+                    .chars()
+                    .all(|c| c.is_alphanumeric() || c == '_' || c == '$')
+        });
+        if valid {
+            Ok(())
+        } else {
+            Err(Error::InvalidRequest(format!(
+                "Invalid Java class name '{}' (expected e.g. 'com.example.App', or a .jar path)",
+                name
+            )))
+        }
+    }
+
+    /// The main class a jar's manifest names
+    pub fn jar_main_class(jar: &str) -> Result<String> {
+        let unreadable = |why: String| {
+            Error::InvalidRequest(format!(
+                "Can't read the manifest of {} ({}); pass javaOptions.mainClass",
+                jar, why
+            ))
+        };
+        let archive = std::fs::read(jar).map_err(|e| unreadable(e.to_string()))?;
+        let manifest = jar::read_entry(&archive, "META-INF/MANIFEST.MF")
+            .map_err(unreadable)?
+            .unwrap_or_default();
+        manifest_main_class(&String::from_utf8_lossy(&manifest)).ok_or_else(|| {
+            Error::InvalidRequest(format!(
+                "{} has no Main-Class in its manifest; pass javaOptions.mainClass",
+                jar
+            ))
+        })
+    }
+
+    /// Launch request for a built program: `program` is a jar or the main
+    /// class (see the module docs)
+    pub fn launch_args(
+        program: &str,
+        args: &[String],
+        cwd: Option<&str>,
+        stop_on_entry: bool,
+        options: &JavaLaunchOptions,
+    ) -> Result<Value> {
+        let mut class_paths = options.class_path.clone();
+        let main_class = if Self::is_jar(program) {
+            class_paths.insert(0, program.to_string());
+            match &options.main_class {
+                Some(main_class) => main_class.clone(),
+                None => Self::jar_main_class(program)?,
+            }
+        } else {
+            if options.main_class.is_some() {
+                return Err(Error::InvalidRequest(
+                    "javaOptions.mainClass is only for jars; program already names the main class"
+                        .to_string(),
+                ));
+            }
+            if class_paths.is_empty() {
+                return Err(Error::InvalidRequest(format!(
+                    "javaOptions.classPath is required to load {}: the directories or jars holding the compiled classes",
+                    program
+                )));
+            }
+            program.to_string()
+        };
+        Self::validate_class_name(&main_class)?;
+
+        let mut launch = json!({
+            "type": "java",
+            "request": "launch",
+            "mainClass": main_class,
+            "classPaths": class_paths,
+            "modulePaths": [],
+            "args": args.join(" "),
+            "vmArgs": options.vm_args.join(" "),
+            "stopOnEntry": stop_on_entry,
+            "console": "internalConsole",
+        });
+        if let Some(cwd) = cwd {
+            launch["cwd"] = json!(cwd);
+        }
+        Ok(launch)
+    }
+
+    /// Attach request for the JDWP agent of a running JVM
+    pub fn attach_args(host_name: &str, port: u16) -> Value {
+        json!({
+            "type": "java",
+            "request": "attach",
+            "hostName": host_name,
+            "port": port,
+        })
+    }
+}
+
+/// `Main-Class` of a manifest; lines longer than 72 bytes continue on the
+/// next line after a space
+fn manifest_main_class(manifest: &str) -> Option<String> {
+    let mut value: Option<String> = None;
+    for line in manifest.lines() {
+        let line = line.trim_end_matches('\r');
+        match (&mut value, line.strip_prefix(' ')) {
+            (Some(value), Some(continued)) => value.push_str(continued),
+            (Some(_), None) => break,
+            (None, _) => {
+                value = line
+                    .strip_prefix("Main-Class:")
+                    .map(|main_class| main_class.trim().to_string());
+            }
+        }
+    }
+    value.filter(|main_class| !main_class.is_empty())
+}
+
+// ============================================================================
+// DebugAdapterLogger Trait Implementation
+// ============================================================================
+
+impl DebugAdapterLogger for JavaAdapter {
+    fn language_name(&self) -> &str {
+        "Java"
+    }
+
+    fn language_emoji(&self) -> &str {
+        "☕"
+    }
+
+    fn transport_type(&self) -> &str {
+        "STDIO"
+    }
+
+    fn adapter_id(&self) -> &str {
+        "java"
+    }
+
+    fn command_line(&self) -> String {
+        Self::command().unwrap_or_else(|_| "java-debug-adapter".to_string())
+    }
+
+    fn log_spawn_error(&self, error: &dyn std::error::Error) {
+        error!("❌ [JAVA] Failed to spawn java-debug adapter: {}", error);
+        error!("   Command: {}", self.command_line());
+        error!("   ");
+        error!("   Possible causes:");
+        error!("   1. No java-debug launcher installed → java-debug ships none; provide one");
+        error!("   2. Installed elsewhere → set adapters.java.path in the server configuration");
+        error!("   3. java not in PATH → java --version (JDK 17 or newer)");
+    }
+
+    fn log_connection_error(&self, error: &dyn std::error::Error) {
+        error!("❌ [JAVA] Adapter connection failed: {}", error);
+        error!("   Transport: STDIO");
+        error!("   The adapter process may have exited; check its stderr output.");
+    }
+
+    fn log_init_error(&self, error: &dyn std::error::Error) {
+        error!("❌ [JAVA] DAP initialization failed: {}", error);
+        error!("   The adapter started but couldn't launch the JVM");
+        error!("   ");
+        error!("   Possible causes:");
+        error!("   1. Main class not found on the class path (is the program compiled?)");
+        error!("   2. Class files built for a newer Java than the adapter runs");
+        error!("   3. In attach mode: no JDWP agent listening on the port");
+        error!("   ");
+        error!("   Verify the program runs:");
+        error!("   $ java -cp <classPath> <mainClass>");
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_launch_args_for_classes() {
+        let options = JavaLaunchOptions {
+            class_path: vec!["/w/out".to_string()],
+            vm_args: vec!["-Xmx256m".to_string(), "-ea".to_string()],
+            ..Default::default()
+        };
+        let launch = JavaAdapter::launch_args(
+            "com.example.FizzBuzz",
+            &["15".to_string()],
+            Some("/w"),
+            true,
+            &options,
+        )
+        .unwrap();
+        assert_eq!(launch["request"], "launch");
+        assert_eq!(launch["mainClass"], "com.example.FizzBuzz");
+        assert_eq!(launch["classPaths"], json!(["/w/out"]));
+        assert_eq!(launch["vmArgs"], "-Xmx256m -ea");
+        assert_eq!(launch["args"], "15");
+        assert_eq!(launch["cwd"], "/w");
+        assert_eq!(launch["stopOnEntry"], true);
+
+        let err = JavaAdapter::launch_args(
+            "com.example.FizzBuzz",
+            &[],
+            None,
+            false,
+            &JavaLaunchOptions::default(),
+        )
+        .unwrap_err();
+        assert!(err.to_string().contains("classPath"), "{}", err);
+        assert!(
+            JavaAdapter::launch_args("com/example/FizzBuzz", &[], None, false, &options).is_err()
+        );
+    }
+
+    #[test]
+    fn test_launch_args_for_jar() {
+        let options = JavaLaunchOptions {
+            main_class: Some("app.Main".to_string()),
+            class_path: vec!["/w/lib/dep.jar".to_string()],
+            ..Default::default()
+        };
+        let launch = JavaAdapter::launch_args("/w/app.jar", &[], None, false, &options).unwrap();
+        assert_eq!(launch["mainClass"], "app.Main");
+        assert_eq!(
+            launch["classPaths"],
+            json!(["/w/app.jar", "/w/lib/dep.jar"])
+        );
+        assert!(launch["cwd"].is_null());
+    }
+
+    #[test]
+    fn test_manifest_main_class() {
+        let manifest = "Manifest-Version: 1.0\r\nMain-Class: com.example.very.long.package.name.th\r\n at.Wraps\r\nCreated-By: 17\r\n";
+        assert_eq!(
+            manifest_main_class(manifest).as_deref(),
+            Some("com.example.very.long.package.name.that.Wraps")
+        );
+        assert_eq!(manifest_main_class("Manifest-Version: 1.0\n"), None);
+    }
+
+    #[test]
+    fn test_attach_args() {
+        let attach = JavaAdapter::attach_args("10.0.0.5", 5005);
+        assert_eq!(attach["request"], "attach");
+        assert_eq!(attach["hostName"], "10.0.0.5");
+        assert_eq!(attach["port"], 5005);
+    }
+}
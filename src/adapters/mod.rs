@@ -1,7 +1,647 @@
+pub mod availability;
+pub mod exec_prefix;
 pub mod golang;
+pub mod goroutine_labels;
+pub mod jar;
+pub mod java;
 pub mod logging;
 pub mod nodejs;
 pub mod python;
 pub mod ruby;
 pub mod rust;
 pub mod security;
+pub mod source_maps;
+pub mod toolchain;
+
+use crate::dap::types::ExceptionOptions;
+use crate::{Error, Result};
+use golang::GoLaunchOptions;
+use java::JavaLaunchOptions;
+use ruby::RubyLaunchOptions;
+use serde::{Deserialize, Serialize};
+use std::collections::HashMap;
+use toolchain::Toolchain;
+
+/// Language-specific options for launching a debuggee
+///
+/// Options for languages other than the one being launched are ignored.
+#[derive(Debug, Clone, Default)]
+pub struct LaunchOptions {
+    /// Launch mode from `supported_modes` (None = the language's default)
+    pub mode: Option<&'static str>,
+    /// Keep the adapter alive for this long after a restart-intent disconnect
+    /// so the next start of the same program can reuse it (Go only)
+    pub keep_adapter_warm: Option<std::time::Duration>,
+    /// Extra environment variables for the debuggee (Python only)
+    pub env: HashMap<String, String>,
+    /// Debug only the program's own code (Python only; None = the server's
+    /// `python.just_my_code`, else debugpy's default)
+    pub just_my_code: Option<bool>,
+    pub go: GoLaunchOptions,
+    pub ruby: RubyLaunchOptions,
+    pub java: JavaLaunchOptions,
+    /// Interpreter (Python) or `go` binary (Go) to use instead of the one on
+    /// PATH (see `toolchain`)
+    pub toolchain: Option<Toolchain>,
+    /// Command the adapter runs through, in another container or namespace
+    /// (see `exec_prefix`)
+    pub exec_prefix: Vec<String>,
+    /// Where the launch waits while the start is set up (see `launch_gate`)
+    pub launch_gate: Option<LaunchGate>,
+    /// Session this start relaunches, whose place under
+    /// `sessions.max_sessions` the new session takes
+    pub replaces: Option<String>,
+}
+
+/// Holds a launch back while it is alive (see `launch_gate`)
+#[derive(Debug)]
+pub struct LaunchHold {
+    /// Dropping it closes the channel, which opens the gate
+    _sender: tokio::sync::watch::Sender<()>,
+}
+
+/// Where a new session's launch waits for its `LaunchHold` to be dropped
+#[derive(Debug, Clone)]
+pub struct LaunchGate(tokio::sync::watch::Receiver<()>);
+
+impl LaunchGate {
+    pub async fn opened(mut self) {
+        while self.0.changed().await.is_ok() {}
+    }
+}
+
+/// A gate for the launch of a session about to be created, and the hold
+/// that keeps it closed
+///
+/// What a start sets up after creating the session (the entry breakpoint,
+/// imported breakpoints, auto-continue) is then in place before the
+/// session's initialization sends `configurationDone`, however long the
+/// setup takes.
+pub fn launch_gate() -> (LaunchHold, LaunchGate) {
+    let (sender, gate) = tokio::sync::watch::channel(());
+    (LaunchHold { _sender: sender }, LaunchGate(gate))
+}
+
+/// Entry points `debugger_start` can stop at: `user_main` is the start of
+/// the program's own code, which stopOnEntry isn't for every adapter (Delve
+/// stops in the Go runtime's bootstrap)
+pub const ENTRY_POINTS: &[&str] = &["user_main"];
+
+/// Breakpoint marking a session's entry point, removed by its first stop
+#[derive(Debug, Clone, PartialEq)]
+pub enum EntryBreakpoint {
+    /// A function breakpoint (Go's `main.main`)
+    Function(String),
+    /// A source line (the first executable line of the program file)
+    Line { path: String, line: i32 },
+}
+
+/// Resolve an entry point for a program of `language` started in `mode`
+pub fn resolve_entry(
+    language: &str,
+    mode: &str,
+    program: &str,
+    entry: &str,
+) -> Result<EntryBreakpoint> {
+    if !ENTRY_POINTS.contains(&entry) {
+        return Err(Error::InvalidRequest(format!(
+            "Unknown entry '{}' (expected: {})",
+            entry,
+            ENTRY_POINTS.join(", ")
+        )));
+    }
+    let unsupported = || {
+        Error::InvalidRequest(format!(
+            "entry '{}' is not supported for {} in mode '{}'",
+            entry, language, mode
+        ))
+    };
+    match language {
+        "go" => golang::GoAdapter::user_main_entry(mode).ok_or_else(unsupported),
+        "python" if mode == "program" => python::PythonAdapter::user_main_entry(program),
+        "ruby" => ruby::RubyAdapter::user_main_entry(program),
+        _ => Err(unsupported()),
+    }
+}
+
+/// Where a program of `language` started in `mode` begins, for
+/// `debugger_entry_point`: the `user_main` entry, or the crate's `main` for
+/// Rust, which has no `user_main` entry
+pub fn entry_point(language: &str, mode: &str, program: &str) -> Result<EntryBreakpoint> {
+    match language {
+        "rust" => Ok(EntryBreakpoint::Function(
+            rust::RustAdapter::entry_function(program),
+        )),
+        _ => resolve_entry(language, mode, program, "user_main"),
+    }
+}
+
+/// Alias accepted for every language's default mode
+pub const DEFAULT_MODE_ALIAS: &str = "launch";
+
+/// Start modes each language accepts, default first
+///
+/// - Go (Delve): `debug` a program, `test` a package, `exec` a pre-built binary
+/// - Python (debugpy): run a `program` file, a `module` (`python -m`), or `pytest`
+///
+/// `attach` attaches to a running process instead of launching one; for
+/// Java, to the JDWP agent of a running JVM.
+pub fn supported_modes(language: &str) -> &'static [&'static str] {
+    match language {
+        "go" => &["debug", "test", "exec", "attach"],
+        "python" => &["program", "module", "pytest", "attach"],
+        "java" => &[DEFAULT_MODE_ALIAS, "attach"],
+        _ => &[DEFAULT_MODE_ALIAS],
+    }
+}
+
+/// Resolve a requested start mode for a language
+///
+/// Returns the canonical mode name; no mode (or `"launch"`) resolves to the
+/// language's default.
+pub fn resolve_mode(language: &str, mode: Option<&str>) -> Result<&'static str> {
+    let modes = supported_modes(language);
+    match mode {
+        None | Some(DEFAULT_MODE_ALIAS) => Ok(modes[0]),
+        Some(mode) => modes.iter().copied().find(|m| *m == mode).ok_or_else(|| {
+            Error::InvalidRequest(format!(
+                "Mode '{}' is not supported for language '{}' (supported: {})",
+                mode,
+                language,
+                modes.join(", ")
+            ))
+        }),
+    }
+}
+
+/// Exception breakpoint modes: stop where any exception is raised, or only
+/// on exceptions nothing handles
+pub const EXCEPTION_MODES: &[&str] = &["raised", "uncaught"];
+
+/// What a launched program does on an exception or panic nothing handles
+/// (`onUncaught` of `debugger_start`)
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum OnUncaught {
+    /// Stop and wait, for post-mortem inspection
+    Break,
+    /// Let the program terminate, so unattended runs don't hang on a crash
+    Terminate,
+}
+
+/// The `onUncaught` behaviour of a session of `language`
+///
+/// Breaking needs a debugger that stops on uncaught exceptions: debugpy
+/// does once its `uncaught` filter is set, Delve on unrecovered panics by
+/// itself. That is the default where possible; the other debuggers let the
+/// program terminate, and asking them to break is refused.
+pub fn resolve_on_uncaught(language: &str, requested: Option<OnUncaught>) -> Result<OnUncaught> {
+    let breaks = language == "go"
+        || exception_filters(language)
+            .iter()
+            .any(|(mode, _)| *mode == "uncaught");
+    match requested {
+        None if breaks => Ok(OnUncaught::Break),
+        None => Ok(OnUncaught::Terminate),
+        Some(OnUncaught::Break) if !breaks => Err(Error::UnsupportedCapability {
+            capability: "exceptionBreakpointFilters (uncaught)".to_string(),
+            adapter: language.to_string(),
+            suggestion: (!exception_filters(language).is_empty()).then(|| {
+                "stop on every raised exception with debugger_set_exception_breakpoints (mode raised) and continue past the handled ones".to_string()
+            }),
+        }),
+        Some(mode) => Ok(mode),
+    }
+}
+
+/// `breakMode`s of DAP exception options
+pub const EXCEPTION_BREAK_MODES: &[&str] = &["never", "always", "unhandled", "userUnhandled"];
+
+/// Check exception options before they are sent: known break modes and no
+/// empty path segments
+///
+/// Whether the adapter takes exception options at all is checked against
+/// its `supportsExceptionOptions` when the request is sent; of the adapters
+/// here only debugpy has it.
+pub fn validate_exception_options(options: &[ExceptionOptions]) -> Result<()> {
+    for option in options {
+        if !EXCEPTION_BREAK_MODES.contains(&option.break_mode.as_str()) {
+            return Err(Error::InvalidRequest(format!(
+                "Unknown exception breakMode '{}' (expected: {})",
+                option.break_mode,
+                EXCEPTION_BREAK_MODES.join(", ")
+            )));
+        }
+        let segments = option.path.iter().flatten();
+        if segments
+            .clone()
+            .any(|s| s.names.is_empty() || s.names.iter().any(|n| n.trim().is_empty()))
+        {
+            return Err(Error::InvalidRequest(
+                "Every exception path segment needs non-empty names".to_string(),
+            ));
+        }
+    }
+    Ok(())
+}
+
+/// Exception breakpoint modes each language's adapter honours, with the
+/// adapter's filter id for each
+pub fn exception_filters(language: &str) -> &'static [(&'static str, &'static str)] {
+    match language {
+        "python" => python::PythonAdapter::EXCEPTION_FILTERS,
+        "ruby" => ruby::RubyAdapter::EXCEPTION_FILTERS,
+        _ => &[],
+    }
+}
+
+/// Step filters a new session of `language` starts with
+///
+/// Python sessions rely on debugpy's justMyCode instead.
+pub fn default_step_filters(language: &str) -> &'static [&'static str] {
+    match language {
+        "go" => golang::GoAdapter::STEP_FILTERS,
+        "ruby" => ruby::RubyAdapter::STEP_FILTERS,
+        _ => &[],
+    }
+}
+
+/// Index of an array, slice or list element from the name the debugger of
+/// `language` gives its variable, or None for other children (fields,
+/// `len()`)
+pub fn element_index(language: &str, name: &str) -> Option<i64> {
+    match language {
+        "go" => golang::GoAdapter::element_index(name),
+        "python" => python::PythonAdapter::element_index(name),
+        "ruby" => ruby::RubyAdapter::element_index(name),
+        // js-debug names elements `0`, CodeLLDB `[0]`
+        _ => name
+            .strip_prefix('[')
+            .and_then(|name| name.strip_suffix(']'))
+            .unwrap_or(name)
+            .parse()
+            .ok(),
+    }
+}
+
+/// Whether values of type `type_`, as the debugger of `language` names
+/// types, hold elements (true: arrays, slices, lists) or keyed entries
+/// (false: maps, dicts, hashes, objects); None for types that tell neither
+///
+/// A dict whose keys are 0, 1, ... has children named like elements, so
+/// the names alone can't tell.
+pub fn holds_elements(language: &str, type_: &str) -> Option<bool> {
+    let type_ = type_.trim();
+    match language {
+        "go" if type_.starts_with("map[") => Some(false),
+        "go" if type_.starts_with('[') => Some(true),
+        "python" => match type_.rsplit('.').next().unwrap_or(type_) {
+            "list" | "tuple" | "deque" | "range" => Some(true),
+            "dict" | "OrderedDict" | "defaultdict" | "Counter" | "mappingproxy" => Some(false),
+            _ => None,
+        },
+        "ruby" => match type_ {
+            "Array" => Some(true),
+            "Hash" => Some(false),
+            _ => None,
+        },
+        "nodejs" if type_ == "Array" || type_.starts_with("Array(") => Some(true),
+        "nodejs" if matches!(type_, "Object" | "Map") => Some(false),
+        "rust" if type_.starts_with('[') || type_.contains("Vec<") => Some(true),
+        "rust" if type_.contains("HashMap<") || type_.contains("BTreeMap<") => Some(false),
+        _ => None,
+    }
+}
+
+/// What a child the debugger of `language` lists is to the value it is in
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum ChildKind {
+    /// A field, element or entry
+    Value,
+    /// The debugger's own addition: debugpy's `len()` and its groups of
+    /// special, function and class variables, js-debug's internal slots
+    /// (`[[Prototype]]`), rdbg's `#class`
+    Synthetic,
+    /// A group whose children are fields of the value: debugpy's protected
+    /// variables, when its settings group them
+    Group,
+}
+
+/// Classify a child of a value by the name the debugger of `language`
+/// gives it
+pub fn child_kind(language: &str, name: &str) -> ChildKind {
+    let synthetic = match language {
+        "python" => matches!(
+            name,
+            "len()" | "special variables" | "function variables" | "class variables"
+        ),
+        "nodejs" => name.starts_with("[[") || name == "__proto__",
+        "ruby" => name.starts_with('#'),
+        _ => false,
+    };
+    match (language, name) {
+        _ if synthetic => ChildKind::Synthetic,
+        ("python", "protected variables") => ChildKind::Group,
+        _ => ChildKind::Value,
+    }
+}
+
+/// Variable names the debugger of `language` may give the entry of map,
+/// dict or hash key `key`
+pub fn map_key_names(language: &str, key: &str) -> Vec<String> {
+    match language {
+        "go" => golang::GoAdapter::map_key_names(key),
+        "python" => python::PythonAdapter::map_key_names(key),
+        "ruby" => ruby::RubyAdapter::map_key_names(key),
+        _ => vec![format!("\"{}\"", key), key.to_string()],
+    }
+}
+
+/// Function breakpoint `name` in the syntax of the debugger of `language`
+///
+/// Go names are normalized for Delve (see
+/// `GoAdapter::function_breakpoint_name`); other debuggers get the name as
+/// it is.
+pub fn function_breakpoint_name(language: &str, name: &str) -> Result<String> {
+    match language {
+        "go" => golang::GoAdapter::function_breakpoint_name(name),
+        _ if name.trim().is_empty() => Err(Error::InvalidRequest(
+            "Function breakpoint name is empty".to_string(),
+        )),
+        _ => Ok(name.trim().to_string()),
+    }
+}
+
+/// The error for a function breakpoint the debugger of `language` couldn't
+/// resolve, where it never will: in Go all code is there from the start.
+/// None where code is loaded as the program runs (modules, classes), so the
+/// breakpoint may resolve later.
+pub fn unresolved_function(language: &str, name: &str, message: Option<&str>) -> Option<Error> {
+    match language {
+        "go" => Some(golang::GoAdapter::unresolved_function(name, message)),
+        _ => None,
+    }
+}
+
+/// Evaluate context in which the adapter for `language` can't run code with
+/// side effects
+///
+/// - vscode-js-debug: `hover` evaluates with V8's side-effect checks, failing
+///   any expression that would change state (assignments, most calls)
+/// - Delve: only the `repl` context can `call` functions, so `watch` (the
+///   default) already reads variables and memory only
+///
+/// debugpy, rdbg and CodeLLDB evaluate alike in every context, so for them
+/// there is no such guarantee.
+pub fn side_effect_free_context(language: &str) -> Result<&'static str> {
+    match language {
+        "nodejs" => Ok("hover"),
+        "go" => Ok("watch"),
+        _ => Err(Error::InvalidRequest(format!(
+            "The {} debugger can't evaluate without side effects; use debugger_peek to read variables without evaluating",
+            language
+        ))),
+    }
+}
+
+/// The expression for what the pointer `expression` points to in `language`
+///
+/// Go (Delve) and Rust (CodeLLDB) dereference with C's `*`. The other
+/// languages have references only, which their debuggers expand directly.
+pub fn dereference_expression(language: &str, expression: &str) -> Result<String> {
+    match language {
+        "go" | "rust" => Ok(format!("*({})", expression)),
+        _ => Err(Error::InvalidRequest(format!(
+            "{} has no pointers to dereference; use debugger_dump_variable or debugger_variables to expand the value",
+            language
+        ))),
+    }
+}
+
+/// Whether `value`, a pointer as the debugger of `language` renders it, is
+/// nil: Delve writes `*main.Calculator nil` (`nil` for unsafe.Pointer),
+/// CodeLLDB a zero address
+pub fn is_nil_pointer(language: &str, value: &str) -> bool {
+    let value = value.trim();
+    let zero_address = value
+        .strip_prefix("0x")
+        .is_some_and(|digits| !digits.is_empty() && digits.bytes().all(|b| b == b'0'));
+    match language {
+        "go" => value == "nil" || value.ends_with(" nil") || zero_address,
+        _ => zero_address,
+    }
+}
+
+/// Translate exception breakpoint modes into an adapter's filter ids
+///
+/// `offered` are the `exceptionBreakpointFilters` the adapter reported in its
+/// initialize response; a filter it didn't offer is refused rather than sent
+/// and silently ignored.
+pub fn resolve_exception_filters(
+    language: &str,
+    adapter_id: &str,
+    modes: &[String],
+    offered: Option<&[String]>,
+) -> Result<Vec<String>> {
+    let table = exception_filters(language);
+    let mut filters = Vec::new();
+    for mode in modes {
+        if !EXCEPTION_MODES.contains(&mode.as_str()) {
+            return Err(Error::InvalidRequest(format!(
+                "Unknown exception breakpoint mode '{}' (expected: {})",
+                mode,
+                EXCEPTION_MODES.join(", ")
+            )));
+        }
+
+        let unsupported = |suggestion: Option<&str>| Error::UnsupportedCapability {
+            capability: format!("exceptionBreakpointFilters ({})", mode),
+            adapter: adapter_id.to_string(),
+            suggestion: suggestion.map(str::to_string),
+        };
+        let filter = match table.iter().find(|(m, _)| m == mode) {
+            Some((_, filter)) => *filter,
+            None if table.is_empty() => return Err(unsupported(None)),
+            None => {
+                let supported: Vec<&str> = table.iter().map(|(m, _)| *m).collect();
+                let suggestion = format!(
+                    "use mode {}; it also stops on exceptions that are handled later, so continue past those",
+                    supported.join(" or ")
+                );
+                return Err(unsupported(Some(&suggestion)));
+            }
+        };
+
+        match offered {
+            Some(offered) if offered.iter().any(|f| f == filter) => {}
+            _ => return Err(unsupported(Some(
+                "the debugger did not offer this exception filter; update it to a newer version",
+            ))),
+        }
+        if !filters.iter().any(|f| f == filter) {
+            filters.push(filter.to_string());
+        }
+    }
+    Ok(filters)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_resolve_on_uncaught() {
+        assert_eq!(
+            resolve_on_uncaught("python", None).unwrap(),
+            OnUncaught::Break
+        );
+        assert_eq!(resolve_on_uncaught("go", None).unwrap(), OnUncaught::Break);
+        assert_eq!(
+            resolve_on_uncaught("ruby", None).unwrap(),
+            OnUncaught::Terminate
+        );
+        assert_eq!(
+            resolve_on_uncaught("go", Some(OnUncaught::Terminate)).unwrap(),
+            OnUncaught::Terminate
+        );
+        let err = resolve_on_uncaught("nodejs", Some(OnUncaught::Break)).unwrap_err();
+        assert!(matches!(
+            err,
+            Error::UnsupportedCapability {
+                suggestion: None,
+                ..
+            }
+        ));
+        let err = resolve_on_uncaught("ruby", Some(OnUncaught::Break)).unwrap_err();
+        assert!(err.to_string().contains("raised"), "{}", err);
+    }
+
+    #[test]
+    fn test_resolve_mode_defaults() {
+        assert_eq!(resolve_mode("go", None).unwrap(), "debug");
+        assert_eq!(resolve_mode("go", Some("launch")).unwrap(), "debug");
+        assert_eq!(resolve_mode("python", None).unwrap(), "program");
+        assert_eq!(resolve_mode("ruby", Some("launch")).unwrap(), "launch");
+    }
+
+    #[test]
+    fn test_resolve_mode_per_language() {
+        assert_eq!(resolve_mode("go", Some("test")).unwrap(), "test");
+        assert_eq!(resolve_mode("go", Some("exec")).unwrap(), "exec");
+        assert_eq!(resolve_mode("python", Some("pytest")).unwrap(), "pytest");
+        assert_eq!(resolve_mode("python", Some("attach")).unwrap(), "attach");
+    }
+
+    #[test]
+    fn test_resolve_mode_rejects_unsupported_combinations() {
+        for (language, mode) in [
+            ("go", "module"),
+            ("python", "exec"),
+            ("ruby", "attach"),
+            ("rust", "test"),
+        ] {
+            match resolve_mode(language, Some(mode)) {
+                Err(Error::InvalidRequest(msg)) => {
+                    assert!(msg.contains(mode), "{}", msg);
+                    assert!(msg.contains("supported:"), "{}", msg);
+                }
+                other => panic!(
+                    "{} {}: expected InvalidRequest, got {:?}",
+                    language, mode, other
+                ),
+            }
+        }
+    }
+
+    #[test]
+    fn test_ruby_exception_filters() {
+        // rdbg's initialize response offers `any` and `RuntimeError`
+        let offered = vec!["any".to_string(), "RuntimeError".to_string()];
+        let modes = |m: &[&str]| m.iter().map(|s| s.to_string()).collect::<Vec<_>>();
+
+        assert_eq!(
+            resolve_exception_filters("ruby", "rdbg", &modes(&["raised"]), Some(&offered)).unwrap(),
+            vec!["any"]
+        );
+        assert!(
+            resolve_exception_filters("ruby", "rdbg", &[], Some(&offered))
+                .unwrap()
+                .is_empty()
+        );
+
+        match resolve_exception_filters("ruby", "rdbg", &modes(&["uncaught"]), Some(&offered)) {
+            Err(Error::UnsupportedCapability {
+                adapter,
+                suggestion,
+                ..
+            }) => {
+                assert_eq!(adapter, "rdbg");
+                assert!(suggestion.unwrap().contains("use mode raised"));
+            }
+            other => panic!("expected UnsupportedCapability, got {:?}", other),
+        }
+
+        // Older rdbg without exception filters
+        assert!(resolve_exception_filters("ruby", "rdbg", &modes(&["raised"]), None).is_err());
+        assert!(matches!(
+            resolve_exception_filters("ruby", "rdbg", &modes(&["thrown"]), Some(&offered)),
+            Err(Error::InvalidRequest(_))
+        ));
+    }
+
+    #[test]
+    fn test_validate_exception_options() {
+        let option = |path: serde_json::Value, mode: &str| -> ExceptionOptions {
+            serde_json::from_value(serde_json::json!({"path": path, "breakMode": mode})).unwrap()
+        };
+        let python = serde_json::json!([
+            {"names": ["Python Exceptions"]},
+            {"names": ["myapp.errors.ValidationError"]}
+        ]);
+        assert!(validate_exception_options(&[option(python.clone(), "always")]).is_ok());
+        assert!(validate_exception_options(&[option(serde_json::json!(null), "never")]).is_ok());
+
+        let err = validate_exception_options(&[option(python, "sometimes")]).unwrap_err();
+        assert!(err.to_string().contains("userUnhandled"));
+        let empty = serde_json::json!([{"names": []}]);
+        assert!(validate_exception_options(&[option(empty, "always")]).is_err());
+    }
+
+    #[test]
+    fn test_exception_filters_per_language() {
+        let offered = vec!["raised".to_string(), "uncaught".to_string()];
+        let both = vec!["uncaught".to_string(), "raised".to_string()];
+        assert_eq!(
+            resolve_exception_filters("python", "debugpy", &both, Some(&offered)).unwrap(),
+            vec!["uncaught", "raised"]
+        );
+        assert!(resolve_exception_filters("go", "delve", &both[..1], None).is_err());
+    }
+
+    #[test]
+    fn test_side_effect_free_context_per_language() {
+        assert_eq!(side_effect_free_context("nodejs").unwrap(), "hover");
+        assert_eq!(side_effect_free_context("go").unwrap(), "watch");
+        for language in ["python", "ruby", "rust"] {
+            let err = side_effect_free_context(language).unwrap_err();
+            assert!(err.to_string().contains("debugger_peek"), "{}", err);
+        }
+    }
+
+    #[test]
+    fn test_dereference_per_language() {
+        assert_eq!(dereference_expression("go", "c").unwrap(), "*(c)");
+        assert_eq!(
+            dereference_expression("rust", "node.next").unwrap(),
+            "*(node.next)"
+        );
+        assert!(dereference_expression("python", "c").is_err());
+
+        assert!(is_nil_pointer("go", "*main.Calculator nil"));
+        assert!(is_nil_pointer("go", "nil"));
+        assert!(!is_nil_pointer("go", "*main.Calculator {name: \"calc\"}"));
+        assert!(!is_nil_pointer("go", "(*main.Calculator)(0xc000010030)"));
+        assert!(is_nil_pointer("rust", "0x0000000000000000"));
+        assert!(!is_nil_pointer("rust", "0x00007ffe5d3c1a40"));
+    }
+}
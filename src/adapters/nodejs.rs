@@ -63,6 +63,7 @@
 //! - DAP spec: https://microsoft.github.io/debug-adapter-protocol/
 
 use super::logging::DebugAdapterLogger;
+use super::source_maps;
 use crate::dap::socket_helper;
 use crate::{Error, Result};
 use serde_json::{json, Value};
@@ -101,7 +102,13 @@ impl NodeJsAdapter {
     /// 2. /tmp/js-debug/src/dapDebugServer.js (integration tests)
     /// 3. /usr/local/lib/js-debug/src/dapDebugServer.js (alternative install)
     /// 4. ~/.vscode-js-debug/src/dapDebugServer.js (user install)
+    ///
+    /// `adapters.nodejs.path` in the server configuration takes precedence.
     pub fn dap_server_path() -> Result<String> {
+        if let Some(path) = crate::config::current().adapter_path("nodejs") {
+            return Ok(shellexpand::tilde(path).to_string());
+        }
+
         let locations = vec![
             "/usr/local/lib/vscode-js-debug/src/dapDebugServer.js",
             "/tmp/js-debug/src/dapDebugServer.js",
@@ -170,6 +177,7 @@ impl NodeJsAdapter {
                     e
                 ))
             })?;
+        crate::process::orphans::track(child.id());
 
         // 4. Connect to DAP server (with 2 second timeout)
         let socket = socket_helper::connect_with_retry(port, Duration::from_secs(2))
@@ -204,6 +212,9 @@ impl NodeJsAdapter {
     /// - args: Arguments to pass to the Node.js program
     /// - cwd: Working directory (optional)
     /// - stop_on_entry: Whether to stop at the first line
+    ///
+    /// Source maps are on for the program's project, so TypeScript compiled
+    /// to it is debugged in `.ts` coordinates (see `source_maps`).
     pub fn launch_config(
         program: &str,
         args: &[String],
@@ -220,6 +231,14 @@ impl NodeJsAdapter {
             "console": "internalConsole",
         });
 
+        // Without a workspace folder js-debug's defaults for these match
+        // nothing, so breakpoints in not-yet-loaded .ts files wouldn't bind
+        let root = source_maps::project_root(std::path::Path::new(program));
+        launch["sourceMaps"] = json!(true);
+        launch["outFiles"] = json!(source_maps::out_files(&root));
+        launch["resolveSourceMapLocations"] =
+            json!([format!("{}/**", root.display()), "!**/node_modules/**"]);
+
         if let Some(cwd_path) = cwd {
             launch["cwd"] = json!(cwd_path);
         }
@@ -391,6 +410,9 @@ mod tests {
         assert_eq!(config["stopOnEntry"], true);
         assert_eq!(config["cwd"], "/workspace");
         assert_eq!(config["console"], "internalConsole");
+        assert_eq!(config["sourceMaps"], true);
+        assert_eq!(config["outFiles"][0], "/workspace/**/*.js");
+        assert_eq!(config["outFiles"][3], "!**/node_modules/**");
     }
 
     #[test]
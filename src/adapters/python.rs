@@ -1,4 +1,5 @@
 use super::logging::DebugAdapterLogger;
+use serde::{Deserialize, Serialize};
 use serde_json::{json, Value};
 use std::error::Error;
 use tracing::error;
@@ -7,8 +8,13 @@ use tracing::error;
 pub struct PythonAdapter;
 
 impl PythonAdapter {
+    /// Python interpreter that runs debugpy: `adapters.python.path` from the
+    /// server configuration, else `python` from PATH
     pub fn command() -> String {
-        "python".to_string()
+        crate::config::current()
+            .adapter_path("python")
+            .unwrap_or("python")
+            .to_string()
     }
 
     pub fn args() -> Vec<String> {
@@ -24,6 +30,26 @@ impl PythonAdapter {
         "debugpy"
     }
 
+    /// Index of a list or tuple element from its variable name: debugpy
+    /// names them `0`, `1`, ... (next to a `len()` entry)
+    pub fn element_index(name: &str) -> Option<i64> {
+        name.parse().ok()
+    }
+
+    /// Variable names debugpy gives the entry of dict key `key`: the key's
+    /// repr, so `'key'` for strings (`"key"` when they contain a `'`)
+    pub fn map_key_names(key: &str) -> Vec<String> {
+        vec![
+            format!("'{}'", key),
+            format!("\"{}\"", key),
+            key.to_string(),
+        ]
+    }
+
+    /// Exception breakpoint modes debugpy honours, with its filter for each
+    pub const EXCEPTION_FILTERS: &'static [(&'static str, &'static str)] =
+        &[("raised", "raised"), ("uncaught", "uncaught")];
+
     pub fn launch_args(program: &str, args: &[String], cwd: Option<&str>) -> Value {
         Self::launch_args_with_options(program, args, cwd, false)
     }
@@ -41,10 +67,12 @@ impl PythonAdapter {
             "args": args,
             "console": "internalConsole",  // Use internalConsole instead of integratedTerminal
             "stopOnEntry": stop_on_entry,
+            // Report the return value of a function stepped out of in Locals
+            "showReturnValue": true,
             // Add Python options to disable frozen modules (Python 3.11+)
             "pythonArgs": ["-Xfrozen_modules=off"],
             // Use the same Python interpreter that's running the adapter
-            "python": "python",
+            "python": Self::command(),
         });
 
         if let Some(cwd_path) = cwd {
@@ -53,6 +81,55 @@ impl PythonAdapter {
 
         launch
     }
+
+    /// Launch configuration for a specific mode
+    ///
+    /// - `program`: run `program` as a script (default)
+    /// - `module`: run `program` as a module name (`python -m program`)
+    /// - `pytest`: run pytest with `program` (a test file or directory) as the
+    ///   first argument
+    pub fn launch_args_for_mode(
+        program: &str,
+        args: &[String],
+        cwd: Option<&str>,
+        stop_on_entry: bool,
+        mode: &str,
+    ) -> Value {
+        let mut launch = Self::launch_args_with_options(program, args, cwd, stop_on_entry);
+        if mode == "program" {
+            return launch;
+        }
+
+        if let Some(obj) = launch.as_object_mut() {
+            obj.remove("program");
+        }
+        if mode == "pytest" {
+            let mut pytest_args = vec![program.to_string()];
+            pytest_args.extend_from_slice(args);
+            launch["module"] = json!("pytest");
+            launch["args"] = json!(pytest_args);
+        } else {
+            launch["module"] = json!(program);
+            // Modules are often installed packages; with justMyCode debugpy
+            // would refuse to bind breakpoints in their files
+            launch["justMyCode"] = json!(false);
+        }
+
+        launch
+    }
+
+    /// Attach arguments for a running Python process
+    ///
+    /// debugpy injects itself into the target process by pid (requires ptrace
+    /// permission, e.g. `--cap-add=SYS_PTRACE` in containers).
+    pub fn attach_args(process_id: u32) -> Value {
+        json!({
+            "request": "attach",
+            "type": "python",
+            "processId": process_id,
+            "justMyCode": false,
+        })
+    }
 }
 
 // ============================================================================
@@ -129,6 +206,183 @@ impl DebugAdapterLogger for PythonAdapter {
     }
 }
 
+// ============================================================================
+// Asyncio Tasks
+// ============================================================================
+
+/// Maximum number of asyncio tasks reported by a task listing
+pub const MAX_ASYNC_TASKS: usize = 50;
+
+/// Longest coroutine name, path or awaited-object repr kept per task
+const ASYNC_TASK_FIELD_CHARS: usize = 200;
+
+/// Python expression listing the event loop's tasks, evaluated in the stopped frame
+///
+/// DAP evaluate takes a single expression, hence the lambdas. The result is
+/// JSON, hex-encoded so that the string repr debugpy returns needs no
+/// unescaping: the only thing to strip are its quotes. `_get_running_loop()`
+/// returns None instead of raising when the frame isn't inside an event loop.
+/// The await location is the innermost frame of the task's coroutine chain
+/// (`Task.get_stack`), and `_fut_waiter` the future it is blocked on.
+const ASYNC_TASKS_EXPRESSION: &str = r#"(lambda asyncio, json, binascii: binascii.hexlify(json.dumps(
+    (lambda loop: {"loop": False} if loop is None else (lambda tasks, current: {
+        "loop": True,
+        "total": len(tasks),
+        "tasks": [(lambda stack, waiter: {
+            "name": t.get_name(),
+            "coroutine": getattr(t.get_coro(), "__qualname__", repr(t.get_coro()))[:FIELD_CHARS],
+            "state": "cancelled" if t.cancelled() else "done" if t.done() else "running" if t is current else "pending",
+            "function": stack[-1].f_code.co_name if stack else None,
+            "path": stack[-1].f_code.co_filename[-FIELD_CHARS:] if stack else None,
+            "line": stack[-1].f_lineno if stack else None,
+            "waitingOn": None if waiter is None else repr(waiter)[:FIELD_CHARS],
+        })([] if t.done() else t.get_stack(), getattr(t, "_fut_waiter", None)) for t in tasks[:MAX_TASKS]],
+    })(sorted(asyncio.all_tasks(loop), key=lambda t: t.get_name()), asyncio.current_task(loop)))(asyncio._get_running_loop()),
+    ensure_ascii=True).encode()).decode())(__import__("asyncio"), __import__("json"), __import__("binascii"))"#;
+
+/// An asyncio task as seen from a stopped frame
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct AsyncTask {
+    pub name: String,
+    /// Qualified name of the task's coroutine
+    pub coroutine: String,
+    /// pending, running (the task the program stopped in), done or cancelled
+    pub state: String,
+    /// Where the task is suspended (or running): innermost function, file and line
+    pub function: Option<String>,
+    pub path: Option<String>,
+    pub line: Option<i64>,
+    /// Repr of the future the task is blocked on, if any
+    pub waiting_on: Option<String>,
+}
+
+/// Tasks of the event loop running in the stopped thread
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct AsyncTasks {
+    /// Whether the stopped frame runs inside an event loop
+    pub event_loop: bool,
+    /// Number of unfinished tasks in the loop
+    pub total: usize,
+    /// Tasks sorted by name, at most `MAX_ASYNC_TASKS`
+    pub tasks: Vec<AsyncTask>,
+    /// More than `MAX_ASYNC_TASKS` tasks exist
+    pub truncated: bool,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub message: Option<String>,
+}
+
+impl PythonAdapter {
+    /// First line of a module that runs code: not blank, a comment, the
+    /// module docstring or a `__future__` import
+    pub fn find_first_executable_line(source: &str) -> Option<usize> {
+        let mut docstring_allowed = true;
+        let mut in_docstring: Option<&str> = None;
+        for (i, line) in source.lines().enumerate() {
+            let trimmed = line.trim();
+            if let Some(quote) = in_docstring {
+                if trimmed.contains(quote) {
+                    in_docstring = None;
+                }
+                continue;
+            }
+            if trimmed.is_empty() || trimmed.starts_with('#') {
+                continue;
+            }
+            if docstring_allowed {
+                docstring_allowed = false;
+                let unprefixed = trimmed.trim_start_matches(['r', 'R', 'u', 'U']);
+                if let Some(quote) = ["\"\"\"", "'''"]
+                    .into_iter()
+                    .find(|q| unprefixed.starts_with(q))
+                {
+                    if !unprefixed[quote.len()..].contains(quote) {
+                        in_docstring = Some(quote);
+                    }
+                    continue;
+                }
+            }
+            if trimmed.starts_with("from __future__ import") {
+                continue;
+            }
+            return Some(i + 1);
+        }
+        None
+    }
+
+    /// Entry breakpoint for `entry: "user_main"`: the first executable line
+    /// of the `__main__` module (the program file)
+    pub fn user_main_entry(program: &str) -> crate::Result<super::EntryBreakpoint> {
+        let source = std::fs::read_to_string(program)
+            .map_err(|e| crate::Error::InvalidRequest(format!("Cannot read {}: {}", program, e)))?;
+        let line = Self::find_first_executable_line(&source).ok_or_else(|| {
+            crate::Error::InvalidRequest(format!("{} has no executable line", program))
+        })?;
+        Ok(super::EntryBreakpoint::Line {
+            path: program.to_string(),
+            line: line as i32,
+        })
+    }
+
+    /// Expression to evaluate in the stopped frame to list asyncio tasks
+    pub fn async_tasks_expression() -> String {
+        ASYNC_TASKS_EXPRESSION
+            .replace("MAX_TASKS", &MAX_ASYNC_TASKS.to_string())
+            .replace("FIELD_CHARS", &ASYNC_TASK_FIELD_CHARS.to_string())
+    }
+
+    /// Decode the result of evaluating `async_tasks_expression()`
+    pub fn parse_async_tasks(result: &str) -> crate::Result<AsyncTasks> {
+        #[derive(Deserialize)]
+        struct Listing {
+            #[serde(rename = "loop")]
+            event_loop: bool,
+            #[serde(default)]
+            total: usize,
+            #[serde(default)]
+            tasks: Vec<AsyncTask>,
+        }
+
+        let unexpected = || {
+            let preview: String = result.chars().take(200).collect();
+            crate::Error::Dap(format!("Unexpected asyncio task listing: {}", preview))
+        };
+
+        let hex = result.trim().trim_matches(|c| c == '\'' || c == '"');
+        if hex.len() % 2 != 0 || !hex.bytes().all(|b| b.is_ascii_hexdigit()) {
+            return Err(unexpected());
+        }
+        let bytes = (0..hex.len())
+            .step_by(2)
+            .map(|i| u8::from_str_radix(&hex[i..i + 2], 16))
+            .collect::<std::result::Result<Vec<u8>, _>>()
+            .map_err(|_| unexpected())?;
+        let listing: Listing = serde_json::from_slice(&bytes).map_err(|_| unexpected())?;
+
+        if !listing.event_loop {
+            return Ok(AsyncTasks {
+                event_loop: false,
+                total: 0,
+                tasks: Vec::new(),
+                truncated: false,
+                message: Some(
+                    "No running event loop: the program is stopped outside async code (e.g. in synchronous code or before asyncio.run)"
+                        .to_string(),
+                ),
+            });
+        }
+
+        Ok(AsyncTasks {
+            event_loop: true,
+            total: listing.total,
+            truncated: listing.total > listing.tasks.len(),
+            tasks: listing.tasks,
+            message: None,
+        })
+    }
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -167,6 +421,48 @@ mod tests {
         assert!(launch["cwd"].is_null());
     }
 
+    #[test]
+    fn test_launch_args_module_mode() {
+        let args = vec!["--port".to_string(), "8000".to_string()];
+        let launch =
+            PythonAdapter::launch_args_for_mode("myapp.server", &args, None, false, "module");
+
+        assert_eq!(launch["module"], "myapp.server");
+        assert!(launch["program"].is_null());
+        assert_eq!(launch["args"], json!(args));
+        assert_eq!(launch["justMyCode"], false);
+
+        let launch = PythonAdapter::launch_args_for_mode(
+            "myapp.server",
+            &[],
+            Some("/workspace"),
+            false,
+            "module",
+        );
+        assert_eq!(launch["cwd"], "/workspace");
+    }
+
+    #[test]
+    fn test_launch_args_pytest_mode() {
+        let args = vec!["-k".to_string(), "test_login".to_string()];
+        let launch =
+            PythonAdapter::launch_args_for_mode("/workspace/tests", &args, None, true, "pytest");
+
+        assert_eq!(launch["module"], "pytest");
+        assert!(launch["program"].is_null());
+        assert_eq!(
+            launch["args"],
+            json!(["/workspace/tests", "-k", "test_login"])
+        );
+        assert_eq!(launch["stopOnEntry"], true);
+
+        let default = PythonAdapter::launch_args_for_mode("app.py", &[], None, false, "program");
+        assert_eq!(
+            default,
+            PythonAdapter::launch_args_with_options("app.py", &[], None, false)
+        );
+    }
+
     #[test]
     fn test_launch_args_with_cwd() {
         let program = "/path/to/script.py";
@@ -186,4 +482,96 @@ mod tests {
 
         assert_eq!(launch["args"], json!([]));
     }
+
+    #[test]
+    fn test_attach_args() {
+        let attach = PythonAdapter::attach_args(1234);
+
+        assert_eq!(attach["request"], "attach");
+        assert_eq!(attach["type"], "python");
+        assert_eq!(attach["processId"], 1234);
+        assert!(attach["program"].is_null());
+    }
+
+    fn hex(text: &str) -> String {
+        text.bytes().map(|b| format!("{:02x}", b)).collect()
+    }
+
+    #[test]
+    fn test_async_tasks_expression_substitutes_limits() {
+        let expression = PythonAdapter::async_tasks_expression();
+        assert!(expression.contains("tasks[:50]"));
+        assert!(expression.contains("[:200]"));
+        assert!(!expression.contains("MAX_TASKS") && !expression.contains("FIELD_CHARS"));
+    }
+
+    #[test]
+    fn test_parse_async_tasks() {
+        // Stopped in worker() of tests/fixtures/async_tasks.py
+        let listing = r#"{"loop": true, "total": 4, "tasks": [
+            {"name": "Task-1", "coroutine": "main", "state": "pending", "function": "main", "path": "/w/async_tasks.py", "line": 30, "waitingOn": null},
+            {"name": "sleeper", "coroutine": "sleeper", "state": "pending", "function": "sleeper", "path": "/w/async_tasks.py", "line": 10, "waitingOn": "<Future pending cb=[Task.task_wakeup()]>"},
+            {"name": "waiter", "coroutine": "waiter", "state": "pending", "function": "waiter", "path": "/w/async_tasks.py", "line": 14, "waitingOn": "<Future pending cb=[Task.task_wakeup()]>"},
+            {"name": "worker", "coroutine": "worker", "state": "running", "function": "worker", "path": "/w/async_tasks.py", "line": 18, "waitingOn": null}
+        ]}"#;
+        let tasks = PythonAdapter::parse_async_tasks(&format!("'{}'", hex(listing))).unwrap();
+
+        assert!(tasks.event_loop && !tasks.truncated);
+        assert_eq!(tasks.total, 4);
+        let sleeper = &tasks.tasks[1];
+        assert_eq!(sleeper.name, "sleeper");
+        assert_eq!(sleeper.line, Some(10));
+        assert!(sleeper
+            .waiting_on
+            .as_deref()
+            .unwrap()
+            .starts_with("<Future pending"));
+        assert_eq!(tasks.tasks[3].state, "running");
+        assert!(tasks.tasks[3].waiting_on.is_none());
+    }
+
+    #[test]
+    fn test_parse_async_tasks_without_loop_or_truncated() {
+        let tasks =
+            PythonAdapter::parse_async_tasks(&format!("'{}'", hex(r#"{"loop": false}"#))).unwrap();
+        assert!(!tasks.event_loop);
+        assert!(tasks.message.unwrap().contains("No running event loop"));
+
+        let listing = r#"{"loop": true, "total": 80, "tasks": [{"name": "t", "coroutine": "c", "state": "pending", "function": null, "path": null, "line": null, "waitingOn": null}]}"#;
+        assert!(
+            PythonAdapter::parse_async_tasks(&hex(listing))
+                .unwrap()
+                .truncated
+        );
+
+        // debugpy cuts very long reprs and reports exceptions as text
+        for result in ["'7b22", "NameError: name 'asyncio' is not defined", "'zz'"] {
+            assert!(
+                PythonAdapter::parse_async_tasks(result).is_err(),
+                "{}",
+                result
+            );
+        }
+    }
+
+    #[test]
+    fn test_user_main_entry() {
+        // Shebang and module docstring come before `def fizzbuzz(n):`
+        let fixture = concat!(env!("CARGO_MANIFEST_DIR"), "/tests/fixtures/fizzbuzz.py");
+        assert_eq!(
+            PythonAdapter::user_main_entry(fixture).unwrap(),
+            super::super::EntryBreakpoint::Line {
+                path: fixture.to_string(),
+                line: 8
+            }
+        );
+
+        let source = "# -*- coding: utf-8 -*-\n'''One line.'''\nfrom __future__ import annotations\n\nimport sys\n";
+        assert_eq!(PythonAdapter::find_first_executable_line(source), Some(5));
+        assert_eq!(
+            PythonAdapter::find_first_executable_line("\"\"\"Only a docstring\"\"\"\n# end\n"),
+            None
+        );
+        assert!(PythonAdapter::user_main_entry("/nonexistent/app.py").is_err());
+    }
 }
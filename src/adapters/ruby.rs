@@ -1,11 +1,13 @@
+use super::exec_prefix;
 use super::logging::DebugAdapterLogger;
 use crate::dap::socket_helper;
 use crate::{Error, Result};
+use serde::Deserialize;
 use serde_json::{json, Value};
 use std::time::Duration;
 use tokio::net::TcpStream;
 use tokio::process::{Child, Command};
-use tracing::{error, info};
+use tracing::{error, info, warn};
 
 /// Ruby rdbg (debug gem) adapter configuration
 ///
@@ -13,6 +15,22 @@ use tracing::{error, info};
 /// rdbg runs the program directly and communicates via TCP socket.
 pub struct RubyAdapter;
 
+/// Ruby-specific launch options
+///
+/// rdbg runs the program itself, so bundler and Rails apps are debugged by
+/// putting rdbg in command mode (`rdbg -c -- <command>`).
+#[derive(Debug, Clone, Default, PartialEq, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct RubyLaunchOptions {
+    /// Run the program via `bundle exec` (uses the Gemfile in cwd)
+    #[serde(default)]
+    pub bundler: bool,
+    /// Treat `program` as a Rails executable (e.g. `bin/rails`) and run
+    /// `<program> server` unless other args are given
+    #[serde(default)]
+    pub rails: bool,
+}
+
 /// Result of spawning Ruby debugger (process + connected socket)
 pub struct RubyDebugSession {
     pub process: Child,
@@ -21,8 +39,12 @@ pub struct RubyDebugSession {
 }
 
 impl RubyAdapter {
+    /// `adapters.ruby.path` from the server configuration, else `rdbg` from PATH
     pub fn command() -> String {
-        "rdbg".to_string()
+        crate::config::current()
+            .adapter_path("ruby")
+            .unwrap_or("rdbg")
+            .to_string()
     }
 
     /// Spawn rdbg with socket-based DAP communication
@@ -33,33 +55,51 @@ impl RubyAdapter {
         program: &str,
         program_args: &[String],
         stop_on_entry: bool,
+    ) -> Result<RubyDebugSession> {
+        Self::spawn_with_options(
+            program,
+            program_args,
+            stop_on_entry,
+            None,
+            &RubyLaunchOptions::default(),
+            &[],
+        )
+        .await
+    }
+
+    /// Spawn rdbg with Ruby-specific launch options (bundler, rails)
+    ///
+    /// `cwd` is where rdbg (and therefore the program) runs; bundler and Rails
+    /// need it to find the Gemfile / application root. With an `exec_prefix`,
+    /// rdbg runs through it in another container or namespace (see
+    /// `exec_prefix`).
+    pub async fn spawn_with_options(
+        program: &str,
+        program_args: &[String],
+        stop_on_entry: bool,
+        cwd: Option<&str>,
+        options: &RubyLaunchOptions,
+        exec_prefix: &[String],
     ) -> Result<RubyDebugSession> {
         // 1. Find free port
         let port = socket_helper::find_free_port()?;
 
         // 2. Build command args
-        let mut args = vec!["--open".to_string(), "--port".to_string(), port.to_string()];
-
-        // Add stop behavior flag
-        if stop_on_entry {
-            args.push("--stop-at-load".to_string());
-        } else {
-            args.push("--nonstop".to_string());
-        }
-
-        // Add program path
-        args.push(program.to_string());
-
-        // Add program arguments
-        args.extend(program_args.iter().cloned());
+        let args = Self::spawn_args(port, program, program_args, stop_on_entry, options);
 
         info!("Spawning rdbg on port {}: rdbg {:?}", port, args);
 
         // 3. Spawn rdbg process
-        let child = Command::new("rdbg")
-            .args(&args)
+        let (executable, args) = exec_prefix::wrap(exec_prefix, &Self::command(), &args);
+        let mut command = Command::new(executable);
+        command.args(&args);
+        if let Some(dir) = cwd {
+            command.current_dir(dir);
+        }
+        let child = command
             .spawn()
             .map_err(|e| Error::Process(format!("Failed to spawn rdbg: {}", e)))?;
+        crate::process::orphans::track(child.id());
 
         // 4. Connect to socket (with 2 second timeout)
         let socket = socket_helper::connect_with_retry(port, Duration::from_secs(2))
@@ -75,10 +115,81 @@ impl RubyAdapter {
         })
     }
 
+    /// Build the rdbg command line
+    ///
+    /// Plain scripts: `--open --port <PORT> <stop flag> program args...`
+    /// Bundler/Rails: `--open --port <PORT> <stop flag> -c -- [bundle exec] <command>`
+    pub fn spawn_args(
+        port: u16,
+        program: &str,
+        program_args: &[String],
+        stop_on_entry: bool,
+        options: &RubyLaunchOptions,
+    ) -> Vec<String> {
+        let mut args = vec!["--open".to_string(), "--port".to_string(), port.to_string()];
+
+        // Add stop behavior flag
+        if stop_on_entry {
+            args.push("--stop-at-load".to_string());
+        } else {
+            args.push("--nonstop".to_string());
+        }
+
+        if !options.bundler && !options.rails {
+            args.push(program.to_string());
+            args.extend(program_args.iter().cloned());
+            return args;
+        }
+
+        // Command mode: rdbg runs an arbitrary command instead of a script
+        args.push("-c".to_string());
+        args.push("--".to_string());
+
+        if options.bundler {
+            args.push("bundle".to_string());
+            args.push("exec".to_string());
+            if !options.rails {
+                args.push("ruby".to_string());
+            }
+        }
+
+        args.push(program.to_string());
+
+        if options.rails && program_args.is_empty() {
+            args.push("server".to_string());
+        }
+        args.extend(program_args.iter().cloned());
+
+        args
+    }
+
     pub fn adapter_id() -> &'static str {
         "rdbg"
     }
 
+    /// Index of an array element from its variable name (`[3]`)
+    pub fn element_index(name: &str) -> Option<i64> {
+        name.strip_prefix('[')?.strip_suffix(']')?.parse().ok()
+    }
+
+    /// Variable names rdbg gives the entry of hash key `key`: the key's
+    /// inspect, so `"key"` for strings and `:key` for symbols
+    pub fn map_key_names(key: &str) -> Vec<String> {
+        vec![format!("\"{}\"", key), format!(":{}", key), key.to_string()]
+    }
+
+    /// Exception breakpoint modes rdbg honours, with its filter for each
+    ///
+    /// rdbg's filters are catch points: `any` stops wherever an exception is
+    /// raised (like `catch Exception`), whether or not something rescues it
+    /// later. rdbg can't know at raise time whether an exception will escape,
+    /// so there is no `uncaught` mode.
+    pub const EXCEPTION_FILTERS: &'static [(&'static str, &'static str)] = &[("raised", "any")];
+
+    /// Step filters of a new session: installed gems and the interpreter's
+    /// own Ruby code
+    pub const STEP_FILTERS: &'static [&'static str] = &["*/gems/*", "<internal:*"];
+
     pub fn launch_args_with_options(
         program: &str,
         args: &[String],
@@ -101,6 +212,83 @@ impl RubyAdapter {
 
         launch
     }
+
+    /// Find the first executable line in a Ruby source file
+    ///
+    /// Skips comments, empty lines, requires, and class/module definitions
+    /// to find the first actual executable line.
+    ///
+    /// Used for the stopOnEntry workaround: rdbg ignores `--stop-at-load` in
+    /// socket mode, so an entry breakpoint is set on this line instead.
+    ///
+    /// Returns line number (1-indexed) or 1 as fallback.
+    pub fn find_first_executable_line(program_path: &str) -> usize {
+        use std::fs;
+
+        let content = match fs::read_to_string(program_path) {
+            Ok(c) => c,
+            Err(e) => {
+                warn!(
+                    "Could not read {} for line detection: {}, using line 1",
+                    program_path, e
+                );
+                return 1;
+            }
+        };
+
+        for (line_num, line) in content.lines().enumerate() {
+            let trimmed = line.trim();
+
+            // Skip empty lines
+            if trimmed.is_empty() {
+                continue;
+            }
+
+            // Skip shebang
+            if line_num == 0 && trimmed.starts_with("#!") {
+                continue;
+            }
+
+            // Skip comments
+            if trimmed.starts_with('#') {
+                continue;
+            }
+
+            // Skip requires/loads (not executable, just declarations)
+            if trimmed.starts_with("require") || trimmed.starts_with("load") {
+                continue;
+            }
+
+            // Skip class/module/def declarations (not entry point)
+            if trimmed.starts_with("class ") || trimmed.starts_with("module ") {
+                // Continue looking inside the class for executable code
+                continue;
+            }
+
+            // Found first executable line!
+            info!("  First executable line detected: {}", line_num + 1);
+            return line_num + 1; // DAP uses 1-indexed lines
+        }
+
+        // Fallback: No executable line found, use line 1
+        warn!("No executable line found in {}, using line 1", program_path);
+        1
+    }
+
+    /// Entry breakpoint for `entry: "user_main"`: the program file's first
+    /// executable line
+    pub fn user_main_entry(program: &str) -> Result<super::EntryBreakpoint> {
+        if !std::path::Path::new(program).is_file() {
+            return Err(Error::InvalidRequest(format!(
+                "Cannot read {}: not a file",
+                program
+            )));
+        }
+        Ok(super::EntryBreakpoint::Line {
+            path: program.to_string(),
+            line: Self::find_first_executable_line(program) as i32,
+        })
+    }
 }
 
 // ============================================================================
@@ -251,4 +439,113 @@ mod tests {
 
         assert_eq!(launch["args"], json!([]));
     }
+
+    #[test]
+    fn test_spawn_args_plain_script() {
+        let args = RubyAdapter::spawn_args(
+            4000,
+            "app.rb",
+            &["a".to_string()],
+            true,
+            &RubyLaunchOptions::default(),
+        );
+        assert_eq!(
+            args,
+            vec!["--open", "--port", "4000", "--stop-at-load", "app.rb", "a"]
+        );
+    }
+
+    #[test]
+    fn test_spawn_args_bundler() {
+        let options = RubyLaunchOptions {
+            bundler: true,
+            rails: false,
+        };
+        let args = RubyAdapter::spawn_args(4000, "app.rb", &[], false, &options);
+        assert_eq!(
+            args,
+            vec![
+                "--open",
+                "--port",
+                "4000",
+                "--nonstop",
+                "-c",
+                "--",
+                "bundle",
+                "exec",
+                "ruby",
+                "app.rb"
+            ]
+        );
+    }
+
+    #[test]
+    fn test_spawn_args_rails_defaults_to_server() {
+        let options = RubyLaunchOptions {
+            bundler: true,
+            rails: true,
+        };
+        let args = RubyAdapter::spawn_args(4000, "bin/rails", &[], true, &options);
+        assert_eq!(
+            &args[4..],
+            &["-c", "--", "bundle", "exec", "bin/rails", "server"]
+        );
+
+        let options = RubyLaunchOptions {
+            bundler: false,
+            rails: true,
+        };
+        let args = RubyAdapter::spawn_args(
+            4000,
+            "bin/rails",
+            &["runner".to_string(), "Job.perform".to_string()],
+            true,
+            &options,
+        );
+        assert_eq!(
+            &args[4..],
+            &["-c", "--", "bin/rails", "runner", "Job.perform"]
+        );
+    }
+
+    #[test]
+    fn test_launch_options_deserialize() {
+        let options: RubyLaunchOptions = serde_json::from_value(json!({"bundler": true})).unwrap();
+        assert!(options.bundler);
+        assert!(!options.rails);
+    }
+
+    #[test]
+    fn test_find_first_executable_line() {
+        let dir = tempfile::tempdir().unwrap();
+        let path = dir.path().join("script.rb");
+        std::fs::write(
+            &path,
+            "#!/usr/bin/env ruby\n# comment\nrequire 'json'\n\nclass Foo\n  def bar; end\nend\nputs 'hi'\n",
+        )
+        .unwrap();
+
+        // "def bar" on line 6 is the first line not skipped
+        assert_eq!(
+            RubyAdapter::find_first_executable_line(path.to_str().unwrap()),
+            6
+        );
+        assert_eq!(
+            RubyAdapter::find_first_executable_line("/nonexistent/script.rb"),
+            1
+        );
+    }
+
+    #[test]
+    fn test_user_main_entry() {
+        let fixture = concat!(env!("CARGO_MANIFEST_DIR"), "/tests/fixtures/fizzbuzz.rb");
+        assert_eq!(
+            RubyAdapter::user_main_entry(fixture).unwrap(),
+            super::super::EntryBreakpoint::Line {
+                path: fixture.to_string(),
+                line: 4
+            }
+        );
+        assert!(RubyAdapter::user_main_entry("/nonexistent/script.rb").is_err());
+    }
 }
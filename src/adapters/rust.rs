@@ -103,7 +103,13 @@ impl RustAdapter {
     /// 2. /usr/local/bin/codelldb (Docker container - old location)
     /// 3. /usr/bin/codelldb (system install)
     /// 4. codelldb (in PATH)
+    ///
+    /// `adapters.rust.path` in the server configuration takes precedence.
     pub fn command() -> String {
+        if let Some(path) = crate::config::current().adapter_path("rust") {
+            return path.to_string();
+        }
+
         let locations = vec![
             "/usr/local/lib/codelldb/adapter/codelldb",
             "/usr/local/bin/codelldb",
@@ -173,6 +179,7 @@ impl RustAdapter {
             .args(&args)
             .spawn()
             .map_err(|e| Error::Process(format!("Failed to spawn codelldb: {}", e)))?;
+        crate::process::orphans::track(child.id());
 
         // 4. Connect to socket (with 3 second timeout - CodeLLDB needs a moment to start)
         let socket = socket_helper::connect_with_retry(port, Duration::from_secs(3))
@@ -730,6 +737,26 @@ impl DebugAdapterLogger for RustAdapter {
 
 /// Helper to log Rust-specific compilation step
 impl RustAdapter {
+    /// Name of the program's `main` for a function breakpoint: the crate's
+    /// `main`, qualified so LLDB doesn't take the C `main` rustc generates
+    ///
+    /// The crate is named after the source file, or after the package for a
+    /// Cargo project's `src/main.rs`.
+    pub fn entry_function(source_path: &str) -> String {
+        let path = std::path::Path::new(source_path);
+        let stem = path.file_stem().and_then(|s| s.to_str()).unwrap_or("main");
+        let crate_name = if stem == "main" {
+            path.parent()
+                .and_then(|src| src.parent())
+                .and_then(|root| std::fs::read_to_string(root.join("Cargo.toml")).ok())
+                .and_then(|manifest| package_name(&manifest))
+                .unwrap_or_else(|| stem.to_string())
+        } else {
+            stem.to_string()
+        };
+        format!("{}::main", crate_name.replace('-', "_"))
+    }
+
     pub fn log_compilation_start(source: &str, release: bool) {
         let build_type = if release { "release" } else { "debug" };
         info!("🔨 [RUST] Compiling {} ({} build)", source, build_type);
@@ -757,6 +784,24 @@ impl RustAdapter {
     }
 }
 
+/// `name` of the `[package]` table of a Cargo manifest
+fn package_name(manifest: &str) -> Option<String> {
+    let mut in_package = false;
+    for line in manifest.lines().map(str::trim) {
+        if line.starts_with('[') {
+            in_package = line == "[package]";
+        } else if in_package {
+            if let Some(value) = line
+                .strip_prefix("name")
+                .and_then(|rest| rest.trim_start().strip_prefix('='))
+            {
+                return Some(value.trim().trim_matches('"').to_string());
+            }
+        }
+    }
+    None
+}
+
 /// Helper to log Rust-specific connection success with port information
 impl RustDebugSession {
     pub fn log_connection_success_with_port(&self) {
@@ -778,6 +823,28 @@ mod tests {
         assert!(cmd.contains("codelldb"));
     }
 
+    #[test]
+    fn test_entry_function_is_the_crates_main() {
+        assert_eq!(
+            RustAdapter::entry_function("/w/fizz-buzz.rs"),
+            "fizz_buzz::main"
+        );
+
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::create_dir(dir.path().join("src")).unwrap();
+        std::fs::write(
+            dir.path().join("Cargo.toml"),
+            "[package]\nversion = \"0.1.0\"\nname = \"cargo-simple\"\n\n[dependencies]\nname = \"x\"\n",
+        )
+        .unwrap();
+        let main = dir.path().join("src/main.rs");
+        assert_eq!(
+            RustAdapter::entry_function(main.to_str().unwrap()),
+            "cargo_simple::main"
+        );
+        assert_eq!(RustAdapter::entry_function("/w/src/main.rs"), "main::main");
+    }
+
     #[test]
     fn test_args() {
         let args = RustAdapter::args();
@@ -2,7 +2,7 @@
 //!
 //! Provides functions to prevent path traversal attacks when debugging programs.
 
-use crate::{Error, Result};
+use crate::{config, Error, Result};
 use std::path::{Component, Path, PathBuf};
 
 /// Validates a source file path to prevent path traversal attacks
@@ -10,7 +10,7 @@ use std::path::{Component, Path, PathBuf};
 /// Security checks:
 /// 1. Canonicalizes path to resolve symlinks and .. components
 /// 2. Validates path exists
-/// 3. (Optional) Ensures path is within WORKSPACE_ROOT if set
+/// 3. (Optional) Ensures path is within a workspace root if any are set
 /// 4. (Optional) Validates file extension matches expected language
 ///
 /// # Arguments
@@ -37,7 +37,7 @@ use std::path::{Component, Path, PathBuf};
 /// This function prevents:
 /// - Path traversal: `../../../../etc/passwd`
 /// - Symlink attacks: `/workspace/link -> /etc/shadow`
-/// - Workspace escapes: `/home/user/secrets.txt` (when WORKSPACE_ROOT is set)
+/// - Workspace escapes: `/home/user/secrets.txt` (when workspace roots are set)
 pub fn validate_source_path(path_str: &str, expected_extension: Option<&str>) -> Result<PathBuf> {
     // First validation: check for suspicious patterns before canonicalization
     let path = Path::new(path_str);
@@ -60,20 +60,8 @@ pub fn validate_source_path(path_str: &str, expected_extension: Option<&str>) ->
         ))
     })?;
 
-    // If WORKSPACE_ROOT environment variable is set, ensure path is within workspace
-    if let Ok(workspace) = std::env::var("WORKSPACE_ROOT") {
-        let workspace_canonical = PathBuf::from(&workspace).canonicalize().map_err(|e| {
-            Error::Compilation(format!("Invalid WORKSPACE_ROOT '{}': {}", workspace, e))
-        })?;
-
-        if !canonical.starts_with(&workspace_canonical) {
-            return Err(Error::Compilation(format!(
-                "Security: Path outside workspace. Path: '{}', Workspace: '{}'",
-                canonical.display(),
-                workspace_canonical.display()
-            )));
-        }
-    }
+    // If workspace roots are configured, ensure path is within one of them
+    check_within_workspace(&canonical, "Path")?;
 
     // Validate file extension if specified
     if let Some(expected_ext) = expected_extension {
@@ -131,17 +119,40 @@ pub fn validate_directory_path(path_str: &str) -> Result<PathBuf> {
         )));
     }
 
-    // If WORKSPACE_ROOT is set, ensure within workspace
-    if let Ok(workspace) = std::env::var("WORKSPACE_ROOT") {
-        let workspace_canonical = PathBuf::from(&workspace).canonicalize().map_err(|e| {
-            Error::Compilation(format!("Invalid WORKSPACE_ROOT '{}': {}", workspace, e))
-        })?;
+    // If workspace roots are configured, ensure within one of them
+    check_within_workspace(&canonical, "Directory")?;
 
-        if !canonical.starts_with(&workspace_canonical) {
+    Ok(canonical)
+}
+
+/// Validates the path of a pre-built binary to run under the debugger
+///
+/// Applies the checks of `validate_source_path` (without an extension) and
+/// additionally requires a regular file that is executable (on Unix, any
+/// execute permission bit).
+pub fn validate_executable_path(path_str: &str) -> Result<PathBuf> {
+    let canonical = validate_source_path(path_str, None)?;
+
+    let metadata = std::fs::metadata(&canonical).map_err(|e| {
+        Error::Compilation(format!(
+            "Invalid or inaccessible binary '{}': {}",
+            path_str, e
+        ))
+    })?;
+    if !metadata.is_file() {
+        return Err(Error::Compilation(format!(
+            "Not a file: '{}'",
+            canonical.display()
+        )));
+    }
+
+    #[cfg(unix)]
+    {
+        use std::os::unix::fs::PermissionsExt;
+        if metadata.permissions().mode() & 0o111 == 0 {
             return Err(Error::Compilation(format!(
-                "Security: Directory outside workspace. Path: '{}', Workspace: '{}'",
-                canonical.display(),
-                workspace_canonical.display()
+                "Not executable: '{}'",
+                canonical.display()
             )));
         }
     }
@@ -149,6 +160,170 @@ pub fn validate_directory_path(path_str: &str) -> Result<PathBuf> {
     Ok(canonical)
 }
 
+/// Environment variable operators can set to disable attaching to processes
+pub const DISABLE_ATTACH_ENV: &str = "DEBUGGER_MCP_DISABLE_ATTACH";
+
+/// Ensures attaching to running processes hasn't been disabled by the operator
+///
+/// Attach is disabled when `DEBUGGER_MCP_DISABLE_ATTACH` is set to anything
+/// other than `0`/`false`/empty.
+pub fn validate_attach_allowed() -> Result<()> {
+    attach_allowed(std::env::var(DISABLE_ATTACH_ENV).ok().as_deref())
+}
+
+/// `validate_attach_allowed` for a `DEBUGGER_MCP_DISABLE_ATTACH` of `setting`
+fn attach_allowed(setting: Option<&str>) -> Result<()> {
+    let disabled =
+        setting.is_some_and(|v| !matches!(v.trim().to_lowercase().as_str(), "" | "0" | "false"));
+
+    if disabled {
+        return Err(Error::InvalidRequest(format!(
+            "Security: Attaching to processes is disabled ({} is set)",
+            DISABLE_ATTACH_ENV
+        )));
+    }
+
+    Ok(())
+}
+
+/// Ensures a JVM's JDWP agent on `host` may be attached to
+///
+/// A JVM reached over JDWP can't be checked against the workspace roots,
+/// so hosts must be listed in `security.jvm_attach_hosts`; without such a
+/// list only loopback is allowed, and only while no workspace roots are set.
+pub fn validate_jvm_attach_host(host: &str) -> Result<()> {
+    let security = config::current().effective().security;
+    jvm_host_allowed(
+        host,
+        &security.jvm_attach_hosts,
+        !security.workspace_roots.is_empty(),
+    )
+}
+
+fn jvm_host_allowed(host: &str, allowed: &[String], restricted: bool) -> Result<()> {
+    if allowed
+        .iter()
+        .any(|allowed| allowed.eq_ignore_ascii_case(host))
+    {
+        return Ok(());
+    }
+    let loopback = host.eq_ignore_ascii_case("localhost")
+        || host
+            .parse::<std::net::IpAddr>()
+            .is_ok_and(|ip| ip.is_loopback());
+    if allowed.is_empty() && loopback && !restricted {
+        return Ok(());
+    }
+    Err(Error::InvalidRequest(format!(
+        "Security: attaching to a JVM on '{}' is not allowed; list the host in security.jvm_attach_hosts of the server configuration",
+        host
+    )))
+}
+
+/// Checks whether a process may be attached to under the workspace restriction
+///
+/// When workspace roots are set, only processes whose working directory or
+/// executable lives inside one of them are allowed. Without workspace roots
+/// every process is allowed.
+pub fn is_process_in_workspace(pid: u32) -> bool {
+    let roots = workspace_roots();
+    if roots.is_empty() {
+        return true;
+    }
+
+    let roots: Vec<PathBuf> = roots
+        .iter()
+        .filter_map(|root| PathBuf::from(root).canonicalize().ok())
+        .collect();
+
+    let proc_dir = PathBuf::from(format!("/proc/{}", pid));
+    ["cwd", "exe"].iter().any(|link| {
+        std::fs::read_link(proc_dir.join(link))
+            .map(|target| roots.iter().any(|root| target.starts_with(root)))
+            .unwrap_or(false)
+    })
+}
+
+/// Directories user-supplied paths must live in
+///
+/// The server configuration's `security.workspace_roots` when set, otherwise
+/// the WORKSPACE_ROOT environment variable. Empty means unrestricted.
+pub fn workspace_roots() -> Vec<String> {
+    config::current().effective().security.workspace_roots
+}
+
+/// Fail unless `path_str` lies within the workspace roots, whether or not it
+/// exists
+///
+/// Checked before anything looks near the path (e.g. `path_case` listing
+/// its directories for a letter case correction), so nothing outside the
+/// roots is read or reported.
+pub fn validate_within_workspace(path_str: &str) -> Result<()> {
+    let path = Path::new(path_str);
+    if path.components().any(|c| c == Component::ParentDir) {
+        return Err(Error::Compilation(format!(
+            "Security: Path contains '..' component: {}",
+            path_str
+        )));
+    }
+    let roots = workspace_roots();
+    if roots.is_empty() {
+        return Ok(());
+    }
+    within_roots(
+        &canonical_prefix(&std::path::absolute(path)?),
+        &roots,
+        "Path",
+    )
+}
+
+/// `path` with its longest existing ancestor canonicalized
+fn canonical_prefix(path: &Path) -> PathBuf {
+    let mut missing = Vec::new();
+    let mut ancestor = path;
+    loop {
+        if let Ok(canonical) = ancestor.canonicalize() {
+            return canonical.join(missing.iter().rev().collect::<PathBuf>());
+        }
+        match (ancestor.parent(), ancestor.file_name()) {
+            (Some(parent), Some(name)) => {
+                missing.push(name);
+                ancestor = parent;
+            }
+            _ => return path.to_path_buf(),
+        }
+    }
+}
+
+/// Ensures a canonical path lies within one of the workspace roots, if any
+fn check_within_workspace(canonical: &Path, kind: &str) -> Result<()> {
+    within_roots(canonical, &workspace_roots(), kind)
+}
+
+fn within_roots(canonical: &Path, roots: &[String], kind: &str) -> Result<()> {
+    if roots.is_empty() {
+        return Ok(());
+    }
+
+    let mut workspaces = Vec::new();
+    for root in roots {
+        let workspace_canonical = PathBuf::from(root)
+            .canonicalize()
+            .map_err(|e| Error::Compilation(format!("Invalid workspace root '{}': {}", root, e)))?;
+        if canonical.starts_with(&workspace_canonical) {
+            return Ok(());
+        }
+        workspaces.push(workspace_canonical.display().to_string());
+    }
+
+    Err(Error::Compilation(format!(
+        "Security: {} outside workspace. Path: '{}', Workspace: '{}'",
+        kind,
+        canonical.display(),
+        workspaces.join("', '")
+    )))
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -196,6 +371,78 @@ mod tests {
         fs::remove_file(test_file).ok();
     }
 
+    #[test]
+    fn test_attach_allowed_respects_setting() {
+        assert!(attach_allowed(None).is_ok());
+        assert!(attach_allowed(Some("0")).is_ok());
+        assert!(attach_allowed(Some(" False ")).is_ok());
+
+        let result = attach_allowed(Some("1"));
+        assert!(result.unwrap_err().to_string().contains("disabled"));
+    }
+
+    #[test]
+    fn test_jvm_attach_hosts() {
+        assert!(jvm_host_allowed("127.0.0.1", &[], false).is_ok());
+        assert!(jvm_host_allowed("localhost", &[], false).is_ok());
+        assert!(jvm_host_allowed("::1", &[], false).is_ok());
+        assert!(jvm_host_allowed("10.0.0.5", &[], false).is_err());
+        // Workspace roots can't be checked for a JVM: only listed hosts
+        assert!(jvm_host_allowed("127.0.0.1", &[], true).is_err());
+
+        let allowed = vec!["jvm.internal".to_string()];
+        assert!(jvm_host_allowed("JVM.internal", &allowed, true).is_ok());
+        let err = jvm_host_allowed("127.0.0.1", &allowed, false).unwrap_err();
+        assert!(err.to_string().contains("jvm_attach_hosts"), "{}", err);
+    }
+
+    #[test]
+    fn test_within_any_workspace_root() {
+        let first = tempfile::tempdir().unwrap();
+        let second = tempfile::tempdir().unwrap();
+        let outside = tempfile::tempdir().unwrap();
+        let roots = vec![
+            first.path().display().to_string(),
+            second.path().display().to_string(),
+        ];
+
+        let inside = second.path().canonicalize().unwrap().join("app.py");
+        assert!(within_roots(&inside, &roots, "Path").is_ok());
+        assert!(within_roots(&inside, &[], "Path").is_ok());
+
+        let err = within_roots(&outside.path().canonicalize().unwrap(), &roots, "Directory")
+            .unwrap_err()
+            .to_string();
+        assert!(
+            err.contains("Security: Directory outside workspace"),
+            "{}",
+            err
+        );
+
+        let missing = vec!["/nonexistent/workspace".to_string()];
+        assert!(within_roots(&inside, &missing, "Path")
+            .unwrap_err()
+            .to_string()
+            .contains("Invalid workspace root"));
+    }
+
+    #[test]
+    fn test_canonical_prefix_of_missing_paths() {
+        let root = tempfile::tempdir().unwrap();
+        let canonical = root.path().canonicalize().unwrap();
+        let roots = vec![root.path().display().to_string()];
+
+        // A misspelled file inside a root is still inside it
+        let missing = root.path().join("src").join("Main.go");
+        let prefix = canonical_prefix(&missing);
+        assert_eq!(prefix, canonical.join("src").join("Main.go"));
+        assert!(within_roots(&prefix, &roots, "Path").is_ok());
+
+        let outside = canonical_prefix(Path::new("/nonexistent-dir/Main.go"));
+        assert_eq!(outside, Path::new("/nonexistent-dir/Main.go"));
+        assert!(within_roots(&outside, &roots, "Path").is_err());
+    }
+
     #[test]
     fn test_validate_directory_path_rejects_file() {
         // Create a temp file
@@ -209,4 +456,29 @@ mod tests {
 
         fs::remove_file(test_file).ok();
     }
+
+    #[test]
+    fn test_validate_executable_path() {
+        let dir = tempfile::tempdir().unwrap();
+        let binary = dir.path().join("app");
+        fs::write(&binary, "\x7fELF").unwrap();
+
+        assert!(validate_executable_path(dir.path().to_str().unwrap())
+            .unwrap_err()
+            .to_string()
+            .contains("Not a file"));
+
+        #[cfg(unix)]
+        {
+            use std::os::unix::fs::PermissionsExt;
+            let err = validate_executable_path(binary.to_str().unwrap()).unwrap_err();
+            assert!(err.to_string().contains("Not executable"));
+
+            fs::set_permissions(&binary, fs::Permissions::from_mode(0o755)).unwrap();
+        }
+        assert_eq!(
+            validate_executable_path(binary.to_str().unwrap()).unwrap(),
+            binary.canonicalize().unwrap()
+        );
+    }
 }
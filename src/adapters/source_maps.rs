@@ -0,0 +1,316 @@
+//! TypeScript through source maps (Node.js)
+//!
+//! vscode-js-debug runs the JavaScript tsc emitted and maps it back to the
+//! TypeScript with the compiler's source maps: with `sourceMaps` on and
+//! `outFiles` covering the compiled output, a breakpoint set in a `.ts` file
+//! binds to the generated line, even in a script that isn't loaded yet, and
+//! stack frames come back in `.ts` paths and lines. The launch configuration
+//! turns both on for the program's project (the nearest directory with a
+//! `tsconfig.json` or `package.json`).
+//!
+//! A `.ts` program is launched as the JavaScript compiled from it, found by
+//! the source map listing it. Mapping breaks in two ways, reported as a
+//! warning with the file's breakpoints:
+//!
+//! - no source map lists the file: compiled without `sourceMap`, or not
+//!   compiled at all, so its breakpoints never bind
+//! - the `.ts` file is newer than its JavaScript: edited and not recompiled,
+//!   so breakpoints map to the old code's lines
+
+use crate::{Error, Result};
+use std::path::{Component, Path, PathBuf};
+
+/// Extensions of TypeScript sources
+const TYPESCRIPT_EXTENSIONS: &[&str] = &["ts", "tsx", "mts", "cts"];
+
+/// Extensions of JavaScript a source map may be attached to
+const JAVASCRIPT_EXTENSIONS: &[&str] = &["js", "mjs", "cjs"];
+
+/// Files marking the root of a project
+const PROJECT_MARKERS: &[&str] = &["tsconfig.json", "package.json"];
+
+/// Output directories tsc projects commonly compile to, tried first
+const OUT_DIRS: &[&str] = &["dist", "out", "build", "lib"];
+
+/// JavaScript files read at most when searching a project for a source map
+const MAX_SCANNED_FILES: usize = 5000;
+
+/// Whether `path` is a TypeScript source
+pub fn is_typescript(path: &str) -> bool {
+    Path::new(path)
+        .extension()
+        .and_then(|ext| ext.to_str())
+        .is_some_and(|ext| TYPESCRIPT_EXTENSIONS.contains(&ext))
+}
+
+/// The project `path` belongs to: the nearest directory with a
+/// `tsconfig.json` or `package.json`, else the file's directory
+pub fn project_root(path: &Path) -> PathBuf {
+    let dir = path
+        .parent()
+        .filter(|dir| !dir.as_os_str().is_empty())
+        .unwrap_or(Path::new("."));
+    dir.ancestors()
+        .find(|dir| PROJECT_MARKERS.iter().any(|m| dir.join(m).is_file()))
+        .unwrap_or(dir)
+        .to_path_buf()
+}
+
+/// `outFiles` for a project: its JavaScript, without dependencies
+pub fn out_files(root: &Path) -> Vec<String> {
+    JAVASCRIPT_EXTENSIONS
+        .iter()
+        .map(|ext| format!("{}/**/*.{}", root.display(), ext))
+        .chain(["!**/node_modules/**".to_string()])
+        .collect()
+}
+
+/// The JavaScript compiled from `source`, searched for in its project
+pub fn find_generated(source: &Path) -> Option<PathBuf> {
+    let source = normalize(source);
+    let root = project_root(&source);
+    let lists_source = |js: &Path| map_sources(js).is_some_and(|sources| sources.contains(&source));
+    if let Some(js) = candidates(&source, &root)
+        .into_iter()
+        .find(|js| lists_source(js))
+    {
+        return Some(js);
+    }
+
+    let mut scanned = 0;
+    let mut dirs = vec![root];
+    while let Some(dir) = dirs.pop() {
+        let Ok(entries) = std::fs::read_dir(&dir) else {
+            continue;
+        };
+        for path in entries.flatten().map(|entry| entry.path()) {
+            let name = path.file_name().and_then(|n| n.to_str()).unwrap_or("");
+            if path.is_dir() {
+                if name != "node_modules" && !name.starts_with('.') {
+                    dirs.push(path);
+                }
+            } else if has_extension(&path, JAVASCRIPT_EXTENSIONS) {
+                scanned += 1;
+                if scanned > MAX_SCANNED_FILES {
+                    return None;
+                }
+                if lists_source(&path) {
+                    return Some(path);
+                }
+            }
+        }
+    }
+    None
+}
+
+/// The JavaScript to launch for a `.ts` program
+pub fn launch_program(program: &str) -> Result<String> {
+    let generated = find_generated(Path::new(program)).ok_or_else(|| {
+        Error::InvalidRequest(format!(
+            "No compiled JavaScript with a source map found for {} in {}. Compile it with source maps first (\"sourceMap\": true in tsconfig.json, or tsc --sourceMap), or pass the compiled .js as program",
+            program,
+            project_root(Path::new(program)).display()
+        ))
+    })?;
+    if let Some(warning) = stale_warning(Path::new(program), &generated) {
+        tracing::warn!("⚠️  {}", warning);
+    }
+    Ok(generated.to_string_lossy().into_owned())
+}
+
+/// Why breakpoints in the TypeScript `source` may not bind, or bind to
+/// stale lines; None when a current source map covers it
+pub fn breakpoint_warning(source: &Path) -> Option<String> {
+    match find_generated(source) {
+        Some(generated) => stale_warning(source, &generated),
+        None => Some(format!(
+            "No source map in {} lists {}: its breakpoints can't be mapped to running JavaScript and won't bind. Compile with \"sourceMap\": true in tsconfig.json and restart the session",
+            project_root(source).display(),
+            source.display()
+        )),
+    }
+}
+
+/// Warning for a source modified after the JavaScript compiled from it
+fn stale_warning(source: &Path, generated: &Path) -> Option<String> {
+    let modified = |path: &Path| std::fs::metadata(path).ok()?.modified().ok();
+    let since = modified(source)?
+        .duration_since(modified(generated)?)
+        .ok()?;
+    Some(format!(
+        "{} was modified {}s after {} was compiled from it: the source map describes the old code, so breakpoints may land on the wrong lines. Recompile and restart the session",
+        source.display(),
+        since.as_secs(),
+        generated.display()
+    ))
+}
+
+/// Where tsc commonly puts the JavaScript for `source`: next to it, or in
+/// an output directory mirroring the project (`src/` dropped)
+fn candidates(source: &Path, root: &Path) -> Vec<PathBuf> {
+    let mut candidates: Vec<PathBuf> = JAVASCRIPT_EXTENSIONS
+        .iter()
+        .map(|ext| source.with_extension(ext))
+        .collect();
+    if let Ok(relative) = source.strip_prefix(root) {
+        let relative = relative.strip_prefix("src").unwrap_or(relative);
+        for dir in OUT_DIRS {
+            for ext in JAVASCRIPT_EXTENSIONS {
+                candidates.push(root.join(dir).join(relative).with_extension(ext));
+            }
+        }
+    }
+    candidates.retain(|path| path.is_file());
+    candidates
+}
+
+/// Sources listed by the source map of the JavaScript file `js`, as
+/// absolute paths
+fn map_sources(js: &Path) -> Option<Vec<PathBuf>> {
+    let contents = std::fs::read_to_string(js).ok()?;
+    let url = contents
+        .lines()
+        .rev()
+        .find_map(|line| line.trim().strip_prefix("//# sourceMappingURL="))?
+        .trim();
+    let js_dir = js.parent()?;
+    let (map, map_dir) = match url.strip_prefix("data:") {
+        Some(data) => {
+            let (_, encoded) = data.split_once("base64,")?;
+            let bytes = base64_decode(encoded)?;
+            (String::from_utf8(bytes).ok()?, js_dir.to_path_buf())
+        }
+        None => {
+            let path = js_dir.join(url);
+            let dir = path.parent()?.to_path_buf();
+            (std::fs::read_to_string(&path).ok()?, dir)
+        }
+    };
+
+    let map: serde_json::Value = serde_json::from_str(&map).ok()?;
+    let source_root = map["sourceRoot"].as_str().unwrap_or("");
+    let base = map_dir.join(source_root);
+    Some(
+        map["sources"]
+            .as_array()?
+            .iter()
+            .filter_map(|source| source.as_str())
+            .map(|source| normalize(&base.join(source.trim_start_matches("file://"))))
+            .collect(),
+    )
+}
+
+fn has_extension(path: &Path, extensions: &[&str]) -> bool {
+    path.extension()
+        .and_then(|ext| ext.to_str())
+        .is_some_and(|ext| extensions.contains(&ext))
+}
+
+/// `path` without `.` and `..` components (the file need not exist)
+fn normalize(path: &Path) -> PathBuf {
+    let mut normalized = PathBuf::new();
+    for component in path.components() {
+        match component {
+            Component::CurDir => {}
+            Component::ParentDir => {
+                normalized.pop();
+            }
+            other => normalized.push(other),
+        }
+    }
+    normalized
+}
+
+/// Decode standard base64, the encoding of inline source maps
+fn base64_decode(encoded: &str) -> Option<Vec<u8>> {
+    let mut bytes = Vec::with_capacity(encoded.len() / 4 * 3);
+    let (mut bits, mut count) = (0u32, 0);
+    for c in encoded.bytes().take_while(|&c| c != b'=') {
+        let value = match c {
+            b'A'..=b'Z' => c - b'A',
+            b'a'..=b'z' => c - b'a' + 26,
+            b'0'..=b'9' => c - b'0' + 52,
+            b'+' => 62,
+            b'/' => 63,
+            _ => return None,
+        };
+        bits = (bits << 6) | value as u32;
+        count += 6;
+        if count >= 8 {
+            count -= 8;
+            bytes.push((bits >> count) as u8);
+        }
+    }
+    Some(bytes)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn write(path: &Path, contents: &str) {
+        std::fs::create_dir_all(path.parent().unwrap()).unwrap();
+        std::fs::write(path, contents).unwrap();
+    }
+
+    #[test]
+    fn test_generated_file_found_through_its_map() {
+        let dir = tempfile::tempdir().unwrap();
+        let root = dir.path().canonicalize().unwrap();
+        write(&root.join("tsconfig.json"), "{}");
+        let source = root.join("src/app.ts");
+        write(&source, "const x: number = 1;\n");
+        write(
+            &root.join("dist/app.js"),
+            "const x = 1;\n//# sourceMappingURL=app.js.map\n",
+        );
+        write(
+            &root.join("dist/app.js.map"),
+            r#"{"version":3,"sources":["../src/app.ts"],"mappings":"AAAA"}"#,
+        );
+
+        assert_eq!(project_root(&source), root);
+        assert_eq!(find_generated(&source), Some(root.join("dist/app.js")));
+
+        let other = root.join("src/other.ts");
+        write(&other, "export {};\n");
+        let warning = breakpoint_warning(&other).unwrap();
+        assert!(warning.contains("No source map"), "{}", warning);
+        assert!(launch_program(&other.to_string_lossy()).is_err());
+    }
+
+    #[test]
+    fn test_inline_map_outside_the_usual_directories() {
+        let dir = tempfile::tempdir().unwrap();
+        let root = dir.path().canonicalize().unwrap();
+        write(&root.join("package.json"), "{}");
+        let source = root.join("lib/util.ts");
+        write(&source, "export {};\n");
+        // {"version":3,"sourceRoot":"../lib","sources":["util.ts"]}
+        let map = "eyJ2ZXJzaW9uIjozLCJzb3VyY2VSb290IjoiLi4vbGliIiwic291cmNlcyI6WyJ1dGlsLnRzIl19";
+        write(
+            &root.join("compiled/util.js"),
+            &format!(
+                "exports.x = 1;\n//# sourceMappingURL=data:application/json;base64,{}\n",
+                map
+            ),
+        );
+        assert_eq!(find_generated(&source), Some(root.join("compiled/util.js")));
+    }
+
+    #[test]
+    fn test_typescript_extensions_and_out_files() {
+        assert!(is_typescript("/w/src/app.ts"));
+        assert!(is_typescript("/w/src/view.tsx"));
+        assert!(!is_typescript("/w/dist/app.js"));
+        assert_eq!(
+            out_files(Path::new("/w")),
+            vec![
+                "/w/**/*.js",
+                "/w/**/*.mjs",
+                "/w/**/*.cjs",
+                "!**/node_modules/**"
+            ]
+        );
+    }
+}
@@ -0,0 +1,212 @@
+//! Interpreters and toolchains chosen per session
+//!
+//! With several Python or Go installations side by side, PATH picks one for
+//! every session. `debugger_start` takes an explicit one instead:
+//!
+//! - `pythonPath` is the interpreter debugpy runs the program with (its
+//!   `python` launch field). The path is kept as given, not resolved: the
+//!   `python` of a virtualenv is a symlink, and only the link itself makes
+//!   the venv's packages importable.
+//! - `goPath` is the `go` binary Delve builds the program with. Delve runs
+//!   `go build` from its PATH, so it is started with the binary's directory
+//!   first on PATH and without `GOROOT`, which would override the toolchain's
+//!   own root.
+//!
+//! Either is checked to be an executable and asked for its version, which
+//! `debugger_start` reports back. A session keeps its toolchain for what it
+//! asks the toolchain later, such as Go's `GOROOT`.
+
+use crate::{Error, Result};
+use serde::Serialize;
+use std::path::{Component, Path, PathBuf};
+use std::time::Duration;
+use tokio::process::Command;
+
+/// How long asking a binary for its version, or its settings, may take
+const VERSION_TIMEOUT: Duration = Duration::from_secs(10);
+
+/// A validated interpreter or toolchain binary and its version
+#[derive(Debug, Clone, PartialEq, Serialize)]
+pub struct Toolchain {
+    pub path: PathBuf,
+    /// e.g. `3.11.4` or `1.22.1`
+    pub version: String,
+}
+
+/// Validate the interpreter at `path` and read its version
+pub async fn python(path: &str) -> Result<Toolchain> {
+    let path = check_executable("pythonPath", path)?;
+    let output = run(&path, &["--version"]).await?;
+    let version = parse_python_version(&output).ok_or_else(|| {
+        Error::InvalidRequest(format!(
+            "pythonPath '{}' is not a Python interpreter: --version printed '{}'",
+            path.display(),
+            output.trim()
+        ))
+    })?;
+    Ok(Toolchain { path, version })
+}
+
+/// Validate the `go` binary at `path` and read its version
+pub async fn go(path: &str) -> Result<Toolchain> {
+    let path = check_executable("goPath", path)?;
+    if path.file_name().and_then(|n| n.to_str()) != Some("go") {
+        return Err(Error::InvalidRequest(format!(
+            "goPath '{}' must be a binary named 'go', since Delve runs `go build` from PATH; for toolchains installed with golang.org/dl use the go binary under `<version> env GOROOT`/bin",
+            path.display()
+        )));
+    }
+    let output = run(&path, &["version"]).await?;
+    let version = parse_go_version(&output).ok_or_else(|| {
+        Error::InvalidRequest(format!(
+            "goPath '{}' is not a Go toolchain: version printed '{}'",
+            path.display(),
+            output.trim()
+        ))
+    })?;
+    Ok(Toolchain { path, version })
+}
+
+/// An absolute path to an executable file, kept as given
+///
+/// Toolchains live outside the workspace (e.g. /usr/local/go/bin/go), so
+/// the workspace roots don't apply.
+fn check_executable(option: &str, path: &str) -> Result<PathBuf> {
+    let path = Path::new(path);
+    if !path.is_absolute() || path.components().any(|c| c == Component::ParentDir) {
+        return Err(Error::InvalidRequest(format!(
+            "{} must be an absolute path without '..', got '{}'",
+            option,
+            path.display()
+        )));
+    }
+    let metadata = std::fs::metadata(path)
+        .map_err(|e| Error::InvalidRequest(format!("{} '{}': {}", option, path.display(), e)))?;
+    if !metadata.is_file() {
+        return Err(Error::InvalidRequest(format!(
+            "{} '{}' is not a file",
+            option,
+            path.display()
+        )));
+    }
+    #[cfg(unix)]
+    {
+        use std::os::unix::fs::PermissionsExt;
+        if metadata.permissions().mode() & 0o111 == 0 {
+            return Err(Error::InvalidRequest(format!(
+                "{} '{}' is not executable",
+                option,
+                path.display()
+            )));
+        }
+    }
+    Ok(path.to_path_buf())
+}
+
+/// `GOROOT` of the `go` binary `go` (a path, or `go` for the one on PATH)
+pub async fn go_root(go: &Path) -> Result<PathBuf> {
+    let output = output(go, &["env", "GOROOT"]).await?;
+    let root = String::from_utf8_lossy(&output.stdout).trim().to_string();
+    if !output.status.success() || root.is_empty() {
+        return Err(Error::Process(format!(
+            "{} env GOROOT failed: {}",
+            go.display(),
+            String::from_utf8_lossy(&output.stderr).trim()
+        )));
+    }
+    Ok(PathBuf::from(root))
+}
+
+/// Stdout and stderr of running `path` (Python 2 prints its version to stderr)
+async fn run(path: &Path, args: &[&str]) -> Result<String> {
+    let output = output(path, args).await?;
+    Ok(format!(
+        "{}{}",
+        String::from_utf8_lossy(&output.stdout),
+        String::from_utf8_lossy(&output.stderr)
+    ))
+}
+
+/// The output of running `path`; one still running after
+/// `VERSION_TIMEOUT` is killed
+async fn output(path: &Path, args: &[&str]) -> Result<std::process::Output> {
+    let output = Command::new(path).args(args).kill_on_drop(true).output();
+    tokio::time::timeout(VERSION_TIMEOUT, output)
+        .await
+        .map_err(|_| {
+            Error::Process(format!(
+                "{} {} did not finish within {:?}",
+                path.display(),
+                args.join(" "),
+                VERSION_TIMEOUT
+            ))
+        })?
+        .map_err(|e| Error::Process(format!("Failed to run {}: {}", path.display(), e)))
+}
+
+/// `3.11.4` from `Python 3.11.4`
+fn parse_python_version(output: &str) -> Option<String> {
+    let version = output.trim().strip_prefix("Python ")?;
+    version
+        .starts_with(|c: char| c.is_ascii_digit())
+        .then(|| version.to_string())
+}
+
+/// `1.22.1` from `go version go1.22.1 linux/amd64`
+fn parse_go_version(output: &str) -> Option<String> {
+    let version = output
+        .strip_prefix("go version go")?
+        .split_whitespace()
+        .next()?;
+    Some(version.to_string())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parse_versions() {
+        assert_eq!(
+            parse_python_version("Python 3.11.4\n").as_deref(),
+            Some("3.11.4")
+        );
+        assert_eq!(parse_python_version("Ruby 3.2"), None);
+        assert_eq!(
+            parse_go_version("go version go1.22.1 linux/amd64\n").as_deref(),
+            Some("1.22.1")
+        );
+        assert_eq!(parse_go_version("dlv version 1.24"), None);
+    }
+
+    #[tokio::test]
+    async fn test_paths_are_checked() {
+        let dir = tempfile::tempdir().unwrap();
+        let script = dir.path().join("go");
+        std::fs::write(
+            &script,
+            "#!/bin/sh\necho go version go1.21.13 linux/amd64\n",
+        )
+        .unwrap();
+
+        let err = go("bin/go").await.unwrap_err();
+        assert!(err.to_string().contains("absolute path"), "{}", err);
+        let err = go(script.to_str().unwrap()).await.unwrap_err();
+        assert!(err.to_string().contains("not executable"), "{}", err);
+
+        #[cfg(unix)]
+        {
+            use std::os::unix::fs::PermissionsExt;
+            std::fs::set_permissions(&script, std::fs::Permissions::from_mode(0o755)).unwrap();
+            let toolchain = go(script.to_str().unwrap()).await.unwrap();
+            assert_eq!(toolchain.version, "1.21.13");
+
+            let renamed = dir.path().join("go1.21.13");
+            std::fs::copy(&script, &renamed).unwrap();
+            let err = go(renamed.to_str().unwrap()).await.unwrap_err();
+            assert!(err.to_string().contains("named 'go'"), "{}", err);
+            let err = python(script.to_str().unwrap()).await.unwrap_err();
+            assert!(err.to_string().contains("not a Python"), "{}", err);
+        }
+    }
+}
@@ -0,0 +1,596 @@
+//! Server configuration file
+//!
+//! `serve` reads an optional configuration file with operator defaults, given
+//! by `--config <path>` or the `DEBUGGER_MCP_CONFIG` environment variable.
+//! Files ending in `.json` are parsed as JSON, anything else as TOML. Every
+//! field is optional, and per-call tool arguments still override these
+//! defaults:
+//!
+//! ```toml
+//! [timeouts]
+//! initialize_ms = 2000
+//! launch_ms = 5000
+//! initialized_ms = 5000
+//! disconnect_ms = 2000
+//! wait_for_stop_ms = 5000
+//! inspection_ms = 10000
+//!
+//! [sessions]
+//! max_sessions = 8
+//! idle_timeout_secs = 1800
+//! reap_orphaned_adapters = true
+//!
+//! [security]
+//! workspace_roots = ["/workspace"]
+//! allow_target_exec_prefix = false
+//! jvm_attach_hosts = ["127.0.0.1"]
+//!
+//! [adapters.go]
+//! path = "/opt/go/bin/dlv"
+//! version = "1.23.1"
+//!
+//! [python]
+//! just_my_code = true
+//!
+//! [logging]
+//! level = "debug"
+//! format = "json"
+//!
+//! [redaction]
+//! patterns = ["(?i)bearer [a-z0-9._-]+"]
+//!
+//! [limits]
+//! max_response_bytes = 262144
+//! spill_dir = "/var/tmp/debugger-mcp-responses"
+//!
+//! [webhooks]
+//! allowed_urls = ["https://ci.example.com/hooks/"]
+//! default_url = "https://ci.example.com/hooks/debugger"
+//! ```
+//!
+//! Unknown fields and invalid values fail startup with an error naming the
+//! field. The loaded configuration is installed once with `init` and read
+//! everywhere else through `current`.
+
+use crate::{Error, Result};
+use regex::Regex;
+use serde::{Deserialize, Serialize};
+use std::collections::BTreeMap;
+use std::path::{Path, PathBuf};
+use std::sync::OnceLock;
+
+/// Environment variable naming the configuration file (overridden by `--config`)
+pub const CONFIG_ENV: &str = "DEBUGGER_MCP_CONFIG";
+
+/// Languages that accept an `[adapters.<language>]` section
+pub const ADAPTER_LANGUAGES: &[&str] = &["python", "ruby", "go", "nodejs", "rust", "java"];
+
+/// Environment variable overriding `limits.max_response_bytes`
+pub const MAX_RESPONSE_BYTES_ENV: &str = "DEBUGGER_MCP_MAX_RESPONSE_BYTES";
+
+/// Smallest accepted response size cap
+pub const MIN_RESPONSE_BYTES: usize = 1024;
+
+/// Replacement for values matched by a redaction pattern
+pub const REDACTED: &str = "[REDACTED]";
+
+const LOG_LEVELS: &[&str] = &["trace", "debug", "info", "warn", "error"];
+const LOG_FORMATS: &[&str] = &["text", "json"];
+
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct ServerConfig {
+    pub timeouts: TimeoutConfig,
+    pub sessions: SessionConfig,
+    pub security: SecurityConfig,
+    /// Adapter binaries per language (see `ADAPTER_LANGUAGES`)
+    pub adapters: BTreeMap<String, AdapterConfig>,
+    pub python: PythonConfig,
+    pub logging: LoggingConfig,
+    pub redaction: RedactionConfig,
+    pub limits: LimitsConfig,
+    pub webhooks: WebhookConfig,
+}
+
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct TimeoutConfig {
+    /// DAP initialize request
+    pub initialize_ms: u64,
+    /// DAP launch request
+    pub launch_ms: u64,
+    /// Wait for the adapter's `initialized` event during launch or attach
+    pub initialized_ms: u64,
+    /// DAP disconnect request
+    pub disconnect_ms: u64,
+    /// Default for debugger_wait_for_stop's timeoutMs
+    pub wait_for_stop_ms: u64,
+    /// Deadline of each inspection request (variables, evaluate,
+    /// stackTrace, ...); a slower one fails alone (see `dap::request_queue`)
+    pub inspection_ms: u64,
+}
+
+impl Default for TimeoutConfig {
+    fn default() -> Self {
+        Self {
+            initialize_ms: 2000,
+            launch_ms: 5000,
+            initialized_ms: 5000,
+            disconnect_ms: 2000,
+            wait_for_stop_ms: 5000,
+            inspection_ms: 10_000,
+        }
+    }
+}
+
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct SessionConfig {
+    /// Maximum number of concurrent sessions (unlimited when unset)
+    pub max_sessions: Option<usize>,
+    /// Sessions without tool activity for this long are disconnected
+    pub idle_timeout_secs: Option<u64>,
+    /// Kill adapters left running by a previous server on startup (they are
+    /// only logged otherwise)
+    pub reap_orphaned_adapters: bool,
+}
+
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct SecurityConfig {
+    /// Directories programs, sources and attach targets must live in;
+    /// replaces the WORKSPACE_ROOT environment variable when set
+    pub workspace_roots: Vec<String>,
+    /// Let `debugger_start` run adapters through `targetExecPrefix` (see
+    /// `adapters::exec_prefix`); off by default since the prefix is an
+    /// arbitrary command
+    pub allow_target_exec_prefix: bool,
+    /// Hosts `debugger_attach` may reach a JVM's JDWP agent on; empty means
+    /// loopback only, and only while no workspace roots are set
+    pub jvm_attach_hosts: Vec<String>,
+}
+
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct AdapterConfig {
+    /// Adapter executable (for nodejs: vscode-js-debug's dapDebugServer.js)
+    pub path: Option<String>,
+    /// Installed adapter version; informational, shown by debugger_get_config
+    pub version: Option<String>,
+}
+
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct PythonConfig {
+    /// debugpy's justMyCode for launches that don't set it
+    pub just_my_code: Option<bool>,
+}
+
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct LoggingConfig {
+    /// Log level when neither --verbose, --log-level nor RUST_LOG is given
+    pub level: Option<String>,
+    /// "text" (default) or "json"
+    pub format: Option<String>,
+}
+
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct RedactionConfig {
+    /// Regular expressions; matches in evaluated values are replaced by `REDACTED`
+    pub patterns: Vec<String>,
+}
+
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct LimitsConfig {
+    /// Tool responses larger than this (pretty-printed JSON) are truncated
+    pub max_response_bytes: usize,
+    /// Directory the full copies of truncated responses are written to
+    /// (`debugger-mcp-responses-<uid>` in the temp dir when unset); created
+    /// 0700, and not written to unless it is the user's and closed to others
+    pub spill_dir: Option<String>,
+}
+
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct WebhookConfig {
+    /// URL prefixes sessions may POST events to; webhooks are off when empty
+    pub allowed_urls: Vec<String>,
+    /// Webhook of sessions started without `webhookUrl`
+    pub default_url: Option<String>,
+}
+
+impl Default for LimitsConfig {
+    fn default() -> Self {
+        Self {
+            max_response_bytes: 256 * 1024,
+            spill_dir: None,
+        }
+    }
+}
+
+impl ServerConfig {
+    /// Read and validate a configuration file
+    pub fn load(path: &Path) -> Result<Self> {
+        let text = std::fs::read_to_string(path)
+            .map_err(|e| Error::Config(format!("Cannot read '{}': {}", path.display(), e)))?;
+        Self::parse(&text, path.extension().is_some_and(|ext| ext == "json"))
+            .map_err(|e| Error::Config(format!("{}: {}", path.display(), e)))
+    }
+
+    /// Parse and validate configuration text (TOML unless `json`)
+    pub fn parse(text: &str, json: bool) -> Result<Self> {
+        let config: Self = if json {
+            serde_json::from_str(text).map_err(|e| Error::Config(e.to_string()))?
+        } else {
+            toml::from_str(text).map_err(|e| Error::Config(e.to_string()))?
+        };
+        config.validate()?;
+        Ok(config)
+    }
+
+    /// Check values serde can't, reporting every invalid field
+    pub fn validate(&self) -> Result<()> {
+        let mut problems = Vec::new();
+
+        for (field, value) in [
+            ("timeouts.initialize_ms", self.timeouts.initialize_ms),
+            ("timeouts.launch_ms", self.timeouts.launch_ms),
+            ("timeouts.initialized_ms", self.timeouts.initialized_ms),
+            ("timeouts.disconnect_ms", self.timeouts.disconnect_ms),
+            ("timeouts.wait_for_stop_ms", self.timeouts.wait_for_stop_ms),
+            ("timeouts.inspection_ms", self.timeouts.inspection_ms),
+        ] {
+            if value == 0 {
+                problems.push(format!("{}: must be greater than 0", field));
+            }
+        }
+        if self.sessions.max_sessions == Some(0) {
+            problems.push("sessions.max_sessions: must be greater than 0".to_string());
+        }
+        if self.sessions.idle_timeout_secs == Some(0) {
+            problems.push("sessions.idle_timeout_secs: must be greater than 0".to_string());
+        }
+
+        if self.limits.max_response_bytes < MIN_RESPONSE_BYTES {
+            problems.push(format!(
+                "limits.max_response_bytes: must be at least {}",
+                MIN_RESPONSE_BYTES
+            ));
+        }
+        if let Some(dir) = &self.limits.spill_dir {
+            if !Path::new(dir).is_absolute() {
+                problems.push(format!(
+                    "limits.spill_dir: '{}' is not an absolute path",
+                    dir
+                ));
+            }
+        }
+
+        for (i, root) in self.security.workspace_roots.iter().enumerate() {
+            let field = format!("security.workspace_roots[{}]", i);
+            if !Path::new(root).is_absolute() {
+                problems.push(format!("{}: '{}' is not an absolute path", field, root));
+            } else if !Path::new(root).is_dir() {
+                problems.push(format!("{}: '{}' is not a directory", field, root));
+            }
+        }
+
+        for (language, adapter) in &self.adapters {
+            if !ADAPTER_LANGUAGES.contains(&language.as_str()) {
+                problems.push(format!(
+                    "adapters.{}: unknown language (expected one of {})",
+                    language,
+                    ADAPTER_LANGUAGES.join(", ")
+                ));
+            }
+            if adapter.path.as_deref() == Some("") {
+                problems.push(format!("adapters.{}.path: must not be empty", language));
+            }
+        }
+
+        if let Some(level) = &self.logging.level {
+            if !LOG_LEVELS.contains(&level.as_str()) {
+                problems.push(format!(
+                    "logging.level: '{}' is not one of {}",
+                    level,
+                    LOG_LEVELS.join(", ")
+                ));
+            }
+        }
+        if let Some(format) = &self.logging.format {
+            if !LOG_FORMATS.contains(&format.as_str()) {
+                problems.push(format!(
+                    "logging.format: '{}' is not one of {}",
+                    format,
+                    LOG_FORMATS.join(", ")
+                ));
+            }
+        }
+
+        for (i, url) in self.webhooks.allowed_urls.iter().enumerate() {
+            if let Err(problem) = crate::debug::webhook::check_http_url(url) {
+                problems.push(format!(
+                    "webhooks.allowed_urls[{}]: '{}' {}",
+                    i, url, problem
+                ));
+            }
+        }
+        if let Some(url) = &self.webhooks.default_url {
+            if let Err(e) = crate::debug::webhook::validate_url(url, &self.webhooks.allowed_urls) {
+                problems.push(format!("webhooks.default_url: {}", e));
+            }
+        }
+
+        for (i, pattern) in self.redaction.patterns.iter().enumerate() {
+            if let Err(e) = Regex::new(pattern) {
+                problems.push(format!("redaction.patterns[{}]: {}", i, e));
+            }
+        }
+
+        if problems.is_empty() {
+            Ok(())
+        } else {
+            Err(Error::Config(problems.join("; ")))
+        }
+    }
+
+    /// Configured adapter executable for a language
+    pub fn adapter_path(&self, language: &str) -> Option<&str> {
+        self.adapters.get(language)?.path.as_deref()
+    }
+
+    /// The configuration with environment fallbacks filled in, as applied
+    pub fn effective(&self) -> Self {
+        let mut effective = self.clone();
+        if effective.security.workspace_roots.is_empty() {
+            if let Ok(root) = std::env::var("WORKSPACE_ROOT") {
+                effective.security.workspace_roots.push(root);
+            }
+        }
+        if let Some(max) = env_max_response_bytes() {
+            effective.limits.max_response_bytes = max;
+        }
+        effective
+    }
+}
+
+/// The installed configuration
+struct Loaded {
+    source: Option<PathBuf>,
+    config: ServerConfig,
+    redactions: Vec<Regex>,
+}
+
+static LOADED: OnceLock<Loaded> = OnceLock::new();
+static DEFAULT: OnceLock<ServerConfig> = OnceLock::new();
+
+/// Path of the configuration file: `--config`, else `DEBUGGER_MCP_CONFIG`
+pub fn resolve_path(cli: Option<PathBuf>) -> Option<PathBuf> {
+    cli.or_else(|| {
+        std::env::var_os(CONFIG_ENV)
+            .filter(|p| !p.is_empty())
+            .map(PathBuf::from)
+    })
+}
+
+/// Install the server configuration; fails if one is already installed
+pub fn init(config: ServerConfig, source: Option<PathBuf>) -> Result<()> {
+    config.validate()?;
+    let redactions = config
+        .redaction
+        .patterns
+        .iter()
+        .filter_map(|p| Regex::new(p).ok())
+        .collect();
+
+    LOADED
+        .set(Loaded {
+            source,
+            config,
+            redactions,
+        })
+        .map_err(|_| Error::Internal("Configuration already initialized".to_string()))
+}
+
+/// The installed configuration, or the defaults if none was installed
+pub fn current() -> &'static ServerConfig {
+    match LOADED.get() {
+        Some(loaded) => &loaded.config,
+        None => DEFAULT.get_or_init(ServerConfig::default),
+    }
+}
+
+/// File the installed configuration was read from
+pub fn source() -> Option<&'static Path> {
+    LOADED.get()?.source.as_deref()
+}
+
+/// Cap on tool response size: `DEBUGGER_MCP_MAX_RESPONSE_BYTES`, else
+/// `limits.max_response_bytes`
+pub fn max_response_bytes() -> usize {
+    env_max_response_bytes().unwrap_or(current().limits.max_response_bytes)
+}
+
+/// Directory full copies of truncated responses are written to
+pub fn spill_dir() -> PathBuf {
+    match &current().limits.spill_dir {
+        Some(dir) => PathBuf::from(dir),
+        None => std::env::temp_dir().join(format!(
+            "debugger-mcp-responses-{}",
+            crate::process::private_dir::current_uid().unwrap_or_default()
+        )),
+    }
+}
+
+/// Valid value of `MAX_RESPONSE_BYTES_ENV`; invalid values are ignored,
+/// with a warning the first time
+fn env_max_response_bytes() -> Option<usize> {
+    static WARNED: OnceLock<()> = OnceLock::new();
+    let value = std::env::var(MAX_RESPONSE_BYTES_ENV).ok()?;
+    match value.trim().parse::<usize>() {
+        Ok(max) if max >= MIN_RESPONSE_BYTES => Some(max),
+        _ => {
+            if WARNED.set(()).is_ok() {
+                tracing::warn!(
+                    "Ignoring {}={:?}: expected a number of bytes >= {}",
+                    MAX_RESPONSE_BYTES_ENV,
+                    value,
+                    MIN_RESPONSE_BYTES
+                );
+            }
+            None
+        }
+    }
+}
+
+/// Apply the configured redaction patterns to a value
+pub fn redact(value: &str) -> String {
+    let patterns = LOADED.get().map(|l| l.redactions.as_slice()).unwrap_or(&[]);
+    redact_with(patterns, value)
+}
+
+fn redact_with(patterns: &[Regex], value: &str) -> String {
+    patterns.iter().fold(value.to_string(), |value, pattern| {
+        pattern.replace_all(&value, REDACTED).into_owned()
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_empty_config_is_default() {
+        let config = ServerConfig::parse("", false).unwrap();
+        assert_eq!(config, ServerConfig::default());
+        assert_eq!(config.timeouts.launch_ms, 5000);
+        assert_eq!(
+            ServerConfig::parse("{}", true).unwrap(),
+            ServerConfig::default()
+        );
+    }
+
+    #[test]
+    fn test_parse_toml() {
+        let dir = tempfile::tempdir().unwrap();
+        let text = format!(
+            r#"
+            [timeouts]
+            launch_ms = 9000
+
+            [sessions]
+            max_sessions = 4
+
+            [security]
+            workspace_roots = ["{}"]
+            allow_target_exec_prefix = true
+            jvm_attach_hosts = ["jvm.internal"]
+
+            [adapters.go]
+            path = "/opt/dlv"
+            version = "1.23.1"
+
+            [python]
+            just_my_code = true
+            "#,
+            dir.path().display()
+        );
+
+        let config = ServerConfig::parse(&text, false).unwrap();
+        assert_eq!(config.timeouts.launch_ms, 9000);
+        assert_eq!(config.timeouts.initialize_ms, 2000);
+        assert_eq!(config.sessions.max_sessions, Some(4));
+        assert!(config.security.allow_target_exec_prefix);
+        assert_eq!(config.security.jvm_attach_hosts, vec!["jvm.internal"]);
+        assert_eq!(config.adapter_path("go"), Some("/opt/dlv"));
+        assert_eq!(config.adapter_path("python"), None);
+        assert_eq!(config.python.just_my_code, Some(true));
+    }
+
+    #[test]
+    fn test_unknown_field_is_named() {
+        let err = ServerConfig::parse("[timeouts]\nlaunch_secs = 5\n", false).unwrap_err();
+        assert!(matches!(err, Error::Config(_)));
+        assert!(err.to_string().contains("launch_secs"), "{}", err);
+
+        let err = ServerConfig::parse(r#"{"sessions": {"max": 2}}"#, true).unwrap_err();
+        assert!(err.to_string().contains("max"), "{}", err);
+    }
+
+    #[test]
+    fn test_validation_reports_every_field() {
+        let text = r#"
+            [timeouts]
+            launch_ms = 0
+
+            [security]
+            workspace_roots = ["relative/dir"]
+
+            [adapters.cobol]
+            path = "/usr/bin/cobdbg"
+
+            [logging]
+            format = "xml"
+
+            [limits]
+            max_response_bytes = 10
+            spill_dir = "spill"
+
+            [redaction]
+            patterns = ["ok", "(unclosed"]
+
+            [webhooks]
+            allowed_urls = ["ftp://ci.example.com"]
+            default_url = "https://ci.example.com/hook"
+        "#;
+        let message = ServerConfig::parse(text, false).unwrap_err().to_string();
+        for field in [
+            "timeouts.launch_ms",
+            "security.workspace_roots[0]",
+            "adapters.cobol",
+            "logging.format",
+            "redaction.patterns[1]",
+            "limits.max_response_bytes",
+            "limits.spill_dir",
+            "webhooks.allowed_urls[0]",
+            "webhooks.default_url",
+        ] {
+            assert!(message.contains(field), "missing {} in: {}", field, message);
+        }
+    }
+
+    #[test]
+    fn test_load_json_file() {
+        let dir = tempfile::tempdir().unwrap();
+        let path = dir.path().join("debugger-mcp.json");
+        std::fs::write(&path, r#"{"logging": {"level": "debug"}}"#).unwrap();
+
+        let config = ServerConfig::load(&path).unwrap();
+        assert_eq!(config.logging.level.as_deref(), Some("debug"));
+
+        let err = ServerConfig::load(&dir.path().join("missing.toml")).unwrap_err();
+        assert!(err.to_string().contains("missing.toml"));
+    }
+
+    #[test]
+    fn test_redact() {
+        let patterns = vec![
+            Regex::new("(?i)bearer [a-z0-9]+").unwrap(),
+            Regex::new("sk_live_\\w+").unwrap(),
+        ];
+        assert_eq!(
+            redact_with(&patterns, "'Bearer abc123' / sk_live_42"),
+            "'[REDACTED]' / [REDACTED]"
+        );
+        assert_eq!(redact_with(&[], "plain"), "plain");
+    }
+
+    #[test]
+    fn test_resolve_path_prefers_cli() {
+        let cli = PathBuf::from("/etc/debugger-mcp.toml");
+        assert_eq!(resolve_path(Some(cli.clone())), Some(cli));
+    }
+}
@@ -0,0 +1,537 @@
+//! Capability pre-checks for DAP requests
+//!
+//! Adapters answer requests they don't support with errors worded in their
+//! own way, sometimes only after a timeout. Instead, every request is checked
+//! before it's sent against the capabilities the adapter reported in its
+//! `initialize` response, corrected by `SUPPORT_OVERRIDES` where an adapter's
+//! advertisement doesn't match what it actually does. Unsupported requests fail
+//! with a uniform `Error::UnsupportedCapability` naming an alternative when
+//! there is one.
+//!
+//! Requests not listed in `REQUEST_CAPABILITIES` are always allowed, unless
+//! they use a breakpoint field or argument that needs a capability of its own
+//! (`exceptionOptions` of `setExceptionBreakpoints`).
+//! `configurationDone` is deliberately absent: it's part of the launch
+//! handshake, which already skips it when unsupported.
+//!
+//! `report` lists every boolean capability of `Capabilities`, gating or
+//! not (`OTHER_CAPABILITIES`), so one snapshot tells an agent everything the
+//! adapter can do; a capability added to `Capabilities` must be added to one
+//! of the lists (`test_report_covers_every_capability` checks).
+
+use super::types::Capabilities;
+use crate::{Error, Result};
+use serde_json::Value;
+use std::collections::BTreeMap;
+
+/// DAP requests that may only be sent when the adapter has the capability
+pub const REQUEST_CAPABILITIES: &[(&str, &str)] = &[
+    ("restart", "supportsRestartRequest"),
+    ("terminate", "supportsTerminateRequest"),
+    ("setFunctionBreakpoints", "supportsFunctionBreakpoints"),
+    ("dataBreakpointInfo", "supportsDataBreakpoints"),
+    ("setDataBreakpoints", "supportsDataBreakpoints"),
+    ("setVariable", "supportsSetVariable"),
+    ("setExpression", "supportsSetExpression"),
+    ("restartFrame", "supportsRestartFrame"),
+    ("stepInTargets", "supportsStepInTargetsRequest"),
+    ("gotoTargets", "supportsGotoTargetsRequest"),
+    ("exceptionInfo", "supportsExceptionInfoRequest"),
+    ("completions", "supportsCompletionsRequest"),
+    ("stepBack", "supportsStepBack"),
+    ("reverseContinue", "supportsStepBack"),
+    (
+        "setInstructionBreakpoints",
+        "supportsInstructionBreakpoints",
+    ),
+    ("disassemble", "supportsDisassembleRequest"),
+    ("breakpointLocations", "supportsBreakpointLocationsRequest"),
+    ("cancel", "supportsCancelRequest"),
+    ("goto", "supportsGotoTargetsRequest"),
+    ("modules", "supportsModulesRequest"),
+    ("loadedSources", "supportsLoadedSourcesRequest"),
+    ("readMemory", "supportsReadMemoryRequest"),
+    ("writeMemory", "supportsWriteMemoryRequest"),
+    ("terminateThreads", "supportsTerminateThreadsRequest"),
+];
+
+/// Breakpoint fields of `setBreakpoints`, `setFunctionBreakpoints` and
+/// `setInstructionBreakpoints` that need a capability (only source
+/// breakpoints have a `logMessage`)
+const BREAKPOINT_FIELD_CAPABILITIES: &[(&str, &str)] = &[
+    ("condition", "supportsConditionalBreakpoints"),
+    ("hitCondition", "supportsHitConditionalBreakpoints"),
+    ("logMessage", "supportsLogPoints"),
+];
+
+/// Request arguments that need a capability: (request, argument, capability)
+const ARGUMENT_CAPABILITIES: &[(&str, &str, &str)] = &[
+    (
+        "setExceptionBreakpoints",
+        "exceptionOptions",
+        "supportsExceptionOptions",
+    ),
+    ("next", "granularity", "supportsSteppingGranularity"),
+    ("stepIn", "granularity", "supportsSteppingGranularity"),
+    ("stepOut", "granularity", "supportsSteppingGranularity"),
+];
+
+/// Capabilities that don't gate a request, argument or breakpoint field but
+/// are reported all the same
+const OTHER_CAPABILITIES: &[&str] = &[
+    "supportsConfigurationDoneRequest",
+    "supportsEvaluateForHovers",
+    "supportsDelayedStackTraceLoading",
+    "supportsValueFormattingOptions",
+    "supportsExceptionFilterOptions",
+    "supportTerminateDebuggee",
+    "supportSuspendDebuggee",
+    "supportsSingleThreadExecutionRequests",
+];
+
+/// Capabilities whose advertised value doesn't match reality: (adapter, capability, supported)
+pub const SUPPORT_OVERRIDES: &[(&str, &str, bool)] = &[
+    // rdbg advertises step back, but it only works while execution is being
+    // recorded (`record on`), which this server never enables
+    ("rdbg", "supportsStepBack", false),
+];
+
+/// What to do instead: (adapter or "*" for any, capability, suggestion)
+///
+/// Adapter-specific entries take precedence over "*".
+const SUGGESTIONS: &[(&str, &str, &str)] = &[
+    (
+        "rdbg",
+        "supportsDataBreakpoints",
+        "use a conditional breakpoint on the lines that modify the value; rdbg does not support data breakpoints",
+    ),
+    (
+        "*",
+        "supportsDataBreakpoints",
+        "use a logpoint or conditional breakpoint on the lines that modify the value",
+    ),
+    (
+        "*",
+        "supportsLogPoints",
+        "set the logpoint with debugger_set_breakpoint (logMessage), which emulates it with a breakpoint that logs and continues",
+    ),
+    (
+        "*",
+        "supportsConditionalBreakpoints",
+        "set a plain breakpoint and check the condition with debugger_evaluate",
+    ),
+    (
+        "*",
+        "supportsHitConditionalBreakpoints",
+        "use a plain breakpoint and continue past it the required number of times",
+    ),
+    (
+        "*",
+        "supportsFunctionBreakpoints",
+        "set a source breakpoint on the first line of the function's body with debugger_set_breakpoint",
+    ),
+    (
+        "*",
+        "supportsRestartRequest",
+        "disconnect and start a new session",
+    ),
+    (
+        "*",
+        "supportsTerminateRequest",
+        "use debugger_disconnect",
+    ),
+    (
+        "*",
+        "supportsInstructionBreakpoints",
+        "set a source or function breakpoint near the instruction and step with debugger_step_over",
+    ),
+    (
+        "*",
+        "supportsDisassembleRequest",
+        "disassemble the binary outside the debugger, e.g. with go tool objdump or objdump -d",
+    ),
+    (
+        "*",
+        "supportsStepBack",
+        "set a breakpoint earlier in the program and start a new session",
+    ),
+    (
+        "*",
+        "supportsSteppingGranularity",
+        "step without granularity, which steps by line",
+    ),
+    (
+        "*",
+        "supportsExceptionOptions",
+        "break on all exceptions with modes and continue past the ones whose class (exception in debugger_session_state) doesn't match",
+    ),
+];
+
+/// Capabilities a request needs, in the order they are checked
+pub fn required_capabilities(command: &str, arguments: Option<&Value>) -> Vec<&'static str> {
+    let mut required: Vec<&'static str> = REQUEST_CAPABILITIES
+        .iter()
+        .filter(|(request, _)| *request == command)
+        .map(|(_, capability)| *capability)
+        .collect();
+
+    if matches!(
+        command,
+        "setBreakpoints" | "setFunctionBreakpoints" | "setInstructionBreakpoints"
+    ) {
+        let breakpoints = arguments
+            .and_then(|args| args.get("breakpoints"))
+            .and_then(Value::as_array);
+        for (field, capability) in BREAKPOINT_FIELD_CAPABILITIES {
+            let used = breakpoints
+                .into_iter()
+                .flatten()
+                .any(|bp| bp.get(*field).is_some_and(|v| !v.is_null()));
+            if used {
+                required.push(capability);
+            }
+        }
+    }
+
+    for (request, argument, capability) in ARGUMENT_CAPABILITIES {
+        let used = arguments
+            .and_then(|args| args.get(*argument))
+            .is_some_and(|v| !v.is_null());
+        if *request == command && used {
+            required.push(capability);
+        }
+    }
+
+    required
+}
+
+/// Whether an adapter supports a capability, after applying overrides
+pub fn is_supported(adapter_id: &str, capabilities: &Capabilities, capability: &str) -> bool {
+    if let Some((_, _, supported)) = SUPPORT_OVERRIDES
+        .iter()
+        .find(|(adapter, name, _)| *adapter == adapter_id && *name == capability)
+    {
+        return *supported;
+    }
+
+    serde_json::to_value(capabilities)
+        .ok()
+        .and_then(|caps| caps.get(capability).and_then(Value::as_bool))
+        .unwrap_or(false)
+}
+
+/// Alternative to an unsupported capability, if there is one
+pub fn suggestion(adapter_id: &str, capability: &str) -> Option<&'static str> {
+    let lookup = |adapter: &str| {
+        SUGGESTIONS
+            .iter()
+            .find(|(a, c, _)| *a == adapter && *c == capability)
+            .map(|(_, _, suggestion)| *suggestion)
+    };
+    lookup(adapter_id).or_else(|| lookup("*"))
+}
+
+/// Whether an adapter supports each capability, after applying overrides;
+/// capabilities it didn't mention are false
+pub fn report(adapter_id: &str, capabilities: &Capabilities) -> BTreeMap<&'static str, bool> {
+    REQUEST_CAPABILITIES
+        .iter()
+        .chain(BREAKPOINT_FIELD_CAPABILITIES)
+        .map(|(_, capability)| capability)
+        .chain(
+            ARGUMENT_CAPABILITIES
+                .iter()
+                .map(|(_, _, capability)| capability),
+        )
+        .chain(OTHER_CAPABILITIES)
+        .map(|capability| {
+            (
+                *capability,
+                is_supported(adapter_id, capabilities, capability),
+            )
+        })
+        .collect()
+}
+
+/// Check a request against an adapter's capabilities before sending it
+pub fn check_request(
+    adapter_id: &str,
+    capabilities: &Capabilities,
+    command: &str,
+    arguments: Option<&Value>,
+) -> Result<()> {
+    for capability in required_capabilities(command, arguments) {
+        if !is_supported(adapter_id, capabilities, capability) {
+            return Err(Error::UnsupportedCapability {
+                capability: capability.to_string(),
+                adapter: adapter_id.to_string(),
+                suggestion: suggestion(adapter_id, capability).map(str::to_string),
+            });
+        }
+    }
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    /// Capabilities from each adapter's initialize response
+    fn reported(adapter_id: &str) -> Capabilities {
+        let body = match adapter_id {
+            "debugpy" => json!({
+                "supportsConfigurationDoneRequest": true,
+                "supportsConditionalBreakpoints": true,
+                "supportsHitConditionalBreakpoints": true,
+                "supportsLogPoints": true,
+                "supportsFunctionBreakpoints": true,
+                "supportsSetVariable": true,
+                "supportsSetExpression": true,
+                "supportsEvaluateForHovers": true,
+                "supportsExceptionInfoRequest": true,
+                "supportsExceptionOptions": true,
+                "supportsCompletionsRequest": true,
+                "supportsGotoTargetsRequest": true,
+                "supportsStepInTargetsRequest": true,
+                "supportsTerminateRequest": true
+            }),
+            "delve" => json!({
+                "supportsConfigurationDoneRequest": true,
+                "supportsConditionalBreakpoints": true,
+                "supportsHitConditionalBreakpoints": true,
+                "supportsLogPoints": true,
+                "supportsFunctionBreakpoints": true,
+                "supportsSetVariable": true,
+                "supportsEvaluateForHovers": true,
+                "supportsExceptionInfoRequest": true,
+                "supportsStepBack": false,
+                "supportsInstructionBreakpoints": true,
+                "supportsDisassembleRequest": true,
+                "supportsTerminateRequest": false,
+                "supportsRestartRequest": false,
+                "supportsSetExpression": false
+            }),
+            "rdbg" => json!({
+                "supportsConfigurationDoneRequest": true,
+                "supportsFunctionBreakpoints": true,
+                "supportsConditionalBreakpoints": true,
+                "supportsTerminateRequest": true,
+                "supportsStepBack": true,
+                "supportsEvaluateForHovers": true,
+                "supportsCompletionsRequest": true,
+                "supportsExceptionInfoRequest": true,
+                "exceptionBreakpointFilters": [
+                    {"filter": "any", "label": "rescue any exception"},
+                    {"filter": "RuntimeError", "label": "rescue RuntimeError"}
+                ]
+            }),
+            _ => unreachable!(),
+        };
+        serde_json::from_value(body).unwrap()
+    }
+
+    fn breakpoint_args(field: &str) -> Value {
+        json!({
+            "source": {"path": "/w/app"},
+            "breakpoints": [{"line": 3}, {"line": 7, field: "x > 1"}]
+        })
+    }
+
+    #[test]
+    fn test_capability_matrix() {
+        // (command, field of a source breakpoint, debugpy, delve, rdbg)
+        let matrix: &[(&str, Option<&str>, bool, bool, bool)] = &[
+            ("setBreakpoints", None, true, true, true),
+            ("setBreakpoints", Some("condition"), true, true, true),
+            ("setBreakpoints", Some("hitCondition"), true, true, false),
+            ("setBreakpoints", Some("logMessage"), true, true, false),
+            ("setFunctionBreakpoints", None, true, true, true),
+            ("setDataBreakpoints", None, false, false, false),
+            ("setVariable", None, true, true, false),
+            ("restart", None, false, false, false),
+            ("terminate", None, true, false, true),
+            ("stepBack", None, false, false, false),
+            ("setInstructionBreakpoints", None, false, true, false),
+            ("disassemble", None, false, true, false),
+            ("breakpointLocations", None, false, false, false),
+            ("stackTrace", None, true, true, true),
+            ("exceptionInfo", None, true, true, true),
+            ("evaluate", None, true, true, true),
+        ];
+
+        for (command, field, debugpy, delve, rdbg) in matrix {
+            let arguments = field.map(breakpoint_args);
+            for (adapter, expected) in [("debugpy", debugpy), ("delve", delve), ("rdbg", rdbg)] {
+                let result =
+                    check_request(adapter, &reported(adapter), command, arguments.as_ref());
+                assert_eq!(
+                    result.is_ok(),
+                    *expected,
+                    "{} {:?} on {}: {:?}",
+                    command,
+                    field,
+                    adapter,
+                    result
+                );
+            }
+        }
+    }
+
+    #[test]
+    fn test_unsupported_error_names_alternative() {
+        let err = check_request("rdbg", &reported("rdbg"), "setDataBreakpoints", None).unwrap_err();
+        match err {
+            Error::UnsupportedCapability {
+                capability,
+                adapter,
+                suggestion,
+            } => {
+                assert_eq!(capability, "supportsDataBreakpoints");
+                assert_eq!(adapter, "rdbg");
+                assert!(suggestion.unwrap().contains("rdbg does not support"));
+            }
+            other => panic!("unexpected error: {:?}", other),
+        }
+
+        assert_eq!(
+            suggestion("debugpy", "supportsDataBreakpoints"),
+            Some("use a logpoint or conditional breakpoint on the lines that modify the value")
+        );
+        assert_eq!(suggestion("delve", "supportsRestartFrame"), None);
+    }
+
+    #[test]
+    fn test_report_covers_gated_capabilities() {
+        let report = report("rdbg", &reported("rdbg"));
+        assert_eq!(report.get("supportsFunctionBreakpoints"), Some(&true));
+        assert_eq!(report.get("supportsStepBack"), Some(&false));
+        assert_eq!(report.get("supportsLogPoints"), Some(&false));
+        for (_, capability) in REQUEST_CAPABILITIES {
+            assert!(report.contains_key(capability));
+        }
+
+        let err = check_request(
+            "rdbg",
+            &reported("rdbg"),
+            "setFunctionBreakpoints",
+            Some(&json!({"breakpoints": [{"name": "Foo#bar", "hitCondition": "2"}]})),
+        )
+        .unwrap_err();
+        assert!(err
+            .to_string()
+            .contains("supportsHitConditionalBreakpoints"));
+        let caps: Capabilities = serde_json::from_value(json!({})).unwrap();
+        let err = check_request("lldb", &caps, "setFunctionBreakpoints", None).unwrap_err();
+        assert!(err.to_string().contains("debugger_set_breakpoint"));
+    }
+
+    #[test]
+    fn test_report_covers_every_capability() {
+        let caps: Capabilities = serde_json::from_value(json!({})).unwrap();
+        let snapshot = report("delve", &caps);
+        let flags = serde_json::to_value(&caps).unwrap();
+        for (capability, value) in flags.as_object().unwrap() {
+            if value.is_null() {
+                assert_eq!(
+                    snapshot.get(capability.as_str()),
+                    Some(&false),
+                    "{} missing from the report",
+                    capability
+                );
+            }
+        }
+
+        let report = report("delve", &reported("delve"));
+        assert_eq!(report.get("supportsDisassembleRequest"), Some(&true));
+        assert_eq!(report.get("supportsConfigurationDoneRequest"), Some(&true));
+        assert_eq!(report.get("supportsReadMemoryRequest"), Some(&false));
+        assert_eq!(report.get("supportsModulesRequest"), Some(&false));
+    }
+
+    #[test]
+    fn test_exception_options_need_capability() {
+        let options = json!({
+            "filters": [],
+            "exceptionOptions": [{
+                "path": [{"names": ["Python Exceptions"]}, {"names": ["myapp.Error"]}],
+                "breakMode": "always"
+            }]
+        });
+        let filters_only = json!({"filters": ["raised"]});
+        for (adapter, supported) in [("debugpy", true), ("delve", false), ("rdbg", false)] {
+            let caps = reported(adapter);
+            let result = check_request(adapter, &caps, "setExceptionBreakpoints", Some(&options));
+            assert_eq!(result.is_ok(), supported, "{}: {:?}", adapter, result);
+            assert!(check_request(
+                adapter,
+                &caps,
+                "setExceptionBreakpoints",
+                Some(&filters_only)
+            )
+            .is_ok());
+            assert_eq!(
+                report(adapter, &caps).get("supportsExceptionOptions"),
+                Some(&supported)
+            );
+        }
+    }
+
+    #[test]
+    fn test_step_granularity_needs_capability() {
+        let step = json!({"threadId": 1, "granularity": "instruction"});
+        let err = check_request("delve", &reported("delve"), "next", Some(&step)).unwrap_err();
+        assert!(
+            err.to_string().contains("step without granularity"),
+            "{}",
+            err
+        );
+        assert!(check_request(
+            "delve",
+            &reported("delve"),
+            "next",
+            Some(&json!({"threadId": 1}))
+        )
+        .is_ok());
+        let caps: Capabilities =
+            serde_json::from_value(json!({"supportsSteppingGranularity": true})).unwrap();
+        for command in ["next", "stepIn", "stepOut"] {
+            assert!(check_request("lldb", &caps, command, Some(&step)).is_ok());
+        }
+    }
+
+    #[test]
+    fn test_override_beats_advertisement() {
+        let caps = reported("rdbg");
+        assert_eq!(caps.supports_step_back, Some(true));
+        assert!(!is_supported("rdbg", &caps, "supportsStepBack"));
+        assert_eq!(caps.exception_breakpoint_filters.unwrap()[0].filter, "any");
+    }
+
+    #[test]
+    fn test_required_capabilities_ignore_null_fields() {
+        let arguments = json!({
+            "source": {"path": "/w/app"},
+            "breakpoints": [{"line": 3, "condition": null, "logMessage": "x={x}"}]
+        });
+        assert_eq!(
+            required_capabilities("setBreakpoints", Some(&arguments)),
+            vec!["supportsLogPoints"]
+        );
+        assert!(required_capabilities("setBreakpoints", None).is_empty());
+        let arguments = json!({
+            "breakpoints": [{"instructionReference": "0x4a1f20", "offset": -4, "hitCondition": "3"}]
+        });
+        assert_eq!(
+            required_capabilities("setInstructionBreakpoints", Some(&arguments)),
+            vec![
+                "supportsInstructionBreakpoints",
+                "supportsHitConditionalBreakpoints"
+            ]
+        );
+        assert_eq!(
+            required_capabilities("reverseContinue", None),
+            vec!["supportsStepBack"]
+        );
+    }
+}
@@ -1,16 +1,28 @@
+use super::capabilities;
+use super::launch_phase::{LaunchPhase, LaunchPhases};
+use super::metrics::Metrics;
+use super::positions::PositionBase;
+use super::read_only;
+use super::request_queue::{self, PendingRequest, PendingRequests, QueueReport, Waiting};
 use super::transport::DapTransport;
 use super::transport_trait::DapTransportTrait;
 use super::types::*;
-use crate::{Error, Result};
+use crate::{config, Error, Result};
 use serde_json::Value;
-use std::collections::HashMap;
-use std::sync::atomic::{AtomicI32, Ordering};
+use std::collections::{HashMap, VecDeque};
+use std::sync::atomic::{AtomicBool, AtomicI32, AtomicU64, Ordering};
 use std::sync::Arc;
+use std::time::{Duration, Instant};
 use tokio::process::{Child, Command};
 use tokio::sync::{mpsc, oneshot, Mutex, Notify, RwLock};
-use tracing::{debug, error, info, warn};
+use tracing::{debug, error, info, warn, Instrument};
+
+/// Frames requested per stack trace unless the caller asks for a page
+pub const DEFAULT_STACK_LEVELS: i32 = 200;
+
+/// Most `output` events kept until a handler is registered for them
+const MAX_EARLY_OUTPUT_EVENTS: usize = 200;
 
-type ResponseSender = oneshot::Sender<Response>;
 type EventNotifier = Arc<Notify>;
 type EventCallback = Arc<dyn Fn(Event) + Send + Sync>;
 type ChildSessionSpawnCallback = Arc<
@@ -21,17 +33,38 @@ type ChildSessionSpawnCallback = Arc<
 pub struct DapClient {
     transport: Arc<Mutex<Box<dyn DapTransportTrait>>>,
     seq_counter: Arc<AtomicI32>,
-    pending_requests: Arc<RwLock<HashMap<i32, ResponseSender>>>,
+    pending_requests: PendingRequests,
     #[allow(dead_code)] // Reserved for future event handling
     event_tx: mpsc::UnboundedSender<Event>,
     // For backward compatibility with wait_for_event
     event_notifiers: Arc<RwLock<HashMap<String, EventNotifier>>>,
     // New: Event callbacks (can have multiple callbacks per event)
     event_callbacks: Arc<RwLock<HashMap<String, Vec<EventCallback>>>>,
+    // `output` events that arrived before an `output` callback was
+    // registered (adapters print diagnostics while starting up); replayed to
+    // the first one
+    early_output: Arc<std::sync::Mutex<VecDeque<Event>>>,
     // Callback for child session spawning (Node.js multi-session)
     child_session_spawn_callback: Arc<RwLock<Option<ChildSessionSpawnCallback>>>,
     // Channel for sending write requests to avoid lock contention
     write_tx: mpsc::UnboundedSender<Message>,
+    // Requests written ahead of those in `write_tx` (see `request_queue`)
+    urgent_tx: mpsc::UnboundedSender<Message>,
+    // Deadline of inspection requests, and how many missed it
+    inspection_timeout: Arc<std::sync::RwLock<Duration>>,
+    timed_out: Arc<AtomicU64>,
+    // Adapter id and capabilities from the last successful initialize
+    capabilities: Arc<RwLock<Option<(String, Capabilities)>>>,
+    // Line and column numbering of the adapter, converted at this boundary
+    positions: Arc<RwLock<PositionBase>>,
+    // Session whose span adapter messages are logged in
+    log_session: Arc<std::sync::RwLock<Option<String>>>,
+    // Request latencies and stops, of the session using this client
+    metrics: Arc<std::sync::RwLock<Arc<Metrics>>>,
+    // Where the start of the session using this client is
+    launch_phases: Arc<std::sync::RwLock<Arc<LaunchPhases>>>,
+    // Refuse requests that change the program (see `read_only`)
+    read_only: Arc<AtomicBool>,
     _child: Option<Child>,
 }
 
@@ -48,6 +81,7 @@ impl DapClient {
             .kill_on_drop(true)
             .spawn()
             .map_err(|e| Error::Process(format!("Failed to spawn debug adapter: {}", e)))?;
+        crate::process::orphans::track(child.id());
 
         let stdin = child
             .stdin
@@ -77,13 +111,19 @@ impl DapClient {
     ) -> Result<Self> {
         let transport = Arc::new(Mutex::new(transport));
         let seq_counter = Arc::new(AtomicI32::new(1));
-        let pending_requests = Arc::new(RwLock::new(HashMap::new()));
+        let pending_requests: PendingRequests = Arc::new(std::sync::Mutex::new(HashMap::new()));
         let (event_tx, event_rx) = mpsc::unbounded_channel();
         let (write_tx, write_rx) = mpsc::unbounded_channel();
+        let (urgent_tx, urgent_rx) = mpsc::unbounded_channel();
+        let inspection_timeout = Duration::from_millis(config::current().timeouts.inspection_ms);
 
         let event_notifiers = Arc::new(RwLock::new(HashMap::new()));
         let event_callbacks = Arc::new(RwLock::new(HashMap::new()));
+        let early_output = Arc::new(std::sync::Mutex::new(VecDeque::new()));
         let child_session_spawn_callback = Arc::new(RwLock::new(None));
+        let positions = Arc::new(RwLock::new(PositionBase::ONE_BASED));
+        let log_session = Arc::new(std::sync::RwLock::new(None));
+        let metrics = Arc::new(std::sync::RwLock::new(Arc::new(Metrics::new())));
 
         let client = Self {
             transport: transport.clone(),
@@ -92,8 +132,18 @@ impl DapClient {
             event_tx,
             event_notifiers: event_notifiers.clone(),
             event_callbacks: event_callbacks.clone(),
+            early_output: early_output.clone(),
             child_session_spawn_callback: child_session_spawn_callback.clone(),
             write_tx: write_tx.clone(),
+            urgent_tx,
+            inspection_timeout: Arc::new(std::sync::RwLock::new(inspection_timeout)),
+            timed_out: Arc::new(AtomicU64::new(0)),
+            read_only: Arc::new(AtomicBool::new(false)),
+            capabilities: Arc::new(RwLock::new(None)),
+            positions: positions.clone(),
+            log_session: log_session.clone(),
+            metrics: metrics.clone(),
+            launch_phases: Arc::new(std::sync::RwLock::new(Arc::new(LaunchPhases::new()))),
             _child: child,
         };
 
@@ -103,23 +153,32 @@ impl DapClient {
             pending_requests.clone(),
             event_notifiers.clone(),
             event_callbacks.clone(),
+            early_output,
             child_session_spawn_callback.clone(),
+            positions,
+            log_session,
+            metrics,
             event_rx,
         ));
 
         // Spawn message writer handler
-        tokio::spawn(Self::message_writer(transport.clone(), write_rx));
+        tokio::spawn(Self::message_writer(transport.clone(), urgent_rx, write_rx));
 
         Ok(client)
     }
 
     /// Message reader task - reads messages from transport and dispatches them
+    #[allow(clippy::too_many_arguments)]
     async fn message_reader(
         transport: Arc<Mutex<Box<dyn DapTransportTrait>>>,
-        pending_requests: Arc<RwLock<HashMap<i32, ResponseSender>>>,
+        pending_requests: PendingRequests,
         event_notifiers: Arc<RwLock<HashMap<String, EventNotifier>>>,
         event_callbacks: Arc<RwLock<HashMap<String, Vec<EventCallback>>>>,
+        early_output: Arc<std::sync::Mutex<VecDeque<Event>>>,
         child_session_spawn_callback: Arc<RwLock<Option<ChildSessionSpawnCallback>>>,
+        positions: Arc<RwLock<PositionBase>>,
+        log_session: Arc<std::sync::RwLock<Option<String>>>,
+        metrics: Arc<std::sync::RwLock<Arc<Metrics>>>,
         mut _event_rx: mpsc::UnboundedReceiver<Event>,
     ) {
         loop {
@@ -158,128 +217,140 @@ impl DapClient {
                 }
             };
 
-            match msg {
-                Message::Response(resp) => {
-                    debug!("Received response for seq {}", resp.request_seq);
-                    let mut pending = pending_requests.write().await;
-                    if let Some(sender) = pending.remove(&resp.request_seq) {
-                        if sender.send(resp).is_err() {
-                            warn!("Failed to send response to waiting request");
+            // Log the message in its session's span (see `log_level`)
+            let span = crate::log_level::session_span(log_session.read().unwrap().as_deref());
+            async {
+                match msg {
+                    Message::Response(mut resp) => {
+                        debug!("Received response for seq {}", resp.request_seq);
+                        if let Some(body) = resp.body.as_mut() {
+                            positions
+                                .read()
+                                .await
+                                .response_from_adapter(&resp.command, body);
+                        }
+                        let waiting = pending_requests
+                            .lock()
+                            .unwrap_or_else(|e| e.into_inner())
+                            .remove(&resp.request_seq);
+                        if let Some(waiting) = waiting {
+                            if waiting.sender.send(resp).is_err() {
+                                warn!("Failed to send response to waiting request");
+                            }
+                        } else {
+                            // Late answers to requests past their deadline
+                            warn!(
+                                "Received response for unknown or abandoned request: {}",
+                                resp.request_seq
+                            );
                         }
-                    } else {
-                        warn!(
-                            "Received response for unknown request: {}",
-                            resp.request_seq
-                        );
-                    }
-                }
-                Message::Event(event) => {
-                    info!(
-                        "🎯 EVENT RECEIVED: '{}' with body: {:?}",
-                        event.event, event.body
-                    );
-
-                    // 1. Notify anyone waiting for this specific event (legacy wait_for_event)
-                    let notifiers = event_notifiers.read().await;
-                    if let Some(notifier) = notifiers.get(&event.event) {
-                        info!("  Notifying waiters for event '{}'", event.event);
-                        notifier.notify_waiters();
                     }
-                    drop(notifiers);
-
-                    // 2. Invoke registered event callbacks
-                    let callbacks = event_callbacks.read().await;
-                    if let Some(handlers) = callbacks.get(&event.event) {
+                    Message::Event(mut event) => {
                         info!(
-                            "  Found {} callback(s) for event '{}'",
-                            handlers.len(),
-                            event.event
+                            "🎯 EVENT RECEIVED: '{}' with body: {:?}",
+                            event.event, event.body
                         );
-                        for (idx, callback) in handlers.iter().enumerate() {
-                            info!("  Invoking callback {} for event '{}'", idx, event.event);
-                            // Invoke callback with cloned event
-                            callback(event.clone());
-                            info!("  Callback {} completed for event '{}'", idx, event.event);
+                        if let Some(body) = event.body.as_mut() {
+                            positions
+                                .read()
+                                .await
+                                .event_from_adapter(&event.event, body);
+                        }
+                        if event.event == "stopped" {
+                            let hit = event
+                                .body
+                                .as_ref()
+                                .and_then(|b| b.get("hitBreakpointIds"))
+                                .and_then(|ids| ids.as_array())
+                                .is_some_and(|ids| !ids.is_empty());
+                            metrics.read().unwrap().record_stop(hit);
                         }
-                    } else {
-                        info!("  No callbacks registered for event '{}'", event.event);
+                        Self::dispatch_event(
+                            &event_notifiers,
+                            &event_callbacks,
+                            &early_output,
+                            event,
+                        )
+                        .await;
                     }
-                }
-                Message::Request(req) => {
-                    info!(
-                        "🔄 REVERSE REQUEST received: '{}' (seq {})",
-                        req.command, req.seq
-                    );
-                    info!("   Arguments: {:?}", req.arguments);
-
-                    // vscode-js-debug sends reverse requests like 'startDebugging' or 'attachedChildSession'
-                    // We need to respond to these requests or the adapter will hang
-
-                    // Handle startDebugging - extract __pendingTargetId and spawn child
-                    if req.command == "startDebugging" {
+                    Message::Request(req) => {
                         info!(
-                            "   🎯 startDebugging request detected - extracting __pendingTargetId"
+                            "🔄 REVERSE REQUEST received: '{}' (seq {})",
+                            req.command, req.seq
                         );
-                        if let Some(args) = &req.arguments {
-                            if let Some(config) = args.get("configuration") {
-                                if let Some(target_id) = config.get("__pendingTargetId") {
-                                    if let Some(target_id_str) = target_id.as_str() {
-                                        info!("   ✅ Found __pendingTargetId: {}", target_id_str);
-
-                                        // Invoke callback to spawn child session
-                                        let callback_guard =
-                                            child_session_spawn_callback.read().await;
-                                        if let Some(callback) = callback_guard.as_ref() {
-                                            info!("   📞 Invoking child session spawn callback with target_id: {}", target_id_str);
-                                            let fut = callback(target_id_str.to_string());
-                                            drop(callback_guard);
-                                            tokio::spawn(fut);
+                        info!("   Arguments: {:?}", req.arguments);
+
+                        // vscode-js-debug sends reverse requests like 'startDebugging' or 'attachedChildSession'
+                        // We need to respond to these requests or the adapter will hang
+
+                        // Handle startDebugging - extract __pendingTargetId and spawn child
+                        if req.command == "startDebugging" {
+                            info!(
+                                "   🎯 startDebugging request detected - extracting __pendingTargetId"
+                            );
+                            if let Some(args) = &req.arguments {
+                                if let Some(config) = args.get("configuration") {
+                                    if let Some(target_id) = config.get("__pendingTargetId") {
+                                        if let Some(target_id_str) = target_id.as_str() {
+                                            info!("   ✅ Found __pendingTargetId: {}", target_id_str);
+
+                                            // Invoke callback to spawn child session
+                                            let callback_guard =
+                                                child_session_spawn_callback.read().await;
+                                            if let Some(callback) = callback_guard.as_ref() {
+                                                info!("   📞 Invoking child session spawn callback with target_id: {}", target_id_str);
+                                                let fut = callback(target_id_str.to_string());
+                                                drop(callback_guard);
+                                                tokio::spawn(fut);
+                                            } else {
+                                                warn!(
+                                                    "   ⚠️  No child session spawn callback registered"
+                                                );
+                                            }
                                         } else {
                                             warn!(
-                                                "   ⚠️  No child session spawn callback registered"
+                                                "   ⚠️  __pendingTargetId is not a string: {:?}",
+                                                target_id
                                             );
                                         }
                                     } else {
-                                        warn!(
-                                            "   ⚠️  __pendingTargetId is not a string: {:?}",
-                                            target_id
-                                        );
+                                        warn!("   ⚠️  No __pendingTargetId in configuration");
                                     }
                                 } else {
-                                    warn!("   ⚠️  No __pendingTargetId in configuration");
+                                    warn!("   ⚠️  No configuration in startDebugging arguments");
                                 }
-                            } else {
-                                warn!("   ⚠️  No configuration in startDebugging arguments");
                             }
                         }
-                    }
 
-                    // Send success response
-                    let response = Response {
-                        seq: 0, // Response seq (incremental, but not critical for reverse requests)
-                        request_seq: req.seq,
-                        success: true,
-                        command: req.command.clone(),
-                        message: None,
-                        body: None,
-                    };
+                        // Send success response
+                        let response = Response {
+                            seq: 0, // Response seq (incremental, but not critical for reverse requests)
+                            request_seq: req.seq,
+                            success: true,
+                            command: req.command.clone(),
+                            message: None,
+                            body: None,
+                        };
 
-                    info!(
-                        "   Sending success response to reverse request '{}'",
-                        req.command
-                    );
+                        info!(
+                            "   Sending success response to reverse request '{}'",
+                            req.command
+                        );
 
-                    // Send response back via transport (don't use write channel to avoid deadlock)
-                    let transport_clone = transport.clone();
-                    tokio::spawn(async move {
-                        let mut transport = transport_clone.lock().await;
-                        if let Err(e) = transport.write_message(&Message::Response(response)).await
-                        {
-                            error!("Failed to send reverse request response: {}", e);
-                        }
-                    });
+                        // Send response back via transport (don't use write channel to avoid deadlock)
+                        let transport_clone = transport.clone();
+                        tokio::spawn(async move {
+                            let mut transport = transport_clone.lock().await;
+                            if let Err(e) = transport.write_message(&Message::Response(response)).await
+                            {
+                                error!("Failed to send reverse request response: {}", e);
+                            }
+                        });
+                    }
                 }
             }
+            .instrument(span)
+            .await;
 
             // Small sleep to let other tasks run (e.g., configurationDone sender)
             // This is necessary because read_message() blocks holding the lock
@@ -289,12 +360,21 @@ impl DapClient {
 
     /// Message writer task - writes messages to transport from a channel
     /// This avoids lock contention between reader and writers
+    ///
+    /// Urgent messages are written first, whatever is queued before them.
     async fn message_writer(
         transport: Arc<Mutex<Box<dyn DapTransportTrait>>>,
+        mut urgent_rx: mpsc::UnboundedReceiver<Message>,
         mut write_rx: mpsc::UnboundedReceiver<Message>,
     ) {
         info!("📝 message_writer: Task started");
-        while let Some(message) = write_rx.recv().await {
+        loop {
+            let message = tokio::select! {
+                biased;
+                Some(message) = urgent_rx.recv() => message,
+                Some(message) = write_rx.recv() => message,
+                else => break,
+            };
             let msg_type = match &message {
                 Message::Request(req) => format!("Request({})", req.command),
                 Message::Response(resp) => format!("Response(seq {})", resp.seq),
@@ -315,13 +395,105 @@ impl DapClient {
         info!("📝 message_writer: Task exiting");
     }
 
+    /// Notify waiters and invoke registered callbacks for an event
+    async fn dispatch_event(
+        event_notifiers: &RwLock<HashMap<String, EventNotifier>>,
+        event_callbacks: &RwLock<HashMap<String, Vec<EventCallback>>>,
+        early_output: &std::sync::Mutex<VecDeque<Event>>,
+        event: Event,
+    ) {
+        // 1. Notify anyone waiting for this specific event (legacy wait_for_event)
+        let notifiers = event_notifiers.read().await;
+        if let Some(notifier) = notifiers.get(&event.event) {
+            info!("  Notifying waiters for event '{}'", event.event);
+            notifier.notify_waiters();
+        }
+        drop(notifiers);
+
+        // 2. Invoke registered event callbacks
+        let callbacks = event_callbacks.read().await;
+        if let Some(handlers) = callbacks.get(&event.event) {
+            info!(
+                "  Found {} callback(s) for event '{}'",
+                handlers.len(),
+                event.event
+            );
+            for (idx, callback) in handlers.iter().enumerate() {
+                info!("  Invoking callback {} for event '{}'", idx, event.event);
+                // Invoke callback with cloned event
+                callback(event.clone());
+                info!("  Callback {} completed for event '{}'", idx, event.event);
+            }
+        } else if event.event == "output" {
+            // Kept while the callbacks are locked, so `on_event` can't
+            // register the first callback between the check and the push
+            let mut early = early_output.lock().unwrap();
+            if early.len() == MAX_EARLY_OUTPUT_EVENTS {
+                early.pop_front();
+            }
+            early.push_back(event);
+        } else {
+            info!("  No callbacks registered for event '{}'", event.event);
+        }
+    }
+
+    /// Deliver an event that did not come from the adapter (e.g. a stop found
+    /// by polling) to the same waiters and callbacks as a real one
+    pub async fn emit_event(&self, event: Event) {
+        Self::dispatch_event(
+            &self.event_notifiers,
+            &self.event_callbacks,
+            &self.early_output,
+            event,
+        )
+        .await;
+    }
+
+    /// Log messages from the adapter in this session's span, so a
+    /// per-session log level applies to them (see `log_level`)
+    pub fn set_log_session(&self, session_id: &str) {
+        *self.log_session.write().unwrap() = Some(session_id.to_string());
+    }
+
+    /// Record request latencies and stops into a session's metrics
+    pub fn set_metrics(&self, metrics: Arc<Metrics>) {
+        *self.metrics.write().unwrap() = metrics;
+    }
+
+    pub fn metrics(&self) -> Arc<Metrics> {
+        self.metrics.read().unwrap().clone()
+    }
+
+    /// Record the phases of the DAP handshake into a session's (see
+    /// `launch_phase`)
+    pub fn set_launch_phases(&self, phases: Arc<LaunchPhases>) {
+        *self.launch_phases.write().unwrap() = phases;
+    }
+
+    fn enter_phase(&self, phase: LaunchPhase) {
+        self.launch_phases.read().unwrap().enter(phase);
+    }
+
     /// Register a callback for a specific DAP event
     /// The callback will be invoked every time the event is received
+    ///
+    /// The first `output` callback is first given the `output` events that
+    /// arrived before it, oldest first, so nothing the adapter printed while
+    /// starting up is lost.
     pub async fn on_event<F>(&self, event_name: &str, callback: F)
     where
         F: Fn(Event) + Send + Sync + 'static,
     {
         let mut callbacks = self.event_callbacks.write().await;
+        if event_name == "output" {
+            let early: Vec<Event> = self.early_output.lock().unwrap().drain(..).collect();
+            if !early.is_empty() {
+                info!("  Replaying {} early output event(s)", early.len());
+            }
+            for event in early {
+                callback(event);
+            }
+        }
         callbacks
             .entry(event_name.to_string())
             .or_insert_with(Vec::new)
@@ -405,6 +577,9 @@ impl DapClient {
         arguments: Option<Value>,
     ) -> Result<i32> {
         debug!("send_request_nowait: Starting for command '{}'", command);
+        self.check_read_only(command)?;
+        self.check_capabilities(command, arguments.as_ref()).await?;
+        let arguments = self.to_adapter_positions(command, arguments).await;
         let seq = self.seq_counter.fetch_add(1, Ordering::SeqCst);
 
         let request = Request {
@@ -430,7 +605,24 @@ impl DapClient {
     }
 
     /// Send a request and wait for response (blocking)
+    ///
+    /// Inspection requests fail with `Error::Timeout` past the inspection
+    /// deadline (see `request_queue`).
     pub async fn send_request(&self, command: &str, arguments: Option<Value>) -> Result<Response> {
+        let deadline = request_queue::is_inspection(command).then(|| self.inspection_timeout());
+        self.send_request_until(command, arguments, deadline).await
+    }
+
+    /// Send a request and wait for its response until `deadline` passes
+    async fn send_request_until(
+        &self,
+        command: &str,
+        arguments: Option<Value>,
+        deadline: Option<Duration>,
+    ) -> Result<Response> {
+        self.check_read_only(command)?;
+        self.check_capabilities(command, arguments.as_ref()).await?;
+        let arguments = self.to_adapter_positions(command, arguments).await;
         let seq = self.seq_counter.fetch_add(1, Ordering::SeqCst);
 
         info!(
@@ -446,23 +638,48 @@ impl DapClient {
 
         let (tx, rx) = oneshot::channel();
 
-        {
-            let mut pending = self.pending_requests.write().await;
-            pending.insert(seq, tx);
-            info!(
-                "✉️  send_request: Registered pending request for seq {}",
-                seq
-            );
-        }
+        self.pending_requests
+            .lock()
+            .unwrap_or_else(|e| e.into_inner())
+            .insert(seq, PendingRequest::new(tx, command));
+        let _waiting = Waiting::new(&self.pending_requests, seq);
+        info!(
+            "✉️  send_request: Registered pending request for seq {}",
+            seq
+        );
 
         info!("✉️  send_request: Sending message to write channel");
-        self.write_tx
-            .send(Message::Request(request))
-            .map_err(|_| Error::Dap("Write channel closed".to_string()))?;
+        self.queue_message(Message::Request(request))?;
 
         info!("✉️  send_request: Waiting for response to seq {}", seq);
-        let response = rx
-            .await
+        let sent = Instant::now();
+        let response = match deadline {
+            Some(deadline) => match tokio::time::timeout(deadline, rx).await {
+                Ok(response) => response,
+                Err(_) => {
+                    self.metrics().record_request(command, deadline, false);
+                    self.timed_out.fetch_add(1, Ordering::Relaxed);
+                    if request_queue::is_inspection(command) {
+                        self.cancel(seq).await;
+                    }
+                    warn!(
+                        "⏱️  '{}' request (seq {}) got no response within {:?}; failed alone, the connection is kept",
+                        command, seq, deadline
+                    );
+                    return Err(Error::Timeout(format!(
+                        "Request '{}' took longer than {:?}",
+                        command, deadline
+                    )));
+                }
+            },
+            None => rx.await,
+        };
+        self.metrics().record_request(
+            command,
+            sent.elapsed(),
+            response.as_ref().is_ok_and(|r| r.success),
+        );
+        let response = response
             .map_err(|_| Error::Dap("Request cancelled or connection closed".to_string()))?;
 
         info!(
@@ -484,14 +701,94 @@ impl DapClient {
             command, timeout
         );
 
-        tokio::time::timeout(timeout, self.send_request(command, arguments))
+        self.send_request_until(command, arguments, Some(timeout))
             .await
-            .map_err(|_| {
-                Error::Dap(format!(
+            .map_err(|e| match e {
+                Error::Timeout(_) => Error::Dap(format!(
                     "Request '{}' timed out after {:?}",
                     command, timeout
-                ))
-            })?
+                )),
+                e => e,
+            })
+    }
+
+    /// Queue a message for the writer, ahead of the others if it is an
+    /// urgent request
+    fn queue_message(&self, message: Message) -> Result<()> {
+        let urgent =
+            matches!(&message, Message::Request(req) if request_queue::is_urgent(&req.command));
+        let channel = if urgent {
+            &self.urgent_tx
+        } else {
+            &self.write_tx
+        };
+        channel
+            .send(message)
+            .map_err(|_| Error::Dap("Write channel closed".to_string()))
+    }
+
+    /// Ask the adapter to stop working on request `seq`, which its caller
+    /// gave up on, where the adapter supports `cancel`
+    async fn cancel(&self, seq: i32) {
+        let supported = match self.capabilities.read().await.as_ref() {
+            Some((adapter_id, caps)) => {
+                capabilities::is_supported(adapter_id, caps, "supportsCancelRequest")
+            }
+            None => false,
+        };
+        if !supported {
+            return;
+        }
+        let arguments = serde_json::json!({ "requestId": seq });
+        let sent = self
+            .send_request_async("cancel", Some(arguments), move |response| {
+                if let Err(e) = response {
+                    debug!("Cancelling request {} failed: {}", seq, e);
+                }
+            })
+            .await;
+        if let Err(e) = sent {
+            debug!("Cancelling request {} failed: {}", seq, e);
+        }
+    }
+
+    /// Deadline of inspection requests
+    pub fn inspection_timeout(&self) -> Duration {
+        *self.inspection_timeout.read().unwrap()
+    }
+
+    pub fn set_inspection_timeout(&self, timeout: Duration) {
+        *self.inspection_timeout.write().unwrap() = timeout;
+    }
+
+    /// Refuse requests that change the program from now on; there is no
+    /// way back (see `read_only`)
+    pub fn set_read_only(&self) {
+        self.read_only.store(true, Ordering::SeqCst);
+    }
+
+    pub fn is_read_only(&self) -> bool {
+        self.read_only.load(Ordering::SeqCst)
+    }
+
+    fn check_read_only(&self, command: &str) -> Result<()> {
+        if self.is_read_only() {
+            read_only::check_command(command)?;
+        }
+        Ok(())
+    }
+
+    /// Requests waiting for a response
+    pub fn queue_report(&self) -> QueueReport {
+        let pending = self
+            .pending_requests
+            .lock()
+            .unwrap_or_else(|e| e.into_inner());
+        request_queue::report(
+            &pending,
+            self.timed_out.load(Ordering::Relaxed),
+            Instant::now(),
+        )
     }
 
     /// Send a request with a callback for the response
@@ -505,6 +802,8 @@ impl DapClient {
         F: FnOnce(Result<Response>) + Send + 'static,
     {
         debug!("send_request_async: Starting for command '{}'", command);
+        self.check_capabilities(command, arguments.as_ref()).await?;
+        let arguments = self.to_adapter_positions(command, arguments).await;
         let seq = self.seq_counter.fetch_add(1, Ordering::SeqCst);
 
         let request = Request {
@@ -516,27 +815,34 @@ impl DapClient {
         let (tx, rx) = oneshot::channel();
 
         debug!("send_request_async: Registering pending request");
-        {
-            let mut pending = self.pending_requests.write().await;
-            pending.insert(seq, tx);
-        }
+        self.pending_requests
+            .lock()
+            .unwrap_or_else(|e| e.into_inner())
+            .insert(seq, PendingRequest::new(tx, command));
 
         debug!(
             "send_request_async: Sending {} request (seq {}) to write channel",
             command, seq
         );
-        self.write_tx
-            .send(Message::Request(request))
-            .map_err(|_| Error::Dap("Write channel closed".to_string()))?;
+        self.queue_message(Message::Request(request))?;
         debug!("send_request_async: Request queued");
 
         // Spawn task to wait for response and invoke callback
+        let metrics = self.metrics();
+        let command = command.to_string();
+        let sent = Instant::now();
         tokio::spawn(async move {
             debug!(
                 "send_request_async callback task: Waiting for response seq {}",
                 seq
             );
-            match rx.await {
+            let response = rx.await;
+            metrics.record_request(
+                &command,
+                sent.elapsed(),
+                response.as_ref().is_ok_and(|r| r.success),
+            );
+            match response {
                 Ok(response) => {
                     debug!(
                         "send_request_async callback task: Got response for seq {}",
@@ -560,8 +866,8 @@ impl DapClient {
             client_name: Some("debugger_mcp".to_string()),
             adapter_id: adapter_id.to_string(),
             locale: Some("en-US".to_string()),
-            lines_start_at_1: Some(true),
-            columns_start_at_1: Some(true),
+            lines_start_at_1: Some(PositionBase::ONE_BASED.lines_start_at1),
+            columns_start_at_1: Some(PositionBase::ONE_BASED.columns_start_at1),
             path_format: Some("path".to_string()),
         };
 
@@ -576,6 +882,16 @@ impl DapClient {
             )));
         }
 
+        let positions =
+            PositionBase::from_initialize(PositionBase::ONE_BASED, response.body.as_ref());
+        if !positions.is_one_based() {
+            info!(
+                "Adapter {} numbers positions {:?}; converting",
+                adapter_id, positions
+            );
+        }
+        *self.positions.write().await = positions;
+
         let caps: Capabilities = response
             .body
             .ok_or_else(|| Error::Dap("No capabilities in initialize response".to_string()))
@@ -584,9 +900,80 @@ impl DapClient {
                     .map_err(|e| Error::Dap(format!("Failed to parse capabilities: {}", e)))
             })?;
 
+        *self.capabilities.write().await = Some((adapter_id.to_string(), caps.clone()));
         Ok(caps)
     }
 
+    /// Capabilities reported by the adapter (None before initialize)
+    pub async fn capabilities(&self) -> Option<Capabilities> {
+        self.capabilities
+            .read()
+            .await
+            .as_ref()
+            .map(|(_, caps)| caps.clone())
+    }
+
+    /// Line and column numbering the adapter uses (1-based before initialize)
+    pub async fn positions(&self) -> PositionBase {
+        *self.positions.read().await
+    }
+
+    /// Request arguments with lines and columns in the adapter's numbering
+    async fn to_adapter_positions(&self, command: &str, arguments: Option<Value>) -> Option<Value> {
+        let mut arguments = arguments?;
+        self.positions
+            .read()
+            .await
+            .request_to_adapter(command, &mut arguments);
+        Some(arguments)
+    }
+
+    /// Adapter id the client was initialized with (None before initialize)
+    pub async fn adapter_id(&self) -> Option<String> {
+        self.capabilities
+            .read()
+            .await
+            .as_ref()
+            .map(|(adapter_id, _)| adapter_id.clone())
+    }
+
+    /// Fail fast if the adapter doesn't support a request (see `capabilities`)
+    ///
+    /// Nothing is checked before initialize, when capabilities aren't known.
+    pub async fn check_capabilities(&self, command: &str, arguments: Option<&Value>) -> Result<()> {
+        match self.capabilities.read().await.as_ref() {
+            Some((adapter_id, caps)) => {
+                capabilities::check_request(adapter_id, caps, command, arguments)
+            }
+            None => Ok(()),
+        }
+    }
+
+    /// Whether logpoints have to be emulated: the adapter was initialized and
+    /// doesn't support them (see `debug::log_points`)
+    pub async fn emulates_log_points(&self) -> bool {
+        match self.capabilities.read().await.as_ref() {
+            Some((adapter_id, caps)) => {
+                !capabilities::is_supported(adapter_id, caps, "supportsLogPoints")
+            }
+            None => false,
+        }
+    }
+
+    /// Source breakpoints in the form the adapter accepts: logpoints become
+    /// plain breakpoints (keeping their conditions) where they are emulated
+    pub async fn native_breakpoints(
+        &self,
+        mut breakpoints: Vec<SourceBreakpoint>,
+    ) -> Vec<SourceBreakpoint> {
+        if self.emulates_log_points().await {
+            for bp in &mut breakpoints {
+                bp.log_message = None;
+            }
+        }
+        breakpoints
+    }
+
     pub async fn launch(&self, args: Value) -> Result<()> {
         let response = self.send_request("launch", Some(args)).await?;
 
@@ -599,11 +986,17 @@ impl DapClient {
 
     /// Proper DAP initialization and launch sequence following the specification
     /// This method implements the correct async flow:
-    /// 1. Send initialize, get response
-    /// 2. Register 'initialized' event handler (just signals, doesn't call methods)
+    /// 1. Register 'initialized' event handler (just signals, doesn't call methods)
+    /// 2. Send initialize, get response
     /// 3. Send launch (triggers 'initialized' event)
     /// 4. Wait for 'initialized' signal, then send configurationDone from main context
     /// 5. Wait for launch response
+    ///
+    /// Most adapters send 'initialized' after launch, but some send it right
+    /// after (or even before) the initialize response. The handler is
+    /// registered before initialize and the signal is buffered, so the
+    /// sequence proceeds once both the response and the event are in hand,
+    /// whichever order they arrive in.
     pub async fn initialize_and_launch(
         &self,
         adapter_id: &str,
@@ -615,19 +1008,44 @@ impl DapClient {
             launch_args,
             adapter_type,
             HashMap::new(),
+            Vec::new(),
         )
         .await
     }
 
+    /// Initialize and launch, setting breakpoints set before the launch
+    /// between the `initialized` event and `configurationDone`
     pub async fn initialize_and_launch_with_pending(
         &self,
         adapter_id: &str,
         launch_args: Value,
         adapter_type: Option<&str>,
         pending_breakpoints: HashMap<String, Vec<SourceBreakpoint>>,
+        pending_function_breakpoints: Vec<FunctionBreakpoint>,
     ) -> Result<()> {
-        // Step 1: Send initialize request and get capabilities
+        // Step 1: Register 'initialized' event handler BEFORE sending initialize
+        // An adapter may send the event as soon as it has answered initialize,
+        // before we get to send launch. The oneshot keeps the signal until we wait.
+        // We use a simple signal approach - the handler just notifies, doesn't send messages
+        let (init_tx, init_rx) = oneshot::channel();
+        let init_tx = Arc::new(tokio::sync::Mutex::new(Some(init_tx)));
+
+        self.on_event("initialized", move |_event| {
+            info!("Received 'initialized' event - signaling");
+            let tx = init_tx.clone();
+            // Just signal - don't call any async methods from here
+            // This keeps the event handler fast (< 0.1ms like Python standalone test)
+            tokio::spawn(async move {
+                if let Some(sender) = tx.lock().await.take() {
+                    let _ = sender.send(());
+                }
+            });
+        })
+        .await;
+
+        // Step 2: Send initialize request and get capabilities
         info!("Sending initialize request to adapter");
+        self.enter_phase(LaunchPhase::Initializing);
         let capabilities = self.initialize(adapter_id).await?;
         debug!(
             "Adapter capabilities: supportsConfigurationDoneRequest={:?}",
@@ -693,38 +1111,34 @@ impl DapClient {
             }
         }
 
-        // Step 2: Register 'initialized' event handler BEFORE sending launch
-        // This handler will be invoked DURING launch processing
-        // We use a simple signal approach - the handler just notifies, doesn't send messages
-        let (init_tx, init_rx) = oneshot::channel();
-        let init_tx = Arc::new(tokio::sync::Mutex::new(Some(init_tx)));
-
-        self.on_event("initialized", move |_event| {
-            info!("Received 'initialized' event - signaling");
-            let tx = init_tx.clone();
-            // Just signal - don't call any async methods from here
-            // This keeps the event handler fast (< 0.1ms like Python standalone test)
-            tokio::spawn(async move {
-                if let Some(sender) = tx.lock().await.take() {
-                    let _ = sender.send(());
-                }
-            });
-        })
-        .await;
-
-        // Step 3: Send launch request (doesn't wait for response yet)
-        info!("Sending launch request with args: {:?}", launch_args);
+        // Step 3: Send launch (or attach) request (doesn't wait for response yet)
+        // Attach follows the same initialized/configurationDone handshake as launch
+        let request_command = match launch_args.get("request").and_then(|v| v.as_str()) {
+            Some("attach") => "attach",
+            _ => "launch",
+        };
+        info!(
+            "Sending {} request with args: {:?}",
+            request_command, launch_args
+        );
         let launch_seq = self
-            .send_request_nowait("launch", Some(launch_args))
+            .send_request_nowait(request_command, Some(launch_args))
             .await?;
-        info!("Launch request sent with seq {}", launch_seq);
+        info!("{} request sent with seq {}", request_command, launch_seq);
+        self.enter_phase(LaunchPhase::WaitingInitializedEvent);
 
-        // Step 4: Wait for 'initialized' event signal
+        // Step 4: Wait for 'initialized' event signal (possibly received already)
         if config_done_supported {
-            info!("Waiting for 'initialized' event (timeout: 5s)...");
-            match tokio::time::timeout(tokio::time::Duration::from_secs(5), init_rx).await {
+            let init_timeout =
+                tokio::time::Duration::from_millis(config::current().timeouts.initialized_ms);
+            info!(
+                "Waiting for 'initialized' event (timeout: {:?})...",
+                init_timeout
+            );
+            match tokio::time::timeout(init_timeout, init_rx).await {
                 Ok(Ok(())) => {
                     info!("✅ Received 'initialized' event signal");
+                    self.enter_phase(LaunchPhase::SendingBreakpoints);
 
                     // Apply pending breakpoints BEFORE configurationDone (correct DAP sequence)
                     if !pending_breakpoints.is_empty() {
@@ -742,8 +1156,10 @@ impl DapClient {
                                 path: Some(source_path.clone()),
                                 name: None,
                                 source_reference: None,
+                                checksums: None,
                             };
-                            match self.set_breakpoints(source, breakpoints.clone()).await {
+                            let breakpoints = self.native_breakpoints(breakpoints.clone()).await;
+                            match self.set_breakpoints(source, breakpoints).await {
                                 Ok(bps) => {
                                     info!("  ✅ Set {} breakpoints for {}", bps.len(), source_path);
                                     for bp in bps {
@@ -766,6 +1182,16 @@ impl DapClient {
                             }
                         }
                     }
+                    if !pending_function_breakpoints.is_empty() {
+                        let count = pending_function_breakpoints.len();
+                        match self
+                            .set_function_breakpoints(pending_function_breakpoints)
+                            .await
+                        {
+                            Ok(_) => info!("  ✅ Set {} function breakpoint(s)", count),
+                            Err(e) => warn!("  ⚠️  Failed to set function breakpoints: {}", e),
+                        }
+                    }
 
                     // Entry breakpoint workaround: Set breakpoint BEFORE configurationDone
                     // This follows the correct DAP sequence (setBreakpoints must be before configurationDone)
@@ -782,7 +1208,9 @@ impl DapClient {
                             Some(path) => {
                                 // Find first executable line based on language
                                 let entry_line = if adapter_type_str == "ruby" {
-                                    Self::find_first_executable_line_ruby(path)
+                                    crate::adapters::ruby::RubyAdapter::find_first_executable_line(
+                                        path,
+                                    )
                                 } else if adapter_type_str == "go" {
                                     Self::find_first_executable_line_go(path)
                                 } else {
@@ -795,6 +1223,7 @@ impl DapClient {
                                     path: Some(path.to_string()),
                                     name: None,
                                     source_reference: None,
+                                    checksums: None,
                                 };
 
                                 let breakpoint = SourceBreakpoint {
@@ -802,6 +1231,7 @@ impl DapClient {
                                     column: None,
                                     condition: None,
                                     hit_condition: None,
+                                    log_message: None,
                                 };
 
                                 // Set breakpoint BEFORE configurationDone (per DAP spec)
@@ -842,19 +1272,26 @@ impl DapClient {
                     ));
                 }
                 Err(_) => {
-                    error!("❌ Timeout waiting for 'initialized' event (5s)");
+                    error!(
+                        "❌ Timeout waiting for 'initialized' event ({:?})",
+                        init_timeout
+                    );
                     error!("   This usually means:");
                     error!("   1. The program path is invalid or not found");
                     error!("   2. The Python environment doesn't have the target program");
                     error!("   3. The program has a syntax error preventing launch");
                     error!("   4. debugpy couldn't start the target program");
                     error!("   Check that the program path exists and is executable");
-                    return Err(Error::Dap("Timeout waiting for 'initialized' event (5s). Program may not exist or has errors.".to_string()));
+                    return Err(Error::Dap(format!(
+                        "Timeout waiting for 'initialized' event ({:?}). Program may not exist or has errors.",
+                        init_timeout
+                    )));
                 }
             }
 
             // Step 5: Now send configurationDone from main context (not from event handler)
             info!("Sending configurationDone");
+            self.enter_phase(LaunchPhase::ConfigurationDone);
             self.configuration_done().await?;
             info!("configurationDone completed");
         }
@@ -879,8 +1316,18 @@ impl DapClient {
             event_tx: self.event_tx.clone(),
             event_notifiers: self.event_notifiers.clone(),
             event_callbacks: self.event_callbacks.clone(),
+            early_output: self.early_output.clone(),
             child_session_spawn_callback: self.child_session_spawn_callback.clone(),
             write_tx: self.write_tx.clone(),
+            urgent_tx: self.urgent_tx.clone(),
+            inspection_timeout: self.inspection_timeout.clone(),
+            timed_out: self.timed_out.clone(),
+            capabilities: self.capabilities.clone(),
+            positions: self.positions.clone(),
+            log_session: self.log_session.clone(),
+            metrics: self.metrics.clone(),
+            launch_phases: self.launch_phases.clone(),
+            read_only: self.read_only.clone(),
             _child: None, // Don't clone the child process
         }
     }
@@ -964,80 +1411,251 @@ impl DapClient {
         Ok(body.breakpoints)
     }
 
-    pub async fn continue_execution(&self, thread_id: i32) -> Result<()> {
-        let args = ContinueArguments { thread_id };
+    /// Replace all function breakpoints
+    ///
+    /// The request carries the complete set; the response lists one
+    /// breakpoint per requested entry, in order.
+    pub async fn set_function_breakpoints(
+        &self,
+        breakpoints: Vec<FunctionBreakpoint>,
+    ) -> Result<Vec<Breakpoint>> {
+        let args = SetFunctionBreakpointsArguments { breakpoints };
 
         let response = self
-            .send_request("continue", Some(serde_json::to_value(args)?))
+            .send_request("setFunctionBreakpoints", Some(serde_json::to_value(args)?))
             .await?;
 
         if !response.success {
             return Err(Error::Dap(format!(
-                "Continue failed: {:?}",
+                "SetFunctionBreakpoints failed: {:?}",
                 response.message
             )));
         }
 
-        Ok(())
+        #[derive(serde::Deserialize)]
+        struct SetFunctionBreakpointsResponse {
+            breakpoints: Vec<Breakpoint>,
+        }
+
+        let body: SetFunctionBreakpointsResponse = response
+            .body
+            .ok_or_else(|| Error::Dap("No breakpoints in response".to_string()))
+            .and_then(|v| {
+                serde_json::from_value(v)
+                    .map_err(|e| Error::Dap(format!("Failed to parse breakpoints: {}", e)))
+            })?;
+
+        Ok(body.breakpoints)
     }
 
-    /// Find the first executable line in a Ruby source file
-    ///
-    /// Skips comments, empty lines, requires, and class/module definitions
-    /// to find the first actual executable line.
+    /// Replace all instruction breakpoints
     ///
-    /// Returns line number (1-indexed) or 1 as fallback.
-    fn find_first_executable_line_ruby(program_path: &str) -> usize {
-        use std::fs;
+    /// Like `setBreakpoints`, the request carries the complete set; the
+    /// response lists one breakpoint per requested entry, in order.
+    pub async fn set_instruction_breakpoints(
+        &self,
+        breakpoints: Vec<InstructionBreakpoint>,
+    ) -> Result<Vec<Breakpoint>> {
+        let args = SetInstructionBreakpointsArguments { breakpoints };
 
-        let content = match fs::read_to_string(program_path) {
-            Ok(c) => c,
-            Err(e) => {
-                warn!(
-                    "Could not read {} for line detection: {}, using line 1",
-                    program_path, e
-                );
-                return 1;
-            }
-        };
+        let response = self
+            .send_request(
+                "setInstructionBreakpoints",
+                Some(serde_json::to_value(args)?),
+            )
+            .await?;
 
-        for (line_num, line) in content.lines().enumerate() {
-            let trimmed = line.trim();
+        if !response.success {
+            return Err(Error::Dap(format!(
+                "SetInstructionBreakpoints failed: {:?}",
+                response.message
+            )));
+        }
 
-            // Skip empty lines
-            if trimmed.is_empty() {
-                continue;
-            }
+        #[derive(serde::Deserialize)]
+        struct SetInstructionBreakpointsResponse {
+            breakpoints: Vec<Breakpoint>,
+        }
 
-            // Skip shebang
-            if line_num == 0 && trimmed.starts_with("#!") {
-                continue;
-            }
+        let body: SetInstructionBreakpointsResponse = response
+            .body
+            .ok_or_else(|| Error::Dap("No breakpoints in response".to_string()))
+            .and_then(|v| {
+                serde_json::from_value(v)
+                    .map_err(|e| Error::Dap(format!("Failed to parse breakpoints: {}", e)))
+            })?;
 
-            // Skip comments
-            if trimmed.starts_with('#') {
-                continue;
-            }
+        Ok(body.breakpoints)
+    }
 
-            // Skip requires/loads (not executable, just declarations)
-            if trimmed.starts_with("require") || trimmed.starts_with("load") {
-                continue;
-            }
+    /// Replace the enabled exception breakpoint filters, and the exception
+    /// options when given (adapters with `supportsExceptionOptions` only)
+    pub async fn set_exception_breakpoints(
+        &self,
+        filters: Vec<String>,
+        exception_options: Option<Vec<ExceptionOptions>>,
+    ) -> Result<()> {
+        let args = SetExceptionBreakpointsArguments {
+            filters,
+            exception_options,
+        };
 
-            // Skip class/module/def declarations (not entry point)
-            if trimmed.starts_with("class ") || trimmed.starts_with("module ") {
-                // Continue looking inside the class for executable code
-                continue;
-            }
+        let response = self
+            .send_request("setExceptionBreakpoints", Some(serde_json::to_value(args)?))
+            .await?;
 
-            // Found first executable line!
-            info!("  First executable line detected: {}", line_num + 1);
-            return line_num + 1; // DAP uses 1-indexed lines
+        if !response.success {
+            return Err(Error::Dap(format!(
+                "SetExceptionBreakpoints failed: {:?}",
+                response.message
+            )));
         }
 
-        // Fallback: No executable line found, use line 1
-        warn!("No executable line found in {}, using line 1", program_path);
-        1
+        Ok(())
+    }
+
+    /// Details of the exception a thread stopped on
+    pub async fn exception_info(&self, thread_id: i32) -> Result<ExceptionInfo> {
+        let args = ExceptionInfoArguments { thread_id };
+
+        let response = self
+            .send_request("exceptionInfo", Some(serde_json::to_value(args)?))
+            .await?;
+
+        if !response.success {
+            return Err(Error::Dap(format!(
+                "ExceptionInfo failed: {:?}",
+                response.message
+            )));
+        }
+
+        response
+            .body
+            .ok_or_else(|| Error::Dap("No body in exceptionInfo response".to_string()))
+            .and_then(|v| {
+                serde_json::from_value(v)
+                    .map_err(|e| Error::Dap(format!("Failed to parse exception info: {}", e)))
+            })
+    }
+
+    /// Disassemble `instruction_count` instructions starting `instruction_offset`
+    /// instructions away from `memory_reference`
+    ///
+    /// Adapters pad the result with invalid instructions where memory can't
+    /// be read, so it always has the requested length.
+    pub async fn disassemble(
+        &self,
+        memory_reference: &str,
+        instruction_offset: i64,
+        instruction_count: i64,
+    ) -> Result<Vec<DisassembledInstruction>> {
+        let args = DisassembleArguments {
+            memory_reference: memory_reference.to_string(),
+            offset: None,
+            instruction_offset: Some(instruction_offset),
+            instruction_count,
+            resolve_symbols: Some(true),
+        };
+
+        let response = self
+            .send_request("disassemble", Some(serde_json::to_value(args)?))
+            .await?;
+
+        if !response.success {
+            return Err(Error::Dap(format!(
+                "Disassemble failed: {:?}",
+                response.message
+            )));
+        }
+
+        #[derive(serde::Deserialize)]
+        struct DisassembleResponse {
+            instructions: Vec<DisassembledInstruction>,
+        }
+
+        let body: DisassembleResponse = response
+            .body
+            .ok_or_else(|| Error::Dap("No instructions in response".to_string()))
+            .and_then(|v| {
+                serde_json::from_value(v)
+                    .map_err(|e| Error::Dap(format!("Failed to parse instructions: {}", e)))
+            })?;
+
+        Ok(body.instructions)
+    }
+
+    /// Lines in `line..=end_line` of a source where a breakpoint can be set
+    pub async fn breakpoint_locations(
+        &self,
+        source: Source,
+        line: i32,
+        end_line: i32,
+    ) -> Result<Vec<i32>> {
+        let args = BreakpointLocationsArguments {
+            source,
+            line,
+            end_line: Some(end_line),
+        };
+
+        let response = self
+            .send_request("breakpointLocations", Some(serde_json::to_value(args)?))
+            .await?;
+
+        if !response.success {
+            return Err(Error::Dap(format!(
+                "BreakpointLocations failed: {:?}",
+                response.message
+            )));
+        }
+
+        #[derive(serde::Deserialize)]
+        struct Location {
+            line: i32,
+        }
+        #[derive(serde::Deserialize)]
+        struct BreakpointLocationsResponse {
+            breakpoints: Vec<Location>,
+        }
+
+        let body: BreakpointLocationsResponse = response
+            .body
+            .ok_or_else(|| Error::Dap("No breakpoint locations in response".to_string()))
+            .and_then(|v| {
+                serde_json::from_value(v)
+                    .map_err(|e| Error::Dap(format!("Failed to parse breakpoint locations: {}", e)))
+            })?;
+
+        let mut lines: Vec<i32> = body.breakpoints.into_iter().map(|l| l.line).collect();
+        lines.dedup();
+        Ok(lines)
+    }
+
+    /// Resume a thread
+    ///
+    /// Returns whether the adapter resumed all threads (`allThreadsContinued`,
+    /// which defaults to true when omitted).
+    pub async fn continue_execution(&self, thread_id: i32) -> Result<bool> {
+        let args = ContinueArguments { thread_id };
+
+        let response = self
+            .send_request("continue", Some(serde_json::to_value(args)?))
+            .await?;
+
+        if !response.success {
+            return Err(Error::Dap(format!(
+                "Continue failed: {:?}",
+                response.message
+            )));
+        }
+
+        let all_threads_continued = response
+            .body
+            .as_ref()
+            .and_then(|body| body.get("allThreadsContinued"))
+            .and_then(|v| v.as_bool())
+            .unwrap_or(true);
+
+        Ok(all_threads_continued)
     }
 
     /// Find the first executable line in a Go source file
@@ -1217,8 +1835,15 @@ impl DapClient {
         1
     }
 
-    pub async fn next(&self, thread_id: i32) -> Result<()> {
-        let args = NextArguments { thread_id };
+    pub async fn next(
+        &self,
+        thread_id: i32,
+        granularity: Option<SteppingGranularity>,
+    ) -> Result<()> {
+        let args = NextArguments {
+            thread_id,
+            granularity,
+        };
 
         let response = self
             .send_request("next", Some(serde_json::to_value(args)?))
@@ -1234,8 +1859,15 @@ impl DapClient {
         Ok(())
     }
 
-    pub async fn step_in(&self, thread_id: i32) -> Result<()> {
-        let args = StepInArguments { thread_id };
+    pub async fn step_in(
+        &self,
+        thread_id: i32,
+        granularity: Option<SteppingGranularity>,
+    ) -> Result<()> {
+        let args = StepInArguments {
+            thread_id,
+            granularity,
+        };
 
         let response = self
             .send_request("stepIn", Some(serde_json::to_value(args)?))
@@ -1248,8 +1880,15 @@ impl DapClient {
         Ok(())
     }
 
-    pub async fn step_out(&self, thread_id: i32) -> Result<()> {
-        let args = StepOutArguments { thread_id };
+    pub async fn step_out(
+        &self,
+        thread_id: i32,
+        granularity: Option<SteppingGranularity>,
+    ) -> Result<()> {
+        let args = StepOutArguments {
+            thread_id,
+            granularity,
+        };
 
         let response = self
             .send_request("stepOut", Some(serde_json::to_value(args)?))
@@ -1265,11 +1904,70 @@ impl DapClient {
         Ok(())
     }
 
+    pub async fn pause(&self, thread_id: i32) -> Result<()> {
+        let args = PauseArguments { thread_id };
+
+        let response = self
+            .send_request("pause", Some(serde_json::to_value(args)?))
+            .await?;
+
+        if !response.success {
+            return Err(Error::Dap(format!("Pause failed: {:?}", response.message)));
+        }
+
+        Ok(())
+    }
+
+    /// Top frames of a thread's stack, at most `DEFAULT_STACK_LEVELS` of them
     pub async fn stack_trace(&self, thread_id: i32) -> Result<Vec<StackFrame>> {
+        let (frames, _) = self
+            .stack_trace_page(thread_id, 0, DEFAULT_STACK_LEVELS)
+            .await?;
+        Ok(frames)
+    }
+
+    /// The top frame of a thread's stack, fetched alone where the adapter
+    /// pages stacks: what stop handling needs, without the frames below
+    pub async fn top_frame(&self, thread_id: i32) -> Result<Option<StackFrame>> {
+        let (frames, _) = self.stack_trace_page(thread_id, 0, 1).await?;
+        Ok(frames.into_iter().next())
+    }
+
+    /// Whether the adapter takes `startFrame` and `levels` on `stackTrace`
+    /// (`supportsDelayedStackTraceLoading`); `before_initialize` until it
+    /// has said
+    pub async fn pages_stack_traces(&self, before_initialize: bool) -> bool {
+        match self.capabilities.read().await.as_ref() {
+            Some((adapter_id, caps)) => {
+                capabilities::is_supported(adapter_id, caps, "supportsDelayedStackTraceLoading")
+            }
+            None => before_initialize,
+        }
+    }
+
+    #[cfg(test)]
+    pub(crate) async fn set_capabilities(&self, adapter_id: &str, caps: Capabilities) {
+        *self.capabilities.write().await = Some((adapter_id.to_string(), caps));
+    }
+
+    /// `levels` frames of a thread's stack starting at `start_frame`, plus the
+    /// stack depth if the adapter reports it
+    ///
+    /// Stacks are requested in pages where the adapter supports it: the
+    /// whole stack of a runaway recursion can be tens of thousands of frames.
+    /// Other adapters may ignore `startFrame` and `levels`, so they are asked
+    /// for the whole stack, which is cut to the page here.
+    pub async fn stack_trace_page(
+        &self,
+        thread_id: i32,
+        start_frame: i32,
+        levels: i32,
+    ) -> Result<(Vec<StackFrame>, Option<i32>)> {
+        let paged = self.pages_stack_traces(true).await;
         let args = StackTraceArguments {
             thread_id,
-            start_frame: None,
-            levels: None,
+            start_frame: paged.then_some(start_frame),
+            levels: paged.then_some(levels),
         };
 
         let response = self
@@ -1287,6 +1985,8 @@ impl DapClient {
         struct StackTraceResponse {
             #[serde(rename = "stackFrames")]
             stack_frames: Vec<StackFrame>,
+            #[serde(rename = "totalFrames")]
+            total_frames: Option<i32>,
         }
 
         let body: StackTraceResponse = response
@@ -1297,21 +1997,192 @@ impl DapClient {
                     .map_err(|e| Error::Dap(format!("Failed to parse stack frames: {}", e)))
             })?;
 
-        Ok(body.stack_frames)
+        if !paged {
+            return Ok(page_of_stack(body.stack_frames, start_frame, levels));
+        }
+        Ok((body.stack_frames, body.total_frames))
+    }
+
+    pub async fn threads(&self) -> Result<Vec<Thread>> {
+        let response = self.send_request("threads", None).await?;
+
+        if !response.success {
+            return Err(Error::Dap(format!(
+                "Threads failed: {:?}",
+                response.message
+            )));
+        }
+
+        #[derive(serde::Deserialize)]
+        struct ThreadsResponse {
+            threads: Vec<Thread>,
+        }
+
+        let body: ThreadsResponse = response
+            .body
+            .ok_or_else(|| Error::Dap("No threads in response".to_string()))
+            .and_then(|v| {
+                serde_json::from_value(v)
+                    .map_err(|e| Error::Dap(format!("Failed to parse threads: {}", e)))
+            })?;
+
+        Ok(body.threads)
+    }
+
+    pub async fn scopes(&self, frame_id: i32) -> Result<Vec<Scope>> {
+        let args = ScopesArguments { frame_id };
+
+        let response = self
+            .send_request("scopes", Some(serde_json::to_value(args)?))
+            .await?;
+
+        if !response.success {
+            return Err(Error::Dap(format!("Scopes failed: {:?}", response.message)));
+        }
+
+        #[derive(serde::Deserialize)]
+        struct ScopesResponse {
+            scopes: Vec<Scope>,
+        }
+
+        let body: ScopesResponse = response
+            .body
+            .ok_or_else(|| Error::Dap("No scopes in response".to_string()))
+            .and_then(|v| {
+                serde_json::from_value(v)
+                    .map_err(|e| Error::Dap(format!("Failed to parse scopes: {}", e)))
+            })?;
+
+        Ok(body.scopes)
+    }
+
+    pub async fn variables(&self, variables_reference: i32) -> Result<Vec<Variable>> {
+        self.request_variables(VariablesArguments {
+            variables_reference,
+            filter: None,
+            start: None,
+            count: None,
+        })
+        .await
+    }
+
+    /// `count` indexed children (elements) of a variable, from index `start`
+    pub async fn indexed_variables(
+        &self,
+        variables_reference: i32,
+        start: i64,
+        count: i64,
+    ) -> Result<Vec<Variable>> {
+        self.request_variables(VariablesArguments {
+            variables_reference,
+            filter: Some("indexed".to_string()),
+            start: Some(start),
+            count: Some(count),
+        })
+        .await
+    }
+
+    async fn request_variables(&self, args: VariablesArguments) -> Result<Vec<Variable>> {
+        let response = self
+            .send_request("variables", Some(serde_json::to_value(args)?))
+            .await?;
+
+        if !response.success {
+            return Err(Error::Dap(format!(
+                "Variables failed: {:?}",
+                response.message
+            )));
+        }
+
+        #[derive(serde::Deserialize)]
+        struct VariablesResponse {
+            variables: Vec<Variable>,
+        }
+
+        let body: VariablesResponse = response
+            .body
+            .ok_or_else(|| Error::Dap("No variables in response".to_string()))
+            .and_then(|v| {
+                serde_json::from_value(v)
+                    .map_err(|e| Error::Dap(format!("Failed to parse variables: {}", e)))
+            })?;
+
+        let mut variables = body.variables;
+        for variable in &mut variables {
+            variable.encoding_lossy = super::encoding::is_lossy(&variable.value);
+        }
+        Ok(variables)
+    }
+
+    /// Fetch the content of a source from the adapter (DAP `source` request)
+    ///
+    /// Adapters identify the source by `sourceReference` when it's non-zero,
+    /// otherwise by path.
+    pub async fn source(&self, source: &Source) -> Result<String> {
+        let args = serde_json::json!({
+            "source": source,
+            "sourceReference": source.source_reference.unwrap_or(0)
+        });
+
+        let response = self.send_request("source", Some(args)).await?;
+
+        if !response.success {
+            return Err(Error::Dap(format!("Source failed: {:?}", response.message)));
+        }
+
+        #[derive(serde::Deserialize)]
+        struct SourceResponse {
+            content: String,
+        }
+
+        let body: SourceResponse = response
+            .body
+            .ok_or_else(|| Error::Dap("No content in source response".to_string()))
+            .and_then(|v| {
+                serde_json::from_value(v)
+                    .map_err(|e| Error::Dap(format!("Failed to parse source: {}", e)))
+            })?;
+
+        Ok(body.content)
     }
 
     pub async fn evaluate(&self, expression: &str, frame_id: Option<i32>) -> Result<String> {
+        // Use "watch" for code expression evaluation, not "repl" (LLDB commands)
+        self.evaluate_in_context(expression, frame_id, "watch")
+            .await
+    }
+
+    /// Evaluate in a specific DAP context (`watch`, `hover`, `repl`, ...)
+    pub async fn evaluate_in_context(
+        &self,
+        expression: &str,
+        frame_id: Option<i32>,
+        context: &str,
+    ) -> Result<String> {
+        self.evaluate_variable(expression, frame_id, context)
+            .await
+            .map(|variable| variable.value)
+    }
+
+    /// Evaluate like `evaluate_in_context`, keeping the result's type and
+    /// variables reference; the variable is named after the expression
+    pub async fn evaluate_variable(
+        &self,
+        expression: &str,
+        frame_id: Option<i32>,
+        context: &str,
+    ) -> Result<Variable> {
         // If frame_id is None, get the top frame from stack trace
         let frame_id = if let Some(id) = frame_id {
             Some(id)
         } else {
             // Get current thread (assume thread 0 for simplicity)
-            match self.stack_trace(0).await {
-                Ok(frames) if !frames.is_empty() => {
-                    info!("📍 Auto-fetched frame_id {} for evaluate", frames[0].id);
-                    Some(frames[0].id)
+            match self.top_frame(0).await {
+                Ok(Some(top)) => {
+                    info!("📍 Auto-fetched frame_id {} for evaluate", top.id);
+                    Some(top.id)
                 }
-                Ok(_) => {
+                Ok(None) => {
                     warn!("⚠️  No stack frames available for evaluate");
                     None
                 }
@@ -1325,7 +2196,7 @@ impl DapClient {
         let args = EvaluateArguments {
             expression: expression.to_string(),
             frame_id,
-            context: Some("watch".to_string()), // Use "watch" for code expression evaluation, not "repl" (LLDB commands)
+            context: Some(context.to_string()),
         };
 
         let response = self
@@ -1340,8 +2211,14 @@ impl DapClient {
         }
 
         #[derive(serde::Deserialize)]
+        #[serde(rename_all = "camelCase")]
         struct EvaluateResponse {
             result: String,
+            #[serde(rename = "type")]
+            type_: Option<String>,
+            #[serde(default)]
+            variables_reference: i32,
+            indexed_variables: Option<i64>,
         }
 
         let body: EvaluateResponse = response
@@ -1352,11 +2229,43 @@ impl DapClient {
                     .map_err(|e| Error::Dap(format!("Failed to parse evaluate result: {}", e)))
             })?;
 
-        Ok(body.result)
+        Ok(Variable {
+            name: expression.to_string(),
+            value: body.result,
+            type_: body.type_,
+            variables_reference: body.variables_reference,
+            indexed_variables: body.indexed_variables,
+            encoding_lossy: false,
+        })
+    }
+
+    /// Restart the debuggee with new launch arguments (DAP `restart`)
+    ///
+    /// Only valid if the adapter reported `supportsRestartRequest`.
+    pub async fn restart(&self, launch_args: Value) -> Result<()> {
+        let response = self
+            .send_request(
+                "restart",
+                Some(serde_json::json!({ "arguments": launch_args })),
+            )
+            .await?;
+
+        if !response.success {
+            return Err(Error::Dap(format!(
+                "Restart failed: {:?}",
+                response.message
+            )));
+        }
+
+        Ok(())
     }
 
     pub async fn disconnect(&self) -> Result<()> {
-        let response = self.send_request("disconnect", None).await?;
+        // A read-only session leaves the process it attached to running
+        let arguments = self
+            .is_read_only()
+            .then(|| serde_json::json!({"terminateDebuggee": false}));
+        let response = self.send_request("disconnect", arguments).await?;
 
         if !response.success {
             warn!("Disconnect failed: {:?}", response.message);
@@ -1367,33 +2276,42 @@ impl DapClient {
 
     // === Timeout Wrappers (Aggressive Timeouts) ===
 
-    /// Initialize with 2 second timeout
+    /// Initialize with the configured timeout (default 2 seconds)
     /// DAP init takes ~100ms normally, 2s = 20x safety margin
     pub async fn initialize_with_timeout(&self, adapter_id: &str) -> Result<Capabilities> {
-        let timeout = std::time::Duration::from_secs(2);
-        info!("⏱️  initialize_with_timeout: Starting with 2s timeout");
+        let timeout = std::time::Duration::from_millis(config::current().timeouts.initialize_ms);
+        info!(
+            "⏱️  initialize_with_timeout: Starting with {:?} timeout",
+            timeout
+        );
 
         tokio::time::timeout(timeout, self.initialize(adapter_id))
             .await
             .map_err(|_| Error::Dap(format!("Initialize timed out after {:?}", timeout)))?
     }
 
-    /// Launch with 5 second timeout
+    /// Launch with the configured timeout (default 5 seconds)
     /// Launch is more complex and may involve file loading
     pub async fn launch_with_timeout(&self, args: Value) -> Result<()> {
-        let timeout = std::time::Duration::from_secs(5);
-        info!("⏱️  launch_with_timeout: Starting with 5s timeout");
+        let timeout = std::time::Duration::from_millis(config::current().timeouts.launch_ms);
+        info!(
+            "⏱️  launch_with_timeout: Starting with {:?} timeout",
+            timeout
+        );
 
         tokio::time::timeout(timeout, self.launch(args))
             .await
             .map_err(|_| Error::Dap(format!("Launch timed out after {:?}", timeout)))?
     }
 
-    /// Disconnect with 2 second timeout (force cleanup)
+    /// Disconnect with the configured timeout (default 2 seconds, force cleanup)
     /// If disconnect hangs, we want to fail fast and let process cleanup handle it
     pub async fn disconnect_with_timeout(&self) -> Result<()> {
-        let timeout = std::time::Duration::from_secs(2);
-        info!("⏱️  disconnect_with_timeout: Starting with 2s timeout");
+        let timeout = std::time::Duration::from_millis(config::current().timeouts.disconnect_ms);
+        info!(
+            "⏱️  disconnect_with_timeout: Starting with {:?} timeout",
+            timeout
+        );
 
         tokio::time::timeout(timeout, self.disconnect())
             .await
@@ -1406,7 +2324,8 @@ impl DapClient {
             })?
     }
 
-    /// Initialize and launch with combined timeout (2s + 5s = 7s total)
+    /// Initialize and launch with the combined configured timeout
+    /// (initialize + launch, 2s + 5s = 7s by default)
     /// This wraps the entire sequence with aggressive timeouts
     pub async fn initialize_and_launch_with_timeout(
         &self,
@@ -1419,6 +2338,7 @@ impl DapClient {
             launch_args,
             adapter_type,
             HashMap::new(),
+            Vec::new(),
         )
         .await
     }
@@ -1429,9 +2349,14 @@ impl DapClient {
         launch_args: Value,
         adapter_type: Option<&str>,
         pending_breakpoints: HashMap<String, Vec<SourceBreakpoint>>,
+        pending_function_breakpoints: Vec<FunctionBreakpoint>,
     ) -> Result<()> {
-        let timeout = std::time::Duration::from_secs(7);
-        info!("⏱️  initialize_and_launch_with_timeout: Starting with 7s timeout");
+        let timeouts = &config::current().timeouts;
+        let timeout = std::time::Duration::from_millis(timeouts.initialize_ms + timeouts.launch_ms);
+        info!(
+            "⏱️  initialize_and_launch_with_timeout: Starting with {:?} timeout",
+            timeout
+        );
         if let Some(atype) = adapter_type {
             info!("   Adapter type: {}", atype);
         }
@@ -1443,6 +2368,7 @@ impl DapClient {
                 launch_args,
                 adapter_type,
                 pending_breakpoints,
+                pending_function_breakpoints,
             ),
         )
         .await
@@ -1455,6 +2381,21 @@ impl DapClient {
     }
 }
 
+/// The `levels` frames from `start_frame` of a whole stack, and its depth
+fn page_of_stack(
+    frames: Vec<StackFrame>,
+    start_frame: i32,
+    levels: i32,
+) -> (Vec<StackFrame>, Option<i32>) {
+    let total = frames.len() as i32;
+    let page = frames
+        .into_iter()
+        .skip(start_frame.max(0) as usize)
+        .take(levels.max(0) as usize)
+        .collect();
+    (page, Some(total))
+}
+
 #[cfg(test)]
 mod tests {
     use super::super::transport_trait::DapTransportTrait;
@@ -1491,6 +2432,39 @@ mod tests {
         mock
     }
 
+    #[tokio::test]
+    async fn test_early_output_is_replayed() {
+        let mut mock_transport = MockTestTransport::new();
+        mock_transport.expect_read_message().times(1).returning(|| {
+            Ok(Message::Event(Event {
+                seq: 1,
+                event: "output".to_string(),
+                body: Some(json!({"category": "stderr", "output": "go: build failed\n"})),
+            }))
+        });
+        mock_transport
+            .expect_read_message()
+            .returning(|| Err(Error::Dap("Connection closed".to_string())));
+
+        let client = DapClient::new_with_transport(Box::new(mock_transport), None)
+            .await
+            .unwrap();
+        tokio::time::sleep(tokio::time::Duration::from_millis(50)).await;
+
+        let received = Arc::new(std::sync::Mutex::new(Vec::new()));
+        let sink = received.clone();
+        client
+            .on_event("output", move |event| sink.lock().unwrap().push(event.body))
+            .await;
+        assert_eq!(
+            *received.lock().unwrap(),
+            vec![Some(
+                json!({"category": "stderr", "output": "go: build failed\n"})
+            )]
+        );
+        assert!(client.early_output.lock().unwrap().is_empty());
+    }
+
     #[tokio::test]
     async fn test_dap_client_initialize() {
         let mut mock_transport = MockTestTransport::new();
@@ -1604,6 +2578,7 @@ mod tests {
             name: Some("test.py".to_string()),
             path: Some("/path/to/test.py".to_string()),
             source_reference: None,
+            checksums: None,
         };
 
         let breakpoints = vec![SourceBreakpoint {
@@ -1611,6 +2586,7 @@ mod tests {
             column: None,
             condition: None,
             hit_condition: None,
+            log_message: None,
         }];
 
         let result = client.set_breakpoints(source, breakpoints).await.unwrap();
@@ -1635,7 +2611,25 @@ mod tests {
             .await
             .unwrap();
 
-        client.continue_execution(1).await.unwrap();
+        assert!(client.continue_execution(1).await.unwrap());
+    }
+
+    #[tokio::test]
+    async fn test_dap_client_continue_single_thread() {
+        let mock_transport = create_mock_with_response(Response {
+            seq: 1,
+            request_seq: 1,
+            command: "continue".to_string(),
+            success: true,
+            message: None,
+            body: Some(json!({"allThreadsContinued": false})),
+        });
+
+        let client = DapClient::new_with_transport(Box::new(mock_transport), None)
+            .await
+            .unwrap();
+
+        assert!(!client.continue_execution(1).await.unwrap());
     }
 
     #[tokio::test]
@@ -1669,6 +2663,33 @@ mod tests {
         assert_eq!(frames[0].line, 42);
     }
 
+    #[tokio::test]
+    async fn test_dap_client_threads() {
+        let mock_transport = create_mock_with_response(Response {
+            seq: 1,
+            request_seq: 1,
+            command: "threads".to_string(),
+            success: true,
+            message: None,
+            body: Some(json!({
+                "threads": [
+                    { "id": 1, "name": "MainThread" },
+                    { "id": 2, "name": "worker" }
+                ]
+            })),
+        });
+
+        let client = DapClient::new_with_transport(Box::new(mock_transport), None)
+            .await
+            .unwrap();
+
+        let threads = client.threads().await.unwrap();
+
+        assert_eq!(threads.len(), 2);
+        assert_eq!(threads[0].name, "MainThread");
+        assert_eq!(threads[1].id, 2);
+    }
+
     #[tokio::test]
     async fn test_dap_client_evaluate() {
         let mock_transport = create_mock_with_response(Response {
@@ -1726,4 +2747,642 @@ mod tests {
 
         client.disconnect().await.unwrap();
     }
+
+    type RecordedRequests = Arc<tokio::sync::Mutex<Vec<(String, Option<Value>)>>>;
+
+    /// Fake rdbg over TCP: answers the handshake and records the order of requests
+    async fn run_fake_rdbg(listener: tokio::net::TcpListener, commands: RecordedRequests) {
+        use crate::dap::transport::DapTransport;
+
+        let (stream, _) = listener.accept().await.unwrap();
+        // Send header and body together, like rdbg does
+        stream.set_nodelay(true).unwrap();
+        let mut transport = DapTransport::new_socket(stream);
+        let mut seq = 1000;
+        let mut launch_seq = None;
+
+        while let Ok(Message::Request(req)) = transport.read_message().await {
+            commands
+                .lock()
+                .await
+                .push((req.command.clone(), req.arguments.clone()));
+            seq += 1;
+
+            let body = match req.command.as_str() {
+                "initialize" => Some(json!({"supportsConfigurationDoneRequest": true})),
+                "launch" => {
+                    // rdbg answers launch only after configurationDone
+                    launch_seq = Some(req.seq);
+                    transport
+                        .write_message(&Message::Event(Event {
+                            seq,
+                            event: "initialized".to_string(),
+                            body: None,
+                        }))
+                        .await
+                        .unwrap();
+                    continue;
+                }
+                "setBreakpoints" => {
+                    let lines: Vec<Value> = req.arguments.as_ref().unwrap()["breakpoints"]
+                        .as_array()
+                        .unwrap()
+                        .iter()
+                        .map(|bp| json!({"verified": true, "line": bp["line"]}))
+                        .collect();
+                    Some(json!({ "breakpoints": lines }))
+                }
+                _ => None,
+            };
+
+            transport
+                .write_message(&Message::Response(Response {
+                    seq,
+                    request_seq: req.seq,
+                    command: req.command.clone(),
+                    success: true,
+                    message: None,
+                    body,
+                }))
+                .await
+                .unwrap();
+
+            if req.command == "configurationDone" {
+                if let Some(request_seq) = launch_seq.take() {
+                    transport
+                        .write_message(&Message::Response(Response {
+                            seq: seq + 1,
+                            request_seq,
+                            command: "launch".to_string(),
+                            success: true,
+                            message: None,
+                            body: None,
+                        }))
+                        .await
+                        .unwrap();
+                }
+            }
+        }
+    }
+
+    /// Fake adapter for the launch handshake: sends `initialized` either ahead
+    /// of its initialize response (`early`) or after the launch request, and
+    /// answers launch after configurationDone
+    async fn run_fake_handshake_adapter(
+        listener: tokio::net::TcpListener,
+        commands: RecordedRequests,
+        early: bool,
+    ) {
+        use crate::dap::transport::DapTransport;
+
+        let (stream, _) = listener.accept().await.unwrap();
+        // Send header and body together
+        stream.set_nodelay(true).unwrap();
+        let mut transport = DapTransport::new_socket(stream);
+        let mut seq = 1000;
+        let mut launch_seq = None;
+        let initialized = |seq| {
+            Message::Event(Event {
+                seq,
+                event: "initialized".to_string(),
+                body: None,
+            })
+        };
+
+        while let Ok(Message::Request(req)) = transport.read_message().await {
+            commands
+                .lock()
+                .await
+                .push((req.command.clone(), req.arguments.clone()));
+            seq += 1;
+
+            let body = match req.command.as_str() {
+                "initialize" => {
+                    if early {
+                        seq += 1;
+                        transport.write_message(&initialized(seq)).await.unwrap();
+                    }
+                    Some(json!({"supportsConfigurationDoneRequest": true}))
+                }
+                "launch" => {
+                    launch_seq = Some(req.seq);
+                    if !early {
+                        transport.write_message(&initialized(seq)).await.unwrap();
+                    }
+                    continue;
+                }
+                _ => None,
+            };
+
+            transport
+                .write_message(&Message::Response(Response {
+                    seq,
+                    request_seq: req.seq,
+                    command: req.command.clone(),
+                    success: true,
+                    message: None,
+                    body,
+                }))
+                .await
+                .unwrap();
+
+            if req.command == "configurationDone" {
+                if let Some(request_seq) = launch_seq.take() {
+                    seq += 1;
+                    transport
+                        .write_message(&Message::Response(Response {
+                            seq,
+                            request_seq,
+                            command: "launch".to_string(),
+                            success: true,
+                            message: None,
+                            body: None,
+                        }))
+                        .await
+                        .unwrap();
+                }
+            }
+        }
+    }
+
+    #[tokio::test]
+    async fn test_initialized_event_in_either_order() {
+        for early in [true, false] {
+            let listener = tokio::net::TcpListener::bind("127.0.0.1:0").await.unwrap();
+            let port = listener.local_addr().unwrap().port();
+            let commands = Arc::new(tokio::sync::Mutex::new(Vec::new()));
+            tokio::spawn(run_fake_handshake_adapter(
+                listener,
+                commands.clone(),
+                early,
+            ));
+
+            let socket = tokio::net::TcpStream::connect(("127.0.0.1", port))
+                .await
+                .unwrap();
+            let client = DapClient::from_socket(socket).await.unwrap();
+            tokio::time::timeout(
+                std::time::Duration::from_secs(2),
+                client.initialize_and_launch("fake", json!({"program": "/w/app"}), None),
+            )
+            .await
+            .unwrap_or_else(|_| panic!("handshake stalled (initialized early: {})", early))
+            .unwrap();
+
+            let commands = commands.lock().await;
+            let names: Vec<&str> = commands.iter().map(|(c, _)| c.as_str()).collect();
+            assert_eq!(
+                names,
+                vec!["initialize", "launch", "configurationDone"],
+                "initialized early: {}",
+                early
+            );
+        }
+    }
+
+    /// Fake adapter that numbers lines and columns from 0 and says so
+    async fn run_fake_zero_based_adapter(
+        listener: tokio::net::TcpListener,
+        commands: RecordedRequests,
+    ) {
+        use crate::dap::transport::DapTransport;
+
+        let (stream, _) = listener.accept().await.unwrap();
+        // Send header and body together
+        stream.set_nodelay(true).unwrap();
+        let mut transport = DapTransport::new_socket(stream);
+        let mut seq = 1000;
+
+        while let Ok(Message::Request(req)) = transport.read_message().await {
+            commands
+                .lock()
+                .await
+                .push((req.command.clone(), req.arguments.clone()));
+            seq += 1;
+
+            let body = match req.command.as_str() {
+                "initialize" => Some(json!({
+                    "supportsConfigurationDoneRequest": true,
+                    "linesStartAt1": false,
+                    "columnsStartAt1": false
+                })),
+                "setBreakpoints" => {
+                    let breakpoints: Vec<Value> = req.arguments.as_ref().unwrap()["breakpoints"]
+                        .as_array()
+                        .unwrap()
+                        .iter()
+                        .map(|bp| json!({"verified": true, "line": bp["line"], "column": 0}))
+                        .collect();
+                    Some(json!({ "breakpoints": breakpoints }))
+                }
+                "stackTrace" => Some(json!({
+                    "stackFrames": [{
+                        "id": 1,
+                        "name": "main",
+                        "source": {"path": "/w/app.js"},
+                        "line": 9,
+                        "column": 4
+                    }],
+                    "totalFrames": 1
+                })),
+                _ => None,
+            };
+
+            transport
+                .write_message(&Message::Response(Response {
+                    seq,
+                    request_seq: req.seq,
+                    command: req.command.clone(),
+                    success: true,
+                    message: None,
+                    body,
+                }))
+                .await
+                .unwrap();
+        }
+    }
+
+    #[tokio::test]
+    async fn test_zero_based_adapter_positions_converted() {
+        let listener = tokio::net::TcpListener::bind("127.0.0.1:0").await.unwrap();
+        let port = listener.local_addr().unwrap().port();
+        let commands = Arc::new(tokio::sync::Mutex::new(Vec::new()));
+        tokio::spawn(run_fake_zero_based_adapter(listener, commands.clone()));
+
+        let socket = tokio::net::TcpStream::connect(("127.0.0.1", port))
+            .await
+            .unwrap();
+        let client = DapClient::from_socket(socket).await.unwrap();
+        client.initialize("pwa-node").await.unwrap();
+        assert!(!client.positions().await.lines_start_at1);
+
+        let source = Source {
+            name: None,
+            path: Some("/w/app.js".to_string()),
+            source_reference: None,
+            checksums: None,
+        };
+        let breakpoint = SourceBreakpoint {
+            line: 10,
+            column: None,
+            condition: None,
+            hit_condition: None,
+            log_message: None,
+        };
+        let set = client
+            .set_breakpoints(source, vec![breakpoint])
+            .await
+            .unwrap();
+        assert_eq!(set[0].line, Some(10));
+        assert_eq!(set[0].column, Some(1));
+
+        let frames = client.stack_trace(1).await.unwrap();
+        assert_eq!((frames[0].line, frames[0].column), (10, 5));
+        // Without supportsDelayedStackTraceLoading the whole stack is asked for
+        let (page, total) = client.stack_trace_page(1, 1, 10).await.unwrap();
+        assert!(page.is_empty());
+        assert_eq!(total, Some(1));
+
+        // The adapter saw our 1-based preference and its own numbering
+        let commands = commands.lock().await;
+        let initialize = commands[0].1.as_ref().unwrap();
+        assert_eq!(initialize["linesStartAt1"], true);
+        assert_eq!(initialize["columnsStartAt1"], true);
+        assert_eq!(commands[1].1.as_ref().unwrap()["breakpoints"][0]["line"], 9);
+        let stack_trace = commands[2].1.as_ref().unwrap();
+        assert!(stack_trace.get("levels").is_none());
+        assert!(stack_trace.get("startFrame").is_none());
+    }
+
+    #[tokio::test]
+    async fn test_rdbg_breakpoints_sent_before_configuration_done() {
+        let dir = tempfile::tempdir().unwrap();
+        let script = dir.path().join("app.rb");
+        std::fs::write(&script, "require 'json'\n\nputs 'hi'\nputs 'bye'\n").unwrap();
+        let script = script.to_str().unwrap().to_string();
+
+        let listener = tokio::net::TcpListener::bind("127.0.0.1:0").await.unwrap();
+        let port = listener.local_addr().unwrap().port();
+        let commands = Arc::new(tokio::sync::Mutex::new(Vec::new()));
+        tokio::spawn(run_fake_rdbg(listener, commands.clone()));
+
+        let socket = tokio::net::TcpStream::connect(("127.0.0.1", port))
+            .await
+            .unwrap();
+        let client = DapClient::from_socket(socket).await.unwrap();
+
+        let mut pending = HashMap::new();
+        pending.insert(
+            script.clone(),
+            vec![SourceBreakpoint {
+                line: 4,
+                column: None,
+                condition: None,
+                hit_condition: None,
+                log_message: None,
+            }],
+        );
+        let launch_args =
+            crate::adapters::ruby::RubyAdapter::launch_args_with_options(&script, &[], None, true);
+
+        client
+            .initialize_and_launch_with_pending(
+                "rdbg",
+                launch_args,
+                Some("ruby"),
+                pending,
+                Vec::new(),
+            )
+            .await
+            .unwrap();
+
+        let commands = commands.lock().await;
+        let names: Vec<&str> = commands.iter().map(|(c, _)| c.as_str()).collect();
+        assert_eq!(
+            names,
+            vec![
+                "initialize",
+                "launch",
+                "setBreakpoints",
+                "setBreakpoints",
+                "configurationDone"
+            ]
+        );
+
+        // stopOnEntry is emulated with an entry breakpoint on the first executable line
+        let launch = commands[1].1.as_ref().unwrap();
+        assert_eq!(launch["stopOnEntry"], false);
+        let entry = commands[3].1.as_ref().unwrap();
+        assert_eq!(entry["breakpoints"][0]["line"], 3);
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_unsupported_requests_fail_without_round_trip() {
+        let listener = tokio::net::TcpListener::bind("127.0.0.1:0").await.unwrap();
+        let port = listener.local_addr().unwrap().port();
+        let commands = Arc::new(tokio::sync::Mutex::new(Vec::new()));
+        tokio::spawn(run_fake_rdbg(listener, commands.clone()));
+
+        let socket = tokio::net::TcpStream::connect(("127.0.0.1", port))
+            .await
+            .unwrap();
+        let client = DapClient::from_socket(socket).await.unwrap();
+        client.initialize("rdbg").await.unwrap();
+
+        let err = client.restart(json!({})).await.unwrap_err();
+        assert!(matches!(
+            err,
+            Error::UnsupportedCapability { ref capability, .. } if capability == "supportsRestartRequest"
+        ));
+
+        let logpoint = SourceBreakpoint {
+            line: 2,
+            column: None,
+            condition: None,
+            hit_condition: None,
+            log_message: Some("x={x}".to_string()),
+        };
+        let source = Source {
+            name: None,
+            path: Some("/w/app.rb".to_string()),
+            source_reference: None,
+            checksums: None,
+        };
+        let err = client
+            .set_breakpoints(source.clone(), vec![logpoint.clone()])
+            .await
+            .unwrap_err();
+        assert!(err.to_string().contains("supportsLogPoints"));
+        // Emulated instead: sent as a plain breakpoint
+        assert!(client.emulates_log_points().await);
+        let native = client.native_breakpoints(vec![logpoint]).await;
+        assert_eq!(native[0].log_message, None);
+
+        let names: Vec<String> = commands
+            .lock()
+            .await
+            .iter()
+            .map(|(c, _)| c.clone())
+            .collect();
+        assert_eq!(names, vec!["initialize"]);
+    }
+
+    /// Fake adapter answering `variables` only after the next `threads`,
+    /// like one working through a huge structure; answers the rest at once
+    async fn run_fake_slow_adapter(listener: tokio::net::TcpListener, commands: RecordedRequests) {
+        use crate::dap::transport::DapTransport;
+
+        let (stream, _) = listener.accept().await.unwrap();
+        stream.set_nodelay(true).unwrap();
+        let mut transport = DapTransport::new_socket(stream);
+        let mut held_back = None;
+        let mut seq = 1000;
+
+        while let Ok(Message::Request(req)) = transport.read_message().await {
+            commands
+                .lock()
+                .await
+                .push((req.command.clone(), req.arguments.clone()));
+            let mut answers = vec![];
+            match req.command.as_str() {
+                "variables" => {
+                    held_back = Some(req.seq);
+                    continue;
+                }
+                "initialize" => answers.push((req, json!({"supportsCancelRequest": true}))),
+                "threads" => {
+                    if let Some(request_seq) = held_back.take() {
+                        let variables = Request {
+                            seq: request_seq,
+                            command: "variables".to_string(),
+                            arguments: None,
+                        };
+                        answers.push((variables, json!({"variables": []})));
+                    }
+                    answers.insert(0, (req, json!({"threads": [{"id": 1, "name": "main"}]})));
+                }
+                _ => answers.push((req, json!({}))),
+            }
+            for (req, body) in answers {
+                seq += 1;
+                transport
+                    .write_message(&Message::Response(Response {
+                        seq,
+                        request_seq: req.seq,
+                        command: req.command,
+                        success: true,
+                        message: None,
+                        body: Some(body),
+                    }))
+                    .await
+                    .unwrap();
+            }
+        }
+    }
+
+    async fn slow_adapter_client() -> (Arc<DapClient>, RecordedRequests) {
+        let listener = tokio::net::TcpListener::bind("127.0.0.1:0").await.unwrap();
+        let port = listener.local_addr().unwrap().port();
+        let commands = Arc::new(tokio::sync::Mutex::new(Vec::new()));
+        tokio::spawn(run_fake_slow_adapter(listener, commands.clone()));
+        let socket = tokio::net::TcpStream::connect(("127.0.0.1", port))
+            .await
+            .unwrap();
+        (
+            Arc::new(DapClient::from_socket(socket).await.unwrap()),
+            commands,
+        )
+    }
+
+    /// Transport whose writes of `variables` requests take `WRITE_DELAY`,
+    /// like a pipe the adapter drains slowly while it works through a huge
+    /// structure; `pause` is answered, everything else is not
+    struct SlowWriteTransport {
+        responses: mpsc::UnboundedSender<Message>,
+        incoming: mpsc::UnboundedReceiver<Message>,
+        written: Arc<std::sync::Mutex<Vec<String>>>,
+    }
+
+    const WRITE_DELAY: Duration = Duration::from_millis(400);
+
+    #[async_trait::async_trait]
+    impl DapTransportTrait for SlowWriteTransport {
+        async fn read_message(&mut self) -> Result<Message> {
+            // recv is cancel-safe, as the reader's polling needs
+            self.incoming
+                .recv()
+                .await
+                .ok_or_else(|| Error::Dap("closed".to_string()))
+        }
+
+        async fn write_message(&mut self, msg: &Message) -> Result<()> {
+            let Message::Request(req) = msg else {
+                return Ok(());
+            };
+            if req.command == "variables" {
+                tokio::time::sleep(WRITE_DELAY).await;
+            }
+            self.written.lock().unwrap().push(req.command.clone());
+            if req.command == "pause" {
+                let _ = self.responses.send(Message::Response(Response {
+                    seq: req.seq + 1000,
+                    request_seq: req.seq,
+                    command: req.command.clone(),
+                    success: true,
+                    message: None,
+                    body: None,
+                }));
+            }
+            Ok(())
+        }
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_pause_is_not_held_up_by_slow_inspection() {
+        let (responses, incoming) = mpsc::unbounded_channel();
+        let written = Arc::new(std::sync::Mutex::new(Vec::new()));
+        let transport = SlowWriteTransport {
+            responses,
+            incoming,
+            written: written.clone(),
+        };
+        let client = Arc::new(
+            DapClient::new_with_transport(Box::new(transport), None)
+                .await
+                .unwrap(),
+        );
+
+        // Five inspections queue up behind the first one's slow write
+        let inspections: Vec<_> = (0..5)
+            .map(|_| {
+                let client = client.clone();
+                tokio::spawn(async move { client.variables(7).await })
+            })
+            .collect();
+        while client.queue_report().depth < 5 {
+            tokio::time::sleep(Duration::from_millis(5)).await;
+        }
+
+        // Written in order, pause would wait for all five writes
+        let start = Instant::now();
+        client.pause(1).await.unwrap();
+        assert!(start.elapsed() < WRITE_DELAY * 4, "{:?}", start.elapsed());
+        // Only the write under way when it was queued went first
+        let written = written.lock().unwrap().clone();
+        assert_eq!(
+            written.get(1).map(String::as_str),
+            Some("pause"),
+            "{:?}",
+            written
+        );
+
+        for inspection in inspections {
+            inspection.abort();
+        }
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_slow_inspection_fails_alone_at_its_deadline() {
+        let (client, commands) = slow_adapter_client().await;
+        client.initialize("fake").await.unwrap();
+        client.set_inspection_timeout(Duration::from_millis(150));
+
+        let start = Instant::now();
+        let err = client.variables(7).await.unwrap_err();
+        assert!(matches!(err, Error::Timeout(_)), "{}", err);
+        assert!(start.elapsed() < Duration::from_secs(1));
+        assert_eq!(client.queue_report().timed_out, 1);
+        // Only the cancel request may still be waiting
+        loop {
+            let report = client.queue_report();
+            if report.depth == 0 {
+                break;
+            }
+            assert_eq!(report.oldest_pending_command.as_deref(), Some("cancel"));
+            tokio::time::sleep(Duration::from_millis(10)).await;
+        }
+
+        // The connection is kept; the late variables answer is dropped
+        client.pause(1).await.unwrap();
+        assert_eq!(client.threads().await.unwrap().len(), 1);
+        let commands = commands.lock().await;
+        let cancel = commands.iter().find(|(c, _)| c == "cancel").unwrap();
+        let variables_seq = commands.iter().position(|(c, _)| c == "variables").unwrap() + 1;
+        assert_eq!(cancel.1.as_ref().unwrap()["requestId"], variables_seq);
+    }
+
+    #[tokio::test]
+    async fn test_urgent_requests_are_written_first() {
+        let written = Arc::new(std::sync::Mutex::new(Vec::new()));
+        let mut transport = MockTestTransport::new();
+        let record = written.clone();
+        transport.expect_write_message().returning(move |msg| {
+            if let Message::Request(req) = msg {
+                record.lock().unwrap().push(req.command.clone());
+            }
+            Ok(())
+        });
+        let transport: Box<dyn DapTransportTrait> = Box::new(transport);
+
+        let (write_tx, write_rx) = mpsc::unbounded_channel();
+        let (urgent_tx, urgent_rx) = mpsc::unbounded_channel();
+        let request = |command: &str| {
+            Message::Request(Request {
+                seq: 1,
+                command: command.to_string(),
+                arguments: None,
+            })
+        };
+        for _ in 0..3 {
+            write_tx.send(request("variables")).unwrap();
+        }
+        urgent_tx.send(request("pause")).unwrap();
+        drop((write_tx, urgent_tx));
+
+        DapClient::message_writer(Arc::new(Mutex::new(transport)), urgent_rx, write_rx).await;
+        assert_eq!(
+            *written.lock().unwrap(),
+            vec!["pause", "variables", "variables", "variables"]
+        );
+    }
 }
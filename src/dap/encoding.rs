@@ -0,0 +1,146 @@
+//! Debuggee text that isn't valid Unicode
+//!
+//! Programs print bytes that aren't UTF-8 and hold strings in other
+//! encodings (Ruby's binary strings, Latin-1 files read as bytes). Adapters
+//! pass such text on in different ways: raw bytes inside a JSON string, or
+//! escapes of lone UTF-16 surrogates (`"\udc80"`, as Python's
+//! `surrogateescape` and JavaScript strings produce), which serde_json
+//! refuses. Either used to fail the whole DAP message.
+//!
+//! Messages are now decoded lossily instead: invalid bytes and lone
+//! surrogates become U+FFFD. A value holding U+FFFD is reported with
+//! `encodingLossy: true`, since its bytes can't be told from the text.
+
+use std::borrow::Cow;
+use tracing::warn;
+
+/// The character invalid bytes and lone surrogates are replaced with
+pub const REPLACEMENT: char = char::REPLACEMENT_CHARACTER;
+
+/// Text of a DAP message body: invalid UTF-8 and escapes of lone
+/// surrogates replaced by U+FFFD
+pub fn decode_message(buffer: Vec<u8>) -> String {
+    let content = match String::from_utf8(buffer) {
+        Ok(content) => content,
+        Err(e) => {
+            warn!(
+                "⚠️  DAP message is not valid UTF-8 ({}); invalid bytes replaced",
+                e.utf8_error()
+            );
+            String::from_utf8_lossy(e.as_bytes()).into_owned()
+        }
+    };
+    match replace_lone_surrogates(&content) {
+        Cow::Borrowed(_) => content,
+        Cow::Owned(replaced) => {
+            warn!("⚠️  DAP message escapes lone surrogates; replaced");
+            replaced
+        }
+    }
+}
+
+/// JSON text with the `\uXXXX` escapes of unpaired surrogates replaced by
+/// `\ufffd`; paired ones (characters beyond the BMP) are kept
+pub fn replace_lone_surrogates(json: &str) -> Cow<'_, str> {
+    if !json.contains("\\u") {
+        return Cow::Borrowed(json);
+    }
+    let bytes = json.as_bytes();
+    let mut replaced: Option<String> = None;
+    let mut copied = 0;
+    let mut i = 0;
+    while i < bytes.len() {
+        if bytes[i] != b'\\' {
+            i += 1;
+            continue;
+        }
+        // An escape: `\\` and `\"` are skipped whole so `\\u` isn't one
+        if bytes.get(i + 1) != Some(&b'u') {
+            i += 2;
+            continue;
+        }
+        let Some(unit) = escaped_unit(bytes, i) else {
+            i += 2;
+            continue;
+        };
+        let paired = match unit {
+            0xD800..=0xDBFF => {
+                matches!(escaped_unit(bytes, i + 6), Some(0xDC00..=0xDFFF))
+            }
+            0xDC00..=0xDFFF => false,
+            _ => {
+                i += 6;
+                continue;
+            }
+        };
+        if paired {
+            i += 12;
+            continue;
+        }
+        let out = replaced.get_or_insert_with(|| String::with_capacity(json.len()));
+        out.push_str(&json[copied..i]);
+        out.push_str("\\ufffd");
+        i += 6;
+        copied = i;
+    }
+    match replaced {
+        Some(mut out) => {
+            out.push_str(&json[copied..]);
+            Cow::Owned(out)
+        }
+        None => Cow::Borrowed(json),
+    }
+}
+
+/// The UTF-16 unit of the `\uXXXX` escape at `at`
+fn escaped_unit(bytes: &[u8], at: usize) -> Option<u32> {
+    if bytes.get(at..at + 2)? != b"\\u" {
+        return None;
+    }
+    let hex = std::str::from_utf8(bytes.get(at + 2..at + 6)?).ok()?;
+    u32::from_str_radix(hex, 16).ok()
+}
+
+/// Whether text had bytes or surrogates replaced (holds U+FFFD)
+pub fn is_lossy(text: &str) -> bool {
+    text.contains(REPLACEMENT)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::Value;
+
+    #[test]
+    fn test_invalid_utf8_is_replaced() {
+        let mut body = b"{\"output\":\"caf".to_vec();
+        body.extend_from_slice(&[0xE9, 0xFF]);
+        body.extend_from_slice(b"\\n\"}");
+        let content = decode_message(body);
+        let value: Value = serde_json::from_str(&content).unwrap();
+        let output = value["output"].as_str().unwrap();
+        assert_eq!(output, "caf\u{FFFD}\u{FFFD}\n");
+        assert!(is_lossy(output));
+    }
+
+    #[test]
+    fn test_lone_surrogates_are_replaced() {
+        for (json, expected) in [
+            (r#""a\udc80b""#, "a\u{FFFD}b"),
+            (r#""\ud800""#, "\u{FFFD}"),
+            (r#""\ud800\u0041""#, "\u{FFFD}A"),
+            (r#""\ud83d\ude00""#, "😀"),
+            (r#""\\udc80""#, "\\udc80"),
+            (r#""\u00e9\n""#, "é\n"),
+        ] {
+            let content = decode_message(json.as_bytes().to_vec());
+            let value: String = serde_json::from_str(&content).unwrap();
+            assert_eq!(value, expected, "{}", json);
+        }
+        assert!(matches!(
+            replace_lone_surrogates(r#"{"a": "\ud83d\ude00"}"#),
+            Cow::Borrowed(_)
+        ));
+        assert!(!is_lossy("plain"));
+    }
+}
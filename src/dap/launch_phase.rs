@@ -0,0 +1,235 @@
+//! Where a session's start is, to debug starts that get stuck
+//!
+//! A start goes through these phases, in order:
+//!
+//! 1. `SpawningAdapter`: starting the adapter process and connecting to it
+//!    (building the program first for Rust)
+//! 2. `Initializing`: the `initialize` request
+//! 3. `WaitingInitializedEvent`: `launch` (or `attach`) sent, until the
+//!    adapter's `initialized` event
+//! 4. `SendingBreakpoints`: breakpoints set before the launch
+//! 5. `ConfigurationDone`: the `configurationDone` request
+//! 6. `WaitingFirstStop`: with stopOnEntry or an entry point, until the
+//!    program first stops
+//!
+//! The client records the phases of the DAP handshake, the session the
+//! others. Each finished phase keeps its duration, which the session's
+//! state reports for successful starts as well; the phase the start is in
+//! is shown while it is in flight and named in the errors of a start that
+//! fails.
+
+use crate::Error;
+use serde::Serialize;
+use std::sync::Mutex;
+use std::time::{Duration, Instant};
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+pub enum LaunchPhase {
+    SpawningAdapter,
+    Initializing,
+    WaitingInitializedEvent,
+    SendingBreakpoints,
+    ConfigurationDone,
+    WaitingFirstStop,
+}
+
+/// A phase and the time spent in it (so far, for the current one)
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct PhaseTiming {
+    pub phase: LaunchPhase,
+    pub elapsed_ms: u64,
+}
+
+impl PhaseTiming {
+    fn new(phase: LaunchPhase, elapsed: Duration) -> Self {
+        Self {
+            phase,
+            elapsed_ms: elapsed.as_millis() as u64,
+        }
+    }
+}
+
+/// The phases of a start so far
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct LaunchReport {
+    /// The phase the start is in; None once it is done
+    pub current: Option<PhaseTiming>,
+    /// Finished phases, in order
+    pub phases: Vec<PhaseTiming>,
+    pub total_ms: u64,
+}
+
+/// The phases of one session's start
+#[derive(Debug, Default)]
+pub struct LaunchPhases {
+    inner: Mutex<Inner>,
+}
+
+#[derive(Debug, Default)]
+struct Inner {
+    current: Option<(LaunchPhase, Instant)>,
+    finished: Vec<PhaseTiming>,
+    /// Set by `finish`; later phases are ignored
+    done: bool,
+}
+
+impl LaunchPhases {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Finish the current phase and start `phase` (unless already in it,
+    /// or the start is done)
+    pub fn enter(&self, phase: LaunchPhase) {
+        let mut inner = self.inner.lock().unwrap();
+        if inner.done || matches!(inner.current, Some((current, _)) if current == phase) {
+            return;
+        }
+        inner.finish();
+        inner.current = Some((phase, Instant::now()));
+    }
+
+    /// Finish the current phase: the start is done, or failed in it
+    ///
+    /// The program may stop before the client is through with the handshake,
+    /// so phases entered after this are ignored.
+    pub fn finish(&self) {
+        let mut inner = self.inner.lock().unwrap();
+        inner.finish();
+        inner.done = true;
+    }
+
+    /// Record `phase` as done before the phases were tracked (spawning the
+    /// adapter, which happens before the session exists)
+    pub fn record_first(&self, phase: LaunchPhase, elapsed: Duration) {
+        self.inner
+            .lock()
+            .unwrap()
+            .finished
+            .insert(0, PhaseTiming::new(phase, elapsed));
+    }
+
+    /// The phase the start is in, if it is in flight
+    pub fn current(&self) -> Option<PhaseTiming> {
+        let inner = self.inner.lock().unwrap();
+        inner
+            .current
+            .map(|(phase, entered)| PhaseTiming::new(phase, entered.elapsed()))
+    }
+
+    /// The phases so far; None before the first
+    pub fn report(&self) -> Option<LaunchReport> {
+        let current = self.current();
+        let inner = self.inner.lock().unwrap();
+        if current.is_none() && inner.finished.is_empty() {
+            return None;
+        }
+        let total_ms = inner
+            .finished
+            .iter()
+            .chain(current.as_ref())
+            .map(|timing| timing.elapsed_ms)
+            .sum();
+        Some(LaunchReport {
+            current,
+            phases: inner.finished.clone(),
+            total_ms,
+        })
+    }
+
+    /// `error` naming the phase the start is in (unchanged when it isn't
+    /// in one)
+    pub fn annotate(&self, error: Error) -> Error {
+        match self.current() {
+            Some(current) => annotate(error, &current),
+            None => error,
+        }
+    }
+}
+
+impl Inner {
+    fn finish(&mut self) {
+        if let Some((phase, entered)) = self.current.take() {
+            self.finished
+                .push(PhaseTiming::new(phase, entered.elapsed()));
+        }
+    }
+}
+
+/// `error` with the phase it happened in appended to its message
+///
+/// Only the errors a stuck start ends with are annotated: timeouts and
+/// failures of the adapter connection or process.
+pub fn annotate(error: Error, phase: &PhaseTiming) -> Error {
+    let note = |message: String| {
+        format!(
+            "{} (launch phase {:?}, after {}ms in it)",
+            message, phase.phase, phase.elapsed_ms
+        )
+    };
+    match error {
+        Error::Dap(message) => Error::Dap(note(message)),
+        Error::Process(message) => Error::Process(note(message)),
+        Error::Timeout(message) => Error::Timeout(note(message)),
+        other => other,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_phases_are_timed_in_order() {
+        let phases = LaunchPhases::new();
+        assert_eq!(phases.report(), None);
+
+        phases.enter(LaunchPhase::Initializing);
+        phases.enter(LaunchPhase::Initializing);
+        std::thread::sleep(Duration::from_millis(20));
+        phases.enter(LaunchPhase::WaitingInitializedEvent);
+        phases.record_first(LaunchPhase::SpawningAdapter, Duration::from_millis(150));
+
+        let report = phases.report().unwrap();
+        assert_eq!(
+            report.current.as_ref().map(|c| c.phase),
+            Some(LaunchPhase::WaitingInitializedEvent)
+        );
+        let order: Vec<_> = report.phases.iter().map(|t| t.phase).collect();
+        assert_eq!(
+            order,
+            vec![LaunchPhase::SpawningAdapter, LaunchPhase::Initializing]
+        );
+        assert!(report.phases[1].elapsed_ms >= 20);
+        assert!(report.total_ms >= 170);
+
+        phases.finish();
+        phases.enter(LaunchPhase::WaitingFirstStop);
+        let report = phases.report().unwrap();
+        assert!(report.current.is_none());
+        assert_eq!(report.phases.len(), 3);
+    }
+
+    #[test]
+    fn test_errors_name_the_current_phase() {
+        let phases = LaunchPhases::new();
+        let err = phases.annotate(Error::Dap("Initialize and launch timed out".to_string()));
+        assert_eq!(
+            err.to_string(),
+            "DAP error: Initialize and launch timed out"
+        );
+
+        phases.enter(LaunchPhase::WaitingInitializedEvent);
+        let err = phases.annotate(Error::Dap("Initialize and launch timed out".to_string()));
+        assert!(
+            err.to_string()
+                .contains("(launch phase WaitingInitializedEvent, after"),
+            "{}",
+            err
+        );
+        let err = phases.annotate(Error::InvalidRequest("bad".to_string()));
+        assert_eq!(err.to_string(), "Invalid request: bad");
+    }
+}
@@ -0,0 +1,285 @@
+//! Request counts and latencies, per session and across sessions
+//!
+//! Every request a `DapClient` sends is timed until its response arrives and
+//! counted by command in a fixed histogram of atomic counters, so recording
+//! takes no lock beyond a read of the command table. Stops, breakpoint hits,
+//! the time from a session's creation to its program's launch and the time
+//! from a stop to its report in the session's state are counted alongside. Each session has its own `Metrics`, which also record
+//! into the server-wide aggregate (`aggregate()`), kept for the life of the
+//! server.
+//!
+//! Percentiles are estimated from the histogram: they are the upper bound
+//! of the bucket the percentile falls in.
+
+use serde::Serialize;
+use std::collections::{BTreeMap, HashMap};
+use std::sync::atomic::{AtomicU64, Ordering};
+use std::sync::{Arc, LazyLock, RwLock};
+use std::time::{Duration, Instant};
+
+/// Upper bounds of the latency buckets, in microseconds; a last bucket
+/// holds everything slower
+const BUCKET_BOUNDS_US: [u64; 16] = [
+    100, 250, 500, 1_000, 2_500, 5_000, 10_000, 25_000, 50_000, 100_000, 250_000, 500_000,
+    1_000_000, 2_500_000, 5_000_000, 10_000_000,
+];
+
+static AGGREGATE: LazyLock<Metrics> = LazyLock::new(|| Metrics {
+    aggregates: false,
+    ..Metrics::new()
+});
+
+/// Metrics of all sessions since the server started
+pub fn aggregate() -> &'static Metrics {
+    &AGGREGATE
+}
+
+/// Latency histogram of one kind of request (or of startups)
+#[derive(Debug, Default)]
+struct Histogram {
+    count: AtomicU64,
+    failures: AtomicU64,
+    total_us: AtomicU64,
+    max_us: AtomicU64,
+    buckets: [AtomicU64; BUCKET_BOUNDS_US.len() + 1],
+}
+
+impl Histogram {
+    fn record(&self, elapsed: Duration, success: bool) {
+        let us = u64::try_from(elapsed.as_micros()).unwrap_or(u64::MAX);
+        let bucket = BUCKET_BOUNDS_US
+            .iter()
+            .position(|bound| us <= *bound)
+            .unwrap_or(BUCKET_BOUNDS_US.len());
+        self.count.fetch_add(1, Ordering::Relaxed);
+        if !success {
+            self.failures.fetch_add(1, Ordering::Relaxed);
+        }
+        self.total_us.fetch_add(us, Ordering::Relaxed);
+        self.max_us.fetch_max(us, Ordering::Relaxed);
+        self.buckets[bucket].fetch_add(1, Ordering::Relaxed);
+    }
+
+    /// Upper bound in microseconds of the bucket holding percentile `p`
+    /// (the maximum for the last bucket)
+    fn percentile_us(&self, count: u64, p: f64) -> u64 {
+        let rank = ((count as f64) * p).ceil().max(1.0) as u64;
+        let mut seen = 0;
+        for (i, bucket) in self.buckets.iter().enumerate() {
+            seen += bucket.load(Ordering::Relaxed);
+            if seen >= rank {
+                return BUCKET_BOUNDS_US
+                    .get(i)
+                    .copied()
+                    .unwrap_or_else(|| self.max_us.load(Ordering::Relaxed));
+            }
+        }
+        self.max_us.load(Ordering::Relaxed)
+    }
+
+    fn report(&self) -> LatencyReport {
+        let count = self.count.load(Ordering::Relaxed);
+        let ms = |us: u64| us as f64 / 1000.0;
+        LatencyReport {
+            count,
+            failures: self.failures.load(Ordering::Relaxed),
+            avg_ms: if count == 0 {
+                0.0
+            } else {
+                ms(self.total_us.load(Ordering::Relaxed) / count)
+            },
+            p50_ms: ms(self.percentile_us(count, 0.5)),
+            p95_ms: ms(self.percentile_us(count, 0.95)),
+            p99_ms: ms(self.percentile_us(count, 0.99)),
+            max_ms: ms(self.max_us.load(Ordering::Relaxed)),
+        }
+    }
+}
+
+/// Counts and latencies of a session, or of all sessions
+#[derive(Debug)]
+pub struct Metrics {
+    created: Instant,
+    /// Whether this is a session's metrics, also recorded into `aggregate()`
+    aggregates: bool,
+    requests: RwLock<HashMap<String, Arc<Histogram>>>,
+    stops: AtomicU64,
+    breakpoint_hits: AtomicU64,
+    startups: Histogram,
+    stop_notifications: Histogram,
+}
+
+impl Default for Metrics {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+impl Metrics {
+    /// Metrics of a new session
+    pub fn new() -> Self {
+        Self {
+            created: Instant::now(),
+            aggregates: true,
+            requests: RwLock::new(HashMap::new()),
+            stops: AtomicU64::new(0),
+            breakpoint_hits: AtomicU64::new(0),
+            startups: Histogram::default(),
+            stop_notifications: Histogram::default(),
+        }
+    }
+
+    /// Time since the metrics (the session) were created
+    pub fn age(&self) -> Duration {
+        self.created.elapsed()
+    }
+
+    /// A request answered after `elapsed` (or never answered: `success` false)
+    pub fn record_request(&self, command: &str, elapsed: Duration, success: bool) {
+        let histogram = self.requests.read().unwrap().get(command).cloned();
+        let histogram = histogram.unwrap_or_else(|| {
+            self.requests
+                .write()
+                .unwrap()
+                .entry(command.to_string())
+                .or_default()
+                .clone()
+        });
+        histogram.record(elapsed, success);
+        if self.aggregates {
+            aggregate().record_request(command, elapsed, success);
+        }
+    }
+
+    /// A `stopped` event, at a breakpoint if `breakpoint_hit`
+    pub fn record_stop(&self, breakpoint_hit: bool) {
+        self.stops.fetch_add(1, Ordering::Relaxed);
+        if breakpoint_hit {
+            self.breakpoint_hits.fetch_add(1, Ordering::Relaxed);
+        }
+        if self.aggregates {
+            aggregate().record_stop(breakpoint_hit);
+        }
+    }
+
+    /// Time from a session's creation until its program was launched
+    pub fn record_startup(&self, elapsed: Duration) {
+        self.startups.record(elapsed, true);
+        if self.aggregates {
+            aggregate().record_startup(elapsed);
+        }
+    }
+
+    /// Time from a `stopped` event's arrival until the stop was in the
+    /// session's state
+    pub fn record_stop_notification(&self, elapsed: Duration) {
+        self.stop_notifications.record(elapsed, true);
+        if self.aggregates {
+            aggregate().record_stop_notification(elapsed);
+        }
+    }
+
+    pub fn report(&self) -> MetricsReport {
+        let requests: BTreeMap<String, LatencyReport> = self
+            .requests
+            .read()
+            .unwrap()
+            .iter()
+            .map(|(command, histogram)| (command.clone(), histogram.report()))
+            .collect();
+        MetricsReport {
+            uptime_ms: self.created.elapsed().as_millis() as u64,
+            total_requests: requests.values().map(|r| r.count).sum(),
+            requests,
+            stops: self.stops.load(Ordering::Relaxed),
+            breakpoint_hits: self.breakpoint_hits.load(Ordering::Relaxed),
+            adapter_startup: self.startups.report(),
+            stop_notification: self.stop_notifications.report(),
+        }
+    }
+}
+
+/// Latencies of one kind of request, in milliseconds
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct LatencyReport {
+    pub count: u64,
+    /// Requests the adapter rejected or never answered
+    pub failures: u64,
+    pub avg_ms: f64,
+    pub p50_ms: f64,
+    pub p95_ms: f64,
+    pub p99_ms: f64,
+    pub max_ms: f64,
+}
+
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct MetricsReport {
+    /// Time since the session was created (or the server started)
+    pub uptime_ms: u64,
+    pub total_requests: u64,
+    /// By DAP command
+    pub requests: BTreeMap<String, LatencyReport>,
+    pub stops: u64,
+    pub breakpoint_hits: u64,
+    /// From a session's creation, right after its adapter was started,
+    /// until its program was launched
+    pub adapter_startup: LatencyReport,
+    /// From a `stopped` event's arrival until the stop was reported in the
+    /// session's state, with its top frame where the adapter pages stacks
+    pub stop_notification: LatencyReport,
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_latency_percentiles() {
+        let metrics = Metrics {
+            aggregates: false,
+            ..Metrics::new()
+        };
+        for _ in 0..90 {
+            metrics.record_request("stackTrace", Duration::from_micros(800), true);
+        }
+        for _ in 0..9 {
+            metrics.record_request("stackTrace", Duration::from_millis(40), true);
+        }
+        metrics.record_request("stackTrace", Duration::from_secs(12), false);
+        metrics.record_request("threads", Duration::from_micros(50), true);
+
+        let report = metrics.report();
+        assert_eq!(report.total_requests, 101);
+        let stack = &report.requests["stackTrace"];
+        assert_eq!(stack.count, 100);
+        assert_eq!(stack.failures, 1);
+        assert_eq!(stack.p50_ms, 1.0);
+        assert_eq!(stack.p95_ms, 50.0);
+        assert_eq!(stack.p99_ms, 50.0);
+        assert_eq!(stack.max_ms, 12_000.0);
+        assert_eq!(report.requests["threads"].p99_ms, 0.1);
+        assert_eq!(report.adapter_startup.count, 0);
+        assert_eq!(report.adapter_startup.avg_ms, 0.0);
+    }
+
+    #[test]
+    fn test_sessions_record_into_aggregate() {
+        let before = aggregate().report();
+        let session = Metrics::new();
+        session.record_stop(true);
+        session.record_stop(false);
+        session.record_startup(Duration::from_millis(700));
+        session.record_stop_notification(Duration::from_millis(3));
+
+        let report = session.report();
+        assert_eq!((report.stops, report.breakpoint_hits), (2, 1));
+        assert_eq!(report.adapter_startup.avg_ms, 700.0);
+        assert_eq!(report.stop_notification.count, 1);
+        // Other tests record concurrently, so the aggregate only grows
+        let after = aggregate().report();
+        assert!(after.stops >= before.stops + 2);
+        assert!(after.adapter_startup.count > before.adapter_startup.count);
+    }
+}
@@ -1,5 +1,12 @@
+pub mod capabilities;
 pub mod client;
+pub mod encoding;
+pub mod launch_phase;
+pub mod metrics;
 pub mod multi_connection_listener;
+pub mod positions;
+pub mod read_only;
+pub mod request_queue;
 pub mod socket_helper;
 pub mod transport;
 pub mod transport_trait;
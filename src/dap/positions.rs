@@ -0,0 +1,197 @@
+//! Line and column numbering at the adapter boundary
+//!
+//! In `initialize` the client tells the adapter whether it counts lines and
+//! columns from 1 or from 0 (`linesStartAt1`, `columnsStartAt1`). This server
+//! always asks for 1-based numbers, but an adapter may number from 0 anyway,
+//! and may say so in its initialize response. The client records the
+//! numbering the adapter uses and converts every line and column in requests,
+//! responses and events here, so everything above the client is 1-based.
+
+use serde::Serialize;
+use serde_json::Value;
+
+/// Whether an adapter counts lines and columns from 1
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct PositionBase {
+    pub lines_start_at1: bool,
+    pub columns_start_at1: bool,
+}
+
+impl Default for PositionBase {
+    fn default() -> Self {
+        Self::ONE_BASED
+    }
+}
+
+/// Objects holding positions in request arguments, by command: JSON
+/// pointers to an object or an array of objects ("" is the arguments)
+const REQUEST_POSITIONS: &[(&str, &str)] = &[
+    ("setBreakpoints", "/breakpoints"),
+    ("breakpointLocations", ""),
+    ("gotoTargets", ""),
+];
+
+/// Objects holding positions in response bodies, by command
+const RESPONSE_POSITIONS: &[(&str, &str)] = &[
+    ("setBreakpoints", "/breakpoints"),
+    ("setFunctionBreakpoints", "/breakpoints"),
+    ("setInstructionBreakpoints", "/breakpoints"),
+    ("setExceptionBreakpoints", "/breakpoints"),
+    ("breakpointLocations", "/breakpoints"),
+    ("stackTrace", "/stackFrames"),
+    ("scopes", "/scopes"),
+    ("gotoTargets", "/targets"),
+    ("stepInTargets", "/targets"),
+    ("disassemble", "/instructions"),
+];
+
+/// Objects holding positions in event bodies, by event
+const EVENT_POSITIONS: &[(&str, &str)] = &[("breakpoint", "/breakpoint"), ("output", "")];
+
+const LINE_FIELDS: &[&str] = &["line", "endLine"];
+const COLUMN_FIELDS: &[&str] = &["column", "endColumn"];
+
+impl PositionBase {
+    /// The numbering this server uses and asks adapters for
+    pub const ONE_BASED: Self = Self {
+        lines_start_at1: true,
+        columns_start_at1: true,
+    };
+
+    /// Numbering an adapter uses after initialize
+    ///
+    /// Adapters are expected to follow the client's numbering; one that
+    /// doesn't can say so with `linesStartAt1` / `columnsStartAt1` in its
+    /// initialize response.
+    pub fn from_initialize(requested: Self, body: Option<&Value>) -> Self {
+        let flag = |key: &str| body.and_then(|b| b.get(key)).and_then(Value::as_bool);
+        Self {
+            lines_start_at1: flag("linesStartAt1").unwrap_or(requested.lines_start_at1),
+            columns_start_at1: flag("columnsStartAt1").unwrap_or(requested.columns_start_at1),
+        }
+    }
+
+    pub fn is_one_based(&self) -> bool {
+        *self == Self::ONE_BASED
+    }
+
+    /// Convert the positions in a request's arguments to the adapter's numbering
+    pub fn request_to_adapter(&self, command: &str, arguments: &mut Value) {
+        self.convert(REQUEST_POSITIONS, command, arguments, -1);
+    }
+
+    /// Convert the positions in a response body to 1-based numbering
+    pub fn response_from_adapter(&self, command: &str, body: &mut Value) {
+        self.convert(RESPONSE_POSITIONS, command, body, 1);
+    }
+
+    /// Convert the positions in an event body to 1-based numbering
+    pub fn event_from_adapter(&self, event: &str, body: &mut Value) {
+        self.convert(EVENT_POSITIONS, event, body, 1);
+    }
+
+    /// Shift positions by `direction` for each 0-based kind of number
+    fn convert(&self, table: &[(&str, &str)], name: &str, value: &mut Value, direction: i64) {
+        if self.is_one_based() {
+            return;
+        }
+        let line_shift = if self.lines_start_at1 { 0 } else { direction };
+        let column_shift = if self.columns_start_at1 { 0 } else { direction };
+
+        for (_, pointer) in table.iter().filter(|(n, _)| *n == name) {
+            let objects: Vec<&mut Value> = match value.pointer_mut(pointer) {
+                Some(Value::Array(items)) => items.iter_mut().collect(),
+                Some(object) => vec![object],
+                None => Vec::new(),
+            };
+            for object in objects {
+                shift(object, LINE_FIELDS, line_shift);
+                shift(object, COLUMN_FIELDS, column_shift);
+            }
+        }
+    }
+}
+
+fn shift(object: &mut Value, fields: &[&str], by: i64) {
+    if by == 0 {
+        return;
+    }
+    let Some(object) = object.as_object_mut() else {
+        return;
+    };
+    for field in fields {
+        if let Some(n) = object.get(*field).and_then(Value::as_i64) {
+            object.insert(field.to_string(), Value::from(n + by));
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    const ZERO_BASED: PositionBase = PositionBase {
+        lines_start_at1: false,
+        columns_start_at1: false,
+    };
+
+    #[test]
+    fn test_from_initialize() {
+        assert_eq!(
+            PositionBase::from_initialize(PositionBase::ONE_BASED, Some(&json!({}))),
+            PositionBase::ONE_BASED
+        );
+        let base = PositionBase::from_initialize(
+            PositionBase::ONE_BASED,
+            Some(&json!({"linesStartAt1": false})),
+        );
+        assert!(!base.lines_start_at1);
+        assert!(base.columns_start_at1);
+    }
+
+    #[test]
+    fn test_conversion_both_directions() {
+        let mut args = json!({
+            "source": {"path": "/w/app.js"},
+            "breakpoints": [{"line": 10}, {"line": 1, "column": 5}]
+        });
+        ZERO_BASED.request_to_adapter("setBreakpoints", &mut args);
+        assert_eq!(
+            args["breakpoints"],
+            json!([{"line": 9}, {"line": 0, "column": 4}])
+        );
+
+        let mut body = json!({"stackFrames": [
+            {"id": 1, "name": "f", "line": 9, "column": 0, "endLine": 11}
+        ]});
+        ZERO_BASED.response_from_adapter("stackTrace", &mut body);
+        assert_eq!(body["stackFrames"][0]["line"], 10);
+        assert_eq!(body["stackFrames"][0]["column"], 1);
+        assert_eq!(body["stackFrames"][0]["endLine"], 12);
+        assert_eq!(body["stackFrames"][0]["id"], 1);
+
+        let mut event = json!({"reason": "changed", "breakpoint": {"id": 3, "line": 4}});
+        ZERO_BASED.event_from_adapter("breakpoint", &mut event);
+        assert_eq!(event["breakpoint"]["line"], 5);
+
+        // Only columns are 0-based
+        let columns_only = PositionBase {
+            lines_start_at1: true,
+            columns_start_at1: false,
+        };
+        let mut body = json!({"breakpoints": [{"verified": true, "line": 10, "column": 0}]});
+        columns_only.response_from_adapter("setBreakpoints", &mut body);
+        assert_eq!(
+            body["breakpoints"][0],
+            json!({"verified": true, "line": 10, "column": 1})
+        );
+
+        // Untouched for 1-based adapters and commands without positions
+        let mut body = json!({"stackFrames": [{"line": 9}]});
+        PositionBase::ONE_BASED.response_from_adapter("stackTrace", &mut body);
+        ZERO_BASED.response_from_adapter("threads", &mut body);
+        assert_eq!(body["stackFrames"][0]["line"], 9);
+    }
+}
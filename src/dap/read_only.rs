@@ -0,0 +1,74 @@
+//! Read-only sessions
+//!
+//! Attaching to a production process with `readOnly: true` guarantees the
+//! agent can look but not change anything. The flag is given at attach,
+//! before the first request reaches the debugger, and can't be cleared
+//! afterwards; session listings show it (`readOnly`) so audits can check it.
+//!
+//! Blocked with `Error::ReadOnlySession`:
+//!
+//! - requests that change the program or end it (`BLOCKED_COMMANDS`),
+//!   refused by the client itself whatever sends them
+//! - evaluations that may call functions: `debugger_evaluate` and
+//!   `debugger_evaluate_all_threads` always run as with `noSideEffects`, and
+//!   fail where the debugger can't guarantee that (Python, Ruby, Rust, Java)
+//! - tools evaluating arbitrary code in the program: the REPL, and
+//!   `debugger_watch_change` (which also stashes values in the program)
+//!
+//! Disconnecting asks the debugger to leave the process running
+//! (`terminateDebuggee: false`).
+//!
+//! Breakpoints, stepping, continuing and pausing, and reading stacks,
+//! variables and memory stay allowed. They still perturb the process: a
+//! stopped thread serves nothing until it is continued, and breakpoint
+//! conditions and logpoint messages are evaluated by the debugger, so they
+//! should stick to reading values.
+
+use crate::{Error, Result};
+
+/// Requests refused in read-only sessions
+const BLOCKED_COMMANDS: &[&str] = &[
+    "setVariable",
+    "setExpression",
+    "writeMemory",
+    "goto",
+    "restartFrame",
+    "restart",
+    "stepBack",
+    "reverseContinue",
+    "terminate",
+    "terminateThreads",
+];
+
+/// Fails if a read-only session may not send `command`
+pub fn check_command(command: &str) -> Result<()> {
+    if BLOCKED_COMMANDS.contains(&command) {
+        return Err(blocked(&format!("The '{}' request", command)));
+    }
+    Ok(())
+}
+
+/// The error for `action`, blocked in a read-only session
+pub fn blocked(action: &str) -> Error {
+    Error::ReadOnlySession(format!(
+        "{} is blocked: the session was attached read-only. Breakpoints, stepping and inspection are still allowed, but they still perturb the process's timing (a stopped thread serves nothing until continued)",
+        action
+    ))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_mutating_requests_are_blocked() {
+        for command in ["setVariable", "writeMemory", "goto", "terminate"] {
+            let err = check_command(command).unwrap_err();
+            assert_eq!(err.error_code(), -32015);
+            assert!(err.to_string().contains("perturb"), "{}", err);
+        }
+        for command in ["stackTrace", "variables", "readMemory", "next", "pause"] {
+            assert!(check_command(command).is_ok(), "{}", command);
+        }
+    }
+}
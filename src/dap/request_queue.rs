@@ -0,0 +1,161 @@
+//! Request priorities and deadlines
+//!
+//! All requests to an adapter share one connection. The writer used to send
+//! them strictly in order, and a request waited for its response as long as
+//! the adapter took, so a `variables` on a huge structure could hold up the
+//! `pause` a user sent after it, and a stuck inspection could only end by
+//! tearing the connection down.
+//!
+//! Now execution-control requests that must not wait (`pause`,
+//! `disconnect`, `terminate`, `cancel`) are written ahead of anything still
+//! queued, and inspection requests (`variables`, `evaluate`, `stackTrace`,
+//! ...) each have a deadline, `timeouts.inspection_ms`. An inspection past
+//! its deadline fails alone with `Error::Timeout`: the connection and the
+//! other requests go on, the adapter is asked to `cancel` it where it
+//! supports that, and its late response is dropped.
+//!
+//! `debugger_get_metrics` reports the requests waiting for a response
+//! (`QueueReport`).
+
+use super::types::Response;
+use serde::Serialize;
+use std::collections::HashMap;
+use std::sync::{Arc, Mutex};
+use std::time::Instant;
+use tokio::sync::oneshot;
+
+/// Requests written ahead of queued ones
+const URGENT_COMMANDS: &[&str] = &["pause", "disconnect", "terminate", "cancel"];
+
+/// Requests that only read program state, failed alone past their deadline
+const INSPECTION_COMMANDS: &[&str] = &[
+    "variables",
+    "evaluate",
+    "stackTrace",
+    "scopes",
+    "source",
+    "threads",
+    "exceptionInfo",
+    "disassemble",
+    "readMemory",
+    "completions",
+    "loadedSources",
+    "breakpointLocations",
+];
+
+/// Whether `command` is written ahead of queued requests
+pub fn is_urgent(command: &str) -> bool {
+    URGENT_COMMANDS.contains(&command)
+}
+
+/// Whether `command` has the inspection deadline
+pub fn is_inspection(command: &str) -> bool {
+    INSPECTION_COMMANDS.contains(&command)
+}
+
+/// A request waiting for its response
+#[derive(Debug)]
+pub struct PendingRequest {
+    pub sender: oneshot::Sender<Response>,
+    pub command: String,
+    pub sent: Instant,
+}
+
+impl PendingRequest {
+    pub fn new(sender: oneshot::Sender<Response>, command: &str) -> Self {
+        Self {
+            sender,
+            command: command.to_string(),
+            sent: Instant::now(),
+        }
+    }
+}
+
+/// Requests waiting for a response, by request seq
+pub type PendingRequests = Arc<Mutex<HashMap<i32, PendingRequest>>>;
+
+/// A caller waiting for the response to request `seq`; dropping it, when
+/// answered, past a deadline or because the caller gave up, forgets the
+/// request
+pub struct Waiting {
+    pending: PendingRequests,
+    seq: i32,
+}
+
+impl Waiting {
+    pub fn new(pending: &PendingRequests, seq: i32) -> Self {
+        Self {
+            pending: pending.clone(),
+            seq,
+        }
+    }
+}
+
+impl Drop for Waiting {
+    fn drop(&mut self) {
+        self.pending
+            .lock()
+            .unwrap_or_else(|e| e.into_inner())
+            .remove(&self.seq);
+    }
+}
+
+/// Requests waiting for a response, for `debugger_get_metrics`
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct QueueReport {
+    /// Requests sent or queued and not answered yet
+    pub depth: usize,
+    /// How long the oldest of them has waited
+    pub oldest_pending_ms: Option<u64>,
+    pub oldest_pending_command: Option<String>,
+    /// Inspections failed at their deadline since the session started
+    pub timed_out: u64,
+}
+
+/// The report of `pending` at `now`
+pub fn report(pending: &HashMap<i32, PendingRequest>, timed_out: u64, now: Instant) -> QueueReport {
+    let oldest = pending.values().min_by_key(|request| request.sent);
+    QueueReport {
+        depth: pending.len(),
+        oldest_pending_ms: oldest
+            .map(|request| now.saturating_duration_since(request.sent).as_millis() as u64),
+        oldest_pending_command: oldest.map(|request| request.command.clone()),
+        timed_out,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::time::Duration;
+
+    #[test]
+    fn test_report_names_the_oldest_request() {
+        let start = Instant::now();
+        let mut pending = HashMap::new();
+        assert_eq!(
+            report(&pending, 0, start),
+            QueueReport {
+                depth: 0,
+                oldest_pending_ms: None,
+                oldest_pending_command: None,
+                timed_out: 0
+            }
+        );
+
+        let (variables, _) = oneshot::channel();
+        let (pause, _) = oneshot::channel();
+        pending.insert(1, PendingRequest::new(variables, "variables"));
+        std::thread::sleep(Duration::from_millis(5));
+        pending.insert(2, PendingRequest::new(pause, "pause"));
+        let report = report(&pending, 2, Instant::now());
+        assert_eq!(report.depth, 2);
+        assert_eq!(report.oldest_pending_command.as_deref(), Some("variables"));
+        assert!(report.oldest_pending_ms.unwrap() >= 5);
+        assert_eq!(report.timed_out, 2);
+
+        assert!(is_urgent("pause") && !is_urgent("variables"));
+        assert!(is_inspection("variables") && !is_inspection("continue"));
+    }
+}
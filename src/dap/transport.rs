@@ -1,3 +1,4 @@
+use super::encoding;
 use super::transport_trait::DapTransportTrait;
 use super::types::Message;
 use crate::{Error, Result};
@@ -83,8 +84,8 @@ impl DapTransport {
         let mut buffer = vec![0u8; content_length];
         tokio::io::AsyncReadExt::read_exact(reader, &mut buffer).await?;
 
-        let content =
-            String::from_utf8(buffer).map_err(|e| Error::Dap(format!("Invalid UTF-8: {}", e)))?;
+        // Debuggee text may not be valid Unicode; never fail the message on it
+        let content = encoding::decode_message(buffer);
 
         Ok((headers, content))
     }
@@ -71,6 +71,161 @@ pub struct Capabilities {
     pub supports_set_variable: Option<bool>,
     pub supports_restart_frame: Option<bool>,
     pub supports_step_in_targets_request: Option<bool>,
+    #[serde(default)]
+    pub supports_restart_request: Option<bool>,
+    #[serde(default)]
+    pub supports_log_points: Option<bool>,
+    #[serde(default)]
+    pub supports_data_breakpoints: Option<bool>,
+    #[serde(default)]
+    pub supports_terminate_request: Option<bool>,
+    #[serde(default)]
+    pub supports_exception_info_request: Option<bool>,
+    #[serde(default)]
+    pub supports_exception_options: Option<bool>,
+    #[serde(default)]
+    pub supports_completions_request: Option<bool>,
+    #[serde(default)]
+    pub supports_goto_targets_request: Option<bool>,
+    #[serde(default)]
+    pub supports_set_expression: Option<bool>,
+    #[serde(default)]
+    pub supports_step_back: Option<bool>,
+    #[serde(default)]
+    pub supports_stepping_granularity: Option<bool>,
+    #[serde(default)]
+    pub supports_instruction_breakpoints: Option<bool>,
+    #[serde(default)]
+    pub supports_disassemble_request: Option<bool>,
+    #[serde(default)]
+    pub supports_breakpoint_locations_request: Option<bool>,
+    /// `stackTrace` takes `startFrame` and `levels`, so deep stacks can be
+    /// fetched a page at a time
+    #[serde(default)]
+    pub supports_delayed_stack_trace_loading: Option<bool>,
+    #[serde(default)]
+    pub supports_cancel_request: Option<bool>,
+    #[serde(default)]
+    pub supports_modules_request: Option<bool>,
+    #[serde(default)]
+    pub supports_loaded_sources_request: Option<bool>,
+    #[serde(default)]
+    pub supports_read_memory_request: Option<bool>,
+    #[serde(default)]
+    pub supports_write_memory_request: Option<bool>,
+    #[serde(default)]
+    pub supports_terminate_threads_request: Option<bool>,
+    #[serde(default)]
+    pub supports_value_formatting_options: Option<bool>,
+    #[serde(default)]
+    pub supports_exception_filter_options: Option<bool>,
+    #[serde(default)]
+    pub support_terminate_debuggee: Option<bool>,
+    #[serde(default)]
+    pub support_suspend_debuggee: Option<bool>,
+    #[serde(default)]
+    pub supports_single_thread_execution_requests: Option<bool>,
+    /// Exception breakpoint filters the adapter offers for `setExceptionBreakpoints`
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub exception_breakpoint_filters: Option<Vec<ExceptionBreakpointsFilter>>,
+    /// Checksum algorithms the adapter can verify sources with (`MD5`,
+    /// `SHA1`, `SHA256`, `timestamp`)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub supported_checksum_algorithms: Option<Vec<String>>,
+}
+
+/// An exception breakpoint filter offered by the adapter
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ExceptionBreakpointsFilter {
+    pub filter: String,
+    pub label: String,
+    #[serde(default)]
+    pub default: Option<bool>,
+}
+
+/// SetExceptionBreakpoints Request Arguments
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SetExceptionBreakpointsArguments {
+    pub filters: Vec<String>,
+    /// Needs `supportsExceptionOptions`
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub exception_options: Option<Vec<ExceptionOptions>>,
+}
+
+/// When to break on the exceptions a path matches
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ExceptionOptions {
+    /// Segments narrowing down the exceptions, from the adapter's category
+    /// (e.g. `Python Exceptions`) to exception types; empty matches all
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub path: Option<Vec<ExceptionPathSegment>>,
+    /// `never`, `always`, `unhandled` or `userUnhandled`
+    pub break_mode: String,
+}
+
+/// One level of an exception path: matches any of the names, or anything
+/// but them when negated
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ExceptionPathSegment {
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub negate: Option<bool>,
+    pub names: Vec<String>,
+}
+
+/// ExceptionInfo Request Arguments
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ExceptionInfoArguments {
+    pub thread_id: i32,
+}
+
+/// ExceptionInfo Response Body
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ExceptionInfo {
+    /// Exception class or type, e.g. `StandardError`
+    pub exception_id: String,
+    /// Usually the exception message
+    #[serde(default)]
+    pub description: Option<String>,
+    /// never, always, unhandled or userUnhandled
+    pub break_mode: String,
+}
+
+/// Disassemble Request Arguments
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct DisassembleArguments {
+    pub memory_reference: String,
+    /// Byte offset applied to `memory_reference` before instruction_offset
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub offset: Option<i64>,
+    /// Instructions to skip from the reference (may be negative)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub instruction_offset: Option<i64>,
+    pub instruction_count: i64,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub resolve_symbols: Option<bool>,
+}
+
+/// A disassembled instruction
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct DisassembledInstruction {
+    pub address: String,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub instruction_bytes: Option<String>,
+    pub instruction: String,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub symbol: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub location: Option<Source>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub line: Option<i32>,
 }
 
 /// Launch Request Arguments
@@ -91,6 +246,58 @@ pub struct SetBreakpointsArguments {
     pub source_modified: Option<bool>,
 }
 
+/// BreakpointLocations Request Arguments
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct BreakpointLocationsArguments {
+    pub source: Source,
+    pub line: i32,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub end_line: Option<i32>,
+}
+
+/// SetFunctionBreakpoints Request Arguments
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SetFunctionBreakpointsArguments {
+    pub breakpoints: Vec<FunctionBreakpoint>,
+}
+
+/// Function breakpoint: stops when a function with this name is entered
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct FunctionBreakpoint {
+    pub name: String,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub condition: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub hit_condition: Option<String>,
+}
+
+/// SetInstructionBreakpoints Request Arguments
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SetInstructionBreakpointsArguments {
+    pub breakpoints: Vec<InstructionBreakpoint>,
+}
+
+/// Instruction breakpoint
+///
+/// `instruction_reference` is a memory reference (e.g. an address from a
+/// stack frame's `instructionPointerReference`); `offset` is a byte offset
+/// from it, which may be negative.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct InstructionBreakpoint {
+    pub instruction_reference: String,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub offset: Option<i64>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub condition: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub hit_condition: Option<String>,
+}
+
 /// Source reference
 #[derive(Debug, Clone, Serialize, Deserialize)]
 #[serde(rename_all = "camelCase")]
@@ -98,6 +305,17 @@ pub struct Source {
     pub name: Option<String>,
     pub path: Option<String>,
     pub source_reference: Option<i32>,
+    /// Checksums of the file's contents, for the adapter to check against
+    /// the program's
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub checksums: Option<Vec<Checksum>>,
+}
+
+/// Checksum of a source file
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct Checksum {
+    pub algorithm: String,
+    pub checksum: String,
 }
 
 /// Source breakpoint
@@ -108,6 +326,8 @@ pub struct SourceBreakpoint {
     pub column: Option<i32>,
     pub condition: Option<String>,
     pub hit_condition: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub log_message: Option<String>,
 }
 
 /// Breakpoint response
@@ -127,7 +347,10 @@ pub struct Breakpoint {
 #[serde(rename_all = "camelCase")]
 pub struct StackTraceArguments {
     pub thread_id: i32,
+    /// Needs `supportsDelayedStackTraceLoading`, like `levels`
+    #[serde(skip_serializing_if = "Option::is_none")]
     pub start_frame: Option<i32>,
+    #[serde(skip_serializing_if = "Option::is_none")]
     pub levels: Option<i32>,
 }
 
@@ -142,6 +365,9 @@ pub struct StackFrame {
     pub column: i32,
     pub end_line: Option<i32>,
     pub end_column: Option<i32>,
+    /// Memory reference of the frame's current instruction (for instruction breakpoints)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub instruction_pointer_reference: Option<String>,
 }
 
 /// Thread info
@@ -169,6 +395,12 @@ pub struct Variable {
     #[serde(rename = "type")]
     pub type_: Option<String>,
     pub variables_reference: i32,
+    /// Number of indexed children (array, slice and list elements)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub indexed_variables: Option<i64>,
+    /// The value had bytes that aren't UTF-8, replaced (see `encoding`)
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub encoding_lossy: bool,
 }
 
 /// Scopes Request Arguments
@@ -178,6 +410,22 @@ pub struct ScopesArguments {
     pub frame_id: i32,
 }
 
+/// Variables Request Arguments
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct VariablesArguments {
+    pub variables_reference: i32,
+    /// `indexed` or `named` children only
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub filter: Option<String>,
+    /// First child to return (paging)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub start: Option<i64>,
+    /// Number of children to return; all when absent
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub count: Option<i64>,
+}
+
 /// Scope
 #[derive(Debug, Clone, Serialize, Deserialize)]
 #[serde(rename_all = "camelCase")]
@@ -185,6 +433,20 @@ pub struct Scope {
     pub name: String,
     pub variables_reference: i32,
     pub expensive: bool,
+    /// `arguments`, `locals` or `registers`
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub presentation_hint: Option<String>,
+    /// Source range the scope covers, where the adapter reports one
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub source: Option<Source>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub line: Option<i32>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub column: Option<i32>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub end_line: Option<i32>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub end_column: Option<i32>,
 }
 
 /// Continue Request Arguments
@@ -199,6 +461,9 @@ pub struct ContinueArguments {
 #[serde(rename_all = "camelCase")]
 pub struct NextArguments {
     pub thread_id: i32,
+    /// Needs `supportsSteppingGranularity`
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub granularity: Option<SteppingGranularity>,
 }
 
 /// StepIn (Step Into) Request Arguments
@@ -206,6 +471,9 @@ pub struct NextArguments {
 #[serde(rename_all = "camelCase")]
 pub struct StepInArguments {
     pub thread_id: i32,
+    /// Needs `supportsSteppingGranularity`
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub granularity: Option<SteppingGranularity>,
 }
 
 /// StepOut (Step Out) Request Arguments
@@ -213,6 +481,25 @@ pub struct StepInArguments {
 #[serde(rename_all = "camelCase")]
 pub struct StepOutArguments {
     pub thread_id: i32,
+    /// Needs `supportsSteppingGranularity`
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub granularity: Option<SteppingGranularity>,
+}
+
+/// How far one step goes; adapters assume `statement` when none is given
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub enum SteppingGranularity {
+    Statement,
+    Line,
+    Instruction,
+}
+
+/// Pause Request Arguments
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct PauseArguments {
+    pub thread_id: i32,
 }
 
 #[cfg(test)]
@@ -255,6 +542,7 @@ mod tests {
             column: Some(5),
             condition: Some("x > 0".to_string()),
             hit_condition: None,
+            log_message: None,
         };
 
         assert_eq!(bp.line, 10);
@@ -270,11 +558,13 @@ mod tests {
                 name: Some("test.py".to_string()),
                 path: Some("/path/to/test.py".to_string()),
                 source_reference: None,
+                checksums: None,
             }),
             line: 42,
             column: 10,
             end_line: None,
             end_column: None,
+            instruction_pointer_reference: None,
         };
 
         assert_eq!(frame.name, "main");
@@ -0,0 +1,260 @@
+//! Breakpoint import/export documents
+//!
+//! A document captures a session's breakpoints so a known-good debugging
+//! setup for a recurring bug can be shared and re-applied to a new session.
+//! Paths are stored exactly as the session knows them (absolute, canonical).
+//!
+//! Change watches and exception breakpoints go with them: watches set with
+//! `debugger_watch_change` as `watches`, a `changed(expr)` breakpoint as a
+//! breakpoint with that condition, and the exception breakpoint modes
+//! (`raised`, `uncaught`) as `exceptionFilters`, translated into the
+//! importing adapter's filters when applied. `watches` may also list plain
+//! expressions to evaluate once stopped (see `debugger_save_config`);
+//! sessions don't track those and only hand them back.
+
+use super::change_watch::DEFAULT_MAX_AUTO_CONTINUES;
+use super::state::{Breakpoint, SessionState};
+use serde::{Deserialize, Serialize};
+
+/// Current document format version
+pub const BREAKPOINT_DOCUMENT_VERSION: u32 = 1;
+
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct BreakpointDocument {
+    pub version: u32,
+    /// Language of the session the document was exported from
+    pub language: String,
+    pub breakpoints: Vec<BreakpointEntry>,
+    #[serde(default)]
+    pub watches: Vec<WatchEntry>,
+    /// Exception breakpoint modes
+    #[serde(default)]
+    pub exception_filters: Vec<String>,
+}
+
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct BreakpointEntry {
+    pub file: String,
+    pub line: i32,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub condition: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub hit_condition: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub log_message: Option<String>,
+    #[serde(default = "default_enabled")]
+    pub enabled: bool,
+}
+
+fn default_enabled() -> bool {
+    true
+}
+
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(untagged)]
+pub enum WatchEntry {
+    /// An expression watched for changes at a line (see `change_watch`)
+    #[serde(rename_all = "camelCase")]
+    Change {
+        expression: String,
+        file: String,
+        line: i32,
+        #[serde(default = "default_max_auto_continues")]
+        max_auto_continues: u64,
+    },
+    /// An expression to evaluate once stopped
+    Expression(String),
+}
+
+fn default_max_auto_continues() -> u64 {
+    DEFAULT_MAX_AUTO_CONTINUES
+}
+
+/// Outcome of importing a single entry
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase", tag = "status")]
+pub enum ImportStatus {
+    /// Sent to the adapter and verified
+    Verified,
+    /// Sent to the adapter but not verified (e.g. no code on that line)
+    Unverified,
+    /// Stored disabled, not sent to the adapter
+    Disabled,
+    /// Stored; will be sent once the session finishes initializing
+    Pending,
+    /// Not imported
+    Failed { reason: String },
+}
+
+/// Outcome of importing a document
+#[derive(Debug, Clone, PartialEq, Default)]
+pub struct ImportReport {
+    /// One per breakpoint entry, in document order
+    pub breakpoints: Vec<ImportStatus>,
+    /// One per change watch, in document order
+    pub watches: Vec<ImportStatus>,
+    /// None when the document has no exception filters
+    pub exception_filters: Option<ImportStatus>,
+}
+
+impl BreakpointDocument {
+    /// Snapshot the breakpoints, watches and exception breakpoints of a
+    /// session, breakpoints and watches sorted by file and line
+    ///
+    /// The breakpoint of a watched line belongs to the watch. A `changed()`
+    /// breakpoint keeps its hit condition, but not a goroutine label.
+    pub fn from_state(language: &str, state: &SessionState) -> Self {
+        let watch_of = |bp: &Breakpoint| {
+            state
+                .change_watches
+                .iter()
+                .find(|w| w.source_path == bp.source_path && w.line == bp.line)
+        };
+        let mut breakpoints: Vec<BreakpointEntry> = state
+            .breakpoints
+            .values()
+            .flatten()
+            .filter_map(|bp| match watch_of(bp) {
+                None => Some(BreakpointEntry::from(bp)),
+                Some(watch) if watch.from_condition => Some(BreakpointEntry {
+                    condition: Some(format!("changed({})", watch.expression)),
+                    ..BreakpointEntry::from(bp)
+                }),
+                Some(_) => None,
+            })
+            .collect();
+        breakpoints.sort_by(|a, b| (&a.file, a.line).cmp(&(&b.file, b.line)));
+        let mut watched: Vec<_> = state
+            .change_watches
+            .iter()
+            .filter(|watch| !watch.from_condition)
+            .collect();
+        watched.sort_by(|a, b| (&a.source_path, a.line).cmp(&(&b.source_path, b.line)));
+        let watches = watched
+            .into_iter()
+            .map(|watch| WatchEntry::Change {
+                expression: watch.expression.clone(),
+                file: watch.source_path.clone(),
+                line: watch.line,
+                max_auto_continues: watch.max_auto_continues,
+            })
+            .collect();
+
+        Self {
+            version: BREAKPOINT_DOCUMENT_VERSION,
+            language: language.to_string(),
+            breakpoints,
+            watches,
+            exception_filters: state.exception_breakpoints.clone(),
+        }
+    }
+}
+
+impl From<&Breakpoint> for BreakpointEntry {
+    fn from(bp: &Breakpoint) -> Self {
+        Self {
+            file: bp.source_path.clone(),
+            line: bp.line,
+            condition: bp.condition.clone(),
+            hit_condition: bp.hit_condition.clone(),
+            log_message: bp.log_message.clone(),
+            enabled: bp.enabled,
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::debug::change_watch::ChangeWatch;
+    use serde_json::json;
+
+    #[test]
+    fn test_entry_defaults() {
+        let entry: BreakpointEntry =
+            serde_json::from_value(json!({"file": "/w/app.py", "line": 4})).unwrap();
+        assert!(entry.enabled);
+        assert!(entry.condition.is_none());
+
+        let doc: BreakpointDocument = serde_json::from_value(json!({
+            "version": 1,
+            "language": "python",
+            "breakpoints": [{"file": "/w/app.py", "line": 4, "hitCondition": ">= 3"}]
+        }))
+        .unwrap();
+        assert_eq!(doc.breakpoints[0].hit_condition.as_deref(), Some(">= 3"));
+        assert!(doc.watches.is_empty());
+        assert!(doc.exception_filters.is_empty());
+    }
+
+    #[test]
+    fn test_from_state_is_sorted() {
+        let mut state = SessionState::new();
+        state.add_breakpoint("/w/b.py".to_string(), 2);
+        state.add_breakpoint("/w/a.py".to_string(), 9);
+        state.add_breakpoint("/w/a.py".to_string(), 3);
+
+        let doc = BreakpointDocument::from_state("python", &state);
+        let locations: Vec<(&str, i32)> = doc
+            .breakpoints
+            .iter()
+            .map(|e| (e.file.as_str(), e.line))
+            .collect();
+        assert_eq!(
+            locations,
+            vec![("/w/a.py", 3), ("/w/a.py", 9), ("/w/b.py", 2)]
+        );
+        assert_eq!(doc.version, BREAKPOINT_DOCUMENT_VERSION);
+    }
+
+    #[test]
+    fn test_from_state_keeps_watches_and_exception_modes() {
+        let mut state = SessionState::new();
+        for line in [2, 4, 6] {
+            state.add_breakpoint("/w/a.py".to_string(), line);
+        }
+        state
+            .change_watches
+            .push(ChangeWatch::new(1, "python", "total", "/w/a.py", 4, 50));
+        state
+            .change_watches
+            .push(ChangeWatch::server_loop(2, "items", "/w/a.py", 6, 1000));
+        state.exception_breakpoints = vec!["uncaught".to_string()];
+
+        // The watch owns its line's breakpoint; changed() stays a breakpoint
+        let doc = BreakpointDocument::from_state("python", &state);
+        let lines: Vec<i32> = doc.breakpoints.iter().map(|e| e.line).collect();
+        assert_eq!(lines, vec![2, 6]);
+        assert_eq!(
+            doc.breakpoints[1].condition.as_deref(),
+            Some("changed(items)")
+        );
+        assert_eq!(
+            doc.watches,
+            vec![WatchEntry::Change {
+                expression: "total".to_string(),
+                file: "/w/a.py".to_string(),
+                line: 4,
+                max_auto_continues: 50,
+            }]
+        );
+        assert_eq!(doc.exception_filters, vec!["uncaught".to_string()]);
+
+        // Expressions to evaluate read back as such
+        let watches: Vec<WatchEntry> = serde_json::from_value(json!([
+            "total",
+            {"expression": "total", "file": "/w/a.py", "line": 4}
+        ]))
+        .unwrap();
+        assert_eq!(watches[0], WatchEntry::Expression("total".to_string()));
+        assert!(matches!(
+            watches[1],
+            WatchEntry::Change {
+                max_auto_continues: DEFAULT_MAX_AUTO_CONTINUES,
+                ..
+            }
+        ));
+    }
+}
@@ -0,0 +1,246 @@
+//! Explanations for breakpoints the adapter moved
+//!
+//! Adapters put a breakpoint on the nearest line that has code: Delve moves
+//! one on an `if` header to the first statement of its body, debugpy one on
+//! a `def` line or a comment to the next statement. Asking for line 13 and
+//! getting line 14 leaves the user guessing, so when the verified line
+//! differs from the requested one the breakpoint carries an explanation,
+//! built from the requested line's text, the adapter's `breakpointLocations`
+//! (where it has them) and the adapter's own `message`.
+
+/// What kind of line a breakpoint was requested on
+#[derive(Debug, Clone, PartialEq)]
+pub enum LineKind {
+    Blank,
+    Comment,
+    /// A closing brace or Ruby `end`
+    BlockEnd,
+    /// Function, class, type or import declaration
+    Declaration,
+    /// Header of a compound statement, by keyword (`if`, `for`, ...)
+    Header(String),
+    Statement,
+}
+
+const DECLARATION_KEYWORDS: &[&str] = &[
+    "func",
+    "def",
+    "fn",
+    "function",
+    "class",
+    "module",
+    "struct",
+    "enum",
+    "trait",
+    "impl",
+    "interface",
+    "type",
+    "package",
+    "import",
+    "use",
+    "from",
+];
+
+const HEADER_KEYWORDS: &[&str] = &[
+    "if", "elif", "elsif", "else", "unless", "for", "while", "until", "loop", "switch", "select",
+    "match", "case", "try", "except", "finally", "with", "begin",
+];
+
+/// Modifiers that may precede a declaration keyword
+const MODIFIERS: &[&str] = &[
+    "pub",
+    "pub(crate)",
+    "async",
+    "export",
+    "default",
+    "static",
+    "public",
+    "private",
+    "protected",
+    "unsafe",
+];
+
+/// The line without a trailing `//` or `#` comment (a guess: string
+/// contents aren't parsed)
+fn strip_trailing_comment(line: &str) -> &str {
+    [" //", "\t//", " #", "\t#"]
+        .iter()
+        .filter_map(|marker| line.find(marker))
+        .min()
+        .map_or(line, |at| &line[..at])
+}
+
+/// Classify a source line by its text
+pub fn classify(line: &str) -> LineKind {
+    let trimmed = line.trim();
+    if trimmed.is_empty() {
+        return LineKind::Blank;
+    }
+    if trimmed.starts_with("#[") || trimmed.starts_with('@') {
+        return LineKind::Declaration;
+    }
+    if ["//", "/*", "* ", "*/", "#"]
+        .iter()
+        .any(|p| trimmed.starts_with(p))
+        || trimmed == "*"
+    {
+        return LineKind::Comment;
+    }
+    let code = strip_trailing_comment(trimmed).trim_end();
+    if code == "end" || code.chars().all(|c| "{}()[];,".contains(c)) {
+        return LineKind::BlockEnd;
+    }
+
+    // `} else if x {` in Go and JavaScript
+    let code = code.trim_start_matches('}').trim_start();
+    let mut words = code
+        .split(|c: char| c.is_whitespace() || c == '(' || c == ':' || c == '{')
+        .filter(|w| !w.is_empty())
+        .skip_while(|w| MODIFIERS.contains(w));
+    let Some(first) = words.next() else {
+        return LineKind::BlockEnd;
+    };
+    if DECLARATION_KEYWORDS.contains(&first) {
+        return LineKind::Declaration;
+    }
+    let opens_block = code.ends_with('{') || code.ends_with(':') || code.ends_with(" do");
+    if HEADER_KEYWORDS.contains(&first) && (opens_block || first == "else" || first == "begin") {
+        if first == "else" && words.next() == Some("if") {
+            return LineKind::Header("else if".to_string());
+        }
+        return LineKind::Header(first.to_string());
+    }
+    LineKind::Statement
+}
+
+/// Why a breakpoint requested on `requested` was verified on `actual`
+///
+/// `requested_text` is the requested line's source, `locations` the lines
+/// the adapter reported as valid breakpoint locations around the two lines.
+pub fn explain(
+    requested: i32,
+    actual: i32,
+    requested_text: Option<&str>,
+    locations: Option<&[i32]>,
+    adapter_message: Option<&str>,
+) -> String {
+    let forward = actual > requested;
+    let nearest_code = if forward {
+        "the next line with code"
+    } else {
+        "the previous line with code"
+    };
+    let kind = requested_text.map(classify);
+    let reason = match &kind {
+        Some(LineKind::Blank) => Some(("blank line".to_string(), nearest_code.to_string())),
+        Some(LineKind::Comment) => Some(("comment".to_string(), nearest_code.to_string())),
+        Some(LineKind::BlockEnd) => Some(("end of a block".to_string(), nearest_code.to_string())),
+        Some(LineKind::Declaration) => Some((
+            "declaration".to_string(),
+            if forward {
+                "the first statement of its body".to_string()
+            } else {
+                nearest_code.to_string()
+            },
+        )),
+        Some(LineKind::Header(keyword)) => Some((
+            format!("{} header", keyword),
+            if forward {
+                format!("the first statement of the {} body", keyword)
+            } else {
+                nearest_code.to_string()
+            },
+        )),
+        Some(LineKind::Statement) | None => None,
+    };
+    let no_location = locations.is_some_and(|lines| !lines.contains(&requested));
+
+    let mut explanation = match reason {
+        Some((what, target)) => format!(
+            "line {} is not a statement ({}); moved to {}, {}",
+            requested, what, actual, target
+        ),
+        None if no_location => format!(
+            "line {} has no breakpoint location; moved to {}, {}",
+            requested, actual, nearest_code
+        ),
+        None => format!(
+            "the debugger moved the breakpoint from line {} to {}",
+            requested, actual
+        ),
+    };
+    if let Some(message) = adapter_message.filter(|m| !m.trim().is_empty()) {
+        explanation.push_str(&format!(" (debugger: {})", message.trim()));
+    }
+    explanation
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_classify_per_language() {
+        let header = |k: &str| LineKind::Header(k.to_string());
+        let cases: &[(&str, LineKind)] = &[
+            // Go
+            (
+                "\tif n%15 == 0 { // Breakpoint target: line 13",
+                header("if"),
+            ),
+            ("\t} else if n%3 == 0 {", header("else if")),
+            ("\t*p = 3", LineKind::Statement),
+            ("func fizzbuzz(n int) string {", LineKind::Declaration),
+            ("\t}", LineKind::BlockEnd),
+            ("\treturn \"FizzBuzz\"", LineKind::Statement),
+            // Python
+            ("def fizzbuzz(n):", LineKind::Declaration),
+            ("    if n % 15 == 0:", header("if")),
+            ("    # Check divisibility", LineKind::Comment),
+            ("@dataclass", LineKind::Declaration),
+            // Ruby
+            ("def fizzbuzz(n)", LineKind::Declaration),
+            ("  end", LineKind::BlockEnd),
+            ("  else", header("else")),
+            ("  [1, 2].each do", LineKind::Statement),
+            // JavaScript
+            ("function fizzbuzz(n) {", LineKind::Declaration),
+            ("export default function main() {", LineKind::Declaration),
+            ("  for (let i = 1; i <= 100; i++) {", header("for")),
+            ("});", LineKind::BlockEnd),
+            // Rust
+            ("pub fn fizzbuzz(n: u32) -> String {", LineKind::Declaration),
+            ("#[derive(Debug)]", LineKind::Declaration),
+            ("    match n % 15 {", header("match")),
+            ("    let x = 1; // not a header", LineKind::Statement),
+            ("", LineKind::Blank),
+        ];
+        for (line, expected) in cases {
+            assert_eq!(&classify(line), expected, "{:?}", line);
+        }
+    }
+
+    #[test]
+    fn test_explanations() {
+        assert_eq!(
+            explain(13, 14, Some("\tif n%15 == 0 {"), None, None),
+            "line 13 is not a statement (if header); moved to 14, the first statement of the if body"
+        );
+        assert_eq!(
+            explain(12, 13, Some("def fizzbuzz(n):"), None, Some("")),
+            "line 12 is not a statement (declaration); moved to 13, the first statement of its body"
+        );
+        assert_eq!(
+            explain(21, 20, Some("  }"), None, None),
+            "line 21 is not a statement (end of a block); moved to 20, the previous line with code"
+        );
+        assert_eq!(
+            explain(8, 9, Some("  x = compute()"), Some(&[9, 10]), None),
+            "line 8 has no breakpoint location; moved to 9, the next line with code"
+        );
+        assert_eq!(
+            explain(8, 9, None, None, Some("adjusted to nearest line")),
+            "the debugger moved the breakpoint from line 8 to 9 (debugger: adjusted to nearest line)"
+        );
+    }
+}
@@ -0,0 +1,243 @@
+//! Breakpoints that stop only when called from a function
+//!
+//! "Stop in `Divide` only when called from `main`" is a breakpoint with a
+//! caller constraint: a function name pattern and how many frames above the
+//! breakpoint's to look in. When a breakpoint stop is for such breakpoints
+//! only, the session loads the frames above the top one and continues at
+//! once unless one of them matches, up to `MAX_CALLER_SKIPS` times in a row;
+//! the next mismatch then stops with `limit_reached`. The stop a match
+//! causes reports the caller frame that matched.
+//!
+//! The check is the server's for every language: a breakpoint condition
+//! runs inside the debugger's own evaluation frames, so it can't tell who
+//! called the breakpoint's function reliably. A `condition` on the same
+//! breakpoint is still checked by the debugger first, without stopping.
+//!
+//! As with unsubscribed breakpoints (see `subscription`), only stops the
+//! adapter reports `hitBreakpointIds` for can be matched, and a stop while a
+//! step or pause is on its way is never continued.
+
+use crate::dap::types::StackFrame;
+use crate::{Error, Result};
+use regex::Regex;
+use serde::{Deserialize, Serialize};
+
+/// Callers looked at when the breakpoint doesn't say: the direct one
+pub const DEFAULT_CALLER_DEPTH: usize = 1;
+
+/// Most callers a constraint may look at
+pub const MAX_CALLER_DEPTH: usize = 3;
+
+/// Mismatching hits continued past in a row before one is reported anyway
+pub const MAX_CALLER_SKIPS: u32 = 1000;
+
+/// Which function a breakpoint's function must be called from
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct CallerConstraint {
+    /// Function name pattern: `*` matches any run of characters; without
+    /// one, the name matches as a whole or as its last qualified part, so
+    /// `main` matches Go's `main.main` and `Multiply` `main.(*Calculator).Multiply`
+    pub pattern: String,
+    /// Callers looked at, nearest first (1: the direct caller)
+    pub depth: usize,
+}
+
+impl CallerConstraint {
+    /// Fails on an empty pattern or a depth outside 1..=`MAX_CALLER_DEPTH`
+    pub fn new(pattern: &str, depth: Option<usize>) -> Result<Self> {
+        let pattern = pattern.trim();
+        if pattern.is_empty() {
+            return Err(Error::InvalidRequest(
+                "caller is empty; give a function name pattern like 'main' or '*Handler'"
+                    .to_string(),
+            ));
+        }
+        let depth = depth.unwrap_or(DEFAULT_CALLER_DEPTH);
+        if !(1..=MAX_CALLER_DEPTH).contains(&depth) {
+            return Err(Error::InvalidRequest(format!(
+                "callerDepth must be between 1 and {}",
+                MAX_CALLER_DEPTH
+            )));
+        }
+        Ok(Self {
+            pattern: pattern.to_string(),
+            depth,
+        })
+    }
+
+    /// Whether function `name` matches the pattern
+    pub fn matches(&self, name: &str) -> bool {
+        if self.pattern.contains('*') {
+            let body = self
+                .pattern
+                .split('*')
+                .map(regex::escape)
+                .collect::<Vec<_>>()
+                .join(".*");
+            return Regex::new(&format!("^{}$", body)).is_ok_and(|re| re.is_match(name));
+        }
+        name == self.pattern
+            || [".", "#", "::", "/"].iter().any(|separator| {
+                name.strip_suffix(self.pattern.as_str())
+                    .is_some_and(|qualifier| qualifier.ends_with(separator))
+            })
+    }
+
+    /// The nearest of `callers` (the frames above the breakpoint's, nearest
+    /// first) within `depth` that matches, with its depth
+    pub fn find<'a>(&self, callers: &'a [StackFrame]) -> Option<(usize, &'a StackFrame)> {
+        callers
+            .iter()
+            .take(self.depth)
+            .enumerate()
+            .find(|(_, frame)| self.matches(&frame.name))
+            .map(|(index, frame)| (index + 1, frame))
+    }
+}
+
+/// The caller a constrained breakpoint stopped the program for
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct CallerMatch {
+    pub breakpoint_id: i32,
+    pub caller: String,
+    /// The matching frame: its function, where it is and how far above the
+    /// breakpoint's frame (None with `limit_reached`)
+    pub function: Option<String>,
+    pub source_path: Option<String>,
+    pub line: Option<i32>,
+    pub depth: Option<usize>,
+    /// Mismatching hits continued past before this stop
+    pub skipped: u32,
+    /// No caller matched, but `MAX_CALLER_SKIPS` hits were continued past
+    #[serde(skip_serializing_if = "std::ops::Not::not")]
+    pub limit_reached: bool,
+}
+
+impl CallerMatch {
+    pub fn matched(
+        breakpoint_id: i32,
+        constraint: &CallerConstraint,
+        depth: usize,
+        frame: &StackFrame,
+        skipped: u32,
+    ) -> Self {
+        Self {
+            breakpoint_id,
+            caller: constraint.pattern.clone(),
+            function: Some(frame.name.clone()),
+            source_path: frame.source.as_ref().and_then(|s| s.path.clone()),
+            line: Some(frame.line),
+            depth: Some(depth),
+            skipped,
+            limit_reached: false,
+        }
+    }
+
+    /// A mismatch stopping the program after `MAX_CALLER_SKIPS` skips
+    pub fn limit_reached(breakpoint_id: i32, constraint: &CallerConstraint, skipped: u32) -> Self {
+        Self {
+            breakpoint_id,
+            caller: constraint.pattern.clone(),
+            function: None,
+            source_path: None,
+            line: None,
+            depth: None,
+            skipped,
+            limit_reached: true,
+        }
+    }
+}
+
+/// What to do with a stop of caller-constrained breakpoints
+#[derive(Debug, Clone, PartialEq)]
+pub enum CallerCheck {
+    /// No caller matched; continue
+    Continue,
+    /// Report the stop, with the caller that matched
+    Stop(CallerMatch),
+}
+
+/// Check the callers of a stop of the breakpoints `constraints`
+///
+/// `frames` is the stopped thread's stack from the top, `skipped` the
+/// mismatches continued past in a row so far.
+pub fn check(
+    constraints: &[(i32, CallerConstraint)],
+    frames: &[StackFrame],
+    skipped: u32,
+) -> CallerCheck {
+    let callers = frames.get(1..).unwrap_or_default();
+    for (id, constraint) in constraints {
+        if let Some((depth, frame)) = constraint.find(callers) {
+            return CallerCheck::Stop(CallerMatch::matched(*id, constraint, depth, frame, skipped));
+        }
+    }
+    match constraints.first() {
+        Some((id, constraint)) if skipped >= MAX_CALLER_SKIPS => {
+            CallerCheck::Stop(CallerMatch::limit_reached(*id, constraint, skipped))
+        }
+        _ => CallerCheck::Continue,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn frame(name: &str) -> StackFrame {
+        StackFrame {
+            id: 1,
+            name: name.to_string(),
+            source: None,
+            line: 7,
+            column: 1,
+            end_line: None,
+            end_column: None,
+            instruction_pointer_reference: None,
+        }
+    }
+
+    #[test]
+    fn test_patterns_match_qualified_names() {
+        let main = CallerConstraint::new("main", None).unwrap();
+        assert!(main.matches("main"));
+        assert!(main.matches("main.main"));
+        assert!(main.matches("app::main"));
+        assert!(!main.matches("main.mainLoop"));
+        assert!(!main.matches("domain"));
+
+        let multiply = CallerConstraint::new("*Calculator*Multiply", None).unwrap();
+        assert!(multiply.matches("main.(*Calculator).Multiply"));
+        assert!(!multiply.matches("main.(*Calculator).Divide"));
+
+        assert!(CallerConstraint::new(" ", None).is_err());
+        assert!(CallerConstraint::new("main", Some(0)).is_err());
+        assert!(CallerConstraint::new("main", Some(MAX_CALLER_DEPTH + 1)).is_err());
+    }
+
+    #[test]
+    fn test_check_looks_within_depth() {
+        let frames = [
+            frame("main.Divide"),
+            frame("main.helper"),
+            frame("main.main"),
+        ];
+        let direct = vec![(4, CallerConstraint::new("main", None).unwrap())];
+        assert_eq!(check(&direct, &frames, 0), CallerCheck::Continue);
+
+        let two_up = vec![(4, CallerConstraint::new("main", Some(2)).unwrap())];
+        let CallerCheck::Stop(found) = check(&two_up, &frames, 3) else {
+            panic!("main is two frames up");
+        };
+        assert_eq!(found.function.as_deref(), Some("main.main"));
+        assert_eq!((found.depth, found.skipped), (Some(2), 3));
+
+        let CallerCheck::Stop(limit) = check(&direct, &frames, MAX_CALLER_SKIPS) else {
+            panic!("the limit stops the program");
+        };
+        assert!(limit.limit_reached);
+        assert_eq!(limit.function, None);
+    }
+}
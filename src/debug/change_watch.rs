@@ -0,0 +1,393 @@
+//! Stop when an expression changes value
+//!
+//! Data breakpoints are rare among the adapters (only CodeLLDB has them, for
+//! memory), so `debugger_watch_change` emulates "stop when `total` changes"
+//! with a breakpoint on a line the expression is read at. The line is
+//! watched in one of two ways:
+//!
+//! - `AdapterCondition` (Python, Ruby, Node.js): the breakpoint's condition
+//!   stashes the expression's printed value in a global of the debuggee and
+//!   is true only when it differs from the stashed one. The debugger checks
+//!   it on every hit without stopping; the first hit only takes the value.
+//! - `ServerLoop` (Go, Rust, whose conditions can't assign): every hit
+//!   stops, the server evaluates the expression in the top frame and
+//!   continues at once while the value is the one seen before, up to
+//!   `max_auto_continues` times. The stop is then reported like a change,
+//!   with `limit_reached`.
+//!
+//! Values are compared as printed (`repr`, `inspect`, `JSON.stringify`, or
+//! the debugger's display), so a list mutated in place counts as changed.
+//! The stop a change causes reports the old and the new value.
+//!
+//! A breakpoint condition of the form `changed(expr)` is the same watch set
+//! with `debugger_set_breakpoint`: the server keeps the value `expr` had at
+//! the previous hit and watches the line with the server loop whatever the
+//! language, so nothing is stashed in the debuggee. The loop's bound and
+//! overhead apply: unchanged hits are continued at once, up to
+//! `DEFAULT_MAX_AUTO_CONTINUES` of them.
+
+use serde::Serialize;
+
+/// Auto-continues of a server-side watch when the caller doesn't say
+pub const DEFAULT_MAX_AUTO_CONTINUES: u64 = 1000;
+
+/// Most auto-continues a server-side watch may be given
+pub const MAX_AUTO_CONTINUES: u64 = 100_000;
+
+/// How a watch finds out the value changed
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub enum WatchStrategy {
+    /// A breakpoint condition comparing to a value stashed in the debuggee
+    AdapterCondition,
+    /// The server stops at every hit, evaluates and continues if unchanged
+    ServerLoop,
+}
+
+impl WatchStrategy {
+    /// The strategy `language` supports
+    pub fn for_language(language: &str) -> Self {
+        match language {
+            "python" | "ruby" | "nodejs" | "javascript" => WatchStrategy::AdapterCondition,
+            _ => WatchStrategy::ServerLoop,
+        }
+    }
+
+    /// What watching costs, for the caller to weigh
+    pub fn overhead(self) -> &'static str {
+        match self {
+            WatchStrategy::AdapterCondition => {
+                "The debugger evaluates a condition at every hit of the line without stopping the program; only a change stops it. Each hit costs one evaluation inside the debuggee."
+            }
+            WatchStrategy::ServerLoop => {
+                "Every hit of the line stops the program: the server evaluates the expression and continues when it is unchanged, a few debugger round trips (milliseconds) per hit. Loops hitting the line often run much slower; the watch stops after maxAutoContinues unchanged hits."
+            }
+        }
+    }
+}
+
+/// A watched expression and what was seen of it
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ChangeWatch {
+    pub id: u32,
+    pub expression: String,
+    pub source_path: String,
+    pub line: i32,
+    pub strategy: WatchStrategy,
+    pub max_auto_continues: u64,
+    /// Value at the last hit the server evaluated (server loop only)
+    pub value: Option<String>,
+    /// Hits the server saw: every hit for the server loop, changes only for
+    /// a condition
+    pub hits: u64,
+    pub auto_continued: u64,
+    /// Expressions reading the previous and current stashed value (adapter
+    /// condition only)
+    #[serde(skip)]
+    pub stash: Option<(String, String)>,
+    /// Set by a `changed(expr)` breakpoint condition rather than
+    /// `debugger_watch_change`
+    #[serde(skip)]
+    pub from_condition: bool,
+}
+
+/// A stop caused by a watch
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct WatchStop {
+    pub watch_id: u32,
+    pub expression: String,
+    pub strategy: WatchStrategy,
+    /// None when no value was seen before (or the stash can't be read)
+    pub old_value: Option<String>,
+    pub new_value: Option<String>,
+    /// Why the value couldn't be read; the watch stops rather than guess
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub error: Option<String>,
+    /// Stopped after `max_auto_continues` unchanged hits, not a change
+    #[serde(skip_serializing_if = "std::ops::Not::not")]
+    pub limit_reached: bool,
+    pub hits: u64,
+    pub auto_continued: u64,
+}
+
+/// What to do at a hit of a server-side watch
+#[derive(Debug, Clone, PartialEq)]
+pub enum Observation {
+    Continue,
+    Stop(WatchStop),
+}
+
+impl ChangeWatch {
+    pub fn new(
+        id: u32,
+        language: &str,
+        expression: &str,
+        source_path: &str,
+        line: i32,
+        max_auto_continues: u64,
+    ) -> Self {
+        Self {
+            id,
+            expression: expression.to_string(),
+            source_path: source_path.to_string(),
+            line,
+            strategy: WatchStrategy::for_language(language),
+            max_auto_continues,
+            value: None,
+            hits: 0,
+            auto_continued: 0,
+            stash: stashed_values(language, id),
+            from_condition: false,
+        }
+    }
+
+    /// A watch the server evaluates whatever the language supports, for
+    /// `changed(expr)` conditions
+    pub fn server_loop(
+        id: u32,
+        expression: &str,
+        source_path: &str,
+        line: i32,
+        max_auto_continues: u64,
+    ) -> Self {
+        Self {
+            strategy: WatchStrategy::ServerLoop,
+            stash: None,
+            from_condition: true,
+            ..Self::new(id, "", expression, source_path, line, max_auto_continues)
+        }
+    }
+
+    /// Take the value read at a hit of a server-side watch
+    ///
+    /// The first value is the baseline and an unchanged one continues, until
+    /// `max_auto_continues`; a changed value or an error stops.
+    pub fn observe(&mut self, value: std::result::Result<String, String>) -> Observation {
+        self.hits += 1;
+        let (value, error) = match value {
+            Ok(value) => (Some(value), None),
+            Err(e) => (None, Some(e)),
+        };
+        let unchanged = error.is_none() && (self.hits == 1 || value == self.value);
+        if unchanged && self.auto_continued < self.max_auto_continues {
+            self.auto_continued += 1;
+            self.value = value;
+            return Observation::Continue;
+        }
+        let limit_reached = unchanged;
+        let old_value = if self.hits > 1 {
+            self.value.clone()
+        } else {
+            None
+        };
+        if value.is_some() {
+            self.value = value.clone();
+        }
+        Observation::Stop(WatchStop {
+            watch_id: self.id,
+            expression: self.expression.clone(),
+            strategy: self.strategy,
+            old_value,
+            new_value: value,
+            error,
+            limit_reached,
+            hits: self.hits,
+            auto_continued: self.auto_continued,
+        })
+    }
+
+    /// The stop of an adapter-side watch, whose condition saw a change
+    pub fn changed(&mut self, old_value: Option<String>, new_value: Option<String>) -> WatchStop {
+        self.hits += 1;
+        WatchStop {
+            watch_id: self.id,
+            expression: self.expression.clone(),
+            strategy: self.strategy,
+            old_value,
+            new_value,
+            error: None,
+            limit_reached: false,
+            hits: self.hits,
+            auto_continued: 0,
+        }
+    }
+}
+
+/// The expression of a `changed(expr)` breakpoint condition; None when the
+/// condition is anything else, including `changed(a) && b`
+pub fn changed_expression(condition: &str) -> Option<&str> {
+    let inner = condition
+        .trim()
+        .strip_prefix("changed(")?
+        .strip_suffix(')')?;
+    // The parenthesis closing `changed(` must be the last one
+    let mut depth = 0usize;
+    let mut quote = None;
+    for c in inner.chars() {
+        match (quote, c) {
+            (Some(q), c) if c == q => quote = None,
+            (Some(_), _) => {}
+            (None, '"' | '\'') => quote = Some(c),
+            (None, '(' | '[' | '{') => depth += 1,
+            (None, ')' | ']' | '}') => depth = depth.checked_sub(1)?,
+            _ => {}
+        }
+    }
+    let inner = inner.trim();
+    (depth == 0 && quote.is_none() && !inner.is_empty()).then_some(inner)
+}
+
+/// Global of the debuggee a watch stashes values in
+fn stash_name(language: &str, id: u32) -> String {
+    match language {
+        "ruby" => format!("$__mcp_watch_{}", id),
+        "nodejs" | "javascript" => format!("globalThis.__mcpWatch{}", id),
+        _ => format!("_mcp_watch_{}", id),
+    }
+}
+
+/// Breakpoint condition true when `expression` prints differently than at
+/// the last hit; None for languages whose conditions can't assign
+///
+/// The stash holds the previous and the current printed value and whether a
+/// value was seen yet.
+pub fn condition(language: &str, id: u32, expression: &str) -> Option<String> {
+    let stash = stash_name(language, id);
+    match language {
+        "python" => Some(format!(
+            "(lambda _s, _v: (lambda _p: (setattr(_s, '{stash}', (_p[1], _v, True)), _p[2] and _p[1] != _v)[1])(getattr(_s, '{stash}', (None, None, False))))(__import__('sys'), repr({expression}))"
+        )),
+        "ruby" => Some(format!(
+            "->(v) {{ c = !{stash}.nil? && {stash}[1] != v; {stash} = [({stash} || [])[1], v]; c }}.call(({expression}).inspect)"
+        )),
+        "nodejs" | "javascript" => Some(format!(
+            "((w, v) => {{ const c = w.seen === true && w.cur !== v; w.prev = w.cur; w.cur = v; w.seen = true; return c; }})({stash} ??= {{}}, ((x) => {{ try {{ return JSON.stringify(x) ?? String(x); }} catch {{ return String(x); }} }})({expression}))"
+        )),
+        _ => None,
+    }
+}
+
+/// Expressions reading the previous and the current printed value from the
+/// stash of an adapter-side watch
+pub fn stashed_values(language: &str, id: u32) -> Option<(String, String)> {
+    let stash = stash_name(language, id);
+    match language {
+        "python" => Some((
+            format!("getattr(__import__('sys'), '{}')[0]", stash),
+            format!("getattr(__import__('sys'), '{}')[1]", stash),
+        )),
+        "ruby" => Some((format!("{}[0]", stash), format!("{}[1]", stash))),
+        "nodejs" | "javascript" => Some((format!("{}.prev", stash), format!("{}.cur", stash))),
+        _ => None,
+    }
+}
+
+/// A stashed printed value as the debugger shows it: a string, so without
+/// the quotes the debugger adds around it
+pub fn unquote(value: &str) -> String {
+    let quoted = value.len() >= 2
+        && ((value.starts_with('\'') && value.ends_with('\''))
+            || (value.starts_with('"') && value.ends_with('"')));
+    if quoted {
+        value[1..value.len() - 1].to_string()
+    } else {
+        value.to_string()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn watch(max_auto_continues: u64) -> ChangeWatch {
+        ChangeWatch::new(1, "go", "total", "/w/main.go", 12, max_auto_continues)
+    }
+
+    #[test]
+    fn test_server_loop_continues_until_the_value_changes() {
+        let mut watch = watch(10);
+        assert_eq!(watch.strategy, WatchStrategy::ServerLoop);
+        assert_eq!(watch.observe(Ok("0".to_string())), Observation::Continue);
+        assert_eq!(watch.observe(Ok("0".to_string())), Observation::Continue);
+        let Observation::Stop(stop) = watch.observe(Ok("3".to_string())) else {
+            panic!("expected a stop");
+        };
+        assert_eq!(stop.old_value.as_deref(), Some("0"));
+        assert_eq!(stop.new_value.as_deref(), Some("3"));
+        assert_eq!((stop.hits, stop.auto_continued), (3, 2));
+        assert!(!stop.limit_reached);
+
+        // The next change is measured from the new value
+        assert_eq!(watch.observe(Ok("3".to_string())), Observation::Continue);
+    }
+
+    #[test]
+    fn test_server_loop_stops_at_the_limit_and_on_errors() {
+        let mut watch = watch(1);
+        assert_eq!(watch.observe(Ok("0".to_string())), Observation::Continue);
+        let Observation::Stop(stop) = watch.observe(Ok("0".to_string())) else {
+            panic!("expected a stop");
+        };
+        assert!(stop.limit_reached);
+
+        let mut watch = super::tests::watch(10);
+        let Observation::Stop(stop) = watch.observe(Err("undefined: total".to_string())) else {
+            panic!("expected a stop");
+        };
+        assert_eq!(stop.error.as_deref(), Some("undefined: total"));
+        assert_eq!(stop.old_value, None);
+        assert!(!stop.limit_reached);
+    }
+
+    #[test]
+    fn test_conditions_by_language() {
+        assert_eq!(
+            WatchStrategy::for_language("python"),
+            WatchStrategy::AdapterCondition
+        );
+        assert!(condition("go", 1, "total").is_none());
+        assert!(stashed_values("rust", 1).is_none());
+
+        let python = condition("python", 3, "total").unwrap();
+        assert!(python.contains("setattr(_s, '_mcp_watch_3'"));
+        assert!(python.ends_with("repr(total))"));
+        let ruby = condition("ruby", 3, "items.sum").unwrap();
+        assert!(ruby.contains("$__mcp_watch_3[1] != v"));
+        assert!(ruby.ends_with(".call((items.sum).inspect)"));
+        let node = condition("nodejs", 3, "state.count").unwrap();
+        assert!(node.contains("globalThis.__mcpWatch3 ??= {}"));
+        assert!(node.ends_with("})(state.count))"));
+        assert_eq!(
+            stashed_values("nodejs", 3).unwrap().0,
+            "globalThis.__mcpWatch3.prev"
+        );
+
+        assert_eq!(unquote("'[1, 2]'"), "[1, 2]");
+        assert_eq!(unquote("\"3\""), "3");
+        assert_eq!(unquote("42"), "42");
+        assert_eq!(unquote("'"), "'");
+    }
+
+    #[test]
+    fn test_changed_conditions() {
+        assert_eq!(changed_expression("changed(total)"), Some("total"));
+        assert_eq!(
+            changed_expression(" changed( items[len(items) - 1] ) "),
+            Some("items[len(items) - 1]")
+        );
+        assert_eq!(
+            changed_expression("changed(s == \")\")"),
+            Some("s == \")\"")
+        );
+        assert_eq!(changed_expression("changed(a) && changed(b)"), None);
+        assert_eq!(changed_expression("changed(a) || b(1)"), None);
+        assert_eq!(changed_expression("changed()"), None);
+        assert_eq!(changed_expression("x > 3"), None);
+
+        let watch = ChangeWatch::server_loop(2, "total", "/w/app.py", 4, 10);
+        assert_eq!(watch.strategy, WatchStrategy::ServerLoop);
+        assert_eq!(watch.stash, None);
+    }
+}
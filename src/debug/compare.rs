@@ -0,0 +1,158 @@
+//! One expression, two sessions
+//!
+//! A/B debugging runs the same program twice (a passing and a failing
+//! input, two versions) and looks for where the runs diverge.
+//! `debugger_compare_sessions` evaluates an expression in the top frame of
+//! each session's stopped thread and lists the results side by side, with
+//! whether they are equal. A session the expression can't be evaluated in
+//! (not stopped, unknown, a name not in scope) gets a status and error of
+//! its own instead of failing the whole call.
+//!
+//! Values are compared in full, then cut to a share of the response size
+//! cap like those of `thread_eval`.
+
+use super::state::DebugState;
+use super::thread_eval;
+use serde::Serialize;
+
+/// Whether a session's value could be evaluated
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub enum SideStatus {
+    Evaluated,
+    /// Running, starting or ended; `state` says which
+    NotStopped,
+    /// The evaluation failed; `error` says why
+    Failed,
+    /// No session with the id
+    NotFound,
+}
+
+/// The expression's value (or why there is none) in one session
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SessionValue {
+    pub session_id: String,
+    pub status: SideStatus,
+    /// The session's state when it isn't stopped
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub state: Option<DebugState>,
+    /// Where the value was evaluated: the stopped thread's top frame
+    pub thread_id: Option<i32>,
+    pub function: Option<String>,
+    pub line: Option<i32>,
+    pub value: Option<String>,
+    pub error: Option<String>,
+    /// Whether `value` was cut
+    #[serde(skip_serializing_if = "std::ops::Not::not")]
+    pub truncated: bool,
+}
+
+impl SessionValue {
+    pub fn new(session_id: &str, status: SideStatus) -> Self {
+        Self {
+            session_id: session_id.to_string(),
+            status,
+            state: None,
+            thread_id: None,
+            function: None,
+            line: None,
+            value: None,
+            error: None,
+            truncated: false,
+        }
+    }
+
+    /// A session that couldn't be found
+    pub fn not_found(session_id: &str, error: String) -> Self {
+        Self {
+            error: Some(error),
+            ..Self::new(session_id, SideStatus::NotFound)
+        }
+    }
+}
+
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct Comparison {
+    pub expression: String,
+    pub sessions: Vec<SessionValue>,
+    /// Whether every session evaluated the expression to the same value;
+    /// None unless all of them evaluated it
+    pub equal: Option<bool>,
+}
+
+/// Compare the full values of `sessions`, then fit each to its share of a
+/// `max_response_bytes` response
+pub fn compare(
+    expression: &str,
+    mut sessions: Vec<SessionValue>,
+    max_response_bytes: usize,
+) -> Comparison {
+    let equal = sessions
+        .iter()
+        .all(|side| side.status == SideStatus::Evaluated)
+        .then(|| {
+            sessions
+                .windows(2)
+                .all(|pair| pair[0].value == pair[1].value)
+        });
+
+    let budget = thread_eval::value_budget(max_response_bytes, sessions.len());
+    for side in &mut sessions {
+        if let Some(value) = side.value.take() {
+            let (value, truncated) = thread_eval::fit_value(&value, budget);
+            side.value = Some(value);
+            side.truncated = truncated;
+        }
+    }
+    Comparison {
+        expression: expression.to_string(),
+        sessions,
+        equal,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn evaluated(session_id: &str, value: &str) -> SessionValue {
+        SessionValue {
+            value: Some(value.to_string()),
+            ..SessionValue::new(session_id, SideStatus::Evaluated)
+        }
+    }
+
+    #[test]
+    fn test_compares_full_values_before_cutting() {
+        let long = "x".repeat(200);
+        let comparison = compare(
+            "total",
+            vec![
+                evaluated("a", &format!("{}1", long)),
+                evaluated("b", &format!("{}2", long)),
+            ],
+            256,
+        );
+        assert_eq!(comparison.equal, Some(false));
+        assert!(comparison.sessions.iter().all(|side| side.truncated));
+        assert_eq!(comparison.sessions[0].value, comparison.sessions[1].value);
+
+        let comparison = compare("n", vec![evaluated("a", "3"), evaluated("b", "3")], 256);
+        assert_eq!(comparison.equal, Some(true));
+    }
+
+    #[test]
+    fn test_equal_needs_every_value() {
+        let mut running = SessionValue::new("b", SideStatus::NotStopped);
+        running.state = Some(DebugState::Running);
+        let comparison = compare("n", vec![evaluated("a", "3"), running], 1024);
+        assert_eq!(comparison.equal, None);
+
+        let json = serde_json::to_value(&comparison).unwrap();
+        assert_eq!(json["sessions"][1]["status"], "notStopped");
+        assert_eq!(json["sessions"][1]["state"], "Running");
+        assert_eq!(json["sessions"][0]["value"], "3");
+    }
+}
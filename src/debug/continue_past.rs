@@ -0,0 +1,119 @@
+//! Continuing past a breakpoint a number of times
+//!
+//! When the interesting iteration of a loop is the 37th, a hit condition
+//! does it, but the breakpoint is often already set and all that is wanted
+//! is "skip 36 hits". `debugger_continue_past` continues and, while the
+//! `ContinuePast` is armed, the stop handler continues again at once every
+//! time the breakpoint is the sole cause of a stop, until its `count`-th
+//! hit. A stop for anything else (another breakpoint, an exception, the
+//! breakpoint together with another) ends it early and is reported as
+//! interrupting it.
+//!
+//! As with unsubscribed breakpoints (see `subscription`), only stops the
+//! adapter reports `hitBreakpointIds` for can be matched, and a stop while a
+//! step or pause is on its way is never continued.
+
+use serde::Serialize;
+
+/// An armed `debugger_continue_past`
+#[derive(Debug, Clone, PartialEq)]
+pub struct ContinuePast {
+    pub breakpoint_id: i32,
+    /// The hit to stop at
+    pub count: u32,
+    /// Hits of the breakpoint alone so far
+    pub hits: u32,
+}
+
+impl ContinuePast {
+    pub fn new(breakpoint_id: i32, count: u32) -> Self {
+        Self {
+            breakpoint_id,
+            count,
+            hits: 0,
+        }
+    }
+
+    /// Count a breakpoint stop; whether it is to be continued
+    pub fn skips(&mut self, hit_ids: &[i32]) -> bool {
+        if hit_ids != [self.breakpoint_id] {
+            return false;
+        }
+        self.hits += 1;
+        self.hits < self.count
+    }
+
+    /// Whether the breakpoint's `count`-th hit was reached
+    pub fn reached(&self) -> bool {
+        self.hits >= self.count
+    }
+}
+
+/// How `debugger_continue_past` ended
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub enum ContinuePastStatus {
+    /// Stopped at the breakpoint's `count`-th hit
+    Reached,
+    /// Something else stopped the program first
+    Interrupted,
+    /// The program ended first
+    Terminated,
+    /// Still running when the timeout ran out; nothing is skipped anymore
+    TimedOut,
+}
+
+/// What `debugger_continue_past` returns
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ContinuePastReport {
+    pub status: ContinuePastStatus,
+    pub breakpoint_id: i32,
+    pub count: u32,
+    /// Hits continued past
+    pub skipped: u32,
+    /// The stop it ended with (Reached, Interrupted)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub thread_id: Option<i32>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub reason: Option<String>,
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub hit_breakpoint_ids: Vec<i32>,
+}
+
+impl ContinuePastReport {
+    pub fn new(status: ContinuePastStatus, past: &ContinuePast) -> Self {
+        Self {
+            status,
+            breakpoint_id: past.breakpoint_id,
+            count: past.count,
+            skipped: past.hits.min(past.count.saturating_sub(1)),
+            thread_id: None,
+            reason: None,
+            hit_breakpoint_ids: Vec::new(),
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_skips_sole_hits_until_count() {
+        let mut past = ContinuePast::new(5, 3);
+        assert!(past.skips(&[5]));
+        assert!(!past.skips(&[5, 6]));
+        assert!(!past.skips(&[]));
+        assert!(past.skips(&[5]));
+        assert!(!past.reached());
+        assert!(!past.skips(&[5]));
+        assert!(past.reached());
+
+        let report = ContinuePastReport::new(ContinuePastStatus::Reached, &past);
+        assert_eq!(report.skipped, 2);
+        let mut once = ContinuePast::new(5, 1);
+        assert!(!once.skips(&[5]));
+        assert!(once.reached());
+    }
+}
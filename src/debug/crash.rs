@@ -0,0 +1,304 @@
+//! Triage reports for programs dying of an unhandled exception or panic
+//!
+//! When the program stops on an exception nothing handles, the adapter is
+//! about to tear it down: once it is resumed or disconnected, the stack, the
+//! locals and the source positions are gone. A `CrashReport` collects them
+//! while they still exist, together with the program's last lines of output,
+//! and stays in the session state after the program terminates.
+
+use super::state::CapturedLocal;
+use crate::dap::types::{ExceptionInfo, StackFrame};
+use serde::Serialize;
+use std::collections::VecDeque;
+
+/// Frames of the crashing thread in a report
+pub const CRASH_FRAMES: usize = 10;
+
+/// Lines of source shown above and below each frame's line
+pub const SNIPPET_CONTEXT_LINES: usize = 2;
+
+/// Lines of program output kept for reports
+pub const OUTPUT_TAIL_LINES: usize = 50;
+
+/// Longest output line kept, in characters
+const OUTPUT_LINE_CHARS: usize = 500;
+
+/// Path fragments marking library code (installed packages, the standard
+/// library, runtime internals), per language
+const LIBRARY_PATHS: &[(&str, &[&str])] = &[
+    (
+        "python",
+        &[
+            "/site-packages/",
+            "/dist-packages/",
+            "/lib/python3",
+            "<frozen ",
+        ],
+    ),
+    (
+        "go",
+        &[
+            "/pkg/mod/",
+            "/usr/local/go/src/",
+            "/usr/lib/go/src/",
+            "/usr/lib/golang/src/",
+        ],
+    ),
+    ("ruby", &["/gems/", "/lib/ruby/", "<internal:"]),
+    (
+        "nodejs",
+        &["/node_modules/", "node:internal", "<node_internals>"],
+    ),
+    (
+        "rust",
+        &[
+            "/.cargo/registry/",
+            "/rustc/",
+            "/library/std/",
+            "/library/core/",
+        ],
+    ),
+];
+
+/// Function name prefixes marking library code, per language
+const LIBRARY_FUNCTIONS: &[(&str, &[&str])] = &[("go", &["runtime.", "testing."])];
+
+/// Whether an exception stop is fatal: nothing in the program handles it
+pub fn is_fatal(info: &ExceptionInfo) -> bool {
+    matches!(info.break_mode.as_str(), "unhandled" | "userUnhandled")
+}
+
+/// Whether a frame belongs to library code rather than the program
+///
+/// Frames without a source path (runtime assembly, native code) count as
+/// library code: there is nothing to show for them.
+pub fn is_library_frame(language: &str, frame: &StackFrame) -> bool {
+    let Some(path) = frame.source.as_ref().and_then(|s| s.path.as_deref()) else {
+        return true;
+    };
+    let listed = |lists: &[(&str, &[&str])], matches: &dyn Fn(&str) -> bool| {
+        lists
+            .iter()
+            .filter(|(lang, _)| *lang == language)
+            .flat_map(|(_, entries)| entries.iter())
+            .any(|entry| matches(entry))
+    };
+    let path = path.replace('\\', "/");
+    listed(LIBRARY_PATHS, &|fragment| path.contains(fragment))
+        || listed(LIBRARY_FUNCTIONS, &|prefix| frame.name.starts_with(prefix))
+}
+
+/// Lines around `line` (1-based) of a source file
+pub fn snippet(content: &str, line: i32) -> Vec<SourceLine> {
+    let center = line.max(1) as usize;
+    let start = center.saturating_sub(SNIPPET_CONTEXT_LINES).max(1);
+    content
+        .lines()
+        .enumerate()
+        .skip(start - 1)
+        .take(center + SNIPPET_CONTEXT_LINES + 1 - start)
+        .map(|(i, text)| SourceLine {
+            line: i + 1,
+            text: text.to_string(),
+        })
+        .collect()
+}
+
+/// The last lines the program wrote to stdout and stderr
+#[derive(Debug, Clone, Default)]
+pub struct OutputTail {
+    lines: VecDeque<String>,
+    /// Output after the last newline
+    partial: String,
+}
+
+impl OutputTail {
+    /// Add the text of an `output` event; events may split lines anywhere
+    pub fn push(&mut self, text: &str) {
+        self.partial.push_str(text);
+        while let Some(end) = self.partial.find('\n') {
+            let line: String = self.partial.drain(..=end).collect();
+            let line = line.trim_end_matches(['\n', '\r']);
+            let line = match line.char_indices().nth(OUTPUT_LINE_CHARS) {
+                Some((cut, _)) => format!("{}...", &line[..cut]),
+                None => line.to_string(),
+            };
+            if self.lines.len() == OUTPUT_TAIL_LINES {
+                self.lines.pop_front();
+            }
+            self.lines.push_back(line);
+        }
+    }
+
+    /// Kept lines, oldest first, including an unterminated last line
+    pub fn lines(&self) -> Vec<String> {
+        let mut lines: Vec<String> = self.lines.iter().cloned().collect();
+        if !self.partial.is_empty() {
+            lines.push(self.partial.clone());
+        }
+        let excess = lines.len().saturating_sub(OUTPUT_TAIL_LINES);
+        lines.split_off(excess)
+    }
+
+    pub fn clear(&mut self) {
+        self.lines.clear();
+        self.partial.clear();
+    }
+}
+
+#[derive(Debug, Clone, PartialEq, Serialize)]
+pub struct SourceLine {
+    pub line: usize,
+    pub text: String,
+}
+
+/// A frame of the crashing thread
+#[derive(Debug, Clone, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct CrashFrame {
+    pub id: i32,
+    pub name: String,
+    pub path: Option<String>,
+    pub line: i32,
+    /// Library code (see `is_library_frame`)
+    pub library: bool,
+    /// Lines around `line`, empty if the source couldn't be read
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub source: Vec<SourceLine>,
+}
+
+impl CrashFrame {
+    pub fn new(language: &str, frame: &StackFrame, source: Vec<SourceLine>) -> Self {
+        Self {
+            id: frame.id,
+            name: frame.name.clone(),
+            path: frame.source.as_ref().and_then(|s| s.path.clone()),
+            line: frame.line,
+            library: is_library_frame(language, frame),
+            source,
+        }
+    }
+}
+
+/// Everything known about a fatal exception at the moment it stopped the program
+#[derive(Debug, Clone, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct CrashReport {
+    pub thread_id: i32,
+    pub exception: ExceptionInfo,
+    /// Innermost `CRASH_FRAMES` frames
+    pub frames: Vec<CrashFrame>,
+    /// Innermost frame that isn't library code, whose locals are listed
+    pub user_frame: Option<String>,
+    pub locals: Vec<CapturedLocal>,
+    pub locals_truncated: bool,
+    /// Last lines of program output, redacted
+    pub output: Vec<String>,
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::dap::types::Source;
+
+    fn frame(name: &str, path: Option<&str>) -> StackFrame {
+        StackFrame {
+            id: 1,
+            name: name.to_string(),
+            source: path.map(|p| Source {
+                name: None,
+                path: Some(p.to_string()),
+                source_reference: None,
+                checksums: None,
+            }),
+            line: 1,
+            column: 0,
+            end_line: None,
+            end_column: None,
+            instruction_pointer_reference: None,
+        }
+    }
+
+    #[test]
+    fn test_library_frames_classified_per_language() {
+        let cases = [
+            ("python", "parse", Some("/w/app/parse.py"), false),
+            (
+                "python",
+                "loads",
+                Some("/usr/lib/python3.11/json/__init__.py"),
+                true,
+            ),
+            (
+                "python",
+                "get",
+                Some("/venv/lib/python3.11/site-packages/requests/api.py"),
+                true,
+            ),
+            ("go", "main.main", Some("/w/main.go"), false),
+            (
+                "go",
+                "runtime.gopanic",
+                Some("/usr/local/go/src/runtime/panic.go"),
+                true,
+            ),
+            (
+                "go",
+                "runtime.goexit",
+                Some("/opt/go/src/runtime/asm.s"),
+                true,
+            ),
+            (
+                "go",
+                "github.com/x/y.Parse",
+                Some("/root/go/pkg/mod/github.com/x/y@v1.2.0/y.go"),
+                true,
+            ),
+            ("ruby", "Foo#bar", Some("/w/lib/foo.rb"), false),
+            (
+                "nodejs",
+                "handler",
+                Some("C:\\w\\node_modules\\x\\i.js"),
+                true,
+            ),
+            ("rust", "main", None, true),
+        ];
+        for (language, name, path, library) in cases {
+            assert_eq!(
+                is_library_frame(language, &frame(name, path)),
+                library,
+                "{} {} {:?}",
+                language,
+                name,
+                path
+            );
+        }
+    }
+
+    #[test]
+    fn test_output_tail_keeps_last_lines() {
+        let mut tail = OutputTail::default();
+        tail.push("starting\nrow 0");
+        tail.push("\r\n");
+        for i in 1..=OUTPUT_TAIL_LINES {
+            tail.push(&format!("row {}\n", i));
+        }
+        tail.push("Traceback (most recent");
+
+        let lines = tail.lines();
+        assert_eq!(lines.len(), OUTPUT_TAIL_LINES);
+        assert_eq!(lines[0], "row 2");
+        assert_eq!(lines.last().unwrap(), "Traceback (most recent");
+    }
+
+    #[test]
+    fn test_snippet_around_line() {
+        let content = "a\nb\nc\nd\ne\nf\n";
+        let lines: Vec<usize> = snippet(content, 1).iter().map(|l| l.line).collect();
+        assert_eq!(lines, vec![1, 2, 3]);
+        let around = snippet(content, 4);
+        assert_eq!(around.first().unwrap().text, "b");
+        assert_eq!(around.len(), 5);
+        assert_eq!(snippet(content, 6).last().unwrap().line, 6);
+    }
+}
@@ -0,0 +1,112 @@
+//! Paged disassembly
+//!
+//! A function can run to thousands of instructions, far more than fits in a
+//! response. Disassembly is read a window at a time, counted in instructions
+//! from a memory reference (by default the current instruction of the
+//! stopped thread). The last window is remembered per session, so the next
+//! and previous windows can be read without repeating the reference.
+
+use crate::dap::types::DisassembledInstruction;
+use serde::Serialize;
+
+/// Instructions in a window when the caller doesn't say
+pub const DEFAULT_INSTRUCTIONS: i64 = 50;
+
+/// Largest window a caller may request
+pub const MAX_INSTRUCTIONS: i64 = 200;
+
+/// Position and size of a disassembly window
+#[derive(Debug, Clone, PartialEq)]
+pub struct DisassemblyWindow {
+    /// Memory reference the window is counted from
+    pub memory_reference: String,
+    /// Instructions between the reference and the first of the window
+    pub instruction_offset: i64,
+    pub instruction_count: i64,
+}
+
+impl DisassemblyWindow {
+    /// The window right after this one
+    pub fn next(&self) -> Self {
+        Self {
+            instruction_offset: self.instruction_offset + self.instruction_count,
+            ..self.clone()
+        }
+    }
+
+    /// The window right before this one
+    pub fn prev(&self) -> Self {
+        Self {
+            instruction_offset: self.instruction_offset - self.instruction_count,
+            ..self.clone()
+        }
+    }
+}
+
+/// One window of disassembly as returned to clients
+#[derive(Debug, Clone, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct DisassemblyPage {
+    pub memory_reference: String,
+    pub instruction_offset: i64,
+    pub instructions: Vec<DisassembledInstruction>,
+    /// Index in `instructions` of the stopped thread's current instruction
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub current_index: Option<usize>,
+}
+
+/// Numeric value of an address like `0x00000000004a1f20`
+fn parse_address(address: &str) -> Option<u64> {
+    let digits = address
+        .strip_prefix("0x")
+        .or_else(|| address.strip_prefix("0X"))?;
+    u64::from_str_radix(digits, 16).ok()
+}
+
+/// Index of the instruction at `pc`, comparing addresses by value
+pub fn find_instruction(instructions: &[DisassembledInstruction], pc: &str) -> Option<usize> {
+    let pc = parse_address(pc)?;
+    instructions
+        .iter()
+        .position(|i| parse_address(&i.address) == Some(pc))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn instruction(address: &str) -> DisassembledInstruction {
+        DisassembledInstruction {
+            address: address.to_string(),
+            instruction_bytes: None,
+            instruction: "NOP".to_string(),
+            symbol: None,
+            location: None,
+            line: None,
+        }
+    }
+
+    #[test]
+    fn test_windows_scroll_by_their_size() {
+        let window = DisassemblyWindow {
+            memory_reference: "0x4a1f20".to_string(),
+            instruction_offset: -25,
+            instruction_count: 50,
+        };
+        assert_eq!(window.next().instruction_offset, 25);
+        assert_eq!(window.prev().instruction_offset, -75);
+        assert_eq!(window.next().prev(), window);
+    }
+
+    #[test]
+    fn test_current_instruction_found_by_value() {
+        let instructions = vec![
+            instruction("0x00000000004a1f1c"),
+            instruction("0x00000000004a1f20"),
+            instruction("invalid"),
+        ];
+        assert_eq!(find_instruction(&instructions, "0x4a1f20"), Some(1));
+        assert_eq!(find_instruction(&instructions, "0x4a1f24"), None);
+        assert_eq!(find_instruction(&instructions, "main.go:12"), None);
+    }
+}
@@ -0,0 +1,655 @@
+//! A variable's value as plain JSON
+//!
+//! `debugger_dump_variable` walks the children of a variable (named like
+//! `debugger_peek` names, `user.orders[0]`) and turns the tree into JSON an
+//! agent can read or compare directly:
+//!
+//! - primitives become JSON numbers, booleans, null and strings where the
+//!   debugger's rendering parses (`parse_primitive`); anything else stays
+//!   the string the debugger showed
+//! - structs, objects, maps, dicts and hashes become objects, keyed by field
+//!   name or unquoted key
+//! - arrays, slices and lists become arrays, in index order; the type the
+//!   debugger gives tells them from maps (`adapters::holds_elements`), the
+//!   children's names only where it doesn't
+//! - a pointer with a single child (Delve's `*p`) stands for what it points
+//!   to
+//!
+//! All fields are kept, Go's unexported ones and Python's `_private` and
+//! name-mangled attributes included; what the debuggers list besides the
+//! value (debugpy's `len()` and special variables, js-debug's
+//! `[[Prototype]]`) is left out, see `adapters::child_kind`.
+//!
+//! A value met again below itself, recognized by its variables reference or
+//! by the address in its rendering (`0xc000010030`) together with its type,
+//! is written `"<cycle>"`. The address alone isn't an identity: a struct
+//! and its first field share one.
+//! Below `max_depth` levels a value is written `"<max depth>"`; once
+//! `max_nodes` values are written, the rest of each container is replaced by
+//! one `"<max nodes: N more>"` marker (an array element, or the value of an
+//! object's `"<truncated>"` key), and `truncated` tells either happened.
+//!
+//! Primitives are redacted (`config::redact`) before they are parsed.
+//!
+//! Like peek, the walk is done in rounds: `build` reports the children it
+//! still needs, the session fetches them, and builds again.
+
+use super::peek::Fetch;
+use crate::adapters::{child_kind, element_index, holds_elements, ChildKind};
+use crate::dap::types::Variable;
+use crate::{Error, Result};
+use serde::Serialize;
+use serde_json::{Map, Value};
+use std::collections::HashMap;
+
+pub const DEFAULT_MAX_DEPTH: usize = 5;
+pub const MAX_DEPTH: usize = 20;
+pub const DEFAULT_MAX_NODES: usize = 1000;
+pub const MAX_NODES: usize = 10_000;
+
+pub const CYCLE: &str = "<cycle>";
+pub const MAX_DEPTH_MARKER: &str = "<max depth>";
+
+/// How much of a value to write
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct Limits {
+    pub max_depth: usize,
+    pub max_nodes: usize,
+}
+
+impl Limits {
+    /// Limits from a request, defaulted and checked against the maximums
+    pub fn new(max_depth: Option<usize>, max_nodes: Option<usize>) -> Result<Self> {
+        let max_depth = max_depth.unwrap_or(DEFAULT_MAX_DEPTH);
+        let max_nodes = max_nodes.unwrap_or(DEFAULT_MAX_NODES);
+        if !(1..=MAX_DEPTH).contains(&max_depth) {
+            return Err(Error::InvalidRequest(format!(
+                "maxDepth must be 1 to {}",
+                MAX_DEPTH
+            )));
+        }
+        if !(1..=MAX_NODES).contains(&max_nodes) {
+            return Err(Error::InvalidRequest(format!(
+                "maxNodes must be 1 to {}",
+                MAX_NODES
+            )));
+        }
+        Ok(Self {
+            max_depth,
+            max_nodes,
+        })
+    }
+}
+
+/// A variable written as JSON
+#[derive(Debug, Clone, PartialEq, Serialize)]
+pub struct Dump {
+    pub value: Value,
+    /// Values written, containers included
+    pub nodes: usize,
+    /// Whether a depth or node limit cut the value short
+    pub truncated: bool,
+}
+
+/// Write `root` as JSON with the children fetched so far; the children
+/// still to fetch come back too, and the dump is complete once there are
+/// none
+pub fn build(
+    root: &Variable,
+    language: &str,
+    children: &HashMap<Fetch, Vec<Variable>>,
+    limits: Limits,
+) -> (Dump, Vec<Fetch>) {
+    let mut builder = Builder {
+        language,
+        children,
+        limits,
+        nodes: 0,
+        truncated: false,
+        needed: Vec::new(),
+        ancestors: Vec::new(),
+    };
+    let value = builder.value(root, 0);
+    let dump = Dump {
+        value,
+        nodes: builder.nodes,
+        truncated: builder.truncated,
+    };
+    (dump, builder.needed)
+}
+
+struct Builder<'a> {
+    language: &'a str,
+    children: &'a HashMap<Fetch, Vec<Variable>>,
+    limits: Limits,
+    nodes: usize,
+    truncated: bool,
+    needed: Vec<Fetch>,
+    /// Reference and typed address of each container above the current value
+    ancestors: Vec<(i32, Option<(String, String)>)>,
+}
+
+impl Builder<'_> {
+    fn value(&mut self, variable: &Variable, depth: usize) -> Value {
+        self.nodes += 1;
+        let reference = variable.variables_reference;
+        if reference <= 0 {
+            return parse_primitive(self.language, &crate::config::redact(&variable.value));
+        }
+        let identity = address(&variable.value).zip(variable.type_.clone());
+        if self.ancestors.iter().any(|(ancestor, ancestor_identity)| {
+            *ancestor == reference || (identity.is_some() && *ancestor_identity == identity)
+        }) {
+            return Value::String(CYCLE.to_string());
+        }
+        if depth >= self.limits.max_depth {
+            self.truncated = true;
+            return Value::String(MAX_DEPTH_MARKER.to_string());
+        }
+        let Some(children) = self.fetched(variable) else {
+            return Value::Null;
+        };
+
+        self.ancestors.push((reference, identity));
+        let value = match children.as_slice() {
+            // A pointer: the value is what it points to
+            [pointee] if pointee.name.is_empty() || pointee.name.starts_with('*') => {
+                self.nodes -= 1;
+                self.value(pointee, depth)
+            }
+            _ if is_array(self.language, variable, &children) => {
+                self.array(variable, &children, depth)
+            }
+            _ => self.object(variable, &children, depth),
+        };
+        self.ancestors.pop();
+        value
+    }
+
+    /// The children of `variable` that make up its value, groups flattened;
+    /// None while some still have to be fetched
+    fn fetched(&mut self, variable: &Variable) -> Option<Vec<Variable>> {
+        let fetch = match variable.indexed_variables {
+            Some(length) => Fetch {
+                reference: variable.variables_reference,
+                page: Some((0, length.min(self.limits.max_nodes as i64))),
+            },
+            None => Fetch::all(variable.variables_reference),
+        };
+        let Some(listed) = self.children.get(&fetch) else {
+            self.needed.push(fetch);
+            return None;
+        };
+        let mut children = Vec::new();
+        let mut complete = true;
+        for child in listed {
+            match child_kind(self.language, &child.name) {
+                ChildKind::Value => children.push(child.clone()),
+                ChildKind::Synthetic => {}
+                ChildKind::Group => match self.children.get(&Fetch::all(child.variables_reference))
+                {
+                    Some(grouped) => children.extend(grouped.iter().cloned()),
+                    None => {
+                        self.needed.push(Fetch::all(child.variables_reference));
+                        complete = false;
+                    }
+                },
+            }
+        }
+        complete.then_some(children)
+    }
+
+    fn array(&mut self, variable: &Variable, children: &[Variable], depth: usize) -> Value {
+        let mut elements: Vec<(i64, &Variable)> = children
+            .iter()
+            .enumerate()
+            .filter_map(
+                |(i, child)| match element_index(self.language, &child.name) {
+                    Some(index) => Some((index, child)),
+                    // Counted elements come in order, whatever their names
+                    None if variable.indexed_variables.is_some() => Some((i as i64, child)),
+                    // `length`, `cap()`
+                    None => None,
+                },
+            )
+            .collect();
+        elements.sort_by_key(|(index, _)| *index);
+        let length = variable
+            .indexed_variables
+            .unwrap_or(elements.len() as i64)
+            .max(elements.len() as i64) as usize;
+
+        let mut array = Vec::new();
+        for (_, element) in elements {
+            if self.nodes >= self.limits.max_nodes {
+                break;
+            }
+            array.push(self.value(element, depth + 1));
+        }
+        if array.len() < length {
+            self.truncated = true;
+            array.push(Value::String(more(length - array.len())));
+        }
+        Value::Array(array)
+    }
+
+    fn object(&mut self, variable: &Variable, children: &[Variable], depth: usize) -> Value {
+        // A counted map (Delve's) is fetched a page of max_nodes long
+        let length = variable
+            .indexed_variables
+            .unwrap_or(0)
+            .max(children.len() as i64) as usize;
+        let mut object = Map::new();
+        let mut written = 0;
+        for child in children {
+            if self.nodes >= self.limits.max_nodes {
+                break;
+            }
+            let value = self.value(child, depth + 1);
+            object.insert(unquote(&child.name).unwrap_or(child.name.clone()), value);
+            written += 1;
+        }
+        if written < length {
+            self.truncated = true;
+            object.insert(
+                "<truncated>".to_string(),
+                Value::String(more(length - written)),
+            );
+        }
+        Value::Object(object)
+    }
+}
+
+fn more(count: usize) -> String {
+    format!("<max nodes: {} more>", count)
+}
+
+/// Whether a value's children are elements: its type says so, or, for types
+/// that don't tell, the debugger counts them (`indexedVariables`) or every
+/// child is named like an element, but for a `length` or `cap()` some
+/// debuggers list with them
+fn is_array(language: &str, variable: &Variable, children: &[Variable]) -> bool {
+    if let Some(elements) = variable
+        .type_
+        .as_deref()
+        .and_then(|type_| holds_elements(language, type_))
+    {
+        return elements;
+    }
+    if variable.indexed_variables.is_some() {
+        return true;
+    }
+    let (elements, others): (Vec<&Variable>, Vec<&Variable>) = children
+        .iter()
+        .partition(|child| element_index(language, &child.name).is_some());
+    !elements.is_empty()
+        && others
+            .iter()
+            .all(|child| matches!(child.name.as_str(), "length" | "cap()"))
+}
+
+/// The address in a value's rendering (`(*main.Node)(0xc000010030)`,
+/// `<Node object at 0x7f3a...>`, `#<Node:0x000055d...>`), which tells the
+/// same object apart when the debugger hands out a new reference for it
+fn address(value: &str) -> Option<String> {
+    let start = value.find("0x")?;
+    let digits = value[start + 2..]
+        .chars()
+        .take_while(|c| c.is_ascii_hexdigit())
+        .count();
+    // Shorter ones are more likely numbers than addresses
+    (digits >= 6).then(|| value[start..start + 2 + digits].to_string())
+}
+
+/// A primitive as the JSON value it renders, by how the debugger of
+/// `language` renders them; the rendering itself where it doesn't parse
+pub fn parse_primitive(language: &str, value: &str) -> Value {
+    let value = value.trim();
+    let null = match language {
+        "python" => &["None"][..],
+        "go" => &["nil", "<nil>"],
+        "ruby" => &["nil"],
+        "nodejs" => &["null", "undefined"],
+        _ => &["null"],
+    };
+    if null.contains(&value) {
+        return Value::Null;
+    }
+    let (yes, no) = match language {
+        "python" => ("True", "False"),
+        _ => ("true", "false"),
+    };
+    if value == yes || value == no {
+        return Value::Bool(value == yes);
+    }
+    if let Some(number) = parse_number(value) {
+        return number;
+    }
+    // Delve writes bytes and runes with their character (`97 = 0x61`,
+    // `97 'a'`)
+    if language == "go" {
+        if let Some((number, rest)) = value.split_once(' ') {
+            if rest.starts_with("= 0x") || rest.starts_with('\'') {
+                if let Some(number) = parse_number(number) {
+                    return number;
+                }
+            }
+        }
+    }
+    if let Some(text) = unquote(value) {
+        return Value::String(text);
+    }
+    Value::String(value.to_string())
+}
+
+/// A JSON number from a decimal integer or float; None for integers out of
+/// range and non-finite floats, which JSON numbers can't hold exactly
+fn parse_number(value: &str) -> Option<Value> {
+    let first = value.chars().next()?;
+    if !(first.is_ascii_digit() || (first == '-' && value.len() > 1)) {
+        return None;
+    }
+    if let Ok(n) = value.parse::<i64>() {
+        return Some(Value::from(n));
+    }
+    if let Ok(n) = value.parse::<u64>() {
+        return Some(Value::from(n));
+    }
+    if value.chars().all(|c| c.is_ascii_digit() || c == '-') {
+        return None;
+    }
+    let n = value.parse::<f64>().ok()?;
+    serde_json::Number::from_f64(n).map(Value::Number)
+}
+
+/// The text of a quoted string or key (`"a\"b"`, `'x'`), escapes resolved
+fn unquote(value: &str) -> Option<String> {
+    let quote = value.chars().next().filter(|c| matches!(c, '"' | '\''))?;
+    let inner = value
+        .strip_prefix(quote)?
+        .strip_suffix(quote)
+        .filter(|_| value.len() >= 2)?;
+    let mut text = String::with_capacity(inner.len());
+    let mut chars = inner.chars();
+    while let Some(c) = chars.next() {
+        if c != '\\' {
+            text.push(c);
+            continue;
+        }
+        match chars.next() {
+            Some('n') => text.push('\n'),
+            Some('t') => text.push('\t'),
+            Some('r') => text.push('\r'),
+            Some('0') => text.push('\0'),
+            Some(other @ ('\\' | '"' | '\'')) => text.push(other),
+            // Left as written: \x41, \u{1F600}, ...
+            Some(other) => {
+                text.push('\\');
+                text.push(other);
+            }
+            None => text.push('\\'),
+        }
+    }
+    Some(text)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    fn var(name: &str, value: &str, reference: i32) -> Variable {
+        Variable {
+            name: name.to_string(),
+            value: value.to_string(),
+            type_: None,
+            variables_reference: reference,
+            indexed_variables: None,
+            encoding_lossy: false,
+        }
+    }
+
+    fn limits() -> Limits {
+        Limits::new(None, None).unwrap()
+    }
+
+    /// Build until nothing more is needed, fetching from `tree`
+    fn dump(
+        root: &Variable,
+        language: &str,
+        tree: &HashMap<Fetch, Vec<Variable>>,
+        limits: Limits,
+    ) -> Dump {
+        let mut children = HashMap::new();
+        loop {
+            let (dump, needed) = build(root, language, &children, limits);
+            if needed.is_empty() {
+                return dump;
+            }
+            for fetch in needed {
+                children.insert(fetch, tree[&fetch].clone());
+            }
+        }
+    }
+
+    #[test]
+    fn test_primitives_by_language() {
+        let cases = [
+            ("go", "42", json!(42)),
+            ("go", "-7", json!(-7)),
+            ("go", "3.5", json!(3.5)),
+            ("go", "1e+06", json!(1e6)),
+            ("go", "true", json!(true)),
+            ("go", "nil", json!(null)),
+            ("go", "97 = 0x61", json!(97)),
+            ("go", "97 'a'", json!(97)),
+            ("go", "\"a \\\"quoted\\\"\\n\"", json!("a \"quoted\"\n")),
+            ("go", "18446744073709551615", json!(u64::MAX)),
+            ("python", "True", json!(true)),
+            ("python", "None", json!(null)),
+            ("python", "'it\\'s'", json!("it's")),
+            ("python", "\"it's\"", json!("it's")),
+            (
+                "python",
+                "123456789012345678901234567890",
+                json!("123456789012345678901234567890"),
+            ),
+            ("python", "inf", json!("inf")),
+            ("python", "b'\\x00'", json!("b'\\x00'")),
+            ("ruby", "nil", json!(null)),
+            ("ruby", ":sym", json!(":sym")),
+            ("ruby", "\"text\"", json!("text")),
+            ("nodejs", "undefined", json!(null)),
+            ("nodejs", "'js'", json!("js")),
+            ("nodejs", "NaN", json!("NaN")),
+            ("rust", "'a'", json!("a")),
+            ("rust", "-0.25", json!(-0.25)),
+            ("java", "null", json!(null)),
+            ("java", "\"s\"", json!("s")),
+            ("java", "LocalDate@42", json!("LocalDate@42")),
+        ];
+        for (language, value, expected) in cases {
+            assert_eq!(
+                parse_primitive(language, value),
+                expected,
+                "{} {}",
+                language,
+                value
+            );
+        }
+        // Each language has its own null and booleans
+        assert_eq!(parse_primitive("go", "None"), json!("None"));
+        assert_eq!(parse_primitive("python", "true"), json!("true"));
+    }
+
+    #[test]
+    fn test_go_struct_with_slice_map_and_cycle() {
+        let mut root = var("node", "(*main.Node)(0xc000010030)", 1);
+        root.type_ = Some("*main.Node".to_string());
+        let mut next = var("next", "(*main.Node)(0xc000010030)", 6);
+        next.type_ = root.type_.clone();
+        let mut items = var("items", "[]int len: 3, cap: 4", 4);
+        items.indexed_variables = Some(3);
+        // Delve counts map entries too
+        let mut tags = var("tags", "map[string]bool [...]", 5);
+        tags.type_ = Some("map[string]bool".to_string());
+        tags.indexed_variables = Some(1);
+        let mut tree = HashMap::new();
+        tree.insert(Fetch::all(1), vec![var("", "main.Node {...}", 2)]);
+        tree.insert(
+            Fetch::all(2),
+            vec![
+                var("Name", "\"head\"", 0),
+                var("secret", "7", 0),
+                items,
+                tags,
+                next,
+            ],
+        );
+        tree.insert(
+            Fetch {
+                reference: 4,
+                page: Some((0, 3)),
+            },
+            vec![var("[0]", "1", 0), var("[1]", "2", 0), var("[2]", "3", 0)],
+        );
+        tree.insert(
+            Fetch {
+                reference: 5,
+                page: Some((0, 1)),
+            },
+            vec![var("\"on\"", "true", 0)],
+        );
+
+        let dump = dump(&root, "go", &tree, limits());
+        assert_eq!(
+            dump.value,
+            json!({
+                "Name": "head",
+                "secret": 7,
+                "items": [1, 2, 3],
+                "tags": {"on": true},
+                "next": "<cycle>"
+            })
+        );
+        assert!(!dump.truncated);
+        assert_eq!(dump.nodes, 10);
+    }
+
+    #[test]
+    fn test_types_decide_arrays_and_shared_addresses() {
+        // A dict keyed 0, 1 has children named like list elements
+        let mut counts = var("counts", "{0: 'a', 1: 'b'}", 1);
+        counts.type_ = Some("dict".to_string());
+        let mut tree = HashMap::new();
+        tree.insert(
+            Fetch::all(1),
+            vec![var("0", "'a'", 0), var("1", "'b'", 0), var("len()", "2", 0)],
+        );
+        let dict = dump(&counts, "python", &tree, limits());
+        assert_eq!(dict.value, json!({"0": "a", "1": "b"}));
+
+        // A struct and a pointer to its first field share an address
+        let mut pair = var("p", "(*main.Pair)(0xc000010030)", 1);
+        pair.type_ = Some("*main.Pair".to_string());
+        let mut first = var("First", "(*main.Inner)(0xc000010030)", 3);
+        first.type_ = Some("*main.Inner".to_string());
+        let mut tree = HashMap::new();
+        tree.insert(Fetch::all(1), vec![var("", "main.Pair {...}", 2)]);
+        tree.insert(Fetch::all(2), vec![first]);
+        tree.insert(Fetch::all(3), vec![var("", "main.Inner {...}", 4)]);
+        tree.insert(Fetch::all(4), vec![var("N", "1", 0)]);
+        let pair = dump(&pair, "go", &tree, limits());
+        assert_eq!(pair.value, json!({"First": {"N": 1}}));
+    }
+
+    #[test]
+    fn test_python_object_keeps_private_attributes() {
+        let root = var("order", "<app.Order object at 0x7f3a2c1b9d60>", 1);
+        let mut tree = HashMap::new();
+        tree.insert(
+            Fetch::all(1),
+            vec![
+                var("special variables", "", 2),
+                var("function variables", "", 3),
+                var("_Order__total", "9.5", 0),
+                var("_status", "'open'", 0),
+                var("lines", "[1, 2]", 4),
+                var("protected variables", "", 5),
+            ],
+        );
+        tree.insert(
+            Fetch::all(4),
+            vec![var("0", "1", 0), var("1", "2", 0), var("len()", "2", 0)],
+        );
+        tree.insert(Fetch::all(5), vec![var("_cache", "None", 0)]);
+
+        let dump = dump(&root, "python", &tree, limits());
+        assert_eq!(
+            dump.value,
+            json!({
+                "_Order__total": 9.5,
+                "_status": "open",
+                "lines": [1, 2],
+                "_cache": null
+            })
+        );
+    }
+
+    #[test]
+    fn test_javascript_array_and_prototype() {
+        let root = var("list", "Array(2)", 1);
+        let mut tree = HashMap::new();
+        tree.insert(
+            Fetch::all(1),
+            vec![
+                var("0", "'a'", 0),
+                var("1", "{x: 1}", 2),
+                var("length", "2", 0),
+                var("[[Prototype]]", "Array(0)", 3),
+            ],
+        );
+        tree.insert(
+            Fetch::all(2),
+            vec![var("x", "1", 0), var("[[Prototype]]", "Object", 3)],
+        );
+        let dump = dump(&root, "nodejs", &tree, limits());
+        assert_eq!(dump.value, json!(["a", {"x": 1}]));
+    }
+
+    #[test]
+    fn test_limits_leave_markers() {
+        let root = var("outer", "{...}", 1);
+        let mut big = var("big", "[]int len: 50", 3);
+        big.indexed_variables = Some(50);
+        let mut tree = HashMap::new();
+        tree.insert(
+            Fetch::all(1),
+            vec![var("inner", "{...}", 2), big, var("after", "1", 0)],
+        );
+        tree.insert(Fetch::all(2), vec![var("deep", "{...}", 4)]);
+        tree.insert(
+            Fetch {
+                reference: 3,
+                page: Some((0, 8)),
+            },
+            (0..8).map(|i| var(&format!("[{}]", i), "0", 0)).collect(),
+        );
+
+        let limits = Limits::new(Some(2), Some(8)).unwrap();
+        let dump = dump(&root, "go", &tree, limits);
+        assert!(dump.truncated);
+        // outer, inner, deep, big and 4 elements make 8 nodes
+        assert_eq!(
+            dump.value,
+            json!({
+                "inner": {"deep": "<max depth>"},
+                "big": [0, 0, 0, 0, "<max nodes: 46 more>"],
+                "<truncated>": "<max nodes: 1 more>"
+            })
+        );
+
+        assert!(Limits::new(Some(0), None).is_err());
+        assert!(Limits::new(None, Some(MAX_NODES + 1)).is_err());
+    }
+}
@@ -0,0 +1,118 @@
+//! Log of the changes that advance a session's `events_seq`
+//!
+//! Every change a poller sees as a new `eventsSeq` (a state change, a
+//! debuggee process, a `memory` event, a relaunch) is kept with its sequence
+//! number, so `debugger_events` can hand a client everything that happened
+//! after the `eventsSeq` it last saw instead of only the latest state. The
+//! last `MAX_SESSION_EVENTS` are kept; a client further behind is told how
+//! many it missed.
+
+use super::state::DebugState;
+use serde::Serialize;
+use serde_json::{json, Value};
+use std::collections::VecDeque;
+
+/// Events kept per session; older ones are dropped
+pub const MAX_SESSION_EVENTS: usize = 256;
+
+/// A change that advanced `events_seq`
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SessionEvent {
+    /// `events_seq` after the change
+    pub seq: u64,
+    /// Milliseconds since the session was created
+    pub at_ms: u64,
+    /// `state`, `process`, `memory` or `relaunched`
+    pub kind: String,
+    pub body: Value,
+}
+
+/// The last `MAX_SESSION_EVENTS` events, oldest first
+#[derive(Debug, Clone, Default)]
+pub struct EventLog {
+    events: VecDeque<SessionEvent>,
+}
+
+impl EventLog {
+    pub fn push(&mut self, event: SessionEvent) {
+        if self.events.len() == MAX_SESSION_EVENTS {
+            self.events.pop_front();
+        }
+        self.events.push_back(event);
+    }
+
+    /// Events after `since`, oldest first, and how many of them were
+    /// dropped already (`latest` is the current `events_seq`)
+    pub fn since(&self, since: u64, latest: u64) -> (Vec<SessionEvent>, u64) {
+        let events: Vec<SessionEvent> = self
+            .events
+            .iter()
+            .filter(|event| event.seq > since)
+            .cloned()
+            .collect();
+        let missed = latest.saturating_sub(since) - events.len() as u64;
+        (events, missed)
+    }
+
+    /// Whether the debug state changed after `since` (or events after it
+    /// were dropped, so it may have)
+    pub fn changed_state_since(&self, since: u64, latest: u64) -> bool {
+        let (events, missed) = self.since(since, latest);
+        missed > 0 || events.iter().any(|event| event.kind == "state")
+    }
+}
+
+/// Body of a `state` event: the state's name and details, as
+/// `debugger_session_state` reports them
+pub fn state_body(state: &DebugState) -> Value {
+    match state {
+        DebugState::NotStarted => json!({"state": "NotStarted"}),
+        DebugState::Initializing => json!({"state": "Initializing"}),
+        DebugState::Initialized => json!({"state": "Initialized"}),
+        DebugState::Launching => json!({"state": "Launching"}),
+        DebugState::Running => json!({"state": "Running"}),
+        DebugState::Stopped { thread_id, reason } => {
+            json!({"state": "Stopped", "threadId": thread_id, "reason": reason})
+        }
+        DebugState::Terminated => json!({"state": "Terminated"}),
+        DebugState::Failed { error } => json!({"state": "Failed", "error": error}),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn event(seq: u64) -> SessionEvent {
+        SessionEvent {
+            seq,
+            at_ms: seq * 10,
+            kind: "state".to_string(),
+            body: state_body(&DebugState::Running),
+        }
+    }
+
+    #[test]
+    fn test_events_since_a_seq() {
+        let mut log = EventLog::default();
+        for seq in 1..=3 {
+            log.push(event(seq));
+        }
+        let (events, missed) = log.since(1, 3);
+        assert_eq!(events.iter().map(|e| e.seq).collect::<Vec<_>>(), vec![2, 3]);
+        assert_eq!(missed, 0);
+        assert!(log.since(3, 3).0.is_empty());
+        assert!(log.changed_state_since(1, 3));
+        assert!(!log.changed_state_since(3, 3));
+
+        // A reader further behind than the log reaches learns what it missed
+        for seq in 4..=(MAX_SESSION_EVENTS as u64 + 10) {
+            log.push(event(seq));
+        }
+        let (events, missed) = log.since(0, MAX_SESSION_EVENTS as u64 + 10);
+        assert_eq!(events.len(), MAX_SESSION_EVENTS);
+        assert_eq!(events[0].seq, 11);
+        assert_eq!(missed, 10);
+    }
+}
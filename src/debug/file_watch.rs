@@ -0,0 +1,220 @@
+//! Re-launching on source changes (watch mode)
+//!
+//! Agents iterating on a fix run the same scenario after every edit. A
+//! session started with `watch: true` polls the modification times of the
+//! program's sources: the files of its language in the program's directory
+//! (for Go, the package directory; for a directory program, the directory
+//! itself). Once changes settle for the debounce window, the program is
+//! launched again with the same `debugger_start` arguments and the
+//! breakpoints are re-applied.
+//!
+//! A relaunch starts a new session; the old session id keeps working and
+//! leads to the new one. It never happens while the program is stopped,
+//! which would throw away an investigation in progress, or while it is
+//! still starting: changes wait until the program runs or has ended.
+
+use super::state::DebugState;
+use crate::{Error, Result};
+use serde::Serialize;
+use std::collections::{BTreeMap, BTreeSet};
+use std::path::{Path, PathBuf};
+use std::time::{Duration, Instant, SystemTime};
+
+/// Quiet time after the last change before relaunching, unless the caller
+/// says
+pub const DEFAULT_DEBOUNCE_MS: u64 = 500;
+
+/// Longest debounce window a caller may ask for
+pub const MAX_DEBOUNCE_MS: u64 = 60_000;
+
+/// How often modification times are checked
+pub const POLL_INTERVAL: Duration = Duration::from_millis(250);
+
+/// Most files one session watches
+pub const MAX_WATCHED_FILES: usize = 1000;
+
+/// What a watching session reports in `debugger_session_state`
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct WatchStatus {
+    pub files: usize,
+    pub debounce_ms: u64,
+    pub relaunches: u32,
+    pub last_relaunch: Option<Relaunch>,
+    /// Why the last relaunch failed; the old session is kept
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub error: Option<String>,
+}
+
+/// The `relaunched` event: a session started again after its sources changed
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct Relaunch {
+    pub previous_session_id: String,
+    pub changed_files: Vec<String>,
+    pub timestamp_ms: u64,
+}
+
+/// Whether a session in `state` may be relaunched: not while it is stopped
+/// or still starting
+pub fn may_relaunch(state: &DebugState) -> bool {
+    matches!(
+        state,
+        DebugState::Running | DebugState::Terminated | DebugState::Failed { .. }
+    )
+}
+
+/// Extensions of `language`'s source files
+fn source_extensions(language: &str) -> &'static [&'static str] {
+    match language {
+        "python" => &["py"],
+        "ruby" => &["rb"],
+        "nodejs" | "javascript" => &["js", "mjs", "cjs"],
+        "go" => &["go"],
+        "rust" => &["rs"],
+        _ => &[],
+    }
+}
+
+/// The sources to watch for `program`: files of the language in its
+/// directory, or in the program itself when it is a directory
+pub fn watched_files(language: &str, program: &Path) -> Result<Vec<PathBuf>> {
+    let dir = if program.is_dir() {
+        program
+    } else {
+        program.parent().unwrap_or(Path::new("."))
+    };
+    let extensions = source_extensions(language);
+    let entries = std::fs::read_dir(dir)
+        .map_err(|e| Error::InvalidRequest(format!("Can't watch {}: {}", dir.display(), e)))?;
+    let mut files: Vec<PathBuf> = entries
+        .filter_map(|entry| entry.ok().map(|entry| entry.path()))
+        .filter(|path| {
+            path.is_file()
+                && path
+                    .extension()
+                    .and_then(|e| e.to_str())
+                    .is_some_and(|e| extensions.contains(&e))
+        })
+        .collect();
+    if program.is_file() && !files.iter().any(|file| file == program) {
+        files.push(program.to_path_buf());
+    }
+    if files.is_empty() {
+        return Err(Error::InvalidRequest(format!(
+            "Nothing to watch: no {} sources in {}",
+            language,
+            dir.display()
+        )));
+    }
+    files.sort();
+    files.truncate(MAX_WATCHED_FILES);
+    Ok(files)
+}
+
+/// Modification times of watched files, and changes not acted on yet
+#[derive(Debug, Clone)]
+pub struct FileWatch {
+    mtimes: BTreeMap<PathBuf, Option<SystemTime>>,
+    debounce: Duration,
+    pending: BTreeSet<PathBuf>,
+    last_change: Option<Instant>,
+}
+
+impl FileWatch {
+    pub fn new(files: Vec<PathBuf>, debounce: Duration) -> Self {
+        let mtimes = files
+            .into_iter()
+            .map(|file| {
+                let mtime = modified(&file);
+                (file, mtime)
+            })
+            .collect();
+        Self {
+            mtimes,
+            debounce,
+            pending: BTreeSet::new(),
+            last_change: None,
+        }
+    }
+
+    pub fn files(&self) -> usize {
+        self.mtimes.len()
+    }
+
+    /// Check the files; true when changes have settled for the debounce
+    /// window (a deleted file counts as changed)
+    pub fn poll(&mut self, now: Instant) -> bool {
+        for (file, mtime) in &mut self.mtimes {
+            let current = modified(file);
+            if current != *mtime {
+                *mtime = current;
+                self.pending.insert(file.clone());
+                self.last_change = Some(now);
+            }
+        }
+        self.last_change
+            .is_some_and(|last| !self.pending.is_empty() && now - last >= self.debounce)
+    }
+
+    /// The changed files, which are then no longer pending
+    pub fn take_changes(&mut self) -> Vec<String> {
+        self.last_change = None;
+        std::mem::take(&mut self.pending)
+            .into_iter()
+            .map(|file| file.display().to_string())
+            .collect()
+    }
+}
+
+fn modified(file: &Path) -> Option<SystemTime> {
+    std::fs::metadata(file).and_then(|m| m.modified()).ok()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_changes_settle_before_relaunch() {
+        let dir = tempfile::tempdir().unwrap();
+        let program = dir.path().join("app.py");
+        std::fs::write(&program, "print(1)\n").unwrap();
+        std::fs::write(dir.path().join("util.py"), "x = 1\n").unwrap();
+        std::fs::write(dir.path().join("notes.txt"), "").unwrap();
+
+        let files = watched_files("python", &program).unwrap();
+        assert_eq!(files.len(), 2);
+        assert!(watched_files("go", &program).unwrap() == vec![program.clone()]);
+
+        let debounce = Duration::from_millis(500);
+        let mut watch = FileWatch::new(files, debounce);
+        let start = Instant::now();
+        assert!(!watch.poll(start));
+
+        let earlier = SystemTime::now() - Duration::from_secs(60);
+        std::fs::File::options()
+            .write(true)
+            .open(&program)
+            .unwrap()
+            .set_modified(earlier)
+            .unwrap();
+        assert!(!watch.poll(start));
+        assert!(!watch.poll(start + Duration::from_millis(400)));
+        assert!(watch.poll(start + debounce));
+        assert_eq!(watch.take_changes(), vec![program.display().to_string()]);
+        assert!(!watch.poll(start + debounce * 2));
+    }
+
+    #[test]
+    fn test_never_relaunched_while_stopped_or_starting() {
+        assert!(may_relaunch(&DebugState::Running));
+        assert!(may_relaunch(&DebugState::Terminated));
+        assert!(!may_relaunch(&DebugState::Stopped {
+            thread_id: 1,
+            reason: "breakpoint".to_string()
+        }));
+        assert!(!may_relaunch(&DebugState::Launching));
+        assert!(!may_relaunch(&DebugState::Initializing));
+    }
+}
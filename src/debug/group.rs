@@ -0,0 +1,95 @@
+//! Session groups: several debuggees started together
+//!
+//! Debugging a client/server pair, or a producer and its consumer, takes one
+//! session per process. A group names those sessions and lets tools fan out
+//! to all of them (continue, state, disconnect). Members are ordinary
+//! sessions: each stays addressable by its own id for stepping, evaluation
+//! and breakpoints, and knows its group and name so its stops say which
+//! member they came from.
+
+use crate::{Error, Result};
+use serde::Serialize;
+use std::collections::HashSet;
+use std::time::Instant;
+
+/// A named session in a group
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct GroupMember {
+    pub name: String,
+    pub session_id: String,
+}
+
+/// Sessions started together by `debugger_start_group`
+#[derive(Debug, Clone)]
+pub struct SessionGroup {
+    pub id: String,
+    /// In the order they were started
+    pub members: Vec<GroupMember>,
+}
+
+/// A session's place in a group, as reported with its state
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct Membership {
+    pub group_id: String,
+    pub name: String,
+}
+
+/// Check member names before anything is started: at least one member,
+/// every name non-empty and unique
+pub fn validate_member_names<S: AsRef<str>>(names: &[S]) -> Result<()> {
+    if names.is_empty() {
+        return Err(Error::InvalidRequest(
+            "A group needs at least one member".to_string(),
+        ));
+    }
+    let mut seen = HashSet::new();
+    for name in names.iter().map(AsRef::as_ref) {
+        if name.trim().is_empty() {
+            return Err(Error::InvalidRequest(
+                "Every group member needs a name".to_string(),
+            ));
+        }
+        if !seen.insert(name) {
+            return Err(Error::InvalidRequest(format!(
+                "Duplicate group member name '{}'",
+                name
+            )));
+        }
+    }
+    Ok(())
+}
+
+/// Index of the member that stopped first, given when each currently
+/// stopped member stopped (None for members that aren't stopped)
+pub fn first_stopped(stopped_at: &[Option<Instant>]) -> Option<usize> {
+    stopped_at
+        .iter()
+        .enumerate()
+        .filter_map(|(i, at)| at.map(|at| (i, at)))
+        .min_by_key(|(_, at)| *at)
+        .map(|(i, _)| i)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::time::Duration;
+
+    #[test]
+    fn test_member_names_and_first_stop() {
+        assert!(validate_member_names(&["server", "client"]).is_ok());
+        assert!(validate_member_names::<&str>(&[]).is_err());
+        assert!(validate_member_names(&["server", " "]).is_err());
+        let err = validate_member_names(&["server", "server"]).unwrap_err();
+        assert!(err
+            .to_string()
+            .contains("Duplicate group member name 'server'"));
+
+        let t0 = Instant::now();
+        let t1 = t0 + Duration::from_millis(5);
+        assert_eq!(first_stopped(&[Some(t1), None, Some(t0)]), Some(2));
+        assert_eq!(first_stopped(&[None, None]), None);
+    }
+}
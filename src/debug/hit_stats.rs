@@ -0,0 +1,357 @@
+//! Breakpoint hit statistics and the summary taken at the end of a session
+//!
+//! Every breakpoint stop is counted for the breakpoints the adapter names in
+//! its `hitBreakpointIds`, including stops the server continues at once
+//! (logpoints it emulates, unsubscribed breakpoints, unchanged watches). A
+//! breakpoint stop naming none, as several adapters send, counts for the
+//! breakpoints at its top frame's line, or in the total only when none is
+//! there.
+//! Only aggregates are kept, a count and the times of the first and last
+//! hit, so a breakpoint hit a million times costs as much as one hit once;
+//! beyond `MAX_TRACKED_BREAKPOINTS` ids, hits are only counted in total.
+//!
+//! Condition failures are known only where the server evaluates the
+//! condition itself: the server-loop watches of `debugger_watch_change`,
+//! whose unchanged hits it continues. An adapter evaluating a condition
+//! skips the false ones without telling anyone, so elsewhere they are
+//! reported unknown rather than as zero.
+//!
+//! When the session reaches a terminal state (Terminated or Failed) the
+//! statistics are summarized per breakpoint, busiest first, together with
+//! the hot path: the breakpoints most of the hits went to.
+//!
+//! A program that exits without ever hitting a breakpoint that was set and
+//! verified most likely ran past it before it was set, or never got there.
+//! Its termination carries an `ExitDiagnosis` saying so, with the exit code
+//! and how long the program ran, rather than leaving a bare Terminated.
+
+use serde::Serialize;
+use std::collections::HashMap;
+
+/// Breakpoint ids with statistics of their own
+pub const MAX_TRACKED_BREAKPOINTS: usize = 1000;
+
+/// Breakpoints named in the hot path at most
+pub const HOT_PATH_LEN: usize = 5;
+
+/// Hits of one breakpoint
+#[derive(Debug, Clone, Default, PartialEq)]
+pub struct HitStats {
+    pub hits: u64,
+    /// Milliseconds since the session was created
+    pub first_hit_ms: Option<u64>,
+    pub last_hit_ms: Option<u64>,
+    /// Hits continued because the server found the condition false
+    pub condition_failures: u64,
+}
+
+/// Hit statistics of a session's breakpoints, by breakpoint id
+#[derive(Debug, Clone, Default)]
+pub struct BreakpointHits {
+    by_id: HashMap<i32, HitStats>,
+    /// Hits of breakpoints beyond `MAX_TRACKED_BREAKPOINTS`, and breakpoint
+    /// stops naming no breakpoint
+    untracked: u64,
+}
+
+impl BreakpointHits {
+    /// Count a stop for the breakpoints `ids`, at `at_ms`
+    pub fn record(&mut self, ids: &[i32], at_ms: u64) {
+        if ids.is_empty() {
+            self.untracked += 1;
+        }
+        for id in ids {
+            if let Some(stats) = self.tracked(*id) {
+                stats.hits += 1;
+                stats.first_hit_ms.get_or_insert(at_ms);
+                stats.last_hit_ms = Some(at_ms);
+            } else {
+                self.untracked += 1;
+            }
+        }
+    }
+
+    /// Count a hit of breakpoint `id` continued for a false condition
+    pub fn record_condition_failure(&mut self, id: i32) {
+        if let Some(stats) = self.tracked(id) {
+            stats.condition_failures += 1;
+        }
+    }
+
+    /// Stops counted for breakpoint `id`
+    pub fn count(&self, id: i32) -> u64 {
+        self.by_id.get(&id).map_or(0, |stats| stats.hits)
+    }
+
+    pub fn get(&self, id: i32) -> Option<&HitStats> {
+        self.by_id.get(&id)
+    }
+
+    /// Stops counted for all breakpoints, tracked or not
+    pub fn total(&self) -> u64 {
+        self.by_id.values().map(|stats| stats.hits).sum::<u64>() + self.untracked
+    }
+
+    fn tracked(&mut self, id: i32) -> Option<&mut HitStats> {
+        if !self.by_id.contains_key(&id) && self.by_id.len() >= MAX_TRACKED_BREAKPOINTS {
+            return None;
+        }
+        Some(self.by_id.entry(id).or_default())
+    }
+
+    /// Summary of `breakpoints` (their location filled in, statistics
+    /// left for this to fill), taken at `at_ms`
+    ///
+    /// Ids with hits but no breakpoint any more (removed, or re-set under a
+    /// new id) are listed without a location.
+    pub fn summarize(
+        &self,
+        mut breakpoints: Vec<BreakpointSummaryEntry>,
+        at_ms: u64,
+    ) -> BreakpointSummary {
+        let listed: Vec<i32> = breakpoints.iter().map(|entry| entry.id).collect();
+        breakpoints.extend(
+            self.by_id
+                .keys()
+                .filter(|id| !listed.contains(id))
+                .map(|&id| BreakpointSummaryEntry {
+                    id,
+                    ..Default::default()
+                }),
+        );
+
+        let total_hits = self.total();
+        for entry in &mut breakpoints {
+            let stats = self.by_id.get(&entry.id).cloned().unwrap_or_default();
+            entry.hits = stats.hits;
+            entry.first_hit_ms = stats.first_hit_ms;
+            entry.last_hit_ms = stats.last_hit_ms;
+            if entry.server_condition {
+                entry.condition_failures = Some(stats.condition_failures);
+            }
+            if total_hits > 0 {
+                entry.share = stats.hits as f64 / total_hits as f64;
+            }
+        }
+        breakpoints.sort_by(|a, b| b.hits.cmp(&a.hits).then(a.id.cmp(&b.id)));
+
+        let mut hot_path = Vec::new();
+        for entry in breakpoints.iter().filter(|entry| entry.hits > 0) {
+            if hot_path.contains(&entry.id) {
+                continue;
+            }
+            hot_path.push(entry.id);
+            if hot_path.len() == HOT_PATH_LEN {
+                break;
+            }
+        }
+
+        BreakpointSummary {
+            at_ms,
+            total_hits,
+            untracked_hits: self.untracked,
+            hot_path,
+            breakpoints,
+        }
+    }
+}
+
+/// One breakpoint in the end-of-session summary
+#[derive(Debug, Clone, Default, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct BreakpointSummaryEntry {
+    pub id: i32,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub source_path: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub line: Option<i32>,
+    /// Function breakpoints
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub function: Option<String>,
+    /// Instruction breakpoints
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub instruction_reference: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub condition: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub log_message: Option<String>,
+    pub hits: u64,
+    pub first_hit_ms: Option<u64>,
+    pub last_hit_ms: Option<u64>,
+    /// None where the adapter evaluates the condition (see the module docs)
+    pub condition_failures: Option<u64>,
+    /// Fraction of all the session's hits
+    pub share: f64,
+    /// The server evaluates the condition, so its failures are counted
+    #[serde(skip)]
+    pub server_condition: bool,
+}
+
+/// Breakpoint statistics at the end of a session
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct BreakpointSummary {
+    /// Milliseconds since the session was created
+    pub at_ms: u64,
+    pub total_hits: u64,
+    /// Hits of breakpoints beyond the tracked ones, and breakpoint stops
+    /// naming none, in `total_hits` only
+    pub untracked_hits: u64,
+    /// Ids of the most hit breakpoints, busiest first
+    pub hot_path: Vec<i32>,
+    /// Busiest first
+    pub breakpoints: Vec<BreakpointSummaryEntry>,
+}
+
+/// Why a session ended without stopping at any of its breakpoints
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ExitDiagnosis {
+    /// From the adapter's `exited` event, which may come after the
+    /// termination
+    pub exit_code: Option<i64>,
+    /// Milliseconds from the (last) launch to the termination
+    pub runtime_ms: u64,
+    /// Verified breakpoints that could have stopped the program (logpoints
+    /// and disabled breakpoints don't count)
+    pub verified_breakpoints: usize,
+    pub message: String,
+}
+
+impl ExitDiagnosis {
+    /// The diagnosis of a termination after `runtime_ms`, if the program
+    /// had `verified_breakpoints` to stop at and none of `summary`'s hits
+    pub fn diagnose(
+        summary: &BreakpointSummary,
+        verified_breakpoints: usize,
+        runtime_ms: u64,
+        exit_code: Option<i64>,
+    ) -> Option<Self> {
+        if summary.total_hits > 0 || verified_breakpoints == 0 {
+            return None;
+        }
+        let mut diagnosis = Self {
+            exit_code,
+            runtime_ms,
+            verified_breakpoints,
+            message: String::new(),
+        };
+        diagnosis.set_exit_code(exit_code);
+        Some(diagnosis)
+    }
+
+    /// Record the exit code, when it arrives after the termination
+    pub fn set_exit_code(&mut self, exit_code: Option<i64>) {
+        self.exit_code = exit_code;
+        let code = exit_code.map_or_else(String::new, |code| format!(" with exit code {}", code));
+        self.message = format!(
+            "program exited{} in {} ms before any breakpoint was hit ({} verified); \
+             consider stopOnEntry or entry in debugger_start to set breakpoints before the \
+             program runs past them",
+            code, self.runtime_ms, self.verified_breakpoints
+        );
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_hits_are_aggregated() {
+        let mut hits = BreakpointHits::default();
+        hits.record(&[1], 10);
+        hits.record(&[1, 2], 25);
+        hits.record(&[1], 40);
+        hits.record_condition_failure(3);
+        hits.record(&[3], 50);
+
+        let stats = hits.get(1).unwrap();
+        assert_eq!(
+            (stats.hits, stats.first_hit_ms, stats.last_hit_ms),
+            (3, Some(10), Some(40))
+        );
+        assert_eq!(hits.count(2), 1);
+        assert_eq!(hits.count(9), 0);
+        assert_eq!(hits.total(), 5);
+    }
+
+    #[test]
+    fn test_untracked_ids_count_in_total_only() {
+        let mut hits = BreakpointHits::default();
+        for id in 0..MAX_TRACKED_BREAKPOINTS as i32 {
+            hits.record(&[id], 1);
+        }
+        hits.record(&[-1], 2);
+        hits.record(&[0], 3);
+        assert_eq!(hits.count(-1), 0);
+        assert_eq!(hits.count(0), 2);
+        assert_eq!(hits.total(), MAX_TRACKED_BREAKPOINTS as u64 + 2);
+    }
+
+    #[test]
+    fn test_summary_is_busiest_first() {
+        let mut hits = BreakpointHits::default();
+        hits.record(&[2], 5);
+        hits.record(&[2], 6);
+        hits.record(&[2], 7);
+        hits.record(&[7], 8);
+        hits.record_condition_failure(2);
+
+        let entries = vec![
+            BreakpointSummaryEntry {
+                id: 1,
+                source_path: Some("/w/app.py".to_string()),
+                line: Some(3),
+                ..Default::default()
+            },
+            BreakpointSummaryEntry {
+                id: 2,
+                source_path: Some("/w/app.py".to_string()),
+                line: Some(9),
+                condition: Some("x > 3".to_string()),
+                server_condition: true,
+                ..Default::default()
+            },
+        ];
+        let summary = hits.summarize(entries, 100);
+        assert_eq!(summary.total_hits, 4);
+        assert_eq!(summary.hot_path, vec![2, 7]);
+
+        let ids: Vec<i32> = summary.breakpoints.iter().map(|e| e.id).collect();
+        assert_eq!(ids, vec![2, 7, 1]);
+        let busiest = &summary.breakpoints[0];
+        assert_eq!(busiest.hits, 3);
+        assert_eq!(
+            (busiest.first_hit_ms, busiest.last_hit_ms),
+            (Some(5), Some(7))
+        );
+        assert_eq!(busiest.condition_failures, Some(1));
+        assert_eq!(busiest.share, 0.75);
+        // Removed breakpoint: no location; adapter-side condition: unknown
+        assert_eq!(summary.breakpoints[1].source_path, None);
+        assert_eq!(summary.breakpoints[2].condition_failures, None);
+        assert_eq!(summary.breakpoints[2].hits, 0);
+    }
+
+    #[test]
+    fn test_exit_without_hits_is_diagnosed() {
+        let mut hits = BreakpointHits::default();
+        let summary = hits.summarize(Vec::new(), 200);
+        assert_eq!(ExitDiagnosis::diagnose(&summary, 0, 180, Some(0)), None);
+
+        let mut diagnosis = ExitDiagnosis::diagnose(&summary, 2, 180, None).unwrap();
+        assert!(diagnosis
+            .message
+            .starts_with("program exited in 180 ms before any breakpoint was hit (2 verified)"));
+        diagnosis.set_exit_code(Some(3));
+        assert!(diagnosis
+            .message
+            .starts_with("program exited with exit code 3 in 180 ms"));
+        assert!(diagnosis.message.contains("stopOnEntry"));
+
+        hits.record(&[1], 50);
+        let summary = hits.summarize(Vec::new(), 200);
+        assert_eq!(ExitDiagnosis::diagnose(&summary, 2, 180, Some(0)), None);
+    }
+}
@@ -0,0 +1,398 @@
+//! Inline values for a stack frame
+//!
+//! Pairs a frame's locals and arguments with the lines of the current
+//! function where their identifier appears, like an IDE showing `i = 42` next
+//! to line 27. This is a lexical scan, not a parse:
+//!
+//! - The function starts at the nearest line above the frame's line that
+//!   declares a function (`def`, `func`, `fn`, `function`/`=>`); for Python
+//!   and Ruby the declaration must also be indented less than every line
+//!   between it and the frame's line, which skips sibling nested functions.
+//! - Only lines from the function start up to the frame's line are scanned
+//!   (at most `MAX_SCAN_LINES`), since values further down aren't meaningful
+//!   yet.
+//! - An identifier matches a variable by name unless it follows a `.` (an
+//!   attribute or method, not the variable).
+
+use crate::dap::types::Variable;
+use serde::Serialize;
+use std::collections::HashMap;
+
+/// Maximum number of lines scanned for one frame
+pub const MAX_SCAN_LINES: usize = 200;
+
+/// Values longer than this many characters are cut and flagged
+pub const MAX_VALUE_LEN: usize = 120;
+
+/// Maximum number of variables considered for one frame
+pub const MAX_VARIABLES: usize = 200;
+
+/// A variable's value shown next to a line that uses it
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct InlineValue {
+    pub line: i32,
+    pub name: String,
+    pub value: String,
+    #[serde(rename = "type", skip_serializing_if = "Option::is_none")]
+    pub type_: Option<String>,
+    /// The name refers to more than one variable in this frame (e.g. a local
+    /// hiding a global, or a Go variable redeclared in an inner block); the
+    /// value is the innermost one
+    pub shadowed: bool,
+    /// The value was shortened, by the adapter or to `MAX_VALUE_LEN`
+    pub truncated: bool,
+}
+
+/// Inline values of one frame and the line range they were collected from
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct InlineValues {
+    pub start_line: i32,
+    pub end_line: i32,
+    /// The function is longer than `MAX_SCAN_LINES`; only its last lines
+    /// (up to the frame's line) were scanned
+    pub range_truncated: bool,
+    pub values: Vec<InlineValue>,
+}
+
+/// A variable after shadowing is resolved
+struct Resolved<'a> {
+    variable: &'a Variable,
+    shadowed: bool,
+    /// Reported by the adapter as an outer, hidden variable (Delve's `(x)`)
+    outer: bool,
+}
+
+/// Compute inline values for a frame
+///
+/// `scopes` holds the variables of each scope, innermost scope first.
+/// `frame_line` is 1-based.
+pub fn compute(
+    language: &str,
+    content: &str,
+    frame_line: i32,
+    scopes: &[Vec<Variable>],
+) -> InlineValues {
+    let lines: Vec<&str> = content.lines().collect();
+    let end = (frame_line.max(1) as usize).min(lines.len());
+    if end == 0 {
+        return InlineValues {
+            start_line: frame_line,
+            end_line: frame_line,
+            range_truncated: false,
+            values: Vec::new(),
+        };
+    }
+
+    let (start, range_truncated) = function_start(language, &lines, end);
+    let variables = resolve_variables(scopes);
+
+    let mut values = Vec::new();
+    for (index, text) in lines.iter().enumerate().take(end).skip(start - 1) {
+        let mut seen: Vec<&str> = Vec::new();
+        for identifier in identifiers(text) {
+            if seen.contains(&identifier) {
+                continue;
+            }
+            seen.push(identifier);
+
+            if let Some(resolved) = variables.get(identifier) {
+                let (value, cut) = truncate_value(&resolved.variable.value);
+                values.push(InlineValue {
+                    line: (index + 1) as i32,
+                    name: identifier.to_string(),
+                    value,
+                    type_: resolved.variable.type_.clone(),
+                    shadowed: resolved.shadowed,
+                    truncated: cut || adapter_truncated(&resolved.variable.value),
+                });
+            }
+        }
+    }
+
+    InlineValues {
+        start_line: start as i32,
+        end_line: end as i32,
+        range_truncated,
+        values,
+    }
+}
+
+/// 1-based first line of the function containing `frame_line`, and whether
+/// the search gave up at `MAX_SCAN_LINES`
+fn function_start(language: &str, lines: &[&str], frame_line: usize) -> (usize, bool) {
+    let indented = matches!(language, "python" | "ruby");
+    // Shallowest indentation between the candidate line and the frame's line;
+    // an enclosing declaration must be shallower still
+    let mut min_indent = indentation(lines[frame_line - 1]);
+
+    let lowest = frame_line.saturating_sub(MAX_SCAN_LINES - 1).max(1);
+    for line in (lowest..=frame_line).rev() {
+        let text = lines[line - 1];
+        if text.trim().is_empty() {
+            continue;
+        }
+        // The frame's own line may be the declaration (e.g. stopped on entry)
+        if declares_function(language, text)
+            && (!indented || line == frame_line || indentation(text) < min_indent)
+        {
+            return (line, false);
+        }
+        min_indent = min_indent.min(indentation(text));
+    }
+
+    (lowest, lowest > 1)
+}
+
+fn declares_function(language: &str, text: &str) -> bool {
+    let mut words = text
+        .split(|c: char| !(c.is_alphanumeric() || c == '_'))
+        .filter(|w| !w.is_empty());
+    match language {
+        "python" | "ruby" => {
+            let trimmed = text.trim_start();
+            trimmed.starts_with("def ") || trimmed.starts_with("async def ")
+        }
+        "go" => words.any(|w| w == "func"),
+        "rust" => words.any(|w| w == "fn"),
+        "javascript" | "nodejs" => words.any(|w| w == "function") || text.contains("=>"),
+        _ => false,
+    }
+}
+
+fn indentation(text: &str) -> usize {
+    text.len() - text.trim_start().len()
+}
+
+/// Identifiers in a line, excluding attribute and method names after a `.`
+fn identifiers(text: &str) -> Vec<&str> {
+    let is_ident = |c: char| c.is_alphanumeric() || c == '_';
+
+    let mut found = Vec::new();
+    let mut start = None;
+    let mut after_dot = false;
+    for (i, c) in text
+        .char_indices()
+        .chain(std::iter::once((text.len(), ' ')))
+    {
+        match (start, is_ident(c)) {
+            (None, true) => start = Some(i),
+            (Some(s), false) => {
+                let word = &text[s..i];
+                if !after_dot && !word.starts_with(|c: char| c.is_ascii_digit()) {
+                    found.push(word);
+                }
+                start = None;
+                after_dot = false;
+            }
+            _ => {}
+        }
+        if start.is_none() && !c.is_whitespace() {
+            after_dot = c == '.';
+        }
+    }
+    found
+}
+
+/// Map names to variables, innermost scope first, recording shadowing
+fn resolve_variables(scopes: &[Vec<Variable>]) -> HashMap<&str, Resolved<'_>> {
+    let mut resolved: HashMap<&str, Resolved> = HashMap::new();
+
+    for variable in scopes.iter().flatten().take(MAX_VARIABLES) {
+        let (name, outer) = match variable
+            .name
+            .strip_prefix('(')
+            .and_then(|n| n.strip_suffix(')'))
+        {
+            Some(name) => (name, true),
+            None => (variable.name.as_str(), false),
+        };
+
+        match resolved.get_mut(name) {
+            None => {
+                resolved.insert(
+                    name,
+                    Resolved {
+                        variable,
+                        shadowed: outer,
+                        outer,
+                    },
+                );
+            }
+            // The visible variable was listed after the one it hides
+            Some(existing) if existing.outer && !outer => {
+                *existing = Resolved {
+                    variable,
+                    shadowed: true,
+                    outer: false,
+                };
+            }
+            Some(existing) => existing.shadowed = true,
+        }
+    }
+
+    resolved
+}
+
+fn truncate_value(value: &str) -> (String, bool) {
+    match value.char_indices().nth(MAX_VALUE_LEN) {
+        Some((cut, _)) => (format!("{}...", &value[..cut]), true),
+        None => (value.to_string(), false),
+    }
+}
+
+/// Adapters shorten long values themselves (`...` or Delve's `...+N more`)
+fn adapter_truncated(value: &str) -> bool {
+    value.ends_with("...") || value.contains("...+")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn var(name: &str, value: &str) -> Variable {
+        Variable {
+            name: name.to_string(),
+            value: value.to_string(),
+            type_: Some("int".to_string()),
+            variables_reference: 0,
+            indexed_variables: None,
+            encoding_lossy: false,
+        }
+    }
+
+    const PYTHON: &str = "\
+import os
+
+def helper():
+    return 1
+
+def total(items, factor):
+    result = 0
+    def scale(x):
+        return x * factor
+    for i in items:
+        result += scale(i) + os.sep.count(i)
+    return result
+";
+
+    #[test]
+    fn test_python_function_range_and_lines() {
+        let scopes = vec![vec![
+            var("items", "[1, 2]"),
+            var("factor", "3"),
+            var("result", "0"),
+            var("i", "1"),
+            var("count", "99"),
+        ]];
+
+        let inline = compute("python", PYTHON, 11, &scopes);
+        // The nested `def scale` is a sibling of the frame's `for` loop
+        assert_eq!((inline.start_line, inline.end_line), (6, 11));
+        assert!(!inline.range_truncated);
+
+        let found: Vec<(i32, &str)> = inline
+            .values
+            .iter()
+            .map(|v| (v.line, v.name.as_str()))
+            .collect();
+        assert_eq!(
+            found,
+            vec![
+                (6, "items"),
+                (6, "factor"),
+                (7, "result"),
+                (9, "factor"),
+                (10, "i"),
+                (10, "items"),
+                (11, "result"),
+                (11, "i"),
+            ]
+        );
+    }
+
+    #[test]
+    fn test_go_shadowed_variables() {
+        let source = "\
+package main
+
+func run() error {
+\terr := setup()
+\tif ok {
+\t\terr := work()
+\t\treturn err
+\t}
+}
+";
+        // Delve lists the hidden outer variable in parentheses
+        let scopes = vec![vec![var("(err)", "nil"), var("err", "io.EOF")]];
+
+        let inline = compute("go", source, 7, &scopes);
+        assert_eq!(inline.start_line, 3);
+        assert!(inline
+            .values
+            .iter()
+            .all(|v| v.name == "err" && v.value == "io.EOF" && v.shadowed));
+        assert_eq!(inline.values.len(), 3);
+    }
+
+    #[test]
+    fn test_inner_scope_shadows_outer() {
+        let scopes = vec![vec![var("x", "1")], vec![var("x", "2"), var("y", "3")]];
+        let inline = compute("rust", "fn main() {\n    let z = x + y;\n}\n", 2, &scopes);
+
+        let x = inline.values.iter().find(|v| v.name == "x").unwrap();
+        assert_eq!(x.value, "1");
+        assert!(x.shadowed);
+        let y = inline.values.iter().find(|v| v.name == "y").unwrap();
+        assert!(!y.shadowed);
+    }
+
+    #[test]
+    fn test_truncated_values() {
+        let long = "a".repeat(MAX_VALUE_LEN + 10);
+        let scopes = vec![vec![
+            var("big", &long),
+            var("s", "[]int len: 500, cap: 500, [1,2,...+436 more]"),
+            var("n", "5"),
+        ]];
+        let inline = compute("go", "func f() {\n\tuse(big, s, n)\n}\n", 2, &scopes);
+
+        let flags: Vec<(&str, bool)> = inline
+            .values
+            .iter()
+            .map(|v| (v.name.as_str(), v.truncated))
+            .collect();
+        assert_eq!(flags, vec![("big", true), ("s", true), ("n", false)]);
+        assert_eq!(
+            inline.values[0].value.chars().count(),
+            MAX_VALUE_LEN + "...".len()
+        );
+    }
+
+    #[test]
+    fn test_scan_is_bounded() {
+        let mut source = String::from("function big() {\n");
+        for i in 0..(MAX_SCAN_LINES * 2) {
+            source.push_str(&format!("  total += {};\n", i));
+        }
+        let frame_line = (MAX_SCAN_LINES * 2 + 1) as i32;
+
+        let inline = compute(
+            "javascript",
+            &source,
+            frame_line,
+            &[vec![var("total", "7")]],
+        );
+        assert!(inline.range_truncated);
+        assert_eq!(inline.values.len(), MAX_SCAN_LINES);
+        assert_eq!(inline.end_line, frame_line);
+    }
+
+    #[test]
+    fn test_frame_line_out_of_range() {
+        let inline = compute("python", "", 10, &[vec![var("x", "1")]]);
+        assert!(inline.values.is_empty());
+    }
+}
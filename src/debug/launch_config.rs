@@ -0,0 +1,201 @@
+//! Named launch configurations
+//!
+//! `debugger_save_config` keeps a debugging setup under a name: the
+//! `debugger_start` arguments (program, args, env, cwd, options) together
+//! with a breakpoint document, which carries the watches too.
+//! `debugger_load_config` starts a new session from it and re-applies the
+//! breakpoints in one call. Configs are kept in memory for the life of the
+//! server.
+//!
+//! A config saved with a workspace is only found under that workspace, so
+//! projects can reuse names; configs without one are server-wide.
+
+use super::breakpoint_io::BreakpointDocument;
+use crate::{Error, Result};
+use serde::Serialize;
+use serde_json::{Map, Value};
+use std::collections::BTreeMap;
+
+/// Most configs a server keeps
+pub const MAX_CONFIGS: usize = 100;
+
+/// Longest config name
+const MAX_NAME_LEN: usize = 64;
+
+/// Start arguments that can't be replayed: a process id names a process
+/// that won't exist the next time
+const UNREPLAYABLE_ARGUMENTS: &[&str] = &["processId"];
+
+/// A saved setup
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct LaunchConfig {
+    pub name: String,
+    /// Workspace the config is scoped to (canonical path)
+    pub workspace: Option<String>,
+    /// `debugger_start` arguments
+    pub start: Map<String, Value>,
+    pub breakpoints: BreakpointDocument,
+    pub saved_at_ms: u64,
+}
+
+impl LaunchConfig {
+    /// Check a config before it is saved
+    ///
+    /// The start arguments need a language matching the breakpoints' and no
+    /// arguments that can't be replayed; the rest is checked by
+    /// `debugger_start` when the config is loaded.
+    pub fn validate(&self) -> Result<()> {
+        validate_name(&self.name)?;
+        let language = self.start.get("language").and_then(Value::as_str);
+        match language {
+            None => {
+                return Err(Error::InvalidRequest(
+                    "A launch config needs the start argument 'language'".to_string(),
+                ))
+            }
+            Some(language) if language != self.breakpoints.language => {
+                return Err(Error::InvalidRequest(format!(
+                    "The breakpoints are for language '{}' but the config starts '{}'",
+                    self.breakpoints.language, language
+                )))
+            }
+            Some(_) => {}
+        }
+        if let Some(argument) = UNREPLAYABLE_ARGUMENTS
+            .iter()
+            .find(|argument| self.start.contains_key(**argument))
+        {
+            return Err(Error::InvalidRequest(format!(
+                "'{}' can't be saved in a launch config, since it won't be valid when the config is loaded; use processName to attach by name",
+                argument
+            )));
+        }
+        Ok(())
+    }
+}
+
+/// Names are 1-64 letters, digits, '-', '_' and '.'
+pub fn validate_name(name: &str) -> Result<()> {
+    let valid = !name.is_empty()
+        && name.len() <= MAX_NAME_LEN
+        && name
+            .chars()
+            .all(|c| c.is_ascii_alphanumeric() || matches!(c, '-' | '_' | '.'));
+    if !valid {
+        return Err(Error::InvalidRequest(format!(
+            "Invalid config name '{}': use 1-{} letters, digits, '-', '_' or '.'",
+            name, MAX_NAME_LEN
+        )));
+    }
+    Ok(())
+}
+
+/// The configs of a server, by workspace and name
+#[derive(Debug, Default)]
+pub struct LaunchConfigs {
+    configs: BTreeMap<(Option<String>, String), LaunchConfig>,
+}
+
+impl LaunchConfigs {
+    /// Save a config, replacing one of the same name and workspace; returns
+    /// whether one was replaced
+    pub fn save(&mut self, config: LaunchConfig) -> Result<bool> {
+        config.validate()?;
+        let key = (config.workspace.clone(), config.name.clone());
+        if !self.configs.contains_key(&key) && self.configs.len() >= MAX_CONFIGS {
+            return Err(Error::InvalidRequest(format!(
+                "At most {} launch configs can be saved; overwrite one instead",
+                MAX_CONFIGS
+            )));
+        }
+        Ok(self.configs.insert(key, config).is_some())
+    }
+
+    pub fn get(&self, workspace: Option<&str>, name: &str) -> Result<&LaunchConfig> {
+        self.configs
+            .get(&(workspace.map(str::to_string), name.to_string()))
+            .ok_or_else(|| {
+                let names = self.names(workspace);
+                Error::InvalidRequest(format!(
+                    "No launch config named '{}'{} (saved: {})",
+                    name,
+                    workspace
+                        .map(|w| format!(" in workspace {}", w))
+                        .unwrap_or_default(),
+                    if names.is_empty() {
+                        "none".to_string()
+                    } else {
+                        names.join(", ")
+                    }
+                ))
+            })
+    }
+
+    /// Names of the configs of `workspace` (or the server-wide ones)
+    pub fn names(&self, workspace: Option<&str>) -> Vec<&str> {
+        self.configs
+            .keys()
+            .filter(|(w, _)| w.as_deref() == workspace)
+            .map(|(_, name)| name.as_str())
+            .collect()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::debug::breakpoint_io::WatchEntry;
+    use crate::debug::breakpoint_io::BREAKPOINT_DOCUMENT_VERSION;
+    use serde_json::json;
+
+    fn config(name: &str, workspace: Option<&str>, start: Value) -> LaunchConfig {
+        LaunchConfig {
+            name: name.to_string(),
+            workspace: workspace.map(str::to_string),
+            start: start.as_object().unwrap().clone(),
+            breakpoints: BreakpointDocument {
+                version: BREAKPOINT_DOCUMENT_VERSION,
+                language: "python".to_string(),
+                breakpoints: Vec::new(),
+                watches: vec![WatchEntry::Expression("total".to_string())],
+                exception_filters: Vec::new(),
+            },
+            saved_at_ms: 0,
+        }
+    }
+
+    #[test]
+    fn test_configs_are_scoped_to_workspaces() {
+        let start = json!({"language": "python", "program": "/w/app.py"});
+        let mut configs = LaunchConfigs::default();
+        assert!(!configs.save(config("repro", None, start.clone())).unwrap());
+        assert!(!configs
+            .save(config("repro", Some("/w"), start.clone()))
+            .unwrap());
+        assert!(configs.save(config("repro", None, start)).unwrap());
+
+        assert_eq!(configs.names(None), ["repro"]);
+        assert!(configs.get(Some("/w"), "repro").is_ok());
+        let err = configs.get(Some("/other"), "repro").unwrap_err();
+        assert!(err.to_string().contains("saved: none"), "{}", err);
+    }
+
+    #[test]
+    fn test_configs_are_validated() {
+        let mut configs = LaunchConfigs::default();
+        for (name, start, expected) in [
+            ("a b", json!({"language": "python"}), "Invalid config name"),
+            ("x", json!({"program": "/w/app.py"}), "'language'"),
+            ("x", json!({"language": "go"}), "breakpoints are for"),
+            (
+                "x",
+                json!({"language": "python", "processId": 42}),
+                "'processId'",
+            ),
+        ] {
+            let err = configs.save(config(name, None, start)).unwrap_err();
+            assert!(err.to_string().contains(expected), "{}", err);
+        }
+    }
+}
@@ -0,0 +1,818 @@
+//! Starting sessions from VS Code launch.json configurations
+//!
+//! Teams keep their debug setups in `.vscode/launch.json`;
+//! `debugger_start_from_launch_json` translates one of its configurations
+//! into `debugger_start` arguments instead of making them write it again.
+//! The file is JSON with comments and trailing commas, as VS Code reads it.
+//!
+//! Configurations of type `go` (Delve), `debugpy` or `python` (debugpy) and
+//! `rdbg` (Ruby) are translated attribute by attribute. Attributes that only
+//! matter to VS Code's UI (`presentation`, `console`, ...) are left out and
+//! listed as ignored. Any other attribute the server can't honor is
+//! rejected, naming it, rather than dropped: a `preLaunchTask` or an
+//! `envFile` left out silently would debug something else than VS Code
+//! does. Overrides replace attributes before the translation, and remove
+//! them with null.
+//!
+//! Of VS Code's variables, `${workspaceFolder}` (by default the directory
+//! holding `.vscode`), `${workspaceFolderBasename}`, `${file}`,
+//! `${fileDirname}` and `${fileBasename}` are substituted; the file ones
+//! need the file, since no editor has one open. Others (`${env:...}`,
+//! `${command:...}`, `${input:...}`) are rejected.
+
+use crate::{Error, Result};
+use serde::Serialize;
+use serde_json::{json, Map, Value};
+use std::path::Path;
+
+/// Launch.json types by the language they start
+pub const LAUNCH_TYPES: &[(&str, &str)] = &[
+    ("go", "go"),
+    ("debugpy", "python"),
+    ("python", "python"),
+    ("rdbg", "ruby"),
+];
+
+/// Attributes only VS Code's UI uses, whatever the type
+const UI_ATTRIBUTES: &[&str] = &[
+    "console",
+    "internalConsoleOptions",
+    "presentation",
+    "showLog",
+    "logOutput",
+    "trace",
+    "useTerminal",
+    "redirectOutput",
+    "showGlobalVariables",
+    "debugAdapter",
+];
+
+/// Values of the variables a configuration may use
+#[derive(Debug, Clone, Default, PartialEq)]
+pub struct Variables {
+    pub workspace_folder: String,
+    /// The file open in the editor: only known when given
+    pub file: Option<String>,
+}
+
+impl Variables {
+    /// Variables of a launch.json at `path`, whose workspace folder is the
+    /// directory holding its `.vscode` directory (or its own directory)
+    pub fn for_file(path: &Path) -> Self {
+        let dir = path.parent().unwrap_or(Path::new("/"));
+        let workspace = match dir.file_name() {
+            Some(name) if name == ".vscode" => dir.parent().unwrap_or(dir),
+            _ => dir,
+        };
+        Self {
+            workspace_folder: workspace.display().to_string(),
+            file: None,
+        }
+    }
+
+    fn value(&self, name: &str, attribute: &str) -> Result<String> {
+        let file = || {
+            self.file.as_deref().map(Path::new).ok_or_else(|| {
+                Error::InvalidRequest(format!(
+                    "'${{{}}}' in attribute '{}' needs the file an editor would have open; give it as overrides.file",
+                    name, attribute
+                ))
+            })
+        };
+        let base_name = |path: &Path| {
+            path.file_name()
+                .map(|name| name.to_string_lossy().into_owned())
+                .unwrap_or_default()
+        };
+        Ok(match name {
+            "workspaceFolder" | "workspaceRoot" => self.workspace_folder.clone(),
+            "workspaceFolderBasename" => base_name(Path::new(&self.workspace_folder)),
+            "file" => file()?.display().to_string(),
+            "fileBasename" => base_name(file()?),
+            "fileDirname" => file()?
+                .parent()
+                .map(|dir| dir.display().to_string())
+                .unwrap_or_default(),
+            _ => {
+                return Err(Error::InvalidRequest(format!(
+                    "Unsupported variable '${{{}}}' in attribute '{}': only workspaceFolder, workspaceFolderBasename, file, fileBasename and fileDirname are substituted; set the attribute through overrides instead",
+                    name, attribute
+                )))
+            }
+        })
+    }
+
+    /// `text` with its variables substituted
+    fn substitute(&self, text: &str, attribute: &str) -> Result<String> {
+        let mut result = String::new();
+        let mut rest = text;
+        while let Some(start) = rest.find("${") {
+            let end = rest[start..].find('}').ok_or_else(|| {
+                Error::InvalidRequest(format!(
+                    "Unterminated variable in attribute '{}': {}",
+                    attribute, text
+                ))
+            })?;
+            result.push_str(&rest[..start]);
+            result.push_str(&self.value(&rest[start + 2..start + end], attribute)?);
+            rest = &rest[start + end + 1..];
+        }
+        result.push_str(rest);
+        Ok(result)
+    }
+
+    fn substitute_value(&self, value: &mut Value, attribute: &str) -> Result<()> {
+        match value {
+            Value::String(text) => *text = self.substitute(text, attribute)?,
+            Value::Array(items) => {
+                for item in items {
+                    self.substitute_value(item, attribute)?;
+                }
+            }
+            Value::Object(map) => {
+                for item in map.values_mut() {
+                    self.substitute_value(item, attribute)?;
+                }
+            }
+            _ => {}
+        }
+        Ok(())
+    }
+}
+
+/// A configuration translated into `debugger_start` arguments
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ImportedConfiguration {
+    pub name: String,
+    #[serde(rename = "type")]
+    pub launch_type: String,
+    pub request: String,
+    /// `debugger_start` arguments
+    pub start: Map<String, Value>,
+    /// UI attributes left out
+    pub ignored: Vec<String>,
+}
+
+/// Parse a launch.json, which may have comments and trailing commas
+pub fn parse(text: &str) -> Result<Value> {
+    serde_json::from_str(&strip_jsonc(text)).map_err(|e| {
+        Error::InvalidRequest(format!(
+            "launch.json is not valid JSON (with comments): {}",
+            e
+        ))
+    })
+}
+
+/// `text` without comments and trailing commas, strings left alone
+fn strip_jsonc(text: &str) -> String {
+    let mut out = String::with_capacity(text.len());
+    let mut chars = text.chars().peekable();
+    let mut in_string = false;
+    while let Some(c) = chars.next() {
+        if in_string {
+            out.push(c);
+            match c {
+                '\\' => out.extend(chars.next()),
+                '"' => in_string = false,
+                _ => {}
+            }
+            continue;
+        }
+        match (c, chars.peek()) {
+            ('"', _) => {
+                in_string = true;
+                out.push(c);
+            }
+            ('/', Some('/')) => {
+                for c in chars.by_ref() {
+                    if c == '\n' {
+                        out.push('\n');
+                        break;
+                    }
+                }
+            }
+            ('/', Some('*')) => {
+                chars.next();
+                let mut last = ' ';
+                for c in chars.by_ref() {
+                    if last == '*' && c == '/' {
+                        break;
+                    }
+                    last = c;
+                }
+            }
+            (']' | '}', _) => {
+                let trimmed = out.trim_end().len();
+                if out[..trimmed].ends_with(',') {
+                    out.truncate(trimmed - 1);
+                }
+                out.push(c);
+            }
+            _ => out.push(c),
+        }
+    }
+    out
+}
+
+/// The configuration named `name` of a parsed launch.json
+pub fn configuration(document: &Value, name: &str) -> Result<Map<String, Value>> {
+    let configurations = document
+        .get("configurations")
+        .and_then(Value::as_array)
+        .ok_or_else(|| {
+            Error::InvalidRequest("launch.json has no 'configurations' array".to_string())
+        })?;
+    let names: Vec<&str> = configurations
+        .iter()
+        .filter_map(|c| c.get("name").and_then(Value::as_str))
+        .collect();
+    configurations
+        .iter()
+        .filter_map(Value::as_object)
+        .find(|c| c.get("name").and_then(Value::as_str) == Some(name))
+        .cloned()
+        .ok_or_else(|| {
+            Error::InvalidRequest(format!(
+                "launch.json has no configuration named '{}' (configurations: {})",
+                name,
+                if names.is_empty() {
+                    "none".to_string()
+                } else {
+                    names.join(", ")
+                }
+            ))
+        })
+}
+
+/// Apply `overrides` to a configuration, returning the variables they set
+///
+/// `workspaceFolder` and `file` are the values of those variables; any other
+/// key replaces the attribute, or removes it when null.
+pub fn apply_overrides(
+    configuration: &mut Map<String, Value>,
+    overrides: &Map<String, Value>,
+    mut variables: Variables,
+) -> Result<Variables> {
+    for (key, value) in overrides {
+        let text = || {
+            value.as_str().map(str::to_string).ok_or_else(|| {
+                Error::InvalidRequest(format!("overrides.{} must be a string path", key))
+            })
+        };
+        match key.as_str() {
+            "workspaceFolder" => variables.workspace_folder = text()?,
+            "file" => variables.file = Some(text()?),
+            _ if value.is_null() => {
+                configuration.remove(key);
+            }
+            _ => {
+                configuration.insert(key.clone(), value.clone());
+            }
+        }
+    }
+    Ok(variables)
+}
+
+/// Translate a configuration into `debugger_start` arguments
+pub fn translate(
+    configuration: &Map<String, Value>,
+    variables: &Variables,
+) -> Result<ImportedConfiguration> {
+    let name = configuration
+        .get("name")
+        .and_then(Value::as_str)
+        .unwrap_or_default()
+        .to_string();
+    let launch_type = configuration
+        .get("type")
+        .and_then(Value::as_str)
+        .ok_or_else(|| Error::InvalidRequest(format!("Configuration '{}' has no 'type'", name)))?;
+    let language = LAUNCH_TYPES
+        .iter()
+        .find(|(t, _)| *t == launch_type)
+        .map(|(_, language)| *language)
+        .ok_or_else(|| {
+            Error::InvalidRequest(format!(
+                "Configuration '{}' is of type '{}'; supported types: {}",
+                name,
+                launch_type,
+                LAUNCH_TYPES
+                    .iter()
+                    .map(|(t, _)| *t)
+                    .collect::<Vec<_>>()
+                    .join(", ")
+            ))
+        })?;
+    let request = match configuration.get("request").and_then(Value::as_str) {
+        Some(request @ ("launch" | "attach")) => request,
+        other => {
+            return Err(Error::InvalidRequest(format!(
+                "Configuration '{}' needs request 'launch' or 'attach', got {}",
+                name,
+                other.map_or("none".to_string(), |r| format!("'{}'", r))
+            )))
+        }
+    };
+    if language == "ruby" && request == "attach" {
+        return Err(Error::InvalidRequest(format!(
+            "Configuration '{}': rdbg attach configurations connect to a debug port, which the server can't; use a launch configuration",
+            name
+        )));
+    }
+
+    let mut translation = Translation {
+        name: &name,
+        launch_type,
+        attach: request == "attach",
+        start: Map::new(),
+        ignored: Vec::new(),
+    };
+    translation
+        .start
+        .insert("language".to_string(), json!(language));
+    // Sorted, so the first unsupported attribute is always the same one,
+    // with the mode first since it decides what the others mean
+    let mut keys: Vec<&String> = configuration.keys().collect();
+    keys.sort_by_key(|key| (key.as_str() != "mode", key.as_str()));
+    for key in keys {
+        if matches!(key.as_str(), "name" | "type" | "request") {
+            continue;
+        }
+        let mut value = configuration[key].clone();
+        variables.substitute_value(&mut value, key)?;
+        if UI_ATTRIBUTES.contains(&key.as_str()) {
+            translation.ignored.push(key.clone());
+            continue;
+        }
+        match language {
+            "go" => translation.go(key, value)?,
+            "python" => translation.python(key, value)?,
+            _ => translation.ruby(key, value)?,
+        }
+    }
+    translation.finish(language)?;
+
+    Ok(ImportedConfiguration {
+        name: name.clone(),
+        launch_type: launch_type.to_string(),
+        request: request.to_string(),
+        start: translation.start,
+        ignored: translation.ignored,
+    })
+}
+
+/// A configuration being translated
+struct Translation<'a> {
+    name: &'a str,
+    launch_type: &'a str,
+    attach: bool,
+    start: Map<String, Value>,
+    ignored: Vec<String>,
+}
+
+impl Translation<'_> {
+    fn set(&mut self, key: &str, value: Value) {
+        self.start.insert(key.to_string(), value);
+    }
+
+    /// Set `key` of the options object `group` (`goOptions`, ...)
+    fn set_option(&mut self, group: &str, key: &str, value: Value) {
+        let options = self.start.entry(group).or_insert_with(|| json!({}));
+        options[key] = value;
+    }
+
+    fn unsupported(&self, attribute: &str, why: &str) -> Error {
+        Error::InvalidRequest(format!(
+            "Configuration '{}': attribute '{}' isn't supported for {} configurations{}{}; remove it with overrides {{\"{}\": null}} to start without it",
+            self.name,
+            attribute,
+            self.launch_type,
+            if why.is_empty() { "" } else { ": " },
+            why,
+            attribute
+        ))
+    }
+
+    fn invalid(&self, attribute: &str, expected: &str) -> Error {
+        Error::InvalidRequest(format!(
+            "Configuration '{}': attribute '{}' must be {}",
+            self.name, attribute, expected
+        ))
+    }
+
+    fn string(&self, attribute: &str, value: Value) -> Result<Value> {
+        match value {
+            Value::String(_) => Ok(value),
+            _ => Err(self.invalid(attribute, "a string")),
+        }
+    }
+
+    fn boolean(&self, attribute: &str, value: Value) -> Result<Value> {
+        match value {
+            Value::Bool(_) => Ok(value),
+            _ => Err(self.invalid(attribute, "true or false")),
+        }
+    }
+
+    fn strings(&self, attribute: &str, value: Value) -> Result<Value> {
+        match &value {
+            Value::Array(items) if items.iter().all(Value::is_string) => Ok(value),
+            _ => Err(self.invalid(attribute, "an array of strings")),
+        }
+    }
+
+    fn process_id(&self, value: Value) -> Result<Value> {
+        let pid = match &value {
+            Value::Number(n) => n.as_u64(),
+            Value::String(s) => s.trim().parse().ok(),
+            _ => None,
+        };
+        pid.map(|pid| json!(pid))
+            .ok_or_else(|| self.invalid("processId", "a process id"))
+    }
+
+    /// Attributes every type shares; false when `key` isn't one
+    fn common(&mut self, key: &str, value: Value) -> Result<bool> {
+        match key {
+            "args" => {
+                let args = self.strings(key, value)?;
+                self.set("args", args);
+            }
+            "cwd" => {
+                let cwd = self.string(key, value)?;
+                self.set("cwd", cwd);
+            }
+            "stopOnEntry" => {
+                let stop = self.boolean(key, value)?;
+                self.set("stopOnEntry", stop);
+            }
+            "processId" if self.attach => {
+                let pid = self.process_id(value)?;
+                self.set("processId", pid);
+            }
+            "preLaunchTask" | "postDebugTask" => {
+                return Err(self.unsupported(
+                    key,
+                    "VS Code tasks can't be run by the server; run the task first",
+                ))
+            }
+            "envFile" => return Err(self.unsupported(key, "give the variables in 'env' instead")),
+            _ => return Ok(false),
+        }
+        Ok(true)
+    }
+
+    fn go(&mut self, key: &str, value: Value) -> Result<()> {
+        if self.common(key, value.clone())? {
+            return Ok(());
+        }
+        match key {
+            "program" => {
+                let program = self.string(key, value)?;
+                self.set("program", program);
+            }
+            "mode" => {
+                let mode = match (self.attach, value.as_str()) {
+                    (false, Some(mode @ ("debug" | "test" | "exec"))) => mode,
+                    // Delve's auto: test for test files, debug otherwise
+                    (false, Some("auto")) => "auto",
+                    (true, Some("local")) => "attach",
+                    (_, Some(mode)) => {
+                        return Err(Error::InvalidRequest(format!(
+                            "Configuration '{}': mode '{}' isn't supported; launch configurations can use debug, test, exec or auto, attach configurations local",
+                            self.name, mode
+                        )))
+                    }
+                    (_, None) => return Err(self.invalid(key, "a string")),
+                };
+                self.set("mode", json!(mode));
+            }
+            "env" => return Err(self.env_unsupported()),
+            "substitutePath" => {
+                let rules = value
+                    .as_array()
+                    .filter(|rules| {
+                        rules.iter().all(|rule| {
+                            rule.get("from").is_some_and(Value::is_string)
+                                && rule.get("to").is_some_and(Value::is_string)
+                        })
+                    })
+                    .ok_or_else(|| self.invalid(key, "an array of {\"from\", \"to\"} paths"))?;
+                let rules = json!(rules
+                    .iter()
+                    .map(|rule| json!({"from": rule["from"], "to": rule["to"]}))
+                    .collect::<Vec<_>>());
+                self.set_option("goOptions", "substitutePath", rules);
+            }
+            "output" => {
+                let output = self.string(key, value)?;
+                self.set_option("goOptions", "output", output);
+            }
+            _ => return Err(self.unsupported(key, "")),
+        }
+        Ok(())
+    }
+
+    fn python(&mut self, key: &str, value: Value) -> Result<()> {
+        if self.common(key, value.clone())? {
+            return Ok(());
+        }
+        match key {
+            "program" | "module" => {
+                let target = self.string(key, value)?;
+                self.set(key, target);
+            }
+            "env" => {
+                let all_strings = value
+                    .as_object()
+                    .is_some_and(|env| env.values().all(Value::is_string));
+                if !all_strings {
+                    return Err(self.invalid(key, "an object of string values"));
+                }
+                self.set("env", value);
+            }
+            "justMyCode" => {
+                let just_my_code = self.boolean(key, value)?;
+                self.set("justMyCode", just_my_code);
+            }
+            "python" | "pythonPath" => {
+                let python = self.string(key, value)?;
+                self.set("pythonPath", python);
+            }
+            "connect" | "listen" | "host" | "port" if self.attach => {
+                return Err(self.unsupported(
+                    key,
+                    "the server attaches to local processes by processId only",
+                ))
+            }
+            _ => return Err(self.unsupported(key, "")),
+        }
+        Ok(())
+    }
+
+    fn ruby(&mut self, key: &str, value: Value) -> Result<()> {
+        if self.common(key, value.clone())? {
+            return Ok(());
+        }
+        match key {
+            "script" => {
+                let script = self.string(key, value)?;
+                self.set("program", script);
+            }
+            "useBundler" => {
+                let bundler = self.boolean(key, value)?;
+                self.set_option("rubyOptions", "bundler", bundler);
+            }
+            "command" => match value.as_str() {
+                Some("ruby") => {}
+                Some("bundle exec ruby") => self.set_option("rubyOptions", "bundler", json!(true)),
+                _ => {
+                    return Err(
+                        self.unsupported(key, "only 'ruby' and 'bundle exec ruby' can be run")
+                    )
+                }
+            },
+            "askParameters" => {
+                if value != json!(false) {
+                    return Err(self.unsupported(
+                        key,
+                        "nothing can be asked for; give script and args in the configuration",
+                    ));
+                }
+            }
+            "env" => return Err(self.env_unsupported()),
+            _ => return Err(self.unsupported(key, "")),
+        }
+        Ok(())
+    }
+
+    fn env_unsupported(&self) -> Error {
+        self.unsupported(
+            "env",
+            "the server passes environment variables to Python programs only",
+        )
+    }
+
+    /// Check the translation is complete, resolving Delve's auto mode
+    fn finish(&mut self, language: &str) -> Result<()> {
+        if self.attach {
+            if !self.start.contains_key("processId") {
+                return Err(Error::InvalidRequest(format!(
+                    "Configuration '{}' attaches but has no processId",
+                    self.name
+                )));
+            }
+            self.set("mode", json!("attach"));
+            return Ok(());
+        }
+        if self.start.contains_key("processId") {
+            return Err(self.unsupported("processId", "only attach configurations take one"));
+        }
+        let has_program = ["program", "module"]
+            .iter()
+            .any(|key| self.start.contains_key(*key));
+        if !has_program {
+            let attribute = match language {
+                "ruby" => "script",
+                "python" => "program or module",
+                _ => "program",
+            };
+            return Err(Error::InvalidRequest(format!(
+                "Configuration '{}' has no {}",
+                self.name, attribute
+            )));
+        }
+        if self.start.get("mode").and_then(Value::as_str) == Some("auto") {
+            let program = self.start["program"].as_str().unwrap_or_default();
+            let mode = if program.ends_with("_test.go") {
+                "test"
+            } else {
+                "debug"
+            };
+            self.set("mode", json!(mode));
+        }
+        Ok(())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    /// Import `name` of the fixture launch.json of `language`, as if it were
+    /// /w/.vscode/launch.json
+    fn import(language: &str, name: &str, overrides: Value) -> Result<ImportedConfiguration> {
+        let text = match language {
+            "go" => include_str!("../../tests/fixtures/launch_json/go/launch.json"),
+            "python" => include_str!("../../tests/fixtures/launch_json/python/launch.json"),
+            _ => include_str!("../../tests/fixtures/launch_json/ruby/launch.json"),
+        };
+        let document = parse(text).unwrap();
+        let mut config = configuration(&document, name)?;
+        let variables = apply_overrides(
+            &mut config,
+            overrides.as_object().unwrap(),
+            Variables::for_file(Path::new("/w/.vscode/launch.json")),
+        )?;
+        translate(&config, &variables)
+    }
+
+    fn assert_error(result: Result<ImportedConfiguration>, expected: &str) {
+        let err = result.unwrap_err().to_string();
+        assert!(err.contains(expected), "{}", err);
+    }
+
+    #[test]
+    fn test_comments_and_trailing_commas() {
+        let document = parse("{\"a\": \"// no \\\" comment\", /* b */ \"c\": [1, 2,],}").unwrap();
+        assert_eq!(document, json!({"a": "// no \" comment", "c": [1, 2]}));
+        assert!(parse("{\"a\": }").is_err());
+        assert_eq!(
+            Variables::for_file(Path::new("/w/app/launch.json")).workspace_folder,
+            "/w/app"
+        );
+    }
+
+    #[test]
+    fn test_go_configurations() {
+        let launch = import("go", "Launch fizzbuzz", json!({})).unwrap();
+        assert_eq!(
+            Value::Object(launch.start),
+            json!({
+                "language": "go",
+                "mode": "debug",
+                "program": "/w/fizzbuzz.go",
+                "args": ["--limit", "15"],
+                "cwd": "/w"
+            })
+        );
+        assert_eq!(launch.ignored, vec!["showLog"]);
+
+        let test = import(
+            "go",
+            "Test current package",
+            json!({"file": "/w/pkg/fizz_test.go"}),
+        )
+        .unwrap();
+        assert_eq!(test.start["mode"], "test");
+        assert_eq!(test.start["program"], "/w/pkg");
+
+        let exec = import("go", "Debug CI binary", json!({"workspaceFolder": "/src"})).unwrap();
+        assert_eq!(exec.start["program"], "/src/bin/fizzbuzz");
+        assert_eq!(
+            exec.start["goOptions"],
+            json!({"substitutePath": [{"from": "/src", "to": "/build/src"}]})
+        );
+        assert_eq!(exec.ignored, vec!["presentation"]);
+
+        let attach = import("go", "Attach to server", json!({})).unwrap();
+        assert_eq!(
+            Value::Object(attach.start),
+            json!({"language": "go", "mode": "attach", "processId": 4242})
+        );
+
+        assert_error(import("go", "Remote Delve", json!({})), "mode 'remote'");
+        assert_error(
+            import("go", "Launch fizzbuzz", json!({"env": {"A": "1"}})),
+            "attribute 'env' isn't supported for go configurations",
+        );
+        assert_error(
+            import("go", "Launch fizzbuzz", json!({"buildFlags": "-tags=dev"})),
+            "attribute 'buildFlags' isn't supported",
+        );
+    }
+
+    #[test]
+    fn test_python_configurations() {
+        assert_error(
+            import("python", "Python Debugger: Current File", json!({})),
+            "'${file}' in attribute 'program' needs the file",
+        );
+        let current = import(
+            "python",
+            "Python Debugger: Current File",
+            json!({"file": "/w/tool.py"}),
+        )
+        .unwrap();
+        assert_eq!(
+            Value::Object(current.start),
+            json!({"language": "python", "program": "/w/tool.py"})
+        );
+        assert_eq!(current.ignored, vec!["console"]);
+
+        let fizzbuzz = import("python", "fizzbuzz", json!({})).unwrap();
+        assert_eq!(
+            Value::Object(fizzbuzz.start),
+            json!({
+                "language": "python",
+                "program": "/w/fizzbuzz.py",
+                "args": ["15"],
+                "cwd": "/w",
+                "env": {"FIZZ": "3", "BUZZ": "5"},
+                "justMyCode": false,
+                "pythonPath": "/w/.venv/bin/python",
+                "stopOnEntry": true
+            })
+        );
+
+        assert_error(
+            import("python", "Module", json!({})),
+            "remove it with overrides {\"preLaunchTask\": null}",
+        );
+        let module = import("python", "Module", json!({"preLaunchTask": null})).unwrap();
+        assert_eq!(module.start["module"], "http.server");
+
+        assert_error(
+            import("python", "Attach by process", json!({})),
+            "Unsupported variable '${command:pickProcess}' in attribute 'processId'",
+        );
+        let attach = import("python", "Attach by process", json!({"processId": "77"})).unwrap();
+        assert_eq!(
+            Value::Object(attach.start),
+            json!({"language": "python", "mode": "attach", "processId": 77})
+        );
+        assert_error(
+            import("python", "Attach over the network", json!({})),
+            "attaches to local processes by processId only",
+        );
+    }
+
+    #[test]
+    fn test_ruby_configurations() {
+        assert_error(
+            import(
+                "ruby",
+                "Debug current file with rdbg",
+                json!({"file": "/w/a.rb"}),
+            ),
+            "attribute 'askParameters' isn't supported",
+        );
+        let fizzbuzz = import("ruby", "fizzbuzz", json!({"stopOnEntry": true})).unwrap();
+        assert_eq!(
+            Value::Object(fizzbuzz.start),
+            json!({
+                "language": "ruby",
+                "program": "/w/fizzbuzz.rb",
+                "args": ["15"],
+                "rubyOptions": {"bundler": true},
+                "stopOnEntry": true
+            })
+        );
+        assert_eq!(fizzbuzz.ignored, vec!["useTerminal"]);
+
+        assert_error(
+            import("ruby", "fizzbuzz with env", json!({})),
+            "passes environment variables to Python programs only",
+        );
+        assert_error(import("ruby", "Attach with rdbg", json!({})), "rdbg attach");
+        assert_error(
+            import("ruby", "fizzbuzz", json!({"script": null})),
+            "has no script",
+        );
+        assert_error(
+            import("ruby", "Missing", json!({})),
+            "(configurations: Debug current file with rdbg, fizzbuzz,",
+        );
+    }
+}
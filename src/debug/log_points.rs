@@ -0,0 +1,106 @@
+//! Logpoints for adapters without native support
+//!
+//! A logpoint logs a message instead of stopping. debugpy and js-debug do
+//! that themselves (`supportsLogPoints`); Delve and rdbg don't. For those the
+//! logpoint is sent as a plain breakpoint (keeping its condition), and when
+//! it is hit the server evaluates the message's `{expression}` placeholders
+//! in the top frame, appends the message to the program output and continues.
+//! The stop is never reported, so logpoints behave the same on every adapter.
+
+/// A piece of a logpoint message
+#[derive(Debug, Clone, PartialEq)]
+pub enum Segment {
+    Text(String),
+    /// `{expression}`, replaced by its value
+    Expression(String),
+}
+
+/// Split a message into text and `{expression}` placeholders
+///
+/// `{{` and `}}` stand for literal braces. An unclosed `{` is kept as text.
+pub fn parse(message: &str) -> Vec<Segment> {
+    let mut segments = Vec::new();
+    let mut text = String::new();
+    let mut chars = message.chars().peekable();
+
+    while let Some(c) = chars.next() {
+        match c {
+            '{' if chars.peek() == Some(&'{') => {
+                chars.next();
+                text.push('{');
+            }
+            '}' if chars.peek() == Some(&'}') => {
+                chars.next();
+                text.push('}');
+            }
+            '{' => {
+                let rest: String = chars.clone().collect();
+                match rest.find('}') {
+                    Some(end) => {
+                        if !text.is_empty() {
+                            segments.push(Segment::Text(std::mem::take(&mut text)));
+                        }
+                        segments.push(Segment::Expression(rest[..end].trim().to_string()));
+                        for _ in rest[..=end].chars() {
+                            chars.next();
+                        }
+                    }
+                    None => text.push('{'),
+                }
+            }
+            c => text.push(c),
+        }
+    }
+    if !text.is_empty() {
+        segments.push(Segment::Text(text));
+    }
+    segments
+}
+
+/// Expressions of a parsed message, in order
+pub fn expressions(segments: &[Segment]) -> Vec<&str> {
+    segments
+        .iter()
+        .filter_map(|s| match s {
+            Segment::Expression(e) => Some(e.as_str()),
+            Segment::Text(_) => None,
+        })
+        .collect()
+}
+
+/// The message with each expression replaced by its value, in the order of
+/// `expressions`
+pub fn render(segments: &[Segment], values: &[String]) -> String {
+    let mut values = values.iter();
+    segments
+        .iter()
+        .map(|s| match s {
+            Segment::Text(t) => t.as_str(),
+            Segment::Expression(_) => values.next().map_or("", String::as_str),
+        })
+        .collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parse_and_render() {
+        let segments = parse("i={i}, {{literal}} total={ sum(xs) }");
+        assert_eq!(expressions(&segments), vec!["i", "sum(xs)"]);
+        assert_eq!(
+            render(&segments, &["3".to_string(), "42".to_string()]),
+            "i=3, {literal} total=42"
+        );
+
+        assert_eq!(
+            parse("no placeholders"),
+            vec![Segment::Text("no placeholders".to_string())]
+        );
+        assert_eq!(
+            parse("open { brace"),
+            vec![Segment::Text("open { brace".to_string())]
+        );
+    }
+}
@@ -1,20 +1,85 @@
-use super::session::DebugSession;
+use super::group::{GroupMember, Membership, SessionGroup};
+use super::launch_config::{LaunchConfig, LaunchConfigs};
+use super::post_mortem::{PostMortems, SessionPostMortem};
+use super::session::{DebugSession, WarmAdapterConfig};
+use crate::adapters::exec_prefix;
 use crate::adapters::golang::GoAdapter;
+use crate::adapters::java::JavaAdapter;
 use crate::adapters::logging::DebugAdapterLogger;
 use crate::adapters::nodejs::NodeJsAdapter;
 use crate::adapters::python::PythonAdapter;
 use crate::adapters::ruby::RubyAdapter;
 use crate::adapters::rust::RustAdapter;
+use crate::adapters::source_maps;
+use crate::adapters::{LaunchGate, LaunchOptions};
 use crate::dap::client::DapClient;
+use crate::dap::launch_phase::{self, LaunchPhase, PhaseTiming};
+use crate::log_level;
 use crate::{Error, Result};
 use std::collections::HashMap;
+use std::future::Future;
+use std::sync::atomic::{AtomicUsize, Ordering};
 use std::sync::Arc;
+use std::time::{Duration, Instant};
 use tokio::sync::RwLock;
-use tracing::{error, info};
+use tracing::{error, info, warn, Instrument};
+
+/// Key of a parked adapter: (language, program)
+type WarmAdapterKey = (String, String);
+
+/// A place under `sessions.max_sessions` held for a session being started,
+/// until it is stored or the start fails (see `reserve_session_slot`)
+struct SessionSlot {
+    reserved: Arc<AtomicUsize>,
+    held: bool,
+    /// The relaunched session whose place the new one takes, given back if
+    /// the start fails before the new session is stored
+    replaces: Option<(HandedOver, String)>,
+}
+
+impl SessionSlot {
+    fn release(&mut self) {
+        if std::mem::take(&mut self.held) {
+            self.reserved.fetch_sub(1, Ordering::SeqCst);
+        }
+    }
+}
+
+impl Drop for SessionSlot {
+    fn drop(&mut self) {
+        self.release();
+        if let Some((handed_over, session_id)) = self.replaces.take() {
+            handed_over.lock().unwrap().remove(&session_id);
+        }
+    }
+}
+
+/// Ids of relaunched sessions whose place under `sessions.max_sessions`
+/// went to their successor, until they are removed
+type HandedOver = Arc<std::sync::Mutex<std::collections::HashSet<String>>>;
 
 /// Session Manager - manages multiple debug sessions
 pub struct SessionManager {
     sessions: Arc<RwLock<HashMap<String, Arc<DebugSession>>>>,
+    /// Sessions whose adapter is kept alive after a restart-intent disconnect
+    warm_adapters: Arc<RwLock<HashMap<WarmAdapterKey, Arc<DebugSession>>>>,
+    /// When each session was last looked up by a tool call
+    last_activity: Arc<std::sync::Mutex<HashMap<String, Instant>>>,
+    /// Maximum number of concurrent sessions (`sessions.max_sessions`)
+    max_sessions: Option<usize>,
+    /// Sessions being started, which count against `max_sessions`
+    reserved_slots: Arc<AtomicUsize>,
+    /// Relaunched sessions, which no longer count against `max_sessions`
+    handed_over: HandedOver,
+    /// Session groups by group id
+    groups: Arc<RwLock<HashMap<String, SessionGroup>>>,
+    /// Launch configs saved with debugger_save_config
+    launch_configs: Arc<RwLock<LaunchConfigs>>,
+    /// Ids of sessions that were relaunched, and the session that took over
+    /// (see `file_watch`)
+    relaunched: Arc<std::sync::Mutex<HashMap<String, String>>>,
+    /// Post-mortems of removed sessions (see `post_mortem`)
+    post_mortems: Arc<std::sync::Mutex<PostMortems>>,
 }
 
 impl Default for SessionManager {
@@ -27,9 +92,75 @@ impl SessionManager {
     pub fn new() -> Self {
         Self {
             sessions: Arc::new(RwLock::new(HashMap::new())),
+            warm_adapters: Arc::new(RwLock::new(HashMap::new())),
+            last_activity: Arc::new(std::sync::Mutex::new(HashMap::new())),
+            max_sessions: crate::config::current().sessions.max_sessions,
+            reserved_slots: Arc::new(AtomicUsize::new(0)),
+            handed_over: HandedOver::default(),
+            groups: Arc::new(RwLock::new(HashMap::new())),
+            launch_configs: Arc::new(RwLock::new(LaunchConfigs::default())),
+            relaunched: Arc::new(std::sync::Mutex::new(HashMap::new())),
+            post_mortems: Arc::new(std::sync::Mutex::new(PostMortems::default())),
         }
     }
 
+    /// Hold a place for a session about to start, failing if it would
+    /// exceed `sessions.max_sessions`
+    ///
+    /// Checked and reserved under the sessions' write lock, so two starts
+    /// can't both take the last place while their adapters spawn.
+    async fn reserve_session_slot(&self) -> Result<SessionSlot> {
+        self.reserve_session_slot_replacing(None).await
+    }
+
+    /// `reserve_session_slot` for a start relaunching session `replaces`,
+    /// which takes that session's place instead of a new one
+    async fn reserve_session_slot_replacing(&self, replaces: Option<&str>) -> Result<SessionSlot> {
+        let sessions = self.sessions.write().await;
+        let mut handed_over = self.handed_over.lock().unwrap();
+        let replaces =
+            replaces.filter(|id| sessions.contains_key(*id) && !handed_over.contains(*id));
+        if let Some(max) = self.max_sessions {
+            let active = sessions
+                .len()
+                .saturating_sub(handed_over.len() + usize::from(replaces.is_some()));
+            if active + self.reserved_slots.load(Ordering::SeqCst) >= max {
+                return Err(Error::InvalidState(format!(
+                    "Session limit reached ({} active); disconnect a session first",
+                    max
+                )));
+            }
+        }
+        if let Some(id) = replaces {
+            handed_over.insert(id.to_string());
+        }
+        self.reserved_slots.fetch_add(1, Ordering::SeqCst);
+        Ok(SessionSlot {
+            reserved: Arc::clone(&self.reserved_slots),
+            held: true,
+            replaces: replaces.map(|id| (Arc::clone(&self.handed_over), id.to_string())),
+        })
+    }
+
+    /// Store a started session in the place reserved for it
+    ///
+    /// A relaunched session it replaces stays handed over until removed.
+    async fn store_session(&self, slot: &mut SessionSlot, session: &Arc<DebugSession>) {
+        let mut sessions = self.sessions.write().await;
+        sessions.insert(session.id.clone(), Arc::clone(session));
+        slot.release();
+        slot.replaces = None;
+    }
+
+    /// Add a session a test built, in a place of its own like a start's
+    #[cfg(test)]
+    pub(crate) async fn insert_session(&self, session: DebugSession) -> Result<String> {
+        let mut slot = self.reserve_session_slot().await?;
+        let session = Arc::new(session);
+        self.store_session(&mut slot, &session).await;
+        Ok(session.id.clone())
+    }
+
     pub async fn create_session(
         &self,
         language: &str,
@@ -38,13 +169,51 @@ impl SessionManager {
         cwd: Option<String>,
         stop_on_entry: bool,
     ) -> Result<String> {
+        self.create_session_with_options(
+            language,
+            program,
+            args,
+            cwd,
+            stop_on_entry,
+            LaunchOptions::default(),
+        )
+        .await
+    }
+
+    /// Create a session with language-specific launch options
+    pub async fn create_session_with_options(
+        &self,
+        language: &str,
+        program: String,
+        args: Vec<String>,
+        cwd: Option<String>,
+        stop_on_entry: bool,
+        options: LaunchOptions,
+    ) -> Result<String> {
+        self.time_spawn(self.spawn_session(language, program, args, cwd, stop_on_entry, options))
+            .await
+    }
+
+    async fn spawn_session(
+        &self,
+        language: &str,
+        program: String,
+        args: Vec<String>,
+        cwd: Option<String>,
+        stop_on_entry: bool,
+        options: LaunchOptions,
+    ) -> Result<String> {
+        let mut slot = self
+            .reserve_session_slot_replacing(options.replaces.as_deref())
+            .await?;
+
         // Type alias for STDIO adapter tuple: (command, args, adapter_id, launch_args, adapter_for_logging)
         type StdioAdapterTuple<'a> = (
             String,
             Vec<String>,
             &'a str,
             serde_json::Value,
-            Box<dyn DebugAdapterLogger + 'a>,
+            Box<dyn DebugAdapterLogger + Send + 'a>,
         );
 
         let (command, adapter_args, adapter_id, launch_args, adapter): StdioAdapterTuple =
@@ -59,12 +228,27 @@ impl SessionManager {
                     let cmd = PythonAdapter::command();
                     let adapter_args = PythonAdapter::args();
                     let adapter_id = PythonAdapter::adapter_id();
-                    let launch_args = PythonAdapter::launch_args_with_options(
+                    let mut launch_args = PythonAdapter::launch_args_for_mode(
                         &program,
                         &args,
                         cwd.as_deref(),
                         stop_on_entry,
+                        options.mode.unwrap_or("program"),
                     );
+                    if !options.env.is_empty() {
+                        launch_args["env"] = serde_json::json!(options.env);
+                    }
+                    if let Some(toolchain) = &options.toolchain {
+                        launch_args["python"] = serde_json::json!(toolchain.path);
+                    }
+                    if launch_args.get("justMyCode").is_none() {
+                        let just_my_code = options
+                            .just_my_code
+                            .or(crate::config::current().python.just_my_code);
+                        if let Some(just_my_code) = just_my_code {
+                            launch_args["justMyCode"] = serde_json::json!(just_my_code);
+                        }
+                    }
 
                     // Log transport initialization
                     adapter.log_transport_init();
@@ -77,6 +261,36 @@ impl SessionManager {
                         Box::new(adapter),
                     )
                 }
+                "java" => {
+                    // Create adapter instance for logging
+                    let adapter = JavaAdapter;
+
+                    // Log adapter selection
+                    adapter.log_selection();
+
+                    // The program is built already: a jar or a main class on the class path
+                    let cmd = JavaAdapter::command().inspect_err(|e| {
+                        adapter.log_spawn_error(e);
+                    })?;
+                    let launch_args = JavaAdapter::launch_args(
+                        &program,
+                        &args,
+                        cwd.as_deref(),
+                        stop_on_entry,
+                        &options.java,
+                    )?;
+
+                    // Log transport initialization
+                    adapter.log_transport_init();
+
+                    (
+                        cmd,
+                        JavaAdapter::args(),
+                        JavaAdapter::adapter_id(),
+                        launch_args,
+                        Box::new(adapter),
+                    )
+                }
                 "ruby" => {
                     // Create adapter instance for logging
                     let adapter = RubyAdapter;
@@ -90,11 +304,18 @@ impl SessionManager {
                     // Ruby uses socket-based communication, not stdio
                     // Spawn rdbg and connect to socket
                     adapter.log_spawn_attempt();
-                    let ruby_session = RubyAdapter::spawn(&program, &args, stop_on_entry)
-                        .await
-                        .inspect_err(|e| {
-                            adapter.log_spawn_error(e);
-                        })?;
+                    let ruby_session = RubyAdapter::spawn_with_options(
+                        &program,
+                        &args,
+                        stop_on_entry,
+                        cwd.as_deref(),
+                        &options.ruby,
+                        &options.exec_prefix,
+                    )
+                    .await
+                    .inspect_err(|e| {
+                        adapter.log_spawn_error(e);
+                    })?;
 
                     // Log successful connection with Ruby-specific details
                     ruby_session.log_connection_success_with_port();
@@ -121,23 +342,29 @@ impl SessionManager {
 
                     // Store session immediately
                     let session_arc = Arc::new(session);
-                    {
-                        let mut sessions = self.sessions.write().await;
-                        sessions.insert(session_id.clone(), session_arc.clone());
-                    }
+                    self.store_session(&mut slot, &session_arc).await;
 
                     // Log workaround application (Ruby requires entry breakpoint workaround)
                     adapter.log_workaround_applied();
 
                     // Initialize and launch in the background
-                    tokio::spawn(
-                        session_arc
-                            .initialize_and_launch_async(adapter_id.to_string(), launch_args),
+                    Self::spawn_initialization(
+                        &session_arc,
+                        adapter_id,
+                        launch_args,
+                        options.launch_gate.clone(),
                     );
 
                     return Ok(session_id);
                 }
                 "nodejs" => {
+                    // A .ts program runs as the JavaScript compiled from it
+                    let program = if source_maps::is_typescript(&program) {
+                        source_maps::launch_program(&program)?
+                    } else {
+                        program
+                    };
+
                     // Create adapter instance for logging
                     let adapter = NodeJsAdapter;
 
@@ -197,10 +424,7 @@ impl SessionManager {
 
                     // Store session immediately
                     let session_arc = Arc::new(session);
-                    {
-                        let mut sessions = self.sessions.write().await;
-                        sessions.insert(session_id.clone(), session_arc.clone());
-                    }
+                    self.store_session(&mut slot, &session_arc).await;
 
                     // Register child session spawn callback on parent client
                     info!("🔄 [NODEJS] Registering child session spawn callback");
@@ -229,9 +453,11 @@ impl SessionManager {
 
                     // Initialize and launch in the background
                     // This will trigger the parent session, which will send startDebugging reverse request
-                    tokio::spawn(
-                        session_arc
-                            .initialize_and_launch_async(adapter_id.to_string(), launch_args),
+                    Self::spawn_initialization(
+                        &session_arc,
+                        adapter_id,
+                        launch_args,
+                        options.launch_gate.clone(),
                     );
 
                     return Ok(session_id);
@@ -243,28 +469,78 @@ impl SessionManager {
                     // Log adapter selection
                     adapter.log_selection();
 
+                    let adapter_id = GoAdapter::adapter_id();
+                    let mode = options.mode.unwrap_or("debug");
+                    if options.go.follow_children {
+                        GoAdapter::check_follow_exec().await?;
+                    }
+                    let mut launch_args = GoAdapter::launch_args_for_mode(
+                        &program,
+                        &args,
+                        cwd.as_deref(),
+                        stop_on_entry,
+                        mode,
+                    );
+                    options.go.apply(&mut launch_args);
+
+                    // Build into a directory of the session's own, removed when it ends;
+                    // not when Delve runs elsewhere, where the directory doesn't exist
+                    let build_dir = if options.go.output.is_none()
+                        && options.exec_prefix.is_empty()
+                        && GoAdapter::builds(mode)
+                    {
+                        GoAdapter::use_build_dir(&mut launch_args)
+                    } else {
+                        None
+                    };
+
+                    // Reuse a parked Delve instead of spawning (and rebuilding) from
+                    // scratch, unless it has to build with another toolchain
+                    if options.keep_adapter_warm.is_some()
+                        && options.toolchain.is_none()
+                        && options.exec_prefix.is_empty()
+                    {
+                        if let Some(session_id) = self
+                            .reuse_warm_adapter(
+                                &mut slot,
+                                language,
+                                &program,
+                                adapter_id,
+                                launch_args.clone(),
+                                options.launch_gate.clone(),
+                            )
+                            .await
+                        {
+                            self.get_session(&session_id)
+                                .await?
+                                .set_build_dir(build_dir)
+                                .await;
+                            return Ok(session_id);
+                        }
+                    }
+
                     // Log transport initialization
                     adapter.log_transport_init();
 
                     // Go uses socket-based communication with Delve DAP server
                     // Spawn dlv dap and connect to socket
                     adapter.log_spawn_attempt();
-                    let go_session = GoAdapter::spawn(&program, &args, stop_on_entry)
-                        .await
-                        .inspect_err(|e| {
-                            adapter.log_spawn_error(e);
-                        })?;
-
-                    // Log successful connection with Go-specific details
-                    go_session.log_connection_success_with_port();
-
-                    let adapter_id = GoAdapter::adapter_id();
-                    let launch_args = GoAdapter::launch_args_with_options(
+                    let spawn_started = std::time::Instant::now();
+                    let go_path = options.toolchain.as_ref().map(|t| t.path.as_path());
+                    let go_session = GoAdapter::spawn(
                         &program,
                         &args,
-                        cwd.as_deref(),
                         stop_on_entry,
-                    );
+                        go_path,
+                        &options.exec_prefix,
+                    )
+                    .await
+                    .inspect_err(|e| {
+                        adapter.log_spawn_error(e);
+                    })?;
+
+                    // Log successful connection with Go-specific details
+                    go_session.log_connection_success_with_port();
 
                     // Create DAP client from socket
                     let client = DapClient::from_socket(go_session.socket)
@@ -277,21 +553,30 @@ impl SessionManager {
                     let session =
                         DebugSession::new(language.to_string(), program.clone(), client).await?;
                     let session_id = session.id.clone();
+                    session.set_build_dir(build_dir).await;
 
                     // Store session immediately
                     let session_arc = Arc::new(session);
-                    {
-                        let mut sessions = self.sessions.write().await;
-                        sessions.insert(session_id.clone(), session_arc.clone());
+                    self.store_session(&mut slot, &session_arc).await;
+
+                    if let Some(grace_period) = options.keep_adapter_warm {
+                        session_arc
+                            .set_warm_adapter(Some(WarmAdapterConfig {
+                                grace_period,
+                                startup_time: spawn_started.elapsed(),
+                            }))
+                            .await;
                     }
 
                     // Log workaround application (if any Go-specific workarounds needed)
                     adapter.log_workaround_applied();
 
                     // Initialize and launch in the background
-                    tokio::spawn(
-                        session_arc
-                            .initialize_and_launch_async(adapter_id.to_string(), launch_args),
+                    Self::spawn_initialization(
+                        &session_arc,
+                        adapter_id,
+                        launch_args,
+                        options.launch_gate.clone(),
                     );
 
                     return Ok(session_id);
@@ -361,18 +646,17 @@ impl SessionManager {
 
                     // Store session immediately
                     let session_arc = Arc::new(session);
-                    {
-                        let mut sessions = self.sessions.write().await;
-                        sessions.insert(session_id.clone(), session_arc.clone());
-                    }
+                    self.store_session(&mut slot, &session_arc).await;
 
                     // Log workaround application (Rust doesn't require workarounds)
                     adapter.log_workaround_applied();
 
                     // Initialize and launch in the background
-                    tokio::spawn(
-                        session_arc
-                            .initialize_and_launch_async(adapter_id.to_string(), launch_args),
+                    Self::spawn_initialization(
+                        &session_arc,
+                        adapter_id,
+                        launch_args,
+                        options.launch_gate.clone(),
                     );
 
                     return Ok(session_id);
@@ -383,6 +667,8 @@ impl SessionManager {
         // Spawn DAP client (Python path - uses STDIO transport)
         // Adapter instance is passed from match arm above for language-specific logging
         adapter.log_spawn_attempt();
+        let (command, adapter_args) =
+            exec_prefix::wrap(&options.exec_prefix, &command, &adapter_args);
         let client = DapClient::spawn(&command, &adapter_args)
             .await
             .inspect_err(|e| {
@@ -398,25 +684,312 @@ impl SessionManager {
 
         // Store session immediately
         let session_arc = Arc::new(session);
-        {
-            let mut sessions = self.sessions.write().await;
-            sessions.insert(session_id.clone(), session_arc.clone());
-        }
+        self.store_session(&mut slot, &session_arc).await;
 
         // Log workaround if needed (Python doesn't require workarounds)
         adapter.log_workaround_applied();
 
         // Initialize and launch in the background
-        tokio::spawn(session_arc.initialize_and_launch_async(adapter_id.to_string(), launch_args));
+        Self::spawn_initialization(
+            &session_arc,
+            adapter_id,
+            launch_args,
+            options.launch_gate.clone(),
+        );
 
         Ok(session_id)
     }
 
+    /// Attach to an already running process by pid
+    ///
+    /// Supported for Go (Delve local attach) and Python (debugpy injection).
+    /// `program` is only used to label the session (e.g. the target's command line).
+    /// With `read_only`, the session can't change the process (see `dap::read_only`).
+    pub async fn attach_session(
+        &self,
+        language: &str,
+        process_id: u32,
+        program: String,
+        read_only: bool,
+    ) -> Result<String> {
+        self.time_spawn(self.spawn_attach(language, process_id, program, read_only))
+            .await
+    }
+
+    async fn spawn_attach(
+        &self,
+        language: &str,
+        process_id: u32,
+        program: String,
+        read_only: bool,
+    ) -> Result<String> {
+        let mut slot = self.reserve_session_slot().await?;
+
+        let (client, adapter_id, attach_args) = match language {
+            "go" => {
+                let adapter = GoAdapter;
+                adapter.log_selection();
+                adapter.log_transport_init();
+
+                // Delve attaches from its own DAP server, so spawn `dlv dap` without a program
+                adapter.log_spawn_attempt();
+                let go_session = GoAdapter::spawn(&program, &[], false, None, &[])
+                    .await
+                    .inspect_err(|e| {
+                        adapter.log_spawn_error(e);
+                    })?;
+                go_session.log_connection_success_with_port();
+
+                let client = DapClient::from_socket(go_session.socket)
+                    .await
+                    .inspect_err(|e| {
+                        adapter.log_connection_error(e);
+                    })?;
+
+                (
+                    client,
+                    GoAdapter::adapter_id(),
+                    GoAdapter::attach_args(process_id),
+                )
+            }
+            "python" => {
+                let adapter = PythonAdapter;
+                adapter.log_selection();
+                adapter.log_transport_init();
+
+                adapter.log_spawn_attempt();
+                let client = DapClient::spawn(&PythonAdapter::command(), &PythonAdapter::args())
+                    .await
+                    .inspect_err(|e| {
+                        adapter.log_spawn_error(e);
+                    })?;
+                adapter.log_connection_success();
+
+                (
+                    client,
+                    PythonAdapter::adapter_id(),
+                    PythonAdapter::attach_args(process_id),
+                )
+            }
+            _ => {
+                return Err(Error::InvalidRequest(format!(
+                    "Attach is not supported for language: {} (supported: go, python)",
+                    language
+                )))
+            }
+        };
+
+        info!("🔗 Attaching {} debugger to pid {}", language, process_id);
+
+        let session = DebugSession::new(language.to_string(), program, client).await?;
+        if read_only {
+            session.make_read_only().await;
+        }
+        let session_id = session.id.clone();
+
+        let session_arc = Arc::new(session);
+        self.store_session(&mut slot, &session_arc).await;
+
+        // Attach uses the same initialize/configurationDone handshake as launch
+        Self::spawn_initialization(&session_arc, adapter_id, attach_args, None);
+
+        Ok(session_id)
+    }
+
+    /// Attach to the JDWP agent of a running JVM (Java's attach mode)
+    ///
+    /// `program` is only used to label the session; `read_only` as for
+    /// `attach_session`.
+    pub async fn attach_jvm_session(
+        &self,
+        host_name: &str,
+        port: u16,
+        program: String,
+        read_only: bool,
+    ) -> Result<String> {
+        self.time_spawn(self.spawn_jvm_attach(host_name, port, program, read_only))
+            .await
+    }
+
+    async fn spawn_jvm_attach(
+        &self,
+        host_name: &str,
+        port: u16,
+        program: String,
+        read_only: bool,
+    ) -> Result<String> {
+        let mut slot = self.reserve_session_slot().await?;
+
+        let adapter = JavaAdapter;
+        adapter.log_selection();
+        adapter.log_transport_init();
+
+        adapter.log_spawn_attempt();
+        let command = JavaAdapter::command().inspect_err(|e| {
+            adapter.log_spawn_error(e);
+        })?;
+        let client = DapClient::spawn(&command, &JavaAdapter::args())
+            .await
+            .inspect_err(|e| {
+                adapter.log_spawn_error(e);
+            })?;
+        adapter.log_connection_success();
+
+        info!(
+            "🔗 Attaching java debugger to JVM at {}:{}",
+            host_name, port
+        );
+
+        let session = DebugSession::new("java".to_string(), program, client).await?;
+        if read_only {
+            session.make_read_only().await;
+        }
+        let session_id = session.id.clone();
+
+        let session_arc = Arc::new(session);
+        self.store_session(&mut slot, &session_arc).await;
+
+        Self::spawn_initialization(
+            &session_arc,
+            JavaAdapter::adapter_id(),
+            JavaAdapter::attach_args(host_name, port),
+            None,
+        );
+
+        Ok(session_id)
+    }
+
+    /// The session a REPL was opened in
+    pub async fn get_repl_session(&self, repl_id: &str) -> Result<Arc<DebugSession>> {
+        let sessions: Vec<(String, Arc<DebugSession>)> = self
+            .sessions
+            .read()
+            .await
+            .iter()
+            .map(|(id, session)| (id.clone(), session.clone()))
+            .collect();
+        for (session_id, session) in sessions {
+            if session.has_repl(repl_id).await {
+                self.last_activity
+                    .lock()
+                    .unwrap()
+                    .insert(session_id, Instant::now());
+                return Ok(session);
+            }
+        }
+        Err(Error::InvalidRequest(format!(
+            "Unknown REPL '{}' (REPLs end with their session)",
+            repl_id
+        )))
+    }
+
     pub async fn get_session(&self, session_id: &str) -> Result<Arc<DebugSession>> {
+        let session_id = self.resolve_session_id(session_id);
         let sessions = self.sessions.read().await;
-        sessions
+        let session = sessions
+            .get(&session_id)
+            .cloned()
+            .ok_or_else(|| Error::SessionNotFound(session_id.clone()))?;
+
+        self.last_activity
+            .lock()
+            .unwrap()
+            .insert(session_id, Instant::now());
+        Ok(session)
+    }
+
+    /// The session now standing for `session_id`: itself, or the session
+    /// that relaunched it (after relaunches of relaunches, the last one)
+    pub fn resolve_session_id(&self, session_id: &str) -> String {
+        self.relaunched
+            .lock()
+            .unwrap()
             .get(session_id)
             .cloned()
+            .unwrap_or_else(|| session_id.to_string())
+    }
+
+    /// Replace a relaunched session by the new one: the old session is
+    /// removed and its id, like the ids that led to it, leads to `new_id`
+    /// from now on
+    pub async fn replace_session(&self, old_id: &str, new_id: &str) -> Result<()> {
+        let old_id = self.resolve_session_id(old_id);
+        self.remove_resolved_session(&old_id).await?;
+        let mut relaunched = self.relaunched.lock().unwrap();
+        for current in relaunched.values_mut() {
+            if *current == old_id {
+                *current = new_id.to_string();
+            }
+        }
+        relaunched.insert(old_id, new_id.to_string());
+        Ok(())
+    }
+
+    /// Give up relaunching `old_id` as `new_id`: the new session is removed
+    /// and the old one counts against `max_sessions` again
+    pub async fn abandon_relaunch(&self, old_id: &str, new_id: &str) {
+        if let Err(e) = self.remove_session(new_id).await {
+            warn!("Failed to remove abandoned session {}: {}", new_id, e);
+        }
+        self.handed_over.lock().unwrap().remove(old_id);
+    }
+
+    /// Disconnect sessions no tool has used for `idle`, returning their ids
+    ///
+    /// Sessions never looked up since creation count as active from the first
+    /// sweep that sees them.
+    pub async fn reap_idle_sessions(&self, idle: Duration) -> Vec<String> {
+        let now = Instant::now();
+        let ids = self.list_sessions().await;
+
+        let idle_ids: Vec<String> = {
+            let mut activity = self.last_activity.lock().unwrap();
+            activity.retain(|id, _| ids.contains(id));
+            ids.into_iter()
+                .filter(|id| {
+                    let last = *activity.entry(id.clone()).or_insert(now);
+                    now.duration_since(last) >= idle
+                })
+                .collect()
+        };
+
+        for id in &idle_ids {
+            info!("💤 Session {} idle for {:?}, disconnecting", id, idle);
+            if let Err(e) = self.remove_session(id).await {
+                warn!("Failed to remove idle session {}: {}", id, e);
+            }
+        }
+        self.post_mortems.lock().unwrap().expire(idle);
+        idle_ids
+    }
+
+    /// The post-mortem of a session whose program ended, whether the session
+    /// is still there or was removed
+    ///
+    /// The post-mortem of a removed session counts as read, and is dropped
+    /// by the idle reaper an idle timeout later (see `post_mortem`).
+    pub async fn get_post_mortem(&self, session_id: &str) -> Result<SessionPostMortem> {
+        let resolved = self.resolve_session_id(session_id);
+        // A relaunched session's own post-mortem, rather than its successor's
+        if resolved != session_id {
+            if let Some(post_mortem) = self.post_mortems.lock().unwrap().read(session_id) {
+                return Ok(post_mortem);
+            }
+        }
+        if let Some(session) = self.sessions.read().await.get(&resolved).cloned() {
+            return match session.post_mortem().await {
+                Some(post_mortem) => Ok(post_mortem),
+                None => Err(Error::InvalidState(format!(
+                    "Session {} is {:?}; a post-mortem is taken when its program ends",
+                    resolved,
+                    session.get_state().await
+                ))),
+            };
+        }
+        self.post_mortems
+            .lock()
+            .unwrap()
+            .read(&resolved)
             .ok_or_else(|| Error::SessionNotFound(session_id.to_string()))
     }
 
@@ -433,12 +1006,311 @@ impl SessionManager {
         sessions.keys().cloned().collect()
     }
 
+    /// Disconnect a session, keeping its adapter alive if it was started warm
+    ///
+    /// When the session was started with `keep_adapter_warm`, an adapter is
+    /// parked instead of shut down and waits up to the grace period for the
+    /// next start of the same program. One that supports `restart` is parked
+    /// with its session, which disappears from the session list. Delve doesn't
+    /// advertise `restart` and exits with its session, so a spare Delve is
+    /// spawned and parked instead, to be launched by the next start. Returns
+    /// the grace period if parked.
+    pub async fn park_session(&self, session_id: &str) -> Result<Option<Duration>> {
+        self.park_session_with(session_id, Self::spawn_spare_adapter)
+            .await
+    }
+
+    /// `park_session`, spawning spare adapters with `spawn_spare`
+    async fn park_session_with<F, Fut>(
+        &self,
+        session_id: &str,
+        spawn_spare: F,
+    ) -> Result<Option<Duration>>
+    where
+        F: FnOnce(String, String) -> Fut,
+        Fut: Future<Output = Result<DebugSession>>,
+    {
+        let session_id = &self.resolve_session_id(session_id);
+        let session = self.get_session(session_id).await?;
+
+        let Some(config) = session.warm_adapter().await else {
+            self.remove_session(session_id).await?;
+            return Ok(None);
+        };
+
+        let session = if session.supports_restart().await {
+            self.sessions.write().await.remove(session_id);
+            session
+        } else {
+            self.remove_session(session_id).await?;
+            let spawn_started = Instant::now();
+            let spare = match spawn_spare(session.language.clone(), session.program.clone()).await {
+                Ok(spare) => spare,
+                Err(e) => {
+                    warn!(
+                        "Could not spawn a spare adapter for session {}: {}",
+                        session_id, e
+                    );
+                    return Ok(None);
+                }
+            };
+            spare
+                .set_warm_adapter(Some(WarmAdapterConfig {
+                    grace_period: config.grace_period,
+                    startup_time: spawn_started.elapsed(),
+                }))
+                .await;
+            Arc::new(spare)
+        };
+
+        let key = (session.language.clone(), session.program.clone());
+        let replaced = self
+            .warm_adapters
+            .write()
+            .await
+            .insert(key.clone(), session.clone());
+        if let Some(replaced) = replaced {
+            let _ = replaced.disconnect().await;
+        }
+
+        info!(
+            "🔥 Parked adapter of session {} for {:?}",
+            session_id, config.grace_period
+        );
+        tokio::spawn(Self::expire_warm_adapter(
+            self.warm_adapters.clone(),
+            key,
+            session,
+            config.grace_period,
+        ));
+
+        Ok(Some(config.grace_period))
+    }
+
+    /// Spawn an adapter for the next start of `program`, connected but not
+    /// yet initialized; only Go sessions are kept warm
+    async fn spawn_spare_adapter(language: String, program: String) -> Result<DebugSession> {
+        if language != "go" {
+            return Err(Error::InvalidRequest(format!(
+                "No spare adapter for {}",
+                language
+            )));
+        }
+        let go_session = GoAdapter::spawn(&program, &[], false, None, &[]).await?;
+        let client = DapClient::from_socket(go_session.socket).await?;
+        DebugSession::new(language, program, client).await
+    }
+
+    /// Tear down a parked adapter once its grace period lapses, unless it was
+    /// reused (or replaced) in the meantime
+    async fn expire_warm_adapter(
+        warm_adapters: Arc<RwLock<HashMap<WarmAdapterKey, Arc<DebugSession>>>>,
+        key: WarmAdapterKey,
+        session: Arc<DebugSession>,
+        grace_period: Duration,
+    ) {
+        tokio::time::sleep(grace_period).await;
+
+        let mut warm = warm_adapters.write().await;
+        match warm.get(&key) {
+            Some(parked) if Arc::ptr_eq(parked, &session) => {
+                warm.remove(&key);
+            }
+            _ => return,
+        }
+        drop(warm);
+
+        info!(
+            "🧊 Grace period lapsed, shutting down parked adapter of session {}",
+            session.id
+        );
+        let _ = session.disconnect().await;
+    }
+
+    /// Restart a parked adapter for `program`, returning its session id
+    ///
+    /// Returns None (after tearing the adapter down) if the restart fails, so
+    /// the caller can fall back to a cold start.
+    async fn reuse_warm_adapter(
+        &self,
+        slot: &mut SessionSlot,
+        language: &str,
+        program: &str,
+        adapter_id: &str,
+        launch_args: serde_json::Value,
+        gate: Option<LaunchGate>,
+    ) -> Option<String> {
+        let key = (language.to_string(), program.to_string());
+        let session = self.warm_adapters.write().await.remove(&key)?;
+        let saved = session
+            .warm_adapter()
+            .await
+            .map(|config| config.startup_time)
+            .unwrap_or_default();
+
+        if !session.supports_restart().await {
+            // A spare adapter, not initialized yet: started like a new session
+            info!(
+                "♻️  Launching on spare adapter of session {} (saved ~{:?} startup)",
+                session.id, saved
+            );
+            session.set_reused_startup(saved).await;
+            self.store_session(slot, &session).await;
+            Self::spawn_initialization(&session, adapter_id, launch_args, gate);
+            return Some(session.id.clone());
+        }
+
+        if let Err(e) = session.restart(launch_args, saved).await {
+            warn!(
+                "Reusing parked adapter of session {} failed ({}), starting a new one",
+                session.id, e
+            );
+            let _ = session.disconnect().await;
+            return None;
+        }
+
+        info!(
+            "♻️  Reused parked adapter for session {} (saved ~{:?} startup)",
+            session.id, saved
+        );
+        self.store_session(slot, &session).await;
+        Some(session.id.clone())
+    }
+
+    /// Group already started sessions, returning the group id
+    ///
+    /// Each member session learns its group and name, so its stops can be
+    /// told apart from the other members'.
+    pub async fn create_group(&self, members: Vec<GroupMember>) -> Result<String> {
+        let group_id = uuid::Uuid::new_v4().to_string();
+        for member in &members {
+            self.get_session(&member.session_id)
+                .await?
+                .set_group_member(Membership {
+                    group_id: group_id.clone(),
+                    name: member.name.clone(),
+                })
+                .await;
+        }
+
+        info!(
+            "👥 Created group {} with {} members",
+            group_id,
+            members.len()
+        );
+        self.groups.write().await.insert(
+            group_id.clone(),
+            SessionGroup {
+                id: group_id.clone(),
+                members,
+            },
+        );
+        Ok(group_id)
+    }
+
+    pub async fn get_group(&self, group_id: &str) -> Result<SessionGroup> {
+        self.groups
+            .read()
+            .await
+            .get(group_id)
+            .cloned()
+            .ok_or_else(|| Error::GroupNotFound(group_id.to_string()))
+    }
+
+    /// Save a launch config; returns whether it replaced one of the same name
+    pub async fn save_launch_config(&self, config: LaunchConfig) -> Result<bool> {
+        self.launch_configs.write().await.save(config)
+    }
+
+    pub async fn launch_config(&self, workspace: Option<&str>, name: &str) -> Result<LaunchConfig> {
+        self.launch_configs
+            .read()
+            .await
+            .get(workspace, name)
+            .cloned()
+    }
+
+    /// Forget a group; its member sessions are left as they are
+    pub async fn remove_group(&self, group_id: &str) -> Result<SessionGroup> {
+        self.groups
+            .write()
+            .await
+            .remove(group_id)
+            .ok_or_else(|| Error::GroupNotFound(group_id.to_string()))
+    }
+
+    /// Run `spawn`, which spawns an adapter and creates a session for it,
+    /// recording how long that took as the start's `SpawningAdapter` phase
+    async fn time_spawn(&self, spawn: impl Future<Output = Result<String>>) -> Result<String> {
+        let started = Instant::now();
+        match spawn.await {
+            Ok(session_id) => {
+                if let Ok(session) = self.get_session(&session_id).await {
+                    session
+                        .launch_phases()
+                        .record_first(LaunchPhase::SpawningAdapter, started.elapsed());
+                }
+                Ok(session_id)
+            }
+            Err(e) => Err(launch_phase::annotate(
+                e,
+                &PhaseTiming {
+                    phase: LaunchPhase::SpawningAdapter,
+                    elapsed_ms: started.elapsed().as_millis() as u64,
+                },
+            )),
+        }
+    }
+
+    /// Initialize and launch (or attach) a new session in the background,
+    /// once `gate` opens if there is one
+    fn spawn_initialization(
+        session: &Arc<DebugSession>,
+        adapter_id: &str,
+        launch_args: serde_json::Value,
+        gate: Option<LaunchGate>,
+    ) {
+        let task = tokio::spawn(
+            session
+                .clone()
+                .initialize_and_launch_async(adapter_id.to_string(), launch_args, gate)
+                .instrument(log_level::session_span(Some(&session.id))),
+        );
+        session.set_init_task(task.abort_handle());
+    }
+
+    /// Disconnect and forget a session, keeping its post-mortem but not
+    /// its spilled responses
+    ///
+    /// A session still starting has its start cancelled first, so a hung
+    /// adapter or build doesn't keep it half set up.
     pub async fn remove_session(&self, session_id: &str) -> Result<()> {
+        let session_id = self.resolve_session_id(session_id);
+        self.remove_resolved_session(&session_id).await?;
+        // The ids of sessions it relaunched lead nowhere now
+        self.relaunched
+            .lock()
+            .unwrap()
+            .retain(|_, current| *current != session_id);
+        Ok(())
+    }
+
+    /// `remove_session` of a session id that is not an alias, leaving the
+    /// ids that led to it
+    async fn remove_resolved_session(&self, session_id: &str) -> Result<()> {
         // Disconnect the session first
         if let Ok(session) = self.get_session(session_id).await {
+            session.cancel_start().await;
             let _ = session.disconnect().await;
+            if let Some(post_mortem) = session.post_mortem().await {
+                self.post_mortems.lock().unwrap().store(post_mortem);
+            }
         }
 
+        self.last_activity.lock().unwrap().remove(session_id);
+        self.handed_over.lock().unwrap().remove(session_id);
+        log_level::clear_session(session_id);
+        crate::mcp::response_limit::remove_session_spills(&crate::config::spill_dir(), session_id);
         let mut sessions = self.sessions.write().await;
         sessions
             .remove(session_id)
@@ -451,6 +1323,7 @@ impl SessionManager {
 #[cfg(test)]
 mod tests {
     use super::*;
+    use crate::debug::state::DebugState;
 
     #[tokio::test]
     async fn test_session_manager_new() {
@@ -480,6 +1353,24 @@ mod tests {
         }
     }
 
+    #[tokio::test]
+    async fn test_group_of_unknown_session_not_created() {
+        let manager = SessionManager::new();
+        let result = manager
+            .create_group(vec![GroupMember {
+                name: "server".to_string(),
+                session_id: "nonexistent".to_string(),
+            }])
+            .await;
+        assert!(matches!(result, Err(Error::SessionNotFound(_))));
+
+        assert!(matches!(
+            manager.get_group("nonexistent").await,
+            Err(Error::GroupNotFound(_))
+        ));
+        assert!(manager.remove_group("nonexistent").await.is_err());
+    }
+
     #[tokio::test]
     async fn test_remove_session_not_found() {
         let manager = SessionManager::new();
@@ -510,4 +1401,599 @@ mod tests {
             _ => panic!("Expected AdapterNotFound error"),
         }
     }
+
+    #[tokio::test]
+    async fn test_attach_session_unsupported_language() {
+        let manager = SessionManager::new();
+        let result = manager
+            .attach_session("ruby", 1234, "app.rb".to_string(), false)
+            .await;
+
+        match result {
+            Err(Error::InvalidRequest(msg)) => assert!(msg.contains("not supported")),
+            _ => panic!("Expected InvalidRequest error"),
+        }
+        assert!(manager.list_sessions().await.is_empty());
+    }
+
+    type RecordedCommands = Arc<tokio::sync::Mutex<Vec<String>>>;
+
+    /// Client of a fake adapter that advertises `capabilities` and records
+    /// every request it receives
+    async fn fake_adapter(capabilities: serde_json::Value) -> (DapClient, RecordedCommands) {
+        use crate::dap::transport::DapTransport;
+        use crate::dap::types::{Message, Response};
+
+        let listener = tokio::net::TcpListener::bind("127.0.0.1:0").await.unwrap();
+        let addr = listener.local_addr().unwrap();
+        let commands: RecordedCommands = Arc::default();
+
+        let recorded = commands.clone();
+        tokio::spawn(async move {
+            let (stream, _) = listener.accept().await.unwrap();
+            stream.set_nodelay(true).unwrap();
+            let mut transport = DapTransport::new_socket(stream);
+            while let Ok(Message::Request(req)) = transport.read_message().await {
+                recorded.lock().await.push(req.command.clone());
+                let body = (req.command == "initialize").then(|| capabilities.clone());
+                let response = Message::Response(Response {
+                    seq: req.seq + 1000,
+                    request_seq: req.seq,
+                    command: req.command,
+                    success: true,
+                    message: None,
+                    body,
+                });
+                if transport.write_message(&response).await.is_err() {
+                    break;
+                }
+            }
+        });
+
+        let socket = tokio::net::TcpStream::connect(addr).await.unwrap();
+        (DapClient::from_socket(socket).await.unwrap(), commands)
+    }
+
+    /// Capabilities of Delve's `initialize` response (dlv dap), which has no
+    /// supportsRestartRequest
+    fn delve_capabilities() -> serde_json::Value {
+        serde_json::json!({
+            "supportsConfigurationDoneRequest": true,
+            "supportsFunctionBreakpoints": true,
+            "supportsInstructionBreakpoints": true,
+            "supportsConditionalBreakpoints": true,
+            "supportsHitConditionalBreakpoints": true,
+            "supportsEvaluateForHovers": true,
+            "supportsSetVariable": true,
+            "supportsExceptionInfoRequest": true,
+            "supportTerminateDebuggee": true,
+            "supportsDelayedStackTraceLoading": true,
+            "supportsLogPoints": true,
+            "supportsDisassembleRequest": true,
+            "supportsClipboardContext": true,
+            "supportsSteppingGranularity": true
+        })
+    }
+
+    /// Go session connected to a fake Delve that supports `restart` and
+    /// records every request it receives
+    async fn warm_go_session(grace_period: Duration) -> (Arc<DebugSession>, RecordedCommands) {
+        let (client, commands) =
+            fake_adapter(serde_json::json!({"supportsRestartRequest": true})).await;
+        let session = DebugSession::new("go".to_string(), "/w/main.go".to_string(), client)
+            .await
+            .unwrap();
+        session.initialize("delve").await.unwrap();
+        session
+            .set_warm_adapter(Some(WarmAdapterConfig {
+                grace_period,
+                startup_time: Duration::from_millis(1500),
+            }))
+            .await;
+
+        (Arc::new(session), commands)
+    }
+
+    async fn manager_with(session: &Arc<DebugSession>) -> SessionManager {
+        let manager = SessionManager::new();
+        manager
+            .sessions
+            .write()
+            .await
+            .insert(session.id.clone(), session.clone());
+        manager
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_launch_waits_for_its_gate() {
+        use crate::dap::transport::DapTransport;
+        use crate::dap::types::Message;
+
+        let listener = tokio::net::TcpListener::bind("127.0.0.1:0").await.unwrap();
+        let addr = listener.local_addr().unwrap();
+        let commands: RecordedCommands = Arc::default();
+        let recorded = commands.clone();
+        tokio::spawn(async move {
+            let (stream, _) = listener.accept().await.unwrap();
+            let mut transport = DapTransport::new_socket(stream);
+            while let Ok(Message::Request(req)) = transport.read_message().await {
+                recorded.lock().await.push(req.command);
+            }
+        });
+        let socket = tokio::net::TcpStream::connect(addr).await.unwrap();
+        let client = DapClient::from_socket(socket).await.unwrap();
+        let session = Arc::new(
+            DebugSession::new("go".to_string(), "/w/main.go".to_string(), client)
+                .await
+                .unwrap(),
+        );
+
+        // Set up while held: the auto-continue flag is in place before
+        // the initialization can read it
+        let (hold, gate) = crate::adapters::launch_gate();
+        SessionManager::spawn_initialization(&session, "delve", serde_json::json!({}), Some(gate));
+        session.set_auto_continue_from_entry(true).await;
+        tokio::time::sleep(Duration::from_millis(400)).await;
+        assert!(commands.lock().await.is_empty());
+        assert_eq!(session.get_state().await, DebugState::NotStarted);
+
+        drop(hold);
+        tokio::time::sleep(Duration::from_millis(200)).await;
+        assert!(commands.lock().await.contains(&"initialize".to_string()));
+        assert!(session.get_full_state().await.auto_continue_entry);
+        session.cancel_start().await;
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_breakpoints_set_while_held_precede_configuration_done() {
+        use crate::dap::transport::DapTransport;
+        use crate::dap::types::{Event, Message, Response};
+
+        // Answers everything, and sends `initialized` after `initialize`
+        let listener = tokio::net::TcpListener::bind("127.0.0.1:0").await.unwrap();
+        let addr = listener.local_addr().unwrap();
+        let commands: RecordedCommands = Arc::default();
+        let recorded = commands.clone();
+        tokio::spawn(async move {
+            let (stream, _) = listener.accept().await.unwrap();
+            stream.set_nodelay(true).unwrap();
+            let mut transport = DapTransport::new_socket(stream);
+            while let Ok(Message::Request(req)) = transport.read_message().await {
+                recorded.lock().await.push(req.command.clone());
+                let body = match req.command.as_str() {
+                    "initialize" => serde_json::json!({
+                        "supportsConfigurationDoneRequest": true,
+                        "supportsFunctionBreakpoints": true
+                    }),
+                    "setFunctionBreakpoints" => serde_json::json!({"breakpoints": []}),
+                    "setBreakpoints" => {
+                        serde_json::json!({"breakpoints": [{"id": 1, "verified": true, "line": 7}]})
+                    }
+                    _ => serde_json::json!({}),
+                };
+                let initialize = req.command == "initialize";
+                let response = Message::Response(Response {
+                    seq: req.seq + 1000,
+                    request_seq: req.seq,
+                    command: req.command,
+                    success: true,
+                    message: None,
+                    body: Some(body),
+                });
+                transport.write_message(&response).await.unwrap();
+                if initialize {
+                    let event = Message::Event(Event {
+                        seq: 2000,
+                        event: "initialized".to_string(),
+                        body: None,
+                    });
+                    transport.write_message(&event).await.unwrap();
+                }
+            }
+        });
+        let socket = tokio::net::TcpStream::connect(addr).await.unwrap();
+        let client = DapClient::from_socket(socket).await.unwrap();
+        let session = Arc::new(
+            DebugSession::new("go".to_string(), "/w/main.go".to_string(), client)
+                .await
+                .unwrap(),
+        );
+
+        // However long setting them takes, they go out with the launch, the
+        // entry breakpoint too
+        let (hold, gate) = crate::adapters::launch_gate();
+        SessionManager::spawn_initialization(&session, "delve", serde_json::json!({}), Some(gate));
+        tokio::time::sleep(Duration::from_millis(300)).await;
+        session
+            .set_breakpoint("/w/main.go".to_string(), 7)
+            .await
+            .unwrap();
+        session
+            .arm_entry_breakpoint(crate::adapters::EntryBreakpoint::Function(
+                "main.main".to_string(),
+            ))
+            .await
+            .unwrap();
+        drop(hold);
+
+        let deadline = tokio::time::Instant::now() + Duration::from_secs(5);
+        while !commands
+            .lock()
+            .await
+            .contains(&"configurationDone".to_string())
+        {
+            assert!(
+                tokio::time::Instant::now() < deadline,
+                "no configurationDone"
+            );
+            tokio::time::sleep(Duration::from_millis(20)).await;
+        }
+        let commands = commands.lock().await.clone();
+        let position = |command: &str| commands.iter().position(|c| c == command).unwrap();
+        assert!(position("setBreakpoints") < position("configurationDone"));
+        assert!(position("setFunctionBreakpoints") < position("configurationDone"));
+        session.cancel_start().await;
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_disconnect_cancels_hung_start() {
+        use crate::dap::transport::DapTransport;
+        use crate::dap::types::Message;
+
+        // An adapter that never answers, like one stuck before `initialized`
+        let listener = tokio::net::TcpListener::bind("127.0.0.1:0").await.unwrap();
+        let addr = listener.local_addr().unwrap();
+        let commands: RecordedCommands = Arc::default();
+        let recorded = commands.clone();
+        tokio::spawn(async move {
+            let (stream, _) = listener.accept().await.unwrap();
+            let mut transport = DapTransport::new_socket(stream);
+            while let Ok(Message::Request(req)) = transport.read_message().await {
+                recorded.lock().await.push(req.command);
+            }
+        });
+        let socket = tokio::net::TcpStream::connect(addr).await.unwrap();
+        let client = DapClient::from_socket(socket).await.unwrap();
+        let session = Arc::new(
+            DebugSession::new("go".to_string(), "/w/main.go".to_string(), client)
+                .await
+                .unwrap(),
+        );
+        let manager = manager_with(&session).await;
+
+        SessionManager::spawn_initialization(&session, "delve", serde_json::json!({}), None);
+        tokio::time::sleep(Duration::from_millis(500)).await;
+        assert_eq!(session.get_state().await, DebugState::Initializing);
+        assert!(commands.lock().await.contains(&"initialize".to_string()));
+
+        manager.remove_session(&session.id).await.unwrap();
+        assert!(session.start_cancelled());
+        assert!(manager.list_sessions().await.is_empty());
+        assert!(commands.lock().await.contains(&"disconnect".to_string()));
+
+        // The aborted start let go of the session, and never fails it later
+        tokio::time::sleep(Duration::from_millis(100)).await;
+        assert_eq!(Arc::strong_count(&session), 1);
+        assert_eq!(session.get_state().await, DebugState::Terminated);
+        assert!(!session.cancel_start().await);
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_parked_adapter_torn_down_after_grace_period() {
+        let (session, commands) = warm_go_session(Duration::from_millis(50)).await;
+        let manager = manager_with(&session).await;
+
+        let parked = manager.park_session(&session.id).await.unwrap();
+        assert_eq!(parked, Some(Duration::from_millis(50)));
+        assert!(manager.list_sessions().await.is_empty());
+        assert_eq!(manager.warm_adapters.read().await.len(), 1);
+        assert!(!commands.lock().await.contains(&"disconnect".to_string()));
+
+        tokio::time::sleep(Duration::from_millis(300)).await;
+
+        assert!(manager.warm_adapters.read().await.is_empty());
+        assert!(commands.lock().await.contains(&"disconnect".to_string()));
+        assert_eq!(session.get_state().await, DebugState::Terminated);
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_warm_adapter_reused_within_grace_period() {
+        let (session, commands) = warm_go_session(Duration::from_millis(100)).await;
+        let manager = manager_with(&session).await;
+        manager.park_session(&session.id).await.unwrap();
+
+        let mut slot = manager.reserve_session_slot().await.unwrap();
+        let reused = manager
+            .reuse_warm_adapter(
+                &mut slot,
+                "go",
+                "/w/main.go",
+                "delve",
+                serde_json::json!({"program": "/w/main.go"}),
+                None,
+            )
+            .await;
+        assert_eq!(reused.as_deref(), Some(session.id.as_str()));
+        assert_eq!(
+            session.reused_startup().await,
+            Some(Duration::from_millis(1500))
+        );
+        assert_eq!(manager.list_sessions().await, vec![session.id.clone()]);
+
+        // The lapsed grace period must not tear down an adapter that was reused
+        tokio::time::sleep(Duration::from_millis(300)).await;
+        let commands = commands.lock().await.clone();
+        assert_eq!(commands, vec!["initialize", "restart"]);
+        assert!(manager.get_session(&session.id).await.is_ok());
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_delve_without_restart_parks_a_spare_adapter() {
+        let (client, commands) = fake_adapter(delve_capabilities()).await;
+        let session = DebugSession::new("go".to_string(), "/w/main.go".to_string(), client)
+            .await
+            .unwrap();
+        session.initialize("delve").await.unwrap();
+        session
+            .set_warm_adapter(Some(WarmAdapterConfig {
+                grace_period: Duration::from_secs(5),
+                startup_time: Duration::from_millis(1500),
+            }))
+            .await;
+        let session = Arc::new(session);
+        assert!(!session.supports_restart().await);
+        let manager = manager_with(&session).await;
+
+        let (spare_client, spare_commands) = fake_adapter(delve_capabilities()).await;
+        let parked = manager
+            .park_session_with(&session.id, |language, program| async move {
+                DebugSession::new(language, program, spare_client).await
+            })
+            .await
+            .unwrap();
+        assert_eq!(parked, Some(Duration::from_secs(5)));
+        // The session ended with its Delve; the spare waits for the next start
+        assert!(commands.lock().await.contains(&"disconnect".to_string()));
+        assert!(manager.list_sessions().await.is_empty());
+        assert_eq!(manager.warm_adapters.read().await.len(), 1);
+        assert!(spare_commands.lock().await.is_empty());
+
+        let mut slot = manager.reserve_session_slot().await.unwrap();
+        let reused = manager
+            .reuse_warm_adapter(
+                &mut slot,
+                "go",
+                "/w/main.go",
+                "delve",
+                serde_json::json!({"program": "/w/main.go"}),
+                None,
+            )
+            .await
+            .expect("the spare adapter should be reused");
+        assert_ne!(reused, session.id);
+        assert_eq!(manager.list_sessions().await, vec![reused.clone()]);
+        let spare = manager.get_session(&reused).await.unwrap();
+        assert!(spare.reused_startup().await.is_some());
+
+        // Launched like a new session, not restarted
+        for _ in 0..50 {
+            if spare_commands.lock().await.contains(&"launch".to_string()) {
+                break;
+            }
+            tokio::time::sleep(Duration::from_millis(20)).await;
+        }
+        let spare_commands = spare_commands.lock().await.clone();
+        assert_eq!(spare_commands[..2], ["initialize", "launch"]);
+        assert!(!spare_commands.contains(&"restart".to_string()));
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_restart_in_place_keeps_launch_arguments() {
+        let (session, commands) = warm_go_session(Duration::from_secs(5)).await;
+        assert!(matches!(
+            session.restart_in_place().await,
+            Err(Error::InvalidState(_))
+        ));
+
+        let launch_args = serde_json::json!({
+            "program": "/w/main.go",
+            "substitutePath": [{"from": "/w", "to": "/src"}],
+            "buildFlags": "-tags=integration"
+        });
+        session
+            .restart(launch_args.clone(), Duration::from_millis(1500))
+            .await
+            .unwrap();
+        session.restart_in_place().await.unwrap();
+
+        assert_eq!(session.launch_arguments().await, Some(launch_args));
+        let commands = commands.lock().await.clone();
+        assert_eq!(commands, vec!["initialize", "restart", "restart"]);
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_session_limit() {
+        let (session, _commands) = warm_go_session(Duration::from_secs(5)).await;
+        let mut manager = manager_with(&session).await;
+        manager.max_sessions = Some(1);
+
+        let err = manager
+            .create_session("python", "/w/app.py".to_string(), vec![], None, false)
+            .await
+            .unwrap_err();
+        assert!(matches!(err, Error::InvalidState(_)));
+        assert!(err.to_string().contains("Session limit reached (1 active)"));
+
+        let err = manager
+            .attach_session("go", 4242, "app".to_string(), false)
+            .await
+            .unwrap_err();
+        assert!(err.to_string().contains("Session limit reached"));
+
+        // A session still starting holds its place until stored or dropped
+        manager.max_sessions = Some(2);
+        let mut starting = manager.reserve_session_slot().await.unwrap();
+        assert!(manager.reserve_session_slot().await.is_err());
+        drop(starting);
+        starting = manager.reserve_session_slot().await.unwrap();
+        let (other, _commands) = warm_go_session(Duration::from_secs(5)).await;
+        manager.store_session(&mut starting, &other).await;
+        drop(starting);
+        assert_eq!(manager.list_sessions().await.len(), 2);
+        assert!(manager.reserve_session_slot().await.is_err());
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_relaunch_takes_the_old_sessions_place() {
+        let (old, _commands) = warm_go_session(Duration::from_secs(5)).await;
+        let mut manager = manager_with(&old).await;
+        manager.max_sessions = Some(1);
+        assert!(manager.reserve_session_slot().await.is_err());
+
+        // A failed relaunch gives the place back
+        let failed = manager
+            .reserve_session_slot_replacing(Some(&old.id))
+            .await
+            .unwrap();
+        drop(failed);
+        assert!(manager.handed_over.lock().unwrap().is_empty());
+        assert!(manager.reserve_session_slot().await.is_err());
+
+        let mut slot = manager
+            .reserve_session_slot_replacing(Some(&old.id))
+            .await
+            .unwrap();
+        // The place is taken once
+        assert!(manager
+            .reserve_session_slot_replacing(Some(&old.id))
+            .await
+            .is_err());
+        let (new, _commands) = warm_go_session(Duration::from_secs(5)).await;
+        manager.store_session(&mut slot, &new).await;
+        drop(slot);
+        assert!(manager.reserve_session_slot().await.is_err());
+
+        manager.replace_session(&old.id, &new.id).await.unwrap();
+        assert_eq!(manager.list_sessions().await, vec![new.id.clone()]);
+        assert_eq!(manager.resolve_session_id(&old.id), new.id);
+        assert!(manager.handed_over.lock().unwrap().is_empty());
+        assert!(manager.reserve_session_slot().await.is_err());
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_relaunched_ids_pruned() {
+        let (first, _commands) = warm_go_session(Duration::from_secs(5)).await;
+        let (second, _commands) = warm_go_session(Duration::from_secs(5)).await;
+        let (third, _commands) = warm_go_session(Duration::from_secs(5)).await;
+        let manager = manager_with(&first).await;
+        for session in [&second, &third] {
+            manager
+                .sessions
+                .write()
+                .await
+                .insert(session.id.clone(), Arc::clone(session));
+        }
+
+        manager
+            .replace_session(&first.id, &second.id)
+            .await
+            .unwrap();
+        manager.replace_session(&first.id, &third.id).await.unwrap();
+        // Every old id leads straight to the current session
+        assert_eq!(manager.resolve_session_id(&first.id), third.id);
+        assert_eq!(manager.resolve_session_id(&second.id), third.id);
+        assert!(manager
+            .relaunched
+            .lock()
+            .unwrap()
+            .values()
+            .all(|current| *current == third.id));
+
+        manager.remove_session(&first.id).await.unwrap();
+        assert!(manager.list_sessions().await.is_empty());
+        assert!(manager.relaunched.lock().unwrap().is_empty());
+        assert!(matches!(
+            manager.get_session(&second.id).await,
+            Err(Error::SessionNotFound(_))
+        ));
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_idle_sessions_reaped() {
+        let (idle, idle_commands) = warm_go_session(Duration::from_secs(5)).await;
+        let (busy, _commands) = warm_go_session(Duration::from_secs(5)).await;
+        let manager = manager_with(&idle).await;
+        manager
+            .sessions
+            .write()
+            .await
+            .insert(busy.id.clone(), busy.clone());
+
+        // The first sweep starts tracking both sessions
+        assert!(manager
+            .reap_idle_sessions(Duration::from_millis(100))
+            .await
+            .is_empty());
+        tokio::time::sleep(Duration::from_millis(150)).await;
+        manager.get_session(&busy.id).await.unwrap();
+
+        let reaped = manager.reap_idle_sessions(Duration::from_millis(100)).await;
+        assert_eq!(reaped, vec![idle.id.clone()]);
+        assert_eq!(manager.list_sessions().await, vec![busy.id.clone()]);
+        assert!(idle_commands
+            .lock()
+            .await
+            .contains(&"disconnect".to_string()));
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_post_mortem_outlives_reaped_session() {
+        let (session, _commands) = warm_go_session(Duration::from_secs(5)).await;
+        let manager = manager_with(&session).await;
+        session
+            .state
+            .write()
+            .await
+            .record_output(Some("stderr"), "panic: boom\n");
+
+        let err = manager.get_post_mortem(&session.id).await.unwrap_err();
+        assert!(err.to_string().contains("when its program ends"), "{}", err);
+
+        manager.reap_idle_sessions(Duration::ZERO).await;
+        assert!(manager.list_sessions().await.is_empty());
+        let post_mortem = manager.get_post_mortem(&session.id).await.unwrap();
+        assert!(post_mortem.removed);
+        assert_eq!(post_mortem.program, "/w/main.go");
+        assert_eq!(post_mortem.post_mortem.output, vec!["panic: boom"]);
+
+        // Read, so the next sweep an idle timeout later drops it
+        manager.reap_idle_sessions(Duration::from_secs(60)).await;
+        assert!(manager.get_post_mortem(&session.id).await.is_ok());
+        manager.reap_idle_sessions(Duration::ZERO).await;
+        assert!(matches!(
+            manager.get_post_mortem(&session.id).await,
+            Err(Error::SessionNotFound(_))
+        ));
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_reuse_only_matches_same_program() {
+        let (session, _commands) = warm_go_session(Duration::from_secs(5)).await;
+        let manager = manager_with(&session).await;
+        manager.park_session(&session.id).await.unwrap();
+
+        let mut slot = manager.reserve_session_slot().await.unwrap();
+        let reused = manager
+            .reuse_warm_adapter(
+                &mut slot,
+                "go",
+                "/w/other.go",
+                "delve",
+                serde_json::json!({}),
+                None,
+            )
+            .await;
+        assert!(reused.is_none());
+        assert_eq!(manager.warm_adapters.read().await.len(), 1);
+    }
 }
@@ -1,9 +1,46 @@
+pub mod breakpoint_io;
+pub mod breakpoint_move;
+pub mod caller_filter;
+pub mod change_watch;
+pub mod compare;
+pub mod continue_past;
+pub mod crash;
+pub mod disassembly;
+pub mod dump;
+pub mod event_log;
+pub mod file_watch;
+pub mod group;
+pub mod hit_stats;
+pub mod inline_values;
+pub mod launch_config;
+pub mod launch_json;
+pub mod log_points;
 pub mod manager;
 pub mod multi_session;
+pub mod output_log;
+pub mod path_case;
+pub mod peek;
+pub mod post_mortem;
+pub mod repl;
+pub mod resource_usage;
+pub mod return_values;
 pub mod session;
+pub mod settings;
+pub mod shared_line;
+pub mod source;
+pub mod source_check;
+pub mod stack;
 pub mod state;
+pub mod step_filter;
+pub mod subscription;
+pub mod thread_eval;
+pub mod transcript;
+pub mod webhook;
 
 pub use manager::SessionManager;
 pub use multi_session::{ChildSession, MultiSessionManager};
-pub use session::{DebugSession, SessionMode};
+pub use session::{
+    BreakpointWaitEnd, DebugSession, FileStepEnd, FileStepOutcome, FilteredStepOutcome,
+    LineStepEnd, LineStepOutcome, SessionMode, WarmAdapterConfig,
+};
 pub use state::{DebugState, SessionState};
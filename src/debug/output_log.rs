@@ -0,0 +1,144 @@
+//! Program output, kept as bytes
+//!
+//! `debugger_get_output` returns what the program wrote to stdout and
+//! stderr, up to the last `MAX_OUTPUT_BYTES`. The log keeps the bytes of
+//! the output events and decodes them only when asked: as UTF-8 text by
+//! default, with U+FFFD for anything that isn't (reported as
+//! `encodingLossy`), or as base64 of the exact bytes kept.
+//!
+//! Output reaches the server as DAP `output` events, whose text is JSON;
+//! bytes the debugger couldn't put in a JSON string arrive already replaced
+//! (see `dap::encoding`). The kept bytes are that UTF-8 text, not what the
+//! program wrote: base64 of output that wasn't UTF-8 holds U+FFFD where the
+//! program's bytes were, and they can't be recovered.
+
+use crate::dap::encoding;
+use std::collections::VecDeque;
+
+/// Bytes of output a session keeps
+pub const MAX_OUTPUT_BYTES: usize = 1024 * 1024;
+
+/// The last `MAX_OUTPUT_BYTES` of a program's output
+#[derive(Debug, Clone, Default)]
+pub struct OutputLog {
+    bytes: VecDeque<u8>,
+    /// Bytes dropped from the front to stay in bounds
+    dropped: u64,
+}
+
+impl OutputLog {
+    pub fn push(&mut self, bytes: &[u8]) {
+        self.bytes.extend(bytes);
+        let excess = self.bytes.len().saturating_sub(MAX_OUTPUT_BYTES);
+        if excess > 0 {
+            self.bytes.drain(..excess);
+            self.dropped += excess as u64;
+            // Don't start in the middle of a character
+            while self.bytes.front().is_some_and(|b| b & 0xC0 == 0x80) {
+                self.bytes.pop_front();
+                self.dropped += 1;
+            }
+        }
+    }
+
+    /// The kept bytes, oldest first
+    pub fn bytes(&self) -> Vec<u8> {
+        self.bytes.iter().copied().collect()
+    }
+
+    /// The last `max_bytes` kept bytes at most, starting at a character
+    pub fn tail(&self, max_bytes: usize) -> Vec<u8> {
+        let mut start = self.bytes.len().saturating_sub(max_bytes);
+        while self.bytes.get(start).is_some_and(|b| b & 0xC0 == 0x80) {
+            start += 1;
+        }
+        self.bytes.range(start..).copied().collect()
+    }
+
+    /// The kept output as text, and whether anything in it was replaced
+    pub fn text(&self) -> (String, bool) {
+        decode(&self.bytes())
+    }
+
+    pub fn dropped(&self) -> u64 {
+        self.dropped
+    }
+
+    pub fn clear(&mut self) {
+        self.bytes.clear();
+        self.dropped = 0;
+    }
+}
+
+/// Output bytes as text, and whether anything in it was replaced
+pub fn decode(bytes: &[u8]) -> (String, bool) {
+    let text = String::from_utf8_lossy(bytes).into_owned();
+    let lossy = encoding::is_lossy(&text);
+    (text, lossy)
+}
+
+const BASE64_ALPHABET: &[u8; 64] =
+    b"ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/";
+
+/// Standard base64 (with padding) of `bytes`
+pub fn base64(bytes: &[u8]) -> String {
+    let mut out = String::with_capacity(bytes.len().div_ceil(3) * 4);
+    for chunk in bytes.chunks(3) {
+        let n = chunk
+            .iter()
+            .enumerate()
+            .fold(0u32, |n, (i, b)| n | ((*b as u32) << (16 - 8 * i)));
+        for i in 0..4 {
+            if i <= chunk.len() {
+                out.push(BASE64_ALPHABET[((n >> (18 - 6 * i)) & 0x3F) as usize] as char);
+            } else {
+                out.push('=');
+            }
+        }
+    }
+    out
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_base64() {
+        for (bytes, expected) in [
+            (&b""[..], ""),
+            (b"f", "Zg=="),
+            (b"fo", "Zm8="),
+            (b"foo", "Zm9v"),
+            (b"foob", "Zm9vYg=="),
+            (&[0xFF, 0xFE, 0x00][..], "//4A"),
+        ] {
+            assert_eq!(base64(bytes), expected);
+        }
+    }
+
+    #[test]
+    fn test_log_is_bounded_at_character_boundaries() {
+        let mut log = OutputLog::default();
+        log.push("hello\n".as_bytes());
+        assert_eq!(log.text(), ("hello\n".to_string(), false));
+
+        log.push("é".repeat(MAX_OUTPUT_BYTES / 2).as_bytes());
+        log.push(b"!");
+        let (text, lossy) = log.text();
+        assert!(!lossy);
+        assert!(text.starts_with('é') && text.ends_with('!'));
+        assert_eq!(log.dropped(), 6 + 2);
+        assert_eq!(log.bytes().len(), MAX_OUTPUT_BYTES - 1);
+
+        assert_eq!(log.tail(4), "é!".as_bytes());
+        assert_eq!(log.tail(3), "é!".as_bytes());
+        assert_eq!(log.tail(2), b"!");
+
+        let mut log = OutputLog::default();
+        log.push("caf\u{FFFD}".as_bytes());
+        assert!(log.text().1);
+        log.clear();
+        assert_eq!(log.text(), (String::new(), false));
+    }
+}
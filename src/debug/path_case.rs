@@ -0,0 +1,183 @@
+//! On-disk casing of breakpoint paths
+//!
+//! A case-insensitive volume mounted into a Linux container (a macOS host
+//! directory, say) opens `Main.go` just fine even though the file is called
+//! `main.go`. The adapter reports the on-disk name, so a breakpoint set under
+//! the caller's casing never matches and is silently left unverified.
+//! Paths are therefore resolved component by component against directory
+//! listings before breakpoints are sent. On a case-sensitive volume the
+//! caller's casing simply doesn't exist; that is reported as `PathNotFound`
+//! with the file whose name differs only in case, if there is one.
+
+use crate::{Error, Result};
+use std::ffi::{OsStr, OsString};
+use std::path::{Component, Path, PathBuf};
+
+/// A path with each component in its on-disk casing
+#[derive(Debug, Clone, PartialEq)]
+pub struct PathCase {
+    pub path: PathBuf,
+    /// Some component was spelled differently on disk
+    pub corrected: bool,
+}
+
+/// How a path component compares to its directory's entries
+#[derive(Debug, PartialEq)]
+enum Lookup {
+    /// Listed as given, or the directory can't be listed
+    AsGiven,
+    /// Exists, but is listed with another casing
+    Differs(OsString),
+    /// Doesn't exist; the entry differing only in case, if any
+    Missing(Option<OsString>),
+}
+
+fn same_ignoring_case(a: &OsStr, b: &OsStr) -> bool {
+    a.to_string_lossy().to_lowercase() == b.to_string_lossy().to_lowercase()
+}
+
+/// Compare a component against the entries of its directory
+///
+/// `exists` tells whether the filesystem opens the component as given; on a
+/// case-insensitive volume it does even when the listing spells it otherwise.
+fn lookup(entries: &[OsString], name: &OsStr, exists: bool) -> Lookup {
+    if entries.iter().any(|entry| entry == name) {
+        return Lookup::AsGiven;
+    }
+    let other_case = entries
+        .iter()
+        .find(|entry| same_ignoring_case(entry, name))
+        .cloned();
+    match (other_case, exists) {
+        (Some(actual), true) => Lookup::Differs(actual),
+        (_, true) => Lookup::AsGiven,
+        (candidate, false) => Lookup::Missing(candidate),
+    }
+}
+
+fn list(dir: &Path) -> Option<Vec<OsString>> {
+    let dir = if dir.as_os_str().is_empty() {
+        Path::new(".")
+    } else {
+        dir
+    };
+    let entries = std::fs::read_dir(dir).ok()?;
+    Some(
+        entries
+            .filter_map(|e| e.ok())
+            .map(|e| e.file_name())
+            .collect(),
+    )
+}
+
+/// Resolve `path` to its on-disk casing
+///
+/// Fails with `PathNotFound` if the path doesn't exist; its candidate is the
+/// path with the first mismatching component replaced by the entry that
+/// differs only in case (and the rest resolved the same way, where possible).
+pub fn resolve(path: &Path) -> Result<PathCase> {
+    let mut resolved = PathBuf::new();
+    let mut corrected = false;
+    let components: Vec<Component> = path.components().collect();
+
+    for (i, component) in components.iter().enumerate() {
+        let Component::Normal(name) = component else {
+            resolved.push(component);
+            continue;
+        };
+        let exists = resolved.join(name).symlink_metadata().is_ok();
+        let step = match list(&resolved) {
+            Some(entries) => lookup(&entries, name, exists),
+            None if exists => Lookup::AsGiven,
+            None => Lookup::Missing(None),
+        };
+        match step {
+            Lookup::AsGiven => resolved.push(name),
+            Lookup::Differs(actual) => {
+                resolved.push(actual);
+                corrected = true;
+            }
+            Lookup::Missing(candidate) => {
+                let candidate = candidate.map(|actual| {
+                    let mut candidate = resolved.join(actual);
+                    candidate.extend(&components[i + 1..]);
+                    match resolve(&candidate) {
+                        Ok(resolved) => resolved.path,
+                        Err(Error::PathNotFound {
+                            candidate: Some(deeper),
+                            ..
+                        }) => PathBuf::from(deeper),
+                        Err(_) => candidate,
+                    }
+                });
+                return Err(Error::PathNotFound {
+                    path: path.display().to_string(),
+                    candidate: candidate.map(|c| c.display().to_string()),
+                });
+            }
+        }
+    }
+
+    Ok(PathCase {
+        path: resolved,
+        corrected,
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn names(names: &[&str]) -> Vec<OsString> {
+        names.iter().map(OsString::from).collect()
+    }
+
+    #[test]
+    fn test_lookup_by_listing() {
+        let entries = names(&["main.go", "Makefile", "go.mod"]);
+        let name = OsStr::new;
+
+        assert_eq!(lookup(&entries, name("main.go"), true), Lookup::AsGiven);
+        // Case-insensitive volume: opens, but is listed as main.go
+        assert_eq!(
+            lookup(&entries, name("Main.go"), true),
+            Lookup::Differs(OsString::from("main.go"))
+        );
+        // Case-sensitive volume: doesn't open
+        assert_eq!(
+            lookup(&entries, name("MAIN.GO"), false),
+            Lookup::Missing(Some(OsString::from("main.go")))
+        );
+        assert_eq!(
+            lookup(&entries, name("util.go"), false),
+            Lookup::Missing(None)
+        );
+    }
+
+    #[test]
+    fn test_resolve_reports_closest_case_candidate() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::create_dir(dir.path().join("cmd")).unwrap();
+        std::fs::write(dir.path().join("cmd/main.go"), "package main\n").unwrap();
+
+        let exact = resolve(&dir.path().join("cmd/main.go")).unwrap();
+        assert_eq!(exact.path, dir.path().join("cmd/main.go"));
+        assert!(!exact.corrected);
+
+        // The sandbox volume is case-sensitive, so the wrong casing is missing
+        match resolve(&dir.path().join("Cmd/Main.go")) {
+            Err(Error::PathNotFound { candidate, .. }) => assert_eq!(
+                candidate.as_deref(),
+                dir.path().join("cmd/main.go").to_str()
+            ),
+            other => panic!("expected PathNotFound, got {:?}", other),
+        }
+        assert!(matches!(
+            resolve(&dir.path().join("cmd/other.go")),
+            Err(Error::PathNotFound {
+                candidate: None,
+                ..
+            })
+        ));
+    }
+}
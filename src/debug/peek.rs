@@ -0,0 +1,571 @@
+//! Quick values by name
+//!
+//! `debugger_peek` is the cheapest way to look at a few values: plain names
+//! (`total`, `user.address.city`) are looked up in the frame's scopes and
+//! their children with `scopes` and `variables` requests only. Nothing is
+//! evaluated, so peeking can't run program code, and each value comes back
+//! as a short string without type or reference.
+//!
+//! Names may also pick elements and entries the way the languages write
+//! them: `items[3]`, `items[-1]`, a slice `items[10:20]` or `items[-10:]`
+//! (last segment only, Python-style bounds) and a map key `m["key"]`. Where
+//! the adapter reports how many elements a value has (`indexedVariables`)
+//! only the wanted ones are fetched, in a page (`start`/`count`); otherwise
+//! the children are listed and matched by the names each language's debugger
+//! gives elements and keys (see `adapters::element_index`). Slice bounds out
+//! of range and slices longer than `MAX_SLICE_LEN` are clamped, and the
+//! clamp reported, rather than failing.
+//!
+//! Lookups are done in rounds: the session fetches the variables every name
+//! still needs (`needed_fetches`), then walks the names again, until every
+//! name is found or known to be missing.
+
+use crate::adapters::{element_index, map_key_names};
+use crate::dap::types::Variable;
+use crate::{Error, Result};
+use serde::Serialize;
+use std::collections::{BTreeMap, HashMap};
+
+/// Most names one peek accepts
+pub const MAX_NAMES: usize = 20;
+
+/// Values longer than this many characters are cut
+pub const MAX_VALUE_LEN: usize = 120;
+
+/// Most elements one slice returns
+pub const MAX_SLICE_LEN: i64 = 100;
+
+/// Values of the peeked names; names that weren't found map to None and are
+/// listed in `missing` as well. Slices are also given element by element in
+/// `slices`.
+#[derive(Debug, Clone, PartialEq, Serialize)]
+pub struct Peek {
+    pub values: BTreeMap<String, Option<String>>,
+    pub missing: Vec<String>,
+    #[serde(skip_serializing_if = "BTreeMap::is_empty")]
+    pub slices: BTreeMap<String, PeekSlice>,
+}
+
+/// Elements `start..end` of a value with `length` elements
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct PeekSlice {
+    pub start: i64,
+    pub end: i64,
+    pub length: i64,
+    pub values: Vec<String>,
+    /// Whether the bounds asked for were out of range or too far apart
+    #[serde(skip_serializing_if = "std::ops::Not::not")]
+    pub clamped: bool,
+}
+
+/// One step of a name's path
+#[derive(Debug, Clone, PartialEq)]
+pub enum Segment {
+    /// A variable (first segment) or field
+    Field(String),
+    /// An element; negative counts from the end
+    Index(i64),
+    /// Elements from..to, Python-style; only as the last segment
+    Slice(Option<i64>, Option<i64>),
+    /// A map, dict or hash entry
+    Key(String),
+}
+
+/// Children to fetch: all of a reference's, or a page of its elements
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash)]
+pub struct Fetch {
+    pub reference: i32,
+    /// `(start, count)` of the indexed children
+    pub page: Option<(i64, i64)>,
+}
+
+impl Fetch {
+    pub fn all(reference: i32) -> Self {
+        Self {
+            reference,
+            page: None,
+        }
+    }
+}
+
+/// Where walking a name's path got to
+#[derive(Debug)]
+enum Walk<'a> {
+    Found(&'a Variable),
+    Slice(PeekSlice),
+    Missing,
+    /// These children have to be fetched first
+    Needs(Fetch),
+}
+
+/// Split a name into its path: `user.items[2]` is `user`, then its field
+/// `items`, then element 2
+pub fn parse_name(name: &str) -> Result<Vec<Segment>> {
+    let invalid = || {
+        Error::InvalidRequest(format!(
+            "'{}' is not a variable, field, element (a[0]), slice (a[1:5]) or key (m[\"k\"]) name; use debugger_evaluate for expressions",
+            name
+        ))
+    };
+    let plain_len = |s: &str| {
+        s.find(|c: char| !(c.is_alphanumeric() || matches!(c, '_' | '$' | '@')))
+            .unwrap_or(s.len())
+    };
+
+    let mut rest = name.trim();
+    let len = plain_len(rest);
+    if len == 0 {
+        return Err(invalid());
+    }
+    let mut path = vec![Segment::Field(rest[..len].to_string())];
+    rest = &rest[len..];
+    while !rest.is_empty() {
+        if matches!(path.last(), Some(Segment::Slice(..))) {
+            return Err(invalid());
+        }
+        if let Some(after) = rest.strip_prefix('.') {
+            let len = plain_len(after);
+            if len == 0 {
+                return Err(invalid());
+            }
+            path.push(Segment::Field(after[..len].to_string()));
+            rest = &after[len..];
+        } else if let Some(after) = rest.strip_prefix('[') {
+            let (segment, after) = parse_bracket(after).ok_or_else(invalid)?;
+            path.push(segment);
+            rest = after;
+        } else {
+            return Err(invalid());
+        }
+    }
+    Ok(path)
+}
+
+/// The segment inside `[...]` and what follows the `]`
+fn parse_bracket(s: &str) -> Option<(Segment, &str)> {
+    if let Some(quote) = s.chars().next().filter(|c| matches!(c, '"' | '\'')) {
+        let key_len = s[1..].find(quote)?;
+        let after = s[1 + key_len + 1..].strip_prefix(']')?;
+        return Some((Segment::Key(s[1..1 + key_len].to_string()), after));
+    }
+    let close = s.find(']')?;
+    let inner = s[..close].trim();
+    let bound = |b: &str| -> Option<Option<i64>> {
+        let b = b.trim();
+        if b.is_empty() {
+            Some(None)
+        } else {
+            b.parse().ok().map(Some)
+        }
+    };
+    let segment = match inner.split_once(':') {
+        Some((from, to)) => Segment::Slice(bound(from)?, bound(to)?),
+        None => Segment::Index(inner.parse().ok()?),
+    };
+    Some((segment, &s[close + 1..]))
+}
+
+/// Check the names of one peek: 1 to `MAX_NAMES`, all valid paths
+pub fn validate_names(names: &[String]) -> Result<()> {
+    if names.is_empty() || names.len() > MAX_NAMES {
+        return Err(Error::InvalidRequest(format!(
+            "Peek takes 1 to {} names, got {}",
+            MAX_NAMES,
+            names.len()
+        )));
+    }
+    for name in names {
+        parse_name(name)?;
+    }
+    Ok(())
+}
+
+/// Python-style slice bounds for `length` elements, clamped to the elements
+/// there are and to `MAX_SLICE_LEN`; and whether anything was clamped
+fn slice_bounds(from: Option<i64>, to: Option<i64>, length: i64) -> (i64, i64, bool) {
+    let mut clamped = false;
+    let mut bound = |b: Option<i64>, default: i64| match b {
+        None => default,
+        Some(b) => {
+            let resolved = if b < 0 { b + length } else { b };
+            let bounded = resolved.clamp(0, length);
+            clamped |= bounded != resolved;
+            bounded
+        }
+    };
+    let start = bound(from, 0);
+    let mut end = bound(to, length).max(start);
+    if end - start > MAX_SLICE_LEN {
+        end = start + MAX_SLICE_LEN;
+        clamped = true;
+    }
+    (start, end, clamped)
+}
+
+/// The element children of `children`, by index
+fn elements<'a>(language: &str, children: &'a [Variable]) -> Vec<(i64, &'a Variable)> {
+    children
+        .iter()
+        .filter_map(|v| element_index(language, &v.name).map(|index| (index, v)))
+        .collect()
+}
+
+/// Follow a path from the scopes (innermost first) through fetched children
+fn walk<'a>(
+    path: &[Segment],
+    language: &str,
+    scopes: &'a [Vec<Variable>],
+    children: &'a HashMap<Fetch, Vec<Variable>>,
+) -> Walk<'a> {
+    let Some(Segment::Field(root)) = path.first() else {
+        return Walk::Missing;
+    };
+    let Some(mut variable) = scopes
+        .iter()
+        .find_map(|variables| variables.iter().find(|v| v.name == *root))
+    else {
+        return Walk::Missing;
+    };
+    for segment in &path[1..] {
+        if variable.variables_reference <= 0 {
+            return Walk::Missing;
+        }
+        let reference = variable.variables_reference;
+        let all = Fetch::all(reference);
+        let found = match (segment, variable.indexed_variables) {
+            (Segment::Field(name), _) => {
+                let Some(fields) = children.get(&all) else {
+                    return Walk::Needs(all);
+                };
+                fields.iter().find(|v| v.name == *name)
+            }
+            (Segment::Key(key), _) => {
+                let Some(fields) = children.get(&all) else {
+                    return Walk::Needs(all);
+                };
+                let names = map_key_names(language, key);
+                fields.iter().find(|v| names.contains(&v.name))
+            }
+            (Segment::Index(index), Some(length)) => {
+                let index = if *index < 0 { index + length } else { *index };
+                if !(0..length).contains(&index) {
+                    return Walk::Missing;
+                }
+                let page = Fetch {
+                    reference,
+                    page: Some((index, 1)),
+                };
+                let Some(elements) = children.get(&page) else {
+                    return Walk::Needs(page);
+                };
+                elements.first()
+            }
+            (Segment::Index(index), None) => {
+                let Some(fields) = children.get(&all) else {
+                    return Walk::Needs(all);
+                };
+                let elements = elements(language, fields);
+                let length = elements.len() as i64;
+                let index = if *index < 0 { index + length } else { *index };
+                elements
+                    .into_iter()
+                    .find(|(i, _)| *i == index)
+                    .map(|(_, v)| v)
+            }
+            (Segment::Slice(from, to), Some(length)) => {
+                let (start, end, clamped) = slice_bounds(*from, *to, length);
+                let values = if start == end {
+                    Vec::new()
+                } else {
+                    let page = Fetch {
+                        reference,
+                        page: Some((start, end - start)),
+                    };
+                    let Some(elements) = children.get(&page) else {
+                        return Walk::Needs(page);
+                    };
+                    elements.iter().map(|v| short_value(&v.value)).collect()
+                };
+                return Walk::Slice(PeekSlice {
+                    start,
+                    end,
+                    length,
+                    values,
+                    clamped,
+                });
+            }
+            (Segment::Slice(from, to), None) => {
+                let Some(fields) = children.get(&all) else {
+                    return Walk::Needs(all);
+                };
+                let elements = elements(language, fields);
+                let length = elements.len() as i64;
+                let (start, end, clamped) = slice_bounds(*from, *to, length);
+                return Walk::Slice(PeekSlice {
+                    start,
+                    end,
+                    length,
+                    values: elements
+                        .into_iter()
+                        .filter(|(i, _)| (start..end).contains(i))
+                        .map(|(_, v)| short_value(&v.value))
+                        .collect(),
+                    clamped,
+                });
+            }
+        };
+        match found {
+            Some(child) => variable = child,
+            None => return Walk::Missing,
+        }
+    }
+    Walk::Found(variable)
+}
+
+/// Children to fetch before the names can be resolved
+pub fn needed_fetches(
+    names: &[String],
+    language: &str,
+    scopes: &[Vec<Variable>],
+    children: &HashMap<Fetch, Vec<Variable>>,
+) -> Vec<Fetch> {
+    let mut needed = Vec::new();
+    for name in names {
+        let Ok(path) = parse_name(name) else {
+            continue;
+        };
+        if let Walk::Needs(fetch) = walk(&path, language, scopes, children) {
+            if !needed.contains(&fetch) {
+                needed.push(fetch);
+            }
+        }
+    }
+    needed
+}
+
+/// Resolve every name with what has been fetched; a name whose children
+/// weren't fetched counts as missing
+pub fn collect(
+    names: &[String],
+    language: &str,
+    scopes: &[Vec<Variable>],
+    children: &HashMap<Fetch, Vec<Variable>>,
+) -> Peek {
+    let mut peek = Peek {
+        values: BTreeMap::new(),
+        missing: Vec::new(),
+        slices: BTreeMap::new(),
+    };
+    for name in names {
+        let walked = parse_name(name).map(|path| walk(&path, language, scopes, children));
+        let found = match walked {
+            Ok(Walk::Found(variable)) => Some(short_value(&variable.value)),
+            Ok(Walk::Slice(slice)) => {
+                let value = short_value(&format!("[{}]", slice.values.join(", ")));
+                peek.slices.insert(name.clone(), slice);
+                Some(value)
+            }
+            Ok(Walk::Missing | Walk::Needs(_)) | Err(_) => None,
+        };
+        if found.is_none() && !peek.missing.contains(name) {
+            peek.missing.push(name.clone());
+        }
+        peek.values.insert(name.clone(), found);
+    }
+    peek
+}
+
+/// The variable a name picks, once its children are fetched; None for
+/// slices and names that weren't found
+pub fn lookup(
+    name: &str,
+    language: &str,
+    scopes: &[Vec<Variable>],
+    children: &HashMap<Fetch, Vec<Variable>>,
+) -> Option<Variable> {
+    match walk(&parse_name(name).ok()?, language, scopes, children) {
+        Walk::Found(variable) => Some(variable.clone()),
+        _ => None,
+    }
+}
+
+/// A value redacted and cut to `MAX_VALUE_LEN` characters
+pub fn short_value(value: &str) -> String {
+    let value = crate::config::redact(value);
+    match value.char_indices().nth(MAX_VALUE_LEN) {
+        Some((cut, _)) => format!("{}...", &value[..cut]),
+        None => value,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn var(name: &str, value: &str, reference: i32) -> Variable {
+        Variable {
+            name: name.to_string(),
+            value: value.to_string(),
+            type_: Some("T".to_string()),
+            variables_reference: reference,
+            indexed_variables: None,
+            encoding_lossy: false,
+        }
+    }
+
+    fn field(name: &str) -> Segment {
+        Segment::Field(name.to_string())
+    }
+
+    fn names(names: &[&str]) -> Vec<String> {
+        names.iter().map(|n| n.to_string()).collect()
+    }
+
+    #[test]
+    fn test_names_must_be_plain() {
+        assert_eq!(
+            parse_name("user.address").unwrap(),
+            vec![field("user"), field("address")]
+        );
+        assert_eq!(parse_name("@count").unwrap(), vec![field("@count")]);
+        assert_eq!(
+            parse_name("m[\"a.b]\"][-1].items[:5]").unwrap(),
+            vec![
+                field("m"),
+                Segment::Key("a.b]".to_string()),
+                Segment::Index(-1),
+                field("items"),
+                Segment::Slice(None, Some(5)),
+            ]
+        );
+        assert_eq!(
+            parse_name("a[-10:]").unwrap(),
+            vec![field("a"), Segment::Slice(Some(-10), None)]
+        );
+        for bad in [
+            "f()", "a[", "a[x]", "a[1:2].b", "a['k'", "x + 1", "user.", "",
+        ] {
+            assert!(parse_name(bad).is_err(), "{:?}", bad);
+        }
+        assert!(validate_names(&names(&["a", "b.c"])).is_ok());
+        assert!(validate_names(&[]).is_err());
+        assert!(validate_names(&vec!["x".to_string(); MAX_NAMES + 1]).is_err());
+    }
+
+    #[test]
+    fn test_resolve_in_rounds() {
+        let scopes = vec![
+            vec![var("n", "5", 0), var("user", "User{...}", 10)],
+            vec![var("n", "99", 0), var("config", "{...}", 20)],
+        ];
+        let wanted = names(&[
+            "n",
+            "user.address.city",
+            "user.age",
+            "config",
+            "nope",
+            "n.x",
+        ]);
+
+        let mut children = HashMap::new();
+        let needed = |children: &HashMap<Fetch, Vec<Variable>>| {
+            needed_fetches(&wanted, "go", &scopes, children)
+                .into_iter()
+                .map(|fetch| fetch.reference)
+                .collect::<Vec<_>>()
+        };
+        assert_eq!(needed(&children), vec![10]);
+        children.insert(
+            Fetch::all(10),
+            vec![var("address", "{...}", 11), var("age", "42", 0)],
+        );
+        assert_eq!(needed(&children), vec![11]);
+        children.insert(Fetch::all(11), vec![var("city", "\"Berlin\"", 0)]);
+        assert!(needed(&children).is_empty());
+
+        let peek = collect(&wanted, "go", &scopes, &children);
+        // The innermost scope wins
+        assert_eq!(peek.values["n"].as_deref(), Some("5"));
+        assert_eq!(
+            peek.values["user.address.city"].as_deref(),
+            Some("\"Berlin\"")
+        );
+        assert_eq!(peek.values["user.age"].as_deref(), Some("42"));
+        assert_eq!(peek.values["config"].as_deref(), Some("{...}"));
+        assert_eq!(peek.values["nope"], None);
+        assert_eq!(peek.missing, vec!["nope", "n.x"]);
+        assert!(peek.slices.is_empty());
+    }
+
+    #[test]
+    fn test_elements_are_fetched_in_pages() {
+        let mut items = var("items", "[]int len: 1000", 10);
+        items.indexed_variables = Some(1000);
+        let scopes = vec![vec![items]];
+        let wanted = names(&["items[-10:]", "items[-1]", "items[990:5000]", "items[7:3]"]);
+
+        let mut children = HashMap::new();
+        let needed = needed_fetches(&wanted, "go", &scopes, &children);
+        let page = |start, count| Fetch {
+            reference: 10,
+            page: Some((start, count)),
+        };
+        assert_eq!(needed, vec![page(990, 10), page(999, 1)]);
+        let elements: Vec<Variable> = (990..1000)
+            .map(|i| var(&format!("[{}]", i), &i.to_string(), 0))
+            .collect();
+        children.insert(page(990, 10), elements);
+        children.insert(page(999, 1), vec![var("[999]", "999", 0)]);
+        assert!(needed_fetches(&wanted, "go", &scopes, &children).is_empty());
+
+        let peek = collect(&wanted, "go", &scopes, &children);
+        assert_eq!(peek.values["items[-1]"].as_deref(), Some("999"));
+        let last = &peek.slices["items[-10:]"];
+        assert_eq!((last.start, last.end, last.length), (990, 1000, 1000));
+        assert_eq!(last.values.len(), 10);
+        assert!(!last.clamped);
+        assert_eq!(
+            peek.values["items[-10:]"].as_deref(),
+            Some("[990, 991, 992, 993, 994, 995, 996, 997, 998, 999]")
+        );
+        // Out of range bounds are clamped, and the clamp reported
+        assert!(peek.slices["items[990:5000]"].clamped);
+        assert_eq!(peek.slices["items[990:5000]"].end, 1000);
+        assert!(peek.slices["items[7:3]"].values.is_empty());
+        assert!(peek.missing.is_empty());
+    }
+
+    #[test]
+    fn test_elements_and_keys_match_the_languages_names() {
+        let scopes = vec![vec![var("xs", "[...]", 10), var("m", "{...}", 20)]];
+        let mut children = HashMap::new();
+        children.insert(
+            Fetch::all(10),
+            vec![var("0", "'a'", 0), var("1", "'b'", 0), var("len()", "2", 0)],
+        );
+        children.insert(
+            Fetch::all(20),
+            vec![var("'name'", "'Ada'", 0), var("2", "'two'", 0)],
+        );
+        let wanted = names(&["xs[1]", "xs[-2]", "xs[0:]", "m[\"name\"]", "m['nope']"]);
+        let peek = collect(&wanted, "python", &scopes, &children);
+        assert_eq!(peek.values["xs[1]"].as_deref(), Some("'b'"));
+        assert_eq!(peek.values["xs[-2]"].as_deref(), Some("'a'"));
+        assert_eq!(peek.slices["xs[0:]"].values, vec!["'a'", "'b'"]);
+        assert_eq!(peek.values["m[\"name\"]"].as_deref(), Some("'Ada'"));
+        assert_eq!(peek.missing, vec!["m['nope']"]);
+
+        // A slice never returns more than MAX_SLICE_LEN elements
+        assert_eq!(slice_bounds(None, None, 500), (0, MAX_SLICE_LEN, true));
+        assert_eq!(slice_bounds(Some(-3), None, 2), (0, 2, true));
+    }
+
+    #[test]
+    fn test_values_are_short() {
+        let long = "x".repeat(MAX_VALUE_LEN * 3);
+        let value = short_value(&long);
+        assert_eq!(value.chars().count(), MAX_VALUE_LEN + "...".len());
+        assert_eq!(short_value("7"), "7");
+    }
+}
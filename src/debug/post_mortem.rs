@@ -0,0 +1,185 @@
+//! What is left of a session after its program ended
+//!
+//! When the session reaches a terminal state (Terminated or Failed) a
+//! `PostMortem` is taken from its state: the exit code, how long the program
+//! ran, the exception and stack of the crash report if the program stopped
+//! on the exception or panic it died of (see `crash`), and the last lines
+//! of its output. Exit codes arrive with the `exited` event, which may come
+//! after the termination; they are filled in then.
+//!
+//! Disconnecting removes the session and its adapter, and the idle reaper
+//! does the same to sessions nobody uses. Their post-mortems move to the
+//! session manager's `PostMortems` so `debugger_get_post_mortem` can still
+//! read them: an unread one is kept until it is read (or pushed out by
+//! `MAX_POST_MORTEMS` newer ones), a read one until a sweep of the reaper
+//! finds it last read an idle timeout ago.
+
+use super::crash::{CrashFrame, CrashReport};
+use super::hit_stats::ExitDiagnosis;
+use super::state::CapturedLocal;
+use crate::dap::types::ExceptionInfo;
+use serde::Serialize;
+use std::collections::VecDeque;
+use std::time::{Duration, Instant};
+
+/// Post-mortems of removed sessions kept; the oldest go first
+pub const MAX_POST_MORTEMS: usize = 32;
+
+/// The end of a session's program, as taken at its termination
+#[derive(Debug, Clone, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct PostMortem {
+    /// Milliseconds since the Unix epoch
+    pub taken_at_ms: u64,
+    /// Why the session failed (Failed); None for a termination
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub error: Option<String>,
+    /// None until (or unless) the adapter reports one
+    pub exit_code: Option<i64>,
+    /// From the last launch to the termination
+    pub runtime_ms: u64,
+    /// The fatal exception or panic, if the program stopped on it
+    pub exception: Option<ExceptionInfo>,
+    /// Thread and innermost frames of the crash, with source snippets
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub thread_id: Option<i32>,
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub stack: Vec<CrashFrame>,
+    /// Innermost frame of the crash that isn't library code, and its locals
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub user_frame: Option<String>,
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub locals: Vec<CapturedLocal>,
+    /// Last lines of stdout and stderr, redacted
+    pub output: Vec<String>,
+    /// Why no breakpoint was hit (see `hit_stats`)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub exit_diagnosis: Option<ExitDiagnosis>,
+}
+
+impl PostMortem {
+    /// A post-mortem of a program whose last lines of output were `output`
+    pub fn new(
+        taken_at_ms: u64,
+        error: Option<String>,
+        exit_code: Option<i64>,
+        runtime_ms: u64,
+        crash: Option<&CrashReport>,
+        output: Vec<String>,
+    ) -> Self {
+        Self {
+            taken_at_ms,
+            error,
+            exit_code,
+            runtime_ms,
+            exception: crash.map(|report| report.exception.clone()),
+            thread_id: crash.map(|report| report.thread_id),
+            stack: crash
+                .map(|report| report.frames.clone())
+                .unwrap_or_default(),
+            user_frame: crash.and_then(|report| report.user_frame.clone()),
+            locals: crash
+                .map(|report| report.locals.clone())
+                .unwrap_or_default(),
+            output,
+            exit_diagnosis: None,
+        }
+    }
+}
+
+/// A session's post-mortem, with the session it is of
+#[derive(Debug, Clone, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SessionPostMortem {
+    pub session_id: String,
+    pub language: String,
+    pub program: String,
+    /// The session was removed; its adapter is gone
+    pub removed: bool,
+    #[serde(flatten)]
+    pub post_mortem: PostMortem,
+}
+
+/// Post-mortems of removed sessions, oldest first
+#[derive(Debug, Default)]
+pub struct PostMortems {
+    entries: VecDeque<(SessionPostMortem, Option<Instant>)>,
+}
+
+impl PostMortems {
+    /// Keep the post-mortem of a removed session, replacing an earlier one
+    /// of the same id
+    pub fn store(&mut self, mut post_mortem: SessionPostMortem) {
+        post_mortem.removed = true;
+        self.entries
+            .retain(|(kept, _)| kept.session_id != post_mortem.session_id);
+        if self.entries.len() == MAX_POST_MORTEMS {
+            self.entries.pop_front();
+        }
+        self.entries.push_back((post_mortem, None));
+    }
+
+    /// The post-mortem of session `session_id`, which counts as read now
+    pub fn read(&mut self, session_id: &str) -> Option<SessionPostMortem> {
+        let (post_mortem, read_at) = self
+            .entries
+            .iter_mut()
+            .find(|(kept, _)| kept.session_id == session_id)?;
+        *read_at = Some(Instant::now());
+        Some(post_mortem.clone())
+    }
+
+    /// Drop post-mortems last read `idle` or longer ago; unread ones stay
+    pub fn expire(&mut self, idle: Duration) -> usize {
+        let before = self.entries.len();
+        self.entries
+            .retain(|(_, read_at)| read_at.is_none_or(|at| at.elapsed() < idle));
+        before - self.entries.len()
+    }
+
+    pub fn len(&self) -> usize {
+        self.entries.len()
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.entries.is_empty()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn post_mortem(session_id: &str, exit_code: i64) -> SessionPostMortem {
+        SessionPostMortem {
+            session_id: session_id.to_string(),
+            language: "go".to_string(),
+            program: "/w/main.go".to_string(),
+            removed: false,
+            post_mortem: PostMortem::new(0, None, Some(exit_code), 20, None, vec![]),
+        }
+    }
+
+    #[test]
+    fn test_unread_post_mortems_survive_expiry() {
+        let mut kept = PostMortems::default();
+        kept.store(post_mortem("a", 1));
+        kept.store(post_mortem("b", 2));
+        kept.store(post_mortem("a", 3));
+        assert_eq!(kept.len(), 2);
+
+        let a = kept.read("a").unwrap();
+        assert!(a.removed);
+        assert_eq!(a.post_mortem.exit_code, Some(3));
+        assert_eq!(kept.expire(Duration::from_secs(60)), 0);
+        assert_eq!(kept.expire(Duration::ZERO), 1);
+        assert!(kept.read("a").is_none());
+        assert!(kept.read("b").is_some());
+
+        for i in 0..MAX_POST_MORTEMS {
+            kept.store(post_mortem(&format!("s{}", i), 0));
+        }
+        assert_eq!(kept.len(), MAX_POST_MORTEMS);
+        assert!(kept.read("b").is_none());
+    }
+}
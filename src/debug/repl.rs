@@ -0,0 +1,172 @@
+//! REPLs bound to a stopped frame
+//!
+//! `debugger_repl_open` binds a REPL to a frame of the stop the program is
+//! at, and `debugger_repl_eval` evaluates input there in the DAP `repl`
+//! context, where adapters keep state between inputs as far as they can:
+//! debugpy runs statements, so `x = 41` assigns a variable the next input
+//! in the same frame can read. Delve takes its own commands (`print`,
+//! `locals`, `call`), CodeLLDB LLDB commands.
+//!
+//! Frames only exist while the program is stopped, so a REPL only evaluates
+//! at the stop it was opened at; once the program resumes, a new REPL has to
+//! be opened. The history of inputs and outputs stays readable.
+
+use crate::{Error, Result};
+use serde::Serialize;
+use std::collections::VecDeque;
+use std::time::Instant;
+
+/// History entries kept per REPL; older ones are dropped
+pub const MAX_HISTORY: usize = 200;
+
+/// REPLs kept per session; the oldest is closed when another is opened
+pub const MAX_REPLS: usize = 16;
+
+/// One input and what it evaluated to
+#[derive(Debug, Clone, PartialEq, Serialize)]
+pub struct ReplEntry {
+    pub input: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub output: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub error: Option<String>,
+}
+
+#[derive(Debug, Clone)]
+pub struct Repl {
+    pub id: String,
+    pub thread_id: i32,
+    /// Frame inputs are evaluated in (None: the adapter's default)
+    pub frame_id: Option<i32>,
+    /// When the program stopped at the stop the REPL is bound to
+    pub stopped_at: Instant,
+    pub history: VecDeque<ReplEntry>,
+    /// Entries dropped from the front of the history
+    pub dropped: usize,
+}
+
+impl Repl {
+    pub fn new(thread_id: i32, frame_id: Option<i32>, stopped_at: Instant) -> Self {
+        Self {
+            id: format!("repl-{}", uuid::Uuid::new_v4()),
+            thread_id,
+            frame_id,
+            stopped_at,
+            history: VecDeque::new(),
+            dropped: 0,
+        }
+    }
+
+    /// Whether the program is still at the REPL's stop (`stopped_at` is the
+    /// session's current stop, None while it runs)
+    pub fn is_current(&self, stopped_at: Option<Instant>) -> bool {
+        stopped_at == Some(self.stopped_at)
+    }
+
+    /// Fail unless the program is still at the REPL's stop
+    pub fn check_current(&self, stopped_at: Option<Instant>) -> Result<()> {
+        if self.is_current(stopped_at) {
+            return Ok(());
+        }
+        Err(Error::InvalidState(format!(
+            "{} is closed: the program resumed from the stop it was opened at. Open a new REPL with debugger_repl_open once the program is stopped; debugger_repl_history still shows this one's history",
+            self.id
+        )))
+    }
+
+    /// Add an input and its result to the history
+    pub fn record(&mut self, input: &str, result: std::result::Result<String, String>) {
+        let (output, error) = match result {
+            Ok(output) => (Some(output), None),
+            Err(error) => (None, Some(error)),
+        };
+        self.history.push_back(ReplEntry {
+            input: input.to_string(),
+            output,
+            error,
+        });
+        while self.history.len() > MAX_HISTORY {
+            self.history.pop_front();
+            self.dropped += 1;
+        }
+    }
+}
+
+/// A session's REPLs, oldest first
+#[derive(Debug, Clone, Default)]
+pub struct Repls {
+    repls: VecDeque<Repl>,
+}
+
+impl Repls {
+    /// Keep a new REPL, closing the oldest beyond `MAX_REPLS`
+    pub fn insert(&mut self, repl: Repl) {
+        self.repls.push_back(repl);
+        while self.repls.len() > MAX_REPLS {
+            self.repls.pop_front();
+        }
+    }
+
+    pub fn get(&self, id: &str) -> Option<&Repl> {
+        self.repls.iter().find(|r| r.id == id)
+    }
+
+    pub fn get_mut(&mut self, id: &str) -> Option<&mut Repl> {
+        self.repls.iter_mut().find(|r| r.id == id)
+    }
+
+    pub fn contains(&self, id: &str) -> bool {
+        self.get(id).is_some()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_history_is_bounded() {
+        let mut repl = Repl::new(1, Some(7), Instant::now());
+        repl.record("x = 41", Ok(String::new()));
+        repl.record("y", Err("NameError: name 'y' is not defined".to_string()));
+        assert_eq!(repl.history[0].output.as_deref(), Some(""));
+        assert!(repl.history[1]
+            .error
+            .as_deref()
+            .unwrap()
+            .contains("NameError"));
+
+        for i in 0..MAX_HISTORY {
+            repl.record(&format!("x + {}", i), Ok((41 + i).to_string()));
+        }
+        assert_eq!(repl.history.len(), MAX_HISTORY);
+        assert_eq!(repl.dropped, 2);
+        assert_eq!(repl.history[0].input, "x + 0");
+    }
+
+    #[test]
+    fn test_closed_by_resume() {
+        let stop = Instant::now();
+        let repl = Repl::new(1, None, stop);
+        assert!(repl.check_current(Some(stop)).is_ok());
+
+        let err = repl.check_current(None).unwrap_err();
+        assert!(matches!(err, Error::InvalidState(_)));
+        assert!(err.to_string().contains("debugger_repl_open"), "{}", err);
+        let next_stop = stop + std::time::Duration::from_millis(1);
+        assert!(repl.check_current(Some(next_stop)).is_err());
+    }
+
+    #[test]
+    fn test_oldest_repl_closed_beyond_limit() {
+        let mut repls = Repls::default();
+        let first = Repl::new(1, None, Instant::now());
+        let first_id = first.id.clone();
+        repls.insert(first);
+        for _ in 0..MAX_REPLS {
+            repls.insert(Repl::new(1, None, Instant::now()));
+        }
+        assert!(!repls.contains(&first_id));
+        assert_eq!(repls.repls.len(), MAX_REPLS);
+    }
+}
@@ -0,0 +1,184 @@
+//! Memory and CPU usage of the debuggee over time
+//!
+//! Once the debugger reports the debuggee's pid (the first `process` event
+//! with a `systemProcessId`), its resident memory and CPU time are read
+//! from procfs every `SAMPLE_INTERVAL` while the session lives, and the
+//! last `MAX_RESOURCE_SAMPLES` readings are kept with the peaks of all of
+//! them. A reading costs two small file reads and no debugger request, so
+//! sampling is always on.
+//!
+//! While the program is stopped nothing is read: a stopped process neither
+//! allocates nor computes, and the values are reported frozen at the last
+//! reading instead, from the moment it stops. The first reading after it
+//! runs again starts a new CPU baseline rather than averaging over the
+//! stop, so it has no `cpuPercent`. Sampling ends for good with the session, or when the
+//! process can't be read: it exited, or runs where this server can't see
+//! it (a remote debugger, another pid namespace).
+
+use crate::process::usage::ProcessUsage;
+use serde::Serialize;
+use std::collections::VecDeque;
+use std::time::Duration;
+
+/// Time between readings
+pub const SAMPLE_INTERVAL: Duration = Duration::from_secs(5);
+
+/// Readings kept; older ones are dropped (ten minutes of running)
+pub const MAX_RESOURCE_SAMPLES: usize = 120;
+
+/// One reading of the debuggee
+#[derive(Debug, Clone, Copy, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ResourceSample {
+    /// Milliseconds since the session was created
+    pub at_ms: u64,
+    pub rss_bytes: u64,
+    /// CPU time since the process started
+    pub cpu_ms: u64,
+    /// CPU time over wall time since the previous reading (100: one core
+    /// busy); None for the first
+    pub cpu_percent: Option<f64>,
+}
+
+/// The debuggee's readings so far
+#[derive(Debug, Clone, PartialEq)]
+pub struct ResourceUsage {
+    pub pid: u32,
+    samples: VecDeque<ResourceSample>,
+    peak_rss_bytes: u64,
+    peak_cpu_percent: Option<f64>,
+    /// Stopped since the last reading: the next one starts a new baseline
+    stopped_since_reading: bool,
+    /// Why sampling ended before the session did
+    ended: Option<String>,
+}
+
+impl ResourceUsage {
+    pub fn new(pid: u32) -> Self {
+        Self {
+            pid,
+            samples: VecDeque::new(),
+            peak_rss_bytes: 0,
+            peak_cpu_percent: None,
+            stopped_since_reading: false,
+            ended: None,
+        }
+    }
+
+    /// Add a reading taken at `at_ms`
+    pub fn record(&mut self, at_ms: u64, usage: ProcessUsage) {
+        let baseline = self.samples.back().filter(|_| !self.stopped_since_reading);
+        let cpu_percent = baseline.and_then(|last| {
+            let wall_ms = at_ms.checked_sub(last.at_ms).filter(|ms| *ms > 0)?;
+            let cpu_ms = usage.cpu_ms.saturating_sub(last.cpu_ms);
+            Some((cpu_ms as f64 * 1000.0 / wall_ms as f64).round() / 10.0)
+        });
+        if self.samples.len() == MAX_RESOURCE_SAMPLES {
+            self.samples.pop_front();
+        }
+        self.samples.push_back(ResourceSample {
+            at_ms,
+            rss_bytes: usage.rss_bytes,
+            cpu_ms: usage.cpu_ms,
+            cpu_percent,
+        });
+        self.peak_rss_bytes = self.peak_rss_bytes.max(usage.rss_bytes);
+        if let Some(percent) = cpu_percent {
+            self.peak_cpu_percent = Some(self.peak_cpu_percent.map_or(percent, |p| p.max(percent)));
+        }
+        self.stopped_since_reading = false;
+    }
+
+    /// The program is stopped: no reading until it runs again, and CPU time
+    /// is measured afresh from the first one after that
+    pub fn freeze(&mut self) {
+        self.stopped_since_reading = true;
+    }
+
+    /// Sampling ended before the session did, for `reason`
+    pub fn end(&mut self, reason: String) {
+        self.ended = Some(reason);
+    }
+
+    pub fn is_ended(&self) -> bool {
+        self.ended.is_some()
+    }
+
+    /// Current and peak values, for `debugger_session_state`; `stopped` is
+    /// whether the program is stopped now
+    pub fn summary(&self, stopped: bool) -> ResourceSummary {
+        ResourceSummary {
+            pid: self.pid,
+            current: self.samples.back().copied(),
+            peak_rss_bytes: self.peak_rss_bytes,
+            peak_cpu_percent: self.peak_cpu_percent,
+            frozen: stopped,
+            ended: self.ended.clone(),
+        }
+    }
+
+    /// Readings kept, oldest first
+    pub fn samples(&self) -> Vec<ResourceSample> {
+        self.samples.iter().copied().collect()
+    }
+}
+
+/// Current and peak usage of the debuggee
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ResourceSummary {
+    pub pid: u32,
+    /// The last reading; None before the first
+    pub current: Option<ResourceSample>,
+    pub peak_rss_bytes: u64,
+    pub peak_cpu_percent: Option<f64>,
+    /// The program is stopped; `current` is as of its last run
+    pub frozen: bool,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub ended: Option<String>,
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn usage(rss_mb: u64, cpu_ms: u64) -> ProcessUsage {
+        ProcessUsage {
+            rss_bytes: rss_mb << 20,
+            cpu_ms,
+        }
+    }
+
+    #[test]
+    fn test_records_rates_and_peaks() {
+        let mut series = ResourceUsage::new(42);
+        series.record(1000, usage(10, 100));
+        series.record(6000, usage(300, 2600));
+        series.freeze();
+        let summary = series.summary(true);
+        assert!(summary.frozen);
+        assert_eq!(summary.current.unwrap().cpu_percent, Some(50.0));
+
+        // Stopped for a minute: the time isn't averaged in
+        series.record(66000, usage(20, 2650));
+        let summary = series.summary(false);
+        assert!(!summary.frozen);
+        assert_eq!(summary.current.unwrap().rss_bytes, 20 << 20);
+        assert_eq!(summary.current.unwrap().cpu_percent, None);
+        assert_eq!(summary.peak_rss_bytes, 300 << 20);
+        assert_eq!(summary.peak_cpu_percent, Some(50.0));
+        assert_eq!(series.samples()[0].cpu_percent, None);
+        series.record(71000, usage(20, 3650));
+        assert_eq!(
+            series.summary(false).current.unwrap().cpu_percent,
+            Some(20.0)
+        );
+        assert_eq!(series.summary(false).peak_cpu_percent, Some(50.0));
+
+        for i in 0..MAX_RESOURCE_SAMPLES as u64 {
+            series.record(72000 + i, usage(1, 3650));
+        }
+        assert_eq!(series.samples().len(), MAX_RESOURCE_SAMPLES);
+        assert_eq!(series.summary(false).peak_rss_bytes, 300 << 20);
+    }
+}
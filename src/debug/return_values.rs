@@ -0,0 +1,137 @@
+//! Return values of a function just stepped out of
+//!
+//! Adapters that report return values do so as extra variables in the top
+//! frame's locals after the step completes, each under its own naming scheme:
+//!
+//! - debugpy: `(return) fizzbuzz` (with `showReturnValue` in the launch config)
+//! - Delve: `(ret) name` for named results, `~r0`, `~r1`, ... for unnamed ones
+//! - rdbg: `%return`
+//! - vscode-js-debug: `Return value`
+//!
+//! Adapters without any of these (e.g. CodeLLDB) simply report none, and
+//! Delve reports them only after a step out, so a step report says why it
+//! has no return value (`unavailable_reason`).
+
+use crate::dap::types::Variable;
+use serde::Serialize;
+
+/// Prefixes of variables holding a return value
+const RETURN_VALUE_PREFIXES: &[&str] = &["(return) ", "(ret) "];
+
+/// Exact names of variables holding a return value
+const RETURN_VALUE_NAMES: &[&str] = &["%return", "Return value"];
+
+/// A value returned by the function that was stepped out of
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ReturnValue {
+    /// Variable name as reported by the adapter (e.g. `(return) fizzbuzz`, `~r0`)
+    pub name: String,
+    pub value: String,
+    #[serde(rename = "type", skip_serializing_if = "Option::is_none")]
+    pub type_: Option<String>,
+    /// Non-zero when the value has children (expand with the adapter's `variables`)
+    pub variables_reference: i32,
+}
+
+/// Whether a variable name marks a return value
+pub fn is_return_value(name: &str) -> bool {
+    RETURN_VALUE_NAMES.contains(&name)
+        || RETURN_VALUE_PREFIXES.iter().any(|p| name.starts_with(p))
+        || name
+            .strip_prefix("~r")
+            .is_some_and(|n| !n.is_empty() && n.chars().all(|c| c.is_ascii_digit()))
+}
+
+/// Return values among a frame's variables, in the order the adapter listed them
+pub fn extract(variables: &[Variable]) -> Vec<ReturnValue> {
+    variables
+        .iter()
+        .filter(|v| is_return_value(&v.name))
+        .map(|v| ReturnValue {
+            name: v.name.clone(),
+            value: v.value.clone(),
+            type_: v.type_.clone(),
+            variables_reference: v.variables_reference,
+        })
+        .collect()
+}
+
+/// Why a step in a session of `language` came back without a return value
+pub fn unavailable_reason(language: &str, step_out: bool) -> &'static str {
+    match language {
+        "rust" => "CodeLLDB doesn't report return values",
+        "go" if !step_out => "Delve reports return values only after a step out",
+        _ if step_out => "the debugger reported no return value for the function stepped out of",
+        _ => "no function returned during the step (or the debugger didn't report its value)",
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn var(name: &str, value: &str) -> Variable {
+        Variable {
+            name: name.to_string(),
+            value: value.to_string(),
+            type_: None,
+            variables_reference: 0,
+            indexed_variables: None,
+            encoding_lossy: false,
+        }
+    }
+
+    #[test]
+    fn test_adapter_naming_schemes() {
+        for name in [
+            "(return) fizzbuzz",
+            "(ret) err",
+            "~r0",
+            "~r12",
+            "%return",
+            "Return value",
+        ] {
+            assert!(is_return_value(name), "{}", name);
+        }
+        for name in ["result", "~r", "~rx", "return", "(returned)", "%returns"] {
+            assert!(!is_return_value(name), "{}", name);
+        }
+    }
+
+    #[test]
+    fn test_extract_fizzbuzz_return() {
+        // debugpy's locals in main() after stepping out of fizzbuzz(15)
+        let locals = vec![
+            var("(return) fizzbuzz", "'FizzBuzz'"),
+            var("i", "15"),
+            var("result", "'14'"),
+        ];
+        let values = extract(&locals);
+        assert_eq!(values.len(), 1);
+        assert_eq!(values[0].name, "(return) fizzbuzz");
+        assert_eq!(values[0].value, "'FizzBuzz'");
+
+        // Delve reports multiple results of a Go function
+        let locals = vec![
+            var("i", "3"),
+            var("~r0", "\"Fizz\""),
+            var("(ret) err", "nil"),
+        ];
+        let names: Vec<String> = extract(&locals).into_iter().map(|v| v.name).collect();
+        assert_eq!(names, vec!["~r0", "(ret) err"]);
+
+        assert!(extract(&[var("i", "1")]).is_empty());
+    }
+
+    #[test]
+    fn test_unavailable_reasons() {
+        assert_eq!(
+            unavailable_reason("rust", true),
+            "CodeLLDB doesn't report return values"
+        );
+        assert!(unavailable_reason("go", false).contains("only after a step out"));
+        assert!(unavailable_reason("go", true).contains("stepped out of"));
+        assert!(unavailable_reason("python", false).contains("no function returned"));
+    }
+}
@@ -67,14 +67,70 @@
 //! - `src/dap/client.rs` - DapClient with reverse request handling
 //! - `docs/NODEJS_ALL_TESTS_PASSING.md` - Multi-session architecture details
 
+use super::breakpoint_io::{BreakpointDocument, ImportReport, ImportStatus, WatchEntry};
+use super::breakpoint_move;
+use super::caller_filter::{self, CallerCheck, CallerMatch};
+use super::change_watch::{self, ChangeWatch, Observation, WatchStop, WatchStrategy};
+use super::compare::{SessionValue, SideStatus};
+use super::continue_past::{ContinuePast, ContinuePastReport, ContinuePastStatus};
+use super::crash::{self, CrashFrame, CrashReport};
+use super::disassembly::{self, DisassemblyPage, DisassemblyWindow};
+use super::dump;
+use super::event_log::SessionEvent;
+use super::file_watch::{Relaunch, WatchStatus};
+use super::group::Membership;
+use super::inline_values::{self, InlineValues};
+use super::log_points;
 use super::multi_session::MultiSessionManager;
-use super::state::{DebugState, SessionState};
+use super::output_log::OutputLog;
+use super::peek::{self, Peek};
+use super::post_mortem::SessionPostMortem;
+use super::repl::Repl;
+use super::resource_usage;
+use super::return_values::{self, ReturnValue};
+use super::settings::{SessionSettings, SettingsUpdate};
+use super::shared_line;
+use super::source::{self, ResolvedSource, SourceOrigin};
+use super::source_check;
+use super::stack::{LoadedStack, StackPage, DEFAULT_STACK_LEVELS};
+use super::state::{
+    Breakpoint, CapturedLocal, DebugState, DebuggeeProcess, ExceptionCapture, FunctionBreakpoint,
+    InstructionBreakpoint, MemoryChange, SessionState, ThreadState,
+};
+use super::step_filter::{StepFilters, MAX_AUTO_STEPS};
+use super::subscription::Subscription;
+use super::thread_eval::{self, ThreadEvaluation, ThreadEvaluations};
+use super::transcript::Transcript;
+use super::webhook::{Delivery, Webhook};
+use crate::adapters::golang::{
+    self, BuildDir, GoAdapter, GoroutinePage, HangAnalysis, SubstitutePath, GOROUTINE_STATE_FRAMES,
+    MAX_ANALYZED_GOROUTINES,
+};
+use crate::adapters::goroutine_labels::{self, GoroutineLabel, LabelLayout, LABELS_POINTER};
+use crate::adapters::python::{AsyncTasks, PythonAdapter};
+use crate::adapters::toolchain::Toolchain;
+use crate::adapters::{
+    default_step_filters, exec_prefix, security, source_maps, EntryBreakpoint, LaunchGate,
+    OnUncaught,
+};
 use crate::dap::client::DapClient;
-use crate::dap::types::{Source, SourceBreakpoint};
+use crate::dap::launch_phase::{LaunchPhase, LaunchPhases, LaunchReport};
+use crate::dap::metrics::{Metrics, MetricsReport};
+use crate::dap::read_only;
+use crate::dap::request_queue::QueueReport;
+use crate::dap::types::{
+    Event, ExceptionInfo, ExceptionOptions, Scope, Source, SourceBreakpoint, StackFrame,
+    SteppingGranularity, Thread, Variable,
+};
 use crate::Result;
-use std::collections::HashMap;
-use std::sync::Arc;
-use tokio::sync::RwLock;
+use std::collections::{BTreeMap, HashMap};
+use std::future::Future;
+use std::path::{Path, PathBuf};
+use std::sync::atomic::{AtomicBool, AtomicU64, Ordering};
+use std::sync::{Arc, Weak};
+use std::time::Duration;
+use tokio::sync::{mpsc, oneshot, watch, RwLock};
+use tokio::task::AbortHandle;
 use tracing::{error, info, warn};
 use uuid::Uuid;
 
@@ -106,8 +162,185 @@ pub struct DebugSession {
     pub(crate) state: Arc<RwLock<SessionState>>,
     /// Pending breakpoints that will be applied after initialization completes
     pending_breakpoints: Arc<RwLock<HashMap<String, Vec<SourceBreakpoint>>>>,
+    /// Interval between keep-alive pings on the adapter connection (None = disabled)
+    keep_alive_interval: Arc<RwLock<Option<Duration>>>,
+    /// Whether the keep-alive loop is currently running
+    keep_alive_running: Arc<AtomicBool>,
+    /// Interval of the stop-detection poll (None = disabled)
+    stop_poll_interval: Arc<RwLock<Option<Duration>>>,
+    /// Whether the stop-detection poll loop is currently running
+    stop_poll_running: Arc<AtomicBool>,
+    /// Successful source resolutions, keyed by the path the adapter reported
+    source_cache: Arc<RwLock<HashMap<String, ResolvedSource>>>,
+    /// Set when the adapter should be kept alive for reuse after a restart-intent disconnect
+    warm_adapter: Arc<RwLock<Option<WarmAdapterConfig>>>,
+    /// Startup time saved because this session reused a warm adapter
+    reused_startup: Arc<RwLock<Option<Duration>>>,
+    /// Take a post-mortem snapshot on every exception stop
+    capture_on_exception: Arc<AtomicBool>,
+    /// Directory of the binary Delve builds; removed when the session ends
+    build_dir: Arc<RwLock<Option<BuildDir>>>,
+    /// Default wait for a stop (None = the server's `wait_for_stop_ms`)
+    wait_for_stop_timeout: Arc<RwLock<Option<Duration>>>,
+    /// `events_seq` of the last stop checked for a crash report
+    crash_checked: Arc<AtomicU64>,
+    /// Code steps don't stop in
+    step_filters: Arc<RwLock<StepFilters>>,
+    /// Request latencies, stops and startup time, recorded by every client
+    metrics: Arc<Metrics>,
+    /// Background initialization and launch (see `cancel_start`)
+    init_task: Arc<std::sync::Mutex<Option<AbortHandle>>>,
+    /// Set when the start was cancelled before the program launched
+    start_cancelled: Arc<AtomicBool>,
+    /// `debugger_start` arguments the session was started with
+    start_arguments: Arc<RwLock<Option<serde_json::Map<String, serde_json::Value>>>>,
+    /// Launch arguments the adapter was last given, unredacted, for restarts
+    launch_arguments: Arc<RwLock<Option<serde_json::Value>>>,
+    /// Attached read-only (see `dap::read_only`); never cleared
+    read_only: Arc<AtomicBool>,
+    /// Command the adapter runs through in another container or namespace
+    /// (see `exec_prefix`); None when it runs on the server's host
+    exec_prefix: Arc<RwLock<Option<exec_prefix::Tracked>>>,
+    /// The validated `pythonPath` or `goPath` the session was started with
+    toolchain: Arc<RwLock<Option<Toolchain>>>,
+    /// Delve's `substitutePath` rules the session was started with, for
+    /// imported paths recorded elsewhere (see `import_breakpoints`)
+    substitute_path: Arc<RwLock<Vec<SubstitutePath>>>,
+    /// Where the start is, and how long each of its phases took
+    launch_phases: Arc<LaunchPhases>,
+    /// Output events on their way into the state, recorded in the order
+    /// the adapter sent them (see `output_recorder`)
+    output_queue: mpsc::UnboundedSender<QueuedOutput>,
 }
 
+/// What the output recorder is given, in order
+enum QueuedOutput {
+    /// The category and text of an `output` event
+    Event(Option<String>, String),
+    /// Everything queued before is recorded (see `flush_output`)
+    Flush(oneshot::Sender<()>),
+}
+
+/// How `DebugSession::step_out_of_file` ended
+#[derive(Debug, Clone, PartialEq)]
+pub enum FileStepEnd {
+    /// Execution is now in another file
+    Left,
+    /// Something other than a step stopped the program (breakpoint, exception, ...)
+    Interrupted { reason: String },
+    /// The program ended
+    Terminated,
+    /// A step didn't complete in time; the program is running
+    StillRunning,
+    /// Still in the file after the maximum number of steps
+    LimitReached,
+}
+
+/// Result of `DebugSession::step_out_of_file`
+#[derive(Debug, Clone)]
+pub struct FileStepOutcome {
+    pub end: FileStepEnd,
+    /// Steps taken
+    pub steps: usize,
+    /// File that was being stepped out of
+    pub from_path: String,
+    /// Top frame where execution stopped (None if it didn't stop)
+    pub location: Option<StackFrame>,
+}
+
+/// How `DebugSession::step_to_line` ended
+#[derive(Debug, Clone, PartialEq)]
+pub enum LineStepEnd {
+    /// Stopped on the line, still in the function
+    Reached,
+    /// Something other than a step stopped the program (breakpoint, exception, ...)
+    Interrupted { reason: String },
+    /// The function returned without reaching the line
+    Returned,
+    /// The program ended
+    Terminated,
+    /// A step didn't complete in time; the program is running
+    StillRunning,
+    /// The line wasn't reached within the maximum number of steps
+    LimitReached,
+}
+
+/// Result of `DebugSession::step_to_line`
+#[derive(Debug, Clone)]
+pub struct LineStepOutcome {
+    pub end: LineStepEnd,
+    /// Steps taken
+    pub steps: usize,
+    /// Top frame where execution stopped (None if it didn't stop)
+    pub location: Option<StackFrame>,
+}
+
+/// How `DebugSession::run_to_breakpoint` ended
+#[derive(Debug, Clone, PartialEq)]
+pub enum BreakpointWaitEnd {
+    /// Stopped at the breakpoint
+    Hit,
+    /// A stop that isn't continued through: any other stop without
+    /// auto-continue, and stops that aren't for a breakpoint (exception,
+    /// pause, ...) with it
+    Interrupted { reason: String },
+    /// The program ended
+    Terminated,
+    /// The timeout passed; the program is running
+    StillRunning,
+    /// Stopped at another breakpoint after the maximum number of auto-continues
+    LimitReached,
+}
+
+/// Result of `DebugSession::run_to_breakpoint`
+#[derive(Debug, Clone)]
+pub struct BreakpointWaitOutcome {
+    pub end: BreakpointWaitEnd,
+    /// Stops at other breakpoints continued through
+    pub auto_continues: u64,
+    /// Thread of the last stop (None if it didn't stop)
+    pub thread_id: Option<i32>,
+    /// Top frame where execution stopped (None if it didn't stop)
+    pub location: Option<StackFrame>,
+}
+
+/// Where a step ended after leaving filtered code
+#[derive(Debug, Clone, PartialEq)]
+pub struct FilteredStepOutcome {
+    /// State after the last step (None if it didn't complete in time)
+    pub state: Option<DebugState>,
+    /// Steps taken automatically to leave filtered frames
+    pub skipped: usize,
+    /// Still in filtered code after `MAX_AUTO_STEPS` steps
+    pub limit_reached: bool,
+}
+
+/// How a session's adapter is kept alive between program restarts
+#[derive(Debug, Clone, Copy, PartialEq)]
+pub struct WarmAdapterConfig {
+    /// How long a parked adapter waits for the next start before being torn down
+    pub grace_period: Duration,
+    /// Time it took to spawn and connect to the adapter (what reuse saves)
+    pub startup_time: Duration,
+}
+
+/// Upper bound on how long a single keep-alive ping may take before the
+/// connection is considered dead
+const KEEP_ALIVE_MAX_TIMEOUT: Duration = Duration::from_secs(5);
+
+/// Upper bound on each request made by a stop-detection poll
+const STOP_POLL_MAX_TIMEOUT: Duration = Duration::from_secs(2);
+
+/// How long loading a stop's top frame may take; the stop is reported
+/// before it either way
+const STOP_FRAME_TIMEOUT: Duration = Duration::from_secs(1);
+
+/// Frames and top-frame variables kept in an exception capture, and the
+/// longest variable value
+const CAPTURE_STACK_LEVELS: i32 = 50;
+const MAX_CAPTURED_LOCALS: usize = 50;
+const CAPTURED_VALUE_CHARS: usize = 500;
+
 impl DebugSession {
     /// Create a new debug session in Single mode (for Python, Ruby)
     ///
@@ -115,6 +348,14 @@ impl DebugSession {
     /// For multi-session debugging (Node.js), use `new_with_mode()`.
     pub async fn new(language: String, program: String, client: DapClient) -> Result<Self> {
         let id = Uuid::new_v4().to_string();
+        client.set_log_session(&id);
+        let metrics = Arc::new(Metrics::new());
+        client.set_metrics(metrics.clone());
+        let launch_phases = Arc::new(LaunchPhases::new());
+        client.set_launch_phases(launch_phases.clone());
+        let step_filters = StepFilters::new(default_step_filters(&language));
+        let state = Arc::new(RwLock::new(SessionState::new()));
+        let output_queue = Self::output_recorder(&state);
 
         Ok(Self {
             id,
@@ -123,8 +364,31 @@ impl DebugSession {
             session_mode: SessionMode::Single {
                 client: Arc::new(RwLock::new(client)),
             },
-            state: Arc::new(RwLock::new(SessionState::new())),
+            state,
             pending_breakpoints: Arc::new(RwLock::new(HashMap::new())),
+            keep_alive_interval: Arc::new(RwLock::new(None)),
+            keep_alive_running: Arc::new(AtomicBool::new(false)),
+            stop_poll_interval: Arc::new(RwLock::new(None)),
+            stop_poll_running: Arc::new(AtomicBool::new(false)),
+            source_cache: Arc::new(RwLock::new(HashMap::new())),
+            warm_adapter: Arc::new(RwLock::new(None)),
+            reused_startup: Arc::new(RwLock::new(None)),
+            capture_on_exception: Arc::new(AtomicBool::new(false)),
+            build_dir: Arc::new(RwLock::new(None)),
+            wait_for_stop_timeout: Arc::new(RwLock::new(None)),
+            crash_checked: Arc::new(AtomicU64::new(0)),
+            step_filters: Arc::new(RwLock::new(step_filters)),
+            metrics,
+            init_task: Arc::new(std::sync::Mutex::new(None)),
+            start_cancelled: Arc::new(AtomicBool::new(false)),
+            start_arguments: Arc::new(RwLock::new(None)),
+            launch_arguments: Arc::new(RwLock::new(None)),
+            read_only: Arc::new(AtomicBool::new(false)),
+            exec_prefix: Arc::new(RwLock::new(None)),
+            toolchain: Arc::new(RwLock::new(None)),
+            substitute_path: Arc::new(RwLock::new(Vec::new())),
+            launch_phases,
+            output_queue,
         })
     }
 
@@ -137,17 +401,97 @@ impl DebugSession {
         session_mode: SessionMode,
     ) -> Result<Self> {
         let id = Uuid::new_v4().to_string();
+        let metrics = Arc::new(Metrics::new());
+        let launch_phases = Arc::new(LaunchPhases::new());
+        let client = match &session_mode {
+            SessionMode::Single { client } => client,
+            SessionMode::MultiSession { parent_client, .. } => parent_client,
+        };
+        {
+            let client = client.read().await;
+            client.set_log_session(&id);
+            client.set_metrics(metrics.clone());
+            client.set_launch_phases(launch_phases.clone());
+        }
+        let step_filters = StepFilters::new(default_step_filters(&language));
+        let state = Arc::new(RwLock::new(SessionState::new()));
+        let output_queue = Self::output_recorder(&state);
 
         Ok(Self {
             id,
             language,
             program,
             session_mode,
-            state: Arc::new(RwLock::new(SessionState::new())),
+            state,
             pending_breakpoints: Arc::new(RwLock::new(HashMap::new())),
+            keep_alive_interval: Arc::new(RwLock::new(None)),
+            keep_alive_running: Arc::new(AtomicBool::new(false)),
+            stop_poll_interval: Arc::new(RwLock::new(None)),
+            stop_poll_running: Arc::new(AtomicBool::new(false)),
+            source_cache: Arc::new(RwLock::new(HashMap::new())),
+            warm_adapter: Arc::new(RwLock::new(None)),
+            reused_startup: Arc::new(RwLock::new(None)),
+            capture_on_exception: Arc::new(AtomicBool::new(false)),
+            build_dir: Arc::new(RwLock::new(None)),
+            wait_for_stop_timeout: Arc::new(RwLock::new(None)),
+            crash_checked: Arc::new(AtomicU64::new(0)),
+            step_filters: Arc::new(RwLock::new(step_filters)),
+            metrics,
+            init_task: Arc::new(std::sync::Mutex::new(None)),
+            start_cancelled: Arc::new(AtomicBool::new(false)),
+            start_arguments: Arc::new(RwLock::new(None)),
+            launch_arguments: Arc::new(RwLock::new(None)),
+            read_only: Arc::new(AtomicBool::new(false)),
+            exec_prefix: Arc::new(RwLock::new(None)),
+            toolchain: Arc::new(RwLock::new(None)),
+            substitute_path: Arc::new(RwLock::new(Vec::new())),
+            launch_phases,
+            output_queue,
         })
     }
 
+    /// Record the output events queued on the returned sender into
+    /// `state`, one at a time in the order they were queued
+    ///
+    /// Event handlers can't wait for the state lock, and a task per event
+    /// could store the lines out of order.
+    fn output_recorder(state: &Arc<RwLock<SessionState>>) -> mpsc::UnboundedSender<QueuedOutput> {
+        let (queue, mut queued) = mpsc::unbounded_channel::<QueuedOutput>();
+        let state = Arc::downgrade(state);
+        tokio::spawn(async move {
+            while let Some(queued) = queued.recv().await {
+                let (category, text) = match queued {
+                    QueuedOutput::Event(category, text) => (category, text),
+                    QueuedOutput::Flush(done) => {
+                        let _ = done.send(());
+                        continue;
+                    }
+                };
+                let Some(state) = state.upgrade() else {
+                    break;
+                };
+                state
+                    .write()
+                    .await
+                    .record_output(category.as_deref(), &text);
+            }
+        });
+        queue
+    }
+
+    /// Wait until the output events received so far are recorded
+    ///
+    /// The adapter's output before a response arrives before it, so after
+    /// this the state has the output that came before the launch response
+    /// (or its failure), and marking the program launched or reporting the
+    /// failure doesn't overtake it.
+    async fn flush_output(&self) {
+        let (done, flushed) = oneshot::channel();
+        if self.output_queue.send(QueuedOutput::Flush(done)).is_ok() {
+            let _ = flushed.await;
+        }
+    }
+
     /// Get the client to use for debugging operations
     ///
     /// # Parent vs Child Responsibilities (Multi-Session Mode)
@@ -253,6 +597,7 @@ impl DebugSession {
 
         // 2. Create DAP client for child
         let child_client = DapClient::from_socket(socket).await?;
+        child_client.set_metrics(self.metrics.clone());
         info!("   Created DAP client for child session");
 
         // 3. Initialize child session
@@ -296,27 +641,22 @@ impl DebugSession {
                 let state_clone = session_state.clone();
                 tokio::spawn(async move {
                     if let Some(body) = &event.body {
-                        let thread_id = body
-                            .get("threadId")
-                            .and_then(|v| v.as_i64())
-                            .map(|v| v as i32)
-                            .unwrap_or(1);
-                        let reason = body
-                            .get("reason")
-                            .and_then(|v| v.as_str())
-                            .unwrap_or("unknown")
-                            .to_string();
+                        let (thread_id, reason, all_threads_stopped) = parse_stopped_event(body);
 
                         info!(
                             "   [CHILD] Updating parent state to Stopped (thread: {}, reason: {})",
                             thread_id, reason
                         );
 
+                        let hit_breakpoint_ids = parse_hit_breakpoint_ids(body);
                         let mut state = state_clone.write().await;
-                        state.set_state(DebugState::Stopped {
-                            thread_id,
-                            reason: reason.clone(),
-                        });
+                        if reason == "breakpoint" {
+                            state.count_breakpoint_hits(&hit_breakpoint_ids, None);
+                        }
+                        state.apply_stopped(thread_id, reason.clone(), all_threads_stopped);
+                        state.set_hit_breakpoints(hit_breakpoint_ids);
+                        state.record_stop(thread_id, &reason);
+                        state.notify_stop(thread_id, &reason, all_threads_stopped);
 
                         info!("   ✅ Parent state updated to Stopped (reason: {})", reason);
                     }
@@ -329,11 +669,12 @@ impl DebugSession {
         child_client
             .on_event("continued", move |event| {
                 info!("▶️  [CHILD] Received 'continued' event: {:?}", event);
+                let (thread_id, all_threads_continued) = parse_continued_event(event.body.as_ref());
                 let state_clone = session_state.clone();
                 tokio::spawn(async move {
                     let mut state = state_clone.write().await;
-                    state.set_state(DebugState::Running);
-                    info!("   ✅ Parent state updated to Running");
+                    state.apply_continued(thread_id, all_threads_continued);
+                    info!("   ✅ Parent state updated to {:?}", state.state);
                 });
             })
             .await;
@@ -346,7 +687,7 @@ impl DebugSession {
                 let state_clone = session_state.clone();
                 tokio::spawn(async move {
                     let mut state = state_clone.write().await;
-                    state.set_state(DebugState::Terminated);
+                    state.end_program();
                     info!("   ✅ Parent state updated to Terminated");
                 });
             })
@@ -357,10 +698,15 @@ impl DebugSession {
         child_client
             .on_event("exited", move |event| {
                 info!("🚪 [CHILD] Received 'exited' event: {:?}", event);
+                let exit_code = parse_exit_code(event.body.as_ref());
                 let state_clone = session_state.clone();
                 tokio::spawn(async move {
                     let mut state = state_clone.write().await;
-                    state.set_state(DebugState::Terminated);
+                    state.end_program();
+                    if let Some(code) = exit_code {
+                        state.record_exit(code);
+                    }
+                    state.notify_exit(exit_code);
                     info!("   ✅ Parent state updated to Terminated (exited)");
                 });
             })
@@ -382,12 +728,14 @@ impl DebugSession {
             path: Some(self.program.clone()),
             name: None,
             source_reference: None,
+            checksums: None,
         };
         let entry_bp = crate::dap::types::SourceBreakpoint {
             line: entry_line as i32,
             column: None,
             condition: None,
             hit_condition: None,
+            log_message: None,
         };
         match child_client
             .set_breakpoints(source.clone(), vec![entry_bp])
@@ -423,6 +771,7 @@ impl DebugSession {
                     path: Some(file.clone()),
                     name: None,
                     source_reference: None,
+                    checksums: None,
                 };
 
                 match child_client.set_breakpoints(source, bp_list.clone()).await {
@@ -472,54 +821,227 @@ impl DebugSession {
         Ok(())
     }
 
-    /// Initialize and launch using the proper DAP sequence
-    /// This combines initialize and launch into one atomic operation
-    pub async fn initialize_and_launch(
-        &self,
-        adapter_id: &str,
-        launch_args: serde_json::Value,
-    ) -> Result<()> {
-        {
-            let mut state = self.state.write().await;
-            state.set_state(DebugState::Initializing);
-        }
-
-        let client_arc = self.get_debug_client().await;
-        let client = client_arc.read().await;
-
-        // Register event handlers BEFORE launching to capture all state changes
-        info!("📡 Registering DAP event handlers for session state tracking");
-
+    /// Register handlers that keep session and per-thread state in sync with adapter events
+    async fn register_state_handlers(&self, client: &DapClient) {
         // Handler for 'stopped' events (breakpoints, steps, entry)
         let session_state = self.state.clone();
+        let stop_client = Arc::downgrade(&self.get_debug_client().await);
+        let launch_phases = self.launch_phases.clone();
+        let metrics = self.metrics.clone();
         client
             .on_event("stopped", move |event| {
                 info!("📍 Received 'stopped' event: {:?}", event);
+                let received = std::time::Instant::now();
+                launch_phases.finish();
 
                 if let Some(body) = &event.body {
-                    let thread_id = body
-                        .get("threadId")
-                        .and_then(|v| v.as_i64())
-                        .map(|v| v as i32)
-                        .unwrap_or(1);
-
-                    let reason = body
-                        .get("reason")
-                        .and_then(|v| v.as_str())
-                        .unwrap_or("unknown")
-                        .to_string();
+                    let (thread_id, reason, all_threads_stopped) = parse_stopped_event(body);
+                    let hit_breakpoint_ids = parse_hit_breakpoint_ids(body);
 
-                    info!("   Thread: {}, Reason: {}", thread_id, reason);
+                    info!(
+                        "   Thread: {}, Reason: {}, All threads stopped: {}, Hit breakpoints: {:?}",
+                        thread_id, reason, all_threads_stopped, hit_breakpoint_ids
+                    );
 
                     // Update session state
                     let state_clone = session_state.clone();
+                    let stop_client = stop_client.clone();
+                    let metrics = metrics.clone();
                     tokio::spawn(async move {
+                        let client = stop_client.upgrade();
+                        if let Some(client) = &client {
+                            if Self::continue_uncaught(&state_clone, client, thread_id, &reason)
+                                .await
+                            {
+                                return;
+                            }
+                        }
+                        let mut watch_stop = None;
+                        let mut caller_stop = None;
+                        // Loaded here for a breakpoint stop naming no
+                        // breakpoint, which is a hit of the one it is at
+                        let mut top_page = None;
+                        if reason == "breakpoint" {
+                            let unattributed = hit_breakpoint_ids.is_empty()
+                                && state_clone
+                                    .read()
+                                    .await
+                                    .breakpoints
+                                    .values()
+                                    .flatten()
+                                    .any(|bp| bp.enabled);
+                            top_page = match &client {
+                                Some(client) if unattributed => {
+                                    Self::read_top_frame(client, thread_id).await
+                                }
+                                _ => None,
+                            };
+                            let mut state = state_clone.write().await;
+                            let mut top: Vec<StackFrame> = top_page
+                                .as_ref()
+                                .and_then(|page| page.frames.first().cloned())
+                                .into_iter()
+                                .collect();
+                            state.alias_paths(&mut top);
+                            let location = top
+                                .first()
+                                .and_then(|top| frame_path(top).map(|path| (path, top.line)));
+                            state.count_breakpoint_hits(&hit_breakpoint_ids, location);
+                            drop(state);
+                            if let Some(client) = &client {
+                                match Self::check_change_watches(
+                                    &state_clone,
+                                    client,
+                                    thread_id,
+                                    &hit_breakpoint_ids,
+                                )
+                                .await
+                                {
+                                    Some(Observation::Continue) => return,
+                                    Some(Observation::Stop(stop)) => watch_stop = Some(stop),
+                                    None => {}
+                                }
+                                match Self::check_callers(
+                                    &state_clone,
+                                    client,
+                                    thread_id,
+                                    &hit_breakpoint_ids,
+                                )
+                                .await
+                                {
+                                    Some(CallerCheck::Continue) => return,
+                                    Some(CallerCheck::Stop(found)) => caller_stop = Some(found),
+                                    None => {}
+                                }
+                                if Self::emulate_log_points(
+                                    &state_clone,
+                                    client,
+                                    thread_id,
+                                    &hit_breakpoint_ids,
+                                )
+                                .await
+                                    || Self::continue_unsubscribed(
+                                        &state_clone,
+                                        client,
+                                        thread_id,
+                                        &hit_breakpoint_ids,
+                                    )
+                                    .await
+                                    || Self::continue_past_hit(
+                                        &state_clone,
+                                        client,
+                                        thread_id,
+                                        &hit_breakpoint_ids,
+                                    )
+                                    .await
+                                {
+                                    return;
+                                }
+                            }
+                        }
+                        let fired = match &client {
+                            Some(client) => {
+                                Self::remove_fired_temporary_breakpoints(
+                                    &state_clone,
+                                    client,
+                                    thread_id,
+                                    &reason,
+                                    &hit_breakpoint_ids,
+                                )
+                                .await
+                            }
+                            None => Vec::new(),
+                        };
+                        let entry = match &client {
+                            Some(client) => {
+                                Self::take_entry_stop(
+                                    &state_clone,
+                                    client,
+                                    thread_id,
+                                    &reason,
+                                    &fired,
+                                )
+                                .await
+                            }
+                            None => false,
+                        };
+                        // Temporary breakpoints of the agent's own fired here too
+                        let other_fired = fired.len() > usize::from(entry);
+                        let at_entry = match &client {
+                            Some(client) if (entry || reason == "entry") && !other_fired => {
+                                Self::continue_from_entry(
+                                    &state_clone,
+                                    client,
+                                    thread_id,
+                                    &hit_breakpoint_ids,
+                                )
+                                .await
+                            }
+                            _ => None,
+                        };
+                        let (reason, hit_breakpoint_ids, fired) = match at_entry {
+                            Some(EntryStop::Continued) => return,
+                            // A breakpoint on the entry line stops the program
+                            // as if the entry stop were its hit
+                            Some(EntryStop::Breakpoints(ids)) => {
+                                if reason != "breakpoint" {
+                                    state_clone.write().await.count_breakpoint_hits(&ids, None);
+                                }
+                                ("breakpoint".to_string(), ids, fired)
+                            }
+                            // Reported like stopOnEntry, not as a hit of a user breakpoint
+                            None if entry => ("entry".to_string(), Vec::new(), Vec::new()),
+                            None => (reason, hit_breakpoint_ids, fired),
+                        };
                         let mut state = state_clone.write().await;
-                        state.set_state(DebugState::Stopped {
-                            thread_id,
-                            reason: reason.clone(),
-                        });
-                        info!("✅ Session state updated to Stopped (reason: {})", reason);
+                        state.apply_stopped(thread_id, reason.clone(), all_threads_stopped);
+                        state.set_hit_breakpoints(hit_breakpoint_ids);
+                        state.fired_temporary = fired;
+                        state.watch_stop = watch_stop;
+                        state.caller_stop = caller_stop;
+                        state.record_stop(thread_id, &reason);
+                        state.notify_stop(thread_id, &reason, all_threads_stopped);
+                        metrics.record_stop_notification(received.elapsed());
+                        match &state.group_member {
+                            Some(member) => info!(
+                                "✅ Member '{}' of group {} stopped (reason: {})",
+                                member.name, member.group_id, reason
+                            ),
+                            None => {
+                                info!("✅ Session state updated to Stopped (reason: {})", reason)
+                            }
+                        }
+                        let seq = state.events_seq;
+                        drop(state);
+
+                        // The stop is reported without waiting for its top frame,
+                        // which is kept for the readers that come after it
+                        let Some(client) = &client else {
+                            return;
+                        };
+                        let top = match top_page {
+                            Some(top) if client.read().await.pages_stack_traces(false).await => {
+                                Some(top)
+                            }
+                            _ => Self::load_top_frame(client, thread_id).await,
+                        };
+                        let Some(mut top) = top else {
+                            return;
+                        };
+                        let mut state = state_clone.write().await;
+                        if state.events.changed_state_since(seq, state.events_seq) {
+                            return;
+                        }
+                        state.alias_paths(&mut top.frames);
+                        if let Some(frame) = top.frames.first() {
+                            state.transcript.locate_latest_stop(
+                                thread_id,
+                                &frame.name,
+                                frame.source.as_ref().and_then(|s| s.path.as_deref()),
+                                frame.line,
+                            );
+                        }
+                        state.stack_cache.insert(seq, thread_id, &top);
                     });
                 }
             })
@@ -530,26 +1052,29 @@ impl DebugSession {
         client
             .on_event("continued", move |event| {
                 info!("▶️  Received 'continued' event: {:?}", event);
+                let (thread_id, all_threads_continued) = parse_continued_event(event.body.as_ref());
 
                 let state_clone = session_state.clone();
                 tokio::spawn(async move {
                     let mut state = state_clone.write().await;
-                    state.set_state(DebugState::Running);
-                    info!("✅ Session state updated to Running");
+                    state.apply_continued(thread_id, all_threads_continued);
+                    info!("✅ Session state updated to {:?}", state.state);
                 });
             })
             .await;
 
         // Handler for 'terminated' events
         let session_state = self.state.clone();
+        let launch_phases = self.launch_phases.clone();
         client
             .on_event("terminated", move |event| {
                 info!("🛑 Received 'terminated' event: {:?}", event);
+                launch_phases.finish();
 
                 let state_clone = session_state.clone();
                 tokio::spawn(async move {
                     let mut state = state_clone.write().await;
-                    state.set_state(DebugState::Terminated);
+                    state.end_program();
                     info!("✅ Session state updated to Terminated");
                 });
             })
@@ -561,147 +1086,857 @@ impl DebugSession {
             .on_event("exited", move |event| {
                 info!("🚪 Received 'exited' event: {:?}", event);
 
+                let exit_code = parse_exit_code(event.body.as_ref());
                 let state_clone = session_state.clone();
                 tokio::spawn(async move {
                     let mut state = state_clone.write().await;
-                    state.set_state(DebugState::Terminated);
+                    state.end_program();
+                    if let Some(code) = exit_code {
+                        state.record_exit(code);
+                    }
+                    state.notify_exit(exit_code);
                     info!("✅ Session state updated to Terminated (exited)");
                 });
             })
             .await;
 
+        // Handler for 'output' events (program output, kept for crash reports,
+        // and startup output, kept to explain a failed launch). Output the
+        // adapter sent before this handler existed is replayed to it.
+        let output_queue = self.output_queue.clone();
+        client
+            .on_event("output", move |event| {
+                let Some(body) = &event.body else {
+                    return;
+                };
+                let category = body
+                    .get("category")
+                    .and_then(|v| v.as_str())
+                    .map(str::to_string);
+                if let Some(text) = body.get("output").and_then(|v| v.as_str()) {
+                    // The recorder only stops with the session
+                    let _ = output_queue.send(QueuedOutput::Event(category, text.to_string()));
+                }
+            })
+            .await;
+
+        // Handler for 'process' events: the debuggee, then children the
+        // debugger follows (Delve with `followExec`) on the same connection
+        let session_state = self.state.clone();
+        client
+            .on_event("process", move |event| {
+                let Some(body) = &event.body else {
+                    return;
+                };
+                let process = DebuggeeProcess {
+                    name: body
+                        .get("name")
+                        .and_then(|v| v.as_str())
+                        .unwrap_or_default()
+                        .to_string(),
+                    pid: body.get("systemProcessId").and_then(|v| v.as_i64()),
+                    start_method: body
+                        .get("startMethod")
+                        .and_then(|v| v.as_str())
+                        .map(str::to_string),
+                };
+                let state_clone = session_state.clone();
+                tokio::spawn(async move {
+                    let mut state = state_clone.write().await;
+                    state.add_process(process);
+                    if let Some(pid) = state.start_resource_sampling() {
+                        tokio::spawn(Self::resource_sampling_loop(
+                            Arc::downgrade(&state_clone),
+                            pid,
+                            resource_usage::SAMPLE_INTERVAL,
+                        ));
+                    }
+                });
+            })
+            .await;
+
+        // Handler for 'memory' events: a range was modified, so values read
+        // before may be stale
+        let session_state = self.state.clone();
+        client
+            .on_event("memory", move |event| {
+                let Some(body) = &event.body else {
+                    return;
+                };
+                let Some(reference) = body.get("memoryReference").and_then(|v| v.as_str()) else {
+                    return;
+                };
+                let reference = reference.to_string();
+                let offset = body.get("offset").and_then(|v| v.as_i64()).unwrap_or(0);
+                let count = body.get("count").and_then(|v| v.as_i64()).unwrap_or(0);
+                let state_clone = session_state.clone();
+                tokio::spawn(async move {
+                    state_clone
+                        .write()
+                        .await
+                        .record_memory_change(reference, offset, count);
+                });
+            })
+            .await;
+
+        // Handler for 'breakpoint' events: the adapter bound, moved or
+        // unbound a breakpoint after answering setBreakpoints (java-debug
+        // binds breakpoints in classes that weren't loaded yet this way)
+        let session_state = self.state.clone();
+        client
+            .on_event("breakpoint", move |event| {
+                let Some(body) = &event.body else {
+                    return;
+                };
+                if body.get("reason").and_then(|v| v.as_str()) != Some("changed") {
+                    return;
+                }
+                let Some(bp) = body.get("breakpoint") else {
+                    return;
+                };
+                let Some(id) = bp.get("id").and_then(|v| v.as_i64()) else {
+                    return;
+                };
+                let verified = bp.get("verified").and_then(|v| v.as_bool()) == Some(true);
+                let line = bp.get("line").and_then(|v| v.as_i64()).map(|l| l as i32);
+                let state_clone = session_state.clone();
+                tokio::spawn(async move {
+                    if state_clone
+                        .write()
+                        .await
+                        .apply_breakpoint_change(id as i32, verified, line)
+                    {
+                        info!(
+                            "📍 Breakpoint {} {} by the debugger",
+                            id,
+                            if verified { "bound" } else { "unbound" }
+                        );
+                    }
+                });
+            })
+            .await;
+
         // Handler for 'thread' events (track threads)
         let session_state = self.state.clone();
         client
             .on_event("thread", move |event| {
                 if let Some(body) = &event.body {
                     if let Some(thread_id) = body.get("threadId").and_then(|v| v.as_i64()) {
+                        let exited = body.get("reason").and_then(|v| v.as_str()) == Some("exited");
                         let state_clone = session_state.clone();
                         tokio::spawn(async move {
                             let mut state = state_clone.write().await;
-                            state.add_thread(thread_id as i32);
+                            if exited {
+                                state.remove_thread(thread_id as i32);
+                            } else {
+                                state.add_thread(thread_id as i32);
+                            }
                         });
                     }
                 }
             })
             .await;
+    }
 
-        // Use the DapClient's event-driven initialize_and_launch method with timeout
-        // This properly handles the 'initialized' event and configurationDone sequence
-        // Timeout: 7s (2s for init + 5s for launch, as per TIMEOUT_IMPLEMENTATION.md)
-        // Pass adapter type for language-specific workarounds (e.g., Ruby stopOnEntry fix)
-        let adapter_type = match self.language.as_str() {
-            "python" => Some("python"),
-            "ruby" => Some("ruby"),
-            "nodejs" => Some("nodejs"),
-            _ => None,
+    /// Log and continue if a breakpoint stop was for emulated logpoints only
+    ///
+    /// Returns whether it was; the stop is then never applied to the state.
+    /// If the thread can't be continued, the stop is reported after all.
+    async fn emulate_log_points(
+        state: &Arc<RwLock<SessionState>>,
+        client: &RwLock<DapClient>,
+        thread_id: i32,
+        hit_ids: &[i32],
+    ) -> bool {
+        if !state.read().await.has_log_points() {
+            return false;
+        }
+        let client = client.read().await;
+        if !client.emulates_log_points().await {
+            return false;
+        }
+        let Some(top) = client.top_frame(thread_id).await.ok().flatten() else {
+            return false;
         };
+        let log_points = state
+            .read()
+            .await
+            .log_points_hit(hit_ids, frame_path(&top).map(|path| (path, top.line)));
+        if log_points.is_empty() {
+            return false;
+        }
 
-        // Collect pending breakpoints to pass to initialization
-        // They will be applied AFTER 'initialized' event, BEFORE configurationDone (correct DAP sequence)
-        let pending_breakpoints_map = {
-            let pending = self.pending_breakpoints.read().await;
-            let pending_count: usize = pending.values().map(|v| v.len()).sum();
-            info!(
-                "🔧 Passing {} pending breakpoint(s) to initialization (will be applied before configurationDone)",
-                pending_count
-            );
-            pending.clone()
-        };
+        let mut output = String::new();
+        for bp in &log_points {
+            let segments = log_points::parse(bp.log_message.as_deref().unwrap_or_default());
+            let mut values = Vec::new();
+            for expression in log_points::expressions(&segments) {
+                values.push(match client.evaluate(expression, Some(top.id)).await {
+                    Ok(value) => value,
+                    Err(e) => format!("<error: {}>", e),
+                });
+            }
+            output.push_str(&log_points::render(&segments, &values));
+            output.push('\n');
+        }
+        info!(
+            "📝 Emulated logpoint at {}:{} on thread {}",
+            log_points[0].source_path, log_points[0].line, thread_id
+        );
+        {
+            let mut state = state.write().await;
+            state.output_tail.push(&output);
+            state.output_log.push(output.as_bytes());
+        }
 
-        // Initialize and launch with pending breakpoints
-        // The DAP client will apply breakpoints after 'initialized' event, before configurationDone
-        client
-            .initialize_and_launch_with_timeout_and_pending(
-                adapter_id,
-                launch_args,
-                adapter_type,
-                pending_breakpoints_map.clone(),
-            )
-            .await?;
+        match client.continue_execution(thread_id).await {
+            Ok(_) => true,
+            Err(e) => {
+                warn!("⚠️  Could not continue past an emulated logpoint: {}", e);
+                false
+            }
+        }
+    }
 
-        // Clear pending breakpoints since they've been applied
+    /// Continue from an uncaught exception or panic stop of a session that
+    /// lets the program terminate (see `set_on_uncaught`)
+    ///
+    /// Returns whether it did; the stop is then never applied to the state.
+    async fn continue_uncaught(
+        state: &Arc<RwLock<SessionState>>,
+        client: &RwLock<DapClient>,
+        thread_id: i32,
+        reason: &str,
+    ) -> bool {
         {
-            let mut pending = self.pending_breakpoints.write().await;
-            if !pending.is_empty() {
+            let state = state.read().await;
+            let unrequested = match reason {
+                "panic" => true,
+                "exception" => state.exception_breakpoints.is_empty(),
+                _ => false,
+            };
+            if !state.terminate_on_uncaught || !unrequested {
+                return false;
+            }
+        }
+        match client.read().await.continue_execution(thread_id).await {
+            Ok(_) => {
                 info!(
-                    "✅ Clearing {} applied pending breakpoint(s)",
-                    pending.len()
+                    "💥 Letting the program terminate on its uncaught {} (thread {})",
+                    reason, thread_id
                 );
-                pending.clear();
+                true
+            }
+            Err(e) => {
+                warn!("⚠️  Could not continue past an uncaught {}: {}", reason, e);
+                false
             }
         }
+    }
 
-        // Pending breakpoints have been applied during initialization
-        // (after 'initialized' event, before configurationDone - the correct DAP sequence)
-        // This fixes the Go debugging issue where breakpoints were being applied too late
+    /// Check a breakpoint stop against the change watches (see `change_watch`)
+    ///
+    /// None when the stop wasn't a watch's. A server-side watch whose value
+    /// didn't change is continued past (`Observation::Continue`; the stop is
+    /// then never applied to the state) unless another breakpoint was hit
+    /// too. An adapter-side watch only stops on a change; its old and new
+    /// value are read from the debuggee's stash.
+    async fn check_change_watches(
+        state: &Arc<RwLock<SessionState>>,
+        client: &RwLock<DapClient>,
+        thread_id: i32,
+        hit_ids: &[i32],
+    ) -> Option<Observation> {
+        if state.read().await.change_watches.is_empty() {
+            return None;
+        }
+        let client = client.read().await;
+        let top = client.top_frame(thread_id).await.ok()??;
+        let (index, only_watch) = state
+            .read()
+            .await
+            .change_watch_hit(hit_ids, frame_path(&top).map(|path| (path, top.line)))?;
+        let watch = state.read().await.change_watches[index].clone();
 
-        // DON'T manually set state to Running here!
-        // The DAP event handlers will update the state based on actual events:
-        // - 'stopped' event (if stopOnEntry=true) → Stopped state
-        // - 'continued' event → Running state
-        // - 'terminated'/'exited' events → Terminated state
-        //
-        // Setting Running here causes a race condition where we overwrite
-        // the Stopped state from the 'stopped' event handler.
-        //
-        // See: https://github.com/ruvnet/debugger_mcp/issues/stopOnEntry-race-condition
+        if watch.strategy == WatchStrategy::AdapterCondition {
+            let (old_expression, new_expression) = watch.stash?;
+            let mut values = Vec::new();
+            for expression in [old_expression, new_expression] {
+                values.push(
+                    client
+                        .evaluate(&expression, Some(top.id))
+                        .await
+                        .ok()
+                        .map(|value| change_watch::unquote(&value)),
+                );
+            }
+            let new_value = values.pop().flatten();
+            let old_value = values.pop().flatten();
+            let stop = state.write().await.change_watches[index].changed(old_value, new_value);
+            return Some(Observation::Stop(stop));
+        }
 
-        Ok(())
+        let value = client
+            .evaluate(&watch.expression, Some(top.id))
+            .await
+            .map(|value| crate::config::redact(&value))
+            .map_err(|e| e.to_string());
+        let observation = {
+            let mut state = state.write().await;
+            let observation = state.change_watches[index].observe(value);
+            if observation == Observation::Continue {
+                state.count_condition_failure(hit_ids, (&watch.source_path, watch.line));
+            }
+            observation
+        };
+        if observation != Observation::Continue {
+            return Some(observation);
+        }
+        if !only_watch {
+            return None;
+        }
+        match client.continue_execution(thread_id).await {
+            Ok(_) => Some(Observation::Continue),
+            Err(e) => {
+                warn!("⚠️  Could not continue past an unchanged watch: {}", e);
+                None
+            }
+        }
     }
 
-    /// Initialize and launch in the background, returning immediately
-    /// Updates state to indicate initialization status
-    pub async fn initialize_and_launch_async(
-        self: Arc<Self>,
-        adapter_id: String,
-        launch_args: serde_json::Value,
-    ) {
-        let session_id = self.id.clone();
-        info!(
-            "🚀 Starting async initialization for session {}",
-            session_id
-        );
-
-        // TEMPORARY HACK: Give the test time to set pending breakpoints
-        // before we collect them. This works around the race condition where:
-        // 1. We spawn this async task
-        // 2. We immediately collect pending breakpoints (empty)
-        // 3. Test sets breakpoints (too late!)
-        //
-        // TODO: Replace with proper solution (dynamic callback or synchronous init)
-        tokio::time::sleep(tokio::time::Duration::from_millis(200)).await;
-
-        match self.initialize_and_launch(&adapter_id, launch_args).await {
-            Ok(()) => {
+    /// Check the callers of a stop of caller-constrained breakpoints (see
+    /// `caller_filter`), continuing when none matches
+    ///
+    /// None when the stop isn't one of those breakpoints alone, or the
+    /// callers couldn't be loaded: the stop is reported as it is.
+    async fn check_callers(
+        state: &Arc<RwLock<SessionState>>,
+        client: &RwLock<DapClient>,
+        thread_id: i32,
+        hit_ids: &[i32],
+    ) -> Option<CallerCheck> {
+        let constraints = state.read().await.caller_constraints(hit_ids)?;
+        let depth = constraints.iter().map(|(_, c)| c.depth).max()?;
+        let client = client.read().await;
+        let frames = match client
+            .stack_trace_page(thread_id, 0, depth as i32 + 1)
+            .await
+        {
+            Ok((frames, _)) => frames,
+            Err(e) => {
+                warn!("⚠️  Could not load the callers of a breakpoint stop: {}", e);
+                return None;
+            }
+        };
+        let check = {
+            let mut state = state.write().await;
+            let check = caller_filter::check(&constraints, &frames, state.caller_skips);
+            if check == CallerCheck::Continue {
+                state.caller_skips += 1;
+                let top = frames.first().and_then(|f| Some((frame_path(f)?, f.line)));
+                state.count_condition_failure(hit_ids, top.unwrap_or_default());
+            }
+            check
+        };
+        if check != CallerCheck::Continue {
+            return Some(check);
+        }
+        match client.continue_execution(thread_id).await {
+            Ok(_) => {
                 info!(
-                    "✅ Async initialization completed successfully for session {}",
-                    session_id
+                    "⏭️  Continued past breakpoints {:?}: no caller matched on thread {}",
+                    hit_ids, thread_id
                 );
+                Some(CallerCheck::Continue)
             }
             Err(e) => {
-                info!(
-                    "❌ Async initialization failed for session {}: {}",
-                    session_id, e
-                );
-                let mut state = self.state.write().await;
-                state.set_state(DebugState::Failed {
-                    error: format!("Initialization failed: {}", e),
-                });
+                warn!("⚠️  Could not continue past a caller mismatch: {}", e);
+                None
             }
         }
     }
 
-    // Deprecated: Use initialize_and_launch instead
-    // Kept for backward compatibility
-    pub async fn initialize(&self, adapter_id: &str) -> Result<()> {
-        let mut state = self.state.write().await;
-        state.set_state(DebugState::Initializing);
+    /// Continue if a breakpoint stop was for unsubscribed breakpoints only
+    /// (with `autoContinueUnsubscribed`; see `subscription`)
+    ///
+    /// Returns whether it was; the stop is then never applied to the state.
+    /// The state stays locked until the continue is sent, so a step or pause
+    /// (`expecting_stop`) goes out either before the check, which then
+    /// reports the stop, or after the continue.
+    async fn continue_unsubscribed(
+        state: &Arc<RwLock<SessionState>>,
+        client: &RwLock<DapClient>,
+        thread_id: i32,
+        hit_ids: &[i32],
+    ) -> bool {
+        let state = state.write().await;
+        if !state.skips_stop(hit_ids) {
+            return false;
+        }
+        let result = client.read().await.continue_execution(thread_id).await;
         drop(state);
+        match result {
+            Ok(_) => {
+                info!(
+                    "⏭️  Continued past unsubscribed breakpoints {:?} on thread {}",
+                    hit_ids, thread_id
+                );
+                true
+            }
+            Err(e) => {
+                warn!(
+                    "⚠️  Could not continue past unsubscribed breakpoints: {}",
+                    e
+                );
+                false
+            }
+        }
+    }
 
-        let client_arc = self.get_debug_client().await;
-        let client = client_arc.read().await;
-        client.initialize(adapter_id).await?;
+    /// The top frame of a stopped thread, loaded alone where the adapter
+    /// said it pages stacks; None elsewhere, where it would take loading the
+    /// whole stack (see `stack`)
+    async fn load_top_frame(client: &RwLock<DapClient>, thread_id: i32) -> Option<StackPage> {
+        if !client.read().await.pages_stack_traces(false).await {
+            return None;
+        }
+        Self::read_top_frame(client, thread_id).await
+    }
+
+    /// The top frame of a stopped thread, whether the adapter pages stacks
+    /// or sends them whole
+    async fn read_top_frame(client: &RwLock<DapClient>, thread_id: i32) -> Option<StackPage> {
+        let client = client.read().await;
+        match tokio::time::timeout(STOP_FRAME_TIMEOUT, client.stack_trace_page(thread_id, 0, 1))
+            .await
+        {
+            Ok(Ok((frames, total_frames))) => Some(StackPage::new(frames, 0, 1, total_frames)),
+            Ok(Err(e)) => {
+                warn!("⚠️  Could not load the top frame of the stop: {}", e);
+                None
+            }
+            Err(_) => {
+                warn!(
+                    "⚠️  Top frame of the stop not loaded within {:?}",
+                    STOP_FRAME_TIMEOUT
+                );
+                None
+            }
+        }
+    }
+
+    /// Continue if a breakpoint stop is one `debugger_continue_past` skips
+    ///
+    /// Returns whether it was; the stop is then never applied to the state.
+    async fn continue_past_hit(
+        state: &Arc<RwLock<SessionState>>,
+        client: &RwLock<DapClient>,
+        thread_id: i32,
+        hit_ids: &[i32],
+    ) -> bool {
+        // Locked until the continue is sent, as in `continue_unsubscribed`
+        let mut state = state.write().await;
+        if !state.skips_past(hit_ids) {
+            return false;
+        }
+        let result = client.read().await.continue_execution(thread_id).await;
+        drop(state);
+        match result {
+            Ok(_) => {
+                info!(
+                    "⏭️  Continued past breakpoint {:?} on thread {}",
+                    hit_ids, thread_id
+                );
+                true
+            }
+            Err(e) => {
+                warn!("⚠️  Could not continue past breakpoint: {}", e);
+                false
+            }
+        }
+    }
+
+    /// Remove the temporary breakpoints a stop was for, and re-send their files
+    ///
+    /// Without `hit_ids` the stop is matched by the top frame's location, and
+    /// only for breakpoint stops: a step that ends on the line is no hit.
+    async fn remove_fired_temporary_breakpoints(
+        state: &Arc<RwLock<SessionState>>,
+        client: &RwLock<DapClient>,
+        thread_id: i32,
+        reason: &str,
+        hit_ids: &[i32],
+    ) -> Vec<Breakpoint> {
+        if !state.read().await.has_temporary_breakpoints()
+            || (hit_ids.is_empty() && reason != "breakpoint")
+        {
+            return Vec::new();
+        }
+        let client = client.read().await;
+        let top = if hit_ids.is_empty() {
+            let Some(top) = client.top_frame(thread_id).await.ok().flatten() else {
+                return Vec::new();
+            };
+            Some(top)
+        } else {
+            None
+        };
+        let location = top
+            .as_ref()
+            .and_then(|top| frame_path(top).map(|path| (path, top.line)));
+        let fired = state
+            .write()
+            .await
+            .remove_temporary_breakpoints_hit(hit_ids, location);
+
+        let mut paths: Vec<&str> = fired.iter().map(|bp| bp.source_path.as_str()).collect();
+        paths.sort_unstable();
+        paths.dedup();
+        for path in paths {
+            match Self::send_source_breakpoints(state, &client, path).await {
+                Ok(()) => info!("🗑️  Removed temporary breakpoint in {}", path),
+                Err(e) => warn!(
+                    "⚠️  Could not remove temporary breakpoint in {}: {}",
+                    path, e
+                ),
+            }
+        }
+        fired
+    }
+
+    /// Whether a stop is the entry breakpoint's; disarms the entry and
+    /// removes a function entry breakpoint (line ones are temporary, so
+    /// `fired` already holds them)
+    async fn take_entry_stop(
+        state: &Arc<RwLock<SessionState>>,
+        client: &RwLock<DapClient>,
+        thread_id: i32,
+        reason: &str,
+        fired: &[Breakpoint],
+    ) -> bool {
+        let Some(entry) = state.read().await.entry_breakpoint.clone() else {
+            return false;
+        };
+        let reached = match &entry {
+            EntryBreakpoint::Line { path, line } => fired
+                .iter()
+                .any(|bp| &bp.source_path == path && bp.line == *line),
+            EntryBreakpoint::Function(name) => {
+                matches!(reason, "breakpoint" | "function breakpoint")
+                    && client
+                        .read()
+                        .await
+                        .top_frame(thread_id)
+                        .await
+                        .ok()
+                        .flatten()
+                        .is_some_and(|top| &top.name == name)
+            }
+        };
+        if !reached {
+            return false;
+        }
+
+        state.write().await.entry_breakpoint = None;
+        if let EntryBreakpoint::Function(name) = &entry {
+            state.write().await.remove_function_breakpoint(name);
+            if let Err(e) = Self::send_function_breakpoints(state, &*client.read().await).await {
+                warn!("⚠️  Could not remove entry breakpoint on {}: {}", name, e);
+            }
+        }
+        info!("🚪 Stopped at the entry point ({:?})", entry);
+        true
+    }
+
+    /// Continue from an entry stop of a session started with
+    /// `autoContinueFromEntry`, unless a breakpoint is on the entry line
+    ///
+    /// The program sits before the line's code, so continuing would run past
+    /// such a breakpoint without stopping; the stop is kept as its hit
+    /// instead. None when the session doesn't auto-continue, or continuing
+    /// failed and the entry stop stands.
+    async fn continue_from_entry(
+        state: &Arc<RwLock<SessionState>>,
+        client: &RwLock<DapClient>,
+        thread_id: i32,
+        hit_ids: &[i32],
+    ) -> Option<EntryStop> {
+        if !state.read().await.auto_continue_entry {
+            return None;
+        }
+        let client = client.read().await;
+        let top = client.top_frame(thread_id).await.ok().flatten();
+        let location = top
+            .as_ref()
+            .and_then(|top| frame_path(top).map(|path| (path, top.line)));
+        let breakpoints = state.read().await.breakpoints_at_entry(hit_ids, location);
+        if !breakpoints.is_empty() {
+            info!(
+                "🚪 Not continuing from the entry stop: breakpoints {:?} are on the entry line",
+                breakpoints
+            );
+            return Some(EntryStop::Breakpoints(breakpoints));
+        }
+        match client.continue_execution(thread_id).await {
+            Ok(_) => {
+                info!("🚪 Continued from the entry stop on thread {}", thread_id);
+                Some(EntryStop::Continued)
+            }
+            Err(e) => {
+                warn!("⚠️  Could not continue from the entry stop: {}", e);
+                None
+            }
+        }
+    }
+
+    /// Initialize and launch using the proper DAP sequence
+    /// This combines initialize and launch into one atomic operation
+    pub async fn initialize_and_launch(
+        &self,
+        adapter_id: &str,
+        launch_args: serde_json::Value,
+    ) -> Result<()> {
+        let launch_sources = self.launch_snapshot().await;
+        {
+            let mut state = self.state.write().await;
+            state.set_state(DebugState::Initializing);
+            state.transcript.record_launch(adapter_id, &launch_args);
+            state.launch_sources = launch_sources;
+            state.expressions = launch_args["expressions"].as_str().map(str::to_string);
+        }
+        *self.launch_arguments.write().await = Some(launch_args.clone());
+        self.launch_phases.enter(LaunchPhase::Initializing);
+        // With these, the start ends at the program's first stop
+        let stops_first = launch_args["stopOnEntry"] == true
+            || self.state.read().await.entry_breakpoint.is_some();
+
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+
+        // Register event handlers BEFORE launching to capture all state changes
+        info!("📡 Registering DAP event handlers for session state tracking");
+
+        self.register_state_handlers(&client).await;
+
+        // Use the DapClient's event-driven initialize_and_launch method with timeout
+        // This properly handles the 'initialized' event and configurationDone sequence
+        // Timeout: 7s (2s for init + 5s for launch, as per TIMEOUT_IMPLEMENTATION.md)
+        // Pass adapter type for language-specific workarounds (e.g., Ruby stopOnEntry fix)
+        let adapter_type = match self.language.as_str() {
+            "python" => Some("python"),
+            "ruby" => Some("ruby"),
+            "nodejs" => Some("nodejs"),
+            _ => None,
+        };
+
+        // Collect pending breakpoints to pass to initialization
+        // They will be applied AFTER 'initialized' event, BEFORE configurationDone (correct DAP sequence)
+        let pending_breakpoints_map = {
+            let pending = self.pending_breakpoints.read().await;
+            let pending_count: usize = pending.values().map(|v| v.len()).sum();
+            info!(
+                "🔧 Passing {} pending breakpoint(s) to initialization (will be applied before configurationDone)",
+                pending_count
+            );
+            pending.clone()
+        };
+
+        // Function breakpoints set before the launch (the entry breakpoint)
+        let pending_function_breakpoints = self
+            .state
+            .read()
+            .await
+            .function_breakpoints
+            .iter()
+            .map(to_function_breakpoint)
+            .collect();
+
+        // Initialize and launch with pending breakpoints
+        // The DAP client will apply breakpoints after 'initialized' event, before configurationDone
+        client
+            .initialize_and_launch_with_timeout_and_pending(
+                adapter_id,
+                launch_args,
+                adapter_type,
+                pending_breakpoints_map.clone(),
+                pending_function_breakpoints,
+            )
+            .await?;
+        self.flush_output().await;
+        self.state.write().await.launched = true;
+        if stops_first {
+            self.launch_phases.enter(LaunchPhase::WaitingFirstStop);
+        } else {
+            self.launch_phases.finish();
+        }
+
+        // Clear pending breakpoints since they've been applied
+        {
+            let mut pending = self.pending_breakpoints.write().await;
+            if !pending.is_empty() {
+                info!(
+                    "✅ Clearing {} applied pending breakpoint(s)",
+                    pending.len()
+                );
+                pending.clear();
+            }
+        }
+
+        // Pending breakpoints have been applied during initialization
+        // (after 'initialized' event, before configurationDone - the correct DAP sequence)
+        // This fixes the Go debugging issue where breakpoints were being applied too late
+
+        // DON'T manually set state to Running here!
+        // The DAP event handlers will update the state based on actual events:
+        // - 'stopped' event (if stopOnEntry=true) → Stopped state
+        // - 'continued' event → Running state
+        // - 'terminated'/'exited' events → Terminated state
+        //
+        // Setting Running here causes a race condition where we overwrite
+        // the Stopped state from the 'stopped' event handler.
+        //
+        // See: https://github.com/ruvnet/debugger_mcp/issues/stopOnEntry-race-condition
+
+        Ok(())
+    }
+
+    /// Initialize and launch in the background, returning immediately
+    /// Updates state to indicate initialization status
+    pub async fn initialize_and_launch_async(
+        self: Arc<Self>,
+        adapter_id: String,
+        launch_args: serde_json::Value,
+        gate: Option<LaunchGate>,
+    ) {
+        let session_id = self.id.clone();
+        info!(
+            "🚀 Starting async initialization for session {}",
+            session_id
+        );
+
+        match gate {
+            // The start sets the session up (entry breakpoint, imported
+            // breakpoints, auto-continue) before letting it launch
+            Some(gate) => gate.opened().await,
+            // TEMPORARY HACK: Give the test time to set pending breakpoints
+            // before we collect them. This works around the race condition where:
+            // 1. We spawn this async task
+            // 2. We immediately collect pending breakpoints (empty)
+            // 3. Test sets breakpoints (too late!)
+            //
+            // TODO: Replace with proper solution (dynamic callback or synchronous init)
+            None => tokio::time::sleep(tokio::time::Duration::from_millis(200)).await,
+        }
+
+        match self.initialize_and_launch(&adapter_id, launch_args).await {
+            Ok(()) => {
+                info!(
+                    "✅ Async initialization completed successfully for session {}",
+                    session_id
+                );
+                self.metrics.record_startup(self.metrics.age());
+                self.check_build_output().await;
+            }
+            Err(e) => {
+                let e = self.launch_phases.annotate(e);
+                self.launch_phases.finish();
+                info!(
+                    "❌ Async initialization failed for session {}: {}",
+                    session_id, e
+                );
+                // Nothing was built, or nothing will run it
+                self.set_build_dir(None).await;
+                self.flush_output().await;
+                let mut state = self.state.write().await;
+                let error = state.start_failure(&format!("Initialization failed: {}", e));
+                state.set_state(DebugState::Failed { error });
+            }
+        }
+    }
+
+    /// Hand the session the task running `initialize_and_launch_async`
+    pub fn set_init_task(&self, task: AbortHandle) {
+        *self.init_task.lock().unwrap() = Some(task);
+    }
+
+    /// Cancel a start still in progress
+    ///
+    /// Aborts the initialization and launch if it hasn't finished (a Go build
+    /// that is stuck, an adapter that never sends `initialized`) and marks
+    /// the session failed. Returns whether there was a start to cancel; the
+    /// adapter itself is torn down by `disconnect`.
+    pub async fn cancel_start(&self) -> bool {
+        let Some(task) = self.init_task.lock().unwrap().take() else {
+            return false;
+        };
+        if task.is_finished() {
+            return false;
+        }
+        task.abort();
+        self.launch_phases.finish();
+        self.start_cancelled.store(true, Ordering::SeqCst);
+        info!("🛑 Cancelled the start of session {}", self.id);
+
+        // Nothing will run what a cancelled build produced
+        self.set_build_dir(None).await;
+        let mut state = self.state.write().await;
+        state.set_state(DebugState::Failed {
+            error: "Cancelled: the session was disconnected before its program launched"
+                .to_string(),
+        });
+        true
+    }
+
+    /// Whether `cancel_start` cancelled the session's start
+    pub fn start_cancelled(&self) -> bool {
+        self.start_cancelled.load(Ordering::SeqCst)
+    }
+
+    /// Make the session read-only for good (see `dap::read_only`); done at
+    /// attach, before the first request is sent
+    pub async fn make_read_only(&self) {
+        self.read_only.store(true, Ordering::SeqCst);
+        self.get_debug_client().await.read().await.set_read_only();
+    }
+
+    pub fn is_read_only(&self) -> bool {
+        self.read_only.load(Ordering::SeqCst)
+    }
+
+    /// Fails in a read-only session, where `action` is blocked
+    pub fn check_writable(&self, action: &str) -> Result<()> {
+        if self.is_read_only() {
+            return Err(read_only::blocked(action));
+        }
+        Ok(())
+    }
+
+    /// Whether an evaluation runs without side effects: when asked to, and
+    /// always in a read-only session, which refuses it where the debugger
+    /// can't guarantee that
+    pub fn side_effect_free(&self, requested: bool) -> Result<bool> {
+        if !self.is_read_only() {
+            return Ok(requested);
+        }
+        crate::adapters::side_effect_free_context(&self.language).map_err(|_| {
+            read_only::blocked(&format!(
+                "Evaluating with the {} debugger (it may call functions; debugger_peek reads variables without evaluating)",
+                self.language
+            ))
+        })?;
+        Ok(true)
+    }
+
+    // Deprecated: Use initialize_and_launch instead
+    // Kept for backward compatibility
+    pub async fn initialize(&self, adapter_id: &str) -> Result<()> {
+        let mut state = self.state.write().await;
+        state.set_state(DebugState::Initializing);
+        drop(state);
+
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        client.initialize(adapter_id).await?;
 
         let mut state = self.state.write().await;
         state.set_state(DebugState::Initialized);
@@ -754,6 +1989,7 @@ impl DebugSession {
                         column: None,
                         condition: None,
                         hit_condition: None,
+                        log_message: None,
                     });
 
                 // Add to state for tracking
@@ -774,34 +2010,17 @@ impl DebugSession {
                     state.add_breakpoint(source_path.clone(), line);
                 }
 
-                // Set via DAP immediately
-                let source = Source {
-                    name: None,
-                    path: Some(source_path.clone()),
-                    source_reference: None,
-                };
-
-                let breakpoints = vec![SourceBreakpoint {
-                    line,
-                    column: None,
-                    condition: None,
-                    hit_condition: None,
-                }];
-
-                let client_arc = self.get_debug_client().await;
-                let client = client_arc.read().await;
-                let result = client.set_breakpoints(source, breakpoints).await?;
+                // Set via DAP immediately. setBreakpoints replaces all breakpoints
+                // for the source, so send every enabled breakpoint for the file.
+                self.sync_source_breakpoints(&source_path).await?;
 
-                // Update state with results
-                if let Some(bp) = result.first() {
-                    let mut state = self.state.write().await;
-                    if let Some(id) = bp.id {
-                        state.update_breakpoint(&source_path, line, id, bp.verified);
-                    }
-                    Ok(bp.verified)
-                } else {
-                    Ok(false)
-                }
+                let state = self.state.read().await;
+                Ok(state
+                    .get_breakpoints(&source_path)
+                    .iter()
+                    .find(|bp| bp.line == line)
+                    .map(|bp| bp.verified)
+                    .unwrap_or(false))
             }
             DebugState::Terminated | DebugState::Failed { .. } => Err(crate::Error::InvalidState(
                 format!("Cannot set breakpoint in state: {:?}", current_state),
@@ -809,231 +2028,6179 @@ impl DebugSession {
         }
     }
 
-    pub async fn continue_execution(&self) -> Result<()> {
-        let state = self.state.read().await;
-        let thread_id = state.threads.first().copied().unwrap_or(1);
-        drop(state);
-
-        let client_arc = self.get_debug_client().await;
-        let client = client_arc.read().await;
-        client.continue_execution(thread_id).await?;
-
-        let mut state = self.state.write().await;
-        state.set_state(DebugState::Running);
-
-        Ok(())
+    /// Set a logpoint: a breakpoint that logs `message` instead of stopping
+    ///
+    /// Replaces any breakpoint on the line. `{expression}` placeholders in the
+    /// message are replaced by their values. Adapters without logpoint
+    /// support get a plain breakpoint, and the session logs and continues
+    /// when it is hit (see `log_points`).
+    pub async fn set_log_point(
+        &self,
+        source_path: String,
+        line: i32,
+        message: String,
+    ) -> Result<bool> {
+        self.set_breakpoint_with(Breakpoint {
+            source_path,
+            line,
+            id: None,
+            verified: false,
+            enabled: true,
+            condition: None,
+            hit_condition: None,
+            log_message: Some(message),
+            temporary: false,
+            verified_line: None,
+            move_explanation: None,
+            caller: None,
+        })
+        .await
     }
 
-    pub async fn step_over(&self, thread_id: i32) -> Result<()> {
-        let client_arc = self.get_debug_client().await;
-        let client = client_arc.read().await;
-        client.next(thread_id).await?;
+    /// Set a breakpoint with options (condition, hit condition, logpoint
+    /// message, temporary), replacing any breakpoint on its line
+    pub async fn set_breakpoint_with(&self, bp: Breakpoint) -> Result<bool> {
+        let current_state = self.get_state().await;
+        if matches!(
+            current_state,
+            DebugState::Terminated | DebugState::Failed { .. }
+        ) {
+            return Err(crate::Error::InvalidState(format!(
+                "Cannot set breakpoint in state: {:?}",
+                current_state
+            )));
+        }
 
-        // State will be updated by 'stopped' event handler when step completes
-        Ok(())
+        let (source_path, line) = (bp.source_path.clone(), bp.line);
+        self.state.write().await.insert_breakpoint(bp);
+        self.apply_line_breakpoints(current_state, &source_path, line)
+            .await
     }
 
-    pub async fn step_into(&self, thread_id: i32) -> Result<()> {
-        let client_arc = self.get_debug_client().await;
-        let client = client_arc.read().await;
-        client.step_in(thread_id).await?;
+    /// Add a conditional breakpoint to a line, next to the conditional
+    /// breakpoints already there (see `shared_line`)
+    ///
+    /// One with the same condition is replaced. Fails if `bp` or a
+    /// breakpoint already on the line isn't a plain conditional one.
+    pub async fn add_shared_breakpoint(&self, bp: Breakpoint) -> Result<bool> {
+        let current_state = self.get_state().await;
+        if matches!(
+            current_state,
+            DebugState::Terminated | DebugState::Failed { .. }
+        ) {
+            return Err(crate::Error::InvalidState(format!(
+                "Cannot set breakpoint in state: {:?}",
+                current_state
+            )));
+        }
 
-        // State will be updated by 'stopped' event handler when step completes
-        Ok(())
+        shared_line::check_shareable(&bp)?;
+        let (source_path, line) = (bp.source_path.clone(), bp.line);
+        {
+            let mut state = self.state.write().await;
+            for existing in state
+                .get_breakpoints(&source_path)
+                .iter()
+                .filter(|b| b.line == line)
+            {
+                shared_line::check_shareable(existing)?;
+            }
+            state.insert_shared_breakpoint(bp);
+        }
+        self.apply_line_breakpoints(current_state, &source_path, line)
+            .await
     }
 
-    pub async fn step_out(&self, thread_id: i32) -> Result<()> {
-        let client_arc = self.get_debug_client().await;
+    /// Remove the breakpoint on a line with `condition`, or all of the
+    /// line's breakpoints when `condition` is None; the others on the line
+    /// stay. Returns how many were removed.
+    pub async fn remove_breakpoint(
+        &self,
+        source_path: &str,
+        line: i32,
+        condition: Option<&str>,
+    ) -> Result<usize> {
+        let current_state = self.get_state().await;
+        if matches!(
+            current_state,
+            DebugState::Terminated | DebugState::Failed { .. }
+        ) {
+            return Err(crate::Error::InvalidState(format!(
+                "Cannot remove breakpoint in state: {:?}",
+                current_state
+            )));
+        }
+
+        let removed = self
+            .state
+            .write()
+            .await
+            .remove_breakpoint(source_path, line, condition);
+        if removed == 0 {
+            return Err(crate::Error::InvalidRequest(match condition {
+                Some(condition) => format!(
+                    "No breakpoint at {}:{} with condition '{}'",
+                    source_path, line, condition
+                ),
+                None => format!("No breakpoint at {}:{}", source_path, line),
+            }));
+        }
+        self.apply_line_breakpoints(current_state, source_path, line)
+            .await?;
+        Ok(removed)
+    }
+
+    /// Send a file's breakpoints after those on `line` changed, or queue
+    /// the line's breakpoints while the session is still initializing;
+    /// whether a breakpoint on the line is verified
+    async fn apply_line_breakpoints(
+        &self,
+        current_state: DebugState,
+        source_path: &str,
+        line: i32,
+    ) -> Result<bool> {
+        if matches!(
+            current_state,
+            DebugState::NotStarted | DebugState::Initializing
+        ) {
+            // Same as set_breakpoint: applied once initialization completes
+            let on_line: Vec<Breakpoint> = self
+                .state
+                .read()
+                .await
+                .get_enabled_breakpoints(source_path)
+                .into_iter()
+                .filter(|bp| bp.line == line)
+                .collect();
+            let mut pending = self.pending_breakpoints.write().await;
+            let file_pending = pending.entry(source_path.to_string()).or_default();
+            file_pending.retain(|p| p.line != line);
+            if !on_line.is_empty() {
+                let group: Vec<&Breakpoint> = on_line.iter().collect();
+                let expressions = self.state.read().await.expressions.clone();
+                let or = shared_line::or_operator(Some(&self.language), expressions.as_deref());
+                file_pending.push(shared_line::combine(&group, or));
+            }
+            return Ok(true);
+        }
+
+        self.sync_source_breakpoints(source_path).await?;
+        let state = self.state.read().await;
+        Ok(state
+            .get_breakpoints(source_path)
+            .iter()
+            .any(|bp| bp.line == line && bp.verified))
+    }
+
+    /// Whether logpoints are emulated by the session rather than the adapter
+    pub async fn log_points_emulated(&self) -> bool {
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        client.emulates_log_points().await
+    }
+
+    /// Enable or disable a breakpoint without forgetting it
+    ///
+    /// DAP has no per-breakpoint enable flag, so disabled breakpoints are kept in
+    /// session state and simply omitted when re-sending `setBreakpoints` for the file.
+    pub async fn set_breakpoint_enabled(
+        &self,
+        breakpoint_id: i32,
+        enabled: bool,
+    ) -> Result<Breakpoint> {
+        let current_state = self.get_state().await;
+        if !matches!(
+            current_state,
+            DebugState::Running
+                | DebugState::Stopped { .. }
+                | DebugState::Initialized
+                | DebugState::Launching
+        ) {
+            return Err(crate::Error::InvalidState(format!(
+                "Cannot change breakpoint in state: {:?}",
+                current_state
+            )));
+        }
+
+        let source_path = {
+            let mut state = self.state.write().await;
+            state
+                .set_breakpoint_enabled(breakpoint_id, enabled)
+                .ok_or_else(|| {
+                    crate::Error::InvalidRequest(format!("Breakpoint {} not found", breakpoint_id))
+                })?
+        };
+
+        info!(
+            "{} breakpoint {} in {}",
+            if enabled { "Enabling" } else { "Disabling" },
+            breakpoint_id,
+            source_path
+        );
+
+        // The line is the stable key: re-enabling may give the breakpoint a new adapter id
+        let line = {
+            let state = self.state.read().await;
+            state
+                .get_breakpoints(&source_path)
+                .into_iter()
+                .find(|bp| bp.id == Some(breakpoint_id))
+                .map(|bp| bp.line)
+        };
+
+        self.sync_source_breakpoints(&source_path).await?;
+
+        let state = self.state.read().await;
+        state
+            .get_breakpoints(&source_path)
+            .into_iter()
+            .find(|bp| Some(bp.line) == line && bp.enabled == enabled)
+            .ok_or_else(|| {
+                crate::Error::Internal(format!("Breakpoint {} lost during sync", breakpoint_id))
+            })
+    }
+
+    /// Re-send all enabled breakpoints for a source and record the adapter's results
+    async fn sync_source_breakpoints(&self, source_path: &str) -> Result<()> {
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        Self::send_source_breakpoints(&self.state, &client, source_path).await
+    }
+
+    /// `sync_source_breakpoints` for event handlers, which hold no session
+    async fn send_source_breakpoints(
+        state: &RwLock<SessionState>,
+        client: &DapClient,
+        source_path: &str,
+    ) -> Result<()> {
+        let (enabled, verify_source, launch_hash, expressions) = {
+            let state = state.read().await;
+            let launch_hash = state.launched_at().map(|_| {
+                state
+                    .launch_sources
+                    .get(&source_check::snapshot_key(Path::new(source_path)))
+                    .cloned()
+            });
+            (
+                state.get_enabled_breakpoints(source_path),
+                state.verify_source,
+                launch_hash,
+                state.expressions.clone(),
+            )
+        };
+
+        let checksums = if verify_source {
+            let supported = client
+                .capabilities()
+                .await
+                .and_then(|caps| caps.supported_checksum_algorithms)
+                .unwrap_or_default();
+            source_check::checksums(Path::new(source_path), &supported)
+        } else {
+            None
+        };
+        let source = Source {
+            name: None,
+            path: Some(source_path.to_string()),
+            source_reference: None,
+            checksums,
+        };
+
+        // Breakpoints sharing a line go out as one (see `shared_line`)
+        let lines = shared_line::by_line(&enabled);
+        let or =
+            shared_line::or_operator(client.adapter_id().await.as_deref(), expressions.as_deref());
+        let breakpoints = client
+            .native_breakpoints(
+                lines
+                    .iter()
+                    .map(|group| shared_line::combine(group, or))
+                    .collect(),
+            )
+            .await;
+        let result = client.set_breakpoints(source, breakpoints).await?;
+
+        // Adapter returns breakpoints in the same order they were sent
+        let mut moves = Vec::new();
+        for (bp, dap_bp) in lines.iter().map(|group| group[0]).zip(result.iter()) {
+            moves.push(match dap_bp.line {
+                Some(actual) if dap_bp.verified && actual != bp.line => {
+                    let explanation = Self::explain_breakpoint_move(
+                        client,
+                        source_path,
+                        bp.line,
+                        actual,
+                        dap_bp.message.as_deref(),
+                    )
+                    .await;
+                    info!(
+                        "↪️  Breakpoint {}:{}: {}",
+                        source_path, bp.line, explanation
+                    );
+                    Some((actual, explanation))
+                }
+                _ => None,
+            });
+        }
+
+        // Sources missing from the launch snapshot are hashed now, so later
+        // edits are caught
+        let mut first_hash = None;
+        let mut warning = match launch_hash.filter(|_| verify_source) {
+            Some(Some(hash)) => source_check::stale_source_warning(Path::new(source_path), &hash),
+            Some(None) => {
+                first_hash = source_check::content_hash(Path::new(source_path));
+                None
+            }
+            None => None,
+        };
+        if source_maps::is_typescript(source_path) {
+            if let Some(map_warning) = source_maps::breakpoint_warning(Path::new(source_path)) {
+                warning = Some(match warning {
+                    Some(stale) => format!("{}; {}", stale, map_warning),
+                    None => map_warning,
+                });
+            }
+        }
+
+        let mut state = state.write().await;
+        if let Some(hash) = first_hash {
+            state
+                .launch_sources
+                .entry(source_check::snapshot_key(Path::new(source_path)))
+                .or_insert(hash);
+        }
+        match warning {
+            Some(warning) => {
+                warn!("⚠️  {}", warning);
+                state
+                    .source_warnings
+                    .insert(source_path.to_string(), warning);
+            }
+            None => {
+                state.source_warnings.remove(source_path);
+            }
+        }
+        for ((bp, dap_bp), moved) in lines
+            .iter()
+            .map(|group| group[0])
+            .zip(result.iter())
+            .zip(moves)
+        {
+            if let Some(id) = dap_bp.id {
+                state.update_breakpoint(source_path, bp.line, id, dap_bp.verified);
+            }
+            state.record_breakpoint_move(source_path, bp.line, moved);
+        }
+
+        Ok(())
+    }
+
+    /// Why the adapter put a breakpoint requested on line `requested` on
+    /// line `actual`; see `breakpoint_move`
+    async fn explain_breakpoint_move(
+        client: &DapClient,
+        source_path: &str,
+        requested: i32,
+        actual: i32,
+        adapter_message: Option<&str>,
+    ) -> String {
+        let text = std::fs::read_to_string(source_path).ok();
+        let requested_text = text.as_deref().and_then(|text| {
+            let index = usize::try_from(requested - 1).ok()?;
+            text.lines().nth(index)
+        });
+
+        let source = Source {
+            name: None,
+            path: Some(source_path.to_string()),
+            source_reference: None,
+            checksums: None,
+        };
+        // Adapters without breakpointLocations fail this; the text suffices
+        let locations = client
+            .breakpoint_locations(source, requested.min(actual), requested.max(actual))
+            .await
+            .ok();
+
+        breakpoint_move::explain(
+            requested,
+            actual,
+            requested_text,
+            locations.as_deref(),
+            adapter_message,
+        )
+    }
+
+    /// Set an instruction breakpoint, replacing any at the same address
+    ///
+    /// `instruction_reference` is a memory reference reported by the adapter
+    /// (e.g. a frame's `instructionPointerReference`) and `offset` a byte
+    /// offset from it. The breakpoint is tracked with the program's current
+    /// modification time so a restart against a rebuilt program can tell its
+    /// address may no longer be valid.
+    pub async fn set_instruction_breakpoint(
+        &self,
+        instruction_reference: String,
+        offset: Option<i64>,
+        condition: Option<String>,
+        hit_condition: Option<String>,
+    ) -> Result<InstructionBreakpoint> {
+        let current_state = self.get_state().await;
+        if !matches!(
+            current_state,
+            DebugState::Running
+                | DebugState::Stopped { .. }
+                | DebugState::Initialized
+                | DebugState::Launching
+        ) {
+            return Err(crate::Error::InvalidState(format!(
+                "Cannot set instruction breakpoint in state: {:?}",
+                current_state
+            )));
+        }
+
+        let previous = {
+            let mut state = self.state.write().await;
+            let previous = state
+                .find_instruction_breakpoint(&instruction_reference, offset)
+                .cloned();
+            state.insert_instruction_breakpoint(InstructionBreakpoint {
+                instruction_reference: instruction_reference.clone(),
+                offset,
+                condition,
+                hit_condition,
+                id: None,
+                verified: false,
+                message: None,
+                program_modified: program_modified(&self.program),
+                stale: false,
+            });
+            previous
+        };
+
+        // setInstructionBreakpoints replaces the whole set, so send every armed one
+        if let Err(e) = self.sync_instruction_breakpoints().await {
+            let mut state = self.state.write().await;
+            state.remove_instruction_breakpoint(&instruction_reference, offset);
+            if let Some(previous) = previous {
+                state.insert_instruction_breakpoint(previous);
+            }
+            return Err(e);
+        }
+
+        let state = self.state.read().await;
+        state
+            .find_instruction_breakpoint(&instruction_reference, offset)
+            .cloned()
+            .ok_or_else(|| crate::Error::Internal("Instruction breakpoint vanished".to_string()))
+    }
+
+    /// Remove the instruction breakpoint at an address, returning whether there was one
+    pub async fn remove_instruction_breakpoint(
+        &self,
+        instruction_reference: &str,
+        offset: Option<i64>,
+    ) -> Result<bool> {
+        let removed = self
+            .state
+            .write()
+            .await
+            .remove_instruction_breakpoint(instruction_reference, offset);
+        if removed {
+            self.sync_instruction_breakpoints().await?;
+        }
+        Ok(removed)
+    }
+
+    /// Set a breakpoint on entry to a function, replacing any on the same function
+    ///
+    /// Only sent if the adapter advertises `supportsFunctionBreakpoints` (and
+    /// the conditional capabilities for a condition or hit condition);
+    /// otherwise this fails with `UnsupportedCapability` before anything is
+    /// recorded.
+    ///
+    /// The name is put in the debugger's syntax first (see
+    /// `adapters::function_breakpoint_name`). Where the debugger can't
+    /// resolve it and never will (Go), the breakpoint isn't kept and this
+    /// fails naming the function.
+    pub async fn set_function_breakpoint(
+        &self,
+        name: String,
+        condition: Option<String>,
+        hit_condition: Option<String>,
+    ) -> Result<FunctionBreakpoint> {
+        let name = crate::adapters::function_breakpoint_name(&self.language, &name)?;
+        let current_state = self.get_state().await;
+        if !matches!(
+            current_state,
+            DebugState::Running
+                | DebugState::Stopped { .. }
+                | DebugState::Initialized
+                | DebugState::Launching
+        ) {
+            return Err(crate::Error::InvalidState(format!(
+                "Cannot set function breakpoint in state: {:?}",
+                current_state
+            )));
+        }
+
+        let bp = crate::dap::types::FunctionBreakpoint {
+            name: name.clone(),
+            condition: condition.clone(),
+            hit_condition: hit_condition.clone(),
+        };
+        let arguments = serde_json::json!({ "breakpoints": [bp] });
+        let client_arc = self.get_debug_client().await;
+        client_arc
+            .read()
+            .await
+            .check_capabilities("setFunctionBreakpoints", Some(&arguments))
+            .await?;
+
+        let previous = {
+            let mut state = self.state.write().await;
+            let previous = state
+                .function_breakpoints
+                .iter()
+                .find(|b| b.name == name)
+                .cloned();
+            state.insert_function_breakpoint(FunctionBreakpoint {
+                name: name.clone(),
+                condition,
+                hit_condition,
+                id: None,
+                verified: false,
+                message: None,
+            });
+            previous
+        };
+
+        // setFunctionBreakpoints replaces the whole set, so send every one
+        if let Err(e) = self.sync_function_breakpoints().await {
+            self.restore_function_breakpoint(&name, previous).await;
+            return Err(e);
+        }
+
+        let bp = self
+            .state
+            .read()
+            .await
+            .function_breakpoints
+            .iter()
+            .find(|b| b.name == name)
+            .cloned()
+            .ok_or_else(|| crate::Error::Internal("Function breakpoint vanished".to_string()))?;
+        if !bp.verified {
+            if let Some(unresolved) =
+                crate::adapters::unresolved_function(&self.language, &name, bp.message.as_deref())
+            {
+                self.restore_function_breakpoint(&name, previous).await;
+                self.sync_function_breakpoints().await?;
+                return Err(unresolved);
+            }
+        }
+        Ok(bp)
+    }
+
+    /// Put back the function breakpoint a failed `set_function_breakpoint`
+    /// replaced (or drop the one it added)
+    async fn restore_function_breakpoint(&self, name: &str, previous: Option<FunctionBreakpoint>) {
+        let mut state = self.state.write().await;
+        state.remove_function_breakpoint(name);
+        if let Some(previous) = previous {
+            state.insert_function_breakpoint(previous);
+        }
+    }
+
+    /// Remove the breakpoint on a function, returning whether there was one
+    pub async fn remove_function_breakpoint(&self, name: &str) -> Result<bool> {
+        let name = crate::adapters::function_breakpoint_name(&self.language, name)?;
+        let removed = self.state.write().await.remove_function_breakpoint(&name);
+        if removed {
+            self.sync_function_breakpoints().await?;
+        }
+        Ok(removed)
+    }
+
+    /// Where the debugger resolves function `name`: its source path and
+    /// line, or None when it resolved to code without a source
+    ///
+    /// Sends the function breakpoints with one on `name` added and then
+    /// without it again, so nothing is kept. A running program could hit
+    /// the probe in between and stop there, so stopped is safer.
+    pub async fn locate_function(&self, name: &str) -> Result<Option<(String, i32)>> {
+        let name = crate::adapters::function_breakpoint_name(&self.language, name)?;
+        let name = name.as_str();
+        let current_state = self.get_state().await;
+        if !matches!(
+            current_state,
+            DebugState::Stopped { .. } | DebugState::Running
+        ) {
+            return Err(crate::Error::InvalidState(format!(
+                "Cannot resolve a function in state {:?}; the program must be running or stopped (e.g. with stopOnEntry)",
+                current_state
+            )));
+        }
+
+        let mut breakpoints: Vec<_> = self
+            .state
+            .read()
+            .await
+            .function_breakpoints
+            .iter()
+            .map(to_function_breakpoint)
+            .collect();
+        let existing = breakpoints.iter().position(|b| b.name == name);
+        let index = existing.unwrap_or(breakpoints.len());
+        if existing.is_none() {
+            breakpoints.push(crate::dap::types::FunctionBreakpoint {
+                name: name.to_string(),
+                condition: None,
+                hit_condition: None,
+            });
+        }
+        let arguments = serde_json::json!({ "breakpoints": breakpoints });
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        client
+            .check_capabilities("setFunctionBreakpoints", Some(&arguments))
+            .await?;
+
+        let result = client.set_function_breakpoints(breakpoints).await;
+        if existing.is_none() {
+            Self::send_function_breakpoints(&self.state, &client).await?;
+        }
+        let resolved = result?.into_iter().nth(index).ok_or_else(|| {
+            crate::Error::Dap(format!("No breakpoint for function {} in response", name))
+        })?;
+        if !resolved.verified {
+            return Err(crate::Error::InvalidRequest(format!(
+                "The debugger couldn't resolve function {}: {}",
+                name,
+                resolved.message.as_deref().unwrap_or("not found")
+            )));
+        }
+        Ok(resolved
+            .source
+            .and_then(|source| source.path)
+            .zip(resolved.line))
+    }
+
+    /// Continue from the entry stop by itself (`autoContinueFromEntry`);
+    /// breakpoints set after the start aren't waited for
+    pub async fn set_auto_continue_from_entry(&self, enabled: bool) {
+        self.state.write().await.auto_continue_entry = enabled;
+    }
+
+    /// Stop at the program's entry point (`entry: "user_main"`)
+    ///
+    /// The entry breakpoint's first stop removes it and reads `entry`, like a
+    /// stopOnEntry stop. debugger_start arms it while the launch is held
+    /// (see `launch_gate`), so it goes out with the pending breakpoints and
+    /// its setBreakpoints response is awaited before `configurationDone`.
+    pub async fn arm_entry_breakpoint(&self, entry: EntryBreakpoint) -> Result<()> {
+        self.state.write().await.entry_breakpoint = Some(entry.clone());
+        match entry {
+            EntryBreakpoint::Line { path, line } => {
+                self.set_breakpoint_with(Breakpoint {
+                    source_path: path,
+                    line,
+                    id: None,
+                    verified: false,
+                    enabled: true,
+                    condition: None,
+                    hit_condition: None,
+                    log_message: None,
+                    temporary: true,
+                    verified_line: None,
+                    move_explanation: None,
+                    caller: None,
+                })
+                .await?;
+            }
+            EntryBreakpoint::Function(name) => {
+                self.add_function_breakpoints(vec![FunctionBreakpoint {
+                    name,
+                    condition: None,
+                    hit_condition: None,
+                    id: None,
+                    verified: false,
+                    message: None,
+                }])
+                .await?;
+            }
+        }
+        Ok(())
+    }
+
+    /// Add function breakpoints without resolving them first: before the
+    /// launch they go out with the pending breakpoints, after it they are
+    /// sent at once
+    pub async fn add_function_breakpoints(
+        &self,
+        breakpoints: Vec<FunctionBreakpoint>,
+    ) -> Result<()> {
+        let launched = !matches!(
+            self.get_state().await,
+            DebugState::NotStarted | DebugState::Initializing
+        );
+        {
+            let mut state = self.state.write().await;
+            for bp in breakpoints {
+                state.insert_function_breakpoint(FunctionBreakpoint {
+                    id: None,
+                    verified: false,
+                    message: None,
+                    ..bp
+                });
+            }
+        }
+        if launched {
+            self.sync_function_breakpoints().await?;
+        }
+        Ok(())
+    }
+
+    /// Send all function breakpoints and record the adapter's answer
+    async fn sync_function_breakpoints(&self) -> Result<()> {
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        Self::send_function_breakpoints(&self.state, &client).await
+    }
+
+    /// `sync_function_breakpoints` for event handlers, which hold no session
+    async fn send_function_breakpoints(
+        state: &RwLock<SessionState>,
+        client: &DapClient,
+    ) -> Result<()> {
+        let breakpoints = state
+            .read()
+            .await
+            .function_breakpoints
+            .iter()
+            .map(to_function_breakpoint)
+            .collect();
+        let result = client.set_function_breakpoints(breakpoints).await?;
+
+        // Adapter returns breakpoints in the same order they were sent
+        state.write().await.update_function_breakpoints(
+            result
+                .into_iter()
+                .map(|bp| (bp.id, bp.verified, bp.message)),
+        );
+        Ok(())
+    }
+
+    /// Send all armed instruction breakpoints and record the adapter's answer
+    async fn sync_instruction_breakpoints(&self) -> Result<()> {
+        let armed = self.state.read().await.armed_instruction_breakpoints();
+        let breakpoints = armed.iter().map(to_instruction_breakpoint).collect();
+
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        let result = client.set_instruction_breakpoints(breakpoints).await?;
+
+        // Adapter returns breakpoints in the same order they were sent
+        self.state.write().await.update_instruction_breakpoints(
+            result
+                .into_iter()
+                .map(|bp| (bp.id, bp.verified, bp.message)),
+        );
+        Ok(())
+    }
+
+    /// Re-send instruction breakpoints after the debuggee was relaunched
+    ///
+    /// Addresses only stay meaningful if the same binary is loaded at the same
+    /// base. Breakpoints set against an older build are marked stale and left
+    /// out; the rest are re-armed with a warning, since address space layout
+    /// randomization can still move them.
+    async fn rearm_instruction_breakpoints(&self) {
+        let (stale, armed) = {
+            let mut state = self.state.write().await;
+            if state.instruction_breakpoints.is_empty() {
+                return;
+            }
+            let stale = state.invalidate_instruction_breakpoints(program_modified(&self.program));
+            (stale, state.armed_instruction_breakpoints().len())
+        };
+
+        if stale > 0 {
+            warn!(
+                "⚠️  {} instruction breakpoint(s) not re-armed: {} changed since they were set",
+                stale, self.program
+            );
+        }
+        if armed > 0 {
+            warn!(
+                "⚠️  Re-arming {} instruction breakpoint(s) at their previous addresses; they only hit the same instructions if the program loads at the same base address",
+                armed
+            );
+        }
+
+        if let Err(e) = self.sync_instruction_breakpoints().await {
+            warn!("⚠️  Failed to re-arm instruction breakpoints: {}", e);
+        }
+    }
+
+    /// Stop on exceptions by mode (`raised`, `uncaught`), replacing any
+    /// previous setting; an empty list turns exception breakpoints off
+    ///
+    /// Modes are translated into the adapter's own filters (see
+    /// `adapters::resolve_exception_filters`). Exception options set before
+    /// are sent again. Returns the filters sent.
+    pub async fn set_exception_breakpoints(&self, modes: &[String]) -> Result<Vec<String>> {
+        let options = self.state.read().await.exception_options.clone();
+        self.set_exception_breakpoints_with_options(modes, &options)
+            .await
+    }
+
+    /// Stop on exceptions by mode and by exception options, replacing both
+    ///
+    /// Exception options (e.g. only exceptions of `myapp.errors` types) need
+    /// an adapter with `supportsExceptionOptions`; an empty list sends none.
+    pub async fn set_exception_breakpoints_with_options(
+        &self,
+        modes: &[String],
+        exception_options: &[ExceptionOptions],
+    ) -> Result<Vec<String>> {
+        crate::adapters::validate_exception_options(exception_options)?;
+        let current_state = self.get_state().await;
+        if !matches!(
+            current_state,
+            DebugState::Running
+                | DebugState::Stopped { .. }
+                | DebugState::Initialized
+                | DebugState::Launching
+        ) {
+            return Err(crate::Error::InvalidState(format!(
+                "Cannot set exception breakpoints in state: {:?}",
+                current_state
+            )));
+        }
+
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        let adapter_id = client
+            .adapter_id()
+            .await
+            .unwrap_or_else(|| self.language.clone());
+        let offered: Option<Vec<String>> = client
+            .capabilities()
+            .await
+            .and_then(|caps| caps.exception_breakpoint_filters)
+            .map(|filters| filters.into_iter().map(|f| f.filter).collect());
+
+        let filters = crate::adapters::resolve_exception_filters(
+            &self.language,
+            &adapter_id,
+            modes,
+            offered.as_deref(),
+        )?;
+        let options = (!exception_options.is_empty()).then(|| exception_options.to_vec());
+        client
+            .set_exception_breakpoints(filters.clone(), options)
+            .await?;
+        drop(client);
+
+        let mut state = self.state.write().await;
+        state.exception_breakpoints = modes.to_vec();
+        state.exception_options = exception_options.to_vec();
+        Ok(filters)
+    }
+
+    /// Class and message of the exception a thread is stopped on
+    ///
+    /// None if the adapter doesn't support `exceptionInfo` or has nothing to report.
+    pub async fn exception_info(&self, thread_id: i32) -> Option<ExceptionInfo> {
+        let client_arc = self.get_debug_client().await;
         let client = client_arc.read().await;
-        client.step_out(thread_id).await?;
+        match client.exception_info(thread_id).await {
+            Ok(info) => Some(info),
+            Err(e) => {
+                info!("No exception info for thread {}: {}", thread_id, e);
+                None
+            }
+        }
+    }
+
+    /// Memory ranges the debugger reported modified, oldest first
+    pub async fn memory_changes(&self) -> Vec<MemoryChange> {
+        self.state
+            .read()
+            .await
+            .memory_changes
+            .iter()
+            .cloned()
+            .collect()
+    }
+
+    /// Request counts and latencies, stops and startup time of the session
+    pub fn metrics(&self) -> MetricsReport {
+        self.metrics.report()
+    }
+
+    /// Phases of the session's start, with the one it is in (see
+    /// `dap::launch_phase`); None before it began
+    pub fn launch_report(&self) -> Option<LaunchReport> {
+        self.launch_phases.report()
+    }
+
+    /// Tracks the phases of the session's start
+    pub fn launch_phases(&self) -> &LaunchPhases {
+        &self.launch_phases
+    }
+
+    /// Requests to the adapter still waiting for a response
+    pub async fn request_queue(&self) -> QueueReport {
+        self.get_debug_client().await.read().await.queue_report()
+    }
+
+    /// Child processes the debugger follows, in the order they started
+    pub async fn child_processes(&self) -> Vec<DebuggeeProcess> {
+        self.state.read().await.child_processes().to_vec()
+    }
+
+    /// Group and member name, if the session was started in a group
+    pub async fn group_member(&self) -> Option<Membership> {
+        self.state.read().await.group_member.clone()
+    }
+
+    pub async fn set_group_member(&self, member: Membership) {
+        self.state.write().await.group_member = Some(member);
+    }
+
+    /// When the program last stopped, if it is stopped now
+    pub async fn stopped_at(&self) -> Option<std::time::Instant> {
+        let state = self.state.read().await;
+        match state.state {
+            DebugState::Stopped { .. } => state.stopped_at,
+            _ => None,
+        }
+    }
+
+    /// Open a REPL at the current stop, in `frame_id` or else the top frame
+    /// of `thread_id` (the stopped thread by default)
+    pub async fn open_repl(&self, frame_id: Option<i32>, thread_id: Option<i32>) -> Result<Repl> {
+        self.check_writable("Opening a REPL (it evaluates arbitrary code in the program)")?;
+        let (stopped_thread, stopped_at) = {
+            let state = self.state.read().await;
+            match (&state.state, state.stopped_at) {
+                (DebugState::Stopped { thread_id, .. }, Some(stopped_at)) => {
+                    (*thread_id, stopped_at)
+                }
+                _ => {
+                    return Err(crate::Error::InvalidState(
+                        "A REPL needs a stopped program; use debugger_wait_for_stop first"
+                            .to_string(),
+                    ))
+                }
+            }
+        };
+        let thread_id = thread_id.unwrap_or(stopped_thread);
+        self.ensure_thread_stopped(thread_id).await?;
+        let frame_id = match frame_id {
+            Some(id) => Some(id),
+            None => self
+                .stack_trace_for_thread(thread_id)
+                .await?
+                .first()
+                .map(|frame| frame.id),
+        };
+
+        let repl = Repl::new(thread_id, frame_id, stopped_at);
+        self.state.write().await.repls.insert(repl.clone());
+        Ok(repl)
+    }
+
+    /// Whether the session has a REPL with this id
+    pub async fn has_repl(&self, repl_id: &str) -> bool {
+        self.state.read().await.repls.contains(repl_id)
+    }
+
+    /// A REPL with its history
+    pub async fn repl(&self, repl_id: &str) -> Result<Repl> {
+        self.state
+            .read()
+            .await
+            .repls
+            .get(repl_id)
+            .cloned()
+            .ok_or_else(|| crate::Error::InvalidRequest(format!("Unknown REPL '{}'", repl_id)))
+    }
+
+    /// Evaluate input in a REPL's frame with the `repl` context, adding it
+    /// to the REPL's history; fails once the program resumed from the REPL's
+    /// stop
+    pub async fn repl_eval(&self, repl_id: &str, input: &str) -> Result<String> {
+        let repl = self.repl(repl_id).await?;
+        repl.check_current(self.stopped_at().await)?;
+        self.ensure_thread_stopped(repl.thread_id).await?;
+
+        let result = self
+            .evaluate_in_context(input, repl.frame_id, "repl")
+            .await
+            .map(|output| crate::config::redact(&output));
+        let recorded = match &result {
+            Ok(output) => Ok(output.clone()),
+            Err(e) => Err(e.to_string()),
+        };
+        if let Some(repl) = self.state.write().await.repls.get_mut(repl_id) {
+            repl.record(input, recorded);
+        }
+        result
+    }
+
+    /// Remember the caller's spelling of a path whose on-disk casing differs
+    ///
+    /// Returns true the first time a path is aliased, so the correction is
+    /// reported once per file.
+    pub async fn alias_path(&self, on_disk: &str, requested: &str) -> bool {
+        self.state
+            .write()
+            .await
+            .path_aliases
+            .insert(on_disk.to_string(), requested.to_string())
+            .is_none()
+    }
+
+    /// Take a post-mortem snapshot whenever the program stops on an exception
+    ///
+    /// Where the adapter can stop on uncaught exceptions only (debugpy), that
+    /// is turned on once the program is launched, unless exception
+    /// breakpoints were set already. Delve stops on unrecovered panics by
+    /// itself; other adapters capture only on exception stops requested with
+    /// `set_exception_breakpoints`.
+    pub async fn set_capture_on_exception(self: &Arc<Self>, enabled: bool) {
+        self.capture_on_exception.store(enabled, Ordering::SeqCst);
+        let arms_uncaught = crate::adapters::exception_filters(&self.language)
+            .iter()
+            .any(|(mode, _)| *mode == "uncaught");
+        if enabled && arms_uncaught {
+            tokio::spawn(Self::arm_uncaught_exceptions(Arc::downgrade(self)));
+        }
+    }
+
+    /// Stop on uncaught exceptions, or let the program terminate
+    /// (`resolve_on_uncaught` tells which the debugger can do)
+    ///
+    /// Breaking arms debugpy's uncaught filter once the program is launched,
+    /// like `set_capture_on_exception`; Delve breaks on unrecovered panics by
+    /// itself. To terminate, the session continues at once from panic stops
+    /// and from exception stops no exception breakpoint asked for, so the
+    /// program dies as it would without a debugger.
+    pub async fn set_on_uncaught(self: &Arc<Self>, mode: OnUncaught) {
+        self.state.write().await.terminate_on_uncaught = mode == OnUncaught::Terminate;
+        let arms_uncaught = crate::adapters::exception_filters(&self.language)
+            .iter()
+            .any(|(mode, _)| *mode == "uncaught");
+        if mode == OnUncaught::Break && arms_uncaught {
+            tokio::spawn(Self::arm_uncaught_exceptions(Arc::downgrade(self)));
+        }
+    }
+
+    /// Turn on uncaught-exception stops as soon as the adapter accepts them
+    async fn arm_uncaught_exceptions(session: Weak<Self>) {
+        let mut changes = match session.upgrade() {
+            Some(session) => session.state.read().await.subscribe(),
+            None => return,
+        };
+        let timeouts = &crate::config::current().timeouts;
+        let deadline = tokio::time::Instant::now()
+            + Duration::from_millis(timeouts.initialize_ms + timeouts.launch_ms);
+        loop {
+            let Some(session) = session.upgrade() else {
+                return;
+            };
+            let state = session.state.read().await;
+            match state.state {
+                DebugState::NotStarted | DebugState::Initializing => {}
+                DebugState::Terminated | DebugState::Failed { .. } => return,
+                _ => {
+                    let already_set = !state.exception_breakpoints.is_empty();
+                    drop(state);
+                    if !already_set {
+                        if let Err(e) = session
+                            .set_exception_breakpoints(&["uncaught".to_string()])
+                            .await
+                        {
+                            warn!("⚠️  Could not stop on uncaught exceptions: {}", e);
+                        }
+                    }
+                    return;
+                }
+            }
+            drop(state);
+            drop(session);
+            if !matches!(
+                tokio::time::timeout_at(deadline, changes.changed()).await,
+                Ok(Ok(()))
+            ) {
+                warn!("⚠️  Program didn't launch in time to stop on uncaught exceptions");
+                return;
+            }
+        }
+    }
+
+    /// Post-mortem snapshot of the exception the program is stopped on
+    ///
+    /// Taken once per stop and only when capturing was enabled with
+    /// `set_capture_on_exception`: exception details, the top
+    /// `CAPTURE_STACK_LEVELS` frames and up to `MAX_CAPTURED_LOCALS` variables
+    /// of the top frame. The snapshot stays in the session state after the
+    /// program moves on, so it can be reported when the program terminates.
+    pub async fn capture_exception(&self) -> Option<ExceptionCapture> {
+        if !self.capture_on_exception.load(Ordering::SeqCst) {
+            return None;
+        }
+        let (thread_id, stop_seq) = {
+            let state = self.state.read().await;
+            match &state.state {
+                DebugState::Stopped { thread_id, reason } if reason == "exception" => {
+                    if let Some(capture) = state
+                        .exception_capture
+                        .as_ref()
+                        .filter(|c| c.stop_seq == state.events_seq)
+                    {
+                        return Some(capture.clone());
+                    }
+                    (*thread_id, state.events_seq)
+                }
+                _ => return None,
+            }
+        };
+
+        let exception = self.exception_info(thread_id).await.map(|mut info| {
+            info.description = info.description.as_deref().map(crate::config::redact);
+            info
+        });
+        let page = match self
+            .stack_trace_page(thread_id, 0, CAPTURE_STACK_LEVELS)
+            .await
+        {
+            Ok(page) => page,
+            Err(e) => {
+                warn!("⚠️  Could not capture the stack of the exception: {}", e);
+                return None;
+            }
+        };
+        let (locals, locals_truncated) = match page.frames.first() {
+            Some(top) => self.capture_locals(top.id).await,
+            None => (Vec::new(), false),
+        };
+
+        let capture = ExceptionCapture {
+            stop_seq,
+            thread_id,
+            exception,
+            stack: page.into(),
+            locals,
+            locals_truncated,
+        };
+        let mut state = self.state.write().await;
+        // Only keep it if the program is still at the same stop
+        if state.events_seq == stop_seq {
+            state.exception_capture = Some(capture.clone());
+        }
+        Some(capture)
+    }
+
+    /// Variables of a frame's non-expensive scopes, redacted and shortened
+    async fn capture_locals(&self, frame_id: i32) -> (Vec<CapturedLocal>, bool) {
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        let scopes = match client.scopes(frame_id).await {
+            Ok(scopes) => scopes,
+            Err(e) => {
+                warn!("⚠️  Could not capture locals of the exception frame: {}", e);
+                return (Vec::new(), false);
+            }
+        };
+
+        let mut locals = Vec::new();
+        for scope in scopes.iter().filter(|s| !s.expensive) {
+            let Ok(variables) = client.variables(scope.variables_reference).await else {
+                continue;
+            };
+            for variable in variables {
+                if locals.len() == MAX_CAPTURED_LOCALS {
+                    return (locals, true);
+                }
+                let value = crate::config::redact(&variable.value);
+                let value = match value.char_indices().nth(CAPTURED_VALUE_CHARS) {
+                    Some((cut, _)) => format!("{}...", &value[..cut]),
+                    None => value,
+                };
+                locals.push(CapturedLocal {
+                    name: variable.name,
+                    value,
+                    type_name: variable.type_,
+                });
+            }
+        }
+        (locals, false)
+    }
+
+    /// Startup output (see `SessionState::record_output`) while the session
+    /// is starting or after its start failed, when there is any
+    pub async fn startup_output(&self) -> Option<Vec<String>> {
+        let state = self.state.read().await;
+        let starting = !state.launched || matches!(state.state, DebugState::Failed { .. });
+        let lines = state.startup_lines();
+        (starting && !lines.is_empty()).then_some(lines)
+    }
+
+    /// Triage report of the unhandled exception or panic the program is
+    /// stopped on, or died of
+    ///
+    /// Built once per stop, when the program stops on an exception whose
+    /// break mode says nothing handles it (for debugpy that takes uncaught
+    /// exception breakpoints, see `set_exception_breakpoints`): exception
+    /// details, the top `CRASH_FRAMES` frames with source snippets, the
+    /// locals of the innermost frame that isn't library code and the last
+    /// lines of output. Resuming and disconnecting build it first, since
+    /// afterwards the adapter tears the program down; it then stays in the
+    /// session state after the program terminates.
+    pub async fn crash_report(&self) -> Option<CrashReport> {
+        let (thread_id, capture) = {
+            let state = self.state.read().await;
+            match &state.state {
+                DebugState::Stopped { thread_id, reason }
+                    if matches!(reason.as_str(), "exception" | "panic")
+                        && self.crash_checked.swap(state.events_seq, Ordering::SeqCst)
+                            != state.events_seq =>
+                {
+                    // A capture of this stop (captureOnException) already
+                    // holds the exception and the top frame's locals
+                    let capture = state
+                        .exception_capture
+                        .clone()
+                        .filter(|c| c.stop_seq == state.events_seq && c.thread_id == *thread_id);
+                    (*thread_id, capture)
+                }
+                _ => return state.crash_report.clone(),
+            }
+        };
+
+        let exception = match capture.as_ref().and_then(|c| c.exception.clone()) {
+            Some(exception) => Some(exception),
+            None => self.exception_info(thread_id).await.map(|mut info| {
+                info.description = info.description.as_deref().map(crate::config::redact);
+                info
+            }),
+        };
+        let Some(exception) = exception.filter(crash::is_fatal) else {
+            return self.state.read().await.crash_report.clone();
+        };
+
+        let page = match self
+            .stack_trace_page(thread_id, 0, CAPTURE_STACK_LEVELS)
+            .await
+        {
+            Ok(page) => page,
+            Err(e) => {
+                warn!("⚠️  Could not capture the stack of the crash: {}", e);
+                return self.state.read().await.crash_report.clone();
+            }
+        };
+        let user_frame = page
+            .frames
+            .iter()
+            .find(|f| !crash::is_library_frame(&self.language, f));
+        let top_frame = page.frames.first().map(|f| f.id);
+        let (locals, locals_truncated) = match (user_frame, &capture) {
+            (Some(frame), Some(capture)) if Some(frame.id) == top_frame => {
+                (capture.locals.clone(), capture.locals_truncated)
+            }
+            (Some(frame), _) => self.capture_locals(frame.id).await,
+            (None, _) => (Vec::new(), false),
+        };
+
+        let mut frames = Vec::new();
+        for frame in page.frames.iter().take(crash::CRASH_FRAMES) {
+            let source = match &frame.source {
+                Some(source) => self
+                    .resolve_source(source)
+                    .await
+                    .content
+                    .map(|content| crash::snippet(&content, frame.line))
+                    .unwrap_or_default(),
+                None => Vec::new(),
+            };
+            frames.push(CrashFrame::new(&self.language, frame, source));
+        }
+
+        let mut state = self.state.write().await;
+        let output = state
+            .output_tail
+            .lines()
+            .iter()
+            .map(|line| crate::config::redact(line))
+            .collect();
+        let report = CrashReport {
+            thread_id,
+            exception,
+            frames,
+            user_frame: user_frame.map(|f| f.name.clone()),
+            locals,
+            locals_truncated,
+            output,
+        };
+        info!(
+            "💥 Crash report taken: {} in {}",
+            report.exception.exception_id,
+            report.user_frame.as_deref().unwrap_or("library code")
+        );
+        state.crash_report = Some(report.clone());
+        if let Some(webhook) = &state.webhook {
+            webhook.send("crashed", serde_json::json!(report));
+        }
+        Some(report)
+    }
+
+    /// POST this session's stops, exits and crashes to `url` (checked with
+    /// `webhook::validate_url`)
+    ///
+    /// Crashes are reported when the program stops on them
+    /// (`crash_watch_loop`), not when the crash report is first asked for.
+    pub async fn set_webhook(self: &Arc<Self>, url: String) {
+        let webhook = Webhook::new(url, &self.id, &self.language, &self.program);
+        let mut state = self.state.write().await;
+        let watching = state.webhook.is_some();
+        state.webhook = Some(webhook);
+        if !watching {
+            tokio::spawn(Self::crash_watch_loop(
+                Arc::downgrade(self),
+                state.subscribe(),
+            ));
+        }
+    }
+
+    /// Take the crash report, and with it send the webhook's "crashed"
+    /// event, at each stop on an unhandled exception or panic, until the
+    /// session is gone
+    async fn crash_watch_loop(session: Weak<Self>, mut changes: watch::Receiver<u64>) {
+        while changes.changed().await.is_ok() {
+            let Some(session) = session.upgrade() else {
+                return;
+            };
+            let crashed = matches!(
+                session.get_state().await,
+                DebugState::Stopped { reason, .. } if matches!(reason.as_str(), "exception" | "panic")
+            );
+            if crashed {
+                session.crash_report().await;
+            }
+        }
+    }
+
+    /// The program's output (see `output_log`)
+    pub async fn output_log(&self) -> OutputLog {
+        self.state.read().await.output_log.clone()
+    }
+
+    /// The webhook URL and its delivery log, oldest first
+    pub async fn webhook_deliveries(&self) -> Option<(String, Vec<Delivery>)> {
+        let state = self.state.read().await;
+        let webhook = state.webhook.as_ref()?;
+        Some((webhook.url.clone(), webhook.deliveries()))
+    }
+
+    /// Stop when `expression` changes value at `line` (see `change_watch`)
+    ///
+    /// Sets a breakpoint on the line, replacing any there, and returns the
+    /// watch and whether the breakpoint was verified.
+    pub async fn watch_change(
+        &self,
+        expression: &str,
+        source_path: &str,
+        line: i32,
+        max_auto_continues: u64,
+    ) -> Result<(ChangeWatch, bool)> {
+        self.check_writable(
+            "Watching for changes (the watch evaluates the expression and stashes values in the program)",
+        )?;
+        let id = self.next_change_watch_id().await;
+        let watch = ChangeWatch::new(
+            id,
+            &self.language,
+            expression,
+            source_path,
+            line,
+            max_auto_continues,
+        );
+        let condition = change_watch::condition(&self.language, id, expression);
+        self.add_change_watch(watch, condition, None).await
+    }
+
+    /// Set a breakpoint whose condition is `changed(expression)` (see
+    /// `change_watch`): a server-side watch of the line, stopping only when
+    /// the value differs from the previous hit's
+    ///
+    /// `condition` and `hit_condition` stay the debugger's, which checks
+    /// them first; the server only sees the hits they let through.
+    pub async fn set_changed_breakpoint(
+        &self,
+        expression: &str,
+        source_path: &str,
+        line: i32,
+        condition: Option<String>,
+        hit_condition: Option<String>,
+    ) -> Result<(ChangeWatch, bool)> {
+        self.check_writable(
+            "A changed() condition (the server evaluates the expression at every hit)",
+        )?;
+        let watch = ChangeWatch::server_loop(
+            self.next_change_watch_id().await,
+            expression,
+            source_path,
+            line,
+            change_watch::DEFAULT_MAX_AUTO_CONTINUES,
+        );
+        self.add_change_watch(watch, condition, hit_condition).await
+    }
+
+    async fn next_change_watch_id(&self) -> u32 {
+        let state = self.state.read().await;
+        state.change_watches.iter().map(|w| w.id).max().unwrap_or(0) + 1
+    }
+
+    /// Set the breakpoint of `watch`, replacing any on its line and the
+    /// line's watch
+    async fn add_change_watch(
+        &self,
+        watch: ChangeWatch,
+        condition: Option<String>,
+        hit_condition: Option<String>,
+    ) -> Result<(ChangeWatch, bool)> {
+        let verified = self
+            .set_breakpoint_with(Breakpoint {
+                source_path: watch.source_path.clone(),
+                line: watch.line,
+                id: None,
+                verified: false,
+                enabled: true,
+                condition,
+                hit_condition,
+                log_message: None,
+                temporary: false,
+                verified_line: None,
+                move_explanation: None,
+                caller: None,
+            })
+            .await?;
+        self.state.write().await.change_watches.push(watch.clone());
+        Ok((watch, verified))
+    }
+
+    /// The change a watch stopped the program for, if the last stop was one
+    pub async fn watch_stop(&self) -> Option<WatchStop> {
+        self.state.read().await.watch_stop.clone()
+    }
+
+    /// The caller a caller-constrained breakpoint stopped the program for,
+    /// if the last stop was one
+    pub async fn caller_stop(&self) -> Option<CallerMatch> {
+        self.state.read().await.caller_stop.clone()
+    }
+
+    /// Watch mode status (see `file_watch`); None when not watching
+    pub async fn file_watch(&self) -> Option<WatchStatus> {
+        self.state.read().await.file_watch.clone()
+    }
+
+    pub async fn set_file_watch(&self, status: Option<WatchStatus>) {
+        self.state.write().await.file_watch = status;
+    }
+
+    /// Record a failed relaunch; the session keeps watching
+    pub async fn set_relaunch_error(&self, error: String) {
+        if let Some(status) = self.state.write().await.file_watch.as_mut() {
+            status.error = Some(error);
+        }
+    }
+
+    /// Note that this session relaunched an older one (see `file_watch`)
+    pub async fn record_relaunch(&self, status: WatchStatus, relaunch: Relaunch) {
+        self.state.write().await.record_relaunch(status, relaunch);
+    }
+
+    /// Record the `debugger_start` arguments, for saving as a launch config
+    pub async fn set_start_arguments(&self, arguments: serde_json::Map<String, serde_json::Value>) {
+        *self.start_arguments.write().await = Some(arguments);
+    }
+
+    pub async fn start_arguments(&self) -> Option<serde_json::Map<String, serde_json::Value>> {
+        self.start_arguments.read().await.clone()
+    }
+
+    /// Snapshot the session's breakpoints as a shareable document
+    pub async fn export_breakpoints(&self) -> BreakpointDocument {
+        let state = self.state.read().await;
+        BreakpointDocument::from_state(&self.language, &state)
+    }
+
+    /// Apply the breakpoints, watches and exception breakpoints of an
+    /// exported document
+    ///
+    /// Fails fast if the document comes from a session of another language.
+    /// Otherwise every entry gets a status, in document order: entries whose file
+    /// is missing (or outside the workspace) fail, the rest are tracked like
+    /// breakpoints from `set_breakpoint`, replacing any on the same line;
+    /// conditional entries on one line share it (see `shared_line`).
+    /// `changed()` entries and watches are set like `set_changed_breakpoint`
+    /// and `watch_change` set them, after the other entries, and the
+    /// exception modes replace the session's last. Files that don't exist
+    /// here but fall under the session's `substitutePath` rules (paths of a
+    /// remote or container session) are mapped to their local paths first.
+    pub async fn import_breakpoints(&self, doc: &BreakpointDocument) -> Result<ImportReport> {
+        if doc.language != self.language {
+            return Err(crate::Error::InvalidRequest(format!(
+                "Breakpoint document is for language '{}' but this session is '{}'",
+                doc.language, self.language
+            )));
+        }
+
+        let current_state = self.get_state().await;
+        if matches!(
+            current_state,
+            DebugState::Terminated | DebugState::Failed { .. }
+        ) {
+            return Err(crate::Error::InvalidState(format!(
+                "Cannot import breakpoints in state: {:?}",
+                current_state
+            )));
+        }
+
+        let rules = self.substitute_path.read().await.clone();
+        let validate = |file: &str| {
+            let file = if std::path::Path::new(file).exists() {
+                file.to_string()
+            } else {
+                golang::local_source_path(&rules, file)
+            };
+            security::validate_source_path(&file, None)
+        };
+
+        let mut statuses = vec![ImportStatus::Unverified; doc.breakpoints.len()];
+        let mut accepted = Vec::new();
+        let mut changed = Vec::new();
+        for (idx, entry) in doc.breakpoints.iter().enumerate() {
+            let expression = entry
+                .condition
+                .as_deref()
+                .and_then(change_watch::changed_expression);
+            match validate(&entry.file) {
+                Ok(path) if expression.is_some() => {
+                    changed.push((idx, path.to_string_lossy().into_owned(), expression))
+                }
+                Ok(path) => accepted.push((
+                    idx,
+                    Breakpoint {
+                        source_path: path.to_string_lossy().into_owned(),
+                        line: entry.line,
+                        id: None,
+                        verified: false,
+                        enabled: entry.enabled,
+                        condition: entry.condition.clone(),
+                        hit_condition: entry.hit_condition.clone(),
+                        log_message: entry.log_message.clone(),
+                        temporary: false,
+                        verified_line: None,
+                        move_explanation: None,
+                        caller: None,
+                    },
+                )),
+                Err(e) => {
+                    statuses[idx] = ImportStatus::Failed {
+                        reason: e.to_string(),
+                    }
+                }
+            }
+        }
+
+        {
+            let mut state = self.state.write().await;
+            for (i, (_, bp)) in accepted.iter().enumerate() {
+                // Conditional entries on one line were exported from a shared line
+                let shares_line = accepted[..i].iter().any(|(_, earlier)| {
+                    earlier.source_path == bp.source_path && earlier.line == bp.line
+                }) && shared_line::check_shareable(bp).is_ok()
+                    && state
+                        .get_breakpoints(&bp.source_path)
+                        .iter()
+                        .filter(|b| b.line == bp.line)
+                        .all(|b| shared_line::check_shareable(b).is_ok());
+                if shares_line {
+                    state.insert_shared_breakpoint(bp.clone());
+                } else {
+                    state.insert_breakpoint(bp.clone());
+                }
+            }
+        }
+
+        let pending = matches!(
+            current_state,
+            DebugState::NotStarted | DebugState::Initializing
+        );
+        if pending {
+            // Same as set_breakpoint: applied once initialization completes
+            for (idx, bp) in &accepted {
+                statuses[*idx] = if bp.enabled {
+                    ImportStatus::Pending
+                } else {
+                    ImportStatus::Disabled
+                };
+            }
+            let mut lines: Vec<(&str, i32)> = accepted
+                .iter()
+                .filter(|(_, bp)| bp.enabled)
+                .map(|(_, bp)| (bp.source_path.as_str(), bp.line))
+                .collect();
+            lines.sort_unstable();
+            lines.dedup();
+            for (source_path, line) in lines {
+                self.apply_line_breakpoints(current_state.clone(), source_path, line)
+                    .await?;
+            }
+        } else {
+            let mut files: Vec<&str> = accepted
+                .iter()
+                .map(|(_, bp)| bp.source_path.as_str())
+                .collect();
+            files.sort_unstable();
+            files.dedup();
+
+            let mut sync_errors = HashMap::new();
+            for file in files {
+                if let Err(e) = self.sync_source_breakpoints(file).await {
+                    sync_errors.insert(file.to_string(), e.to_string());
+                }
+            }
+
+            let state = self.state.read().await;
+            for (idx, bp) in &accepted {
+                statuses[*idx] = if let Some(e) = sync_errors.get(&bp.source_path) {
+                    ImportStatus::Failed { reason: e.clone() }
+                } else if !bp.enabled {
+                    ImportStatus::Disabled
+                } else if state
+                    .get_breakpoints(&bp.source_path)
+                    .iter()
+                    .any(|b| b.line == bp.line && b.verified)
+                {
+                    ImportStatus::Verified
+                } else {
+                    ImportStatus::Unverified
+                };
+            }
+        }
+
+        for (idx, source_path, expression) in changed {
+            let entry = &doc.breakpoints[idx];
+            statuses[idx] = if !entry.enabled {
+                ImportStatus::Failed {
+                    reason: "A changed() breakpoint is only imported enabled".to_string(),
+                }
+            } else {
+                let set = self
+                    .set_changed_breakpoint(
+                        expression.unwrap_or_default(),
+                        &source_path,
+                        entry.line,
+                        None,
+                        entry.hit_condition.clone(),
+                    )
+                    .await;
+                applied_status(set.map(|(_, verified)| verified), pending)
+            };
+        }
+
+        let mut watches = Vec::new();
+        for watch in &doc.watches {
+            let WatchEntry::Change {
+                expression,
+                file,
+                line,
+                max_auto_continues,
+            } = watch
+            else {
+                continue;
+            };
+            let set = match validate(file) {
+                Ok(path) => {
+                    self.watch_change(
+                        expression,
+                        &path.to_string_lossy(),
+                        *line,
+                        (*max_auto_continues).min(change_watch::MAX_AUTO_CONTINUES),
+                    )
+                    .await
+                }
+                Err(e) => Err(e),
+            };
+            watches.push(applied_status(set.map(|(_, verified)| verified), pending));
+        }
+
+        let exception_filters = if doc.exception_filters.is_empty() {
+            None
+        } else {
+            Some(
+                match self.set_exception_breakpoints(&doc.exception_filters).await {
+                    Ok(_) => ImportStatus::Verified,
+                    Err(e) => ImportStatus::Failed {
+                        reason: e.to_string(),
+                    },
+                },
+            )
+        };
+
+        Ok(ImportReport {
+            breakpoints: statuses,
+            watches,
+            exception_filters,
+        })
+    }
+
+    pub async fn continue_execution(&self) -> Result<()> {
+        let state = self.state.read().await;
+        let thread_id = match &state.state {
+            DebugState::Stopped { thread_id, .. } => *thread_id,
+            _ => state.threads.first().copied().unwrap_or(1),
+        };
+        drop(state);
+
+        self.continue_thread(thread_id).await
+    }
+
+    /// Resume a specific thread
+    pub async fn continue_thread(&self, thread_id: i32) -> Result<()> {
+        self.ensure_thread_stopped(thread_id).await?;
+        self.crash_report().await;
+
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        let all_threads_continued = client.continue_execution(thread_id).await?;
+
+        let mut state = self.state.write().await;
+        state.apply_continued(thread_id, all_threads_continued);
+
+        Ok(())
+    }
+
+    /// Continue, skipping stops for breakpoint `breakpoint_id` alone until
+    /// its `count`-th hit (see `continue_past`)
+    ///
+    /// Waits up to `timeout` for the program to stop; a stop for anything
+    /// else ends the wait early. Nothing is skipped once this returns.
+    pub async fn continue_past(
+        &self,
+        breakpoint_id: i32,
+        count: u32,
+        timeout: Duration,
+    ) -> Result<ContinuePastReport> {
+        if count == 0 {
+            return Err(crate::Error::InvalidRequest(
+                "count must be at least 1 (the hit to stop at)".to_string(),
+            ));
+        }
+        let since = {
+            let mut state = self.state.write().await;
+            if !matches!(state.state, DebugState::Stopped { .. }) {
+                return Err(crate::Error::InvalidState(format!(
+                    "Cannot continue past a breakpoint in state {:?}; the program must be stopped",
+                    state.state
+                )));
+            }
+            check_breakpoint_ids(&state, Some(&[breakpoint_id]))?;
+            state.continue_past = Some(ContinuePast::new(breakpoint_id, count));
+            state.events_seq
+        };
+
+        let stop = match self.continue_execution().await {
+            Ok(()) => self.wait_for_stop_since(since, timeout).await,
+            Err(e) => {
+                self.state.write().await.continue_past = None;
+                return Err(e);
+            }
+        };
+
+        let mut state = self.state.write().await;
+        let past = state
+            .continue_past
+            .take()
+            .unwrap_or_else(|| ContinuePast::new(breakpoint_id, count));
+        let report = match stop {
+            Some(DebugState::Stopped { thread_id, reason }) => {
+                let status = if past.reached() && state.hit_breakpoint_ids == [breakpoint_id] {
+                    ContinuePastStatus::Reached
+                } else {
+                    ContinuePastStatus::Interrupted
+                };
+                ContinuePastReport {
+                    thread_id: Some(thread_id),
+                    reason: Some(reason),
+                    hit_breakpoint_ids: state.hit_breakpoint_ids.clone(),
+                    ..ContinuePastReport::new(status, &past)
+                }
+            }
+            Some(_) => ContinuePastReport::new(ContinuePastStatus::Terminated, &past),
+            None => ContinuePastReport::new(ContinuePastStatus::TimedOut, &past),
+        };
+        info!(
+            "⏭️  Continue past breakpoint {} ended: {:?} after {} skipped hit(s)",
+            breakpoint_id, report.status, report.skipped
+        );
+        Ok(report)
+    }
+
+    /// Fails with `ThreadRunning` if the given thread is known to be running
+    pub async fn ensure_thread_stopped(&self, thread_id: i32) -> Result<()> {
+        let state = self.state.read().await;
+        state.check_thread_stopped(thread_id)
+    }
+
+    /// Threads reported by the adapter together with their tracked run state
+    pub async fn threads(&self) -> Result<Vec<(Thread, Option<ThreadState>)>> {
+        let threads = {
+            let client_arc = self.get_debug_client().await;
+            let client = client_arc.read().await;
+            client.threads().await?
+        };
+
+        let mut state = self.state.write().await;
+        Ok(threads
+            .into_iter()
+            .map(|thread| {
+                state.add_thread(thread.id);
+                let run_state = state.thread_states.get(&thread.id).cloned();
+                (thread, run_state)
+            })
+            .collect())
+    }
+
+    /// Step over; returns the granularity the step goes by (see
+    /// `step_granularity`)
+    pub async fn step_over(
+        &self,
+        thread_id: i32,
+        granularity: Option<SteppingGranularity>,
+    ) -> Result<SteppingGranularity> {
+        self.crash_report().await;
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        let (sent, effective) = Self::step_granularity(&client, granularity).await;
+        self.expecting_stop(client.next(thread_id, sent)).await?;
+
+        // State will be updated by 'stopped' event handler when step completes
+        Ok(effective)
+    }
+
+    pub async fn step_into(
+        &self,
+        thread_id: i32,
+        granularity: Option<SteppingGranularity>,
+    ) -> Result<SteppingGranularity> {
+        self.crash_report().await;
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        let (sent, effective) = Self::step_granularity(&client, granularity).await;
+        self.expecting_stop(client.step_in(thread_id, sent)).await?;
+
+        // State will be updated by 'stopped' event handler when step completes
+        Ok(effective)
+    }
+
+    pub async fn step_out(
+        &self,
+        thread_id: i32,
+        granularity: Option<SteppingGranularity>,
+    ) -> Result<SteppingGranularity> {
+        self.crash_report().await;
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        let (sent, effective) = Self::step_granularity(&client, granularity).await;
+        self.expecting_stop(client.step_out(thread_id, sent))
+            .await?;
+
+        // State will be updated by 'stopped' event handler when step completes
+        Ok(effective)
+    }
+
+    /// The granularity to send a step with, and the one it goes by
+    ///
+    /// Adapters without `supportsSteppingGranularity` step by line whatever
+    /// is asked, so another granularity falls back to line stepping rather
+    /// than failing the step. Adapters with it step by statement by default.
+    async fn step_granularity(
+        client: &DapClient,
+        requested: Option<SteppingGranularity>,
+    ) -> (Option<SteppingGranularity>, SteppingGranularity) {
+        let supported = client
+            .capabilities()
+            .await
+            .and_then(|caps| caps.supports_stepping_granularity)
+            .unwrap_or(false);
+        match (requested, supported) {
+            (Some(granularity), true) => (Some(granularity), granularity),
+            (None, true) => (None, SteppingGranularity::Statement),
+            (requested, false) => {
+                if requested.is_some_and(|g| g != SteppingGranularity::Line) {
+                    info!(
+                        "↘️  Adapter can't step by {:?}; stepping by line instead",
+                        requested
+                    );
+                }
+                (None, SteppingGranularity::Line)
+            }
+        }
+    }
+
+    /// Step until the thread is no longer in its current source file
+    ///
+    /// Steps out while a frame further down the stack belongs to another file
+    /// (each step out pops a frame of this file); once the file is at the
+    /// bottom of the stack, steps over line by line. Gives up after
+    /// `max_steps` steps, and stops early when anything other than a step
+    /// completes (a breakpoint or exception), the program ends, or a step
+    /// doesn't finish within the wait-for-stop timeout.
+    pub async fn step_out_of_file(
+        &self,
+        thread_id: i32,
+        max_steps: usize,
+    ) -> Result<FileStepOutcome> {
+        let mut frames = self.stack_trace_for_thread(thread_id).await?;
+        let from_path = frames
+            .first()
+            .and_then(frame_path)
+            .ok_or_else(|| {
+                crate::Error::InvalidState(
+                    "The current frame has no source file to step out of".to_string(),
+                )
+            })?
+            .to_string();
+        let wait = self.wait_for_stop_timeout().await;
+
+        let outcome = |end, steps, frames: Vec<StackFrame>| FileStepOutcome {
+            end,
+            steps,
+            from_path: from_path.clone(),
+            location: frames.into_iter().next(),
+        };
+
+        for step in 1..=max_steps {
+            let caller_elsewhere = frames
+                .iter()
+                .skip(1)
+                .any(|f| frame_path(f) != Some(from_path.as_str()));
+            let since = self.events_seq().await;
+            if caller_elsewhere {
+                self.step_out(thread_id, None).await?;
+            } else {
+                self.step_over(thread_id, None).await?;
+            }
+
+            match self.wait_for_stop_since(since, wait).await {
+                Some(DebugState::Stopped {
+                    thread_id: stopped,
+                    reason,
+                }) => {
+                    frames = self.stack_trace_for_thread(stopped).await?;
+                    if reason != "step" {
+                        return Ok(outcome(FileStepEnd::Interrupted { reason }, step, frames));
+                    }
+                    if frames.first().and_then(frame_path) != Some(from_path.as_str()) {
+                        return Ok(outcome(FileStepEnd::Left, step, frames));
+                    }
+                }
+                Some(_) => return Ok(outcome(FileStepEnd::Terminated, step, Vec::new())),
+                None => return Ok(outcome(FileStepEnd::StillRunning, step, Vec::new())),
+            }
+        }
+
+        Ok(outcome(FileStepEnd::LimitReached, max_steps, frames))
+    }
+
+    /// Step over line by line until the current function stops on `line`
+    ///
+    /// Calls are stepped over, so execution never goes deeper than the
+    /// function; it ends when the function returns first, or when anything
+    /// other than a step (a breakpoint, an exception) stops the program. At
+    /// least one step is taken: from `line` itself, this runs to its next
+    /// execution, e.g. the next iteration of a loop.
+    pub async fn step_to_line(
+        &self,
+        thread_id: i32,
+        line: i32,
+        max_steps: usize,
+    ) -> Result<LineStepOutcome> {
+        let frames = self.stack_trace_for_thread(thread_id).await?;
+        let function = FunctionKey::of(&frames).ok_or_else(|| {
+            crate::Error::InvalidState(format!("Thread {} has no stack frames", thread_id))
+        })?;
+        let wait = self.wait_for_stop_timeout().await;
+
+        let outcome = |end, steps, frames: Vec<StackFrame>| LineStepOutcome {
+            end,
+            steps,
+            location: frames.into_iter().next(),
+        };
+
+        for step in 1..=max_steps {
+            let since = self.events_seq().await;
+            self.step_over(thread_id, None).await?;
+
+            match self.wait_for_stop_since(since, wait).await {
+                Some(DebugState::Stopped {
+                    thread_id: stopped,
+                    reason,
+                }) => {
+                    let frames = self.stack_trace_for_thread(stopped).await?;
+                    if let Some(end) = line_step_end(&function, &frames, &reason, line) {
+                        return Ok(outcome(end, step, frames));
+                    }
+                }
+                Some(_) => return Ok(outcome(LineStepEnd::Terminated, step, Vec::new())),
+                None => return Ok(outcome(LineStepEnd::StillRunning, step, Vec::new())),
+            }
+        }
+
+        let frames = self.stack_trace_for_thread(thread_id).await?;
+        Ok(outcome(LineStepEnd::LimitReached, max_steps, frames))
+    }
+
+    /// Run until breakpoint `breakpoint_id` is hit
+    ///
+    /// Continues a stopped program (a running one is just waited for) and
+    /// returns at the first stop for the breakpoint. With `auto_continue`,
+    /// stops at other breakpoints are continued through, at most
+    /// `max_auto_continues` times; any other stop (an exception, a pause)
+    /// ends the wait where it is. `timeout` bounds the whole wait.
+    pub async fn run_to_breakpoint(
+        &self,
+        breakpoint_id: i32,
+        auto_continue: bool,
+        max_auto_continues: u64,
+        timeout: Duration,
+    ) -> Result<BreakpointWaitOutcome> {
+        let deadline = tokio::time::Instant::now() + timeout;
+        let (state, mut since) = {
+            let state = self.state.read().await;
+            check_breakpoint_ids(&state, Some(&[breakpoint_id]))?;
+            (state.state.clone(), state.events_seq)
+        };
+        match state {
+            DebugState::Stopped { .. } => self.continue_execution().await?,
+            DebugState::Running => {}
+            other => {
+                return Err(crate::Error::InvalidState(format!(
+                    "Cannot wait for a breakpoint in state {:?}; the program must be running or stopped",
+                    other
+                )))
+            }
+        }
+
+        let mut auto_continues = 0;
+        let outcome = |end, auto_continues, thread_id, location| BreakpointWaitOutcome {
+            end,
+            auto_continues,
+            thread_id,
+            location,
+        };
+        loop {
+            let wait = deadline.saturating_duration_since(tokio::time::Instant::now());
+            let (thread_id, reason) = match self.wait_for_stop_since(since, wait).await {
+                Some(DebugState::Stopped { thread_id, reason }) => (thread_id, reason),
+                Some(_) => {
+                    return Ok(outcome(
+                        BreakpointWaitEnd::Terminated,
+                        auto_continues,
+                        None,
+                        None,
+                    ))
+                }
+                None => {
+                    return Ok(outcome(
+                        BreakpointWaitEnd::StillRunning,
+                        auto_continues,
+                        None,
+                        None,
+                    ))
+                }
+            };
+
+            let top = self
+                .stack_trace_for_thread(thread_id)
+                .await
+                .ok()
+                .and_then(|frames| frames.into_iter().next());
+            let (hit, at_breakpoint) = {
+                let state = self.state.read().await;
+                let location = top
+                    .as_ref()
+                    .and_then(|top| frame_path(top).map(|path| (path, top.line)));
+                (
+                    state.stop_was_for(breakpoint_id, location),
+                    !state.hit_breakpoint_ids.is_empty() || reason.contains("breakpoint"),
+                )
+            };
+            let end = if hit {
+                BreakpointWaitEnd::Hit
+            } else if !auto_continue || !at_breakpoint {
+                BreakpointWaitEnd::Interrupted { reason }
+            } else if auto_continues == max_auto_continues {
+                BreakpointWaitEnd::LimitReached
+            } else {
+                since = self.events_seq().await;
+                self.continue_execution().await?;
+                auto_continues += 1;
+                continue;
+            };
+            return Ok(outcome(end, auto_continues, Some(thread_id), top));
+        }
+    }
+
+    /// Step filters in effect
+    pub async fn step_filters(&self) -> StepFilters {
+        self.step_filters.read().await.clone()
+    }
+
+    /// Keep stepping while a step stopped in filtered code
+    ///
+    /// `stop` is the state the step ended in. Steps out while a frame further
+    /// down the stack is unfiltered, and over otherwise, until the top frame
+    /// is unfiltered or `MAX_AUTO_STEPS` steps were taken. Anything other
+    /// than a step stop (a breakpoint inside the filtered code, say) ends it
+    /// where it is.
+    pub async fn leave_filtered_code(&self, stop: DebugState) -> Result<FilteredStepOutcome> {
+        let filters = self.step_filters().await;
+        let wait = self.wait_for_stop_timeout().await;
+        let mut state = Some(stop);
+        let mut skipped = 0;
+
+        while let Some(DebugState::Stopped { thread_id, reason }) = &state {
+            if filters.is_empty() || reason != "step" {
+                break;
+            }
+            let thread_id = *thread_id;
+            let frames = self.stack_trace_for_thread(thread_id).await?;
+            if !frames.first().is_some_and(|f| filters.matches(f)) {
+                break;
+            }
+            if skipped == MAX_AUTO_STEPS {
+                return Ok(FilteredStepOutcome {
+                    state,
+                    skipped,
+                    limit_reached: true,
+                });
+            }
+
+            let since = self.events_seq().await;
+            if frames.iter().skip(1).any(|f| !filters.matches(f)) {
+                self.step_out(thread_id, None).await?;
+            } else {
+                self.step_over(thread_id, None).await?;
+            }
+            skipped += 1;
+            state = self.wait_for_stop_since(since, wait).await;
+        }
+
+        Ok(FilteredStepOutcome {
+            state,
+            skipped,
+            limit_reached: false,
+        })
+    }
+
+    /// Pause the program; the `stopped` event updates the state
+    pub async fn pause(&self, thread_id: i32) -> Result<()> {
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        self.expecting_stop(client.pause(thread_id)).await
+    }
+
+    /// Send a step or pause, marking its stop as on its way so that it
+    /// isn't continued as a stop for unsubscribed breakpoints
+    async fn expecting_stop(&self, request: impl Future<Output = Result<()>>) -> Result<()> {
+        self.state.write().await.user_stop_pending = true;
+        let result = request.await;
+        if result.is_err() {
+            self.state.write().await.user_stop_pending = false;
+        }
+        result
+    }
+
+    /// Follow breakpoints `ids`, or all breakpoints when None
+    pub async fn subscribe(&self, ids: Option<&[i32]>) -> Result<Subscription> {
+        let mut state = self.state.write().await;
+        check_breakpoint_ids(&state, ids)?;
+        state.subscription.subscribe(ids);
+        Ok(state.subscription.clone())
+    }
+
+    /// Stop following breakpoints `ids`, or all breakpoints when None
+    pub async fn unsubscribe(&self, ids: Option<&[i32]>) -> Result<Subscription> {
+        let mut state = self.state.write().await;
+        check_breakpoint_ids(&state, ids)?;
+        state.subscription.unsubscribe(ids);
+        Ok(state.subscription.clone())
+    }
+
+    /// List the asyncio tasks of the event loop the program is stopped in
+    ///
+    /// Evaluates `PythonAdapter::async_tasks_expression()` in the top frame of
+    /// the stopped thread, so it only sees a loop when that frame runs inside
+    /// one; otherwise the result says there is no running event loop.
+    pub async fn list_async_tasks(&self) -> Result<AsyncTasks> {
+        if self.language != "python" {
+            return Err(crate::Error::InvalidRequest(format!(
+                "Asyncio task inspection is only supported for python sessions, not {}",
+                self.language
+            )));
+        }
+
+        let state = self.get_state().await;
+        if !matches!(state, DebugState::Stopped { .. }) {
+            return Err(crate::Error::InvalidState(format!(
+                "Cannot list asyncio tasks in state {:?}; the program must be stopped",
+                state
+            )));
+        }
+
+        let result = self
+            .evaluate_unrecorded(&PythonAdapter::async_tasks_expression(), None, "watch")
+            .await?;
+        PythonAdapter::parse_async_tasks(&result)
+    }
+
+    /// Diagnose why a Go program hangs
+    ///
+    /// Pauses the program if it's running, then classifies every goroutine
+    /// (up to `MAX_ANALYZED_GOROUTINES`) by the stack it is parked on; see
+    /// `GoAdapter::analyze_hang`.
+    pub async fn analyze_hang(&self) -> Result<HangAnalysis> {
+        if self.language != "go" {
+            return Err(crate::Error::InvalidRequest(format!(
+                "Hang analysis is only supported for go sessions, not {}",
+                self.language
+            )));
+        }
+
+        let (state, since, first_thread) = {
+            let state = self.state.read().await;
+            (
+                state.state.clone(),
+                state.events_seq,
+                state.threads.first().copied().unwrap_or(1),
+            )
+        };
+        match state {
+            DebugState::Running => {
+                self.pause(first_thread).await?;
+                let timeout = self.wait_for_stop_timeout().await;
+                match self.wait_for_stop_since(since, timeout).await {
+                    Some(DebugState::Stopped { .. }) => {}
+                    Some(_) => {
+                        return Err(crate::Error::InvalidState(
+                            "Program terminated before it could be paused".to_string(),
+                        ))
+                    }
+                    None => {
+                        return Err(crate::Error::Timeout(format!(
+                            "Program did not pause within {:?}",
+                            timeout
+                        )))
+                    }
+                }
+            }
+            DebugState::Stopped { .. } => {}
+            other => {
+                return Err(crate::Error::InvalidState(format!(
+                    "Cannot analyze a hang in state {:?}; the program must be running or stopped",
+                    other
+                )))
+            }
+        }
+
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        let threads = client.threads().await?;
+        let truncated = threads.len() > MAX_ANALYZED_GOROUTINES;
+
+        let mut goroutines = Vec::new();
+        for thread in threads.into_iter().take(MAX_ANALYZED_GOROUTINES) {
+            // A goroutine that exited in the meantime just has no frames
+            let frames = client.stack_trace(thread.id).await.unwrap_or_default();
+            goroutines.push((thread, frames));
+        }
+
+        Ok(GoAdapter::analyze_hang(&goroutines, truncated))
+    }
+
+    /// A page of the program's goroutines: where each is and whether it is
+    /// blocked (see `GoAdapter::summarize_goroutine`)
+    ///
+    /// Delve names every goroutine thread after its current function; the
+    /// top `GOROUTINE_STATE_FRAMES` frames of each goroutine on the page are
+    /// loaded for its location and what it waits on, and stand in for names
+    /// Delve didn't format. The program must be stopped: Delve lists
+    /// goroutines only then.
+    pub async fn goroutines(&self, offset: usize, limit: usize) -> Result<GoroutinePage> {
+        if self.language != "go" {
+            return Err(crate::Error::InvalidRequest(format!(
+                "Goroutines are only listed for go sessions, not {}; use debugger_list_threads",
+                self.language
+            )));
+        }
+        let state = self.get_state().await;
+        if !matches!(state, DebugState::Stopped { .. }) {
+            return Err(crate::Error::InvalidState(format!(
+                "Cannot list goroutines in state {:?}; pause the program first (debugger_pause)",
+                state
+            )));
+        }
+
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        let threads = client.threads().await?;
+        let mut goroutines = Vec::new();
+        for thread in threads.iter().skip(offset).take(limit) {
+            // A goroutine that exited in the meantime just has no frames
+            let frames = match client
+                .stack_trace_page(thread.id, 0, GOROUTINE_STATE_FRAMES)
+                .await
+            {
+                Ok((mut frames, _)) => {
+                    self.state.read().await.alias_paths(&mut frames);
+                    Some(frames)
+                }
+                Err(_) => None,
+            };
+            goroutines.push(GoAdapter::summarize_goroutine(thread, frames.as_deref()));
+        }
+
+        let named = threads
+            .iter()
+            .all(|thread| golang::parse_thread_name(&thread.name).is_some());
+        let next = offset.saturating_add(limit);
+        Ok(GoroutinePage {
+            goroutines,
+            total: threads.len(),
+            offset,
+            next_offset: (next < threads.len()).then_some(next),
+            truncated: threads.len() >= golang::DELVE_MAX_THREADS,
+            source: if named { "threadNames" } else { "stackTrace" },
+        })
+    }
+
+    /// Evaluate an expression in the top frame of the stopped thread, for
+    /// `debugger_compare_sessions` (see `compare`)
+    ///
+    /// Never fails: a session that isn't stopped, or an evaluation that
+    /// fails, is reported in the value's status.
+    pub async fn value_for_comparison(
+        &self,
+        expression: &str,
+        no_side_effects: bool,
+    ) -> SessionValue {
+        let mut side = SessionValue::new(&self.id, SideStatus::Evaluated);
+        let state = self.get_state().await;
+        let DebugState::Stopped { thread_id, .. } = state else {
+            side.status = SideStatus::NotStopped;
+            side.state = Some(state);
+            return side;
+        };
+        side.thread_id = Some(thread_id);
+
+        let result = async {
+            let top = self
+                .stack_trace_for_thread(thread_id)
+                .await?
+                .into_iter()
+                .next()
+                .ok_or_else(|| {
+                    crate::Error::InvalidState("Thread has no stack frames".to_string())
+                })?;
+            side.function = Some(top.name);
+            side.line = Some(top.line);
+            if self.side_effect_free(no_side_effects)? {
+                self.evaluate_without_side_effects(expression, Some(top.id))
+                    .await
+            } else {
+                self.evaluate(expression, Some(top.id)).await
+            }
+        }
+        .await;
+        match result {
+            Ok(value) => side.value = Some(value),
+            Err(e) => {
+                side.status = SideStatus::Failed;
+                side.error = Some(e.to_string());
+            }
+        }
+        side
+    }
+
+    /// Evaluate an expression in the top frame of every stopped thread
+    ///
+    /// Up to `max_threads` stopped threads are evaluated, in the adapter's
+    /// order; see `thread_eval`. Each evaluation is recorded in the
+    /// transcript.
+    pub async fn evaluate_all_threads(
+        &self,
+        expression: &str,
+        max_threads: usize,
+        no_side_effects: bool,
+    ) -> Result<ThreadEvaluations> {
+        let state = self.get_state().await;
+        if !matches!(state, DebugState::Stopped { .. }) {
+            return Err(crate::Error::InvalidState(format!(
+                "Cannot evaluate in threads in state {:?}; the program must be stopped",
+                state
+            )));
+        }
+        let context = if no_side_effects {
+            crate::adapters::side_effect_free_context(&self.language)?
+        } else {
+            "watch"
+        };
+
+        let (running, stopped): (Vec<_>, Vec<_>) = self
+            .threads()
+            .await?
+            .into_iter()
+            .partition(|(_, run_state)| matches!(run_state, Some(ThreadState::Running)));
+        let omitted_threads = stopped.len().saturating_sub(max_threads);
+        let stopped: Vec<Thread> = stopped
+            .into_iter()
+            .take(max_threads)
+            .map(|(thread, _)| thread)
+            .collect();
+        let budget = thread_eval::value_budget(
+            crate::config::current().limits.max_response_bytes,
+            stopped.len(),
+        );
+
+        let mut results = Vec::new();
+        for thread in stopped {
+            let mut result = ThreadEvaluation {
+                thread_id: thread.id,
+                thread_name: thread.name,
+                function: None,
+                line: None,
+                value: None,
+                error: None,
+                truncated: false,
+            };
+            let top = match self.stack_trace_for_thread(thread.id).await {
+                Ok(frames) => frames.into_iter().next(),
+                Err(e) => {
+                    result.error = Some(e.to_string());
+                    results.push(result);
+                    continue;
+                }
+            };
+            let Some(top) = top else {
+                result.error = Some("Thread has no stack frames".to_string());
+                results.push(result);
+                continue;
+            };
+            result.function = Some(top.name);
+            result.line = Some(top.line);
+            match self
+                .evaluate_in_context(expression, Some(top.id), context)
+                .await
+            {
+                Ok(value) => {
+                    let (value, truncated) = thread_eval::fit_value(&value, budget);
+                    result.value = Some(value);
+                    result.truncated = truncated;
+                }
+                Err(e) => result.error = Some(e.to_string()),
+            }
+            results.push(result);
+        }
+
+        Ok(ThreadEvaluations {
+            expression: expression.to_string(),
+            results,
+            running_threads: running.into_iter().map(|(thread, _)| thread.id).collect(),
+            omitted_threads,
+        })
+    }
+
+    /// Return values the adapter reports for the function just stepped out of
+    ///
+    /// Looks in the non-expensive scopes of the thread's top frame; empty when
+    /// the adapter doesn't report them (see `return_values`).
+    pub async fn return_values(&self, thread_id: i32) -> Result<Vec<ReturnValue>> {
+        let frames = self.stack_trace_for_thread(thread_id).await?;
+        let Some(top) = frames.first() else {
+            return Ok(Vec::new());
+        };
+
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        let mut values = Vec::new();
+        for scope in client.scopes(top.id).await? {
+            if scope.expensive {
+                continue;
+            }
+            values.extend(return_values::extract(
+                &client.variables(scope.variables_reference).await?,
+            ));
+        }
+        Ok(values)
+    }
+
+    pub async fn stack_trace(&self) -> Result<Vec<crate::dap::types::StackFrame>> {
+        let state = self.state.read().await;
+
+        // Get thread_id from the current Stopped state, or fallback to threads list
+        let thread_id = match &state.state {
+            DebugState::Stopped { thread_id, .. } => *thread_id,
+            _ => state.threads.first().copied().unwrap_or(1),
+        };
+        drop(state);
+
+        self.stack_trace_for_thread(thread_id).await
+    }
+
+    /// Top frames of a specific thread's stack (the thread must not be running)
+    ///
+    /// At most `DEFAULT_STACK_LEVELS` frames; use `stack_trace_page` for the
+    /// depth of the stack or the frames below.
+    pub async fn stack_trace_for_thread(
+        &self,
+        thread_id: i32,
+    ) -> Result<Vec<crate::dap::types::StackFrame>> {
+        Ok(self
+            .stack_trace_page(thread_id, 0, DEFAULT_STACK_LEVELS)
+            .await?
+            .frames)
+    }
+
+    /// pprof labels of a stopped goroutine (see `goroutine_labels`)
+    ///
+    /// Each label layout is tried in turn; an error means none could be read
+    /// in this Go version, which callers report for the goroutine alone.
+    pub async fn goroutine_labels(&self, thread_id: i32) -> Result<BTreeMap<String, String>> {
+        let top = self
+            .stack_trace_for_thread(thread_id)
+            .await?
+            .into_iter()
+            .next()
+            .ok_or_else(|| {
+                crate::Error::InvalidState(format!("Goroutine {} has no stack frames", thread_id))
+            })?;
+        let pointer = self
+            .evaluate_unrecorded(LABELS_POINTER, Some(top.id), "watch")
+            .await
+            .map_err(|e| crate::Error::Dap(format!("labels unavailable: {}", e)))?;
+        if goroutine_labels::is_nil_pointer(&pointer) {
+            return Ok(BTreeMap::new());
+        }
+        let mut error = String::new();
+        for layout in LabelLayout::ALL {
+            match self
+                .evaluate_unrecorded(&layout.labels_expression(), Some(top.id), "watch")
+                .await
+            {
+                Ok(value) => match goroutine_labels::parse_labels(&value) {
+                    Some(labels) => return Ok(labels),
+                    None => error = format!("unreadable value '{}'", value),
+                },
+                Err(e) => error = e.to_string(),
+            }
+        }
+        Err(crate::Error::Dap(format!("labels unavailable: {}", error)))
+    }
+
+    /// Condition for a breakpoint that stops only goroutines labelled
+    /// `label`, for the label layout of the Go toolchain the session builds
+    /// with (its validated `goPath`, else `go` from PATH)
+    pub async fn goroutine_label_condition(&self, label: &GoroutineLabel) -> Result<String> {
+        if self.language != "go" {
+            return Err(crate::Error::InvalidRequest(format!(
+                "goroutineLabel is only supported for Go sessions, not {}",
+                self.language
+            )));
+        }
+        let go = self
+            .toolchain
+            .read()
+            .await
+            .as_ref()
+            .map_or_else(|| PathBuf::from("go"), |t| t.path.clone());
+        goroutine_labels::toolchain_layout(&go)
+            .await?
+            .condition(label)
+    }
+
+    /// Disassemble a window of `instruction_count` instructions, starting
+    /// `instruction_offset` instructions away from `memory_reference`
+    ///
+    /// Without a reference the window is counted from the stopped thread's
+    /// current instruction. The window is remembered for
+    /// `scroll_disassembly`. Only Delve is supported.
+    pub async fn disassemble(
+        &self,
+        memory_reference: Option<String>,
+        instruction_offset: i64,
+        instruction_count: i64,
+    ) -> Result<DisassemblyPage> {
+        if self.language != "go" {
+            return Err(crate::Error::UnsupportedCapability {
+                capability: "supportsDisassembleRequest".to_string(),
+                adapter: self.language.clone(),
+                suggestion: crate::dap::capabilities::suggestion(
+                    &self.language,
+                    "supportsDisassembleRequest",
+                )
+                .map(str::to_string),
+            });
+        }
+
+        let pc = self.current_instruction().await;
+        let memory_reference = match (memory_reference, &pc) {
+            (Some(reference), _) => reference,
+            (None, Some(pc)) => pc.clone(),
+            (None, None) => {
+                return Err(crate::Error::InvalidState(
+                    "No current instruction to disassemble around; stop the program or pass a memoryReference"
+                        .to_string(),
+                ))
+            }
+        };
+        let window = DisassemblyWindow {
+            memory_reference,
+            instruction_offset,
+            instruction_count: instruction_count.clamp(1, disassembly::MAX_INSTRUCTIONS),
+        };
+        self.read_disassembly(window, pc).await
+    }
+
+    /// Disassemble the window after (or before) the last one read
+    pub async fn scroll_disassembly(&self, forward: bool) -> Result<DisassemblyPage> {
+        let last = self.state.read().await.disassembly_window.clone();
+        let Some(last) = last else {
+            return Err(crate::Error::InvalidState(
+                "No disassembly window to scroll; call debugger_disassemble first".to_string(),
+            ));
+        };
+        let window = if forward { last.next() } else { last.prev() };
+        let pc = self.current_instruction().await;
+        self.read_disassembly(window, pc).await
+    }
+
+    async fn read_disassembly(
+        &self,
+        window: DisassemblyWindow,
+        pc: Option<String>,
+    ) -> Result<DisassemblyPage> {
+        let instructions = {
+            let client_arc = self.get_debug_client().await;
+            let client = client_arc.read().await;
+            client
+                .disassemble(
+                    &window.memory_reference,
+                    window.instruction_offset,
+                    window.instruction_count,
+                )
+                .await?
+        };
+
+        let current_index = pc.and_then(|pc| disassembly::find_instruction(&instructions, &pc));
+        let page = DisassemblyPage {
+            memory_reference: window.memory_reference.clone(),
+            instruction_offset: window.instruction_offset,
+            instructions,
+            current_index,
+        };
+        self.state.write().await.disassembly_window = Some(window);
+        Ok(page)
+    }
+
+    /// Address of the stopped thread's current instruction
+    async fn current_instruction(&self) -> Option<String> {
+        let thread_id = match self.get_state().await {
+            DebugState::Stopped { thread_id, .. } => thread_id,
+            _ => return None,
+        };
+        let page = self.stack_trace_page(thread_id, 0, 1).await.ok()?;
+        page.frames.first()?.instruction_pointer_reference.clone()
+    }
+
+    /// `levels` frames of a thread's stack starting at `start_frame`
+    pub async fn stack_trace_page(
+        &self,
+        thread_id: i32,
+        start_frame: i32,
+        levels: i32,
+    ) -> Result<StackPage> {
+        self.ensure_thread_stopped(thread_id).await?;
+
+        // Frames loaded since the program stopped are served from the cache
+        let seq = {
+            let state = self.state.read().await;
+            if let Some(page) =
+                state
+                    .stack_cache
+                    .page(state.events_seq, thread_id, start_frame, levels)
+            {
+                return Ok(page);
+            }
+            state.events_seq
+        };
+
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        let (frames, total_frames) = client
+            .stack_trace_page(thread_id, start_frame, levels)
+            .await?;
+        drop(client);
+
+        let mut page = StackPage::new(frames, start_frame, levels, total_frames);
+        {
+            let mut state = self.state.write().await;
+            state.alias_paths(&mut page.frames);
+            state.stack_cache.insert(seq, thread_id, &page);
+        }
+        if let Some(top) = page.frames.first().filter(|_| start_frame == 0) {
+            self.state.write().await.transcript.locate_latest_stop(
+                thread_id,
+                &top.name,
+                top.source.as_ref().and_then(|s| s.path.as_deref()),
+                top.line,
+            );
+        }
+        Ok(page)
+    }
+
+    /// Frames of the stopped thread loaded since the program stopped (see
+    /// `stack`), with the thread's id
+    pub async fn loaded_stack(&self) -> Option<(i32, LoadedStack)> {
+        let state = self.state.read().await;
+        let DebugState::Stopped { thread_id, .. } = state.state else {
+            return None;
+        };
+        let stack = state.stack_cache.loaded(state.events_seq, thread_id)?;
+        Some((thread_id, stack.clone()))
+    }
+
+    /// Transcript of the session so far
+    ///
+    /// If the program is stopped and the stop's location isn't known yet, a
+    /// stack trace is taken first so the transcript can say where it stopped.
+    pub async fn transcript(&self) -> Transcript {
+        let (stopped_thread, unlocated) = {
+            let state = self.state.read().await;
+            let thread = match &state.state {
+                DebugState::Stopped { thread_id, .. } => Some(*thread_id),
+                _ => None,
+            };
+            (thread, state.transcript.latest_stop_unlocated())
+        };
+        if let (Some(thread_id), true) = (stopped_thread, unlocated) {
+            if let Err(e) = self.stack_trace_for_thread(thread_id).await {
+                warn!(
+                    "⚠️  Could not locate the current stop for the transcript: {}",
+                    e
+                );
+            }
+        }
+        self.state.read().await.transcript.clone()
+    }
+
+    /// Resolve the content of a frame's source
+    ///
+    /// Tries the local path, then (for Go) the local module cache, then the
+    /// adapter's `source` request. Successful resolutions are cached for the
+    /// lifetime of the session; failures are not, so a later retry can succeed
+    /// once the module has been downloaded.
+    pub async fn resolve_source(&self, source: &Source) -> ResolvedSource {
+        let path = source.path.clone().unwrap_or_default();
+
+        if !path.is_empty() {
+            if let Some(cached) = self.source_cache.read().await.get(&path) {
+                return cached.clone();
+            }
+        }
+
+        let gomodcache = if self.language == "go" {
+            source::local_gomodcache()
+        } else {
+            None
+        };
+
+        let resolved = match source::resolve_from_filesystem(&path, gomodcache.as_deref()) {
+            Some(resolved) => resolved,
+            None => {
+                let client_arc = self.get_debug_client().await;
+                let client = client_arc.read().await;
+                match client.source(source).await {
+                    Ok(content) => ResolvedSource {
+                        origin: SourceOrigin::Adapter,
+                        resolved_path: None,
+                        module: source::go_module_version(&path),
+                        content: Some(content),
+                    },
+                    Err(e) => {
+                        warn!("Could not resolve source '{}': {}", path, e);
+                        return ResolvedSource::unavailable(source::go_module_version(&path));
+                    }
+                }
+            }
+        };
+
+        if !path.is_empty() {
+            self.source_cache
+                .write()
+                .await
+                .insert(path, resolved.clone());
+        }
+        resolved
+    }
+
+    /// Scopes of a stack frame, without their variables
+    pub async fn scopes(&self, frame_id: i32) -> Result<Vec<Scope>> {
+        let mut scopes = {
+            let client_arc = self.get_debug_client().await;
+            let client = client_arc.read().await;
+            client.scopes(frame_id).await?
+        };
+        let state = self.state.read().await;
+        for source in scopes.iter_mut().filter_map(|s| s.source.as_mut()) {
+            if let Some(alias) = source.path.as_ref().and_then(|p| state.path_aliases.get(p)) {
+                source.path = Some(alias.clone());
+            }
+        }
+        Ok(scopes)
+    }
+
+    /// Inline values for a stack frame
+    ///
+    /// Fetches the frame's non-expensive, non-global scopes (locals and
+    /// arguments) and maps each variable to the lines of the current function
+    /// that use it; see `inline_values::compute`.
+    pub async fn inline_values(&self, frame: &StackFrame) -> Result<InlineValues> {
+        let source = frame.source.as_ref().ok_or_else(|| {
+            crate::Error::InvalidRequest(format!("Frame {} has no source", frame.id))
+        })?;
+        let content = self.resolve_source(source).await.content.ok_or_else(|| {
+            crate::Error::InvalidRequest(format!(
+                "Source of frame {} is unavailable ({})",
+                frame.id,
+                source.path.as_deref().unwrap_or("no path")
+            ))
+        })?;
+
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        let mut scopes = Vec::new();
+        for scope in client.scopes(frame.id).await? {
+            if scope.expensive || scope.name.to_lowercase().contains("global") {
+                continue;
+            }
+            scopes.push(client.variables(scope.variables_reference).await?);
+        }
+
+        Ok(inline_values::compute(
+            &self.language,
+            &content,
+            frame.line,
+            &scopes,
+        ))
+    }
+
+    /// Short values of variable, field, element, slice and key names in a
+    /// frame
+    ///
+    /// Names are resolved against the frame's non-expensive scopes, innermost
+    /// first, fetching only the children the names lead through; see
+    /// `peek`. Nothing is evaluated.
+    pub async fn peek(&self, names: &[String], frame_id: i32) -> Result<Peek> {
+        peek::validate_names(names)?;
+
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        let (scopes, children) = self.look_up(&client, names, frame_id).await?;
+        Ok(peek::collect(names, &self.language, &scopes, &children))
+    }
+
+    /// The variables of a frame's inexpensive scopes, and the children
+    /// fetched to find `names` in them
+    async fn look_up(
+        &self,
+        client: &DapClient,
+        names: &[String],
+        frame_id: i32,
+    ) -> Result<(Vec<Vec<Variable>>, HashMap<peek::Fetch, Vec<Variable>>)> {
+        let mut scopes = Vec::new();
+        for scope in client.scopes(frame_id).await? {
+            if !scope.expensive {
+                scopes.push(client.variables(scope.variables_reference).await?);
+            }
+        }
+
+        let mut children = HashMap::new();
+        loop {
+            let needed = peek::needed_fetches(names, &self.language, &scopes, &children);
+            if needed.is_empty() {
+                break;
+            }
+            Self::fetch_children(client, needed, &mut children).await;
+        }
+        Ok((scopes, children))
+    }
+
+    /// Fetch children into `children`; those that can't be listed are
+    /// taken to have none
+    async fn fetch_children(
+        client: &DapClient,
+        fetches: Vec<peek::Fetch>,
+        children: &mut HashMap<peek::Fetch, Vec<Variable>>,
+    ) {
+        for fetch in fetches {
+            let fields = match fetch.page {
+                None => client.variables(fetch.reference).await,
+                Some((start, count)) => {
+                    client
+                        .indexed_variables(fetch.reference, start, count)
+                        .await
+                }
+            };
+            children.insert(fetch, fields.unwrap_or_default());
+        }
+    }
+
+    /// The variable `name` (a peek name) as plain JSON (see `dump`)
+    pub async fn dump_variable(
+        &self,
+        name: &str,
+        frame_id: i32,
+        limits: dump::Limits,
+    ) -> Result<dump::Dump> {
+        let names = [name.to_string()];
+        peek::validate_names(&names)?;
+
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        let (scopes, found) = self.look_up(&client, &names, frame_id).await?;
+        let root = peek::lookup(name, &self.language, &scopes, &found).ok_or_else(|| {
+            crate::Error::InvalidRequest(format!(
+                "No variable '{}' in frame {} (slices can't be dumped; dump the whole array)",
+                name, frame_id
+            ))
+        })?;
+
+        Ok(self.expand(&client, &root, limits).await)
+    }
+
+    /// What the pointer `expression` points to, as plain JSON (see `dump`)
+    ///
+    /// Returns the pointer as evaluated, and the value it points to unless
+    /// it is nil (see `adapters::dereference_expression`).
+    pub async fn dereference(
+        &self,
+        expression: &str,
+        frame_id: i32,
+        limits: dump::Limits,
+    ) -> Result<(Variable, Option<dump::Dump>)> {
+        let dereferenced = crate::adapters::dereference_expression(&self.language, expression)?;
+
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        let pointer = client
+            .evaluate_variable(expression, Some(frame_id), "watch")
+            .await?;
+        if crate::adapters::is_nil_pointer(&self.language, &pointer.value) {
+            return Ok((pointer, None));
+        }
+        let pointee = client
+            .evaluate_variable(&dereferenced, Some(frame_id), "watch")
+            .await?;
+        let dump = self.expand(&client, &pointee, limits).await;
+        Ok((pointer, Some(dump)))
+    }
+
+    /// The value behind a variables reference (a pointer's, from
+    /// `debugger_variables`), as plain JSON (see `dump`)
+    pub async fn dump_reference(&self, reference: i32, limits: dump::Limits) -> Result<dump::Dump> {
+        if reference <= 0 {
+            return Err(crate::Error::InvalidRequest(
+                "variablesReference must be positive; a nil pointer has no reference".to_string(),
+            ));
+        }
+        let root = Variable {
+            name: String::new(),
+            value: String::new(),
+            type_: None,
+            variables_reference: reference,
+            indexed_variables: None,
+            encoding_lossy: false,
+        };
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        Ok(self.expand(&client, &root, limits).await)
+    }
+
+    /// Dump `root`, fetching its children round by round
+    async fn expand(
+        &self,
+        client: &DapClient,
+        root: &Variable,
+        limits: dump::Limits,
+    ) -> dump::Dump {
+        let mut children = HashMap::new();
+        loop {
+            let (dump, needed) = dump::build(root, &self.language, &children, limits);
+            if needed.is_empty() {
+                return dump;
+            }
+            Self::fetch_children(client, needed, &mut children).await;
+        }
+    }
+
+    /// Evaluate an expression, recording it in the session transcript
+    pub async fn evaluate(&self, expression: &str, frame_id: Option<i32>) -> Result<String> {
+        self.evaluate_in_context(expression, frame_id, "watch")
+            .await
+    }
+
+    /// Evaluate in the context where the adapter can't run code with side
+    /// effects; fails for adapters without one (see
+    /// `adapters::side_effect_free_context`)
+    pub async fn evaluate_without_side_effects(
+        &self,
+        expression: &str,
+        frame_id: Option<i32>,
+    ) -> Result<String> {
+        let context = crate::adapters::side_effect_free_context(&self.language)?;
+        self.evaluate_in_context(expression, frame_id, context)
+            .await
+    }
+
+    async fn evaluate_in_context(
+        &self,
+        expression: &str,
+        frame_id: Option<i32>,
+        context: &str,
+    ) -> Result<String> {
+        let result = self
+            .evaluate_unrecorded(expression, frame_id, context)
+            .await;
+        let outcome = match &result {
+            Ok(value) => Ok(value.as_str()),
+            Err(e) => Err(e.to_string()),
+        };
+        self.state
+            .write()
+            .await
+            .transcript
+            .record_evaluation(expression, frame_id, outcome);
+        result
+    }
+
+    /// Evaluate an expression on the server's own behalf (not recorded)
+    async fn evaluate_unrecorded(
+        &self,
+        expression: &str,
+        frame_id: Option<i32>,
+        context: &str,
+    ) -> Result<String> {
+        // If frame_id is None, auto-fetch it from stack trace using correct thread ID
+        let frame_id = if let Some(id) = frame_id {
+            Some(id)
+        } else {
+            // Get current thread ID from Stopped state
+            let state = self.state.read().await;
+            if let DebugState::Stopped { thread_id, .. } = &state.state {
+                // Get stack trace with correct thread ID
+                let client_arc = self.get_debug_client().await;
+                let client = client_arc.read().await;
+                match client.top_frame(*thread_id).await {
+                    Ok(Some(top)) => {
+                        info!(
+                            "📍 Auto-fetched frame_id {} from thread {}",
+                            top.id, thread_id
+                        );
+                        Some(top.id)
+                    }
+                    Ok(None) => {
+                        warn!("⚠️  No stack frames available for evaluate");
+                        None
+                    }
+                    Err(e) => {
+                        warn!("⚠️  Failed to get stack trace for evaluate: {}", e);
+                        None
+                    }
+                }
+            } else {
+                warn!("⚠️  Cannot auto-fetch frame_id: not in Stopped state");
+                None
+            }
+        };
+
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        client
+            .evaluate_in_context(expression, frame_id, context)
+            .await
+    }
+
+    pub async fn disconnect(&self) -> Result<()> {
+        self.crash_report().await;
+        // The program ends because of the disconnect, not on its own
+        self.state.write().await.disconnecting = true;
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+
+        // Use disconnect with 2s timeout (force cleanup if hangs)
+        // If timeout occurs, we still update state to Terminated
+        match client.disconnect_with_timeout().await {
+            Ok(_) => info!("✅ Disconnect completed successfully"),
+            Err(e) => {
+                warn!(
+                    "⚠️  Disconnect timeout or error: {}, proceeding with cleanup",
+                    e
+                );
+                // Continue anyway - state will be set to Terminated
+            }
+        }
+
+        let mut state = self.state.write().await;
+        state.set_state(DebugState::Terminated);
+        let pids: Vec<i64> = state.processes.iter().filter_map(|p| p.pid).collect();
+        drop(state);
+
+        // Ending the prefix command leaves what it started in the target running
+        let tracked = self.exec_prefix.read().await.clone();
+        if let Some(tracked) = tracked {
+            tracked.kill(&pids).await;
+        }
+
+        // The adapter is gone, so is any use for the binary it built
+        self.set_build_dir(None).await;
+
+        Ok(())
+    }
+
+    /// Let go of the build directory if the adapter didn't build into it
+    ///
+    /// Delve versions without the launch `output` option ignore it and build
+    /// `__debug_bin` in their working directory as before.
+    async fn check_build_output(&self) {
+        let mut build_dir = self.build_dir.write().await;
+        let Some(dir) = build_dir.as_ref() else {
+            return;
+        };
+        let built = std::fs::read_dir(dir.path()).is_ok_and(|mut e| e.next().is_some());
+        if !built {
+            warn!(
+                "⚠️  Delve didn't build into {}; it may predate the launch 'output' option and build in its working directory instead",
+                dir.path().display()
+            );
+            *build_dir = None;
+        }
+    }
+
+    /// Keep the toolchain the session was started with (see `toolchain`)
+    pub async fn set_toolchain(&self, toolchain: Toolchain) {
+        *self.toolchain.write().await = Some(toolchain);
+    }
+
+    /// Keep the `substitutePath` rules the session was started with
+    pub async fn set_substitute_path(&self, rules: Vec<SubstitutePath>) {
+        *self.substitute_path.write().await = rules;
+    }
+
+    /// Note that the adapter runs through `tracked`'s prefix (see
+    /// `exec_prefix`)
+    pub async fn set_exec_prefix(&self, tracked: exec_prefix::Tracked) {
+        *self.exec_prefix.write().await = Some(tracked);
+    }
+
+    /// Hand the session the directory of the binary Delve builds for it
+    ///
+    /// A previous directory (from before a warm restart) is removed.
+    pub async fn set_build_dir(&self, dir: Option<BuildDir>) {
+        *self.build_dir.write().await = dir;
+    }
+
+    /// How long to wait for the program to stop when the caller doesn't say
+    pub async fn wait_for_stop_timeout(&self) -> Duration {
+        self.wait_for_stop_timeout.read().await.unwrap_or_else(|| {
+            Duration::from_millis(crate::config::current().timeouts.wait_for_stop_ms)
+        })
+    }
+
+    /// Effective values of the settings `debugger_configure` can change
+    ///
+    /// Launch-time settings are read from the arguments of the last launch.
+    pub async fn settings(&self) -> SessionSettings {
+        let ms = |interval: Option<Duration>| interval.map_or(0, |i| i.as_millis() as u64);
+        let state = self.state.read().await;
+        let launch = state.transcript.launches.last().map(|l| &l.arguments);
+        let launch_flag = |key: &str| launch.and_then(|args| args.get(key)?.as_bool());
+        SessionSettings {
+            capture_on_exception: self.capture_on_exception.load(Ordering::SeqCst),
+            exception_breakpoints: state.exception_breakpoints.clone(),
+            keep_alive_interval_ms: ms(*self.keep_alive_interval.read().await),
+            step_filters: self.step_filters.read().await.patterns().to_vec(),
+            stop_poll_interval_ms: ms(*self.stop_poll_interval.read().await),
+            verify_source: state.verify_source,
+            auto_continue_unsubscribed: state.auto_continue_unsubscribed,
+            wait_for_stop_timeout_ms: self.wait_for_stop_timeout().await.as_millis() as u64,
+            // debugpy defaults to justMyCode
+            just_my_code: (self.language == "python")
+                .then(|| launch_flag("justMyCode").unwrap_or(true)),
+            stop_on_entry: launch_flag("stopOnEntry").unwrap_or(false),
+        }
+    }
+
+    /// Apply the live settings of `update`
+    ///
+    /// Exception breakpoints go first since the adapter may refuse them; if
+    /// it does, nothing is changed. Returns the launch-time settings the
+    /// update would change, which take a restart of the program.
+    pub async fn configure(self: &Arc<Self>, update: &SettingsUpdate) -> Result<Vec<&'static str>> {
+        let requires_restart = update.requires_restart(&self.settings().await);
+
+        if let Some(modes) = &update.exception_breakpoints {
+            self.set_exception_breakpoints(modes).await?;
+        }
+        match (update.capture_on_exception, &update.exception_breakpoints) {
+            // Explicit exception breakpoints replace the ones capturing would arm
+            (Some(enabled), Some(_)) => self.capture_on_exception.store(enabled, Ordering::SeqCst),
+            (Some(enabled), None) => self.set_capture_on_exception(enabled).await,
+            (None, _) => {}
+        }
+        let interval = |ms: u64| (ms > 0).then(|| Duration::from_millis(ms));
+        if let Some(ms) = update.keep_alive_interval_ms {
+            self.set_keep_alive(interval(ms)).await;
+        }
+        if let Some(ms) = update.stop_poll_interval_ms {
+            self.set_stop_polling(interval(ms)).await;
+        }
+        if let Some(ms) = update.wait_for_stop_timeout_ms {
+            *self.wait_for_stop_timeout.write().await = Some(Duration::from_millis(ms));
+        }
+        if let Some(patterns) = &update.step_filters {
+            *self.step_filters.write().await = StepFilters::new(patterns);
+        }
+        if let Some(verify) = update.verify_source {
+            self.set_verify_source(verify).await?;
+        }
+        if let Some(enabled) = update.auto_continue_unsubscribed {
+            self.state.write().await.auto_continue_unsubscribed = enabled;
+        }
+        Ok(requires_restart)
+    }
+
+    /// Turn source verification on or off; breakpoints already sent are
+    /// re-sent (after launch) so their sources are checked
+    pub async fn set_verify_source(&self, verify: bool) -> Result<()> {
+        let sources: Vec<String> = {
+            let mut state = self.state.write().await;
+            state.verify_source = verify;
+            if !verify {
+                state.source_warnings.clear();
+                return Ok(());
+            }
+            if state.launched_at().is_none() {
+                return Ok(());
+            }
+            state.breakpoints.keys().cloned().collect()
+        };
+        for source in sources {
+            self.sync_source_breakpoints(&source).await?;
+        }
+        Ok(())
+    }
+
+    /// Content hashes of the program's sources and the files with
+    /// breakpoints, taken as the program is launched
+    async fn launch_snapshot(&self) -> HashMap<String, String> {
+        let sources: Vec<String> = self
+            .state
+            .read()
+            .await
+            .breakpoints
+            .keys()
+            .cloned()
+            .collect();
+        source_check::launch_snapshot(&self.language, Path::new(&self.program), &sources)
+    }
+
+    /// Warning that a source's breakpoints may bind to stale code
+    pub async fn source_warning(&self, source_path: &str) -> Option<String> {
+        self.state
+            .read()
+            .await
+            .source_warnings
+            .get(source_path)
+            .cloned()
+    }
+
+    pub async fn set_warm_adapter(&self, config: Option<WarmAdapterConfig>) {
+        *self.warm_adapter.write().await = config;
+    }
+
+    pub async fn warm_adapter(&self) -> Option<WarmAdapterConfig> {
+        *self.warm_adapter.read().await
+    }
+
+    /// Adapter id and its support for every capability-gated feature (see
+    /// `dap::capabilities`); None before the adapter is initialized
+    pub async fn capability_report(
+        &self,
+    ) -> Option<(String, std::collections::BTreeMap<&'static str, bool>)> {
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        let capabilities = client.capabilities().await?;
+        let adapter_id = client
+            .adapter_id()
+            .await
+            .unwrap_or_else(|| self.language.clone());
+        let report = crate::dap::capabilities::report(&adapter_id, &capabilities);
+        Some((adapter_id, report))
+    }
+
+    /// Line and column numbering the adapter uses; the client converts it,
+    /// so everything the session reports is 1-based regardless
+    pub async fn positions(&self) -> crate::dap::positions::PositionBase {
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        client.positions().await
+    }
+
+    /// Checksum algorithms the adapter verifies sources with
+    pub async fn supported_checksum_algorithms(&self) -> Vec<String> {
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        client
+            .capabilities()
+            .await
+            .and_then(|caps| caps.supported_checksum_algorithms)
+            .unwrap_or_default()
+    }
+
+    /// Whether the adapter accepts the DAP `restart` request
+    pub async fn supports_restart(&self) -> bool {
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        client
+            .capabilities()
+            .await
+            .and_then(|caps| caps.supports_restart_request)
+            .unwrap_or(false)
+    }
+
+    /// Start the program again on the already running adapter
+    ///
+    /// Used when a warm adapter is reused: the adapter process and connection
+    /// stay up and only the debuggee is relaunched with `launch_args`.
+    /// Source and function breakpoints stay set in the adapter; instruction
+    /// breakpoints are re-armed only where their addresses may still be valid.
+    /// Thread state and cached sources (which may have been edited since) are
+    /// reset.
+    pub async fn restart(
+        &self,
+        launch_args: serde_json::Value,
+        startup_saved: Duration,
+    ) -> Result<()> {
+        self.restart_with(launch_args).await?;
+        self.set_reused_startup(startup_saved).await;
+        Ok(())
+    }
+
+    /// Start the program again on the running adapter with the launch
+    /// arguments it was started with (see `restart`)
+    ///
+    /// Needs an adapter that supports the DAP `restart` request; the
+    /// arguments carry every language-specific option of the start (Delve's
+    /// substitutePath and output, debugpy's justMyCode and environment).
+    pub async fn restart_in_place(&self) -> Result<()> {
+        let launch_args = self.launch_arguments.read().await.clone().ok_or_else(|| {
+            crate::Error::InvalidState(format!(
+                "Session {} has not launched a program yet",
+                self.id
+            ))
+        })?;
+        self.restart_with(launch_args).await
+    }
+
+    /// Launch arguments the adapter was last given
+    pub async fn launch_arguments(&self) -> Option<serde_json::Value> {
+        self.launch_arguments.read().await.clone()
+    }
+
+    async fn restart_with(&self, launch_args: serde_json::Value) -> Result<()> {
+        *self.launch_arguments.write().await = Some(launch_args.clone());
+        // The last run's output goes before the state is cleared
+        self.flush_output().await;
+        let launch_sources = self.launch_snapshot().await;
+        {
+            let mut state = self.state.write().await;
+            state.set_state(DebugState::Launching);
+            state.threads.clear();
+            state.thread_states.clear();
+            state.exception_capture = None;
+            state.crash_report = None;
+            state.breakpoint_summary = None;
+            state.exit_diagnosis = None;
+            state.disconnecting = false;
+            state.post_mortem = None;
+            state.output_tail.clear();
+            state.output_log.clear();
+            state.startup_output.clear();
+            state.launched = false;
+            state.disassembly_window = None;
+            state.memory_changes.clear();
+            // The relaunched program is a new process, sampled anew
+            state.processes.clear();
+            state.resource_usage = None;
+            let adapter_id = state
+                .transcript
+                .launches
+                .last()
+                .map_or_else(|| self.language.clone(), |l| l.adapter_id.clone());
+            state.transcript.record_launch(&adapter_id, &launch_args);
+            state.launch_sources = launch_sources;
+            state.expressions = launch_args["expressions"].as_str().map(str::to_string);
+        }
+        self.source_cache.write().await.clear();
+
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+        client.restart(launch_args).await?;
+        drop(client);
+        self.flush_output().await;
+
+        // A stop at entry may already have been reported
+        let mut state = self.state.write().await;
+        state.launched = true;
+        if state.state == DebugState::Launching {
+            state.set_state(DebugState::Running);
+        }
+        drop(state);
+
+        self.rearm_instruction_breakpoints().await;
+        Ok(())
+    }
+
+    /// Startup time saved by reusing a warm adapter (None for a cold start)
+    pub async fn reused_startup(&self) -> Option<Duration> {
+        *self.reused_startup.read().await
+    }
+
+    /// Record that the session started on a warm adapter, saving `saved`
+    pub async fn set_reused_startup(&self, saved: Duration) {
+        *self.reused_startup.write().await = Some(saved);
+    }
+
+    /// Enable, retune or disable periodic keep-alive pings on the adapter connection
+    ///
+    /// Long-lived sessions (e.g. attached to a remote Delve) can be dropped by
+    /// intermediaries without either side noticing. While enabled, a lightweight
+    /// `threads` request is sent every `interval`; if it fails or doesn't answer
+    /// in time, the session is marked Failed with the reason.
+    ///
+    /// Passing `None` stops the keep-alive loop at its next tick.
+    pub async fn set_keep_alive(self: &Arc<Self>, interval: Option<Duration>) {
+        *self.keep_alive_interval.write().await = interval;
+
+        if interval.is_none() || self.keep_alive_running.swap(true, Ordering::SeqCst) {
+            return;
+        }
+
+        info!(
+            "💓 Starting keep-alive for session {} (interval: {:?})",
+            self.id, interval
+        );
+        tokio::spawn(Self::keep_alive_loop(Arc::downgrade(self)));
+    }
+
+    /// Keep-alive loop - only holds a weak reference so a removed session stops it
+    async fn keep_alive_loop(session: Weak<Self>) {
+        loop {
+            let interval = match session.upgrade() {
+                Some(session) => {
+                    let interval = *session.keep_alive_interval.read().await;
+                    if interval.is_none() {
+                        session.keep_alive_running.store(false, Ordering::SeqCst);
+                    }
+                    interval
+                }
+                None => None,
+            };
+            let Some(interval) = interval else {
+                return;
+            };
+
+            tokio::time::sleep(interval).await;
+
+            let Some(session) = session.upgrade() else {
+                return;
+            };
+
+            match session.get_state().await {
+                DebugState::Terminated | DebugState::Failed { .. } => {
+                    session.keep_alive_running.store(false, Ordering::SeqCst);
+                    return;
+                }
+                // Nothing to keep alive until the adapter handshake is done
+                DebugState::NotStarted | DebugState::Initializing => continue,
+                _ => {}
+            }
+
+            let client_arc = session.get_debug_client().await;
+            let client = client_arc.read().await;
+            let ping_timeout = interval.min(KEEP_ALIVE_MAX_TIMEOUT);
+            let error = match tokio::time::timeout(ping_timeout, client.threads()).await {
+                Ok(Ok(_)) => continue,
+                Ok(Err(e)) => e.to_string(),
+                Err(_) => format!("no response within {:?}", ping_timeout),
+            };
+            drop(client);
+
+            warn!("💔 Keep-alive failed for session {}: {}", session.id, error);
+            let mut state = session.state.write().await;
+            state.set_state(DebugState::Failed {
+                error: format!("Adapter connection lost (keep-alive failed: {})", error),
+            });
+            session.keep_alive_running.store(false, Ordering::SeqCst);
+            return;
+        }
+    }
+
+    /// Read the debuggee's memory and CPU time every `interval` (see
+    /// `resource_usage`) until the session ends or another process is
+    /// sampled; only holds a weak reference so a removed session stops it
+    async fn resource_sampling_loop(
+        state: Weak<RwLock<SessionState>>,
+        pid: u32,
+        interval: Duration,
+    ) {
+        let sampled = |state: &SessionState| {
+            state
+                .resource_usage
+                .as_ref()
+                .is_some_and(|usage| usage.pid == pid && !usage.is_ended())
+        };
+        loop {
+            tokio::time::sleep(interval).await;
+            let Some(state) = state.upgrade() else {
+                return;
+            };
+            {
+                let mut state = state.write().await;
+                if !sampled(&state) {
+                    return;
+                }
+                match &state.state {
+                    DebugState::Terminated | DebugState::Failed { .. } => return,
+                    DebugState::Stopped { .. } => {
+                        if let Some(usage) = &mut state.resource_usage {
+                            usage.freeze();
+                        }
+                        continue;
+                    }
+                    _ => {}
+                }
+            }
+
+            let reading = crate::process::usage::read_usage(pid);
+            let mut state = state.write().await;
+            if !sampled(&state) {
+                return;
+            }
+            // Stopped while reading: the reading may be from either side of
+            // the stop, and isn't kept
+            match &state.state {
+                DebugState::Terminated | DebugState::Failed { .. } => return,
+                DebugState::Stopped { .. } => {
+                    if let Some(usage) = &mut state.resource_usage {
+                        usage.freeze();
+                    }
+                    continue;
+                }
+                _ => {}
+            }
+            let at_ms = state.transcript.elapsed_ms();
+            let Some(usage) = &mut state.resource_usage else {
+                return;
+            };
+            match reading {
+                Some(reading) => usage.record(at_ms, reading),
+                None => {
+                    info!("📉 Stopped sampling process {}: it can't be read", pid);
+                    usage.end(format!(
+                        "process {} can't be read from /proc: it exited, or runs where this server can't see it",
+                        pid
+                    ));
+                    return;
+                }
+            }
+        }
+    }
+
+    /// Enable or disable polling for stops the event stream missed
+    ///
+    /// Safety net for adapters or transports that occasionally drop `stopped`
+    /// events. While the session is Running, every `interval` the poll asks the
+    /// adapter for its threads and requests a stack trace of each thread not
+    /// known to be stopped; adapters reject stack traces of running threads, so
+    /// a successful one means the thread is stopped and the event was lost.
+    ///
+    /// Cost: one `threads` request plus one `stackTrace` request per running
+    /// thread on every tick, which competes with user requests on the same
+    /// connection. Keep the interval in the seconds range.
+    pub async fn set_stop_polling(self: &Arc<Self>, interval: Option<Duration>) {
+        *self.stop_poll_interval.write().await = interval;
+
+        if interval.is_none() || self.stop_poll_running.swap(true, Ordering::SeqCst) {
+            return;
+        }
+
+        info!(
+            "🔍 Starting stop-detection polling for session {} (interval: {:?})",
+            self.id, interval
+        );
+        tokio::spawn(Self::stop_poll_loop(Arc::downgrade(self)));
+    }
+
+    /// Stop-detection loop - only holds a weak reference so a removed session stops it
+    async fn stop_poll_loop(session: Weak<Self>) {
+        loop {
+            let interval = match session.upgrade() {
+                Some(session) => {
+                    let interval = *session.stop_poll_interval.read().await;
+                    if interval.is_none() {
+                        session.stop_poll_running.store(false, Ordering::SeqCst);
+                    }
+                    interval
+                }
+                None => None,
+            };
+            let Some(interval) = interval else {
+                return;
+            };
+
+            tokio::time::sleep(interval).await;
+
+            let Some(session) = session.upgrade() else {
+                return;
+            };
+
+            match session.get_state().await {
+                DebugState::Terminated | DebugState::Failed { .. } => {
+                    session.stop_poll_running.store(false, Ordering::SeqCst);
+                    return;
+                }
+                DebugState::Running => {}
+                // Only a Running session can have missed a stop
+                _ => continue,
+            }
+
+            if let Err(e) = session.poll_for_missed_stop(interval).await {
+                warn!(
+                    "Stop-detection poll failed for session {}: {}",
+                    session.id, e
+                );
+            }
+        }
+    }
+
+    /// Look for a stopped thread the session still believes is running
+    ///
+    /// When one is found a synthetic `stopped` event is delivered through the
+    /// client, so state handlers and waiters react exactly as for a real one.
+    /// Returns the thread found, if any.
+    async fn poll_for_missed_stop(&self, interval: Duration) -> Result<Option<i32>> {
+        let timeout = interval.min(STOP_POLL_MAX_TIMEOUT);
+        let client_arc = self.get_debug_client().await;
+        let client = client_arc.read().await;
+
+        let threads = tokio::time::timeout(timeout, client.threads())
+            .await
+            .map_err(|_| {
+                crate::Error::Dap(format!("threads: no response within {:?}", timeout))
+            })??;
+
+        for thread in threads {
+            let known_stopped = matches!(
+                self.state.read().await.thread_states.get(&thread.id),
+                Some(ThreadState::Stopped { .. })
+            );
+            if known_stopped {
+                continue;
+            }
+
+            let top = tokio::time::timeout(timeout, client.top_frame(thread.id)).await;
+            if !matches!(top, Ok(Ok(Some(_)))) {
+                continue;
+            }
+
+            // The event stream may have caught up while we were polling
+            if !matches!(self.get_state().await, DebugState::Running) {
+                return Ok(None);
+            }
+
+            warn!(
+                "🔍 Session {}: thread {} is stopped but no 'stopped' event was received, reconciling",
+                self.id, thread.id
+            );
+            client
+                .emit_event(Event {
+                    seq: 0,
+                    event: "stopped".to_string(),
+                    body: Some(serde_json::json!({
+                        "reason": "unknown",
+                        "description": "Stop detected by polling (event was missed)",
+                        "threadId": thread.id,
+                        "allThreadsStopped": false
+                    })),
+                })
+                .await;
+            return Ok(Some(thread.id));
+        }
+
+        Ok(None)
+    }
+
+    pub async fn get_state(&self) -> DebugState {
+        let state = self.state.read().await;
+        state.state.clone()
+    }
+
+    /// Wait up to `timeout` for the state to differ from `from`, returning the
+    /// state at that point
+    pub async fn wait_for_state_change(&self, from: &DebugState, timeout: Duration) -> DebugState {
+        let deadline = tokio::time::Instant::now() + timeout;
+        let mut changes = self.state.read().await.subscribe();
+
+        loop {
+            let current = self.get_state().await;
+            if &current != from {
+                return current;
+            }
+            match tokio::time::timeout_at(deadline, changes.changed()).await {
+                Ok(Ok(())) => continue,
+                _ => return current,
+            }
+        }
+    }
+
+    /// Events after `events_seq` `since` (see `event_log`), waiting up to
+    /// `timeout` for one if there is none yet
+    ///
+    /// Also returns how many of them were dropped from the log, and the
+    /// current `events_seq`.
+    pub async fn events_since(
+        &self,
+        since: u64,
+        timeout: Duration,
+    ) -> (Vec<SessionEvent>, u64, u64) {
+        let deadline = tokio::time::Instant::now() + timeout;
+        let mut changes = self.state.read().await.subscribe();
+
+        loop {
+            {
+                let state = self.state.read().await;
+                if state.events_seq > since {
+                    let (events, missed) = state.events.since(since, state.events_seq);
+                    return (events, missed, state.events_seq);
+                }
+            }
+            match tokio::time::timeout_at(deadline, changes.changed()).await {
+                Ok(Ok(())) => continue,
+                _ => return (Vec::new(), 0, self.events_seq().await),
+            }
+        }
+    }
+
+    /// Number of state changes recorded so far
+    pub async fn events_seq(&self) -> u64 {
+        self.state.read().await.events_seq
+    }
+
+    /// Wait up to `timeout` for the session to stop or terminate after state
+    /// change `since`; None if it didn't
+    pub async fn wait_for_stop_since(&self, since: u64, timeout: Duration) -> Option<DebugState> {
+        let deadline = tokio::time::Instant::now() + timeout;
+        let mut changes = self.state.read().await.subscribe();
+
+        loop {
+            {
+                let state = self.state.read().await;
+                if state.events_seq > since
+                    && matches!(
+                        state.state,
+                        DebugState::Stopped { .. } | DebugState::Terminated
+                    )
+                {
+                    return Some(state.state.clone());
+                }
+            }
+            match tokio::time::timeout_at(deadline, changes.changed()).await {
+                Ok(Ok(())) => continue,
+                _ => return None,
+            }
+        }
+    }
+
+    /// Cursor of recorded state changes, and the delay to suggest to a client
+    /// polling a running session
+    pub async fn poll_hint(&self) -> (u64, Option<u64>) {
+        let mut state = self.state.write().await;
+        let retry_after = (state.state == DebugState::Running).then(|| state.next_poll_delay());
+        (state.events_seq, retry_after)
+    }
+
+    pub async fn get_full_state(&self) -> SessionState {
+        let state = self.state.read().await;
+        state.clone()
+    }
+
+    /// The post-mortem taken when the program ended; None before that
+    pub async fn post_mortem(&self) -> Option<SessionPostMortem> {
+        let post_mortem = self.state.read().await.post_mortem.clone()?;
+        Some(SessionPostMortem {
+            session_id: self.id.clone(),
+            language: self.language.clone(),
+            program: self.program.clone(),
+            removed: false,
+            post_mortem,
+        })
+    }
+}
+
+/// Import status of a watch or `changed()` entry from whether its
+/// breakpoint was verified, or is `pending` initialization
+fn applied_status(verified: Result<bool>, pending: bool) -> ImportStatus {
+    match verified {
+        Ok(_) if pending => ImportStatus::Pending,
+        Ok(true) => ImportStatus::Verified,
+        Ok(false) => ImportStatus::Unverified,
+        Err(e) => ImportStatus::Failed {
+            reason: e.to_string(),
+        },
+    }
+}
+
+/// Extract (threadId, reason, allThreadsStopped) from a 'stopped' event body
+///
+/// Per the DAP spec an omitted `allThreadsStopped` means only the named thread stopped.
+fn parse_stopped_event(body: &serde_json::Value) -> (i32, String, bool) {
+    let thread_id = body
+        .get("threadId")
+        .and_then(|v| v.as_i64())
+        .map(|v| v as i32)
+        .unwrap_or(1);
+    let reason = body
+        .get("reason")
+        .and_then(|v| v.as_str())
+        .unwrap_or("unknown")
+        .to_string();
+    let all_threads_stopped = body
+        .get("allThreadsStopped")
+        .and_then(|v| v.as_bool())
+        .unwrap_or(false);
+
+    (thread_id, reason, all_threads_stopped)
+}
+
+/// Ids of the breakpoints that caused a stop (absent for non-breakpoint stops
+/// and adapters that don't report them)
+fn parse_hit_breakpoint_ids(body: &serde_json::Value) -> Vec<i32> {
+    body.get("hitBreakpointIds")
+        .and_then(|v| v.as_array())
+        .map(|ids| {
+            ids.iter()
+                .filter_map(|id| id.as_i64())
+                .map(|id| id as i32)
+                .collect()
+        })
+        .unwrap_or_default()
+}
+
+/// Fail for ids that aren't breakpoints of the session
+fn check_breakpoint_ids(state: &SessionState, ids: Option<&[i32]>) -> Result<()> {
+    let known: Vec<i32> = state
+        .breakpoints
+        .values()
+        .flatten()
+        .filter_map(|bp| bp.id)
+        .chain(state.function_breakpoints.iter().filter_map(|bp| bp.id))
+        .chain(state.instruction_breakpoints.iter().filter_map(|bp| bp.id))
+        .collect();
+    let unknown: Vec<String> = ids
+        .unwrap_or_default()
+        .iter()
+        .filter(|id| !known.contains(id))
+        .map(|id| id.to_string())
+        .collect();
+    if unknown.is_empty() {
+        return Ok(());
+    }
+    Err(crate::Error::InvalidRequest(format!(
+        "No breakpoint with id {}; debugger_list_breakpoints shows the ids of the session's breakpoints",
+        unknown.join(", ")
+    )))
+}
+
+/// DAP form of a tracked function breakpoint
+fn to_function_breakpoint(bp: &FunctionBreakpoint) -> crate::dap::types::FunctionBreakpoint {
+    crate::dap::types::FunctionBreakpoint {
+        name: bp.name.clone(),
+        condition: bp.condition.clone(),
+        hit_condition: bp.hit_condition.clone(),
+    }
+}
+
+/// DAP form of a tracked instruction breakpoint
+fn to_instruction_breakpoint(
+    bp: &InstructionBreakpoint,
+) -> crate::dap::types::InstructionBreakpoint {
+    crate::dap::types::InstructionBreakpoint {
+        instruction_reference: bp.instruction_reference.clone(),
+        offset: bp.offset,
+        condition: bp.condition.clone(),
+        hit_condition: bp.hit_condition.clone(),
+    }
+}
+
+/// Modification time of the debugged program, if it is a file that exists
+fn program_modified(program: &str) -> Option<std::time::SystemTime> {
+    std::fs::metadata(program).and_then(|m| m.modified()).ok()
+}
+
+/// What became of an entry stop in a session that auto-continues
+enum EntryStop {
+    Continued,
+    /// Kept as a hit of these breakpoints, on the entry line
+    Breakpoints(Vec<i32>),
+}
+
+/// Source path of a frame, if it has one
+fn frame_path(frame: &StackFrame) -> Option<&str> {
+    frame.source.as_ref().and_then(|s| s.path.as_deref())
+}
+
+/// A function invocation: the top frame's function and file, and the caller's
+/// function and line, which stay put while the function runs (telling a
+/// recursive call's return apart from the function itself)
+#[derive(Debug, Clone, PartialEq)]
+struct FunctionKey {
+    name: String,
+    path: Option<String>,
+    caller: Option<(String, i32)>,
+}
+
+impl FunctionKey {
+    fn of(frames: &[StackFrame]) -> Option<Self> {
+        let top = frames.first()?;
+        Some(Self {
+            name: top.name.clone(),
+            path: frame_path(top).map(str::to_string),
+            caller: frames.get(1).map(|f| (f.name.clone(), f.line)),
+        })
+    }
+}
+
+/// Whether a stop ends `step_to_line`, and how
+fn line_step_end(
+    function: &FunctionKey,
+    frames: &[StackFrame],
+    reason: &str,
+    line: i32,
+) -> Option<LineStepEnd> {
+    if reason != "step" {
+        return Some(LineStepEnd::Interrupted {
+            reason: reason.to_string(),
+        });
+    }
+    if FunctionKey::of(frames).as_ref() != Some(function) {
+        return Some(LineStepEnd::Returned);
+    }
+    (frames[0].line == line).then_some(LineStepEnd::Reached)
+}
+
+/// Exit code from an 'exited' event body
+fn parse_exit_code(body: Option<&serde_json::Value>) -> Option<i64> {
+    body.and_then(|b| b.get("exitCode"))
+        .and_then(|v| v.as_i64())
+}
+
+/// Extract (threadId, allThreadsContinued) from a 'continued' event body
+///
+/// Per the DAP spec an omitted `allThreadsContinued` means all threads resumed.
+fn parse_continued_event(body: Option<&serde_json::Value>) -> (i32, bool) {
+    let thread_id = body
+        .and_then(|b| b.get("threadId"))
+        .and_then(|v| v.as_i64())
+        .map(|v| v as i32)
+        .unwrap_or(1);
+    let all_threads_continued = body
+        .and_then(|b| b.get("allThreadsContinued"))
+        .and_then(|v| v.as_bool())
+        .unwrap_or(true);
+
+    (thread_id, all_threads_continued)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::dap::transport_trait::DapTransportTrait;
+    use crate::dap::types::*;
+    use crate::debug::breakpoint_io::BreakpointEntry;
+    use crate::debug::caller_filter::CallerConstraint;
+    use crate::Error;
+    use mockall::mock;
+    use serde_json::json;
+
+    mock! {
+        pub TestTransport {}
+
+        #[async_trait::async_trait]
+        impl DapTransportTrait for TestTransport {
+            async fn read_message(&mut self) -> Result<Message>;
+            async fn write_message(&mut self, msg: &Message) -> Result<()>;
+        }
+    }
+
+    fn create_mock_with_response(response: Response) -> MockTestTransport {
+        let mut mock = MockTestTransport::new();
+        mock.expect_write_message().times(1).returning(|_| Ok(()));
+        mock.expect_read_message()
+            .times(1)
+            .return_once(move || Ok(Message::Response(response)));
+        mock.expect_read_message()
+            .returning(|| Err(Error::Dap("Connection closed".to_string())));
+        mock
+    }
+
+    fn create_empty_mock() -> MockTestTransport {
+        let mut mock = MockTestTransport::new();
+        mock.expect_read_message()
+            .returning(|| Err(Error::Dap("Connection closed".to_string())));
+        mock
+    }
+
+    #[tokio::test]
+    async fn test_session_new() {
+        let mock_transport = create_empty_mock();
+        let client = DapClient::new_with_transport(Box::new(mock_transport), None)
+            .await
+            .unwrap();
+
+        let session = DebugSession::new("python".to_string(), "test.py".to_string(), client)
+            .await
+            .unwrap();
+
+        assert_eq!(session.language, "python");
+        assert_eq!(session.program, "test.py");
+        assert!(!session.id.is_empty());
+    }
+
+    #[tokio::test]
+    async fn test_session_initialize() {
+        let response = Response {
+            seq: 1,
+            request_seq: 1,
+            command: "initialize".to_string(),
+            success: true,
+            message: None,
+            body: Some(json!({"supportsConfigurationDoneRequest": true})),
+        };
+
+        let mock_transport = create_mock_with_response(response);
+        let client = DapClient::new_with_transport(Box::new(mock_transport), None)
+            .await
+            .unwrap();
+        let session = DebugSession::new("python".to_string(), "test.py".to_string(), client)
+            .await
+            .unwrap();
+
+        session.initialize("debugpy").await.unwrap();
+
+        let state = session.get_state().await;
+        assert_eq!(state, DebugState::Initialized);
+    }
+
+    // Note: launch test removed due to async complexity with mocked transport
+    // The launch functionality is indirectly tested through integration tests
+
+    // Note: set_breakpoint test removed due to async complexity with mocked transport
+    // The breakpoint functionality is indirectly tested through integration tests
+
+    // Note: continue_execution test removed due to async complexity with mocked transport
+    // The continue functionality is indirectly tested through integration tests
+
+    // Note: stack_trace test removed due to async complexity with mocked transport
+    // The stack trace functionality is indirectly tested through integration tests
+
+    // Note: evaluate test removed due to async complexity with mocked transport
+    // The evaluate functionality is indirectly tested through integration tests
+
+    // Note: disconnect test removed due to async complexity with mocked transport
+    // The disconnect functionality is indirectly tested through integration tests
+
+    #[tokio::test]
+    async fn test_keep_alive_failure_marks_session_failed() {
+        // Transport accepts writes but never answers, so the ping times out
+        let mut mock_transport = create_empty_mock();
+        mock_transport.expect_write_message().returning(|_| Ok(()));
+        let client = DapClient::new_with_transport(Box::new(mock_transport), None)
+            .await
+            .unwrap();
+        let session = Arc::new(
+            DebugSession::new("go".to_string(), "main.go".to_string(), client)
+                .await
+                .unwrap(),
+        );
+        session.state.write().await.set_state(DebugState::Running);
+
+        session
+            .set_keep_alive(Some(Duration::from_millis(20)))
+            .await;
+        tokio::time::sleep(Duration::from_millis(200)).await;
+
+        match session.get_state().await {
+            DebugState::Failed { error } => assert!(error.contains("keep-alive")),
+            other => panic!("Expected Failed state, got {:?}", other),
+        }
+    }
+
+    #[tokio::test]
+    async fn test_resource_sampling_freezes_while_stopped() {
+        let state = Arc::new(RwLock::new(SessionState::new()));
+        {
+            let mut state = state.write().await;
+            state.add_process(DebuggeeProcess {
+                name: "test".to_string(),
+                pid: Some(std::process::id() as i64),
+                start_method: None,
+            });
+            state.set_state(DebugState::Running);
+        }
+        let pid = state.write().await.start_resource_sampling().unwrap();
+        assert_eq!(state.write().await.start_resource_sampling(), None);
+        let sampling = tokio::spawn(DebugSession::resource_sampling_loop(
+            Arc::downgrade(&state),
+            pid,
+            Duration::from_millis(10),
+        ));
+
+        tokio::time::sleep(Duration::from_millis(100)).await;
+        let samples = state
+            .read()
+            .await
+            .resource_usage
+            .as_ref()
+            .unwrap()
+            .samples();
+        assert!(samples.len() >= 2, "{:?}", samples);
+        assert!(samples[0].rss_bytes > 0);
+
+        state.write().await.set_state(DebugState::Stopped {
+            thread_id: 1,
+            reason: "breakpoint".to_string(),
+        });
+        tokio::time::sleep(Duration::from_millis(50)).await;
+        assert!(state.read().await.resource_summary().unwrap().frozen);
+        tokio::time::sleep(Duration::from_millis(50)).await;
+        let stopped = state.read().await.resource_usage.clone().unwrap();
+        tokio::time::sleep(Duration::from_millis(50)).await;
+        let still = state.read().await.resource_usage.clone().unwrap();
+        assert_eq!(still.samples().len(), stopped.samples().len());
+
+        state.write().await.set_state(DebugState::Terminated);
+        tokio::time::timeout(Duration::from_secs(1), sampling)
+            .await
+            .unwrap()
+            .unwrap();
+    }
+
+    #[tokio::test]
+    async fn test_keep_alive_disabled_by_default() {
+        let mock_transport = create_empty_mock();
+        let client = DapClient::new_with_transport(Box::new(mock_transport), None)
+            .await
+            .unwrap();
+        let session = DebugSession::new("go".to_string(), "main.go".to_string(), client)
+            .await
+            .unwrap();
+
+        assert!(session.keep_alive_interval.read().await.is_none());
+        assert!(!session.keep_alive_running.load(Ordering::SeqCst));
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_wait_for_state_change() {
+        let mock_transport = create_empty_mock();
+        let client = DapClient::new_with_transport(Box::new(mock_transport), None)
+            .await
+            .unwrap();
+        let session = Arc::new(
+            DebugSession::new("go".to_string(), "main.go".to_string(), client)
+                .await
+                .unwrap(),
+        );
+        session.state.write().await.set_state(DebugState::Running);
+
+        // Nothing happens: returns the unchanged state after the timeout
+        let state = session
+            .wait_for_state_change(&DebugState::Running, Duration::from_millis(50))
+            .await;
+        assert_eq!(state, DebugState::Running);
+        assert_eq!(session.poll_hint().await, (1, Some(100)));
+        assert_eq!(session.poll_hint().await, (1, Some(200)));
+
+        let stopper = session.clone();
+        tokio::spawn(async move {
+            tokio::time::sleep(Duration::from_millis(50)).await;
+            stopper
+                .state
+                .write()
+                .await
+                .apply_stopped(1, "breakpoint".to_string(), true);
+        });
+        let state = session
+            .wait_for_state_change(&DebugState::Running, Duration::from_secs(5))
+            .await;
+        assert!(matches!(state, DebugState::Stopped { thread_id: 1, .. }));
+        assert_eq!(session.poll_hint().await, (2, None));
+    }
+
+    #[tokio::test]
+    async fn test_set_breakpoint_enabled_validation() {
+        let mock_transport = create_empty_mock();
+        let client = DapClient::new_with_transport(Box::new(mock_transport), None)
+            .await
+            .unwrap();
+        let session = DebugSession::new("python".to_string(), "test.py".to_string(), client)
+            .await
+            .unwrap();
+
+        // Not allowed before the session is running
+        let result = session.set_breakpoint_enabled(1, false).await;
+        assert!(matches!(result, Err(crate::Error::InvalidState(_))));
+
+        // Unknown breakpoint id
+        session.state.write().await.set_state(DebugState::Running);
+        let result = session.set_breakpoint_enabled(1, false).await;
+        assert!(matches!(result, Err(crate::Error::InvalidRequest(_))));
+    }
+
+    /// Mock adapter that emits the given events once released, then closes
+    fn create_event_mock(events: Vec<Event>) -> (MockTestTransport, std::sync::mpsc::Sender<()>) {
+        let (release_tx, release_rx) = std::sync::mpsc::channel::<()>();
+        let release_rx = std::sync::Mutex::new(Some(release_rx));
+        let mut queue: std::collections::VecDeque<Event> = events.into();
+
+        let mut mock = MockTestTransport::new();
+        mock.expect_read_message().returning(move || {
+            // Hold the first event back until the handlers are registered
+            if let Some(rx) = release_rx.lock().unwrap().take() {
+                let _ = rx.recv();
+            }
+            // Space events out so each handler's state update lands in order
+            std::thread::sleep(Duration::from_millis(10));
+            match queue.pop_front() {
+                Some(event) => Ok(Message::Event(event)),
+                None => Err(Error::Dap("Connection closed".to_string())),
+            }
+        });
+
+        (mock, release_tx)
+    }
+
+    fn event(seq: i32, name: &str, body: serde_json::Value) -> Event {
+        Event {
+            seq,
+            event: name.to_string(),
+            body: Some(body),
+        }
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_mixed_thread_states_from_adapter_events() {
+        let (mock_transport, release) = create_event_mock(vec![
+            event(1, "thread", json!({"reason": "started", "threadId": 1})),
+            event(2, "thread", json!({"reason": "started", "threadId": 2})),
+            event(
+                3,
+                "continued",
+                json!({"threadId": 1, "allThreadsContinued": true}),
+            ),
+            // Only thread 2 stops (allThreadsStopped: false)
+            event(
+                4,
+                "stopped",
+                json!({"reason": "breakpoint", "threadId": 2, "allThreadsStopped": false}),
+            ),
+        ]);
+        let client = DapClient::new_with_transport(Box::new(mock_transport), None)
+            .await
+            .unwrap();
+        let session = DebugSession::new("go".to_string(), "main.go".to_string(), client)
+            .await
+            .unwrap();
+
+        {
+            let client_arc = session.get_debug_client().await;
+            let client = client_arc.read().await;
+            session.register_state_handlers(&client).await;
+        }
+        release.send(()).unwrap();
+        tokio::time::sleep(Duration::from_millis(200)).await;
+
+        let state = session.get_full_state().await;
+        assert_eq!(state.thread_states.get(&1), Some(&ThreadState::Running));
+        assert_eq!(
+            state.thread_states.get(&2),
+            Some(&ThreadState::Stopped {
+                reason: "breakpoint".to_string()
+            })
+        );
+        assert!(matches!(
+            state.state,
+            DebugState::Stopped { thread_id: 2, .. }
+        ));
+
+        // Tools targeting the running thread get ThreadRunning, the stopped one is fine
+        assert!(matches!(
+            session.ensure_thread_stopped(1).await,
+            Err(Error::ThreadRunning(1))
+        ));
+        assert!(session.ensure_thread_stopped(2).await.is_ok());
+        assert!(matches!(
+            session.stack_trace_for_thread(1).await,
+            Err(Error::ThreadRunning(1))
+        ));
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_single_thread_continue_keeps_session_stopped() {
+        let (mock_transport, release) = create_event_mock(vec![
+            event(
+                1,
+                "stopped",
+                json!({"reason": "breakpoint", "threadId": 1, "allThreadsStopped": true}),
+            ),
+            event(2, "thread", json!({"reason": "started", "threadId": 2})),
+            event(
+                3,
+                "stopped",
+                json!({"reason": "pause", "threadId": 2, "allThreadsStopped": true}),
+            ),
+            event(
+                4,
+                "continued",
+                json!({"threadId": 2, "allThreadsContinued": false}),
+            ),
+            event(5, "thread", json!({"reason": "exited", "threadId": 2})),
+        ]);
+        let client = DapClient::new_with_transport(Box::new(mock_transport), None)
+            .await
+            .unwrap();
+        let session = DebugSession::new("python".to_string(), "app.py".to_string(), client)
+            .await
+            .unwrap();
+
+        {
+            let client_arc = session.get_debug_client().await;
+            let client = client_arc.read().await;
+            session.register_state_handlers(&client).await;
+        }
+        release.send(()).unwrap();
+        tokio::time::sleep(Duration::from_millis(200)).await;
+
+        let state = session.get_full_state().await;
+        assert_eq!(state.threads, vec![1]);
+        assert!(matches!(
+            state.state,
+            DebugState::Stopped { thread_id: 1, .. }
+        ));
+        assert!(session.ensure_thread_stopped(1).await.is_ok());
+    }
+
+    #[test]
+    fn test_parse_event_defaults() {
+        let (thread_id, reason, all) = parse_stopped_event(&json!({"threadId": 4}));
+        assert_eq!((thread_id, reason.as_str(), all), (4, "unknown", false));
+
+        assert_eq!(parse_continued_event(None), (1, true));
+        assert_eq!(
+            parse_continued_event(Some(&json!({"threadId": 3, "allThreadsContinued": false}))),
+            (3, false)
+        );
+    }
+
+    #[tokio::test]
+    async fn test_resolve_source_falls_back_to_adapter_and_caches() {
+        let response = Response {
+            seq: 1,
+            request_seq: 1,
+            command: "source".to_string(),
+            success: true,
+            message: None,
+            body: Some(json!({"content": "package errors\n"})),
+        };
+
+        // Only one source request is answered; the second lookup must hit the cache
+        let mock_transport = create_mock_with_response(response);
+        let client = DapClient::new_with_transport(Box::new(mock_transport), None)
+            .await
+            .unwrap();
+        let session = DebugSession::new("go".to_string(), "main.go".to_string(), client)
+            .await
+            .unwrap();
+
+        let source = Source {
+            name: None,
+            path: Some(
+                "/nonexistent/go/pkg/mod/github.com/pkg/errors@v0.9.1/errors.go".to_string(),
+            ),
+            source_reference: None,
+            checksums: None,
+        };
+
+        let resolved = session.resolve_source(&source).await;
+        assert_eq!(resolved.origin, SourceOrigin::Adapter);
+        assert_eq!(resolved.content.as_deref(), Some("package errors\n"));
+        assert_eq!(
+            resolved.module.as_deref(),
+            Some("github.com/pkg/errors@v0.9.1")
+        );
+
+        let cached = session.resolve_source(&source).await;
+        assert_eq!(cached, resolved);
+    }
+
+    /// Client connected to a fake adapter that answers `threads` with a single
+    /// thread and `stackTrace` according to whether that thread is stopped
+    async fn fake_adapter_client(thread_stopped: bool) -> DapClient {
+        use crate::dap::transport::DapTransport;
+
+        let listener = tokio::net::TcpListener::bind("127.0.0.1:0").await.unwrap();
+        let addr = listener.local_addr().unwrap();
+
+        tokio::spawn(async move {
+            let (stream, _) = listener.accept().await.unwrap();
+            stream.set_nodelay(true).unwrap();
+            let mut transport = DapTransport::new_socket(stream);
+            while let Ok(Message::Request(req)) = transport.read_message().await {
+                let (success, body) = match req.command.as_str() {
+                    "threads" => (true, json!({"threads": [{"id": 1, "name": "main"}]})),
+                    // Thread 2 is 50 frames deep, and paged
+                    "stackTrace" if req.arguments.as_ref().unwrap()["threadId"] == 2 => {
+                        let args = req.arguments.clone().unwrap_or_default();
+                        let start = args["startFrame"].as_i64().unwrap_or(0);
+                        let levels = args["levels"].as_i64().unwrap_or(50);
+                        let frames: Vec<serde_json::Value> = (start..(start + levels).min(50))
+                            .map(|i| json!({"id": 100 + i, "name": format!("depth{}", i), "line": i + 1, "column": 1}))
+                            .collect();
+                        (true, json!({"stackFrames": frames, "totalFrames": 50}))
+                    }
+                    // Thread 3 is stopped at line 12 of /w/main.go, running or not
+                    "stackTrace" if req.arguments.as_ref().unwrap()["threadId"] == 3 => (
+                        true,
+                        json!({"stackFrames": [{"id": 1, "name": "main", "source": {"path": "/w/main.go"}, "line": 12, "column": 1}]}),
+                    ),
+                    "stackTrace" if thread_stopped => (
+                        true,
+                        json!({"stackFrames": [{"id": 1, "name": "main", "line": 3, "column": 1}]}),
+                    ),
+                    "exceptionInfo" if thread_stopped => (
+                        true,
+                        json!({"exceptionId": "panic", "description": "index out of range", "breakMode": "unhandled"}),
+                    ),
+                    "scopes" if thread_stopped => (
+                        true,
+                        json!({"scopes": [
+                            {"name": "Locals", "variablesReference": 7, "expensive": false,
+                             "source": {"path": "/w/main.go"}, "line": 1, "endLine": 9},
+                            {"name": "Globals", "variablesReference": 8, "expensive": true}
+                        ]}),
+                    ),
+                    // Like Delve, moves breakpoints off `if` headers (line
+                    // 2 of files named `moved.go`)
+                    "setBreakpoints" => {
+                        let args = req.arguments.clone().unwrap_or_default();
+                        let moves = args["source"]["path"]
+                            .as_str()
+                            .is_some_and(|p| p.ends_with("moved.go"));
+                        let breakpoints: Vec<serde_json::Value> = args["breakpoints"]
+                            .as_array()
+                            .into_iter()
+                            .flatten()
+                            .enumerate()
+                            .map(|(i, bp)| {
+                                let line = bp["line"].as_i64().unwrap_or_default();
+                                let line = if moves && line == 2 { 3 } else { line };
+                                json!({"id": 100 + i, "verified": true, "line": line})
+                            })
+                            .collect();
+                        (true, json!({"breakpoints": breakpoints}))
+                    }
+                    // The struct `*c` points to
+                    "variables" if req.arguments.as_ref().unwrap()["variablesReference"] == 9 => (
+                        true,
+                        json!({"variables": [
+                            {"name": "name", "value": "\"calc\"", "variablesReference": 0},
+                            {"name": "total", "value": "42", "variablesReference": 0}
+                        ]}),
+                    ),
+                    "variables" => (
+                        true,
+                        json!({"variables": [{"name": "i", "value": "3", "type": "int", "variablesReference": 0}]}),
+                    ),
+                    // Knows `i`, and the pointers `c` and `none`
+                    "evaluate" if thread_stopped => {
+                        let args = req.arguments.clone().unwrap_or_default();
+                        let result = match args["expression"].as_str() {
+                            Some("i") => json!({"result": "3", "variablesReference": 0}),
+                            Some("c") => json!({
+                                "result": "(*main.Calculator)(0xc000010030)",
+                                "type": "*main.Calculator",
+                                "variablesReference": 10
+                            }),
+                            Some("*(c)") => json!({
+                                "result": "main.Calculator {name: \"calc\", total: 42}",
+                                "type": "main.Calculator",
+                                "variablesReference": 9
+                            }),
+                            Some("none") => json!({
+                                "result": "*main.Calculator nil",
+                                "type": "*main.Calculator",
+                                "variablesReference": 0
+                            }),
+                            _ => json!(null),
+                        };
+                        (!result.is_null() && args["frameId"] == 1, result)
+                    }
+                    // Knows `main.main` only
+                    "setFunctionBreakpoints" => {
+                        let args = req.arguments.clone().unwrap_or_default();
+                        let breakpoints: Vec<serde_json::Value> = args["breakpoints"]
+                            .as_array()
+                            .into_iter()
+                            .flatten()
+                            .map(|bp| match bp["name"].as_str() {
+                                Some("main.main") => json!({
+                                    "verified": true,
+                                    "source": {"path": "/w/main.go"},
+                                    "line": 5
+                                }),
+                                Some("main.(*Calculator).Multiply") => json!({
+                                    "verified": true,
+                                    "source": {"path": "/w/types.go"},
+                                    "line": 10
+                                }),
+                                _ => {
+                                    json!({"verified": false, "message": "could not find function"})
+                                }
+                            })
+                            .collect();
+                        (true, json!({"breakpoints": breakpoints}))
+                    }
+                    // The stopped fake refuses, to test what happens before resuming
+                    "continue" if !thread_stopped => (true, json!({"allThreadsContinued": true})),
+                    _ => (false, json!({})),
+                };
+                let response = Message::Response(Response {
+                    seq: req.seq + 1000,
+                    request_seq: req.seq,
+                    command: req.command,
+                    success,
+                    message: (!success).then(|| "thread is running".to_string()),
+                    body: Some(body),
+                });
+                if transport.write_message(&response).await.is_err() {
+                    break;
+                }
+            }
+        });
+
+        let socket = tokio::net::TcpStream::connect(addr).await.unwrap();
+        DapClient::from_socket(socket).await.unwrap()
+    }
+
+    async fn running_session(thread_stopped: bool) -> DebugSession {
+        let client = fake_adapter_client(thread_stopped).await;
+        let session = DebugSession::new("go".to_string(), "main.go".to_string(), client)
+            .await
+            .unwrap();
+        {
+            let client_arc = session.get_debug_client().await;
+            let client = client_arc.read().await;
+            session.register_state_handlers(&client).await;
+        }
+        let mut state = session.state.write().await;
+        state.add_thread(1);
+        state.set_state(DebugState::Running);
+        state.apply_continued(1, true);
+        drop(state);
+        session
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_stop_poll_reconciles_missed_stop() {
+        let session = running_session(true).await;
+
+        let found = session
+            .poll_for_missed_stop(Duration::from_millis(500))
+            .await
+            .unwrap();
+        assert_eq!(found, Some(1));
+
+        tokio::time::sleep(Duration::from_millis(100)).await;
+        assert!(matches!(
+            session.get_state().await,
+            DebugState::Stopped { thread_id: 1, .. }
+        ));
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_stale_source_judged_by_content_not_mtime() {
+        let session = running_session(false).await;
+        let dir = tempfile::tempdir().unwrap();
+        let path = dir.path().join("main.go");
+        std::fs::write(&path, "package main").unwrap();
+        let source = path.display().to_string();
+        {
+            let mut state = session.state.write().await;
+            state.transcript.record_launch("go", &json!({}));
+            state.launch_sources = source_check::launch_snapshot("go", &path, []);
+            state.verify_source = true;
+        }
+        let set_mtime = |at: std::time::SystemTime| {
+            let file = std::fs::File::options().write(true).open(&path).unwrap();
+            file.set_modified(at).unwrap();
+        };
+        let launched = session.state.read().await.launched_at().unwrap();
+
+        // Checked out again: newer mtime, same content
+        set_mtime(launched + Duration::from_secs(60));
+        session.set_breakpoint(source.clone(), 1).await.unwrap();
+        assert_eq!(session.source_warning(&source).await, None);
+
+        // Copied over with its mtime preserved: older mtime, new content
+        std::fs::write(&path, "package main\n\nfunc main() {}").unwrap();
+        set_mtime(launched - Duration::from_secs(60));
+        session.set_breakpoint(source.clone(), 3).await.unwrap();
+        let warning = session.source_warning(&source).await.unwrap();
+        assert!(warning.contains("changed since"), "{}", warning);
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_stop_poll_leaves_running_threads_alone() {
+        let session = running_session(false).await;
+
+        let found = session
+            .poll_for_missed_stop(Duration::from_millis(500))
+            .await
+            .unwrap();
+        assert_eq!(found, None);
+        assert_eq!(session.get_state().await, DebugState::Running);
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_scopes_keep_range_and_path_alias() {
+        let session = running_session(true).await;
+        session.alias_path("/w/main.go", "/W/main.go").await;
+
+        let scopes = session.scopes(1).await.unwrap();
+        assert_eq!(scopes.len(), 2);
+        assert_eq!(scopes[0].name, "Locals");
+        assert!(!scopes[0].expensive);
+        assert_eq!(scopes[0].line, Some(1));
+        assert_eq!(scopes[0].end_line, Some(9));
+        assert_eq!(
+            scopes[0].source.as_ref().and_then(|s| s.path.as_deref()),
+            Some("/W/main.go")
+        );
+        assert!(scopes[1].expensive);
+        assert!(scopes[1].source.is_none());
+    }
+
+    #[test]
+    fn test_line_step_ends() {
+        let frame = |name: &str, line: i32| StackFrame {
+            id: 0,
+            name: name.to_string(),
+            source: Some(Source {
+                name: None,
+                path: Some("/w/fizzbuzz.py".to_string()),
+                source_reference: None,
+                checksums: None,
+            }),
+            line,
+            column: 1,
+            end_line: None,
+            end_column: None,
+            instruction_pointer_reference: None,
+        };
+        let start = [frame("fizzbuzz", 9), frame("main", 20)];
+        let function = FunctionKey::of(&start).unwrap();
+
+        let next = [frame("fizzbuzz", 11), frame("main", 20)];
+        assert_eq!(line_step_end(&function, &next, "step", 14), None);
+        let buzz = [frame("fizzbuzz", 14), frame("main", 20)];
+        assert_eq!(
+            line_step_end(&function, &buzz, "step", 14),
+            Some(LineStepEnd::Reached)
+        );
+        assert_eq!(
+            line_step_end(&function, &buzz, "breakpoint", 14),
+            Some(LineStepEnd::Interrupted {
+                reason: "breakpoint".to_string()
+            })
+        );
+        // Back in main, or in the recursive caller of the same function
+        assert_eq!(
+            line_step_end(&function, &[frame("main", 21)], "step", 14),
+            Some(LineStepEnd::Returned)
+        );
+        let caller = [frame("fizzbuzz", 14), frame("fizzbuzz", 30)];
+        assert_eq!(
+            line_step_end(&function, &caller, "step", 14),
+            Some(LineStepEnd::Returned)
+        );
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_peek_reads_locals_without_evaluate() {
+        let session = running_session(true).await;
+        let names: Vec<String> = ["i", "i.len", "total"]
+            .iter()
+            .map(|n| n.to_string())
+            .collect();
+
+        // The fake adapter fails evaluate; peek never sends it
+        let peek = session.peek(&names, 1).await.unwrap();
+        assert_eq!(peek.values["i"].as_deref(), Some("3"));
+        assert_eq!(peek.missing, vec!["i.len", "total"]);
+
+        let expression = vec!["len(xs)".to_string()];
+        assert!(session.peek(&expression, 1).await.is_err());
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_failed_start_names_its_launch_phase() {
+        // The fake adapter refuses `initialize`
+        let client = fake_adapter_client(false).await;
+        let session = Arc::new(
+            DebugSession::new("go".to_string(), "main.go".to_string(), client)
+                .await
+                .unwrap(),
+        );
+        session
+            .clone()
+            .initialize_and_launch_async("go".to_string(), json!({"program": "main.go"}), None)
+            .await;
+
+        let DebugState::Failed { error } = session.get_state().await else {
+            panic!("start should fail");
+        };
+        assert!(
+            error.contains("(launch phase Initializing, after"),
+            "{}",
+            error
+        );
+        let report = session.launch_report().unwrap();
+        assert!(report.current.is_none());
+        let phases: Vec<_> = report.phases.iter().map(|t| t.phase).collect();
+        assert_eq!(phases, vec![LaunchPhase::Initializing]);
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_dereference_walks_pointee_and_reports_nil() {
+        let session = running_session(true).await;
+        let limits = dump::Limits::new(None, None).unwrap();
+
+        let (pointer, pointee) = session.dereference("c", 1, limits).await.unwrap();
+        assert_eq!(pointer.type_.as_deref(), Some("*main.Calculator"));
+        assert_eq!(pointee.unwrap().value, json!({"name": "calc", "total": 42}));
+
+        let (pointer, pointee) = session.dereference("none", 1, limits).await.unwrap();
+        assert_eq!(pointer.value, "*main.Calculator nil");
+        assert!(pointee.is_none());
+
+        let dump = session.dump_reference(9, limits).await.unwrap();
+        assert_eq!(dump.value, json!({"name": "calc", "total": 42}));
+        assert!(session.dump_reference(0, limits).await.is_err());
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_unreadable_goroutine_labels_are_reported_unavailable() {
+        let session = running_session(true).await;
+        session
+            .state
+            .write()
+            .await
+            .apply_stopped(1, "pause".to_string(), true);
+        // The fake adapter evaluates nothing but `i`
+        let err = session.goroutine_labels(1).await.unwrap_err();
+        assert!(err.to_string().contains("labels unavailable"), "{}", err);
+
+        let python = DebugSession::new(
+            "python".to_string(),
+            "app.py".to_string(),
+            fake_adapter_client(true).await,
+        )
+        .await
+        .unwrap();
+        let label = GoroutineLabel {
+            key: "tenant".to_string(),
+            value: "acme".to_string(),
+        };
+        let err = python.goroutine_label_condition(&label).await.unwrap_err();
+        assert!(err.to_string().contains("only supported for Go"), "{}", err);
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_stopped_event_records_hit_breakpoints() {
+        let session = running_session(false).await;
+        {
+            let mut state = session.state.write().await;
+            state.add_breakpoint("/w/main.go".to_string(), 12);
+            state.update_breakpoint("/w/main.go", 12, 5, true);
+        }
+        let client_arc = session.get_debug_client().await;
+
+        client_arc
+            .read()
+            .await
+            .emit_event(event(
+                1,
+                "stopped",
+                json!({"reason": "breakpoint", "threadId": 1, "hitBreakpointIds": [5]}),
+            ))
+            .await;
+        tokio::time::sleep(Duration::from_millis(100)).await;
+        let (hit, unknown) = session.get_full_state().await.hit_breakpoints();
+        assert_eq!(hit.len(), 1);
+        assert_eq!(hit[0].line, 12);
+        assert!(unknown.is_empty());
+
+        // A later stop that isn't a breakpoint hit clears them
+        client_arc
+            .read()
+            .await
+            .emit_event(event(
+                2,
+                "stopped",
+                json!({"reason": "step", "threadId": 1}),
+            ))
+            .await;
+        tokio::time::sleep(Duration::from_millis(100)).await;
+        assert!(session.get_full_state().await.hit_breakpoint_ids.is_empty());
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_moved_breakpoint_explained() {
+        let session = running_session(false).await;
+        let dir = tempfile::tempdir().unwrap();
+        let path = dir.path().join("moved.go");
+        std::fs::write(&path, "func main() {\n\tif ready {\n\t\tstart()\n\t}\n}\n").unwrap();
+        let path = path.to_string_lossy().to_string();
+
+        assert!(session.set_breakpoint(path.clone(), 2).await.unwrap());
+        let bps = session.get_full_state().await.get_breakpoints(&path);
+        assert_eq!(bps[0].verified_line, Some(3));
+        assert_eq!(
+            bps[0].move_explanation.as_deref(),
+            Some("line 2 is not a statement (if header); moved to 3, the first statement of the if body")
+        );
+
+        // Setting the verified line replaces the moved breakpoint
+        session.set_breakpoint(path.clone(), 3).await.unwrap();
+        let bps = session.get_full_state().await.get_breakpoints(&path);
+        assert_eq!(bps.len(), 1);
+        assert_eq!((bps[0].line, bps[0].verified_line), (3, None));
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_conditional_breakpoints_share_a_line() {
+        let session = running_session(false).await;
+        let conditional = |condition: &str| super::Breakpoint {
+            source_path: "/w/main.go".to_string(),
+            line: 5,
+            id: None,
+            verified: false,
+            enabled: true,
+            condition: Some(condition.to_string()),
+            hit_condition: None,
+            log_message: None,
+            temporary: false,
+            verified_line: None,
+            move_explanation: None,
+            caller: None,
+        };
+        assert!(session
+            .add_shared_breakpoint(conditional("i == 1"))
+            .await
+            .unwrap());
+        assert!(session
+            .add_shared_breakpoint(conditional("i == 2"))
+            .await
+            .unwrap());
+        let bps = session.get_full_state().await.get_breakpoints("/w/main.go");
+        assert_eq!(bps.len(), 2);
+        assert!(bps.iter().all(|bp| bp.verified && bp.id == bps[0].id));
+
+        // A plain breakpoint keeps the line to itself
+        session
+            .set_breakpoint("/w/main.go".to_string(), 9)
+            .await
+            .unwrap();
+        let mut plain = conditional("i == 3");
+        plain.line = 9;
+        assert!(session.add_shared_breakpoint(plain).await.is_err());
+
+        assert_eq!(
+            session
+                .remove_breakpoint("/w/main.go", 5, Some("i == 1"))
+                .await
+                .unwrap(),
+            1
+        );
+        let bps = session.get_full_state().await.get_breakpoints("/w/main.go");
+        assert_eq!(bps.len(), 2);
+        assert!(bps
+            .iter()
+            .any(|bp| bp.line == 5 && bp.condition.as_deref() == Some("i == 2") && bp.verified));
+        assert!(session
+            .remove_breakpoint("/w/main.go", 5, Some("i == 1"))
+            .await
+            .is_err());
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_breakpoint_stops_only_for_its_caller() {
+        let session = running_session(false).await;
+        let with_caller = |depth| super::Breakpoint {
+            source_path: "/w/main.go".to_string(),
+            line: 5,
+            id: None,
+            verified: false,
+            enabled: true,
+            condition: None,
+            hit_condition: None,
+            log_message: None,
+            temporary: false,
+            verified_line: None,
+            move_explanation: None,
+            caller: Some(CallerConstraint::new("depth2", Some(depth)).unwrap()),
+        };
+        let stop_at_breakpoint = || async {
+            let client_arc = session.get_debug_client().await;
+            client_arc
+                .read()
+                .await
+                .emit_event(event(
+                    1,
+                    "stopped",
+                    json!({"reason": "breakpoint", "threadId": 2, "hitBreakpointIds": [100]}),
+                ))
+                .await;
+            tokio::time::sleep(Duration::from_millis(200)).await;
+        };
+
+        // Thread 2 is called from depth1, called from depth2
+        session.set_breakpoint_with(with_caller(1)).await.unwrap();
+        stop_at_breakpoint().await;
+        assert_eq!(session.get_state().await, DebugState::Running);
+        assert_eq!(session.state.read().await.caller_skips, 1);
+        assert!(session.caller_stop().await.is_none());
+
+        session.set_breakpoint_with(with_caller(2)).await.unwrap();
+        stop_at_breakpoint().await;
+        assert!(matches!(
+            session.get_state().await,
+            DebugState::Stopped { thread_id: 2, .. }
+        ));
+        let found = session.caller_stop().await.unwrap();
+        assert_eq!(found.function.as_deref(), Some("depth2"));
+        assert_eq!(
+            (found.breakpoint_id, found.depth, found.skipped),
+            (100, Some(2), 1)
+        );
+        assert_eq!(session.state.read().await.caller_skips, 0);
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_temporary_breakpoint_removed_by_its_stop() {
+        let session = running_session(false).await;
+        {
+            let mut state = session.state.write().await;
+            state.add_breakpoint("/w/main.go".to_string(), 12);
+            state.update_breakpoint("/w/main.go", 12, 5, true);
+            state.insert_breakpoint(crate::debug::state::Breakpoint {
+                source_path: "/w/main.go".to_string(),
+                line: 20,
+                id: Some(6),
+                verified: true,
+                enabled: true,
+                condition: Some("n > 2".to_string()),
+                hit_condition: None,
+                log_message: None,
+                temporary: true,
+                verified_line: None,
+                move_explanation: None,
+                caller: None,
+            });
+        }
+        let client_arc = session.get_debug_client().await;
+
+        // Steps and other breakpoints leave it in place
+        for (seq, body) in [
+            json!({"reason": "step", "threadId": 1}),
+            json!({"reason": "breakpoint", "threadId": 1, "hitBreakpointIds": [5]}),
+        ]
+        .into_iter()
+        .enumerate()
+        {
+            client_arc
+                .read()
+                .await
+                .emit_event(event(seq as i32 + 1, "stopped", body))
+                .await;
+        }
+        tokio::time::sleep(Duration::from_millis(100)).await;
+        assert!(session.get_full_state().await.has_temporary_breakpoints());
+
+        client_arc
+            .read()
+            .await
+            .emit_event(event(
+                3,
+                "stopped",
+                json!({"reason": "breakpoint", "threadId": 1, "hitBreakpointIds": [6]}),
+            ))
+            .await;
+        tokio::time::sleep(Duration::from_millis(100)).await;
+        let state = session.get_full_state().await;
+        let lines: Vec<i32> = state
+            .get_breakpoints("/w/main.go")
+            .iter()
+            .map(|bp| bp.line)
+            .collect();
+        assert_eq!(lines, vec![12]);
+        let (hit, _) = state.hit_breakpoints();
+        assert_eq!(hit.len(), 1);
+        assert_eq!(hit[0].line, 20);
+        assert!(hit[0].temporary);
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_unsubscribed_breakpoint_counts_without_stopping() {
+        let session = running_session(false).await;
+        {
+            let mut state = session.state.write().await;
+            state.add_breakpoint("/w/main.go".to_string(), 12);
+            state.update_breakpoint("/w/main.go", 12, 5, true);
+            state.auto_continue_unsubscribed = true;
+        }
+        let err = session.unsubscribe(Some(&[9])).await.unwrap_err();
+        assert!(
+            err.to_string().contains("No breakpoint with id 9"),
+            "{}",
+            err
+        );
+        session.unsubscribe(Some(&[5])).await.unwrap();
+
+        let client_arc = session.get_debug_client().await;
+        let hit = json!({"reason": "breakpoint", "threadId": 1, "hitBreakpointIds": [5]});
+        client_arc
+            .read()
+            .await
+            .emit_event(event(1, "stopped", hit.clone()))
+            .await;
+        tokio::time::sleep(Duration::from_millis(100)).await;
+        let state = session.get_full_state().await;
+        assert_eq!(state.state, DebugState::Running);
+        assert_eq!(state.breakpoint_hits.count(5), 1);
+
+        // The stop of a step that ends on the breakpoint is reported
+        session.state.write().await.user_stop_pending = true;
+        client_arc
+            .read()
+            .await
+            .emit_event(event(2, "stopped", hit))
+            .await;
+        tokio::time::sleep(Duration::from_millis(100)).await;
+        let state = session.get_full_state().await;
+        assert!(matches!(state.state, DebugState::Stopped { .. }));
+        assert_eq!(state.breakpoint_hits.count(5), 2);
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_unsubscribed_continue_keeps_state_locked_until_sent() {
+        let session = running_session(false).await;
+        {
+            let mut state = session.state.write().await;
+            state.add_breakpoint("/w/main.go".to_string(), 12);
+            state.update_breakpoint("/w/main.go", 12, 5, true);
+            state.auto_continue_unsubscribed = true;
+        }
+        session.unsubscribe(Some(&[5])).await.unwrap();
+
+        // Hold the continue back: a step or pause can't mark its stop as
+        // on its way in the meantime
+        let client_arc = session.get_debug_client().await;
+        let client = client_arc.write().await;
+        client
+            .emit_event(event(
+                1,
+                "stopped",
+                json!({"reason": "breakpoint", "threadId": 1, "hitBreakpointIds": [5]}),
+            ))
+            .await;
+        tokio::time::sleep(Duration::from_millis(100)).await;
+        assert!(
+            tokio::time::timeout(Duration::from_millis(50), session.state.write())
+                .await
+                .is_err()
+        );
+
+        drop(client);
+        tokio::time::sleep(Duration::from_millis(100)).await;
+        let state = session.get_full_state().await;
+        assert_eq!(state.state, DebugState::Running);
+        assert_eq!(state.breakpoint_hits.count(5), 1);
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_continue_past_skips_hits_until_count() {
+        let session = Arc::new(running_session(false).await);
+        {
+            let mut state = session.state.write().await;
+            for (line, id) in [(12, 5), (20, 6)] {
+                state.add_breakpoint("/w/main.go".to_string(), line);
+                state.update_breakpoint("/w/main.go", line, id, true);
+            }
+            state.apply_stopped(1, "entry".to_string(), true);
+        }
+        let timeout = Duration::from_secs(5);
+        let err = session.continue_past(9, 3, timeout).await.unwrap_err();
+        assert!(
+            err.to_string().contains("No breakpoint with id 9"),
+            "{}",
+            err
+        );
+        assert!(session.continue_past(5, 0, timeout).await.is_err());
+
+        let client_arc = session.get_debug_client().await;
+        let emit_hits = |stops: Vec<Vec<i32>>| {
+            let client_arc = client_arc.clone();
+            async move {
+                for (seq, ids) in stops.into_iter().enumerate() {
+                    tokio::time::sleep(Duration::from_millis(100)).await;
+                    let hit =
+                        json!({"reason": "breakpoint", "threadId": 1, "hitBreakpointIds": ids});
+                    client_arc
+                        .read()
+                        .await
+                        .emit_event(event(seq as i32, "stopped", hit))
+                        .await;
+                }
+            }
+        };
+
+        // The first two hits are skipped, the third stops
+        let hits = tokio::spawn(emit_hits(vec![vec![5], vec![5], vec![5]]));
+        let report = session.continue_past(5, 3, timeout).await.unwrap();
+        hits.await.unwrap();
+        assert_eq!(report.status, ContinuePastStatus::Reached);
+        assert_eq!((report.count, report.skipped), (3, 2));
+        assert_eq!(report.hit_breakpoint_ids, vec![5]);
+        let state = session.get_full_state().await;
+        assert!(state.continue_past.is_none());
+        assert_eq!(state.breakpoint_hits.count(5), 3);
+
+        // A hit shared with another breakpoint interrupts it
+        let hits = tokio::spawn(emit_hits(vec![vec![5], vec![5, 6]]));
+        let report = session.continue_past(5, 10, timeout).await.unwrap();
+        hits.await.unwrap();
+        assert_eq!(report.status, ContinuePastStatus::Interrupted);
+        assert_eq!(report.skipped, 1);
+        assert_eq!(report.hit_breakpoint_ids, vec![5, 6]);
+
+        let report = session
+            .continue_past(5, 2, Duration::from_millis(200))
+            .await
+            .unwrap();
+        assert_eq!(report.status, ContinuePastStatus::TimedOut);
+        assert!(session.get_full_state().await.continue_past.is_none());
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_breakpoint_stop_naming_no_breakpoint_counts_for_its_location() {
+        let session = running_session(false).await;
+        {
+            let mut state = session.state.write().await;
+            state.add_breakpoint("/w/main.go".to_string(), 12);
+            state.update_breakpoint("/w/main.go", 12, 5, true);
+        }
+        let client_arc = session.get_debug_client().await;
+        for (seq, thread_id) in [(1, 3), (2, 3), (3, 1)] {
+            client_arc
+                .read()
+                .await
+                .emit_event(event(
+                    seq,
+                    "stopped",
+                    json!({"reason": "breakpoint", "threadId": thread_id}),
+                ))
+                .await;
+            tokio::time::sleep(Duration::from_millis(100)).await;
+        }
+
+        // Thread 3 stopped at the breakpoint's line twice; thread 1's
+        // location is unknown
+        let state = session.get_full_state().await;
+        assert_eq!(state.breakpoint_hits.count(5), 2);
+        let summary = state.summarize_breakpoints();
+        assert_eq!((summary.total_hits, summary.untracked_hits), (3, 1));
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_run_to_breakpoint_continues_through_other_breakpoints() {
+        let session = Arc::new(running_session(false).await);
+        {
+            let mut state = session.state.write().await;
+            for (line, id) in [(12, 5), (20, 6)] {
+                state.add_breakpoint("/w/main.go".to_string(), line);
+                state.update_breakpoint("/w/main.go", line, id, true);
+            }
+            state.apply_stopped(1, "entry".to_string(), true);
+        }
+        let timeout = Duration::from_secs(5);
+
+        let client_arc = session.get_debug_client().await;
+        // Each stop once the program was continued from the one before
+        let emit_stops = |stops: Vec<serde_json::Value>| {
+            let client_arc = client_arc.clone();
+            let session = session.clone();
+            async move {
+                for (seq, body) in stops.into_iter().enumerate() {
+                    while session.get_state().await != DebugState::Running {
+                        tokio::time::sleep(Duration::from_millis(10)).await;
+                    }
+                    client_arc
+                        .read()
+                        .await
+                        .emit_event(event(seq as i32, "stopped", body))
+                        .await;
+                    while session.get_state().await == DebugState::Running {
+                        tokio::time::sleep(Duration::from_millis(10)).await;
+                    }
+                }
+            }
+        };
+        let hit =
+            |id: i32| json!({"reason": "breakpoint", "threadId": 1, "hitBreakpointIds": [id]});
+
+        // Two stops at breakpoint 6 are continued through, then 5 is hit
+        let stops = tokio::spawn(emit_stops(vec![hit(6), hit(6), hit(5)]));
+        let outcome = session
+            .run_to_breakpoint(5, true, 10, timeout)
+            .await
+            .unwrap();
+        stops.await.unwrap();
+        assert_eq!(outcome.end, BreakpointWaitEnd::Hit);
+        assert_eq!((outcome.auto_continues, outcome.thread_id), (2, Some(1)));
+        let state = session.get_full_state().await;
+        assert_eq!(state.breakpoint_hits.count(6), 2);
+        assert_eq!(state.breakpoint_hits.count(5), 1);
+
+        // Without auto-continue, the other breakpoint ends the wait
+        let stops = tokio::spawn(emit_stops(vec![hit(6)]));
+        let outcome = session
+            .run_to_breakpoint(5, false, 10, timeout)
+            .await
+            .unwrap();
+        stops.await.unwrap();
+        assert_eq!(
+            outcome.end,
+            BreakpointWaitEnd::Interrupted {
+                reason: "breakpoint".to_string()
+            }
+        );
+        assert_eq!(outcome.auto_continues, 0);
+
+        // An exception isn't continued through
+        let exception = json!({"reason": "exception", "threadId": 1});
+        let stops = tokio::spawn(emit_stops(vec![hit(6), exception]));
+        let outcome = session
+            .run_to_breakpoint(5, true, 10, timeout)
+            .await
+            .unwrap();
+        stops.await.unwrap();
+        assert_eq!(
+            outcome.end,
+            BreakpointWaitEnd::Interrupted {
+                reason: "exception".to_string()
+            }
+        );
+        assert_eq!(outcome.auto_continues, 1);
+
+        // Past the maximum, the next other breakpoint stops it
+        let stops = tokio::spawn(emit_stops(vec![hit(6), hit(6)]));
+        let outcome = session
+            .run_to_breakpoint(5, true, 1, timeout)
+            .await
+            .unwrap();
+        stops.await.unwrap();
+        assert_eq!(outcome.end, BreakpointWaitEnd::LimitReached);
+        assert_eq!(outcome.auto_continues, 1);
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_stop_loads_top_frame_and_deeper_frames_on_demand() {
+        let session = running_session(false).await;
+        let stack_requests = |session: &DebugSession| {
+            session
+                .metrics()
+                .requests
+                .get("stackTrace")
+                .map_or(0, |r| r.count)
+        };
+        let client_arc = session.get_debug_client().await;
+        let caps: Capabilities =
+            serde_json::from_value(json!({"supportsDelayedStackTraceLoading": true})).unwrap();
+        client_arc
+            .read()
+            .await
+            .set_capabilities("debugpy", caps)
+            .await;
+        client_arc
+            .read()
+            .await
+            .emit_event(event(
+                1,
+                "stopped",
+                json!({"reason": "breakpoint", "threadId": 2}),
+            ))
+            .await;
+        tokio::time::sleep(Duration::from_millis(200)).await;
+
+        // The stop is reported with its top frame alone
+        let (thread_id, stack) = session.loaded_stack().await.unwrap();
+        assert_eq!((thread_id, stack.frames.len()), (2, 1));
+        assert_eq!(stack.frames[0].name, "depth0");
+        assert!(stack.partial());
+        assert_eq!(stack_requests(&session), 1);
+        assert_eq!(session.metrics().stop_notification.count, 1);
+
+        // Deeper frames are loaded when asked for, and kept
+        let page = session.stack_trace_page(2, 0, 20).await.unwrap();
+        assert_eq!(page.frames.len(), 20);
+        assert_eq!(stack_requests(&session), 2);
+        let page = session.stack_trace_page(2, 10, 5).await.unwrap();
+        let ids: Vec<i32> = page.frames.iter().map(|f| f.id).collect();
+        assert_eq!(ids, vec![110, 111, 112, 113, 114]);
+        assert_eq!(stack_requests(&session), 2);
+        let page = session.stack_trace_page(2, 45, 20).await.unwrap();
+        assert_eq!(page.frames.len(), 5);
+        assert!(!page.has_more());
+        assert_eq!(stack_requests(&session), 3);
+
+        // Nothing loaded before the program resumed is served after it
+        {
+            let mut state = session.state.write().await;
+            state.apply_continued(2, true);
+            state.apply_stopped(2, "step".to_string(), true);
+        }
+        assert!(session.loaded_stack().await.is_none());
+        session.stack_trace_page(2, 10, 5).await.unwrap();
+        assert_eq!(stack_requests(&session), 4);
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_value_for_comparison() {
+        let session = running_session(true).await;
+        let side = session.value_for_comparison("i", false).await;
+        assert_eq!(side.status, SideStatus::NotStopped);
+        assert_eq!(side.state, Some(DebugState::Running));
+
+        session
+            .state
+            .write()
+            .await
+            .apply_stopped(1, "breakpoint".to_string(), true);
+        let side = session.value_for_comparison("i", false).await;
+        assert_eq!(side.status, SideStatus::Evaluated);
+        assert_eq!(side.value.as_deref(), Some("3"));
+        assert_eq!((side.thread_id, side.line), (Some(1), Some(3)));
+        assert_eq!(side.function.as_deref(), Some("main"));
+
+        let side = session.value_for_comparison("missing", false).await;
+        assert_eq!(side.status, SideStatus::Failed);
+        assert!(side.error.is_some());
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_memory_event_is_recorded() {
+        let session = running_session(false).await;
+        let since = session.events_seq().await;
+        let client_arc = session.get_debug_client().await;
+        client_arc
+            .read()
+            .await
+            .emit_event(event(
+                1,
+                "memory",
+                json!({"memoryReference": "0xc00001a0b8", "offset": 0, "count": 1}),
+            ))
+            .await;
+        tokio::time::sleep(Duration::from_millis(100)).await;
+
+        let changes = session.memory_changes().await;
+        assert_eq!(changes.len(), 1);
+        assert_eq!(changes[0].memory_reference, "0xc00001a0b8");
+        assert_eq!(changes[0].count, 1);
+        assert!(changes[0].events_seq > since);
+        assert_eq!(session.events_seq().await, changes[0].events_seq);
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_goroutines_need_a_stop() {
+        let session = running_session(true).await;
+        let err = session.goroutines(0, 10).await.unwrap_err();
+        assert!(err.to_string().contains("debugger_pause"), "{}", err);
 
-        // State will be updated by 'stopped' event handler when step completes
-        Ok(())
+        session
+            .state
+            .write()
+            .await
+            .apply_stopped(1, "pause".to_string(), true);
+        // The fake's thread name isn't Delve's, so the stack stands in
+        let page = session.goroutines(0, 10).await.unwrap();
+        assert_eq!((page.total, page.next_offset), (1, None));
+        assert!(!page.truncated);
+        assert_eq!(page.source, "stackTrace");
+        assert_eq!(page.goroutines[0].function.as_deref(), Some("main"));
+        assert_eq!(page.goroutines[0].state, "running");
+
+        let page = session.goroutines(1, 10).await.unwrap();
+        assert!(page.goroutines.is_empty());
     }
 
-    pub async fn stack_trace(&self) -> Result<Vec<crate::dap::types::StackFrame>> {
-        let state = self.state.read().await;
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_locate_function_keeps_no_breakpoint() {
+        let session = running_session(false).await;
+        // Still launching, the adapter can't answer yet
+        session.state.write().await.set_state(DebugState::Launching);
+        let err = session.locate_function("main.main").await.unwrap_err();
+        assert!(err.to_string().contains("running or stopped"), "{}", err);
 
-        // Get thread_id from the current Stopped state, or fallback to threads list
-        let thread_id = match &state.state {
-            DebugState::Stopped { thread_id, .. } => *thread_id,
-            _ => state.threads.first().copied().unwrap_or(1),
-        };
-        drop(state);
+        session
+            .state
+            .write()
+            .await
+            .apply_stopped(1, "entry".to_string(), true);
+        assert_eq!(
+            session.locate_function("main.main").await.unwrap(),
+            Some(("/w/main.go".to_string(), 5))
+        );
+        assert!(session.state.read().await.function_breakpoints.is_empty());
 
-        let client_arc = self.get_debug_client().await;
-        let client = client_arc.read().await;
-        client.stack_trace(thread_id).await
+        let err = session.locate_function("main.nope").await.unwrap_err();
+        assert!(
+            err.to_string().contains("could not find function"),
+            "{}",
+            err
+        );
     }
 
-    pub async fn evaluate(&self, expression: &str, frame_id: Option<i32>) -> Result<String> {
-        // If frame_id is None, auto-fetch it from stack trace using correct thread ID
-        let frame_id = if let Some(id) = frame_id {
-            Some(id)
-        } else {
-            // Get current thread ID from Stopped state
-            let state = self.state.read().await;
-            if let DebugState::Stopped { thread_id, .. } = &state.state {
-                // Get stack trace with correct thread ID
-                let client_arc = self.get_debug_client().await;
-                let client = client_arc.read().await;
-                match client.stack_trace(*thread_id).await {
-                    Ok(frames) if !frames.is_empty() => {
-                        info!(
-                            "📍 Auto-fetched frame_id {} from thread {}",
-                            frames[0].id, thread_id
-                        );
-                        Some(frames[0].id)
-                    }
-                    Ok(_) => {
-                        warn!("⚠️  No stack frames available for evaluate");
-                        None
-                    }
-                    Err(e) => {
-                        warn!("⚠️  Failed to get stack trace for evaluate: {}", e);
-                        None
-                    }
-                }
-            } else {
-                warn!("⚠️  Cannot auto-fetch frame_id: not in Stopped state");
-                None
-            }
-        };
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_go_function_breakpoint_must_resolve() {
+        let session = running_session(false).await;
+        let bp = session
+            .set_function_breakpoint("main.*Calculator.Multiply()".to_string(), None, None)
+            .await
+            .unwrap();
+        assert_eq!(bp.name, "main.(*Calculator).Multiply");
+        assert!(bp.verified);
 
-        let client_arc = self.get_debug_client().await;
-        let client = client_arc.read().await;
-        client.evaluate(expression, frame_id).await
+        let err = session
+            .set_function_breakpoint("main.Ad".to_string(), None, None)
+            .await
+            .unwrap_err();
+        assert!(
+            err.to_string().contains("couldn't find function main.Ad"),
+            "{}",
+            err
+        );
+        let names: Vec<_> = session
+            .state
+            .read()
+            .await
+            .function_breakpoints
+            .iter()
+            .map(|b| b.name.clone())
+            .collect();
+        assert_eq!(names, vec!["main.(*Calculator).Multiply"]);
+
+        assert!(session
+            .remove_function_breakpoint("main.(*Calculator).Multiply ")
+            .await
+            .unwrap());
     }
 
-    pub async fn disconnect(&self) -> Result<()> {
-        let client_arc = self.get_debug_client().await;
-        let client = client_arc.read().await;
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_entry_breakpoint_stop_reads_entry() {
+        let session = running_session(false).await;
+        session
+            .arm_entry_breakpoint(EntryBreakpoint::Line {
+                path: "/w/app.py".to_string(),
+                line: 8,
+            })
+            .await
+            .unwrap();
+        let client_arc = session.get_debug_client().await;
 
-        // Use disconnect with 2s timeout (force cleanup if hangs)
-        // If timeout occurs, we still update state to Terminated
-        match client.disconnect_with_timeout().await {
-            Ok(_) => info!("✅ Disconnect completed successfully"),
-            Err(e) => {
-                warn!(
-                    "⚠️  Disconnect timeout or error: {}, proceeding with cleanup",
-                    e
-                );
-                // Continue anyway - state will be set to Terminated
+        // The fake adapter gave the entry breakpoint id 100
+        client_arc
+            .read()
+            .await
+            .emit_event(event(
+                1,
+                "stopped",
+                json!({"reason": "breakpoint", "threadId": 1, "hitBreakpointIds": [100]}),
+            ))
+            .await;
+        tokio::time::sleep(Duration::from_millis(100)).await;
+
+        let state = session.get_full_state().await;
+        assert!(matches!(
+            &state.state,
+            DebugState::Stopped { reason, .. } if reason == "entry"
+        ));
+        assert!(state.entry_breakpoint.is_none());
+        assert!(state.get_breakpoints("/w/app.py").is_empty());
+        let (hit, unknown) = state.hit_breakpoints();
+        assert!(hit.is_empty() && unknown.is_empty());
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_entry_stop_auto_continued_unless_breakpoint_there() {
+        let session = running_session(false).await;
+        session.set_auto_continue_from_entry(true).await;
+        let client_arc = session.get_debug_client().await;
+
+        client_arc
+            .read()
+            .await
+            .emit_event(event(
+                1,
+                "stopped",
+                json!({"reason": "entry", "threadId": 1}),
+            ))
+            .await;
+        tokio::time::sleep(Duration::from_millis(100)).await;
+        assert_eq!(session.get_state().await, DebugState::Running);
+
+        // A breakpoint on the entry line would be passed by continuing
+        {
+            let mut state = session.state.write().await;
+            state.add_breakpoint("/w/main.go".to_string(), 1);
+            state.update_breakpoint("/w/main.go", 1, 7, true);
+        }
+        client_arc
+            .read()
+            .await
+            .emit_event(event(
+                2,
+                "stopped",
+                json!({"reason": "entry", "threadId": 1, "hitBreakpointIds": [7]}),
+            ))
+            .await;
+        tokio::time::sleep(Duration::from_millis(100)).await;
+
+        let state = session.get_full_state().await;
+        assert!(matches!(
+            &state.state,
+            DebugState::Stopped { reason, .. } if reason == "breakpoint"
+        ));
+        assert_eq!(state.hit_breakpoints().0.len(), 1);
+        assert_eq!(state.breakpoint_hits.count(7), 1);
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_crash_webhook_sent_at_the_stop() {
+        let session = Arc::new(running_session(true).await);
+        // Nothing listens there, so the delivery fails, but is logged
+        let port = std::net::TcpListener::bind("127.0.0.1:0")
+            .unwrap()
+            .local_addr()
+            .unwrap()
+            .port();
+        session
+            .set_webhook(format!("http://127.0.0.1:{}/hook", port))
+            .await;
+        let client_arc = session.get_debug_client().await;
+        client_arc
+            .read()
+            .await
+            .emit_event(event(
+                1,
+                "stopped",
+                json!({"reason": "exception", "threadId": 1}),
+            ))
+            .await;
+
+        // Nobody asks for the crash report
+        let mut crashed = false;
+        for _ in 0..50 {
+            let (_, deliveries) = session.webhook_deliveries().await.unwrap();
+            crashed = deliveries.iter().any(|d| d.event == "crashed");
+            if crashed {
+                break;
             }
+            tokio::time::sleep(Duration::from_millis(100)).await;
         }
+        assert!(crashed);
+        assert!(session.get_full_state().await.crash_report.is_some());
+    }
 
-        let mut state = self.state.write().await;
-        state.set_state(DebugState::Terminated);
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_crash_report_reuses_the_exception_capture() {
+        let session = Arc::new(running_session(true).await);
+        session.set_capture_on_exception(true).await;
+        session
+            .state
+            .write()
+            .await
+            .apply_stopped(1, "exception".to_string(), true);
+        let exception_requests = |session: &DebugSession| {
+            session
+                .metrics()
+                .requests
+                .get("exceptionInfo")
+                .map_or(0, |r| r.count)
+        };
 
-        Ok(())
+        let capture = session.capture_exception().await.unwrap();
+        assert_eq!(exception_requests(&session), 1);
+        let report = session.crash_report().await.unwrap();
+        assert_eq!(exception_requests(&session), 1);
+        assert_eq!(
+            Some(&report.exception.exception_id),
+            capture.exception.as_ref().map(|e| &e.exception_id)
+        );
     }
 
-    pub async fn get_state(&self) -> DebugState {
-        let state = self.state.read().await;
-        state.state.clone()
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_output_recorded_in_order() {
+        let session = running_session(false).await;
+        let client_arc = session.get_debug_client().await;
+        let client = client_arc.read().await;
+        for i in 0..200 {
+            client
+                .emit_event(event(
+                    i + 1,
+                    "output",
+                    json!({"category": "stdout", "output": format!("line {}\n", i)}),
+                ))
+                .await;
+        }
+        drop(client);
+        tokio::time::sleep(Duration::from_millis(200)).await;
+
+        let expected: Vec<String> = (0..200).map(|i| format!("line {}", i)).collect();
+        let state = session.get_full_state().await;
+        let lines = state.output_tail.lines();
+        assert_eq!(lines[..], expected[expected.len() - lines.len()..]);
+        let startup = state.startup_lines();
+        assert_eq!(startup[..], expected[expected.len() - startup.len()..]);
     }
 
-    pub async fn get_full_state(&self) -> SessionState {
-        let state = self.state.read().await;
-        state.clone()
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_output_flushed_before_launch_and_failure() {
+        let session = running_session(false).await;
+        session.state.write().await.launched = false;
+        let client_arc = session.get_debug_client().await;
+        let client = client_arc.read().await;
+        for (seq, line) in [(1, "go: downloading"), (2, "main.go:7: undefined: x")] {
+            client
+                .emit_event(event(
+                    seq,
+                    "output",
+                    json!({"category": "stderr", "output": format!("{}\n", line)}),
+                ))
+                .await;
+        }
+        drop(client);
+
+        // What marking the launch or its failure waits for, without sleeping
+        session.flush_output().await;
+        let state = session.state.read().await;
+        assert_eq!(
+            state.start_failure("Initialization failed: exit status 1"),
+            "Initialization failed: exit status 1\nstartup output:\ngo: downloading\nmain.go:7: undefined: x"
+        );
     }
-}
 
-#[cfg(test)]
-mod tests {
-    use super::*;
-    use crate::dap::transport_trait::DapTransportTrait;
-    use crate::dap::types::*;
-    use crate::Error;
-    use mockall::mock;
-    use serde_json::json;
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_exception_stop_captured_once_and_kept_after_exit() {
+        let session = Arc::new(running_session(true).await);
+        session
+            .state
+            .write()
+            .await
+            .apply_stopped(1, "exception".to_string(), true);
+        assert!(session.capture_exception().await.is_none());
 
-    mock! {
-        pub TestTransport {}
+        // Go arms nothing, panics stop by themselves
+        session.set_capture_on_exception(true).await;
+        let capture = session.capture_exception().await.unwrap();
+        let exception = capture.exception.as_ref().unwrap();
+        assert_eq!(exception.exception_id, "panic");
+        assert_eq!(exception.break_mode, "unhandled");
+        assert_eq!(capture.stack.total_frames, Some(1));
+        assert_eq!(capture.locals.len(), 1);
+        assert_eq!(capture.locals[0].name, "i");
+        assert!(!capture.locals_truncated);
 
-        #[async_trait::async_trait]
-        impl DapTransportTrait for TestTransport {
-            async fn read_message(&mut self) -> Result<Message>;
-            async fn write_message(&mut self, msg: &Message) -> Result<()>;
-        }
+        // The same stop reuses the snapshot, the terminated session keeps it
+        let again = session.capture_exception().await.unwrap();
+        assert_eq!(again.stop_seq, capture.stop_seq);
+        session
+            .state
+            .write()
+            .await
+            .set_state(DebugState::Terminated);
+        assert!(session.capture_exception().await.is_none());
+        assert!(session.get_full_state().await.exception_capture.is_some());
     }
 
-    fn create_mock_with_response(response: Response) -> MockTestTransport {
-        let mut mock = MockTestTransport::new();
-        mock.expect_write_message().times(1).returning(|_| Ok(()));
-        mock.expect_read_message()
-            .times(1)
-            .return_once(move || Ok(Message::Response(response)));
-        mock.expect_read_message()
-            .returning(|| Err(Error::Dap("Connection closed".to_string())));
-        mock
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_evaluate_all_threads() {
+        let session = running_session(true).await;
+        let err = session
+            .evaluate_all_threads("i", 8, false)
+            .await
+            .unwrap_err();
+        assert!(matches!(err, crate::Error::InvalidState(_)));
+
+        session
+            .state
+            .write()
+            .await
+            .apply_stopped(1, "pause".to_string(), true);
+        let evaluations = session.evaluate_all_threads("i", 8, false).await.unwrap();
+        assert_eq!(evaluations.results.len(), 1);
+        let main = &evaluations.results[0];
+        assert_eq!((main.thread_id, main.line), (1, Some(3)));
+        assert_eq!(main.value.as_deref(), Some("3"));
+
+        // A failed evaluation is reported for its thread
+        let evaluations = session.evaluate_all_threads("j", 8, false).await.unwrap();
+        assert!(evaluations.results[0].value.is_none());
+        assert!(evaluations.results[0].error.is_some());
+
+        let evaluations = session.evaluate_all_threads("i", 0, false).await.unwrap();
+        assert!(evaluations.results.is_empty());
+        assert_eq!(evaluations.omitted_threads, 1);
+        assert_eq!(session.transcript().await.evaluations.len(), 2);
     }
 
-    fn create_empty_mock() -> MockTestTransport {
-        let mut mock = MockTestTransport::new();
-        mock.expect_read_message()
-            .returning(|| Err(Error::Dap("Connection closed".to_string())));
-        mock
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_server_side_change_watch_reports_values() {
+        let session = running_session(true).await;
+        let (watch, verified) = session.watch_change("i", "/w/main.go", 3, 0).await.unwrap();
+        assert!(verified);
+        assert_eq!(watch.strategy, WatchStrategy::ServerLoop);
+        let bp = session.get_full_state().await.get_breakpoints("/w/main.go")[0].clone();
+        assert_eq!((bp.id, bp.condition), (Some(100), None));
+
+        let client = session.get_debug_client().await;
+        let observation =
+            DebugSession::check_change_watches(&session.state, &client, 1, &[100]).await;
+        let Some(Observation::Stop(stop)) = observation else {
+            panic!("expected a watch stop, got {:?}", observation);
+        };
+        assert!(stop.limit_reached);
+        assert_eq!(stop.new_value.as_deref(), Some("3"));
+        assert_eq!(
+            DebugSession::check_change_watches(&session.state, &client, 1, &[101]).await,
+            None
+        );
+
+        // Replacing the watch on the line keeps ids unique
+        let (watch, _) = session
+            .watch_change("total", "/w/main.go", 3, 5)
+            .await
+            .unwrap();
+        assert_eq!(watch.id, 2);
+        let observation =
+            DebugSession::check_change_watches(&session.state, &client, 1, &[100]).await;
+        let Some(Observation::Stop(stop)) = observation else {
+            panic!("expected a watch stop, got {:?}", observation);
+        };
+        assert!(stop.error.is_some() && !stop.limit_reached);
+        assert_eq!(session.get_full_state().await.change_watches.len(), 1);
     }
 
-    #[tokio::test]
-    async fn test_session_new() {
-        let mock_transport = create_empty_mock();
-        let client = DapClient::new_with_transport(Box::new(mock_transport), None)
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_changed_condition_continues_unchanged_hits() {
+        let session = running_session(true).await;
+        let (watch, verified) = session
+            .set_changed_breakpoint("i", "/w/main.go", 3, None, Some(">= 2".to_string()))
             .await
             .unwrap();
+        assert!(verified);
+        assert_eq!(watch.strategy, WatchStrategy::ServerLoop);
+        assert_eq!(
+            watch.max_auto_continues,
+            change_watch::DEFAULT_MAX_AUTO_CONTINUES
+        );
+        let bp = session.get_full_state().await.get_breakpoints("/w/main.go")[0].clone();
+        assert_eq!(bp.condition, None);
+        assert_eq!(bp.hit_condition.as_deref(), Some(">= 2"));
 
-        let session = DebugSession::new("python".to_string(), "test.py".to_string(), client)
+        // The first hit takes the value, the same value again is continued
+        // (which this fake refuses, so the stop would be reported as it is)
+        let client = session.get_debug_client().await;
+        for _ in 0..2 {
+            let observation =
+                DebugSession::check_change_watches(&session.state, &client, 1, &[100]).await;
+            assert!(
+                !matches!(observation, Some(Observation::Stop(_))),
+                "{:?}",
+                observation
+            );
+        }
+        let watch = session.get_full_state().await.change_watches[0].clone();
+        assert_eq!((watch.hits, watch.auto_continued), (2, 2));
+
+        // A plain breakpoint on the line is no longer a watch
+        session
+            .set_breakpoint_with(crate::debug::state::Breakpoint {
+                source_path: "/w/main.go".to_string(),
+                line: 3,
+                id: None,
+                verified: false,
+                enabled: true,
+                condition: Some("i > 1".to_string()),
+                hit_condition: None,
+                log_message: None,
+                temporary: false,
+                verified_line: None,
+                move_explanation: None,
+                caller: None,
+            })
             .await
             .unwrap();
+        assert!(session.get_full_state().await.change_watches.is_empty());
 
-        assert_eq!(session.language, "python");
-        assert_eq!(session.program, "test.py");
-        assert!(!session.id.is_empty());
+        // Evaluating at every hit is what read-only sessions rule out
+        session.make_read_only().await;
+        let err = session
+            .set_changed_breakpoint("i", "/w/main.go", 3, None, None)
+            .await
+            .unwrap_err();
+        assert!(err.to_string().contains("changed()"), "{}", err);
+        assert!(session.get_full_state().await.change_watches.is_empty());
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_crash_report_taken_before_resuming() {
+        let session = running_session(true).await;
+        {
+            let mut state = session.state.write().await;
+            state
+                .output_tail
+                .push("loading\npanic: index out of range\n");
+            state.apply_stopped(1, "exception".to_string(), true);
+        }
+
+        // The fake adapter refuses to continue; the report is taken regardless
+        assert!(session.continue_thread(1).await.is_err());
+        let report = session.get_full_state().await.crash_report.unwrap();
+        assert_eq!(report.exception.break_mode, "unhandled");
+        assert_eq!(report.frames.len(), 1);
+        // A frame without source is library code, so no locals are taken
+        assert!(report.frames[0].library);
+        assert_eq!(report.user_frame, None);
+        assert_eq!(report.output, vec!["loading", "panic: index out of range"]);
+
+        session
+            .state
+            .write()
+            .await
+            .set_state(DebugState::Terminated);
+        assert_eq!(session.crash_report().await.unwrap().thread_id, 1);
     }
 
     #[tokio::test]
-    async fn test_session_initialize() {
-        let response = Response {
-            seq: 1,
-            request_seq: 1,
-            command: "initialize".to_string(),
-            success: true,
-            message: None,
-            body: Some(json!({"supportsConfigurationDoneRequest": true})),
-        };
+    async fn test_stop_polling_disabled_by_default() {
+        let session = DebugSession::new(
+            "python".to_string(),
+            "test.py".to_string(),
+            DapClient::new_with_transport(Box::new(create_empty_mock()), None)
+                .await
+                .unwrap(),
+        )
+        .await
+        .unwrap();
 
-        let mock_transport = create_mock_with_response(response);
-        let client = DapClient::new_with_transport(Box::new(mock_transport), None)
+        assert!(session.stop_poll_interval.read().await.is_none());
+        assert!(!session.stop_poll_running.load(Ordering::SeqCst));
+    }
+
+    fn breakpoint_entry(file: &str, line: i32) -> BreakpointEntry {
+        BreakpointEntry {
+            file: file.to_string(),
+            line,
+            condition: None,
+            hit_condition: None,
+            log_message: None,
+            enabled: true,
+        }
+    }
+
+    async fn not_started_session(language: &str) -> DebugSession {
+        let client = DapClient::new_with_transport(Box::new(create_empty_mock()), None)
             .await
             .unwrap();
-        let session = DebugSession::new("python".to_string(), "test.py".to_string(), client)
+        DebugSession::new(language.to_string(), "main".to_string(), client)
             .await
-            .unwrap();
+            .unwrap()
+    }
 
-        session.initialize("debugpy").await.unwrap();
+    #[tokio::test]
+    async fn test_step_filters_default_per_language() {
+        let session = Arc::new(not_started_session("go").await);
+        assert!(session
+            .settings()
+            .await
+            .step_filters
+            .contains(&"fmt.*".to_string()));
+        assert!(not_started_session("python")
+            .await
+            .step_filters()
+            .await
+            .is_empty());
 
-        let state = session.get_state().await;
-        assert_eq!(state, DebugState::Initialized);
+        // Only step stops are stepped away from
+        let stop = DebugState::Stopped {
+            thread_id: 1,
+            reason: "breakpoint".to_string(),
+        };
+        let outcome = session.leave_filtered_code(stop.clone()).await.unwrap();
+        assert_eq!(
+            outcome,
+            FilteredStepOutcome {
+                state: Some(stop),
+                skipped: 0,
+                limit_reached: false,
+            }
+        );
+
+        let update = SettingsUpdate {
+            step_filters: Some(Vec::new()),
+            ..Default::default()
+        };
+        session.configure(&update).await.unwrap();
+        assert!(session.step_filters().await.is_empty());
     }
 
-    // Note: launch test removed due to async complexity with mocked transport
-    // The launch functionality is indirectly tested through integration tests
+    #[tokio::test]
+    async fn test_breakpoint_export_import_round_trip() {
+        let dir = tempfile::tempdir().unwrap();
+        let file = dir.path().canonicalize().unwrap().join("app.py");
+        std::fs::write(&file, "x = 1\ny = 2\nz = 3\n").unwrap();
+        let file = file.to_str().unwrap();
 
-    // Note: set_breakpoint test removed due to async complexity with mocked transport
-    // The breakpoint functionality is indirectly tested through integration tests
+        let mut conditional = breakpoint_entry(file, 2);
+        conditional.condition = Some("y > 1".to_string());
+        conditional.hit_condition = Some("3".to_string());
+        let mut logpoint = breakpoint_entry(file, 3);
+        logpoint.log_message = Some("z is {z}".to_string());
+        logpoint.enabled = false;
 
-    // Note: continue_execution test removed due to async complexity with mocked transport
-    // The continue functionality is indirectly tested through integration tests
+        let doc = BreakpointDocument {
+            version: 1,
+            language: "python".to_string(),
+            breakpoints: vec![breakpoint_entry(file, 1), conditional, logpoint],
+            watches: Vec::new(),
+            exception_filters: Vec::new(),
+        };
 
-    // Note: stack_trace test removed due to async complexity with mocked transport
-    // The stack trace functionality is indirectly tested through integration tests
+        let session = not_started_session("python").await;
+        let statuses = session.import_breakpoints(&doc).await.unwrap().breakpoints;
+        assert_eq!(
+            statuses,
+            vec![
+                ImportStatus::Pending,
+                ImportStatus::Pending,
+                ImportStatus::Disabled
+            ]
+        );
 
-    // Note: evaluate test removed due to async complexity with mocked transport
-    // The evaluate functionality is indirectly tested through integration tests
+        // Only enabled breakpoints are queued for the adapter
+        let pending = session.pending_breakpoints.read().await;
+        assert_eq!(pending.get(file).map(|bps| bps.len()), Some(2));
+        drop(pending);
 
-    // Note: disconnect test removed due to async complexity with mocked transport
-    // The disconnect functionality is indirectly tested through integration tests
+        // Exporting and importing into a fresh session reproduces the document
+        let exported = session.export_breakpoints().await;
+        assert_eq!(exported, doc);
+
+        let other = not_started_session("python").await;
+        other.import_breakpoints(&exported).await.unwrap();
+        assert_eq!(other.export_breakpoints().await, doc);
+    }
+
+    #[tokio::test]
+    async fn test_watches_and_exception_filters_round_trip() {
+        let dir = tempfile::tempdir().unwrap();
+        let file = dir.path().canonicalize().unwrap().join("app.py");
+        std::fs::write(&file, "total = 0\nfor i in range(3):\n    total += i\n").unwrap();
+        let file = file.to_str().unwrap();
+
+        let mut changed = breakpoint_entry(file, 3);
+        changed.condition = Some("changed(total)".to_string());
+        changed.hit_condition = Some("2".to_string());
+        let doc = BreakpointDocument {
+            version: 1,
+            language: "python".to_string(),
+            breakpoints: vec![breakpoint_entry(file, 1), changed],
+            watches: vec![WatchEntry::Change {
+                expression: "i".to_string(),
+                file: file.to_string(),
+                line: 2,
+                max_auto_continues: 50,
+            }],
+            exception_filters: vec!["uncaught".to_string()],
+        };
+
+        let session = not_started_session("python").await;
+        let report = session.import_breakpoints(&doc).await.unwrap();
+        assert_eq!(
+            report.breakpoints,
+            vec![ImportStatus::Pending, ImportStatus::Pending]
+        );
+        assert_eq!(report.watches, vec![ImportStatus::Pending]);
+        // Exception breakpoints need an initialized debugger
+        assert!(matches!(
+            report.exception_filters,
+            Some(ImportStatus::Failed { .. })
+        ));
+
+        let state = session.get_full_state().await;
+        let strategies: Vec<(i32, WatchStrategy)> = state
+            .change_watches
+            .iter()
+            .map(|w| (w.line, w.strategy))
+            .collect();
+        assert_eq!(
+            strategies,
+            vec![
+                (3, WatchStrategy::ServerLoop),
+                (2, WatchStrategy::AdapterCondition)
+            ]
+        );
+        let exported = session.export_breakpoints().await;
+        assert_eq!(exported.breakpoints, doc.breakpoints);
+        assert_eq!(exported.watches, doc.watches);
+
+        // Watches change the program; read-only sessions refuse them
+        let other = not_started_session("python").await;
+        other.make_read_only().await;
+        let report = other.import_breakpoints(&doc).await.unwrap();
+        assert!(matches!(report.breakpoints[1], ImportStatus::Failed { .. }));
+        assert!(matches!(report.watches[0], ImportStatus::Failed { .. }));
+    }
+
+    #[tokio::test]
+    async fn test_import_breakpoints_missing_file() {
+        let dir = tempfile::tempdir().unwrap();
+        let file = dir.path().canonicalize().unwrap().join("main.go");
+        std::fs::write(&file, "package main\n").unwrap();
+        let missing = dir.path().join("gone.go");
+
+        let doc = BreakpointDocument {
+            version: 1,
+            language: "go".to_string(),
+            breakpoints: vec![
+                breakpoint_entry(missing.to_str().unwrap(), 5),
+                breakpoint_entry(file.to_str().unwrap(), 1),
+            ],
+            watches: Vec::new(),
+            exception_filters: Vec::new(),
+        };
+
+        let session = not_started_session("go").await;
+        let statuses = session.import_breakpoints(&doc).await.unwrap().breakpoints;
+        assert!(matches!(statuses[0], ImportStatus::Failed { .. }));
+        assert_eq!(statuses[1], ImportStatus::Pending);
+
+        let exported = session.export_breakpoints().await;
+        assert_eq!(exported.breakpoints.len(), 1);
+        assert_eq!(exported.breakpoints[0].file, file.to_str().unwrap());
+    }
+
+    #[tokio::test]
+    async fn test_import_breakpoints_language_mismatch() {
+        let doc = BreakpointDocument {
+            version: 1,
+            language: "ruby".to_string(),
+            breakpoints: vec![breakpoint_entry("/nonexistent/app.rb", 1)],
+            watches: Vec::new(),
+            exception_filters: Vec::new(),
+        };
+
+        let session = not_started_session("python").await;
+        let result = session.import_breakpoints(&doc).await;
+        assert!(matches!(result, Err(Error::InvalidRequest(_))));
+        assert!(session.export_breakpoints().await.breakpoints.is_empty());
+    }
+
+    #[tokio::test]
+    async fn test_import_breakpoints_maps_substituted_paths() {
+        let dir = tempfile::tempdir().unwrap();
+        let root = dir.path().canonicalize().unwrap();
+        let file = root.join("cmd/main.go");
+        std::fs::create_dir_all(file.parent().unwrap()).unwrap();
+        std::fs::write(&file, "package main\n").unwrap();
+
+        // Exported from a session in a container that builds in /build/app
+        let doc = BreakpointDocument {
+            version: 1,
+            language: "go".to_string(),
+            breakpoints: vec![
+                breakpoint_entry("/build/app/cmd/main.go", 1),
+                breakpoint_entry(file.to_str().unwrap(), 1),
+            ],
+            watches: Vec::new(),
+            exception_filters: Vec::new(),
+        };
+        let session = not_started_session("go").await;
+        session
+            .set_substitute_path(vec![SubstitutePath {
+                from: root.to_string_lossy().into_owned(),
+                to: "/build/app".to_string(),
+            }])
+            .await;
+        let statuses = session.import_breakpoints(&doc).await.unwrap().breakpoints;
+        assert_eq!(statuses, vec![ImportStatus::Pending; 2]);
+
+        let exported = session.export_breakpoints().await;
+        assert_eq!(exported.breakpoints.len(), 1);
+        assert_eq!(exported.breakpoints[0].file, file.to_str().unwrap());
+    }
 
     #[tokio::test]
     async fn test_session_get_state() {
@@ -0,0 +1,315 @@
+//! Session settings that can be changed after start
+//!
+//! Options given to `debugger_start` otherwise stay fixed for the life of the
+//! session. The ones listed here can be changed with `debugger_configure`:
+//! live settings take effect immediately, restart settings are validated and
+//! reported back because the adapter only reads them at launch.
+
+use crate::adapters::EXCEPTION_MODES;
+use crate::{Error, Result};
+use serde::Serialize;
+use serde_json::{Map, Value};
+
+/// Settings applied to the running session
+pub const LIVE_SETTINGS: &[&str] = &[
+    "autoContinueUnsubscribed",
+    "captureOnException",
+    "exceptionBreakpoints",
+    "keepAliveIntervalMs",
+    "stepFilters",
+    "stopPollIntervalMs",
+    "verifySource",
+    "waitForStopTimeoutMs",
+];
+
+/// Settings the adapter only reads at launch
+pub const RESTART_SETTINGS: &[&str] = &["justMyCode", "stopOnEntry"];
+
+/// Upper bound on `waitForStopTimeoutMs`
+pub const MAX_WAIT_FOR_STOP_MS: u64 = 300_000;
+
+/// Effective settings of a session
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SessionSettings {
+    /// Continue from stops for unsubscribed breakpoints (see `subscription`)
+    pub auto_continue_unsubscribed: bool,
+    pub capture_on_exception: bool,
+    /// Exception breakpoint modes (`raised`, `uncaught`)
+    pub exception_breakpoints: Vec<String>,
+    /// 0 when keep-alive pings are off
+    pub keep_alive_interval_ms: u64,
+    /// Patterns of code steps don't stop in (see `step_filter`)
+    pub step_filters: Vec<String>,
+    /// 0 when stop polling is off
+    pub stop_poll_interval_ms: u64,
+    /// Check breakpoint sources against the program (see `source_check`)
+    pub verify_source: bool,
+    /// Default timeout of debugger_wait_for_stop and of the waits after steps
+    pub wait_for_stop_timeout_ms: u64,
+    /// As launched (python only)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub just_my_code: Option<bool>,
+    /// As launched
+    pub stop_on_entry: bool,
+}
+
+impl SessionSettings {
+    /// An update setting every live setting to these, to carry them over to
+    /// a relaunched session
+    ///
+    /// Exception breakpoints are left out: they go with the breakpoint
+    /// document, which sets them once the adapter accepts them.
+    pub fn live_update(&self) -> SettingsUpdate {
+        SettingsUpdate {
+            auto_continue_unsubscribed: Some(self.auto_continue_unsubscribed),
+            capture_on_exception: Some(self.capture_on_exception),
+            exception_breakpoints: None,
+            keep_alive_interval_ms: Some(self.keep_alive_interval_ms),
+            step_filters: Some(self.step_filters.clone()),
+            stop_poll_interval_ms: Some(self.stop_poll_interval_ms),
+            verify_source: Some(self.verify_source),
+            wait_for_stop_timeout_ms: Some(self.wait_for_stop_timeout_ms),
+            just_my_code: None,
+            stop_on_entry: None,
+        }
+    }
+}
+
+/// A partial update of the settings; None leaves a setting unchanged
+#[derive(Debug, Clone, Default, PartialEq)]
+pub struct SettingsUpdate {
+    pub auto_continue_unsubscribed: Option<bool>,
+    pub capture_on_exception: Option<bool>,
+    pub exception_breakpoints: Option<Vec<String>>,
+    pub keep_alive_interval_ms: Option<u64>,
+    pub step_filters: Option<Vec<String>>,
+    pub stop_poll_interval_ms: Option<u64>,
+    pub verify_source: Option<bool>,
+    pub wait_for_stop_timeout_ms: Option<u64>,
+    pub just_my_code: Option<bool>,
+    pub stop_on_entry: Option<bool>,
+}
+
+fn invalid(key: &str, expected: &str, value: &Value) -> Error {
+    Error::InvalidRequest(format!(
+        "Setting '{}' must be {}, got {}",
+        key, expected, value
+    ))
+}
+
+fn bool_setting(key: &str, value: &Value) -> Result<bool> {
+    value
+        .as_bool()
+        .ok_or_else(|| invalid(key, "a boolean", value))
+}
+
+fn strings_setting(key: &str, value: &Value) -> Result<Vec<String>> {
+    value
+        .as_array()
+        .and_then(|items| {
+            items
+                .iter()
+                .map(|i| i.as_str().map(str::to_string))
+                .collect::<Option<Vec<_>>>()
+        })
+        .ok_or_else(|| invalid(key, "an array of strings", value))
+}
+
+fn ms_setting(key: &str, value: &Value) -> Result<u64> {
+    value
+        .as_u64()
+        .ok_or_else(|| invalid(key, "a non-negative integer", value))
+}
+
+impl SettingsUpdate {
+    /// Parse and validate a settings object for a session in `language`
+    ///
+    /// Every key is checked before anything is applied, so an invalid update
+    /// changes nothing.
+    pub fn parse(language: &str, settings: &Map<String, Value>) -> Result<Self> {
+        let mut update = Self::default();
+        for (key, value) in settings {
+            match key.as_str() {
+                "autoContinueUnsubscribed" => {
+                    update.auto_continue_unsubscribed = Some(bool_setting(key, value)?)
+                }
+                "captureOnException" => {
+                    update.capture_on_exception = Some(bool_setting(key, value)?)
+                }
+                "exceptionBreakpoints" => {
+                    let modes = strings_setting(key, value)?;
+                    if let Some(mode) = modes
+                        .iter()
+                        .find(|m| !EXCEPTION_MODES.contains(&m.as_str()))
+                    {
+                        return Err(Error::InvalidRequest(format!(
+                            "Unknown exception breakpoint mode '{}' (expected: {})",
+                            mode,
+                            EXCEPTION_MODES.join(", ")
+                        )));
+                    }
+                    update.exception_breakpoints = Some(modes);
+                }
+                "keepAliveIntervalMs" => {
+                    update.keep_alive_interval_ms = Some(ms_setting(key, value)?)
+                }
+                "stepFilters" => update.step_filters = Some(strings_setting(key, value)?),
+                "stopPollIntervalMs" => {
+                    update.stop_poll_interval_ms = Some(ms_setting(key, value)?)
+                }
+                "verifySource" => update.verify_source = Some(bool_setting(key, value)?),
+                "waitForStopTimeoutMs" => {
+                    let ms = ms_setting(key, value)?;
+                    if ms == 0 || ms > MAX_WAIT_FOR_STOP_MS {
+                        return Err(invalid(
+                            key,
+                            &format!("between 1 and {}", MAX_WAIT_FOR_STOP_MS),
+                            value,
+                        ));
+                    }
+                    update.wait_for_stop_timeout_ms = Some(ms);
+                }
+                "justMyCode" if language == "python" => {
+                    update.just_my_code = Some(bool_setting(key, value)?)
+                }
+                "justMyCode" => {
+                    return Err(Error::InvalidRequest(format!(
+                        "Setting 'justMyCode' applies to python sessions only, not {}",
+                        language
+                    )))
+                }
+                "stopOnEntry" => update.stop_on_entry = Some(bool_setting(key, value)?),
+                _ => {
+                    let valid: Vec<&str> = LIVE_SETTINGS
+                        .iter()
+                        .chain(RESTART_SETTINGS)
+                        .copied()
+                        .collect();
+                    return Err(Error::InvalidRequest(format!(
+                        "Unknown setting '{}' (valid settings: {})",
+                        key,
+                        valid.join(", ")
+                    )));
+                }
+            }
+        }
+        Ok(update)
+    }
+
+    /// Restart settings this update would change from `current`
+    pub fn requires_restart(&self, current: &SessionSettings) -> Vec<&'static str> {
+        let mut keys = Vec::new();
+        if self
+            .just_my_code
+            .is_some_and(|v| Some(v) != current.just_my_code)
+        {
+            keys.push("justMyCode");
+        }
+        if self
+            .stop_on_entry
+            .is_some_and(|v| v != current.stop_on_entry)
+        {
+            keys.push("stopOnEntry");
+        }
+        keys
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    fn settings(value: Value) -> Map<String, Value> {
+        value.as_object().unwrap().clone()
+    }
+
+    #[test]
+    fn test_parse_validates_every_key() {
+        let update = SettingsUpdate::parse(
+            "python",
+            &settings(json!({
+                "captureOnException": true,
+                "exceptionBreakpoints": ["uncaught"],
+                "keepAliveIntervalMs": 0,
+                "waitForStopTimeoutMs": 15000,
+                "stepFilters": ["fmt.*", "*/gems/*"],
+                "verifySource": true,
+                "autoContinueUnsubscribed": true,
+                "justMyCode": false
+            })),
+        )
+        .unwrap();
+        assert_eq!(update.capture_on_exception, Some(true));
+        assert_eq!(
+            update.exception_breakpoints,
+            Some(vec!["uncaught".to_string()])
+        );
+        assert_eq!(update.keep_alive_interval_ms, Some(0));
+        assert_eq!(update.stop_poll_interval_ms, None);
+        assert_eq!(update.step_filters.unwrap(), vec!["fmt.*", "*/gems/*"]);
+        assert_eq!(update.wait_for_stop_timeout_ms, Some(15000));
+        assert_eq!(update.verify_source, Some(true));
+        assert_eq!(update.auto_continue_unsubscribed, Some(true));
+        assert_eq!(update.just_my_code, Some(false));
+
+        let err = SettingsUpdate::parse("python", &settings(json!({"inspectDepth": 3})))
+            .unwrap_err()
+            .to_string();
+        assert!(err.contains("Unknown setting 'inspectDepth'"));
+        for key in LIVE_SETTINGS.iter().chain(RESTART_SETTINGS) {
+            assert!(err.contains(key), "{} missing from: {}", key, err);
+        }
+
+        for bad in [
+            json!({"captureOnException": "yes"}),
+            json!({"exceptionBreakpoints": ["all"]}),
+            json!({"keepAliveIntervalMs": -1}),
+            json!({"stepFilters": "fmt.*"}),
+            json!({"waitForStopTimeoutMs": 0}),
+        ] {
+            assert!(
+                SettingsUpdate::parse("python", &settings(bad.clone())).is_err(),
+                "{}",
+                bad
+            );
+        }
+        assert!(SettingsUpdate::parse("go", &settings(json!({"justMyCode": true}))).is_err());
+    }
+
+    #[test]
+    fn test_requires_restart_only_for_changes() {
+        let current = SessionSettings {
+            auto_continue_unsubscribed: false,
+            capture_on_exception: false,
+            exception_breakpoints: Vec::new(),
+            keep_alive_interval_ms: 0,
+            step_filters: Vec::new(),
+            stop_poll_interval_ms: 0,
+            verify_source: false,
+            wait_for_stop_timeout_ms: 5000,
+            just_my_code: Some(true),
+            stop_on_entry: false,
+        };
+        let update = SettingsUpdate {
+            just_my_code: Some(false),
+            stop_on_entry: Some(false),
+            capture_on_exception: Some(true),
+            ..Default::default()
+        };
+        assert_eq!(update.requires_restart(&current), vec!["justMyCode"]);
+
+        // Carried over to a relaunch: live settings only, none needing one
+        let live = SessionSettings {
+            step_filters: vec!["fmt.*".to_string()],
+            keep_alive_interval_ms: 500,
+            ..current.clone()
+        }
+        .live_update();
+        assert_eq!(live.step_filters, Some(vec!["fmt.*".to_string()]));
+        assert_eq!(live.keep_alive_interval_ms, Some(500));
+        assert_eq!(live.wait_for_stop_timeout_ms, Some(5000));
+        assert!(live.requires_restart(&current).is_empty());
+    }
+}
@@ -0,0 +1,170 @@
+//! Several conditional breakpoints on one line
+//!
+//! DAP has one breakpoint per source line: `setBreakpoints` takes a line
+//! once, with one condition. A session can still keep several breakpoints
+//! on a line, each with its own condition (`additional: true` on
+//! `debugger_set_breakpoint`). They are sent as one breakpoint whose
+//! condition is the OR of theirs, so the program stops when any of them
+//! holds:
+//!
+//! - Each condition is parenthesized and joined with the OR of the
+//!   expressions the adapter evaluates: ` or ` for Python and for CodeLLDB's
+//!   simple (its default) and python evaluators, ` || ` for CodeLLDB's
+//!   native evaluator and the other languages.
+//! - The breakpoints share the adapter's id, verification and moved line,
+//!   and a stop at the line is reported for all of them: the adapter can't
+//!   say which condition held.
+//! - Only plain conditional breakpoints share a line. A hit condition, a
+//!   logpoint message or a temporary breakpoint changes what a stop means
+//!   for the whole line, so those keep the line to themselves.
+//!
+//! Removing one of them re-sends the others' conditions; disabling the
+//! line's id disables all of them, since the adapter knows one breakpoint.
+
+use super::state::Breakpoint;
+use crate::dap::types::SourceBreakpoint;
+use crate::{Error, Result};
+
+/// The OR operator of conditions for an adapter id, or for a language
+/// before the adapter is known, and CodeLLDB's `expressions` evaluator
+pub fn or_operator(adapter: Option<&str>, expressions: Option<&str>) -> &'static str {
+    match adapter {
+        Some("debugpy" | "python") => " or ",
+        Some("codelldb" | "rust") => match expressions {
+            Some("native") => " || ",
+            // Simple expressions are Python's, with the program's variables
+            _ => " or ",
+        },
+        _ => " || ",
+    }
+}
+
+/// Fails unless `bp` may share its line with other breakpoints
+pub fn check_shareable(bp: &Breakpoint) -> Result<()> {
+    let problem = if bp.condition.is_none() {
+        "has no condition"
+    } else if bp.hit_condition.is_some() {
+        "has a hit condition"
+    } else if bp.log_message.is_some() {
+        "is a logpoint"
+    } else if bp.temporary {
+        "is temporary"
+    } else if bp.caller.is_some() {
+        "has a caller"
+    } else {
+        return Ok(());
+    };
+    Err(Error::InvalidRequest(format!(
+        "The breakpoint at {}:{} {}; only conditional breakpoints can share a line",
+        bp.source_path, bp.line, problem
+    )))
+}
+
+/// Breakpoints grouped by requested line, in order of each line's first
+pub fn by_line(breakpoints: &[Breakpoint]) -> Vec<Vec<&Breakpoint>> {
+    let mut groups: Vec<Vec<&Breakpoint>> = Vec::new();
+    for bp in breakpoints {
+        match groups.iter_mut().find(|group| group[0].line == bp.line) {
+            Some(group) => group.push(bp),
+            None => groups.push(vec![bp]),
+        }
+    }
+    groups
+}
+
+/// The one DAP breakpoint sent for the breakpoints on a line
+pub fn combine(group: &[&Breakpoint], or: &str) -> SourceBreakpoint {
+    let first = group[0];
+    let condition = if group.len() == 1 {
+        first.condition.clone()
+    } else {
+        group
+            .iter()
+            .map(|bp| bp.condition.as_ref().map(|c| format!("({})", c)))
+            .collect::<Option<Vec<_>>>()
+            .map(|conditions| conditions.join(or))
+    };
+    SourceBreakpoint {
+        line: first.line,
+        column: None,
+        condition,
+        hit_condition: first.hit_condition.clone(),
+        log_message: first.log_message.clone(),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn bp(line: i32, condition: Option<&str>) -> Breakpoint {
+        Breakpoint {
+            source_path: "/w/app.py".to_string(),
+            line,
+            id: None,
+            verified: false,
+            enabled: true,
+            condition: condition.map(str::to_string),
+            hit_condition: None,
+            log_message: None,
+            temporary: false,
+            verified_line: None,
+            move_explanation: None,
+            caller: None,
+        }
+    }
+
+    #[test]
+    fn test_conditions_on_a_line_are_ored() {
+        let breakpoints = vec![
+            bp(7, Some("x > 3")),
+            bp(9, None),
+            bp(7, Some("name == 'bob'")),
+        ];
+        let groups = by_line(&breakpoints);
+        assert_eq!(groups.len(), 2);
+
+        let combined = combine(&groups[0], or_operator(Some("debugpy"), None));
+        assert_eq!(combined.line, 7);
+        assert_eq!(
+            combined.condition.as_deref(),
+            Some("(x > 3) or (name == 'bob')")
+        );
+        assert_eq!(
+            combine(&groups[0], or_operator(Some("dlv"), None))
+                .condition
+                .as_deref(),
+            Some("(x > 3) || (name == 'bob')")
+        );
+        assert_eq!(combine(&groups[1], " or ").condition, None);
+        assert_eq!(
+            combine(&groups[0][..1], " or ").condition.as_deref(),
+            Some("x > 3")
+        );
+    }
+
+    #[test]
+    fn test_codelldb_or_follows_its_evaluator() {
+        for (expressions, or) in [
+            (None, " or "),
+            (Some("simple"), " or "),
+            (Some("python"), " or "),
+            (Some("native"), " || "),
+        ] {
+            assert_eq!(or_operator(Some("codelldb"), expressions), or);
+            assert_eq!(or_operator(Some("rust"), expressions), or);
+        }
+        // Only CodeLLDB has evaluators to choose from
+        assert_eq!(or_operator(Some("dlv"), Some("python")), " || ");
+    }
+
+    #[test]
+    fn test_only_conditional_breakpoints_share_a_line() {
+        assert!(check_shareable(&bp(7, Some("x > 3"))).is_ok());
+        let err = check_shareable(&bp(7, None)).unwrap_err();
+        assert!(err.to_string().contains("has no condition"), "{}", err);
+        let mut logpoint = bp(7, Some("x > 3"));
+        logpoint.log_message = Some("x={x}".to_string());
+        assert!(check_shareable(&logpoint).is_err());
+    }
+}
@@ -0,0 +1,195 @@
+//! Source resolution for stack frames
+//!
+//! Stack frames often point at files the agent can't read directly: a Go panic
+//! usually lands in the module cache (`$GOMODCACHE/<module>@<version>/...`) of
+//! whatever machine built the binary, which may not be mounted here. Resolution
+//! tries, in order:
+//!
+//! 1. The path as-is on the local filesystem
+//! 2. The same module file under the local `GOMODCACHE` (Go only)
+//! 3. The DAP `source` request (done by `DebugSession::resolve_source`)
+//!
+//! If nothing works the result is `SourceUnavailable`, carrying the `module@version`
+//! string so the client can fetch the module itself.
+
+use serde::Serialize;
+use std::path::{Path, PathBuf};
+
+/// Where the content of a resolved source came from
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "snake_case")]
+pub enum SourceOrigin {
+    /// Read from the path reported by the adapter
+    Local,
+    /// Read from the local Go module cache
+    ModuleCache,
+    /// Returned by the adapter's `source` request
+    Adapter,
+    /// Could not be resolved
+    SourceUnavailable,
+}
+
+/// Result of resolving a frame's source
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ResolvedSource {
+    pub origin: SourceOrigin,
+    /// Path the content was read from (None for adapter-provided content)
+    pub resolved_path: Option<String>,
+    /// `module@version` for Go module cache paths
+    pub module: Option<String>,
+    #[serde(skip)]
+    pub content: Option<String>,
+}
+
+impl ResolvedSource {
+    pub fn unavailable(module: Option<String>) -> Self {
+        Self {
+            origin: SourceOrigin::SourceUnavailable,
+            resolved_path: None,
+            module,
+            content: None,
+        }
+    }
+}
+
+/// Resolve a source path from the filesystem only (steps 1 and 2)
+pub fn resolve_from_filesystem(path: &str, gomodcache: Option<&Path>) -> Option<ResolvedSource> {
+    if let Ok(content) = std::fs::read_to_string(path) {
+        return Some(ResolvedSource {
+            origin: SourceOrigin::Local,
+            resolved_path: Some(path.to_string()),
+            module: go_module_version(path),
+            content: Some(content),
+        });
+    }
+
+    let candidate = module_cache_candidate(path, gomodcache?)?;
+    let content = std::fs::read_to_string(&candidate).ok()?;
+    Some(ResolvedSource {
+        origin: SourceOrigin::ModuleCache,
+        resolved_path: Some(candidate.to_string_lossy().into_owned()),
+        module: go_module_version(path),
+        content: Some(content),
+    })
+}
+
+/// The local Go module cache: `$GOMODCACHE`, else `$GOPATH/pkg/mod`, else `~/go/pkg/mod`
+pub fn local_gomodcache() -> Option<PathBuf> {
+    if let Some(dir) = std::env::var_os("GOMODCACHE").filter(|d| !d.is_empty()) {
+        return Some(PathBuf::from(dir));
+    }
+    if let Some(gopath) = std::env::var_os("GOPATH").filter(|d| !d.is_empty()) {
+        // GOPATH may be a list; the module cache lives under the first entry
+        let first = std::env::split_paths(&gopath).next()?;
+        return Some(first.join("pkg").join("mod"));
+    }
+    std::env::var_os("HOME").map(|home| PathBuf::from(home).join("go").join("pkg").join("mod"))
+}
+
+/// Path relative to the module cache root, e.g. `github.com/foo/bar@v1.2.3/x.go`
+fn module_cache_relative(path: &str) -> Option<&str> {
+    let (_, rest) = path.split_once("/pkg/mod/")?;
+    // Module archives and checksums live under cache/download, not sources
+    if rest.starts_with("cache/") {
+        return None;
+    }
+    Some(rest)
+}
+
+/// Rebase a module cache path from the build machine onto the local cache
+fn module_cache_candidate(path: &str, gomodcache: &Path) -> Option<PathBuf> {
+    Some(gomodcache.join(module_cache_relative(path)?))
+}
+
+/// Extract `module@version` from a Go module cache path
+///
+/// The cache escapes upper-case letters as `!` + lower-case
+/// (`github.com/!burnt!sushi/toml`), which is undone here.
+pub fn go_module_version(path: &str) -> Option<String> {
+    let relative = module_cache_relative(path)?;
+
+    let mut components = Vec::new();
+    for component in relative.split('/') {
+        components.push(component);
+        if component.contains('@') {
+            return Some(unescape_module_path(&components.join("/")));
+        }
+    }
+    None
+}
+
+fn unescape_module_path(escaped: &str) -> String {
+    let mut out = String::with_capacity(escaped.len());
+    let mut chars = escaped.chars();
+    while let Some(c) = chars.next() {
+        if c == '!' {
+            if let Some(next) = chars.next() {
+                out.extend(next.to_uppercase());
+            }
+        } else {
+            out.push(c);
+        }
+    }
+    out
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_go_module_version() {
+        assert_eq!(
+            go_module_version("/home/ci/go/pkg/mod/github.com/pkg/errors@v0.9.1/errors.go"),
+            Some("github.com/pkg/errors@v0.9.1".to_string())
+        );
+        assert_eq!(
+            go_module_version("/go/pkg/mod/github.com/!burnt!sushi/toml@v1.3.2/internal/tz.go"),
+            Some("github.com/BurntSushi/toml@v1.3.2".to_string())
+        );
+        assert_eq!(go_module_version("/workspace/main.go"), None);
+        assert_eq!(
+            go_module_version("/go/pkg/mod/cache/download/github.com/pkg/errors/@v/v0.9.1.zip"),
+            None
+        );
+    }
+
+    #[test]
+    fn test_resolve_local_file() {
+        let dir = tempfile::tempdir().unwrap();
+        let file = dir.path().join("main.go");
+        std::fs::write(&file, "package main\n").unwrap();
+
+        let resolved = resolve_from_filesystem(file.to_str().unwrap(), None).unwrap();
+        assert_eq!(resolved.origin, SourceOrigin::Local);
+        assert_eq!(resolved.content.as_deref(), Some("package main\n"));
+        assert_eq!(resolved.module, None);
+    }
+
+    #[test]
+    fn test_resolve_from_local_module_cache() {
+        let cache = tempfile::tempdir().unwrap();
+        let module_dir = cache.path().join("github.com/pkg/errors@v0.9.1");
+        std::fs::create_dir_all(&module_dir).unwrap();
+        std::fs::write(module_dir.join("errors.go"), "package errors\n").unwrap();
+
+        let remote = "/build/agent/go/pkg/mod/github.com/pkg/errors@v0.9.1/errors.go";
+        let resolved = resolve_from_filesystem(remote, Some(cache.path())).unwrap();
+
+        assert_eq!(resolved.origin, SourceOrigin::ModuleCache);
+        assert_eq!(
+            resolved.module.as_deref(),
+            Some("github.com/pkg/errors@v0.9.1")
+        );
+        assert_eq!(resolved.content.as_deref(), Some("package errors\n"));
+    }
+
+    #[test]
+    fn test_resolve_missing_file() {
+        let cache = tempfile::tempdir().unwrap();
+        let remote = "/build/agent/go/pkg/mod/github.com/pkg/errors@v0.9.1/errors.go";
+        assert!(resolve_from_filesystem(remote, Some(cache.path())).is_none());
+        assert!(resolve_from_filesystem("/nonexistent/main.go", None).is_none());
+    }
+}
@@ -0,0 +1,158 @@
+//! Checks that breakpoints are set on the source the program was built from
+//!
+//! "I edited the file but didn't rebuild" is a classic: Delve built the
+//! binary at launch, so a breakpoint set in a file edited since binds to
+//! the old line table and stops on the wrong statement, or never. With the
+//! session's `verifySource` setting on, breakpoints are checked two ways:
+//!
+//! - Adapters reporting `supportedChecksumAlgorithms` get the file's
+//!   checksum with every `setBreakpoints`, so they can refuse a mismatch
+//!   themselves. Only SHA256 is computed.
+//! - Whatever the adapter, a file whose content changed since the program
+//!   was launched gets a warning with its breakpoints. None of Delve,
+//!   debugpy, rdbg, vscode-js-debug or CodeLLDB report checksum algorithms,
+//!   so this is what catches the common case.
+//!
+//! Modification times can't tell: a `git checkout` gives unchanged files a
+//! new one, and a copy preserving them hides real edits. So each launch
+//! takes a snapshot of SHA256 hashes of the program's sources (the files of
+//! its language in its directory, as for watch mode) and of the files with
+//! breakpoints. A source outside both is hashed when its first breakpoints
+//! are sent, so only later edits to it are caught.
+
+use super::file_watch;
+use crate::dap::types::Checksum;
+use sha2::{Digest, Sha256};
+use std::collections::HashMap;
+use std::path::Path;
+
+/// Checksum algorithms computed for adapters that support them
+pub const COMPUTED_ALGORITHMS: &[&str] = &["SHA256"];
+
+/// Checksums of a file in the algorithms both the adapter and the server
+/// support; None when there are none (or the file can't be read)
+pub fn checksums(path: &Path, supported: &[String]) -> Option<Vec<Checksum>> {
+    if !supported.iter().any(|a| a == "SHA256") {
+        return None;
+    }
+    Some(vec![Checksum {
+        algorithm: "SHA256".to_string(),
+        checksum: content_hash(path)?,
+    }])
+}
+
+/// Hex SHA256 of a file's content; None when it can't be read
+pub fn content_hash(path: &Path) -> Option<String> {
+    let contents = std::fs::read(path).ok()?;
+    let digest = Sha256::digest(&contents);
+    Some(digest.iter().map(|b| format!("{:02x}", b)).collect())
+}
+
+/// Key of a source in a snapshot: its canonical path, so spellings of the
+/// same file agree
+pub fn snapshot_key(path: &Path) -> String {
+    std::fs::canonicalize(path)
+        .unwrap_or_else(|_| path.to_path_buf())
+        .display()
+        .to_string()
+}
+
+/// Content hashes of `language` sources of `program` and of `sources`
+/// (the files with breakpoints) at launch, by `snapshot_key`
+pub fn launch_snapshot<'a>(
+    language: &str,
+    program: &Path,
+    sources: impl IntoIterator<Item = &'a String>,
+) -> HashMap<String, String> {
+    let program_sources = file_watch::watched_files(language, program).unwrap_or_default();
+    program_sources
+        .into_iter()
+        .chain(
+            sources
+                .into_iter()
+                .map(|source| Path::new(source).to_path_buf()),
+        )
+        .filter_map(|path| Some((snapshot_key(&path), content_hash(&path)?)))
+        .collect()
+}
+
+/// Warning for a source file whose content no longer hashes to
+/// `launch_hash`, its hash when the program was launched
+pub fn stale_source_warning(path: &Path, launch_hash: &str) -> Option<String> {
+    if content_hash(path)? == launch_hash {
+        return None;
+    }
+    Some(format!(
+        "{} changed since the program was launched: the running program still has the old code, so breakpoints may bind to stale lines. Restart the session to rebuild/reload it",
+        path.display()
+    ))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::time::{Duration, SystemTime};
+
+    #[test]
+    fn test_sha256_only_when_supported() {
+        let dir = tempfile::tempdir().unwrap();
+        let path = dir.path().join("main.go");
+        std::fs::write(&path, "abc").unwrap();
+
+        assert_eq!(checksums(&path, &["MD5".to_string()]), None);
+        let sums = checksums(&path, &["MD5".to_string(), "SHA256".to_string()]).unwrap();
+        assert_eq!(sums[0].algorithm, "SHA256");
+        assert_eq!(
+            sums[0].checksum,
+            "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+        );
+        assert_eq!(
+            checksums(&dir.path().join("missing.go"), &["SHA256".to_string()]),
+            None
+        );
+    }
+
+    #[test]
+    fn test_edit_after_launch_warns() {
+        let dir = tempfile::tempdir().unwrap();
+        let path = dir.path().join("fizzbuzz.go");
+        std::fs::write(&path, "package main").unwrap();
+        let snapshot = launch_snapshot("go", &path, []);
+        let launch_hash = &snapshot[&snapshot_key(&path)];
+        let set_mtime = |at: SystemTime| {
+            let file = std::fs::File::options().write(true).open(&path).unwrap();
+            file.set_modified(at).unwrap();
+        };
+        let launched = std::fs::metadata(&path).unwrap().modified().unwrap();
+
+        // Same content with a new mtime (a checkout): not stale
+        set_mtime(launched + Duration::from_secs(30));
+        assert_eq!(stale_source_warning(&path, launch_hash), None);
+
+        // New content with an old mtime (a copy preserving it): stale
+        std::fs::write(&path, "package main\n\nfunc main() {}").unwrap();
+        set_mtime(launched - Duration::from_secs(30));
+        let warning = stale_source_warning(&path, launch_hash).unwrap();
+        assert!(warning.contains("changed since"), "{}", warning);
+    }
+
+    #[test]
+    fn test_snapshot_covers_program_sources_and_breakpoint_files() {
+        let dir = tempfile::tempdir().unwrap();
+        let main = dir.path().join("main.go");
+        let helper = dir.path().join("helper.go");
+        let other = tempfile::tempdir().unwrap();
+        let shared = other.path().join("shared.go");
+        for path in [&main, &helper, &shared] {
+            std::fs::write(path, "package main").unwrap();
+        }
+        std::fs::write(dir.path().join("notes.txt"), "not go").unwrap();
+
+        let sources = vec![shared.display().to_string()];
+        let snapshot = launch_snapshot("go", &main, &sources);
+        assert_eq!(snapshot.len(), 3, "{:?}", snapshot);
+        for path in [&main, &helper, &shared] {
+            assert!(snapshot.contains_key(&snapshot_key(path)), "{:?}", path);
+        }
+    }
+}
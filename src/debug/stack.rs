@@ -0,0 +1,482 @@
+//! Bounded stack traces for deep or runaway recursion
+//!
+//! A stack overflow produces thousands of frames, most of them the same few
+//! calls over and over. Stacks are requested a page at a time and repeated
+//! runs of a frame cycle are collapsed into a single marker, so the trace
+//! stays readable and the response stays small.
+//!
+//! `find_frames` looks frames up by function instead of index, for callers
+//! that know the symbol they want to evaluate in but not how deep it is.
+//!
+//! Frames are only valid until the program resumes, but within a stop they
+//! don't change, so the pages loaded are kept in a `StackCache` until the
+//! next state change. Where the adapter pages stacks
+//! (`supportsDelayedStackTraceLoading`), a stop loads the top frame alone,
+//! for the stop's state; deeper frames are loaded when asked for.
+
+pub use crate::dap::client::DEFAULT_STACK_LEVELS;
+use crate::dap::types::StackFrame;
+use serde::Serialize;
+use std::collections::HashMap;
+
+/// Largest page of frames a caller may request
+pub const MAX_STACK_LEVELS: i32 = 1000;
+
+/// Longest cycle of frames that is detected (mutual recursion across this
+/// many functions)
+const MAX_CYCLE_FRAMES: usize = 8;
+
+/// A cycle must occur at least this often in a row to be collapsed
+const MIN_CYCLE_REPEATS: usize = 3;
+
+/// One page of a thread's stack
+#[derive(Debug, Clone)]
+pub struct StackPage {
+    pub frames: Vec<StackFrame>,
+    /// Index of the first frame in the page
+    pub start_frame: i32,
+    /// Depth of the whole stack, if known
+    pub total_frames: Option<i32>,
+}
+
+impl StackPage {
+    /// Build a page from an adapter response to a request for `levels` frames
+    ///
+    /// Adapters may omit `totalFrames`; a page shorter than requested then
+    /// still tells the depth, a full one doesn't.
+    pub fn new(
+        frames: Vec<StackFrame>,
+        start_frame: i32,
+        levels: i32,
+        total_frames: Option<i32>,
+    ) -> Self {
+        let len = frames.len() as i32;
+        let total_frames = match total_frames {
+            // Some adapters report 0 when they don't know
+            Some(total) if total >= start_frame + len && total > 0 => Some(total),
+            _ if len < levels => Some(start_frame + len),
+            _ => None,
+        };
+        Self {
+            frames,
+            start_frame,
+            total_frames,
+        }
+    }
+
+    /// Whether frames exist below this page
+    pub fn has_more(&self) -> bool {
+        !matches!(self.total_frames,
+            Some(total) if self.start_frame + self.frames.len() as i32 >= total)
+    }
+}
+
+/// Frames loaded at one stop, by thread
+///
+/// Each thread keeps the frames loaded from the top down; a page further
+/// down than that isn't kept. Everything is dropped at the next state change
+/// (`seq`, the session's `events_seq`), when frame ids stop being valid.
+#[derive(Debug, Clone, Default)]
+pub struct StackCache {
+    seq: u64,
+    stacks: HashMap<i32, LoadedStack>,
+}
+
+/// The top of a thread's stack, as far as it was loaded
+#[derive(Debug, Clone, Default)]
+pub struct LoadedStack {
+    pub frames: Vec<StackFrame>,
+    /// Depth of the whole stack, if known
+    pub total_frames: Option<i32>,
+}
+
+impl LoadedStack {
+    /// Whether frames below the loaded ones may exist
+    pub fn partial(&self) -> bool {
+        self.total_frames != Some(self.frames.len() as i32)
+    }
+}
+
+impl StackCache {
+    /// Frames of `thread_id` loaded at state change `seq`
+    pub fn loaded(&self, seq: u64, thread_id: i32) -> Option<&LoadedStack> {
+        (self.seq == seq).then(|| self.stacks.get(&thread_id))?
+    }
+
+    /// `levels` frames at `start_frame`, if they were loaded at state change
+    /// `seq` (or the stack was loaded to its bottom)
+    pub fn page(
+        &self,
+        seq: u64,
+        thread_id: i32,
+        start_frame: i32,
+        levels: i32,
+    ) -> Option<StackPage> {
+        let stack = self.loaded(seq, thread_id)?;
+        let start = usize::try_from(start_frame).ok()?;
+        let end = start.saturating_add(usize::try_from(levels).ok()?);
+        let end = if end <= stack.frames.len() {
+            end
+        } else if !stack.partial() && start <= stack.frames.len() {
+            stack.frames.len()
+        } else {
+            return None;
+        };
+        Some(StackPage {
+            frames: stack.frames[start..end].to_vec(),
+            start_frame,
+            total_frames: stack.total_frames,
+        })
+    }
+
+    /// Keep `page` of `thread_id`'s stack, loaded at state change `seq`
+    ///
+    /// Frames of an earlier state change are dropped first; a page loaded
+    /// before a later one is not kept.
+    pub fn insert(&mut self, seq: u64, thread_id: i32, page: &StackPage) {
+        if seq < self.seq {
+            return;
+        }
+        if seq > self.seq {
+            self.seq = seq;
+            self.stacks.clear();
+        }
+        let stack = self.stacks.entry(thread_id).or_default();
+        let Ok(start) = usize::try_from(page.start_frame) else {
+            return;
+        };
+        let loaded = stack.frames.len();
+        if start > loaded {
+            return;
+        }
+        stack
+            .frames
+            .extend(page.frames.iter().skip(loaded - start).cloned());
+        if page.total_frames.is_some() {
+            stack.total_frames = page.total_frames;
+        }
+    }
+}
+
+/// A page of frames as returned to clients, with recursion collapsed
+#[derive(Debug, Clone, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct StackReport {
+    pub stack_frames: Vec<StackEntry>,
+    pub start_frame: i32,
+    pub total_frames: Option<i32>,
+    /// Frames exist below this page (fetch them with a later start frame)
+    pub more_frames: bool,
+}
+
+impl From<StackPage> for StackReport {
+    fn from(page: StackPage) -> Self {
+        let more_frames = page.has_more();
+        Self {
+            stack_frames: collapse_cycles(page.frames),
+            start_frame: page.start_frame,
+            total_frames: page.total_frames,
+            more_frames,
+        }
+    }
+}
+
+/// An entry of a collapsed stack trace
+#[derive(Debug, Clone, Serialize)]
+#[serde(untagged)]
+pub enum StackEntry {
+    Frame(StackFrame),
+    Repeated(RepeatedFrames),
+}
+
+/// Marker standing in for consecutive repetitions of a frame cycle
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct RepeatedFrames {
+    /// Human readable summary, e.g. "frame countdown (app.py:12) repeated 196 times"
+    pub repeated: String,
+    /// Functions of the cycle, innermost first
+    pub functions: Vec<String>,
+    /// Times the cycle repeats beyond the one shown above the marker
+    pub times: usize,
+    /// Frames the marker stands for
+    pub frame_count: usize,
+}
+
+/// What makes two frames "the same" for cycle detection: same function at
+/// the same place. Frame ids differ for every frame and are ignored.
+fn frame_key(frame: &StackFrame) -> (&str, Option<&str>, i32) {
+    (
+        frame.name.as_str(),
+        frame.source.as_ref().and_then(|s| s.path.as_deref()),
+        frame.line,
+    )
+}
+
+fn describe(frame: &StackFrame) -> String {
+    let file = frame
+        .source
+        .as_ref()
+        .and_then(|s| s.name.as_deref().or(s.path.as_deref()))
+        .map(|f| f.rsplit(['/', '\\']).next().unwrap_or(f));
+    match file {
+        Some(file) => format!("{} ({}:{})", frame.name, file, frame.line),
+        None => frame.name.clone(),
+    }
+}
+
+/// Number of times the cycle of `len` frames starting at `start` occurs in a row
+fn cycle_repeats(frames: &[StackFrame], start: usize, len: usize) -> usize {
+    let mut repeats = 1;
+    while start + (repeats + 1) * len <= frames.len()
+        && (0..len)
+            .all(|i| frame_key(&frames[start + i]) == frame_key(&frames[start + repeats * len + i]))
+    {
+        repeats += 1;
+    }
+    repeats
+}
+
+/// Collapse repeated runs of a frame cycle
+///
+/// The first occurrence of a cycle is kept so its frames stay inspectable;
+/// the following repetitions become one `RepeatedFrames` marker. Where
+/// cycles of several lengths start at the same frame, the one covering the
+/// most frames wins, the shortest on a tie (a pair repeated is also a cycle
+/// of four).
+pub fn collapse_cycles(frames: Vec<StackFrame>) -> Vec<StackEntry> {
+    let mut entries = Vec::new();
+    let mut i = 0;
+    while i < frames.len() {
+        let best = (1..=MAX_CYCLE_FRAMES)
+            .map(|len| (len, cycle_repeats(&frames, i, len)))
+            .filter(|&(_, repeats)| repeats >= MIN_CYCLE_REPEATS)
+            .max_by_key(|&(len, repeats)| (len * repeats, std::cmp::Reverse(len)));
+
+        match best {
+            Some((len, repeats)) => {
+                let cycle = &frames[i..i + len];
+                entries.extend(cycle.iter().cloned().map(StackEntry::Frame));
+                let times = repeats - 1;
+                let repeated = if len == 1 {
+                    format!("frame {} repeated {} times", describe(&cycle[0]), times)
+                } else {
+                    format!(
+                        "frames {} repeated {} times",
+                        cycle.iter().map(describe).collect::<Vec<_>>().join(" → "),
+                        times
+                    )
+                };
+                entries.push(StackEntry::Repeated(RepeatedFrames {
+                    repeated,
+                    functions: cycle.iter().map(|f| f.name.clone()).collect(),
+                    times,
+                    frame_count: times * len,
+                }));
+                i += repeats * len;
+            }
+            None => {
+                entries.push(StackEntry::Frame(frames[i].clone()));
+                i += 1;
+            }
+        }
+    }
+    entries
+}
+
+/// A frame of the function searched for by `find_frames`
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct FrameMatch {
+    pub frame_id: i32,
+    /// Index in the stack, 0 being the innermost frame
+    pub depth: i32,
+    pub name: String,
+    pub path: Option<String>,
+    pub line: i32,
+}
+
+/// Whether the frame `name` is of `function`
+///
+/// Adapters qualify names differently (`main.handler`, `(*Server).handle`,
+/// `app::handler`, `Server#handle`, `block in Server#handle`), so a name
+/// matches when it is `function` or ends with it after a `.`, `:`, `#`,
+/// `/` or space. Qualifying the function narrows the match.
+pub fn matches_function(name: &str, function: &str) -> bool {
+    name.strip_suffix(function).is_some_and(|qualifier| {
+        qualifier.is_empty() || qualifier.ends_with(['.', ':', '#', '/', ' '])
+    })
+}
+
+/// Frames of `function` in a page, innermost first (several when it recurses)
+pub fn find_frames(page: &StackPage, function: &str) -> Vec<FrameMatch> {
+    page.frames
+        .iter()
+        .zip(page.start_frame..)
+        .filter(|(frame, _)| matches_function(&frame.name, function))
+        .map(|(frame, depth)| FrameMatch {
+            frame_id: frame.id,
+            depth,
+            name: frame.name.clone(),
+            path: frame.source.as_ref().and_then(|s| s.path.clone()),
+            line: frame.line,
+        })
+        .collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::dap::types::Source;
+
+    fn frame(id: i32, name: &str, line: i32) -> StackFrame {
+        StackFrame {
+            id,
+            name: name.to_string(),
+            source: Some(Source {
+                name: Some("deep_recursion.py".to_string()),
+                path: Some("/w/deep_recursion.py".to_string()),
+                source_reference: None,
+                checksums: None,
+            }),
+            line,
+            column: 0,
+            end_line: None,
+            end_column: None,
+            instruction_pointer_reference: None,
+        }
+    }
+
+    #[test]
+    fn test_overflowing_recursion_collapsed() {
+        // First page of tests/fixtures/deep_recursion.py stopped on RecursionError
+        let mut frames = Vec::new();
+        for pair in 0..100 {
+            frames.push(frame(2 * pair + 1, "descend", 12));
+            frames.push(frame(2 * pair + 2, "visit", 16));
+        }
+        let page = StackPage::new(frames, 0, DEFAULT_STACK_LEVELS, Some(2003));
+        assert!(page.has_more());
+
+        let entries = collapse_cycles(page.frames);
+        assert_eq!(entries.len(), 3);
+        assert!(matches!(&entries[0], StackEntry::Frame(f) if f.id == 1 && f.name == "descend"));
+        assert!(matches!(&entries[1], StackEntry::Frame(f) if f.name == "visit"));
+        let StackEntry::Repeated(marker) = &entries[2] else {
+            panic!("expected a repeated-frames marker");
+        };
+        assert_eq!(marker.functions, vec!["descend", "visit"]);
+        assert_eq!((marker.times, marker.frame_count), (99, 198));
+        assert_eq!(
+            marker.repeated,
+            "frames descend (deep_recursion.py:12) → visit (deep_recursion.py:16) repeated 99 times"
+        );
+    }
+
+    #[test]
+    fn test_cache_serves_loaded_frames_until_state_changes() {
+        let mut cache = StackCache::default();
+        let frames: Vec<StackFrame> = (1..=30).map(|id| frame(id, "f", id)).collect();
+
+        // A stop loads the top frame alone
+        cache.insert(4, 1, &StackPage::new(frames[..1].to_vec(), 0, 1, None));
+        assert!(cache.loaded(4, 1).unwrap().partial());
+        assert!(cache.page(4, 1, 0, 1).is_some());
+        assert!(cache.page(4, 1, 0, 20).is_none());
+
+        // Overlapping pages extend it; pages further down aren't kept
+        cache.insert(4, 1, &StackPage::new(frames[..20].to_vec(), 0, 20, None));
+        cache.insert(
+            4,
+            1,
+            &StackPage::new(frames[25..].to_vec(), 25, 20, Some(30)),
+        );
+        assert_eq!(cache.loaded(4, 1).unwrap().frames.len(), 20);
+        let page = cache.page(4, 1, 10, 5).unwrap();
+        assert_eq!(
+            page.frames.iter().map(|f| f.id).collect::<Vec<_>>(),
+            vec![11, 12, 13, 14, 15]
+        );
+        assert!(cache.page(4, 1, 15, 10).is_none());
+
+        cache.insert(
+            4,
+            1,
+            &StackPage::new(frames[20..].to_vec(), 20, 20, Some(30)),
+        );
+        let stack = cache.loaded(4, 1).unwrap();
+        assert!(!stack.partial());
+        let page = cache.page(4, 1, 25, 20).unwrap();
+        assert_eq!(page.frames.len(), 5);
+        assert!(!page.has_more());
+
+        // Frames of another stop are never served, nor kept when late
+        assert!(cache.page(5, 1, 0, 1).is_none());
+        cache.insert(6, 1, &StackPage::new(frames[..1].to_vec(), 0, 1, None));
+        cache.insert(5, 1, &StackPage::new(frames.clone(), 0, 30, Some(30)));
+        assert_eq!(cache.loaded(6, 1).unwrap().frames.len(), 1);
+    }
+
+    #[test]
+    fn test_single_frame_recursion_and_tail_kept() {
+        let mut frames = vec![frame(1, "explode", 3)];
+        frames.extend((2..=6).map(|id| frame(id, "countdown", 7)));
+        frames.push(frame(7, "main", 21));
+        let page = StackPage::new(frames, 0, DEFAULT_STACK_LEVELS, None);
+        assert_eq!(page.total_frames, Some(7));
+        assert!(!page.has_more());
+
+        let entries = collapse_cycles(page.frames);
+        let shape: Vec<String> = entries
+            .iter()
+            .map(|e| match e {
+                StackEntry::Frame(f) => f.name.clone(),
+                StackEntry::Repeated(r) => format!("x{}", r.times),
+            })
+            .collect();
+        assert_eq!(shape, vec!["explode", "countdown", "x4", "main"]);
+    }
+
+    #[test]
+    fn test_frames_found_by_function() {
+        for (name, function, expected) in [
+            ("handler", "handler", true),
+            ("main.handler", "handler", true),
+            ("(*Server).handle", "handle", true),
+            ("app::handler", "handler", true),
+            ("block in Server#handle", "Server#handle", true),
+            ("main.handler", "main.handler", true),
+            ("main.handlerFunc", "handler", false),
+            ("prehandler", "handler", false),
+            ("main.handler", "other.handler", false),
+        ] {
+            assert_eq!(matches_function(name, function), expected, "{}", name);
+        }
+
+        let mut frames = vec![frame(10, "explode", 3)];
+        frames.extend((11..=13).map(|id| frame(id, "countdown", 7)));
+        frames.push(frame(14, "main", 21));
+        let page = StackPage::new(frames, 5, DEFAULT_STACK_LEVELS, None);
+        let found = find_frames(&page, "countdown");
+        assert_eq!(
+            found
+                .iter()
+                .map(|m| (m.frame_id, m.depth))
+                .collect::<Vec<_>>(),
+            [(11, 6), (12, 7), (13, 8)]
+        );
+        assert_eq!(found[0].path.as_deref(), Some("/w/deep_recursion.py"));
+        assert!(find_frames(&page, "missing").is_empty());
+    }
+
+    #[test]
+    fn test_short_runs_not_collapsed_and_unknown_depth() {
+        let frames = vec![frame(1, "a", 1), frame(2, "a", 1), frame(3, "b", 2)];
+        assert_eq!(collapse_cycles(frames.clone()).len(), 3);
+
+        // A full page without totalFrames leaves the depth unknown
+        let page = StackPage::new(frames, 0, 3, None);
+        assert_eq!(page.total_frames, None);
+        assert!(page.has_more());
+    }
+}
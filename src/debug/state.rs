@@ -1,5 +1,43 @@
+use super::caller_filter::{CallerConstraint, CallerMatch};
+use super::change_watch::{ChangeWatch, WatchStop, WatchStrategy};
+use super::continue_past::ContinuePast;
+use super::crash::{CrashReport, OutputTail};
+use super::disassembly::DisassemblyWindow;
+use super::event_log::{self, EventLog, SessionEvent};
+use super::file_watch::{Relaunch, WatchStatus};
+use super::group::Membership;
+use super::hit_stats::{BreakpointHits, BreakpointSummary, BreakpointSummaryEntry, ExitDiagnosis};
+use super::output_log::OutputLog;
+use super::post_mortem::PostMortem;
+use super::repl::Repls;
+use super::resource_usage::{ResourceSummary, ResourceUsage};
+use super::stack::{StackCache, StackReport};
+use super::subscription::Subscription;
+use super::transcript::Transcript;
+use super::webhook::Webhook;
+use crate::adapters::EntryBreakpoint;
+use crate::dap::types::{ExceptionInfo, ExceptionOptions, StackFrame};
+use crate::{Error, Result};
 use serde::{Deserialize, Serialize};
-use std::collections::HashMap;
+use std::collections::{HashMap, VecDeque};
+use std::sync::Arc;
+use std::time::{Instant, SystemTime, UNIX_EPOCH};
+use tokio::sync::watch;
+
+/// First delay suggested to clients polling a running session
+pub const MIN_POLL_DELAY_MS: u64 = 100;
+
+/// Longest delay suggested to clients polling a running session
+pub const MAX_POLL_DELAY_MS: u64 = 2000;
+
+/// Memory changes kept per session; older ones are dropped
+pub const MAX_MEMORY_CHANGES: usize = 32;
+
+/// Category startup output is reported with
+pub const STARTUP_OUTPUT_CATEGORY: &str = "startup";
+
+/// Startup lines quoted in the error of a failed start
+const STARTUP_LINES_IN_ERROR: usize = 10;
 
 #[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
 pub enum DebugState {
@@ -13,19 +51,283 @@ pub enum DebugState {
     Failed { error: String },
 }
 
+/// Run state of a single thread
+///
+/// Adapters that report `allThreadsStopped: false` only stop the thread named in
+/// the event, so a session can have stopped and running threads at the same time.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub enum ThreadState {
+    Running,
+    Stopped { reason: String },
+}
+
 #[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
 pub struct Breakpoint {
     pub source_path: String,
     pub line: i32,
     pub id: Option<i32>,
     pub verified: bool,
+    /// Disabled breakpoints are kept here but not sent to the adapter
+    #[serde(default = "default_enabled")]
+    pub enabled: bool,
+    #[serde(default)]
+    pub condition: Option<String>,
+    #[serde(default)]
+    pub hit_condition: Option<String>,
+    /// Logpoint message (the adapter logs instead of stopping)
+    #[serde(default)]
+    pub log_message: Option<String>,
+    /// Removed after the first stop it causes
+    #[serde(default)]
+    pub temporary: bool,
+    /// Line the adapter put the breakpoint on, when it differs from `line`
+    #[serde(default)]
+    pub verified_line: Option<i32>,
+    /// Why the adapter moved the breakpoint (see `breakpoint_move`)
+    #[serde(default)]
+    pub move_explanation: Option<String>,
+    /// Stop only when called from a matching function (see `caller_filter`)
+    #[serde(default)]
+    pub caller: Option<CallerConstraint>,
+}
+
+impl Breakpoint {
+    /// Whether the breakpoint is on `line`, as requested or as verified
+    pub fn is_at(&self, line: i32) -> bool {
+        self.line == line || self.verified_line == Some(line)
+    }
+
+    /// Line the program stops at
+    pub fn effective_line(&self) -> i32 {
+        self.verified_line.unwrap_or(self.line)
+    }
+}
+
+fn default_enabled() -> bool {
+    true
+}
+
+/// An instruction breakpoint and the adapter's answer to it
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct InstructionBreakpoint {
+    /// Memory reference the breakpoint is relative to (e.g. `0x4a1f20`)
+    pub instruction_reference: String,
+    /// Byte offset from `instruction_reference` (may be negative)
+    #[serde(default)]
+    pub offset: Option<i64>,
+    #[serde(default)]
+    pub condition: Option<String>,
+    #[serde(default)]
+    pub hit_condition: Option<String>,
+    pub id: Option<i32>,
+    pub verified: bool,
+    /// Why the adapter couldn't verify the breakpoint, or why it is stale
+    #[serde(default)]
+    pub message: Option<String>,
+    /// Modification time of the program when the breakpoint was set
+    #[serde(skip)]
+    pub program_modified: Option<SystemTime>,
+    /// The program changed since the breakpoint was set, so the address may
+    /// no longer hold the same instruction; stale breakpoints are not sent to
+    /// the adapter until set again
+    #[serde(default)]
+    pub stale: bool,
+}
+
+impl InstructionBreakpoint {
+    fn same_location(&self, reference: &str, offset: Option<i64>) -> bool {
+        self.instruction_reference == reference && self.offset.unwrap_or(0) == offset.unwrap_or(0)
+    }
+}
+
+/// A function breakpoint and the adapter's answer to it
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct FunctionBreakpoint {
+    /// Function name as the adapter understands it (e.g. `main.handler`)
+    pub name: String,
+    #[serde(default)]
+    pub condition: Option<String>,
+    #[serde(default)]
+    pub hit_condition: Option<String>,
+    pub id: Option<i32>,
+    pub verified: bool,
+    /// Why the adapter couldn't verify the breakpoint
+    #[serde(default)]
+    pub message: Option<String>,
+}
+
+/// Post-mortem snapshot taken when the program stops on an exception
+/// (sessions started with `captureOnException`)
+#[derive(Debug, Clone, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ExceptionCapture {
+    /// `events_seq` of the stop the snapshot was taken at
+    #[serde(skip)]
+    pub stop_seq: u64,
+    pub thread_id: i32,
+    /// None if the adapter doesn't support `exceptionInfo`
+    pub exception: Option<ExceptionInfo>,
+    pub stack: StackReport,
+    /// Variables of the top frame's non-expensive scopes
+    pub locals: Vec<CapturedLocal>,
+    pub locals_truncated: bool,
+}
+
+#[derive(Debug, Clone, Serialize)]
+pub struct CapturedLocal {
+    pub name: String,
+    /// Redacted and shortened
+    pub value: String,
+    #[serde(rename = "type", skip_serializing_if = "Option::is_none")]
+    pub type_name: Option<String>,
+}
+
+/// A process the debugger reported with a `process` event
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct DebuggeeProcess {
+    pub name: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub pid: Option<i64>,
+    /// `launch`, `attach` or `attachForSuspendedLaunch`
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub start_method: Option<String>,
+}
+
+/// A range of memory the debugger reported modified with a `memory` event
+/// (after `writeMemory`, `setVariable` and the like)
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct MemoryChange {
+    pub memory_reference: String,
+    /// Bytes between the reference and the first modified byte
+    pub offset: i64,
+    pub count: i64,
+    /// `events_seq` the change was recorded at; values read before it may
+    /// be stale
+    pub events_seq: u64,
 }
 
 #[derive(Debug, Clone)]
 pub struct SessionState {
     pub state: DebugState,
     pub breakpoints: HashMap<String, Vec<Breakpoint>>,
+    /// Instruction breakpoints, in the order they are sent to the adapter
+    pub instruction_breakpoints: Vec<InstructionBreakpoint>,
+    /// Function breakpoints, in the order they are sent to the adapter
+    pub function_breakpoints: Vec<FunctionBreakpoint>,
+    /// Exception breakpoint modes in effect (`raised`, `uncaught`)
+    pub exception_breakpoints: Vec<String>,
+    /// Exception options in effect, sent along with the modes
+    pub exception_options: Vec<ExceptionOptions>,
     pub threads: Vec<i32>,
+    /// Per-thread run state (threads without an entry are in an unknown state)
+    pub thread_states: HashMap<i32, ThreadState>,
+    /// Adapter ids of the breakpoints that caused the last stop
+    /// (`hitBreakpointIds` of the `stopped` event)
+    pub hit_breakpoint_ids: Vec<i32>,
+    /// Temporary breakpoints the last stop removed, still reported as hit
+    pub fired_temporary: Vec<Breakpoint>,
+    /// Number of state changes recorded so far; a cursor for pollers
+    pub events_seq: u64,
+    /// Publishes `events_seq` to long-polling readers
+    events_tx: Arc<watch::Sender<u64>>,
+    /// What advanced `events_seq`, for `debugger_events`
+    pub events: EventLog,
+    /// Last suggested poll delay and the `events_seq` it was suggested at
+    poll_backoff: Option<(u64, u64)>,
+    /// Launches, stops, evaluations and exit code, for export
+    pub transcript: Transcript,
+    /// Snapshot of the latest exception stop; kept after the program
+    /// terminates as its post-mortem
+    pub exception_capture: Option<ExceptionCapture>,
+    /// Triage report of the unhandled exception or panic the program died of
+    pub crash_report: Option<CrashReport>,
+    /// Last lines of program output
+    pub output_tail: OutputTail,
+    /// Program output as bytes, for `debugger_get_output`
+    pub output_log: OutputLog,
+    /// Output of any category but telemetry before the launch (or attach)
+    /// response: adapter diagnostics and build messages that explain a
+    /// launch failure
+    pub startup_output: OutputTail,
+    /// The launch response arrived; output is no longer startup output
+    pub launched: bool,
+    /// Last disassembly window read, for scrolling
+    pub disassembly_window: Option<DisassemblyWindow>,
+    /// Caller's spelling of paths whose on-disk casing differs, by on-disk path
+    pub path_aliases: HashMap<String, String>,
+    /// When the program last stopped, to order stops across sessions
+    pub stopped_at: Option<Instant>,
+    /// Group and member name, for sessions started in a group
+    pub group_member: Option<Membership>,
+    /// Entry breakpoint still waiting for its stop (`entry: "user_main"`)
+    pub entry_breakpoint: Option<EntryBreakpoint>,
+    /// Continue from entry stops at once (`autoContinueFromEntry`)
+    pub auto_continue_entry: bool,
+    /// Processes reported by `process` events: the debuggee, then any child
+    /// processes the debugger follows
+    pub processes: Vec<DebuggeeProcess>,
+    /// REPLs opened with `debugger_repl_open`
+    pub repls: Repls,
+    /// Memory and CPU readings of the debuggee (see `resource_usage`)
+    pub resource_usage: Option<ResourceUsage>,
+    /// Check breakpoint sources against the program (`verifySource`)
+    pub verify_source: bool,
+    /// Why a source's breakpoints may bind to stale code, by source path
+    pub source_warnings: HashMap<String, String>,
+    /// Content hashes of the sources as of the last launch (see
+    /// `source_check::launch_snapshot`)
+    pub launch_sources: HashMap<String, String>,
+    /// Recent `memory` events, oldest first
+    pub memory_changes: VecDeque<MemoryChange>,
+    /// Breakpoints whose stops are followed (see `subscription`)
+    pub subscription: Subscription,
+    /// Continue at once from stops for unfollowed breakpoints only
+    pub auto_continue_unsubscribed: bool,
+    /// Breakpoint to continue past until its n-th hit (see `continue_past`)
+    pub continue_past: Option<ContinuePast>,
+    /// Frames loaded since the last state change (see `stack`)
+    pub stack_cache: StackCache,
+    /// Stops for each breakpoint id, including the ones continued at once
+    pub breakpoint_hits: BreakpointHits,
+    /// Breakpoint statistics taken when the session reached a terminal
+    /// state (see `hit_stats`)
+    pub breakpoint_summary: Option<BreakpointSummary>,
+    /// Why the program ended without hitting any of its verified
+    /// breakpoints, taken when the adapter reports its end (see `end_program`)
+    pub exit_diagnosis: Option<ExitDiagnosis>,
+    /// The session is being disconnected, so the program's end is not its own
+    pub disconnecting: bool,
+    /// Exit code, crash and last output, taken with `breakpoint_summary` at
+    /// termination (see `post_mortem`)
+    pub post_mortem: Option<PostMortem>,
+    /// A step or pause was sent and its stop hasn't arrived yet
+    pub user_stop_pending: bool,
+    /// Where stops, exits and crashes are POSTed (`webhookUrl`)
+    pub webhook: Option<Webhook>,
+    /// Continue from stops on exceptions and panics nothing handles, unless
+    /// exception breakpoints asked for them (`onUncaught: terminate`)
+    pub terminate_on_uncaught: bool,
+    /// Expressions watched for changes (see `change_watch`)
+    pub change_watches: Vec<ChangeWatch>,
+    /// The change a watch stopped the program for at the last stop (set
+    /// after `apply_stopped`, which clears it)
+    pub watch_stop: Option<WatchStop>,
+    /// The caller a caller-constrained breakpoint stopped the program for at
+    /// the last stop (see `caller_filter`; set after `apply_stopped`, which
+    /// clears it)
+    pub caller_stop: Option<CallerMatch>,
+    /// Hits of caller-constrained breakpoints continued past since the last
+    /// reported stop
+    pub caller_skips: u32,
+    /// Watch mode: sources watched for changes to relaunch on (see
+    /// `file_watch`); None when not watching
+    pub file_watch: Option<WatchStatus>,
+    /// The launch's `expressions` argument: CodeLLDB's expression evaluator,
+    /// which conditions sharing a line are ORed for (see `shared_line`)
+    pub expressions: Option<String>,
 }
 
 impl Default for SessionState {
@@ -39,12 +341,106 @@ impl SessionState {
         Self {
             state: DebugState::NotStarted,
             breakpoints: HashMap::new(),
+            instruction_breakpoints: Vec::new(),
+            function_breakpoints: Vec::new(),
+            exception_breakpoints: Vec::new(),
+            exception_options: Vec::new(),
             threads: Vec::new(),
+            thread_states: HashMap::new(),
+            hit_breakpoint_ids: Vec::new(),
+            fired_temporary: Vec::new(),
+            events_seq: 0,
+            events_tx: Arc::new(watch::channel(0).0),
+            events: EventLog::default(),
+            poll_backoff: None,
+            transcript: Transcript::new(),
+            exception_capture: None,
+            crash_report: None,
+            output_tail: OutputTail::default(),
+            output_log: OutputLog::default(),
+            startup_output: OutputTail::default(),
+            launched: false,
+            disassembly_window: None,
+            path_aliases: HashMap::new(),
+            stopped_at: None,
+            group_member: None,
+            entry_breakpoint: None,
+            auto_continue_entry: false,
+            processes: Vec::new(),
+            repls: Repls::default(),
+            resource_usage: None,
+            memory_changes: VecDeque::new(),
+            subscription: Subscription::default(),
+            auto_continue_unsubscribed: false,
+            continue_past: None,
+            stack_cache: StackCache::default(),
+            breakpoint_hits: BreakpointHits::default(),
+            breakpoint_summary: None,
+            exit_diagnosis: None,
+            disconnecting: false,
+            post_mortem: None,
+            user_stop_pending: false,
+            webhook: None,
+            terminate_on_uncaught: false,
+            file_watch: None,
+            expressions: None,
+            change_watches: Vec::new(),
+            watch_stop: None,
+            caller_stop: None,
+            caller_skips: 0,
+            verify_source: false,
+            source_warnings: HashMap::new(),
+            launch_sources: HashMap::new(),
         }
     }
 
+    /// When the program was last launched (or attached to)
+    pub fn launched_at(&self) -> Option<SystemTime> {
+        let launch = self.transcript.launches.last()?;
+        let ms = self.transcript.started_at_ms + launch.at_ms;
+        Some(UNIX_EPOCH + std::time::Duration::from_millis(ms))
+    }
+
     pub fn set_state(&mut self, state: DebugState) {
+        let terminal = matches!(state, DebugState::Terminated | DebugState::Failed { .. });
+        if terminal && self.breakpoint_summary.is_none() {
+            self.breakpoint_summary = Some(self.summarize_breakpoints());
+            self.post_mortem = Some(self.take_post_mortem(&state));
+        }
+        let body = event_log::state_body(&state);
         self.state = state;
+        self.record_event("state", body);
+    }
+
+    /// Advance `events_seq` for a change of `kind`, logging it and waking
+    /// long-polling readers
+    fn record_event(&mut self, kind: &str, body: serde_json::Value) {
+        self.events_seq += 1;
+        self.events.push(SessionEvent {
+            seq: self.events_seq,
+            at_ms: self.transcript.elapsed_ms(),
+            kind: kind.to_string(),
+            body,
+        });
+        self.events_tx.send_replace(self.events_seq);
+    }
+
+    /// Receiver notified whenever the state changes
+    pub fn subscribe(&self) -> watch::Receiver<u64> {
+        self.events_tx.subscribe()
+    }
+
+    /// Delay to suggest to a client polling this session
+    ///
+    /// Doubles from `MIN_POLL_DELAY_MS` up to `MAX_POLL_DELAY_MS` with every
+    /// poll that finds nothing new, and starts over after any state change.
+    pub fn next_poll_delay(&mut self) -> u64 {
+        let delay = match self.poll_backoff {
+            Some((seq, delay)) if seq == self.events_seq => (delay * 2).min(MAX_POLL_DELAY_MS),
+            _ => MIN_POLL_DELAY_MS,
+        };
+        self.poll_backoff = Some((self.events_seq, delay));
+        delay
     }
 
     pub fn add_breakpoint(&mut self, source: String, line: i32) {
@@ -53,29 +449,833 @@ impl SessionState {
             line,
             id: None,
             verified: false,
+            enabled: true,
+            condition: None,
+            hit_condition: None,
+            log_message: None,
+            temporary: false,
+            verified_line: None,
+            move_explanation: None,
+            caller: None,
+        };
+        self.insert_breakpoint(bp);
+    }
+
+    /// Add a breakpoint, replacing any existing one on the same line (as
+    /// requested or as verified) and the line's change watch
+    pub fn insert_breakpoint(&mut self, bp: Breakpoint) {
+        self.change_watches
+            .retain(|w| !(w.source_path == bp.source_path && w.line == bp.line));
+        let bps = self.breakpoints.entry(bp.source_path.clone()).or_default();
+        bps.retain(|b| !b.is_at(bp.line));
+        bps.push(bp);
+    }
+
+    /// Add a conditional breakpoint next to the others on its line,
+    /// replacing one with the same condition (see `shared_line`)
+    pub fn insert_shared_breakpoint(&mut self, bp: Breakpoint) {
+        let bps = self.breakpoints.entry(bp.source_path.clone()).or_default();
+        bps.retain(|b| !(b.line == bp.line && b.condition == bp.condition));
+        bps.push(bp);
+    }
+
+    /// Remove the breakpoint on `line` with `condition`, or every breakpoint
+    /// on the line when `condition` is None, returning how many were removed
+    pub fn remove_breakpoint(&mut self, source: &str, line: i32, condition: Option<&str>) -> usize {
+        let Some(bps) = self.breakpoints.get_mut(source) else {
+            return 0;
         };
+        let before = bps.len();
+        bps.retain(|b| {
+            !(b.is_at(line) && condition.is_none_or(|c| b.condition.as_deref() == Some(c)))
+        });
+        before - bps.len()
+    }
 
-        self.breakpoints.entry(source).or_default().push(bp);
+    /// Record where the adapter put a breakpoint requested on `line`, and why
+    /// it moved it (None: it didn't)
+    pub fn record_breakpoint_move(
+        &mut self,
+        source: &str,
+        line: i32,
+        moved: Option<(i32, String)>,
+    ) {
+        let (verified_line, explanation) = moved.unzip();
+        for bp in self
+            .breakpoints
+            .get_mut(source)
+            .into_iter()
+            .flatten()
+            .filter(|b| b.line == line)
+        {
+            bp.verified_line = verified_line;
+            bp.move_explanation = explanation.clone();
+        }
     }
 
+    /// Record the adapter's id and verification for the breakpoints
+    /// requested on `line`
     pub fn update_breakpoint(&mut self, source: &str, line: i32, id: i32, verified: bool) {
         if let Some(bps) = self.breakpoints.get_mut(source) {
-            if let Some(bp) = bps.iter_mut().find(|b| b.line == line) {
+            for bp in bps.iter_mut().filter(|b| b.line == line) {
                 bp.id = Some(id);
                 bp.verified = verified;
             }
         }
     }
 
+    /// Apply a `breakpoint` event (reason `changed`): the adapter bound,
+    /// moved or unbound breakpoint `id` after answering `setBreakpoints`, as
+    /// java-debug does once the class of a deferred breakpoint loads.
+    /// Returns whether the id is tracked.
+    pub fn apply_breakpoint_change(&mut self, id: i32, verified: bool, line: Option<i32>) -> bool {
+        let mut known = false;
+        for bp in self
+            .breakpoints
+            .values_mut()
+            .flatten()
+            .filter(|bp| bp.id == Some(id))
+        {
+            known = true;
+            bp.verified = verified;
+            match line {
+                Some(line) if line != bp.line => {
+                    bp.verified_line = Some(line);
+                    bp.move_explanation = Some(format!(
+                        "the debugger bound it to line {} after the program started",
+                        line
+                    ));
+                }
+                Some(_) => {
+                    bp.verified_line = None;
+                    bp.move_explanation = None;
+                }
+                None => {}
+            }
+        }
+        known
+    }
+
     pub fn get_breakpoints(&self, source: &str) -> Vec<Breakpoint> {
         self.breakpoints.get(source).cloned().unwrap_or_default()
     }
 
+    /// Breakpoints for a source that should be sent to the adapter
+    pub fn get_enabled_breakpoints(&self, source: &str) -> Vec<Breakpoint> {
+        self.breakpoints
+            .get(source)
+            .map(|bps| bps.iter().filter(|bp| bp.enabled).cloned().collect())
+            .unwrap_or_default()
+    }
+
+    /// Enable or disable a breakpoint by id, returning its source path
+    ///
+    /// Breakpoints sharing a line share the id, and change together.
+    pub fn set_breakpoint_enabled(&mut self, id: i32, enabled: bool) -> Option<String> {
+        let mut source_path = None;
+        for bp in self
+            .breakpoints
+            .values_mut()
+            .flat_map(|bps| bps.iter_mut())
+            .filter(|bp| bp.id == Some(id))
+        {
+            bp.enabled = enabled;
+            source_path = Some(bp.source_path.clone());
+        }
+        source_path
+    }
+
+    /// Add a function breakpoint, replacing any on the same function
+    pub fn insert_function_breakpoint(&mut self, bp: FunctionBreakpoint) {
+        self.function_breakpoints.retain(|b| b.name != bp.name);
+        self.function_breakpoints.push(bp);
+    }
+
+    /// Remove the breakpoint on a function, returning whether there was one
+    pub fn remove_function_breakpoint(&mut self, name: &str) -> bool {
+        let before = self.function_breakpoints.len();
+        self.function_breakpoints.retain(|b| b.name != name);
+        self.function_breakpoints.len() != before
+    }
+
+    /// Record the adapter's answer for the function breakpoints, in the
+    /// order they were sent
+    pub fn update_function_breakpoints(
+        &mut self,
+        results: impl IntoIterator<Item = (Option<i32>, bool, Option<String>)>,
+    ) {
+        for (bp, (id, verified, message)) in self.function_breakpoints.iter_mut().zip(results) {
+            bp.id = id;
+            bp.verified = verified;
+            bp.message = message;
+        }
+    }
+
+    /// Add an instruction breakpoint, replacing any at the same address
+    pub fn insert_instruction_breakpoint(&mut self, bp: InstructionBreakpoint) {
+        self.instruction_breakpoints
+            .retain(|b| !b.same_location(&bp.instruction_reference, bp.offset));
+        self.instruction_breakpoints.push(bp);
+    }
+
+    /// Remove the instruction breakpoint at an address, returning whether there was one
+    pub fn remove_instruction_breakpoint(&mut self, reference: &str, offset: Option<i64>) -> bool {
+        let before = self.instruction_breakpoints.len();
+        self.instruction_breakpoints
+            .retain(|b| !b.same_location(reference, offset));
+        self.instruction_breakpoints.len() != before
+    }
+
+    /// The instruction breakpoint at an address
+    pub fn find_instruction_breakpoint(
+        &self,
+        reference: &str,
+        offset: Option<i64>,
+    ) -> Option<&InstructionBreakpoint> {
+        self.instruction_breakpoints
+            .iter()
+            .find(|b| b.same_location(reference, offset))
+    }
+
+    /// Instruction breakpoints that should be sent to the adapter
+    pub fn armed_instruction_breakpoints(&self) -> Vec<InstructionBreakpoint> {
+        self.instruction_breakpoints
+            .iter()
+            .filter(|bp| !bp.stale)
+            .cloned()
+            .collect()
+    }
+
+    /// Record the adapter's answer for the armed instruction breakpoints, in
+    /// the order they were sent
+    pub fn update_instruction_breakpoints(
+        &mut self,
+        results: impl IntoIterator<Item = (Option<i32>, bool, Option<String>)>,
+    ) {
+        let armed = self
+            .instruction_breakpoints
+            .iter_mut()
+            .filter(|bp| !bp.stale);
+        for (bp, (id, verified, message)) in armed.zip(results) {
+            bp.id = id;
+            bp.verified = verified;
+            bp.message = message;
+        }
+    }
+
+    /// Mark instruction breakpoints set against another build of the program
+    /// as stale, returning how many were newly marked
+    ///
+    /// Breakpoints whose program modification time is unknown (then or now)
+    /// are kept armed.
+    pub fn invalidate_instruction_breakpoints(
+        &mut self,
+        program_modified: Option<SystemTime>,
+    ) -> usize {
+        let mut marked = 0;
+        for bp in self
+            .instruction_breakpoints
+            .iter_mut()
+            .filter(|bp| !bp.stale)
+        {
+            let changed = matches!(
+                (bp.program_modified, program_modified),
+                (Some(then), Some(now)) if then != now
+            );
+            if changed {
+                bp.stale = true;
+                bp.verified = false;
+                bp.id = None;
+                bp.message = Some(
+                    "Program changed since the breakpoint was set; set it again at the new address"
+                        .to_string(),
+                );
+                marked += 1;
+            }
+        }
+        marked
+    }
+
     pub fn add_thread(&mut self, thread_id: i32) {
         if !self.threads.contains(&thread_id) {
             self.threads.push(thread_id);
         }
     }
+
+    /// Keep the text of an `output` event: program output (stdout, stderr)
+    /// for crash reports and `debugger_get_output`, and anything but
+    /// telemetry as startup output until the launch response
+    pub fn record_output(&mut self, category: Option<&str>, text: &str) {
+        if !self.launched && category != Some("telemetry") {
+            self.startup_output.push(text);
+        }
+        if matches!(category, Some("stdout" | "stderr")) {
+            self.output_tail.push(text);
+            self.output_log.push(text.as_bytes());
+        }
+    }
+
+    /// Startup output lines, redacted
+    pub fn startup_lines(&self) -> Vec<String> {
+        self.startup_output
+            .lines()
+            .iter()
+            .map(|line| crate::config::redact(line))
+            .collect()
+    }
+
+    /// The error of a failed start, with the last startup lines, which often
+    /// say why (a build error, a missing module)
+    pub fn start_failure(&self, error: &str) -> String {
+        let lines = self.startup_lines();
+        if lines.is_empty() {
+            return error.to_string();
+        }
+        let skip = lines.len().saturating_sub(STARTUP_LINES_IN_ERROR);
+        format!(
+            "{}\n{} output:\n{}",
+            error,
+            STARTUP_OUTPUT_CATEGORY,
+            lines[skip..].join("\n")
+        )
+    }
+
+    /// Record a process event; a pid already known is reported only once
+    pub fn add_process(&mut self, process: DebuggeeProcess) {
+        if process.pid.is_some() && self.processes.iter().any(|p| p.pid == process.pid) {
+            return;
+        }
+        self.record_event("process", serde_json::json!(process));
+        self.processes.push(process);
+    }
+
+    /// Record a `memory` event; readers polling `events_seq` see it as a
+    /// change, so values they read earlier can be fetched again
+    pub fn record_memory_change(&mut self, memory_reference: String, offset: i64, count: i64) {
+        let change = MemoryChange {
+            memory_reference,
+            offset,
+            count,
+            events_seq: self.events_seq + 1,
+        };
+        self.record_event("memory", serde_json::json!(change));
+        self.memory_changes.push_back(change);
+        while self.memory_changes.len() > MAX_MEMORY_CHANGES {
+            self.memory_changes.pop_front();
+        }
+    }
+
+    /// The debuggee's pid, the first time it is known: sampling its
+    /// resource usage starts now
+    pub fn start_resource_sampling(&mut self) -> Option<u32> {
+        if self.resource_usage.is_some() {
+            return None;
+        }
+        let pid = u32::try_from(self.processes.first()?.pid?).ok()?;
+        self.resource_usage = Some(ResourceUsage::new(pid));
+        Some(pid)
+    }
+
+    /// The debuggee's current and peak usage, frozen while it is stopped
+    pub fn resource_summary(&self) -> Option<ResourceSummary> {
+        let stopped = matches!(self.state, DebugState::Stopped { .. });
+        self.resource_usage
+            .as_ref()
+            .map(|usage| usage.summary(stopped))
+    }
+
+    /// Followed child processes (every process after the debuggee)
+    pub fn child_processes(&self) -> &[DebuggeeProcess] {
+        self.processes.get(1..).unwrap_or_default()
+    }
+
+    pub fn remove_thread(&mut self, thread_id: i32) {
+        self.threads.retain(|t| *t != thread_id);
+        self.thread_states.remove(&thread_id);
+    }
+
+    /// Record a stop: only the named thread unless the adapter stopped all threads
+    pub fn apply_stopped(&mut self, thread_id: i32, reason: String, all_threads_stopped: bool) {
+        self.add_thread(thread_id);
+
+        if all_threads_stopped {
+            for tid in &self.threads {
+                self.thread_states.insert(
+                    *tid,
+                    ThreadState::Stopped {
+                        reason: reason.clone(),
+                    },
+                );
+            }
+        } else {
+            self.thread_states.insert(
+                thread_id,
+                ThreadState::Stopped {
+                    reason: reason.clone(),
+                },
+            );
+        }
+
+        self.stopped_at = Some(Instant::now());
+        self.user_stop_pending = false;
+        self.watch_stop = None;
+        self.caller_stop = None;
+        self.caller_skips = 0;
+        self.set_state(DebugState::Stopped { thread_id, reason });
+    }
+
+    /// Count a breakpoint stop as a hit of `hit_ids`, or, when the adapter
+    /// named none, of the breakpoints at the stop's `location`
+    pub fn count_breakpoint_hits(&mut self, hit_ids: &[i32], location: Option<(&str, i32)>) {
+        let at_ms = self.transcript.elapsed_ms();
+        let ids: Vec<i32> = if hit_ids.is_empty() {
+            self.breakpoints_at_stop(hit_ids, location)
+                .iter()
+                .filter_map(|bp| bp.id)
+                .collect()
+        } else {
+            hit_ids.to_vec()
+        };
+        self.breakpoint_hits.record(&ids, at_ms);
+    }
+
+    /// Count a hit of the breakpoint at `location` (or `hit_ids`) that
+    /// the server continued because its condition was false
+    pub fn count_condition_failure(&mut self, hit_ids: &[i32], location: (&str, i32)) {
+        let ids: Vec<i32> = self
+            .breakpoints_at_stop(hit_ids, Some(location))
+            .iter()
+            .filter_map(|bp| bp.id)
+            .collect();
+        for id in ids {
+            self.breakpoint_hits.record_condition_failure(id);
+        }
+    }
+
+    /// Hit statistics of every breakpoint, busiest first
+    pub fn summarize_breakpoints(&self) -> BreakpointSummary {
+        let server_condition = |bp: &Breakpoint| {
+            bp.caller.is_some()
+                || self.change_watches.iter().any(|watch| {
+                    watch.strategy == WatchStrategy::ServerLoop
+                        && watch.source_path == bp.source_path
+                        && watch.line == bp.line
+                })
+        };
+        let mut entries: Vec<BreakpointSummaryEntry> = Vec::new();
+        for bp in self.breakpoints.values().flatten() {
+            let Some(id) = bp.id else { continue };
+            entries.push(BreakpointSummaryEntry {
+                id,
+                source_path: Some(bp.source_path.clone()),
+                line: Some(bp.effective_line()),
+                condition: bp.condition.clone(),
+                log_message: bp.log_message.clone(),
+                server_condition: server_condition(bp),
+                ..Default::default()
+            });
+        }
+        for bp in &self.function_breakpoints {
+            let Some(id) = bp.id else { continue };
+            entries.push(BreakpointSummaryEntry {
+                id,
+                function: Some(bp.name.clone()),
+                condition: bp.condition.clone(),
+                ..Default::default()
+            });
+        }
+        for bp in &self.instruction_breakpoints {
+            let Some(id) = bp.id else { continue };
+            entries.push(BreakpointSummaryEntry {
+                id,
+                instruction_reference: Some(bp.instruction_reference.clone()),
+                condition: bp.condition.clone(),
+                ..Default::default()
+            });
+        }
+        self.breakpoint_hits
+            .summarize(entries, self.transcript.elapsed_ms())
+    }
+
+    /// Breakpoints that can stop the program: verified and enabled, and not
+    /// logpoints or stale instruction breakpoints
+    fn verified_breakpoint_count(&self) -> usize {
+        let lines = self
+            .breakpoints
+            .values()
+            .flatten()
+            .filter(|bp| bp.verified && bp.enabled && bp.log_message.is_none())
+            .count();
+        let functions = self.function_breakpoints.iter().filter(|bp| bp.verified);
+        let instructions = self
+            .instruction_breakpoints
+            .iter()
+            .filter(|bp| bp.verified && !bp.stale);
+        lines + functions.count() + instructions.count()
+    }
+
+    /// The post-mortem of a program ending in `state`, Terminated or Failed
+    fn take_post_mortem(&self, state: &DebugState) -> PostMortem {
+        let error = match state {
+            DebugState::Failed { error } => Some(error.clone()),
+            _ => None,
+        };
+        let output = self
+            .output_tail
+            .lines()
+            .iter()
+            .map(|line| crate::config::redact(line))
+            .collect();
+        let mut post_mortem = PostMortem::new(
+            self.transcript.started_at_ms + self.transcript.elapsed_ms(),
+            error,
+            self.transcript.exit_code,
+            self.runtime_ms(),
+            self.crash_report.as_ref(),
+            output,
+        );
+        post_mortem.exit_diagnosis = self.exit_diagnosis.clone();
+        post_mortem
+    }
+
+    /// Milliseconds since the program was last launched (or attached to), or
+    /// since the session was created before that
+    fn runtime_ms(&self) -> u64 {
+        let launched = self.transcript.launches.last().map_or(0, |l| l.at_ms);
+        self.transcript.elapsed_ms().saturating_sub(launched)
+    }
+
+    /// Whether a breakpoint stop is continued at once: it hit unfollowed
+    /// breakpoints only, and no step or pause is waiting for a stop
+    pub fn skips_stop(&self, hit_ids: &[i32]) -> bool {
+        self.auto_continue_unsubscribed
+            && !self.user_stop_pending
+            && self.subscription.ignores_stop(hit_ids)
+    }
+
+    /// Whether a breakpoint stop is continued for `debugger_continue_past`:
+    /// its breakpoint alone was hit, short of the hit to stop at, and no step
+    /// or pause is waiting for a stop
+    pub fn skips_past(&mut self, hit_ids: &[i32]) -> bool {
+        if self.user_stop_pending {
+            return false;
+        }
+        self.continue_past
+            .as_mut()
+            .is_some_and(|past| past.skips(hit_ids))
+    }
+
+    /// The caller constraints of a breakpoint stop, by breakpoint id, when
+    /// every breakpoint it hit has one and no step or pause is waiting for a
+    /// stop; None when the stop is to be reported as it is
+    pub fn caller_constraints(&self, hit_ids: &[i32]) -> Option<Vec<(i32, CallerConstraint)>> {
+        if self.user_stop_pending || hit_ids.is_empty() {
+            return None;
+        }
+        let hit = self.breakpoints_at_stop(hit_ids, None);
+        let all_known = hit_ids
+            .iter()
+            .all(|id| hit.iter().any(|bp| bp.id == Some(*id)));
+        if !all_known {
+            return None;
+        }
+        hit.into_iter()
+            .map(|bp| Some((bp.id?, bp.caller.clone()?)))
+            .collect()
+    }
+
+    /// POST the last stop to the session's webhook, if it has one (call
+    /// after `set_hit_breakpoints`)
+    pub fn notify_stop(&self, thread_id: i32, reason: &str, all_threads_stopped: bool) {
+        if let Some(webhook) = &self.webhook {
+            webhook.send(
+                "stopped",
+                serde_json::json!({
+                    "threadId": thread_id,
+                    "reason": reason,
+                    "allThreadsStopped": all_threads_stopped,
+                    "hitBreakpointIds": self.hit_breakpoint_ids,
+                }),
+            );
+        }
+    }
+
+    /// Record that this session relaunched `relaunch.previous_session_id`
+    /// (the `relaunched` event): readers polling `events_seq` see it, and
+    /// the webhook, if any, is sent it
+    pub fn record_relaunch(&mut self, mut status: WatchStatus, relaunch: Relaunch) {
+        self.record_event("relaunched", serde_json::json!(relaunch));
+        if let Some(webhook) = &self.webhook {
+            webhook.send("relaunched", serde_json::json!(relaunch));
+        }
+        status.relaunches += 1;
+        status.last_relaunch = Some(relaunch);
+        status.error = None;
+        self.file_watch = Some(status);
+    }
+
+    /// The adapter reported the program's end (its `terminated` or `exited`
+    /// event): the session is Terminated, and diagnosed if the program ran
+    /// past every breakpoint
+    ///
+    /// Only the first end is diagnosed, and not one the user's disconnect
+    /// caused.
+    pub fn end_program(&mut self) {
+        let ended = matches!(
+            self.state,
+            DebugState::Terminated | DebugState::Failed { .. }
+        );
+        self.set_state(DebugState::Terminated);
+        if ended || self.disconnecting {
+            return;
+        }
+        if let Some(summary) = &self.breakpoint_summary {
+            self.exit_diagnosis = ExitDiagnosis::diagnose(
+                summary,
+                self.verified_breakpoint_count(),
+                self.runtime_ms(),
+                self.transcript.exit_code,
+            );
+        }
+        if let Some(post_mortem) = &mut self.post_mortem {
+            post_mortem.exit_diagnosis = self.exit_diagnosis.clone();
+        }
+    }
+
+    /// Record the exit code of the adapter's `exited` event
+    pub fn record_exit(&mut self, exit_code: i64) {
+        self.transcript.record_exit(exit_code);
+        if let Some(diagnosis) = &mut self.exit_diagnosis {
+            diagnosis.set_exit_code(Some(exit_code));
+        }
+        if let Some(post_mortem) = &mut self.post_mortem {
+            post_mortem.exit_code = Some(exit_code);
+            post_mortem.exit_diagnosis = self.exit_diagnosis.clone();
+        }
+    }
+
+    /// POST the program's exit to the session's webhook, if it has one,
+    /// with the exit diagnosis when no breakpoint was hit
+    pub fn notify_exit(&self, exit_code: Option<i64>) {
+        if let Some(webhook) = &self.webhook {
+            let mut body = serde_json::json!({ "exitCode": exit_code });
+            if let Some(diagnosis) = &self.exit_diagnosis {
+                body["diagnosis"] = serde_json::json!(diagnosis);
+            }
+            webhook.send("exited", body);
+        }
+    }
+
+    /// Add the last stop to the transcript, located at the breakpoint that
+    /// caused it if there is one (call after `set_hit_breakpoints`)
+    pub fn record_stop(&mut self, thread_id: i32, reason: &str) {
+        let location = self
+            .hit_breakpoints()
+            .0
+            .first()
+            .map(|bp| (bp.source_path.clone(), bp.effective_line()));
+        let ids = self.hit_breakpoint_ids.clone();
+        self.transcript
+            .record_stop(thread_id, reason, ids, location);
+    }
+
+    /// Give frames' sources the caller's spelling of their paths (see
+    /// `path_aliases`)
+    pub fn alias_paths(&self, frames: &mut [StackFrame]) {
+        if self.path_aliases.is_empty() {
+            return;
+        }
+        for source in frames.iter_mut().filter_map(|f| f.source.as_mut()) {
+            if let Some(alias) = source.path.as_ref().and_then(|p| self.path_aliases.get(p)) {
+                source.path = Some(alias.clone());
+            }
+        }
+    }
+
+    /// Record which breakpoints caused the last stop
+    pub fn set_hit_breakpoints(&mut self, ids: Vec<i32>) {
+        self.hit_breakpoint_ids = ids;
+    }
+
+    /// Whether any enabled breakpoint is a logpoint
+    pub fn has_log_points(&self) -> bool {
+        self.breakpoints
+            .values()
+            .flatten()
+            .any(|bp| bp.enabled && bp.log_message.is_some())
+    }
+
+    /// Logpoints a breakpoint stop was for, when it was for logpoints only
+    ///
+    /// The stop is matched by `hit_ids` when the adapter reports them, and
+    /// otherwise by the top frame's `location` (path, line). Empty if any
+    /// breakpoint there is an ordinary one: then the stop is real.
+    pub fn log_points_hit(
+        &self,
+        hit_ids: &[i32],
+        location: Option<(&str, i32)>,
+    ) -> Vec<Breakpoint> {
+        let hit = self.breakpoints_at_stop(hit_ids, location);
+        if hit.iter().all(|bp| bp.log_message.is_some()) {
+            hit.into_iter().cloned().collect()
+        } else {
+            Vec::new()
+        }
+    }
+
+    /// Enabled breakpoints a stop was for, by `hit_ids` when the adapter
+    /// reports them and otherwise by the top frame's `location`
+    fn breakpoints_at_stop(
+        &self,
+        hit_ids: &[i32],
+        location: Option<(&str, i32)>,
+    ) -> Vec<&Breakpoint> {
+        let enabled = self.breakpoints.values().flatten().filter(|bp| bp.enabled);
+        if hit_ids.is_empty() {
+            let Some((path, line)) = location else {
+                return Vec::new();
+            };
+            enabled
+                .filter(|bp| bp.source_path == path && bp.is_at(line))
+                .collect()
+        } else {
+            enabled
+                .filter(|bp| bp.id.is_some_and(|id| hit_ids.contains(&id)))
+                .collect()
+        }
+    }
+
+    /// Ids of the breakpoints an entry stop is also at, which continuing
+    /// from it would pass without stopping (logpoints don't stop anyway)
+    pub fn breakpoints_at_entry(&self, hit_ids: &[i32], location: Option<(&str, i32)>) -> Vec<i32> {
+        self.breakpoints_at_stop(hit_ids, location)
+            .into_iter()
+            .filter(|bp| bp.log_message.is_none())
+            .filter_map(|bp| bp.id)
+            .collect()
+    }
+
+    /// Whether the last stop was for breakpoint `id`, by the adapter's hit ids
+    /// or, when it reports none, by the top frame's `location`
+    pub fn stop_was_for(&self, id: i32, location: Option<(&str, i32)>) -> bool {
+        if self.hit_breakpoint_ids.contains(&id) {
+            return true;
+        }
+        self.hit_breakpoint_ids.is_empty()
+            && self
+                .breakpoints_at_stop(&[], location)
+                .iter()
+                .any(|bp| bp.id == Some(id))
+    }
+
+    /// The change watch a breakpoint stop was for, and whether the stop was
+    /// for its breakpoint only (matched like `log_points_hit`)
+    pub fn change_watch_hit(
+        &self,
+        hit_ids: &[i32],
+        location: Option<(&str, i32)>,
+    ) -> Option<(usize, bool)> {
+        let hit = self.breakpoints_at_stop(hit_ids, location);
+        let watches = |watch: &ChangeWatch, bp: &Breakpoint| {
+            bp.source_path == watch.source_path && bp.line == watch.line
+        };
+        let index = self
+            .change_watches
+            .iter()
+            .position(|watch| hit.iter().any(|bp| watches(watch, bp)))?;
+        let watch = &self.change_watches[index];
+        Some((index, hit.iter().all(|bp| watches(watch, bp))))
+    }
+
+    pub fn has_temporary_breakpoints(&self) -> bool {
+        self.breakpoints.values().flatten().any(|bp| bp.temporary)
+    }
+
+    /// Remove the temporary breakpoints a stop was for, returning them
+    ///
+    /// Matched like `log_points_hit`. The adapter only stops once a
+    /// breakpoint's condition holds, so a conditional temporary breakpoint
+    /// survives every hit that doesn't satisfy it.
+    pub fn remove_temporary_breakpoints_hit(
+        &mut self,
+        hit_ids: &[i32],
+        location: Option<(&str, i32)>,
+    ) -> Vec<Breakpoint> {
+        let fired: Vec<Breakpoint> = self
+            .breakpoints_at_stop(hit_ids, location)
+            .into_iter()
+            .filter(|bp| bp.temporary)
+            .cloned()
+            .collect();
+        for bp in &fired {
+            if let Some(bps) = self.breakpoints.get_mut(&bp.source_path) {
+                bps.retain(|b| b.line != bp.line);
+            }
+        }
+        fired
+    }
+
+    /// Breakpoints that caused the last stop, plus any hit ids that don't
+    /// match a tracked breakpoint (e.g. set by the adapter itself)
+    pub fn hit_breakpoints(&self) -> (Vec<Breakpoint>, Vec<i32>) {
+        let mut hit = Vec::new();
+        let mut unknown = Vec::new();
+        for id in &self.hit_breakpoint_ids {
+            // Breakpoints sharing a line share the id: all of them are reported
+            let matching: Vec<Breakpoint> = self
+                .breakpoints
+                .values()
+                .flatten()
+                .chain(&self.fired_temporary)
+                .filter(|bp| bp.id == Some(*id))
+                .cloned()
+                .collect();
+            if matching.is_empty() {
+                unknown.push(*id);
+            }
+            hit.extend(matching);
+        }
+        (hit, unknown)
+    }
+
+    /// Record a resume: only the named thread unless the adapter resumed all threads
+    ///
+    /// The session stays `Stopped` while any other thread is still stopped.
+    pub fn apply_continued(&mut self, thread_id: i32, all_threads_continued: bool) {
+        self.add_thread(thread_id);
+
+        if all_threads_continued {
+            for tid in &self.threads {
+                self.thread_states.insert(*tid, ThreadState::Running);
+            }
+        } else {
+            self.thread_states.insert(thread_id, ThreadState::Running);
+        }
+
+        let state = match self.first_stopped_thread() {
+            Some((thread_id, reason)) => DebugState::Stopped { thread_id, reason },
+            None => DebugState::Running,
+        };
+        self.set_state(state);
+    }
+
+    /// First thread (in discovery order) that is currently stopped
+    pub fn first_stopped_thread(&self) -> Option<(i32, String)> {
+        self.threads
+            .iter()
+            .find_map(|tid| match self.thread_states.get(tid) {
+                Some(ThreadState::Stopped { reason }) => Some((*tid, reason.clone())),
+                _ => None,
+            })
+    }
+
+    /// Fails with `ThreadRunning` if the thread is known to be running
+    pub fn check_thread_stopped(&self, thread_id: i32) -> Result<()> {
+        match self.thread_states.get(&thread_id) {
+            Some(ThreadState::Running) => Err(Error::ThreadRunning(thread_id)),
+            _ => Ok(()),
+        }
+    }
 }
 
 #[cfg(test)]
@@ -119,6 +1319,116 @@ mod tests {
         assert!(bps[0].verified);
     }
 
+    #[test]
+    fn test_instruction_breakpoints_invalidated_on_rebuild() {
+        let built = SystemTime::UNIX_EPOCH + std::time::Duration::from_secs(1000);
+        let rebuilt = built + std::time::Duration::from_secs(60);
+        let instruction = |reference: &str, offset: Option<i64>, modified| InstructionBreakpoint {
+            instruction_reference: reference.to_string(),
+            offset,
+            condition: None,
+            hit_condition: None,
+            id: None,
+            verified: false,
+            message: None,
+            program_modified: modified,
+            stale: false,
+        };
+
+        let mut state = SessionState::new();
+        state.insert_instruction_breakpoint(instruction("0x4a1f20", Some(8), Some(built)));
+        state.insert_instruction_breakpoint(instruction("0x4a2000", None, None));
+        // Same address (offset 0 == no offset) replaces the earlier entry
+        state.insert_instruction_breakpoint(instruction("0x4a2000", Some(0), None));
+        assert_eq!(state.instruction_breakpoints.len(), 2);
+
+        state.update_instruction_breakpoints(vec![(Some(7), true, None), (Some(8), true, None)]);
+        assert!(state.instruction_breakpoints.iter().all(|bp| bp.verified));
+
+        // Unchanged program: everything stays armed
+        assert_eq!(state.invalidate_instruction_breakpoints(Some(built)), 0);
+        assert_eq!(state.armed_instruction_breakpoints().len(), 2);
+
+        // Rebuilt program: the dated breakpoint goes stale, the undated one stays
+        assert_eq!(state.invalidate_instruction_breakpoints(Some(rebuilt)), 1);
+        let stale = state
+            .find_instruction_breakpoint("0x4a1f20", Some(8))
+            .unwrap();
+        assert!(stale.stale && !stale.verified && stale.message.is_some());
+        let armed = state.armed_instruction_breakpoints();
+        assert_eq!(armed.len(), 1);
+        assert_eq!(armed[0].id, Some(8));
+
+        // Results only apply to armed breakpoints
+        state.update_instruction_breakpoints(vec![(Some(9), false, Some("bad".to_string()))]);
+        assert_eq!(
+            state
+                .find_instruction_breakpoint("0x4a1f20", Some(8))
+                .unwrap()
+                .id,
+            None
+        );
+        assert_eq!(
+            state
+                .find_instruction_breakpoint("0x4a2000", None)
+                .unwrap()
+                .id,
+            Some(9)
+        );
+
+        assert!(state.remove_instruction_breakpoint("0x4a1f20", Some(8)));
+        assert!(!state.remove_instruction_breakpoint("0x4a1f20", Some(8)));
+    }
+
+    #[test]
+    fn test_breakpoint_enabled_by_default() {
+        let mut state = SessionState::new();
+        state.add_breakpoint("test.py".to_string(), 10);
+
+        assert!(state.get_breakpoints("test.py")[0].enabled);
+        assert_eq!(state.get_enabled_breakpoints("test.py").len(), 1);
+    }
+
+    #[test]
+    fn test_set_breakpoint_enabled() {
+        let mut state = SessionState::new();
+        state.add_breakpoint("test.py".to_string(), 10);
+        state.add_breakpoint("test.py".to_string(), 20);
+        state.update_breakpoint("test.py", 10, 1, true);
+        state.update_breakpoint("test.py", 20, 2, true);
+
+        let source = state.set_breakpoint_enabled(1, false);
+        assert_eq!(source.as_deref(), Some("test.py"));
+
+        // Disabled breakpoint is kept but not sent to the adapter
+        assert_eq!(state.get_breakpoints("test.py").len(), 2);
+        let enabled = state.get_enabled_breakpoints("test.py");
+        assert_eq!(enabled.len(), 1);
+        assert_eq!(enabled[0].line, 20);
+
+        state.set_breakpoint_enabled(1, true);
+        assert_eq!(state.get_enabled_breakpoints("test.py").len(), 2);
+
+        assert!(state.set_breakpoint_enabled(99, false).is_none());
+    }
+
+    #[test]
+    fn test_followed_child_processes() {
+        let process = |name: &str, pid: i64| DebuggeeProcess {
+            name: name.to_string(),
+            pid: Some(pid),
+            start_method: Some("launch".to_string()),
+        };
+        let mut state = SessionState::new();
+        state.add_process(process("parent", 100));
+        assert!(state.child_processes().is_empty());
+
+        state.add_process(process("helper", 101));
+        state.add_process(process("helper", 101));
+        assert_eq!(state.child_processes(), &[process("helper", 101)]);
+        assert_eq!(state.events_seq, 2);
+    }
+
     #[test]
     fn test_add_thread() {
         let mut state = SessionState::new();
@@ -131,6 +1441,282 @@ mod tests {
         assert!(state.threads.contains(&2));
     }
 
+    #[test]
+    fn test_apply_stopped_single_thread() {
+        let mut state = SessionState::new();
+        state.apply_continued(1, true);
+        state.apply_continued(2, true);
+
+        state.apply_stopped(2, "breakpoint".to_string(), false);
+
+        assert_eq!(state.thread_states[&1], ThreadState::Running);
+        assert_eq!(
+            state.thread_states[&2],
+            ThreadState::Stopped {
+                reason: "breakpoint".to_string()
+            }
+        );
+        assert!(matches!(
+            state.state,
+            DebugState::Stopped { thread_id: 2, .. }
+        ));
+        assert!(matches!(
+            state.check_thread_stopped(1),
+            Err(Error::ThreadRunning(1))
+        ));
+        assert!(state.check_thread_stopped(2).is_ok());
+    }
+
+    #[test]
+    fn test_apply_stopped_all_threads() {
+        let mut state = SessionState::new();
+        state.add_thread(1);
+        state.add_thread(2);
+
+        state.apply_stopped(1, "pause".to_string(), true);
+
+        assert!(state.check_thread_stopped(1).is_ok());
+        assert!(state.check_thread_stopped(2).is_ok());
+        assert!(matches!(
+            state.thread_states[&2],
+            ThreadState::Stopped { .. }
+        ));
+    }
+
+    #[test]
+    fn test_hit_breakpoints() {
+        let mut state = SessionState::new();
+        state.add_breakpoint("a.py".to_string(), 3);
+        state.add_breakpoint("a.py".to_string(), 7);
+        state.update_breakpoint("a.py", 3, 1, true);
+        state.update_breakpoint("a.py", 7, 2, true);
+
+        state.set_hit_breakpoints(vec![2, 9]);
+        let (hit, unknown) = state.hit_breakpoints();
+        assert_eq!(hit.len(), 1);
+        assert_eq!(hit[0].line, 7);
+        assert_eq!(unknown, vec![9]);
+
+        state.set_hit_breakpoints(Vec::new());
+        assert!(state.hit_breakpoints().0.is_empty());
+    }
+
+    #[test]
+    fn test_deferred_breakpoint_bound_by_event() {
+        let mut state = SessionState::new();
+        state.add_breakpoint("FizzBuzz.java".to_string(), 12);
+        state.update_breakpoint("FizzBuzz.java", 12, 3, false);
+
+        assert!(state.apply_breakpoint_change(3, true, Some(12)));
+        let bp = &state.get_breakpoints("FizzBuzz.java")[0];
+        assert!(bp.verified);
+        assert_eq!(bp.verified_line, None);
+
+        assert!(state.apply_breakpoint_change(3, true, Some(13)));
+        assert_eq!(
+            state.get_breakpoints("FizzBuzz.java")[0].verified_line,
+            Some(13)
+        );
+        assert!(!state.apply_breakpoint_change(8, true, None));
+    }
+
+    #[test]
+    fn test_breakpoints_sharing_a_line() {
+        let mut state = SessionState::new();
+        for condition in ["x > 3", "y == 0", "x > 3"] {
+            state.insert_shared_breakpoint(Breakpoint {
+                source_path: "a.py".to_string(),
+                line: 7,
+                id: None,
+                verified: false,
+                enabled: true,
+                condition: Some(condition.to_string()),
+                hit_condition: None,
+                log_message: None,
+                temporary: false,
+                verified_line: None,
+                move_explanation: None,
+                caller: None,
+            });
+        }
+        assert_eq!(state.get_breakpoints("a.py").len(), 2);
+
+        state.update_breakpoint("a.py", 7, 4, true);
+        state.set_hit_breakpoints(vec![4]);
+        assert_eq!(state.hit_breakpoints().0.len(), 2);
+        state.set_breakpoint_enabled(4, false);
+        assert!(state.get_enabled_breakpoints("a.py").is_empty());
+
+        assert_eq!(state.remove_breakpoint("a.py", 7, Some("x > 3")), 1);
+        let left = state.get_breakpoints("a.py");
+        assert_eq!(left.len(), 1);
+        assert_eq!(left[0].condition.as_deref(), Some("y == 0"));
+        assert_eq!(state.remove_breakpoint("a.py", 7, Some("x > 3")), 0);
+        assert_eq!(state.remove_breakpoint("a.py", 7, None), 1);
+    }
+
+    #[test]
+    fn test_stop_was_for() {
+        let mut state = SessionState::new();
+        state.add_breakpoint("a.go".to_string(), 3);
+        state.add_breakpoint("a.go".to_string(), 9);
+        state.update_breakpoint("a.go", 3, 1, true);
+        state.update_breakpoint("a.go", 9, 2, true);
+
+        state.set_hit_breakpoints(vec![2]);
+        assert!(state.stop_was_for(2, None));
+        assert!(!state.stop_was_for(1, Some(("a.go", 3))));
+
+        // No hit ids: matched by where the program stopped
+        state.set_hit_breakpoints(Vec::new());
+        assert!(state.stop_was_for(1, Some(("a.go", 3))));
+        assert!(!state.stop_was_for(1, Some(("a.go", 9))));
+        assert!(!state.stop_was_for(1, None));
+    }
+
+    #[test]
+    fn test_log_points_hit() {
+        let mut state = SessionState::new();
+        state.add_breakpoint("a.go".to_string(), 3);
+        state.insert_breakpoint(Breakpoint {
+            source_path: "a.go".to_string(),
+            line: 7,
+            id: Some(2),
+            verified: true,
+            enabled: true,
+            condition: None,
+            hit_condition: None,
+            log_message: Some("x={x}".to_string()),
+            temporary: false,
+            verified_line: None,
+            move_explanation: None,
+            caller: None,
+        });
+        state.update_breakpoint("a.go", 3, 1, true);
+        assert!(state.has_log_points());
+
+        assert_eq!(state.log_points_hit(&[2], None).len(), 1);
+        assert_eq!(state.log_points_hit(&[], Some(("a.go", 7))).len(), 1);
+        // An ordinary breakpoint hit at the same time makes the stop real
+        assert!(state.log_points_hit(&[1, 2], None).is_empty());
+        assert!(state.log_points_hit(&[], Some(("a.go", 3))).is_empty());
+        assert!(state.log_points_hit(&[], None).is_empty());
+    }
+
+    #[test]
+    fn test_moved_breakpoint_known_by_both_lines() {
+        let mut state = SessionState::new();
+        state.add_breakpoint("fizzbuzz.go".to_string(), 13);
+        state.update_breakpoint("fizzbuzz.go", 13, 1, true);
+        state.record_breakpoint_move(
+            "fizzbuzz.go",
+            13,
+            Some((14, "line 13 is not a statement (if header)".to_string())),
+        );
+
+        let bp = &state.get_breakpoints("fizzbuzz.go")[0];
+        assert!(bp.is_at(13) && bp.is_at(14));
+        assert_eq!(bp.effective_line(), 14);
+        assert_eq!(
+            state
+                .breakpoints_at_stop(&[], Some(("fizzbuzz.go", 14)))
+                .len(),
+            1
+        );
+
+        // A breakpoint on the verified line replaces it
+        state.add_breakpoint("fizzbuzz.go".to_string(), 14);
+        let lines: Vec<i32> = state
+            .get_breakpoints("fizzbuzz.go")
+            .iter()
+            .map(|bp| bp.line)
+            .collect();
+        assert_eq!(lines, vec![14]);
+
+        // A later answer without a move clears it
+        state.record_breakpoint_move("fizzbuzz.go", 14, None);
+        assert_eq!(state.get_breakpoints("fizzbuzz.go")[0].verified_line, None);
+    }
+
+    #[test]
+    fn test_temporary_breakpoints_removed_when_hit() {
+        let mut state = SessionState::new();
+        state.add_breakpoint("a.go".to_string(), 3);
+        state.update_breakpoint("a.go", 3, 1, true);
+        state.insert_breakpoint(Breakpoint {
+            source_path: "a.go".to_string(),
+            line: 7,
+            id: Some(2),
+            verified: true,
+            enabled: true,
+            condition: Some("i == 5".to_string()),
+            hit_condition: None,
+            log_message: None,
+            temporary: true,
+            verified_line: None,
+            move_explanation: None,
+            caller: None,
+        });
+        assert!(state.has_temporary_breakpoints());
+
+        // A stop at the ordinary breakpoint leaves the temporary one alone
+        assert!(state
+            .remove_temporary_breakpoints_hit(&[1], None)
+            .is_empty());
+        assert!(state
+            .remove_temporary_breakpoints_hit(&[], Some(("a.go", 3)))
+            .is_empty());
+
+        let fired = state.remove_temporary_breakpoints_hit(&[2], None);
+        assert_eq!(fired.len(), 1);
+        assert_eq!(fired[0].line, 7);
+        assert_eq!(state.get_breakpoints("a.go").len(), 1);
+        assert!(!state.has_temporary_breakpoints());
+
+        // Still reported as the breakpoint that caused the stop
+        state.fired_temporary = fired;
+        state.set_hit_breakpoints(vec![2]);
+        let (hit, unknown) = state.hit_breakpoints();
+        assert_eq!(hit[0].line, 7);
+        assert!(unknown.is_empty());
+    }
+
+    #[test]
+    fn test_apply_continued_keeps_other_stopped_threads() {
+        let mut state = SessionState::new();
+        state.add_thread(1);
+        state.add_thread(2);
+        state.apply_stopped(1, "breakpoint".to_string(), true);
+
+        // Only thread 1 resumes: session is still stopped on thread 2
+        state.apply_continued(1, false);
+        assert_eq!(state.thread_states[&1], ThreadState::Running);
+        assert!(matches!(
+            state.state,
+            DebugState::Stopped { thread_id: 2, .. }
+        ));
+
+        state.apply_continued(2, true);
+        assert_eq!(state.state, DebugState::Running);
+        assert!(state.first_stopped_thread().is_none());
+    }
+
+    #[test]
+    fn test_unknown_thread_is_not_reported_running() {
+        let state = SessionState::new();
+        assert!(state.check_thread_stopped(42).is_ok());
+    }
+
+    #[test]
+    fn test_remove_thread() {
+        let mut state = SessionState::new();
+        state.apply_stopped(3, "step".to_string(), false);
+        state.remove_thread(3);
+
+        assert!(state.threads.is_empty());
+        assert!(state.thread_states.is_empty());
+    }
+
     #[test]
     fn test_get_breakpoints_empty() {
         let state = SessionState::new();
@@ -152,4 +1738,239 @@ mod tests {
             panic!("Expected Stopped state");
         }
     }
+
+    #[test]
+    fn test_poll_delay_backs_off_until_state_changes() {
+        let mut state = SessionState::new();
+        state.set_state(DebugState::Running);
+
+        let delays: Vec<u64> = (0..7).map(|_| state.next_poll_delay()).collect();
+        assert_eq!(delays, vec![100, 200, 400, 800, 1600, 2000, 2000]);
+
+        state.apply_stopped(1, "breakpoint".to_string(), true);
+        state.apply_continued(1, true);
+        assert_eq!(state.next_poll_delay(), MIN_POLL_DELAY_MS);
+    }
+
+    #[test]
+    fn test_state_changes_advance_events_seq() {
+        let mut state = SessionState::new();
+        let mut rx = state.subscribe();
+        assert_eq!(state.events_seq, 0);
+
+        state.set_state(DebugState::Running);
+        state.apply_stopped(2, "pause".to_string(), false);
+        assert_eq!(state.events_seq, 2);
+        assert!(rx.has_changed().unwrap());
+        assert_eq!(*rx.borrow_and_update(), 2);
+
+        let (events, missed) = state.events.since(1, state.events_seq);
+        assert_eq!(missed, 0);
+        assert_eq!(events.len(), 1);
+        assert_eq!(events[0].kind, "state");
+        assert_eq!(events[0].body["reason"], "pause");
+    }
+
+    #[test]
+    fn test_unsubscribed_stops_skipped_unless_stepping() {
+        let mut state = SessionState::new();
+        state.subscription.unsubscribe(Some(&[4]));
+        assert!(!state.skips_stop(&[4]));
+
+        state.auto_continue_unsubscribed = true;
+        assert!(state.skips_stop(&[4]));
+        assert!(!state.skips_stop(&[4, 5]));
+        assert!(!state.skips_stop(&[]));
+
+        // A step ending on the breakpoint is the step's stop
+        state.user_stop_pending = true;
+        assert!(!state.skips_stop(&[4]));
+        state.apply_stopped(1, "breakpoint".to_string(), true);
+        assert!(!state.user_stop_pending);
+        assert!(state.skips_stop(&[4]));
+
+        state.count_breakpoint_hits(&[4], None);
+        state.count_breakpoint_hits(&[4, 5], None);
+        assert_eq!(state.breakpoint_hits.count(4), 2);
+        assert_eq!(state.breakpoint_hits.count(5), 1);
+    }
+
+    #[test]
+    fn test_breakpoint_summary_at_termination() {
+        let mut state = SessionState::new();
+        state.add_breakpoint("a.go".to_string(), 3);
+        state.add_breakpoint("a.go".to_string(), 9);
+        state.update_breakpoint("a.go", 3, 1, true);
+        state.update_breakpoint("a.go", 9, 2, true);
+        state.count_breakpoint_hits(&[2], None);
+        state.count_breakpoint_hits(&[2], None);
+        state.count_condition_failure(&[], ("a.go", 9));
+
+        state.set_state(DebugState::Running);
+        assert!(state.breakpoint_summary.is_none());
+        state.set_state(DebugState::Terminated);
+        let summary = state.breakpoint_summary.clone().unwrap();
+        assert_eq!(summary.total_hits, 2);
+        assert_eq!(summary.hot_path, vec![2]);
+        assert_eq!(summary.breakpoints[0].line, Some(9));
+        // Not a server-evaluated condition: failures unknown
+        assert_eq!(summary.breakpoints[0].condition_failures, None);
+        assert_eq!(summary.breakpoints[1].hits, 0);
+
+        // Taken once: later states don't replace it
+        state.count_breakpoint_hits(&[1], None);
+        state.set_state(DebugState::Failed {
+            error: "gone".to_string(),
+        });
+        assert_eq!(state.breakpoint_summary, Some(summary));
+        assert_eq!(state.exit_diagnosis, None);
+    }
+
+    #[test]
+    fn test_exit_before_any_hit_is_diagnosed() {
+        let mut state = SessionState::new();
+        state.add_breakpoint("a.go".to_string(), 3);
+        state.add_breakpoint("a.go".to_string(), 9);
+        state.update_breakpoint("a.go", 3, 1, true);
+        state.update_breakpoint("a.go", 9, 2, false);
+        state.transcript.record_launch("go", &serde_json::json!({}));
+
+        state.end_program();
+        let diagnosis = state.exit_diagnosis.clone().unwrap();
+        assert_eq!(
+            (diagnosis.exit_code, diagnosis.verified_breakpoints),
+            (None, 1)
+        );
+        // The exited event may follow the termination
+        state.record_exit(2);
+        let diagnosis = state.exit_diagnosis.clone().unwrap();
+        assert_eq!(diagnosis.exit_code, Some(2));
+        assert!(diagnosis.message.contains("exit code 2"));
+        assert_eq!(state.transcript.exit_code, Some(2));
+
+        // Adapter failures aren't exits
+        let mut failed = SessionState::new();
+        failed.add_breakpoint("a.go".to_string(), 3);
+        failed.update_breakpoint("a.go", 3, 1, true);
+        failed.set_state(DebugState::Failed {
+            error: "gone".to_string(),
+        });
+        assert_eq!(failed.exit_diagnosis, None);
+        // Nor are ends after one
+        failed.end_program();
+        assert_eq!(failed.exit_diagnosis, None);
+    }
+
+    #[test]
+    fn test_disconnect_and_unattributed_hits_not_diagnosed() {
+        let running = || {
+            let mut state = SessionState::new();
+            state.add_breakpoint("a.go".to_string(), 3);
+            state.update_breakpoint("a.go", 3, 1, true);
+            state.transcript.record_launch("go", &serde_json::json!({}));
+            state.set_state(DebugState::Running);
+            state
+        };
+
+        // The user's disconnect ends the program, set Terminated before or
+        // after the adapter's events
+        let mut state = running();
+        state.disconnecting = true;
+        state.end_program();
+        assert_eq!(state.exit_diagnosis, None);
+        let mut state = running();
+        state.set_state(DebugState::Terminated);
+        state.end_program();
+        assert_eq!(state.exit_diagnosis, None);
+
+        // A breakpoint stop whose adapter named no breakpoint is a hit, of
+        // the breakpoint at its location if there is one
+        let mut state = running();
+        state.count_breakpoint_hits(&[], None);
+        state.end_program();
+        assert_eq!(state.exit_diagnosis, None);
+        let summary = state.breakpoint_summary.unwrap();
+        assert_eq!((summary.total_hits, summary.untracked_hits), (1, 1));
+        let mut state = running();
+        state.count_breakpoint_hits(&[], Some(("a.go", 3)));
+        state.count_breakpoint_hits(&[], Some(("a.go", 4)));
+        assert_eq!(state.breakpoint_hits.count(1), 1);
+        let summary = state.summarize_breakpoints();
+        assert_eq!((summary.total_hits, summary.untracked_hits), (2, 1));
+    }
+
+    #[test]
+    fn test_post_mortem_taken_at_termination() {
+        let mut state = SessionState::new();
+        state.transcript.record_launch("go", &serde_json::json!({}));
+        state.record_output(Some("stdout"), "working\n");
+        state.record_output(Some("stderr"), "panic: runtime error\n");
+        assert!(state.post_mortem.is_none());
+
+        state.set_state(DebugState::Terminated);
+        state.record_exit(2);
+        let post_mortem = state.post_mortem.clone().unwrap();
+        assert_eq!(post_mortem.exit_code, Some(2));
+        assert_eq!(post_mortem.output, vec!["working", "panic: runtime error"]);
+        assert!(post_mortem.exception.is_none() && post_mortem.error.is_none());
+
+        let mut failed = SessionState::new();
+        failed.set_state(DebugState::Failed {
+            error: "adapter gone".to_string(),
+        });
+        let post_mortem = failed.post_mortem.unwrap();
+        assert_eq!(post_mortem.error.as_deref(), Some("adapter gone"));
+    }
+
+    #[test]
+    fn test_output_before_launch_is_startup_output() {
+        let mut state = SessionState::new();
+        state.record_output(Some("console"), "Building ./cmd/app\n");
+        state.record_output(Some("stderr"), "main.go:7: undefined: x\n");
+        state.record_output(Some("telemetry"), "{}\n");
+        assert_eq!(
+            state.startup_lines(),
+            vec!["Building ./cmd/app", "main.go:7: undefined: x"]
+        );
+        assert_eq!(state.output_tail.lines(), vec!["main.go:7: undefined: x"]);
+        assert_eq!(
+            state.start_failure("Initialization failed: launch timed out"),
+            "Initialization failed: launch timed out\nstartup output:\nBuilding ./cmd/app\nmain.go:7: undefined: x"
+        );
+
+        state.launched = true;
+        state.record_output(Some("stdout"), "hello\n");
+        assert_eq!(state.startup_lines().len(), 2);
+        assert_eq!(state.output_tail.lines().len(), 2);
+        assert_eq!(
+            state.output_log.text(),
+            ("main.go:7: undefined: x\nhello\n".to_string(), false)
+        );
+        assert_eq!(SessionState::new().start_failure("boom"), "boom");
+    }
+
+    #[test]
+    fn test_memory_changes_advance_events_seq() {
+        let mut state = SessionState::new();
+        let mut rx = state.subscribe();
+        state.set_state(DebugState::Running);
+
+        state.record_memory_change("0xc000012345".to_string(), 8, 4);
+        assert_eq!(*rx.borrow_and_update(), 2);
+        assert_eq!(
+            state.memory_changes[0],
+            MemoryChange {
+                memory_reference: "0xc000012345".to_string(),
+                offset: 8,
+                count: 4,
+                events_seq: 2,
+            }
+        );
+
+        for i in 0..MAX_MEMORY_CHANGES as i64 {
+            state.record_memory_change("0xc000012345".to_string(), i, 1);
+        }
+        assert_eq!(state.memory_changes.len(), MAX_MEMORY_CHANGES);
+        assert_eq!(state.memory_changes[0].offset, 0);
+    }
 }
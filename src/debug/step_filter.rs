@@ -0,0 +1,119 @@
+//! Step filters: code a step never stops in
+//!
+//! Even with justMyCode, stepping into a line like `fmt.Printf(...)` lands in
+//! the standard library, and Ruby steps descend into gem internals. A step
+//! filter names such code by function name (`fmt.*`) or source path
+//! (`*/gems/*`). When a step stops in a filtered frame, the session keeps
+//! stepping (out while the caller is unfiltered, over otherwise) until it
+//! reaches unfiltered code, giving up after `MAX_AUTO_STEPS` steps.
+
+use crate::dap::types::StackFrame;
+use regex::Regex;
+
+/// Most steps taken automatically to leave filtered code after one step
+pub const MAX_AUTO_STEPS: usize = 20;
+
+/// A set of step filter patterns
+///
+/// `*` matches any run of characters, `/` included. A pattern without `*`
+/// is a prefix: `/usr/lib/go/` filters everything below that directory.
+#[derive(Debug, Clone, Default)]
+pub struct StepFilters {
+    patterns: Vec<String>,
+    regexes: Vec<Regex>,
+}
+
+fn compile(pattern: &str) -> Regex {
+    let body = pattern
+        .split('*')
+        .map(regex::escape)
+        .collect::<Vec<_>>()
+        .join(".*");
+    let anchored = if pattern.contains('*') {
+        format!("^{}$", body)
+    } else {
+        format!("^{}", body)
+    };
+    Regex::new(&anchored).expect("escaped glob is a valid regex")
+}
+
+impl StepFilters {
+    pub fn new<S: AsRef<str>>(patterns: &[S]) -> Self {
+        let patterns: Vec<String> = patterns
+            .iter()
+            .map(|p| p.as_ref().to_string())
+            .filter(|p| !p.is_empty())
+            .collect();
+        let regexes = patterns.iter().map(|p| compile(p)).collect();
+        Self { patterns, regexes }
+    }
+
+    pub fn patterns(&self) -> &[String] {
+        &self.patterns
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.patterns.is_empty()
+    }
+
+    /// Whether a frame's function name or source path matches a filter
+    pub fn matches(&self, frame: &StackFrame) -> bool {
+        let path = frame
+            .source
+            .as_ref()
+            .and_then(|s| s.path.as_deref())
+            .map(|p| p.replace('\\', "/"));
+        self.regexes
+            .iter()
+            .any(|re| re.is_match(&frame.name) || path.as_deref().is_some_and(|p| re.is_match(p)))
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::dap::types::Source;
+
+    fn frame(name: &str, path: Option<&str>) -> StackFrame {
+        StackFrame {
+            id: 1,
+            name: name.to_string(),
+            source: path.map(|p| Source {
+                name: None,
+                path: Some(p.to_string()),
+                source_reference: None,
+                checksums: None,
+            }),
+            line: 1,
+            column: 0,
+            end_line: None,
+            end_column: None,
+            instruction_pointer_reference: None,
+        }
+    }
+
+    #[test]
+    fn test_filters_match_name_or_path() {
+        let filters = StepFilters::new(&["fmt.*", "*/gems/*", "/usr/lib/go/", ""]);
+        assert_eq!(filters.patterns().len(), 3);
+
+        assert!(filters.matches(&frame("fmt.Printf", Some("/w/print.go"))));
+        assert!(filters.matches(&frame("fmt.(*pp).doPrintf", None)));
+        assert!(!filters.matches(&frame("main.main", Some("/w/main.go"))));
+        // `.` is literal, not any character
+        assert!(!filters.matches(&frame("fmtx", None)));
+        assert!(filters.matches(&frame(
+            "JSON::Parser#parse",
+            Some("/usr/lib/ruby/gems/3.2.0/gems/json-2.6/lib/json.rb")
+        )));
+        assert!(filters.matches(&frame("Foo", Some("C:\\ruby\\gems\\x\\foo.rb"))));
+        // Prefix pattern
+        assert!(filters.matches(&frame(
+            "strconv.Itoa",
+            Some("/usr/lib/go/src/strconv/itoa.go")
+        )));
+        assert!(!filters.matches(&frame("main.f", Some("/home/usr/lib/go/main.go"))));
+
+        assert!(StepFilters::new::<&str>(&[]).is_empty());
+    }
+}
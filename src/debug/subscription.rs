@@ -0,0 +1,104 @@
+//! Which breakpoints a client follows
+//!
+//! A busy breakpoint (a logging one in a loop) drowns out the one being
+//! debugged. `debugger_subscribe` and `debugger_unsubscribe` pick the
+//! breakpoints whose stops matter; by default every breakpoint is followed.
+//! With the session's `autoContinueUnsubscribed` setting on, a breakpoint
+//! stop where only unfollowed breakpoints were hit is continued right away
+//! and never reported, which turns those breakpoints into counters: their
+//! hits still show in `debugger_list_breakpoints`.
+//!
+//! Only breakpoint stops the adapter reports `hitBreakpointIds` for can be
+//! matched. Stops while a step or pause is on its way are never continued,
+//! since the step may end on a breakpoint and continuing would run past it.
+
+use serde_json::{json, Value};
+use std::collections::BTreeSet;
+
+/// The breakpoints followed
+#[derive(Debug, Clone, PartialEq)]
+pub struct Subscription {
+    /// Follow every breakpoint but `ids` (else `ids` only)
+    all: bool,
+    ids: BTreeSet<i32>,
+}
+
+impl Default for Subscription {
+    fn default() -> Self {
+        Self {
+            all: true,
+            ids: BTreeSet::new(),
+        }
+    }
+}
+
+impl Subscription {
+    /// Follow breakpoints `ids`, or every breakpoint when None
+    pub fn subscribe(&mut self, ids: Option<&[i32]>) {
+        match ids {
+            None => *self = Self::default(),
+            Some(ids) if self.all => self.ids.retain(|id| !ids.contains(id)),
+            Some(ids) => self.ids.extend(ids),
+        }
+    }
+
+    /// Stop following breakpoints `ids`, or every breakpoint when None
+    pub fn unsubscribe(&mut self, ids: Option<&[i32]>) {
+        match ids {
+            None => {
+                self.all = false;
+                self.ids.clear();
+            }
+            Some(ids) if self.all => self.ids.extend(ids),
+            Some(ids) => self.ids.retain(|id| !ids.contains(id)),
+        }
+    }
+
+    pub fn follows(&self, id: i32) -> bool {
+        self.all != self.ids.contains(&id)
+    }
+
+    /// Whether a breakpoint stop for `hit_ids` hit unfollowed breakpoints
+    /// only (false when the adapter didn't say which were hit)
+    pub fn ignores_stop(&self, hit_ids: &[i32]) -> bool {
+        !hit_ids.is_empty() && !hit_ids.iter().any(|id| self.follows(*id))
+    }
+
+    /// `{"all": true, "except": [ids]}` or `{"all": false, "breakpointIds": [ids]}`
+    pub fn to_json(&self) -> Value {
+        if self.all {
+            json!({"all": true, "except": self.ids})
+        } else {
+            json!({"all": false, "breakpointIds": self.ids})
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_subscribe_and_unsubscribe() {
+        let mut subscription = Subscription::default();
+        assert!(subscription.follows(3));
+
+        subscription.unsubscribe(Some(&[3, 4]));
+        assert!(!subscription.follows(3) && subscription.follows(5));
+        assert!(subscription.ignores_stop(&[3, 4]));
+        assert!(!subscription.ignores_stop(&[3, 5]));
+        assert!(!subscription.ignores_stop(&[]));
+        subscription.subscribe(Some(&[4]));
+        assert_eq!(subscription.to_json(), json!({"all": true, "except": [3]}));
+
+        subscription.unsubscribe(None);
+        subscription.subscribe(Some(&[7]));
+        assert!(subscription.follows(7) && !subscription.follows(3));
+        assert_eq!(
+            subscription.to_json(),
+            json!({"all": false, "breakpointIds": [7]})
+        );
+        subscription.subscribe(None);
+        assert_eq!(subscription, Subscription::default());
+    }
+}
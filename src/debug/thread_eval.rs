@@ -0,0 +1,82 @@
+//! One expression, every stopped thread
+//!
+//! Concurrency bugs show up as threads (goroutines) disagreeing about shared
+//! state. `debugger_evaluate_all_threads` evaluates an expression in the top
+//! frame of each stopped thread and lists the results side by side. A thread
+//! the expression can't be evaluated in (no frames, a name not in scope)
+//! gets its error instead of failing the whole call.
+//!
+//! Threads are evaluated in the adapter's order, up to a bound
+//! (`DEFAULT_MAX_THREADS`, at most `MAX_THREADS`), and each value is cut to
+//! a share of the response size cap so one huge value can't crowd out the
+//! others.
+
+use serde::Serialize;
+
+/// Threads evaluated when the caller doesn't say
+pub const DEFAULT_MAX_THREADS: usize = 32;
+
+/// Most threads one call evaluates
+pub const MAX_THREADS: usize = 256;
+
+/// Values are never cut shorter than this many characters
+const MIN_VALUE_LEN: usize = 64;
+
+/// The expression's value (or error) in one thread's top frame
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ThreadEvaluation {
+    pub thread_id: i32,
+    pub thread_name: String,
+    /// Function of the top frame
+    pub function: Option<String>,
+    pub line: Option<i32>,
+    pub value: Option<String>,
+    pub error: Option<String>,
+    /// Whether `value` was cut
+    #[serde(skip_serializing_if = "std::ops::Not::not")]
+    pub truncated: bool,
+}
+
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ThreadEvaluations {
+    pub expression: String,
+    pub results: Vec<ThreadEvaluation>,
+    /// Threads still running, which can't be evaluated in
+    pub running_threads: Vec<i32>,
+    /// Stopped threads beyond the bound that were not evaluated
+    pub omitted_threads: usize,
+}
+
+/// Characters each of `threads` values may take of a `max_response_bytes`
+/// response (half of it, leaving room for the rest of each result)
+pub fn value_budget(max_response_bytes: usize, threads: usize) -> usize {
+    (max_response_bytes / 2 / threads.max(1)).max(MIN_VALUE_LEN)
+}
+
+/// A value redacted and cut to `budget` characters, and whether it was cut
+pub fn fit_value(value: &str, budget: usize) -> (String, bool) {
+    let value = crate::config::redact(value);
+    match value.char_indices().nth(budget) {
+        Some((cut, _)) => (format!("{}...", &value[..cut]), true),
+        None => (value, false),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_values_share_the_response_cap() {
+        assert_eq!(value_budget(256 * 1024, 32), 4096);
+        assert_eq!(value_budget(1000, 256), MIN_VALUE_LEN);
+        assert_eq!(value_budget(1000, 0), 500);
+
+        let (value, truncated) = fit_value(&"é".repeat(100), 70);
+        assert!(truncated);
+        assert_eq!(value.chars().count(), 73);
+        assert_eq!(fit_value("42", 70), ("42".to_string(), false));
+    }
+}
@@ -0,0 +1,294 @@
+//! Record of what happened in a session, for reproducibility and bug reports
+//!
+//! Launches, stops, evaluations and the exit code are appended as they
+//! happen; breakpoints and the current state are taken from the session when
+//! the transcript is exported. Values are redacted as they are recorded:
+//! environment variables whose names mark them as credentials are replaced
+//! entirely, everything else goes through the configured redaction patterns.
+
+use crate::config;
+use serde::Serialize;
+use serde_json::Value;
+use std::time::{Instant, SystemTime, UNIX_EPOCH};
+
+/// Records kept per kind; the oldest are dropped beyond this
+pub const MAX_RECORDS: usize = 1000;
+
+/// Longest expression or result kept per evaluation
+const MAX_VALUE_CHARS: usize = 1000;
+
+/// Environment variable names containing one of these are never recorded
+const SENSITIVE_ENV_MARKERS: &[&str] = &[
+    "SECRET",
+    "TOKEN",
+    "PASSWORD",
+    "PASSWD",
+    "CREDENTIAL",
+    "API_KEY",
+    "ACCESS_KEY",
+    "PRIVATE_KEY",
+    "AUTH",
+];
+
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct LaunchRecord {
+    /// Milliseconds since the session was created
+    pub at_ms: u64,
+    pub adapter_id: String,
+    /// Launch (or attach) arguments as sent to the adapter, redacted
+    pub arguments: Value,
+}
+
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct StopRecord {
+    pub at_ms: u64,
+    pub thread_id: i32,
+    pub reason: String,
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub hit_breakpoint_ids: Vec<i32>,
+    /// Where the program stopped: the top frame once a stack trace was taken,
+    /// else the location of the breakpoint that was hit, if any
+    pub function: Option<String>,
+    pub path: Option<String>,
+    pub line: Option<i32>,
+}
+
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct EvaluationRecord {
+    pub at_ms: u64,
+    pub expression: String,
+    pub frame_id: Option<i32>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub result: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub error: Option<String>,
+}
+
+#[derive(Debug, Clone)]
+pub struct Transcript {
+    started: Instant,
+    /// Wall-clock creation time, in milliseconds since the Unix epoch
+    pub started_at_ms: u64,
+    pub launches: Vec<LaunchRecord>,
+    pub stops: Vec<StopRecord>,
+    pub evaluations: Vec<EvaluationRecord>,
+    /// Exit code from the adapter's `exited` event
+    pub exit_code: Option<i64>,
+    /// Records dropped because a kind exceeded `MAX_RECORDS`
+    pub dropped_records: usize,
+}
+
+impl Default for Transcript {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+impl Transcript {
+    pub fn new() -> Self {
+        Self {
+            started: Instant::now(),
+            started_at_ms: SystemTime::now()
+                .duration_since(UNIX_EPOCH)
+                .map_or(0, |d| d.as_millis() as u64),
+            launches: Vec::new(),
+            stops: Vec::new(),
+            evaluations: Vec::new(),
+            exit_code: None,
+            dropped_records: 0,
+        }
+    }
+
+    /// Milliseconds since the transcript was started
+    pub fn elapsed_ms(&self) -> u64 {
+        self.started.elapsed().as_millis() as u64
+    }
+
+    pub fn record_launch(&mut self, adapter_id: &str, arguments: &Value) {
+        let record = LaunchRecord {
+            at_ms: self.elapsed_ms(),
+            adapter_id: adapter_id.to_string(),
+            arguments: redact_launch_arguments(arguments),
+        };
+        push(&mut self.launches, record, &mut self.dropped_records);
+    }
+
+    pub fn record_stop(
+        &mut self,
+        thread_id: i32,
+        reason: &str,
+        hit_breakpoint_ids: Vec<i32>,
+        breakpoint_location: Option<(String, i32)>,
+    ) {
+        let (path, line) = breakpoint_location.unzip();
+        let record = StopRecord {
+            at_ms: self.elapsed_ms(),
+            thread_id,
+            reason: reason.to_string(),
+            hit_breakpoint_ids,
+            function: None,
+            path,
+            line,
+        };
+        push(&mut self.stops, record, &mut self.dropped_records);
+    }
+
+    /// Fill in the location of the latest stop from the top frame of its thread
+    ///
+    /// Only the latest stop can still be the current one, and only if no
+    /// frame was recorded for it yet.
+    pub fn locate_latest_stop(
+        &mut self,
+        thread_id: i32,
+        function: &str,
+        path: Option<&str>,
+        line: i32,
+    ) {
+        if let Some(stop) = self.stops.last_mut() {
+            if stop.thread_id == thread_id && stop.function.is_none() {
+                stop.function = Some(function.to_string());
+                stop.path = path.map(str::to_string).or(stop.path.take());
+                stop.line = Some(line);
+            }
+        }
+    }
+
+    /// Whether the latest stop still lacks its top frame
+    pub fn latest_stop_unlocated(&self) -> bool {
+        self.stops
+            .last()
+            .is_some_and(|stop| stop.function.is_none())
+    }
+
+    pub fn record_evaluation(
+        &mut self,
+        expression: &str,
+        frame_id: Option<i32>,
+        outcome: std::result::Result<&str, String>,
+    ) {
+        let (result, error) = match outcome {
+            Ok(value) => (Some(clip(value)), None),
+            Err(e) => (None, Some(clip(&e))),
+        };
+        let record = EvaluationRecord {
+            at_ms: self.elapsed_ms(),
+            expression: clip(expression),
+            frame_id,
+            result,
+            error,
+        };
+        push(&mut self.evaluations, record, &mut self.dropped_records);
+    }
+
+    pub fn record_exit(&mut self, exit_code: i64) {
+        self.exit_code = Some(exit_code);
+    }
+}
+
+fn push<T>(records: &mut Vec<T>, record: T, dropped: &mut usize) {
+    if records.len() >= MAX_RECORDS {
+        records.remove(0);
+        *dropped += 1;
+    }
+    records.push(record);
+}
+
+/// Redact and shorten a recorded value
+fn clip(text: &str) -> String {
+    let text = config::redact(text);
+    match text.char_indices().nth(MAX_VALUE_CHARS) {
+        Some((cut, _)) => format!("{}...", &text[..cut]),
+        None => text,
+    }
+}
+
+/// Whether an environment variable name marks its value as a credential
+pub fn is_sensitive_env(name: &str) -> bool {
+    let name = name.to_ascii_uppercase();
+    SENSITIVE_ENV_MARKERS
+        .iter()
+        .any(|marker| name.contains(marker))
+}
+
+/// Launch arguments with sensitive environment values removed and the
+/// redaction patterns applied to every other string
+pub fn redact_launch_arguments(arguments: &Value) -> Value {
+    fn walk(value: &Value, env: bool) -> Value {
+        match value {
+            Value::Object(map) => Value::Object(
+                map.iter()
+                    .map(|(key, item)| {
+                        let item = if env && is_sensitive_env(key) {
+                            Value::String(config::REDACTED.to_string())
+                        } else {
+                            walk(item, key == "env")
+                        };
+                        (key.clone(), item)
+                    })
+                    .collect(),
+            ),
+            Value::Array(items) => Value::Array(items.iter().map(|v| walk(v, false)).collect()),
+            Value::String(text) => Value::String(config::redact(text)),
+            other => other.clone(),
+        }
+    }
+    walk(arguments, false)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    #[test]
+    fn test_sensitive_env_values_redacted() {
+        let launch = json!({
+            "request": "launch",
+            "program": "/w/app.py",
+            "args": ["--port", "8000"],
+            "env": {"APP_ENV": "test", "GITHUB_TOKEN": "ghp_x", "db_password": "hunter2"}
+        });
+        let redacted = redact_launch_arguments(&launch);
+
+        assert_eq!(redacted["env"]["APP_ENV"], "test");
+        assert_eq!(redacted["env"]["GITHUB_TOKEN"], config::REDACTED);
+        assert_eq!(redacted["env"]["db_password"], config::REDACTED);
+        assert_eq!(redacted["args"], json!(["--port", "8000"]));
+        assert_eq!(redacted["program"], "/w/app.py");
+    }
+
+    #[test]
+    fn test_stops_located_and_evaluations_clipped() {
+        let mut transcript = Transcript::new();
+        transcript.record_stop(
+            1,
+            "breakpoint",
+            vec![3],
+            Some(("/w/app.py".to_string(), 18)),
+        );
+        transcript.locate_latest_stop(1, "fizzbuzz", Some("/w/app.py"), 18);
+        // A second frame lookup for the same stop changes nothing
+        transcript.locate_latest_stop(1, "main", Some("/w/app.py"), 30);
+        assert_eq!(transcript.stops[0].function.as_deref(), Some("fizzbuzz"));
+        assert!(!transcript.latest_stop_unlocated());
+
+        transcript.record_stop(1, "step", vec![], None);
+        assert!(transcript.latest_stop_unlocated());
+
+        transcript.record_evaluation("n", Some(4), Ok("15"));
+        transcript.record_evaluation(&"x".repeat(5000), None, Err("NameError".to_string()));
+        assert_eq!(transcript.evaluations[0].result.as_deref(), Some("15"));
+        let long = &transcript.evaluations[1];
+        assert!(long.expression.len() < 1100 && long.expression.ends_with("..."));
+        assert_eq!(long.error.as_deref(), Some("NameError"));
+
+        for _ in 0..MAX_RECORDS {
+            transcript.record_stop(2, "pause", vec![], None);
+        }
+        assert_eq!(transcript.stops.len(), MAX_RECORDS);
+        assert_eq!(transcript.dropped_records, 2);
+    }
+}
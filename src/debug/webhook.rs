@@ -0,0 +1,354 @@
+//! Session event webhooks
+//!
+//! CI jobs and other automation often can't hold an MCP connection open, so a
+//! session can POST its events to an HTTP endpoint instead: `webhookUrl` on
+//! `debugger_start`, or `webhooks.default_url` from the server configuration.
+//! The events are:
+//!
+//! - `stopped`: thread, reason and breakpoints hit, as `debugger_wait_for_stop`
+//!   reports them
+//! - `exited`: the exit code
+//! - `crashed`: the crash report, once it is taken
+//! - `relaunched`: the session watching its sources started again (see
+//!   `file_watch`); sent by the new session
+//!
+//! Each is one JSON object (`WebhookPayload`). A delivery that fails (a
+//! transport error or a non-2xx status) is retried once after
+//! `RETRY_DELAY`; either way the outcome goes to the session's delivery log,
+//! read with `debugger_webhook_deliveries`. Deliveries run in their own tasks
+//! and never fail or delay the session.
+//!
+//! Since the URL comes from the client, it must start with one of the
+//! server's `webhooks.allowed_urls`; with none configured, webhooks are off.
+//! This keeps an untrusted client from making the server reach internal
+//! services. For the same reason redirects aren't followed: an allowed
+//! endpoint answering 3xx could send the event anywhere, so the delivery
+//! fails instead.
+
+use crate::config::WebhookConfig;
+use crate::{Error, Result};
+use serde::Serialize;
+use serde_json::Value;
+use std::collections::VecDeque;
+use std::sync::{Arc, Mutex};
+use std::time::{Duration, SystemTime, UNIX_EPOCH};
+use tracing::{info, warn};
+
+/// Deliveries kept in a session's log
+pub const MAX_DELIVERIES: usize = 100;
+
+/// How long one POST may take
+const DELIVERY_TIMEOUT: Duration = Duration::from_secs(5);
+
+/// Wait before the one retry of a failed delivery
+const RETRY_DELAY: Duration = Duration::from_secs(1);
+
+/// Attempts per delivery: the first and one retry
+const MAX_ATTEMPTS: u32 = 2;
+
+/// What is POSTed for an event
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct WebhookPayload {
+    /// `stopped`, `exited`, `crashed` or `relaunched`
+    pub event: String,
+    pub session_id: String,
+    pub language: String,
+    pub program: String,
+    pub timestamp_ms: u64,
+    pub body: Value,
+}
+
+/// Outcome of delivering one event
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct Delivery {
+    pub event: String,
+    pub timestamp_ms: u64,
+    pub delivered: bool,
+    pub attempts: u32,
+    /// HTTP status of the last attempt, if it got a response
+    pub status: Option<u16>,
+    /// Why the last attempt failed
+    pub error: Option<String>,
+}
+
+/// A session's webhook and its delivery log
+#[derive(Debug, Clone)]
+pub struct Webhook {
+    pub url: String,
+    session_id: String,
+    language: String,
+    program: String,
+    client: std::result::Result<reqwest::Client, String>,
+    deliveries: Arc<Mutex<VecDeque<Delivery>>>,
+}
+
+impl Webhook {
+    /// A webhook for a session; `url` must have passed `validate_url`
+    pub fn new(url: String, session_id: &str, language: &str, program: &str) -> Self {
+        // Without a client of its own, deliveries fail rather than fall back
+        // to one following redirects
+        let client = reqwest::Client::builder()
+            .timeout(DELIVERY_TIMEOUT)
+            .redirect(reqwest::redirect::Policy::none())
+            .build()
+            .map_err(|e| e.to_string());
+        Self {
+            url,
+            session_id: session_id.to_string(),
+            language: language.to_string(),
+            program: program.to_string(),
+            client,
+            deliveries: Arc::new(Mutex::new(VecDeque::new())),
+        }
+    }
+
+    /// The payload of an event
+    pub fn payload(&self, event: &str, body: Value) -> WebhookPayload {
+        WebhookPayload {
+            event: event.to_string(),
+            session_id: self.session_id.clone(),
+            language: self.language.clone(),
+            program: self.program.clone(),
+            timestamp_ms: now_ms(),
+            body,
+        }
+    }
+
+    /// POST an event in the background
+    pub fn send(&self, event: &str, body: Value) {
+        let payload = self.payload(event, body);
+        let webhook = self.clone();
+        tokio::spawn(async move { webhook.deliver(payload).await });
+    }
+
+    /// POST `payload`, retrying once, and log the outcome
+    async fn deliver(&self, payload: WebhookPayload) {
+        let mut delivery = Delivery {
+            event: payload.event.clone(),
+            timestamp_ms: payload.timestamp_ms,
+            delivered: false,
+            attempts: 0,
+            status: None,
+            error: None,
+        };
+        while delivery.attempts < MAX_ATTEMPTS && !delivery.delivered {
+            if delivery.attempts > 0 {
+                tokio::time::sleep(RETRY_DELAY).await;
+            }
+            delivery.attempts += 1;
+            let client = match &self.client {
+                Ok(client) => client,
+                Err(e) => {
+                    delivery.error = Some(format!("No HTTP client: {}", e));
+                    break;
+                }
+            };
+            match client.post(&self.url).json(&payload).send().await {
+                Ok(response) => {
+                    let status = response.status().as_u16();
+                    let location = response
+                        .headers()
+                        .get(reqwest::header::LOCATION)
+                        .and_then(|location| location.to_str().ok());
+                    delivery.status = Some(status);
+                    delivery.error = status_error(status, location);
+                    delivery.delivered = delivery.error.is_none();
+                }
+                Err(e) => {
+                    delivery.status = None;
+                    delivery.error = Some(e.to_string());
+                }
+            }
+        }
+        if delivery.delivered {
+            info!(
+                "🪝 Webhook '{}' event delivered to {}",
+                delivery.event, self.url
+            );
+        } else {
+            warn!(
+                "⚠️  Webhook '{}' event not delivered to {} after {} attempts: {}",
+                delivery.event,
+                self.url,
+                delivery.attempts,
+                delivery.error.as_deref().unwrap_or_default()
+            );
+        }
+        self.record(delivery);
+    }
+
+    fn record(&self, delivery: Delivery) {
+        let mut deliveries = self.deliveries.lock().unwrap_or_else(|e| e.into_inner());
+        if deliveries.len() >= MAX_DELIVERIES {
+            deliveries.pop_front();
+        }
+        deliveries.push_back(delivery);
+    }
+
+    /// The delivery log, oldest first
+    pub fn deliveries(&self) -> Vec<Delivery> {
+        let deliveries = self.deliveries.lock().unwrap_or_else(|e| e.into_inner());
+        deliveries.iter().cloned().collect()
+    }
+}
+
+/// Why a response with `status` is a failed delivery; a redirect (to
+/// `location`) is one, as it isn't followed
+fn status_error(status: u16, location: Option<&str>) -> Option<String> {
+    match status {
+        200..=299 => None,
+        300..=399 => Some(format!(
+            "HTTP {}: redirect to {} not followed",
+            status,
+            location.unwrap_or("(no location)")
+        )),
+        _ => Some(format!("HTTP {}", status)),
+    }
+}
+
+/// The webhook of a new session: `requested` if it is allowed, else the
+/// server's default
+pub fn resolve_url(requested: Option<&str>, config: &WebhookConfig) -> Result<Option<String>> {
+    match requested {
+        Some(url) => {
+            validate_url(url, &config.allowed_urls)?;
+            Ok(Some(url.to_string()))
+        }
+        None => Ok(config.default_url.clone()),
+    }
+}
+
+/// Check a webhook URL against the allowed prefixes
+///
+/// The URL must be http(s) without credentials and start with one of
+/// `allowed`, ending there or continuing with a path, query or fragment, so
+/// `https://ci.example.com` doesn't admit `https://ci.example.com.evil.io`.
+pub fn validate_url(url: &str, allowed: &[String]) -> Result<()> {
+    check_http_url(url).map_err(|problem| {
+        Error::InvalidRequest(format!("Invalid webhook URL '{}': {}", url, problem))
+    })?;
+    if allowed.is_empty() {
+        return Err(Error::InvalidRequest(
+            "Webhooks are disabled on this server: it allows no webhook URLs (webhooks.allowed_urls)".to_string(),
+        ));
+    }
+    let permitted = allowed.iter().any(|prefix| {
+        url.strip_prefix(prefix.as_str()).is_some_and(|rest| {
+            prefix.ends_with('/') || rest.is_empty() || rest.starts_with(['/', '?', '#'])
+        })
+    });
+    if !permitted {
+        return Err(Error::InvalidRequest(format!(
+            "Webhook URL '{}' is not allowed on this server (allowed: {})",
+            url,
+            allowed.join(", ")
+        )));
+    }
+    Ok(())
+}
+
+/// Why `url` isn't an http(s) URL with a host and no credentials
+pub fn check_http_url(url: &str) -> std::result::Result<(), String> {
+    let rest = url
+        .strip_prefix("https://")
+        .or_else(|| url.strip_prefix("http://"))
+        .ok_or("must start with http:// or https://")?;
+    let authority = rest.split(['/', '?', '#']).next().unwrap_or_default();
+    if authority.is_empty() {
+        return Err("has no host".to_string());
+    }
+    if authority.contains('@') {
+        return Err("must not contain credentials".to_string());
+    }
+    if url.chars().any(|c| c.is_whitespace() || c.is_control()) {
+        return Err("must not contain whitespace".to_string());
+    }
+    Ok(())
+}
+
+fn now_ms() -> u64 {
+    SystemTime::now()
+        .duration_since(UNIX_EPOCH)
+        .map_or(0, |d| d.as_millis() as u64)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    #[test]
+    fn test_urls_are_checked_against_the_allowlist() {
+        let allowed = vec![
+            "https://ci.example.com".to_string(),
+            "http://10.0.0.5:8080/hooks/".to_string(),
+        ];
+        for url in [
+            "https://ci.example.com",
+            "https://ci.example.com/debug?job=42",
+            "http://10.0.0.5:8080/hooks/session",
+        ] {
+            assert!(validate_url(url, &allowed).is_ok(), "{}", url);
+        }
+        for (url, expected) in [
+            ("https://ci.example.com.evil.io/x", "not allowed"),
+            ("http://ci.example.com/x", "not allowed"),
+            ("http://169.254.169.254/latest", "not allowed"),
+            ("https://user:pw@ci.example.com/x", "credentials"),
+            ("file:///etc/passwd", "http://"),
+            ("https:///x", "no host"),
+        ] {
+            let err = validate_url(url, &allowed).unwrap_err();
+            assert!(err.to_string().contains(expected), "{}: {}", url, err);
+        }
+        let err = validate_url("https://ci.example.com", &[]).unwrap_err();
+        assert!(err.to_string().contains("disabled"), "{}", err);
+
+        let config = WebhookConfig {
+            allowed_urls: allowed,
+            default_url: Some("https://ci.example.com/default".to_string()),
+        };
+        assert_eq!(
+            resolve_url(None, &config).unwrap().as_deref(),
+            Some("https://ci.example.com/default")
+        );
+        assert!(resolve_url(Some("https://evil.io"), &config).is_err());
+    }
+
+    #[test]
+    fn test_redirects_fail_deliveries() {
+        assert_eq!(status_error(204, None), None);
+        let err = status_error(307, Some("http://169.254.169.254/latest")).unwrap();
+        assert!(err.contains("redirect to http://169.254.169.254/latest not followed"));
+        assert_eq!(status_error(500, None).as_deref(), Some("HTTP 500"));
+    }
+
+    #[test]
+    fn test_delivery_log_is_bounded() {
+        let webhook = Webhook::new(
+            "https://ci.example.com/hook".to_string(),
+            "s1",
+            "python",
+            "/w/app.py",
+        );
+        let payload = webhook.payload("exited", json!({"exitCode": 1}));
+        assert_eq!(payload.session_id, "s1");
+        assert_eq!(payload.body["exitCode"], 1);
+
+        for attempts in 0..=MAX_DELIVERIES as u32 {
+            webhook.record(Delivery {
+                event: "stopped".to_string(),
+                timestamp_ms: 0,
+                delivered: false,
+                attempts,
+                status: None,
+                error: None,
+            });
+        }
+        let deliveries = webhook.deliveries();
+        assert_eq!(deliveries.len(), MAX_DELIVERIES);
+        assert_eq!(deliveries[0].attempts, 1);
+    }
+}
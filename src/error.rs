@@ -1,3 +1,5 @@
+use crate::process::ProcessInfo;
+use serde_json::{json, Value};
 use thiserror::Error;
 
 #[derive(Debug, Error)]
@@ -11,6 +13,9 @@ pub enum Error {
     #[error("Session not found: {0}")]
     SessionNotFound(String),
 
+    #[error("Session group not found: {0}")]
+    GroupNotFound(String),
+
     #[error("Adapter not found for language: {0}")]
     AdapterNotFound(String),
 
@@ -37,6 +42,40 @@ pub enum Error {
 
     #[error("Internal error: {0}")]
     Internal(String),
+
+    #[error("Configuration error: {0}")]
+    Config(String),
+
+    #[error("Cancelled: {0}")]
+    Cancelled(String),
+
+    #[error("Read-only session: {0}")]
+    ReadOnlySession(String),
+
+    #[error("Thread {0} is running; it must be stopped for this operation")]
+    ThreadRunning(i32),
+
+    #[error("{adapter} does not support {capability}{}", suggestion.as_ref().map(|s| format!("; {}", s)).unwrap_or_default())]
+    UnsupportedCapability {
+        /// DAP capability name, e.g. `supportsLogPoints`
+        capability: String,
+        adapter: String,
+        /// Alternative to try instead, if there is one
+        suggestion: Option<String>,
+    },
+
+    #[error("Path not found: {path}{}", candidate.as_ref().map(|c| format!("; did you mean '{}'?", c)).unwrap_or_default())]
+    PathNotFound {
+        path: String,
+        /// Existing path differing only in letter case, if there is one
+        candidate: Option<String>,
+    },
+
+    #[error("Multiple processes match '{query}' ({}); retry with a processId", candidates.len())]
+    AmbiguousProcess {
+        query: String,
+        candidates: Vec<ProcessInfo>,
+    },
 }
 
 impl Error {
@@ -49,12 +88,44 @@ impl Error {
             Error::InvalidState(_) => -32005,
             Error::Timeout(_) => -32006,
             Error::Compilation(_) => -32007,
+            Error::AmbiguousProcess { .. } => -32008,
+            Error::ThreadRunning(_) => -32009,
+            Error::UnsupportedCapability { .. } => -32010,
+            Error::Config(_) => -32011,
+            Error::PathNotFound { .. } => -32012,
+            Error::GroupNotFound(_) => -32013,
+            Error::Cancelled(_) => -32014,
+            Error::ReadOnlySession(_) => -32015,
             Error::InvalidRequest(_) => -32600,
             Error::MethodNotFound(_) => -32601,
             Error::Internal(_) => -32603,
             Error::Io(_) | Error::Json(_) => -32603,
         }
     }
+
+    /// Structured details for the JSON-RPC `error.data` field, if any
+    pub fn data(&self) -> Option<Value> {
+        match self {
+            Error::AmbiguousProcess { query, candidates } => Some(json!({
+                "query": query,
+                "candidates": candidates,
+            })),
+            Error::UnsupportedCapability {
+                capability,
+                adapter,
+                suggestion,
+            } => Some(json!({
+                "capability": capability,
+                "adapter": adapter,
+                "suggestion": suggestion,
+            })),
+            Error::PathNotFound { path, candidate } => Some(json!({
+                "path": path,
+                "candidate": candidate,
+            })),
+            _ => None,
+        }
+    }
 }
 
 #[cfg(test)]
@@ -110,6 +181,94 @@ mod tests {
         assert_eq!(err.to_string(), "Internal error: unexpected state");
     }
 
+    #[test]
+    fn test_ambiguous_process_error() {
+        let candidate = |pid| ProcessInfo {
+            pid,
+            name: "myservice".to_string(),
+            cmdline: "/usr/bin/myservice".to_string(),
+            user: Some("app".to_string()),
+            start_time: Some(1_700_000_000),
+        };
+        let err = Error::AmbiguousProcess {
+            query: "myservice".to_string(),
+            candidates: vec![candidate(10), candidate(11)],
+        };
+        assert_eq!(err.error_code(), -32008);
+        assert!(err
+            .to_string()
+            .contains("Multiple processes match 'myservice' (2)"));
+
+        let data = err.data().unwrap();
+        assert_eq!(data["query"], "myservice");
+        assert_eq!(data["candidates"][1]["pid"], 11);
+        assert_eq!(data["candidates"][0]["startTime"], 1_700_000_000);
+    }
+
+    #[test]
+    fn test_group_not_found_error() {
+        let err = Error::GroupNotFound("g-1".to_string());
+        assert_eq!(err.error_code(), -32013);
+        assert_eq!(err.to_string(), "Session group not found: g-1");
+    }
+
+    #[test]
+    fn test_cancelled_error() {
+        let err = Error::Cancelled("start of session s-1".to_string());
+        assert_eq!(err.error_code(), -32014);
+        assert_eq!(err.to_string(), "Cancelled: start of session s-1");
+    }
+
+    #[test]
+    fn test_thread_running_error() {
+        let err = Error::ThreadRunning(7);
+        assert_eq!(err.error_code(), -32009);
+        assert!(err.to_string().starts_with("Thread 7 is running"));
+    }
+
+    #[test]
+    fn test_unsupported_capability_error() {
+        let err = Error::UnsupportedCapability {
+            capability: "supportsDataBreakpoints".to_string(),
+            adapter: "rdbg".to_string(),
+            suggestion: Some("use a conditional breakpoint".to_string()),
+        };
+        assert_eq!(err.error_code(), -32010);
+        assert_eq!(
+            err.to_string(),
+            "rdbg does not support supportsDataBreakpoints; use a conditional breakpoint"
+        );
+        assert_eq!(err.data().unwrap()["capability"], "supportsDataBreakpoints");
+    }
+
+    #[test]
+    fn test_config_error() {
+        let err = Error::Config("logging.format: 'xml' is not one of text, json".to_string());
+        assert_eq!(err.error_code(), -32011);
+        assert!(err
+            .to_string()
+            .starts_with("Configuration error: logging.format"));
+    }
+
+    #[test]
+    fn test_path_not_found_error() {
+        let err = Error::PathNotFound {
+            path: "/w/Main.go".to_string(),
+            candidate: Some("/w/main.go".to_string()),
+        };
+        assert_eq!(err.error_code(), -32012);
+        assert_eq!(
+            err.to_string(),
+            "Path not found: /w/Main.go; did you mean '/w/main.go'?"
+        );
+        assert_eq!(err.data().unwrap()["candidate"], "/w/main.go");
+    }
+
+    #[test]
+    fn test_plain_errors_have_no_data() {
+        assert!(Error::Internal("x".to_string()).data().is_none());
+    }
+
     #[test]
     fn test_io_error_conversion() {
         let io_err = std::io::Error::new(std::io::ErrorKind::NotFound, "file not found");
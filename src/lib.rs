@@ -1,7 +1,12 @@
+// The debugger_start schema is one json! literal deeper than the default limit
+#![recursion_limit = "256"]
+
 pub mod adapters;
+pub mod config;
 pub mod dap;
 pub mod debug;
 pub mod error;
+pub mod log_level;
 pub mod mcp;
 pub mod process;
 
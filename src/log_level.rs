@@ -0,0 +1,254 @@
+//! Log verbosity, adjustable while the server runs
+//!
+//! The server's tracing filter is installed behind a reload handle, so
+//! `debugger_set_log_level` can turn logging up around a tricky step and back
+//! down afterwards without a restart. The level is either global, replacing
+//! whatever `--log-level`, the config file or `RUST_LOG` set at startup, or
+//! per session: a `session` span with the session id is entered around
+//! every tool call for the session, its background launch and every message
+//! its adapter sends, and a per-session level is a filter directive for that
+//! span.
+
+use crate::{Error, Result};
+use std::collections::BTreeMap;
+use std::sync::{Mutex, OnceLock};
+use tracing::Span;
+use tracing_subscriber::layer::SubscriberExt;
+use tracing_subscriber::util::SubscriberInitExt;
+use tracing_subscriber::{reload, EnvFilter, Registry};
+
+/// Levels accepted by `set_level`
+pub const LEVELS: &[&str] = &["trace", "debug", "info", "warn", "error"];
+
+/// Name of the span carrying a session id
+pub const SESSION_SPAN: &str = "session";
+
+/// The global filter and the per-session levels in effect
+struct Levels {
+    /// Level or `RUST_LOG`-style directives for everything
+    global: String,
+    sessions: BTreeMap<String, String>,
+}
+
+impl Levels {
+    /// The global filter ("info" before logging is initialized)
+    fn global(&self) -> &str {
+        if self.global.is_empty() {
+            "info"
+        } else {
+            &self.global
+        }
+    }
+}
+
+static LEVELS_IN_EFFECT: Mutex<Levels> = Mutex::new(Levels {
+    global: String::new(),
+    sessions: BTreeMap::new(),
+});
+
+static HANDLE: OnceLock<reload::Handle<EnvFilter, Registry>> = OnceLock::new();
+
+/// Install the tracing subscriber, writing to stderr
+///
+/// `filter` is a level or `RUST_LOG`-style directives; `json` selects JSON
+/// lines over text.
+pub fn init(filter: &str, json: bool) -> Result<()> {
+    let env_filter = EnvFilter::try_new(filter)
+        .map_err(|e| Error::Config(format!("Invalid log filter '{}': {}", filter, e)))?;
+    let (layer, handle) = reload::Layer::new(env_filter);
+    let fmt = tracing_subscriber::fmt::layer().with_writer(std::io::stderr);
+    let registry = tracing_subscriber::registry().with(layer);
+    let installed = if json {
+        registry.with(fmt.json()).try_init()
+    } else {
+        registry.with(fmt).try_init()
+    };
+    installed.map_err(|e| Error::Internal(format!("Logging already initialized: {}", e)))?;
+
+    LEVELS_IN_EFFECT.lock().unwrap().global = filter.to_string();
+    let _ = HANDLE.set(handle);
+    Ok(())
+}
+
+/// The filter to start with: `rust_log` directives (`RUST_LOG`) if set and
+/// valid, else `level`, with why the directives were ignored
+pub fn startup_filter(rust_log: Option<String>, level: String) -> (String, Option<String>) {
+    let Some(directives) = rust_log.filter(|d| !d.is_empty()) else {
+        return (level, None);
+    };
+    match EnvFilter::try_new(&directives) {
+        Ok(_) => (directives, None),
+        Err(e) => {
+            let why = format!(
+                "Ignoring invalid RUST_LOG '{}' ({}), using '{}'",
+                directives, e, level
+            );
+            (level, Some(why))
+        }
+    }
+}
+
+/// Span to enter around work for a session (a disabled span without one)
+pub fn session_span(session_id: Option<&str>) -> Span {
+    match session_id {
+        Some(id) => tracing::info_span!(SESSION_SPAN, id),
+        None => Span::none(),
+    }
+}
+
+/// The global level, or a session's level (the global one unless set)
+pub fn level(session_id: Option<&str>) -> String {
+    let levels = LEVELS_IN_EFFECT.lock().unwrap();
+    session_id
+        .and_then(|id| levels.sessions.get(id))
+        .map_or(levels.global(), String::as_str)
+        .to_string()
+}
+
+/// Set the global level, or one session's level; returns the previous level
+pub fn set_level(session_id: Option<&str>, level: &str) -> Result<String> {
+    let level = level.trim().to_lowercase();
+    if !LEVELS.contains(&level.as_str()) {
+        return Err(Error::InvalidRequest(format!(
+            "Invalid log level '{}'; expected one of {}",
+            level,
+            LEVELS.join(", ")
+        )));
+    }
+
+    let mut levels = LEVELS_IN_EFFECT.lock().unwrap();
+    let previous = match session_id {
+        Some(id) => levels
+            .sessions
+            .insert(id.to_string(), level)
+            .unwrap_or_else(|| levels.global().to_string()),
+        None => {
+            let previous = levels.global().to_string();
+            levels.global = level;
+            previous
+        }
+    };
+    reload(&levels)?;
+    Ok(previous)
+}
+
+/// Forget a session's level (when the session ends)
+pub fn clear_session(session_id: &str) {
+    let mut levels = LEVELS_IN_EFFECT.lock().unwrap();
+    if levels.sessions.remove(session_id).is_some() {
+        let _ = reload(&levels);
+    }
+}
+
+/// Filter directives for the levels: the global filter, then one span
+/// directive per session
+fn directives(levels: &Levels) -> String {
+    std::iter::once(levels.global().to_string())
+        .chain(
+            levels
+                .sessions
+                .iter()
+                .map(|(id, level)| format!("[{}{{id={}}}]={}", SESSION_SPAN, id, level)),
+        )
+        .collect::<Vec<_>>()
+        .join(",")
+}
+
+/// Install the filter for the levels (nothing to do without a subscriber)
+fn reload(levels: &Levels) -> Result<()> {
+    let Some(handle) = HANDLE.get() else {
+        return Ok(());
+    };
+    let filter = EnvFilter::try_new(directives(levels))
+        .map_err(|e| Error::Internal(format!("Invalid log filter: {}", e)))?;
+    handle
+        .reload(filter)
+        .map_err(|e| Error::Internal(format!("Failed to change the log level: {}", e)))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::io::Write;
+    use std::sync::Arc;
+
+    /// Writer collecting formatted log lines
+    #[derive(Clone, Default)]
+    struct Captured(Arc<Mutex<Vec<u8>>>);
+
+    impl Write for Captured {
+        fn write(&mut self, buf: &[u8]) -> std::io::Result<usize> {
+            self.0.lock().unwrap().extend_from_slice(buf);
+            Ok(buf.len())
+        }
+        fn flush(&mut self) -> std::io::Result<()> {
+            Ok(())
+        }
+    }
+
+    #[test]
+    fn test_session_directive_raises_one_session() {
+        let levels = Levels {
+            global: "warn".to_string(),
+            sessions: BTreeMap::from([(
+                "0b6f1c2e-5d1a-4f7e-9a61-3c2d8e4f5a10".to_string(),
+                "debug".to_string(),
+            )]),
+        };
+        let captured = Captured::default();
+        let writer = captured.clone();
+        let subscriber = tracing_subscriber::registry()
+            .with(EnvFilter::try_new(directives(&levels)).unwrap())
+            .with(
+                tracing_subscriber::fmt::layer()
+                    .with_ansi(false)
+                    .with_writer(move || writer.clone()),
+            );
+
+        tracing::subscriber::with_default(subscriber, || {
+            tracing::debug!("outside any session");
+            session_span(Some("0b6f1c2e-5d1a-4f7e-9a61-3c2d8e4f5a10"))
+                .in_scope(|| tracing::debug!("traced session"));
+            session_span(Some("7e1d")).in_scope(|| tracing::debug!("other session"));
+            tracing::warn!("global warning");
+        });
+
+        let output = String::from_utf8(captured.0.lock().unwrap().clone()).unwrap();
+        assert!(output.contains("traced session"), "{}", output);
+        assert!(output.contains("global warning"), "{}", output);
+        assert!(!output.contains("outside any session"), "{}", output);
+        assert!(!output.contains("other session"), "{}", output);
+    }
+
+    #[test]
+    fn test_set_level_validates_and_returns_previous() {
+        let err = set_level(None, "verbose").unwrap_err();
+        assert!(err.to_string().contains("trace, debug, info, warn, error"));
+
+        // Without an installed subscriber only the bookkeeping changes
+        let session = "test-set-level-session";
+        let global = level(None);
+        assert_eq!(set_level(Some(session), "TRACE").unwrap(), global);
+        assert_eq!(level(Some(session)), "trace");
+        assert_eq!(set_level(Some(session), "info").unwrap(), "trace");
+        clear_session(session);
+        assert_eq!(level(Some(session)), global);
+    }
+
+    #[test]
+    fn test_startup_filter_falls_back_from_invalid_rust_log() {
+        let info = || "info".to_string();
+        assert_eq!(startup_filter(None, info()), (info(), None));
+        assert_eq!(startup_filter(Some(String::new()), info()), (info(), None));
+        assert_eq!(
+            startup_filter(Some("debugger_mcp=debug".to_string()), info()),
+            ("debugger_mcp=debug".to_string(), None)
+        );
+
+        let (filter, why) = startup_filter(Some("debugger_mcp=loud".to_string()), info());
+        assert_eq!(filter, "info");
+        assert!(why
+            .unwrap()
+            .contains("invalid RUST_LOG 'debugger_mcp=loud'"));
+    }
+}
@@ -1,6 +1,8 @@
 use clap::{Parser, Subcommand};
+use debugger_mcp::config::{self, ServerConfig};
+use debugger_mcp::log_level;
 use debugger_mcp::Result;
-use tracing_subscriber::EnvFilter;
+use std::path::PathBuf;
 
 #[derive(Parser)]
 #[command(name = "debugger_mcp")]
@@ -18,9 +20,14 @@ enum Commands {
         #[arg(short, long)]
         verbose: bool,
 
-        /// Set log level (trace, debug, info, warn, error)
-        #[arg(long, default_value = "info")]
-        log_level: String,
+        /// Set log level (trace, debug, info, warn, error) [default: info]
+        #[arg(long)]
+        log_level: Option<String>,
+
+        /// Configuration file with server defaults (TOML, or JSON for .json files);
+        /// falls back to DEBUGGER_MCP_CONFIG
+        #[arg(long)]
+        config: Option<PathBuf>,
     },
 }
 
@@ -29,16 +36,36 @@ async fn main() -> Result<()> {
     let cli = Cli::parse();
 
     match cli.command {
-        Commands::Serve { verbose, log_level } => {
-            // Initialize tracing
-            let level = if verbose { "debug" } else { &log_level };
-            let filter =
-                EnvFilter::try_from_default_env().unwrap_or_else(|_| EnvFilter::new(level));
-
-            tracing_subscriber::fmt()
-                .with_env_filter(filter)
-                .with_writer(std::io::stderr)
-                .init();
+        Commands::Serve {
+            verbose,
+            log_level,
+            config: config_path,
+        } => {
+            // Load the configuration before anything reads it; an invalid file fails startup
+            let path = config::resolve_path(config_path);
+            let server_config = match &path {
+                Some(path) => ServerConfig::load(path)?,
+                None => ServerConfig::default(),
+            };
+            let logging = server_config.logging.clone();
+            config::init(server_config, path)?;
+
+            // Initialize tracing: --verbose, then --log-level, then the config file
+            let level = if verbose {
+                "debug".to_string()
+            } else {
+                log_level
+                    .or(logging.level)
+                    .unwrap_or_else(|| "info".to_string())
+            };
+            // RUST_LOG directives win unless invalid; debugger_set_log_level can
+            // change either at runtime
+            let (filter, ignored) =
+                log_level::startup_filter(std::env::var("RUST_LOG").ok(), level);
+            log_level::init(&filter, logging.format.as_deref() == Some("json"))?;
+            if let Some(why) = ignored {
+                tracing::warn!("⚠️  {}", why);
+            }
 
             // Run the server
             debugger_mcp::serve().await?;
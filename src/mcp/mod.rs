@@ -1,5 +1,6 @@
 pub mod protocol;
 pub mod resources;
+pub mod response_limit;
 pub mod tools;
 pub mod transport;
 pub mod transport_trait;
@@ -14,6 +15,18 @@ use tools::ToolsHandler;
 use tracing::{error, info};
 use transport::StdioTransport;
 
+/// Version reported in `initialize` and by `debugger_info`
+pub const SERVER_VERSION: &str = env!("CARGO_PKG_VERSION");
+
+/// Commit the server was built from, when the build sets DEBUGGER_MCP_GIT_COMMIT
+pub const GIT_COMMIT: Option<&str> = option_env!("DEBUGGER_MCP_GIT_COMMIT");
+
+/// Transports the server speaks MCP over
+pub const TRANSPORTS: &[&str] = &["stdio"];
+
+/// Upper bound on how often idle sessions are looked for
+const IDLE_SWEEP_INTERVAL: std::time::Duration = std::time::Duration::from_secs(60);
+
 pub struct McpServer {
     transport: StdioTransport,
     handler: ProtocolHandler,
@@ -21,10 +34,45 @@ pub struct McpServer {
 
 impl McpServer {
     pub async fn new() -> Result<Self> {
-        info!("Initializing MCP server");
+        info!(
+            "Initializing MCP server {} ({})",
+            SERVER_VERSION,
+            GIT_COMMIT.unwrap_or("unknown commit")
+        );
+
+        // Probe the adapters now so debugger_info answers at once
+        tokio::spawn(async {
+            for language in crate::adapters::availability::languages().await {
+                match &language.error {
+                    None => info!(
+                        "{} available: {} {}",
+                        language.language,
+                        language.adapter.as_deref().unwrap_or_default(),
+                        language.version.as_deref().unwrap_or("(unknown version)")
+                    ),
+                    Some(error) => info!("{} unavailable: {}", language.language, error),
+                }
+            }
+        });
 
         let session_manager = Arc::new(RwLock::new(SessionManager::new()));
 
+        // Adapters of a server that crashed may still be running
+        crate::process::orphans::sweep(crate::config::current().sessions.reap_orphaned_adapters);
+
+        // Disconnect sessions left idle longer than `sessions.idle_timeout_secs`
+        if let Some(secs) = crate::config::current().sessions.idle_timeout_secs {
+            let idle = std::time::Duration::from_secs(secs);
+            let manager = Arc::clone(&session_manager);
+            tokio::spawn(async move {
+                let mut sweep = tokio::time::interval((idle / 2).min(IDLE_SWEEP_INTERVAL));
+                loop {
+                    sweep.tick().await;
+                    manager.read().await.reap_idle_sessions(idle).await;
+                }
+            });
+        }
+
         // Create tools handler
         let tools_handler = Arc::new(ToolsHandler::new(Arc::clone(&session_manager)));
 
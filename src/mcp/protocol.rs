@@ -151,7 +151,7 @@ impl ProtocolHandler {
             },
             "serverInfo": {
                 "name": "debugger_mcp",
-                "version": "0.1.0",
+                "version": super::SERVER_VERSION,
             },
         });
 
@@ -220,18 +220,28 @@ impl ProtocolHandler {
             }
         };
 
+        let spill_dir =
+            super::response_limit::spill_dir_for(&crate::config::spill_dir(), &arguments);
         match handler.handle_tool(name, arguments).await {
-            Ok(result) => JsonRpcResponse {
-                jsonrpc: "2.0".to_string(),
-                id: req.id,
-                result: Some(serde_json::json!({
-                    "content": [{
-                        "type": "text",
-                        "text": serde_json::to_string_pretty(&result).unwrap_or_else(|_| "{}".to_string())
-                    }]
-                })),
-                error: None,
-            },
+            Ok(result) => {
+                let result = super::response_limit::limit(
+                    name,
+                    result,
+                    crate::config::max_response_bytes(),
+                    Some(&spill_dir),
+                );
+                JsonRpcResponse {
+                    jsonrpc: "2.0".to_string(),
+                    id: req.id,
+                    result: Some(serde_json::json!({
+                        "content": [{
+                            "type": "text",
+                            "text": serde_json::to_string_pretty(&result).unwrap_or_else(|_| "{}".to_string())
+                        }]
+                    })),
+                    error: None,
+                }
+            }
             Err(e) => JsonRpcResponse {
                 jsonrpc: "2.0".to_string(),
                 id: req.id,
@@ -239,7 +249,7 @@ impl ProtocolHandler {
                 error: Some(JsonRpcError {
                     code: e.error_code(),
                     message: e.to_string(),
-                    data: None,
+                    data: e.data(),
                 }),
             },
         }
@@ -280,7 +290,7 @@ impl ProtocolHandler {
                 error: Some(JsonRpcError {
                     code: e.error_code(),
                     message: e.to_string(),
-                    data: None,
+                    data: e.data(),
                 }),
             },
         }
@@ -351,7 +361,7 @@ impl ProtocolHandler {
                 error: Some(JsonRpcError {
                     code: e.error_code(),
                     message: e.to_string(),
-                    data: None,
+                    data: e.data(),
                 }),
             },
         }
@@ -1,3 +1,4 @@
+use crate::debug::stack::{StackReport, DEFAULT_STACK_LEVELS};
 use crate::debug::SessionManager;
 use crate::{Error, Result};
 use serde::{Deserialize, Serialize};
@@ -740,6 +741,7 @@ impl ResourcesHandler {
                     "language": session.language,
                     "program": session.program,
                     "state": state,
+                    "readOnly": session.is_read_only(),
                 }));
             }
         }
@@ -787,6 +789,7 @@ impl ResourcesHandler {
             "language": session.language,
             "program": session.program,
             "state": state,
+            "readOnly": session.is_read_only(),
             "breakpoints": all_breakpoints,
         });
 
@@ -805,19 +808,26 @@ impl ResourcesHandler {
 
         let state = session.get_state().await;
 
-        // Only get stack trace if stopped
-        let frames: Vec<crate::dap::types::StackFrame> = match state {
-            crate::debug::state::DebugState::Stopped { .. } => {
-                session.stack_trace().await.unwrap_or_default()
-            }
-            _ => vec![],
+        // Only get stack trace if stopped; the same first page as debugger_stack_trace
+        let report = match state {
+            crate::debug::state::DebugState::Stopped { thread_id, .. } => session
+                .stack_trace_page(thread_id, 0, DEFAULT_STACK_LEVELS)
+                .await
+                .ok()
+                .map(StackReport::from),
+            _ => None,
         };
 
-        let content = json!({
+        let mut content = json!({
             "sessionId": session.id,
             "state": state,
-            "stackFrames": frames,
+            "stackFrames": [],
         });
+        if let Some(report) = report {
+            content["stackFrames"] = json!(report.stack_frames);
+            content["totalFrames"] = json!(report.total_frames);
+            content["moreFrames"] = json!(report.more_frames);
+        }
 
         Ok(ResourceContents {
             uri: format!("debugger://sessions/{}/stackTrace", session_id),
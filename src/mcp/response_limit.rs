@@ -0,0 +1,435 @@
+//! Size cap for tool responses
+//!
+//! A single inspection of a huge data structure can produce a response too
+//! large for an MCP client to use. Responses whose pretty-printed JSON exceeds
+//! the configured maximum (`limits.max_response_bytes`, or the
+//! `DEBUGGER_MCP_MAX_RESPONSE_BYTES` environment variable) are shrunk until they
+//! fit: the largest array loses its trailing half, or the longest string is
+//! cut, one step at a time. The result keeps its shape and gains a
+//! `responseTruncated` object describing what was dropped and how to narrow
+//! the request.
+//!
+//! Before truncating, the full response is spilled to a JSON file under
+//! `limits.spill_dir`, one directory per session, so nothing is lost: the
+//! note's `spilledTo` names the file, and every shortened array is
+//! summarized by its count and its first and last items. Responses hold
+//! program data, so the directories are created 0700 and a file is only
+//! written to one that is the user's own and closed to others (see
+//! `private_dir`); a session's directory is removed with the session.
+
+use crate::process::private_dir;
+use serde_json::{json, Value};
+use std::path::{Path, PathBuf};
+use std::sync::atomic::{AtomicU64, Ordering};
+
+/// Strings are never cut shorter than this many characters
+const MIN_STRING_KEEP: usize = 256;
+
+/// Marker appended to cut strings
+const CUT_MARKER: &str = "...[truncated]";
+
+/// How to get the rest of a truncated response, per tool
+const TRUNCATION_HINTS: &[(&str, &str)] = &[
+    (
+        "debugger_stack_trace",
+        "request fewer frames with levels and page through the rest with startFrame, pass threadId to fetch one thread's frames, or inspect a specific frame with debugger_evaluate",
+    ),
+    (
+        "debugger_wait_for_stop",
+        "the exception capture was shrunk; page the stack with debugger_stack_trace and read locals with debugger_evaluate",
+    ),
+    (
+        "debugger_evaluate",
+        "evaluate a narrower expression, e.g. a single field, an index, a slice or len()",
+    ),
+    (
+        "debugger_evaluate_all_threads",
+        "lower maxThreads or evaluate a narrower expression",
+    ),
+    (
+        "debugger_inline_values",
+        "evaluate the variables you need individually with debugger_evaluate",
+    ),
+    (
+        "debugger_source_context",
+        "request fewer context lines",
+    ),
+    (
+        "debugger_export_session",
+        "the oldest stops and evaluations were dropped; export earlier in the session to keep them",
+    ),
+    (
+        "debugger_list_breakpoints",
+        "export breakpoints with debugger_export_breakpoints and filter the document",
+    ),
+];
+
+const DEFAULT_HINT: &str = "narrow the request (fewer items or a more specific expression)";
+
+/// First and last items of a shortened array are only summarized up to this
+/// size (compact JSON)
+const MAX_SUMMARY_ITEM_BYTES: usize = 1024;
+
+/// Spilled responses so far, keeping file names unique
+static SPILLED: AtomicU64 = AtomicU64::new(0);
+
+/// Something that can be shrunk: a JSON pointer and its serialized size
+struct Candidate {
+    pointer: String,
+    size: usize,
+}
+
+/// Directory to spill a tool call's response to: the call's session's own
+/// under `root`, or `root` itself for calls without a session
+pub fn spill_dir_for(root: &Path, arguments: &Value) -> PathBuf {
+    arguments
+        .get("sessionId")
+        .and_then(Value::as_str)
+        .and_then(|id| session_spill_dir(root, id))
+        .unwrap_or_else(|| root.to_path_buf())
+}
+
+/// Session `session_id`'s directory under `root`
+fn session_spill_dir(root: &Path, session_id: &str) -> Option<PathBuf> {
+    // Session ids are UUIDs; anything else mustn't become a path
+    let valid = !session_id.is_empty()
+        && session_id
+            .chars()
+            .all(|c| c.is_ascii_alphanumeric() || c == '-');
+    valid.then(|| root.join(session_id))
+}
+
+/// Delete the responses spilled for a session that ended
+pub fn remove_session_spills(root: &Path, session_id: &str) {
+    let Some(dir) = session_spill_dir(root, session_id) else {
+        return;
+    };
+    let owned = private_dir::current_uid().is_some_and(|uid| private_dir::owned_by(&dir, uid));
+    if owned {
+        if let Err(e) = std::fs::remove_dir_all(&dir) {
+            tracing::warn!(
+                "⚠️  Could not remove spilled responses in {}: {}",
+                dir.display(),
+                e
+            );
+        }
+    }
+}
+
+/// Write a full response to a new file in `dir`
+fn spill(dir: &Path, tool: &str, result: &Value) -> Option<PathBuf> {
+    let seq = SPILLED.fetch_add(1, Ordering::Relaxed);
+    let millis = std::time::SystemTime::now()
+        .duration_since(std::time::UNIX_EPOCH)
+        .map_or(0, |d| d.as_millis());
+    let path = dir.join(format!(
+        "{}-{}-{}-{}.json",
+        tool,
+        millis,
+        std::process::id(),
+        seq
+    ));
+    let uid = private_dir::current_uid()
+        .ok_or_else(|| std::io::Error::other("the current uid is unknown"));
+    let written = uid
+        .and_then(|uid| private_dir::ensure_private(dir, uid))
+        .and_then(|_| {
+            std::fs::write(
+                &path,
+                serde_json::to_string_pretty(result).unwrap_or_default(),
+            )
+        });
+    match written {
+        Ok(()) => Some(path),
+        Err(e) => {
+            tracing::warn!(
+                "⚠️  Could not spill the {} response to {}: {}",
+                tool,
+                path.display(),
+                e
+            );
+            None
+        }
+    }
+}
+
+/// An array item for a summary, or None when it's too large for one
+fn summary_item(item: Option<&Value>) -> Option<Value> {
+    let item = item?;
+    let size = serde_json::to_string(item).map_or(usize::MAX, |s| s.len());
+    (size <= MAX_SUMMARY_ITEM_BYTES).then(|| item.clone())
+}
+
+/// Apply the size cap to a tool's response
+///
+/// With a `spill_dir`, the full response is written to a file there first.
+pub fn limit(tool: &str, result: Value, max_bytes: usize, spill_dir: Option<&Path>) -> Value {
+    let original = pretty_len(&result);
+    if original <= max_bytes {
+        return result;
+    }
+
+    let mut result = match result {
+        Value::Object(_) => result,
+        other => json!({ "result": other }),
+    };
+    let spilled_to = spill_dir.and_then(|dir| spill(dir, tool, &result));
+    let full = result.clone();
+
+    // (pointer, original length) of every shrunk array or string
+    let mut shrunk: Vec<(String, usize)> = Vec::new();
+    while pretty_len(&result) > max_bytes {
+        let Some(candidate) = largest_candidate(&result) else {
+            break;
+        };
+        let Some(target) = result.pointer_mut(&candidate.pointer) else {
+            break;
+        };
+
+        let before = match target {
+            Value::Array(items) => {
+                let len = items.len();
+                items.truncate(len / 2);
+                len
+            }
+            Value::String(text) => {
+                let len = text.chars().count();
+                let keep = (len / 2).max(MIN_STRING_KEEP);
+                let cut = text.char_indices().nth(keep).map_or(text.len(), |(i, _)| i);
+                text.truncate(cut);
+                text.push_str(CUT_MARKER);
+                len
+            }
+            _ => break,
+        };
+        if !shrunk.iter().any(|(p, _)| *p == candidate.pointer) {
+            shrunk.push((candidate.pointer, before));
+        }
+    }
+
+    let omitted: Vec<Value> = shrunk
+        .iter()
+        .map(|(pointer, total)| {
+            let kept = match result.pointer(pointer) {
+                Some(Value::Array(items)) => items.len(),
+                Some(Value::String(text)) => text.chars().count() - CUT_MARKER.len(),
+                _ => 0,
+            };
+            let mut entry = json!({ "path": pointer, "kept": kept, "total": total });
+            if let Some(Value::Array(items)) = full.pointer(pointer) {
+                if let Some(first) = summary_item(items.first()) {
+                    entry["first"] = first;
+                }
+                if let Some(last) = summary_item(items.last()) {
+                    entry["last"] = last;
+                }
+            }
+            entry
+        })
+        .collect();
+
+    let hint = TRUNCATION_HINTS
+        .iter()
+        .find(|(name, _)| *name == tool)
+        .map_or(DEFAULT_HINT, |(_, hint)| *hint);
+
+    let hint = match &spilled_to {
+        Some(path) => format!(
+            "Response exceeded {} bytes and was truncated; the full response is in {}, or {}",
+            max_bytes,
+            path.display(),
+            hint
+        ),
+        None => format!(
+            "Response exceeded {} bytes and was truncated; {}",
+            max_bytes, hint
+        ),
+    };
+    result["responseTruncated"] = json!({
+        "originalBytes": original,
+        "maxBytes": max_bytes,
+        "omitted": omitted,
+        "hint": hint,
+    });
+    if let Some(path) = spilled_to {
+        result["responseTruncated"]["spilledTo"] = json!(path.display().to_string());
+    }
+    result
+}
+
+fn pretty_len(value: &Value) -> usize {
+    serde_json::to_string_pretty(value).map_or(0, |s| s.len())
+}
+
+/// Largest shrinkable array or string in the response
+fn largest_candidate(value: &Value) -> Option<Candidate> {
+    let mut best: Option<Candidate> = None;
+    collect(value, String::new(), &mut best);
+    best
+}
+
+fn collect(value: &Value, pointer: String, best: &mut Option<Candidate>) {
+    let shrinkable = match value {
+        Value::Array(items) => !items.is_empty(),
+        Value::String(text) => text.chars().count() > MIN_STRING_KEEP + CUT_MARKER.len(),
+        _ => false,
+    };
+    if shrinkable {
+        let size = serde_json::to_string(value).map_or(0, |s| s.len());
+        if !matches!(best, Some(b) if b.size >= size) {
+            *best = Some(Candidate {
+                pointer: pointer.clone(),
+                size,
+            });
+        }
+    }
+
+    match value {
+        Value::Array(items) => {
+            for (i, item) in items.iter().enumerate() {
+                collect(item, format!("{}/{}", pointer, i), best);
+            }
+        }
+        Value::Object(map) => {
+            for (key, item) in map {
+                let key = key.replace('~', "~0").replace('/', "~1");
+                collect(item, format!("{}/{}", pointer, key), best);
+            }
+        }
+        _ => {}
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn frames(count: usize) -> Value {
+        let frames: Vec<Value> = (0..count)
+            .map(|i| json!({"id": i, "name": format!("recurse_{}", i), "line": i}))
+            .collect();
+        json!({ "stackFrames": frames })
+    }
+
+    #[test]
+    fn test_small_response_untouched() {
+        let response = frames(3);
+        assert_eq!(
+            limit("debugger_stack_trace", response.clone(), 4096, None),
+            response
+        );
+    }
+
+    #[test]
+    fn test_large_array_truncated_with_indicator() {
+        let response = limit("debugger_stack_trace", frames(2000), 8 * 1024, None);
+
+        assert!(pretty_len(&response) <= 8 * 1024 + 512);
+        let kept = response["stackFrames"].as_array().unwrap().len();
+        assert!(kept > 0 && kept < 2000);
+
+        let note = &response["responseTruncated"];
+        assert_eq!(note["maxBytes"], 8 * 1024);
+        assert_eq!(note["omitted"][0]["path"], "/stackFrames");
+        assert_eq!(note["omitted"][0]["kept"], kept);
+        assert_eq!(note["omitted"][0]["total"], 2000);
+        assert!(note["hint"].as_str().unwrap().contains("threadId"));
+    }
+
+    #[test]
+    fn test_long_string_cut() {
+        let response = json!({"result": "x".repeat(100_000)});
+        let response = limit("debugger_evaluate", response, 4096, None);
+
+        let result = response["result"].as_str().unwrap();
+        assert!(result.ends_with(CUT_MARKER));
+        assert!(result.len() < 4096);
+        assert_eq!(
+            response["responseTruncated"]["omitted"][0]["total"],
+            100_000
+        );
+        assert!(response["responseTruncated"]["hint"]
+            .as_str()
+            .unwrap()
+            .contains("narrower expression"));
+    }
+
+    #[test]
+    fn test_non_object_response_wrapped() {
+        let response = limit("debugger_other", json!(vec![1; 5000]), 1024, None);
+        assert!(response["result"].is_array());
+        assert!(response["responseTruncated"]["hint"]
+            .as_str()
+            .unwrap()
+            .contains(DEFAULT_HINT));
+    }
+
+    #[test]
+    fn test_spill_at_the_threshold() {
+        let root = tempfile::tempdir().unwrap();
+        let dir = root.path().join("responses");
+        let response = frames(500);
+        let size = pretty_len(&response);
+
+        // A response of exactly the maximum is neither cut nor spilled
+        let kept = limit("debugger_stack_trace", response.clone(), size, Some(&dir));
+        assert_eq!(kept, response);
+        assert!(!dir.exists());
+
+        // One byte less, and the full response goes to a file
+        let cut = limit(
+            "debugger_stack_trace",
+            response.clone(),
+            size - 1,
+            Some(&dir),
+        );
+        let note = &cut["responseTruncated"];
+        let path = note["spilledTo"].as_str().unwrap();
+        assert!(path.contains("debugger_stack_trace-"));
+        assert!(note["hint"].as_str().unwrap().contains(path));
+        let spilled: Value = serde_json::from_str(&std::fs::read_to_string(path).unwrap()).unwrap();
+        assert_eq!(spilled, response);
+
+        let omitted = &note["omitted"][0];
+        assert_eq!(omitted["total"], 500);
+        assert_eq!(omitted["first"]["name"], "recurse_0");
+        assert_eq!(omitted["last"]["name"], "recurse_499");
+    }
+
+    #[test]
+    fn test_spill_dir_per_session() {
+        let root = Path::new("/tmp/spill");
+        let session = json!({"sessionId": "0b6f1c2e-5d1a-4f7e-9a61-3c2d8e4f5a10"});
+        assert_eq!(
+            spill_dir_for(root, &session),
+            root.join("0b6f1c2e-5d1a-4f7e-9a61-3c2d8e4f5a10")
+        );
+        assert_eq!(spill_dir_for(root, &json!({"sessionId": "../etc"})), root);
+        assert_eq!(spill_dir_for(root, &Value::Null), root);
+    }
+
+    #[cfg(unix)]
+    #[test]
+    fn test_spills_private_and_removed_with_session() {
+        use std::os::unix::fs::PermissionsExt;
+
+        let root = tempfile::tempdir().unwrap();
+        let session = "0b6f1c2e-5d1a-4f7e-9a61-3c2d8e4f5a10";
+        let dir = spill_dir_for(root.path(), &json!({ "sessionId": session }));
+        let path = spill(&dir, "debugger_evaluate", &json!({"result": "x"})).unwrap();
+        assert_eq!(
+            std::fs::metadata(&dir).unwrap().permissions().mode() & 0o777,
+            0o700
+        );
+
+        // Ending the session takes its spills; nothing else is touched
+        remove_session_spills(root.path(), "../etc");
+        remove_session_spills(root.path(), session);
+        assert!(!path.exists() && !dir.exists());
+        assert!(root.path().exists());
+
+        // A directory others can reach gets nothing
+        std::fs::set_permissions(root.path(), std::fs::Permissions::from_mode(0o755)).unwrap();
+        assert!(spill(root.path(), "debugger_evaluate", &json!({})).is_none());
+    }
+}
@@ -1,516 +1,5625 @@
+use crate::adapters::exec_prefix;
+use crate::adapters::golang::{
+    GoAdapter, GoLaunchOptions, DEFAULT_GOROUTINE_PAGE, MAX_GOROUTINE_PAGE,
+};
+use crate::adapters::goroutine_labels::GoroutineLabel;
+use crate::adapters::java::{JavaAdapter, JavaLaunchOptions};
+use crate::adapters::ruby::RubyLaunchOptions;
 use crate::adapters::security;
-use crate::debug::SessionManager;
-use crate::{Error, Result};
+use crate::adapters::source_maps;
+use crate::adapters::toolchain;
+use crate::adapters::{
+    entry_point, launch_gate, resolve_entry, resolve_mode, resolve_on_uncaught, EntryBreakpoint,
+    LaunchGate, LaunchOptions, OnUncaught,
+};
+use crate::dap::encoding;
+use crate::dap::types::{ExceptionOptions, Source, SteppingGranularity};
+use crate::debug::breakpoint_io::{
+    BreakpointDocument, BreakpointEntry, ImportReport, ImportStatus, WatchEntry,
+    BREAKPOINT_DOCUMENT_VERSION,
+};
+use crate::debug::caller_filter::CallerConstraint;
+use crate::debug::change_watch::{self, WatchStrategy};
+use crate::debug::compare;
+use crate::debug::continue_past::ContinuePastStatus;
+use crate::debug::disassembly::DEFAULT_INSTRUCTIONS;
+use crate::debug::dump;
+use crate::debug::file_watch::{self, FileWatch, Relaunch, WatchStatus};
+use crate::debug::group::{self, GroupMember};
+use crate::debug::launch_config::LaunchConfig;
+use crate::debug::launch_json;
+use crate::debug::output_log;
+use crate::debug::path_case;
+use crate::debug::resource_usage;
+use crate::debug::return_values;
+use crate::debug::settings::SettingsUpdate;
+use crate::debug::stack::{self, StackReport, DEFAULT_STACK_LEVELS, MAX_STACK_LEVELS};
+use crate::debug::state::{
+    Breakpoint, DebugState, FunctionBreakpoint, InstructionBreakpoint, ThreadState,
+};
+use crate::debug::thread_eval;
+use crate::debug::webhook;
+use crate::debug::{BreakpointWaitEnd, DebugSession, FileStepEnd, LineStepEnd, SessionManager};
+use crate::process::{discovery, ProcessInfo};
+use crate::{config, log_level, Error, Result};
 use serde::Deserialize;
 use serde_json::{json, Value};
+use std::collections::HashMap;
 use std::sync::Arc;
 use tokio::sync::RwLock;
+use tracing::{info, warn, Instrument};
 
 #[derive(Debug, Deserialize)]
 #[serde(rename_all = "camelCase")]
 pub struct DebuggerStartArgs {
     pub language: String,
+    /// Program to launch (required unless mode is "attach")
+    #[serde(default)]
     pub program: String,
     #[serde(default)]
     pub args: Vec<String>,
     pub cwd: Option<String>,
     #[serde(default)]
     pub stop_on_entry: bool,
+    /// Continue from the entry stop without a debugger_continue (see
+    /// `DebugSession::continue_from_entry`)
+    #[serde(default)]
+    pub auto_continue_from_entry: bool,
+    /// Interval for adapter keep-alive pings in milliseconds (None or 0 = disabled)
+    pub keep_alive_interval_ms: Option<u64>,
+    /// Interval for polling for missed stop events in milliseconds (None or 0 = disabled)
+    pub stop_poll_interval_ms: Option<u64>,
+    /// "launch" (default) or "attach"
+    pub mode: Option<String>,
+    /// Python module to run (`python -m`); shorthand for mode "module"
+    pub module: Option<String>,
+    /// Extra environment variables for the debuggee (python only)
+    #[serde(default)]
+    pub env: HashMap<String, String>,
+    /// Pid to attach to (attach mode)
+    pub process_id: Option<u32>,
+    /// Name or command line substring of the process to attach to (attach mode)
+    pub process_name: Option<String>,
+    /// Go-only launch options (substitutePath)
+    #[serde(default)]
+    pub go_options: GoLaunchOptions,
+    /// Ruby-only launch options (bundler, rails)
+    #[serde(default)]
+    pub ruby_options: RubyLaunchOptions,
+    /// Java-only options (main class, class path, JVM args; JDWP address to
+    /// attach to)
+    #[serde(default)]
+    pub java_options: JavaLaunchOptions,
+    /// Keep the adapter alive after a restart-intent disconnect for reuse (go only)
+    #[serde(default)]
+    pub keep_adapter_warm: bool,
+    /// How long a warm adapter waits for the next start (default 30s)
+    pub warm_grace_period_ms: Option<u64>,
+    /// Snapshot exception, stack and locals whenever the program stops on an exception
+    #[serde(default)]
+    pub capture_on_exception: bool,
+    /// Where to stop first: "user_main" is the start of the program's own code
+    pub entry: Option<String>,
+    /// Check breakpoint sources against the program (see `source_check`)
+    #[serde(default)]
+    pub verify_source: bool,
+    /// Interpreter to run the program with instead of `python` (python only)
+    pub python_path: Option<String>,
+    /// Debug only the program's own code (python only)
+    pub just_my_code: Option<bool>,
+    /// `go` binary to build the program with instead of `go` from PATH (go only)
+    pub go_path: Option<String>,
+    /// Where to POST stop, exit and crash events (see `webhook`)
+    pub webhook_url: Option<String>,
+    /// Stop on uncaught exceptions or let the program terminate; defaults to
+    /// stopping where the debugger can
+    pub on_uncaught: Option<OnUncaught>,
+    /// Relaunch when the program's sources change (see `file_watch`)
+    #[serde(default)]
+    pub watch: bool,
+    /// Quiet time after a change before relaunching
+    pub watch_debounce_ms: Option<u64>,
+    /// Attach without the means to change the process (see `read_only`)
+    #[serde(default)]
+    pub read_only: bool,
+    /// Argv the adapter runs through, in another container or namespace
+    /// (see `exec_prefix`)
+    #[serde(default)]
+    pub target_exec_prefix: Vec<String>,
+}
+
+impl DebuggerStartArgs {
+    /// The `onUncaught` behavior in effect, checked against the language and
+    /// `captureOnException`
+    fn on_uncaught(&self) -> Result<OnUncaught> {
+        let mode = resolve_on_uncaught(&self.language, self.on_uncaught)?;
+        if mode == OnUncaught::Terminate && self.capture_on_exception {
+            return Err(Error::InvalidRequest(
+                "captureOnException needs exception stops, which onUncaught 'terminate' skips; use onUncaught 'break'".to_string(),
+            ));
+        }
+        Ok(mode)
+    }
 }
 
+/// Default grace period for a parked warm adapter
+const DEFAULT_WARM_GRACE_PERIOD_MS: u64 = 30_000;
+
 #[derive(Debug, Deserialize)]
 #[serde(rename_all = "camelCase")]
 pub struct SetBreakpointArgs {
     pub session_id: String,
     pub source_path: String,
     pub line: i32,
+    /// Makes the breakpoint a logpoint that logs this message instead of stopping
+    pub log_message: Option<String>,
+    /// Stop only when this expression is true
+    pub condition: Option<String>,
+    /// Stop only on hits matching this count expression (e.g. ">= 3")
+    pub hit_condition: Option<String>,
+    /// Remove the breakpoint after the first stop it causes
+    #[serde(default)]
+    pub temporary: bool,
+    /// Go only: stop only goroutines carrying this pprof label
+    pub goroutine_label: Option<GoroutineLabel>,
+    /// Stop only when called from a function matching this pattern
+    pub caller: Option<String>,
+    /// Callers the pattern is checked against, nearest first (default 1)
+    pub caller_depth: Option<usize>,
+    /// Keep the conditional breakpoints already on the line; the program
+    /// stops when any condition holds
+    #[serde(default)]
+    pub additional: bool,
+    /// Remove the breakpoint with `condition` on the line, or all of the
+    /// line's breakpoints without one
+    #[serde(default)]
+    pub remove: bool,
 }
 
 #[derive(Debug, Deserialize)]
 #[serde(rename_all = "camelCase")]
-pub struct ContinueArgs {
+pub struct WatchChangeArgs {
     pub session_id: String,
+    pub expression: String,
+    /// Line the expression is read at
+    pub source_path: String,
+    pub line: i32,
+    /// Unchanged hits continued past by a server-side watch
+    pub max_auto_continues: Option<u64>,
 }
 
 #[derive(Debug, Deserialize)]
 #[serde(rename_all = "camelCase")]
-pub struct StackTraceArgs {
+pub struct SetInstructionBreakpointArgs {
     pub session_id: String,
+    /// Memory reference, e.g. a frame's instructionPointerReference
+    pub instruction_reference: String,
+    /// Byte offset from the reference (may be negative)
+    pub offset: Option<i64>,
+    pub condition: Option<String>,
+    pub hit_condition: Option<String>,
+    /// Remove the breakpoint at this address instead of setting one
+    #[serde(default)]
+    pub remove: bool,
 }
 
 #[derive(Debug, Deserialize)]
 #[serde(rename_all = "camelCase")]
-pub struct EvaluateArgs {
+pub struct SetFunctionBreakpointArgs {
     pub session_id: String,
-    pub expression: String,
-    pub frame_id: Option<i32>,
+    /// Function name as the adapter understands it, e.g. `main.handler`
+    pub name: String,
+    pub condition: Option<String>,
+    pub hit_condition: Option<String>,
+    /// Remove the breakpoint on this function instead of setting one
+    #[serde(default)]
+    pub remove: bool,
 }
 
 #[derive(Debug, Deserialize)]
 #[serde(rename_all = "camelCase")]
-pub struct DisconnectArgs {
+pub struct DisassembleArgs {
     pub session_id: String,
+    /// Defaults to the stopped thread's current instruction
+    pub memory_reference: Option<String>,
+    /// Defaults to centering the window on the current instruction, or 0
+    /// with an explicit memoryReference
+    pub instruction_offset: Option<i64>,
+    /// Capped at `MAX_INSTRUCTIONS`
+    pub instruction_count: Option<i64>,
 }
 
 #[derive(Debug, Deserialize)]
 #[serde(rename_all = "camelCase")]
-pub struct SessionStateArgs {
+pub struct ScrollDisassemblyArgs {
     pub session_id: String,
 }
 
 #[derive(Debug, Deserialize)]
 #[serde(rename_all = "camelCase")]
-pub struct WaitForStopArgs {
+pub struct GetCapabilitiesArgs {
     pub session_id: String,
-    #[serde(default = "default_timeout")]
-    pub timeout_ms: u64,
 }
 
-fn default_timeout() -> u64 {
-    5000
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SetExceptionBreakpointsArgs {
+    pub session_id: String,
+    /// `raised` and/or `uncaught`; empty turns exception breakpoints off
+    #[serde(default)]
+    pub modes: Vec<String>,
+    /// DAP exception options, for adapters with `supportsExceptionOptions`
+    #[serde(default)]
+    pub exception_options: Vec<ExceptionOptions>,
 }
 
 #[derive(Debug, Deserialize)]
 #[serde(rename_all = "camelCase")]
-pub struct ListBreakpointsArgs {
+pub struct ConfigureArgs {
     pub session_id: String,
+    /// Settings to change (see `debug::settings`); empty just reports them
+    #[serde(default)]
+    pub settings: serde_json::Map<String, Value>,
 }
 
 #[derive(Debug, Deserialize)]
 #[serde(rename_all = "camelCase")]
-pub struct StepArgs {
+pub struct ContinueArgs {
     pub session_id: String,
+    /// Thread to resume (defaults to the stopped thread)
     pub thread_id: Option<i32>,
 }
 
-pub struct ToolsHandler {
-    session_manager: Arc<RwLock<SessionManager>>,
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ContinuePastArgs {
+    pub session_id: String,
+    pub breakpoint_id: i32,
+    /// The hit of the breakpoint to stop at
+    pub count: u32,
+    /// How long to wait (None = the session's `waitForStopTimeoutMs`)
+    pub timeout_ms: Option<u64>,
 }
 
-impl ToolsHandler {
-    pub fn new(session_manager: Arc<RwLock<SessionManager>>) -> Self {
-        Self { session_manager }
-    }
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct StackTraceArgs {
+    pub session_id: String,
+    /// Thread to inspect (defaults to the stopped thread)
+    pub thread_id: Option<i32>,
+    /// Index of the first frame to return (0 is the innermost)
+    #[serde(default)]
+    pub start_frame: i32,
+    /// Frames to return, capped at `MAX_STACK_LEVELS`
+    pub levels: Option<i32>,
+}
 
-    pub async fn handle_tool(&self, name: &str, arguments: Value) -> Result<Value> {
-        match name {
-            "debugger_start" => self.debugger_start(arguments).await,
-            "debugger_session_state" => self.debugger_session_state(arguments).await,
-            "debugger_set_breakpoint" => self.debugger_set_breakpoint(arguments).await,
-            "debugger_continue" => self.debugger_continue(arguments).await,
-            "debugger_stack_trace" => self.debugger_stack_trace(arguments).await,
-            "debugger_evaluate" => self.debugger_evaluate(arguments).await,
-            "debugger_disconnect" => self.debugger_disconnect(arguments).await,
-            "debugger_wait_for_stop" => self.debugger_wait_for_stop(arguments).await,
-            "debugger_list_breakpoints" => self.debugger_list_breakpoints(arguments).await,
-            "debugger_step_over" => self.debugger_step_over(arguments).await,
-            "debugger_step_into" => self.debugger_step_into(arguments).await,
-            "debugger_step_out" => self.debugger_step_out(arguments).await,
-            _ => Err(Error::MethodNotFound(name.to_string())),
-        }
-    }
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct FindFrameArgs {
+    pub session_id: String,
+    /// Function name, optionally qualified (`handler`, `main.handler`)
+    pub function: String,
+    /// Thread to search (defaults to the stopped thread)
+    pub thread_id: Option<i32>,
+    /// Frames searched from the innermost, capped at `MAX_STACK_LEVELS`
+    pub levels: Option<i32>,
+}
 
-    async fn debugger_start(&self, arguments: Value) -> Result<Value> {
-        let args: DebuggerStartArgs = serde_json::from_value(arguments)?;
-
-        // Validate program path to prevent path traversal attacks
-        // For Rust, validate with .rs extension; for others, allow any file
-        let extension = match args.language.as_str() {
-            "rust" => Some("rs"),
-            "python" => Some("py"),
-            "ruby" => Some("rb"),
-            "javascript" | "nodejs" => Some("js"),
-            "go" => Some("go"),
-            _ => None,
-        };
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct EvaluateArgs {
+    pub session_id: String,
+    pub expression: String,
+    pub frame_id: Option<i32>,
+    /// Thread whose top frame is used when frameId is omitted
+    pub thread_id: Option<i32>,
+    /// Evaluate where the adapter can't run code with side effects
+    #[serde(default)]
+    pub no_side_effects: bool,
+}
 
-        let validated_program = security::validate_source_path(&args.program, extension)?;
-        let program = validated_program
-            .to_str()
-            .ok_or_else(|| Error::Internal("Non-UTF8 program path (invalid encoding)".to_string()))?
-            .to_string();
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct EvaluateAllThreadsArgs {
+    pub session_id: String,
+    pub expression: String,
+    /// Most stopped threads to evaluate in (`thread_eval::DEFAULT_MAX_THREADS`)
+    pub max_threads: Option<usize>,
+    #[serde(default)]
+    pub no_side_effects: bool,
+}
 
-        // Validate cwd if provided
-        let validated_cwd = if let Some(cwd_path) = &args.cwd {
-            let validated = security::validate_directory_path(cwd_path)?;
-            Some(
-                validated
-                    .to_str()
-                    .ok_or_else(|| {
-                        Error::Internal("Non-UTF8 cwd path (invalid encoding)".to_string())
-                    })?
-                    .to_string(),
-            )
-        } else {
-            None
-        };
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct CompareSessionsArgs {
+    /// The two sessions to compare
+    pub session_ids: Vec<String>,
+    pub expression: String,
+    #[serde(default)]
+    pub no_side_effects: bool,
+}
 
-        let manager = self.session_manager.read().await;
-        let session_id = manager
-            .create_session(
-                &args.language,
-                program,
-                args.args,
-                validated_cwd,
-                args.stop_on_entry,
-            )
-            .await?;
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ReplOpenArgs {
+    pub session_id: String,
+    pub frame_id: Option<i32>,
+    /// Thread whose top frame is used when frameId is omitted
+    pub thread_id: Option<i32>,
+}
 
-        Ok(json!({
-            "sessionId": session_id,
-            "status": "started"
-        }))
-    }
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ReplEvalArgs {
+    pub repl_id: String,
+    pub input: String,
+}
 
-    async fn debugger_session_state(&self, arguments: Value) -> Result<Value> {
-        let args: SessionStateArgs = serde_json::from_value(arguments)?;
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ReplHistoryArgs {
+    pub repl_id: String,
+}
 
-        let manager = self.session_manager.read().await;
-        let state = manager.get_session_state(&args.session_id).await?;
-
-        // Convert DebugState to JSON-friendly format
-        let (state_str, details) = match state {
-            crate::debug::state::DebugState::NotStarted => ("NotStarted", json!({})),
-            crate::debug::state::DebugState::Initializing => ("Initializing", json!({})),
-            crate::debug::state::DebugState::Initialized => ("Initialized", json!({})),
-            crate::debug::state::DebugState::Launching => ("Launching", json!({})),
-            crate::debug::state::DebugState::Running => ("Running", json!({})),
-            crate::debug::state::DebugState::Stopped { thread_id, reason } => (
-                "Stopped",
-                json!({
-                    "threadId": thread_id,
-                    "reason": reason
-                }),
-            ),
-            crate::debug::state::DebugState::Terminated => ("Terminated", json!({})),
-            crate::debug::state::DebugState::Failed { error } => (
-                "Failed",
-                json!({
-                    "error": error
-                }),
-            ),
-        };
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ListThreadsArgs {
+    pub session_id: String,
+    /// Go only: read the pprof labels of each stopped goroutine
+    #[serde(default)]
+    pub labels: bool,
+}
 
-        Ok(json!({
-            "sessionId": args.session_id,
-            "state": state_str,
-            "details": details
-        }))
-    }
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ListGoroutinesArgs {
+    pub session_id: String,
+    /// Goroutines to skip, from a previous page's nextOffset
+    #[serde(default)]
+    pub offset: usize,
+    /// Goroutines per page
+    pub limit: Option<usize>,
+}
 
-    async fn debugger_set_breakpoint(&self, arguments: Value) -> Result<Value> {
-        let args: SetBreakpointArgs = serde_json::from_value(arguments)?;
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SourceContextArgs {
+    pub session_id: String,
+    /// Frame whose source to show (from debugger_stack_trace)
+    pub frame_id: Option<i32>,
+    /// Source path, used when frameId is omitted
+    pub path: Option<String>,
+    /// Line to center on (defaults to the frame's line)
+    pub line: Option<i32>,
+    #[serde(default = "default_context_lines")]
+    pub context_lines: usize,
+}
 
-        // Validate source path to prevent path traversal
-        // Note: We validate without extension requirement since breakpoints
-        // can be set in any source file regardless of language
-        let validated_source = security::validate_source_path(&args.source_path, None)?;
-        let source_path = validated_source
-            .to_str()
-            .ok_or_else(|| Error::Internal("Non-UTF8 source path (invalid encoding)".to_string()))?
-            .to_string();
+fn default_context_lines() -> usize {
+    5
+}
 
-        let manager = self.session_manager.read().await;
-        let session = manager.get_session(&args.session_id).await?;
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct AnalyzeHangArgs {
+    pub session_id: String,
+}
 
-        let verified = session
-            .set_breakpoint(source_path.clone(), args.line)
-            .await?;
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ListAsyncTasksArgs {
+    pub session_id: String,
+}
 
-        Ok(json!({
-            "verified": verified,
-            "sourcePath": source_path,
-            "line": args.line
-        }))
-    }
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct InlineValuesArgs {
+    pub session_id: String,
+    /// Frame to show values for (from debugger_stack_trace)
+    pub frame_id: i32,
+}
 
-    async fn debugger_continue(&self, arguments: Value) -> Result<Value> {
-        let args: ContinueArgs = serde_json::from_value(arguments)?;
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct PeekArgs {
+    pub session_id: String,
+    /// Plain variable or field names (`total`, `user.name`)
+    pub names: Vec<String>,
+    /// Frame to look in (defaults to the stopped thread's top frame)
+    pub frame_id: Option<i32>,
+}
 
-        let manager = self.session_manager.read().await;
-        let session = manager.get_session(&args.session_id).await?;
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct DumpVariableArgs {
+    pub session_id: String,
+    /// Variable name or path, as for debugger_peek (`order`, `user.orders[0]`)
+    pub path: String,
+    /// Frame to look in (defaults to the stopped thread's top frame)
+    pub frame_id: Option<i32>,
+    pub max_depth: Option<usize>,
+    pub max_nodes: Option<usize>,
+}
 
-        session.continue_execution().await?;
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct DereferenceArgs {
+    pub session_id: String,
+    /// Expression yielding a pointer (`c`, `node.next`)
+    pub expression: Option<String>,
+    /// Variables reference of a pointer from debugger_variables, instead of
+    /// an expression
+    pub variables_reference: Option<i32>,
+    /// Frame to evaluate in (defaults to the stopped thread's top frame)
+    pub frame_id: Option<i32>,
+    pub max_depth: Option<usize>,
+    pub max_nodes: Option<usize>,
+}
 
-        Ok(json!({
-            "status": "continued"
-        }))
-    }
+/// debugger_dereference's response for `c` in `(*Calculator).Multiply` of
+/// tests/fixtures/go/multifile, shown in its description. A unit test
+/// checks it against the handler's response, the Go integration tests
+/// against Delve's
+pub const DEREFERENCE_EXAMPLE: &str = r#"{"frameId": 1000, "expression": "c", "pointer": "(*main.Calculator)(0xc000014070)", "type": "*main.Calculator", "nil": false, "value": {"Name": "TestCalc", "Version": "1.0"}, "nodes": 3, "truncated": false}"#;
 
-    async fn debugger_stack_trace(&self, arguments: Value) -> Result<Value> {
-        let args: StackTraceArgs = serde_json::from_value(arguments)?;
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct StartGroupArgs {
+    /// debugger_start arguments plus a member `name`, one per debuggee
+    pub configs: Vec<Value>,
+}
 
-        let manager = self.session_manager.read().await;
-        let session = manager.get_session(&args.session_id).await?;
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct GroupArgs {
+    pub group_id: String,
+}
 
-        // Validate we're in a stopped state
-        let state = session.get_state().await;
-        if !matches!(state, crate::debug::state::DebugState::Stopped { .. }) {
-            return Err(Error::InvalidState(
-                "Cannot get stack trace while program is running. The program must be stopped at a breakpoint, entry point, or step. Use debugger_wait_for_stop() to wait for the program to stop.".to_string()
-            ));
-        }
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct GroupContinueArgs {
+    pub group_id: String,
+    /// Block until a member stops or every member ends
+    #[serde(default)]
+    pub wait_for_stop: bool,
+    /// How long to wait (None = the server's `wait_for_stop_ms`)
+    pub timeout_ms: Option<u64>,
+}
 
-        let frames = session.stack_trace().await?;
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ScopesArgs {
+    pub session_id: String,
+    /// Frame to list scopes of (from debugger_stack_trace)
+    pub frame_id: i32,
+}
 
-        Ok(json!({
-            "stackFrames": frames
-        }))
-    }
-
-    async fn debugger_evaluate(&self, arguments: Value) -> Result<Value> {
-        let args: EvaluateArgs = serde_json::from_value(arguments)?;
-
-        let manager = self.session_manager.read().await;
-        let session = manager.get_session(&args.session_id).await?;
-
-        // Validate we're in a stopped state
-        let state = session.get_state().await;
-        if !matches!(state, crate::debug::state::DebugState::Stopped { .. }) {
-            return Err(Error::InvalidState(
-                "Cannot evaluate expressions while program is running. The program must be stopped at a breakpoint, entry point, or step. Use debugger_wait_for_stop() to wait for the program to stop.".to_string()
-            ));
-        }
-
-        let result = session.evaluate(&args.expression, args.frame_id).await?;
-
-        Ok(json!({
-            "result": result
-        }))
-    }
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct DisconnectArgs {
+    pub session_id: String,
+    /// The program is about to be started again; keep a warm adapter alive
+    #[serde(default)]
+    pub restart: bool,
+}
 
-    async fn debugger_wait_for_stop(&self, arguments: Value) -> Result<Value> {
-        let args: WaitForStopArgs = serde_json::from_value(arguments)?;
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct RestartArgs {
+    pub session_id: String,
+}
 
-        let manager = self.session_manager.read().await;
-        let session = manager.get_session(&args.session_id).await?;
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SessionStateArgs {
+    pub session_id: String,
+    /// Wait up to this long for the state to change before answering
+    #[serde(default)]
+    pub block_for_ms: Option<u64>,
+}
 
-        let timeout = tokio::time::Duration::from_millis(args.timeout_ms);
-        let start = tokio::time::Instant::now();
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct IsStoppedArgs {
+    pub session_id: String,
+}
 
-        loop {
-            let state = session.get_state().await;
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct EventsArgs {
+    pub session_id: String,
+    /// eventsSeq the client last saw (0: everything kept)
+    #[serde(default)]
+    pub since_seq: u64,
+    /// Wait up to this long for an event if there is none after sinceSeq
+    #[serde(default)]
+    pub block_for_ms: Option<u64>,
+}
 
-            // Check if we're stopped
-            if let crate::debug::state::DebugState::Stopped { thread_id, reason } = state {
-                return Ok(json!({
-                    "state": "Stopped",
-                    "threadId": thread_id,
-                    "reason": reason
-                }));
-            }
+/// Upper bound on `blockForMs` of debugger_session_state and debugger_events
+const MAX_BLOCK_FOR_MS: u64 = 30_000;
 
-            // Check if program terminated
-            if matches!(state, crate::debug::state::DebugState::Terminated) {
-                return Ok(json!({
-                    "state": "Terminated",
-                    "reason": "Program exited"
-                }));
-            }
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct WaitForStopArgs {
+    pub session_id: String,
+    /// Defaults to the session's waitForStopTimeoutMs
+    pub timeout_ms: Option<u64>,
+}
 
-            // Check if program failed
-            if let crate::debug::state::DebugState::Failed { error } = state {
-                return Err(Error::Dap(format!("Session failed: {}", error)));
-            }
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ListBreakpointsArgs {
+    pub session_id: String,
+}
 
-            // Check timeout
-            if start.elapsed() > timeout {
-                return Err(Error::InvalidState(format!(
-                    "Timeout waiting for program to stop ({}ms). Current state: {:?}",
-                    args.timeout_ms, state
-                )));
-            }
+/// Arguments of debugger_subscribe and debugger_unsubscribe
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SubscriptionArgs {
+    pub session_id: String,
+    #[serde(default)]
+    pub breakpoint_ids: Option<Vec<i32>>,
+    /// Every breakpoint, instead of `breakpoint_ids`
+    #[serde(default)]
+    pub all: bool,
+}
 
-            // Sleep briefly before checking again
-            tokio::time::sleep(tokio::time::Duration::from_millis(50)).await;
+impl SubscriptionArgs {
+    /// The breakpoints named, None for all of them
+    fn ids(&self) -> Result<Option<&[i32]>> {
+        match (&self.breakpoint_ids, self.all) {
+            (Some(ids), false) => Ok(Some(ids)),
+            (None, true) => Ok(None),
+            _ => Err(Error::InvalidRequest(
+                "Give either breakpointIds or all: true".to_string(),
+            )),
         }
     }
+}
 
-    async fn debugger_list_breakpoints(&self, arguments: Value) -> Result<Value> {
-        let args: ListBreakpointsArgs = serde_json::from_value(arguments)?;
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct LastHitBreakpointsArgs {
+    pub session_id: String,
+}
 
-        let manager = self.session_manager.read().await;
-        let session = manager.get_session(&args.session_id).await?;
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ToggleBreakpointArgs {
+    pub session_id: String,
+    pub breakpoint_id: i32,
+}
 
-        let full_state = session.get_full_state().await;
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct GetMetricsArgs {
+    /// Also report this session's metrics (only the aggregate when omitted)
+    pub session_id: Option<String>,
+}
 
-        // Collect all breakpoints from all source files
-        let mut all_breakpoints = Vec::new();
-        for (source_path, breakpoints) in full_state.breakpoints.iter() {
-            for bp in breakpoints {
-                all_breakpoints.push(json!({
-                    "id": bp.id,
-                    "verified": bp.verified,
-                    "line": bp.line,
-                    "sourcePath": source_path
-                }));
-            }
-        }
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct EntryPointArgs {
+    pub session_id: String,
+}
 
-        Ok(json!({
-            "breakpoints": all_breakpoints
-        }))
-    }
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct WatchStopArgs {
+    pub session_id: String,
+}
 
-    async fn debugger_step_over(&self, arguments: Value) -> Result<Value> {
-        let args: StepArgs = serde_json::from_value(arguments)?;
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct GetOutputArgs {
+    pub session_id: String,
+    /// Return the bytes kept base64-encoded instead of as text. They are
+    /// the UTF-8 the debugger sent, not the program's raw bytes
+    #[serde(default)]
+    pub base64: bool,
+    /// Return only the last this many bytes
+    pub max_bytes: Option<usize>,
+}
 
-        let manager = self.session_manager.read().await;
-        let session = manager.get_session(&args.session_id).await?;
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct GetPostMortemArgs {
+    /// A session whose program ended, possibly disconnected since
+    pub session_id: String,
+}
 
-        // Validate we're in a stopped state
-        let state = session.get_state().await;
-        let thread_id = if let crate::debug::state::DebugState::Stopped { thread_id, .. } = state {
-            thread_id
-        } else {
-            return Err(Error::InvalidState(
-                "Cannot step while program is running. The program must be stopped first."
-                    .to_string(),
-            ));
-        };
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ResourceUsageArgs {
+    pub session_id: String,
+}
 
-        let thread_id = args.thread_id.unwrap_or(thread_id);
-        session.step_over(thread_id).await?;
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct WebhookDeliveriesArgs {
+    pub session_id: String,
+}
 
-        Ok(json!({
-            "status": "stepping",
-            "threadId": thread_id
-        }))
-    }
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SetLogLevelArgs {
+    /// trace, debug, info, warn or error
+    pub level: String,
+    /// Set the level for this session only (global when omitted)
+    pub session_id: Option<String>,
+}
 
-    async fn debugger_step_into(&self, arguments: Value) -> Result<Value> {
-        let args: StepArgs = serde_json::from_value(arguments)?;
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ExportBreakpointsArgs {
+    pub session_id: String,
+}
 
-        let manager = self.session_manager.read().await;
-        let session = manager.get_session(&args.session_id).await?;
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SaveConfigArgs {
+    pub name: String,
+    /// Session to capture the start arguments and breakpoints of
+    pub session_id: Option<String>,
+    /// debugger_start arguments (instead of a session's)
+    pub start: Option<serde_json::Map<String, Value>>,
+    /// Breakpoints (instead of the session's)
+    pub breakpoints: Option<Vec<BreakpointEntry>>,
+    #[serde(default)]
+    pub watches: Vec<String>,
+    /// Scope the config to this workspace directory
+    pub workspace: Option<String>,
+}
 
-        // Validate we're in a stopped state
-        let state = session.get_state().await;
-        let thread_id = if let crate::debug::state::DebugState::Stopped { thread_id, .. } = state {
-            thread_id
-        } else {
-            return Err(Error::InvalidState(
-                "Cannot step while program is running. The program must be stopped first."
-                    .to_string(),
-            ));
-        };
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct LoadConfigArgs {
+    pub name: String,
+    pub workspace: Option<String>,
+}
 
-        let thread_id = args.thread_id.unwrap_or(thread_id);
-        session.step_into(thread_id).await?;
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct StartFromLaunchJsonArgs {
+    /// Path of the launch.json
+    pub path: String,
+    pub configuration_name: String,
+    /// Variable values (`workspaceFolder`, `file`) and attributes replacing
+    /// the configuration's (see `launch_json`)
+    #[serde(default)]
+    pub overrides: serde_json::Map<String, Value>,
+}
 
-        Ok(json!({
-            "status": "stepping",
-            "threadId": thread_id
-        }))
-    }
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ExportSessionArgs {
+    pub session_id: String,
+}
 
-    async fn debugger_step_out(&self, arguments: Value) -> Result<Value> {
-        let args: StepArgs = serde_json::from_value(arguments)?;
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ImportBreakpointsArgs {
+    pub session_id: String,
+    /// Document previously returned by debugger_export_breakpoints
+    pub document: BreakpointDocument,
+}
 
-        let manager = self.session_manager.read().await;
-        let session = manager.get_session(&args.session_id).await?;
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct StepArgs {
+    pub session_id: String,
+    pub thread_id: Option<i32>,
+    pub granularity: Option<SteppingGranularity>,
+}
 
-        // Validate we're in a stopped state
-        let state = session.get_state().await;
-        let thread_id = if let crate::debug::state::DebugState::Stopped { thread_id, .. } = state {
-            thread_id
-        } else {
-            return Err(Error::InvalidState(
-                "Cannot step while program is running. The program must be stopped first."
-                    .to_string(),
-            ));
-        };
+/// Default and upper bound on `maxSteps` of debugger_step_out_of_file
+const DEFAULT_FILE_STEPS: usize = 100;
+const MAX_FILE_STEPS: usize = 1000;
 
-        let thread_id = args.thread_id.unwrap_or(thread_id);
-        session.step_out(thread_id).await?;
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct StepOutOfFileArgs {
+    pub session_id: String,
+    pub thread_id: Option<i32>,
+    pub max_steps: Option<usize>,
+}
 
-        Ok(json!({
-            "status": "stepping",
-            "threadId": thread_id
-        }))
-    }
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct StepToLineArgs {
+    pub session_id: String,
+    /// Line of the current function to stop on
+    pub line: i32,
+    pub thread_id: Option<i32>,
+    pub max_steps: Option<usize>,
+}
 
-    async fn debugger_disconnect(&self, arguments: Value) -> Result<Value> {
-        let args: DisconnectArgs = serde_json::from_value(arguments)?;
+/// Default and upper bound on `maxAutoContinues` of debugger_wait_for_breakpoint
+const DEFAULT_BREAKPOINT_AUTO_CONTINUES: u64 = 100;
+const MAX_BREAKPOINT_AUTO_CONTINUES: u64 = 10_000;
 
-        let manager = self.session_manager.write().await;
-        manager.remove_session(&args.session_id).await?;
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct WaitForBreakpointArgs {
+    pub session_id: String,
+    pub breakpoint_id: i32,
+    /// Continue through stops at other breakpoints (default true)
+    pub auto_continue: Option<bool>,
+    pub max_auto_continues: Option<u64>,
+    /// Defaults to the session's waitForStopTimeoutMs
+    pub timeout_ms: Option<u64>,
+}
+
+pub struct ToolsHandler {
+    session_manager: Arc<RwLock<SessionManager>>,
+}
+
+impl ToolsHandler {
+    pub fn new(session_manager: Arc<RwLock<SessionManager>>) -> Self {
+        Self { session_manager }
+    }
+
+    /// Run a tool, in its session's log span when it names one (see `log_level`)
+    pub async fn handle_tool(&self, name: &str, arguments: Value) -> Result<Value> {
+        let span = log_level::session_span(arguments.get("sessionId").and_then(Value::as_str));
+        self.dispatch_tool(name, arguments).instrument(span).await
+    }
+
+    async fn dispatch_tool(&self, name: &str, arguments: Value) -> Result<Value> {
+        match name {
+            "debugger_start" => self.debugger_start(arguments).await,
+            "debugger_start_group" => self.debugger_start_group(arguments).await,
+            "debugger_group_continue" => self.debugger_group_continue(arguments).await,
+            "debugger_group_state" => self.debugger_group_state(arguments).await,
+            "debugger_group_disconnect" => self.debugger_group_disconnect(arguments).await,
+            "debugger_session_state" => self.debugger_session_state(arguments).await,
+            "debugger_is_stopped" => self.debugger_is_stopped(arguments).await,
+            "debugger_events" => self.debugger_events(arguments).await,
+            "debugger_configure" => self.debugger_configure(arguments).await,
+            "debugger_set_breakpoint" => self.debugger_set_breakpoint(arguments).await,
+            "debugger_set_instruction_breakpoint" => {
+                self.debugger_set_instruction_breakpoint(arguments).await
+            }
+            "debugger_set_function_breakpoint" => {
+                self.debugger_set_function_breakpoint(arguments).await
+            }
+            "debugger_set_exception_breakpoints" => {
+                self.debugger_set_exception_breakpoints(arguments).await
+            }
+            "debugger_get_capabilities" => self.debugger_get_capabilities(arguments).await,
+            "debugger_disassemble" => self.debugger_disassemble(arguments).await,
+            "debugger_disassemble_next" => self.debugger_scroll_disassembly(arguments, true).await,
+            "debugger_disassemble_prev" => self.debugger_scroll_disassembly(arguments, false).await,
+            "debugger_continue" => self.debugger_continue(arguments).await,
+            "debugger_continue_past" => self.debugger_continue_past(arguments).await,
+            "debugger_stack_trace" => self.debugger_stack_trace(arguments).await,
+            "debugger_watch_change" => self.debugger_watch_change(arguments).await,
+            "debugger_find_frame" => self.debugger_find_frame(arguments).await,
+            "debugger_entry_point" => self.debugger_entry_point(arguments).await,
+            "debugger_evaluate" => self.debugger_evaluate(arguments).await,
+            "debugger_evaluate_all_threads" => self.debugger_evaluate_all_threads(arguments).await,
+            "debugger_compare_sessions" => self.debugger_compare_sessions(arguments).await,
+            "debugger_repl_open" => self.debugger_repl_open(arguments).await,
+            "debugger_repl_eval" => self.debugger_repl_eval(arguments).await,
+            "debugger_repl_history" => self.debugger_repl_history(arguments).await,
+            "debugger_disconnect" => self.debugger_disconnect(arguments).await,
+            "debugger_restart" => self.debugger_restart(arguments).await,
+            "debugger_wait_for_stop" => self.debugger_wait_for_stop(arguments).await,
+            "debugger_wait_for_breakpoint" => self.debugger_wait_for_breakpoint(arguments).await,
+            "debugger_list_threads" => self.debugger_list_threads(arguments).await,
+            "debugger_list_goroutines" => self.debugger_list_goroutines(arguments).await,
+            "debugger_source_context" => self.debugger_source_context(arguments).await,
+            "debugger_inline_values" => self.debugger_inline_values(arguments).await,
+            "debugger_scopes" => self.debugger_scopes(arguments).await,
+            "debugger_peek" => self.debugger_peek(arguments).await,
+            "debugger_dump_variable" => self.debugger_dump_variable(arguments).await,
+            "debugger_dereference" => self.debugger_dereference(arguments).await,
+            "debugger_analyze_hang" => self.debugger_analyze_hang(arguments).await,
+            "debugger_list_async_tasks" => self.debugger_list_async_tasks(arguments).await,
+            "debugger_list_breakpoints" => self.debugger_list_breakpoints(arguments).await,
+            "debugger_subscribe" => self.debugger_subscribe(arguments, true).await,
+            "debugger_unsubscribe" => self.debugger_subscribe(arguments, false).await,
+            "debugger_last_hit_breakpoints" => self.debugger_last_hit_breakpoints(arguments).await,
+            "debugger_enable_breakpoint" => self.debugger_toggle_breakpoint(arguments, true).await,
+            "debugger_disable_breakpoint" => {
+                self.debugger_toggle_breakpoint(arguments, false).await
+            }
+            "debugger_export_breakpoints" => self.debugger_export_breakpoints(arguments).await,
+            "debugger_export_session" => self.debugger_export_session(arguments).await,
+            "debugger_import_breakpoints" => self.debugger_import_breakpoints(arguments).await,
+            "debugger_save_config" => self.debugger_save_config(arguments).await,
+            "debugger_load_config" => self.debugger_load_config(arguments).await,
+            "debugger_start_from_launch_json" => {
+                self.debugger_start_from_launch_json(arguments).await
+            }
+            "debugger_step_over" => self.debugger_step_over(arguments).await,
+            "debugger_step_into" => self.debugger_step_into(arguments).await,
+            "debugger_step_out" => self.debugger_step_out(arguments).await,
+            "debugger_step_out_of_file" => self.debugger_step_out_of_file(arguments).await,
+            "debugger_step_to_line" => self.debugger_step_to_line(arguments).await,
+            "debugger_get_config" => self.debugger_get_config().await,
+            "debugger_set_log_level" => self.debugger_set_log_level(arguments).await,
+            "debugger_get_metrics" => self.debugger_get_metrics(arguments).await,
+            "debugger_get_output" => self.debugger_get_output(arguments).await,
+            "debugger_get_post_mortem" => self.debugger_get_post_mortem(arguments).await,
+            "debugger_resource_usage" => self.debugger_resource_usage(arguments).await,
+            "debugger_watch_stop" => self.debugger_watch_stop(arguments).await,
+            "debugger_webhook_deliveries" => self.debugger_webhook_deliveries(arguments).await,
+            "debugger_info" => self.debugger_info().await,
+            _ => Err(Error::MethodNotFound(name.to_string())),
+        }
+    }
+
+    async fn debugger_start(&self, arguments: Value) -> Result<Value> {
+        let (_hold, gate) = launch_gate();
+        self.start_held(arguments, gate).await
+    }
+
+    /// debugger_start, with the launch waiting at `gate` until its hold is
+    /// dropped
+    async fn start_held(&self, arguments: Value, gate: LaunchGate) -> Result<Value> {
+        // Kept with the session so debugger_save_config can replay the start
+        let start_arguments = arguments.as_object().cloned();
+        let response = self.start_session(arguments, gate, None).await?;
+        if let (Some(start), Some(session_id)) = (start_arguments, response["sessionId"].as_str()) {
+            let manager = self.session_manager.read().await;
+            manager
+                .get_session(session_id)
+                .await?
+                .set_start_arguments(start)
+                .await;
+        }
+        Ok(response)
+    }
+
+    async fn start_session(
+        &self,
+        arguments: Value,
+        gate: LaunchGate,
+        replaces: Option<&str>,
+    ) -> Result<Value> {
+        let mut args: DebuggerStartArgs = serde_json::from_value(arguments)?;
+
+        if let Some(module) = args.module.take() {
+            if args.language != "python" {
+                return Err(Error::InvalidRequest(format!(
+                    "module is only supported for python, not {}",
+                    args.language
+                )));
+            }
+            if !args.program.is_empty() {
+                return Err(Error::InvalidRequest(
+                    "Specify either program or module, not both".to_string(),
+                ));
+            }
+            if !matches!(args.mode.as_deref(), None | Some("module")) {
+                return Err(Error::InvalidRequest(
+                    "module can only be used with mode 'module'".to_string(),
+                ));
+            }
+            args.mode = Some("module".to_string());
+            args.program = module;
+        }
+
+        if args.java_options != JavaLaunchOptions::default() && args.language != "java" {
+            return Err(Error::InvalidRequest(format!(
+                "javaOptions is only supported for java, not {}",
+                args.language
+            )));
+        }
+
+        if !args.env.is_empty() && args.language != "python" {
+            return Err(Error::InvalidRequest(format!(
+                "env is only supported for python, not {}",
+                args.language
+            )));
+        }
+
+        if args.just_my_code.is_some() && args.language != "python" {
+            return Err(Error::InvalidRequest(format!(
+                "justMyCode is only supported for python, not {}",
+                args.language
+            )));
+        }
+
+        for (option, path, language) in [
+            ("pythonPath", &args.python_path, "python"),
+            ("goPath", &args.go_path, "go"),
+        ] {
+            if path.is_some() && args.language != language {
+                return Err(Error::InvalidRequest(format!(
+                    "{} is only supported for {}, not {}",
+                    option, language, args.language
+                )));
+            }
+        }
+
+        let mode = resolve_mode(&args.language, args.mode.as_deref())?;
+        if mode == "attach" {
+            if args.entry.is_some() {
+                return Err(Error::InvalidRequest(
+                    "entry is only supported when launching".to_string(),
+                ));
+            }
+            if args.python_path.is_some() || args.go_path.is_some() {
+                return Err(Error::InvalidRequest(
+                    "pythonPath and goPath are only supported when launching; an attached process keeps its own".to_string(),
+                ));
+            }
+            if args.watch {
+                return Err(Error::InvalidRequest(
+                    "watch is only supported when launching; an attached process can't be relaunched".to_string(),
+                ));
+            }
+            if args.auto_continue_from_entry {
+                return Err(Error::InvalidRequest(
+                    "autoContinueFromEntry is only supported when launching; an attached process has no entry stop".to_string(),
+                ));
+            }
+            if !args.target_exec_prefix.is_empty() {
+                return Err(Error::InvalidRequest(
+                    "targetExecPrefix is only supported when launching".to_string(),
+                ));
+            }
+            return self.debugger_attach(args).await;
+        }
+        if args.read_only {
+            return Err(Error::InvalidRequest(
+                "readOnly is only supported when attaching; a launched program is the agent's own"
+                    .to_string(),
+            ));
+        }
+        if args.auto_continue_from_entry {
+            if !args.stop_on_entry && args.entry.is_none() {
+                return Err(Error::InvalidRequest(
+                    "autoContinueFromEntry continues from the entry stop, so it needs stopOnEntry or entry".to_string(),
+                ));
+            }
+            // vscode-js-debug runs the program in a child session whose stops
+            // the server only forwards
+            if matches!(args.language.as_str(), "javascript" | "nodejs") {
+                return Err(Error::InvalidRequest(format!(
+                    "autoContinueFromEntry is not supported for {}",
+                    args.language
+                )));
+            }
+        }
+        if args.go_path.is_some() && !GoAdapter::builds(mode) {
+            return Err(Error::InvalidRequest(format!(
+                "goPath is only used when Delve builds the program (modes debug and test), not in mode {}",
+                mode
+            )));
+        }
+        if !args.target_exec_prefix.is_empty() {
+            exec_prefix::validate(&args.language, &args.target_exec_prefix)?;
+            // Both are about the server's host, not the target
+            if args.python_path.is_some() || args.go_path.is_some() {
+                return Err(Error::InvalidRequest(
+                    "pythonPath and goPath can't be combined with targetExecPrefix; make the toolchain the target's default or put it in the prefix".to_string(),
+                ));
+            }
+            if args.keep_adapter_warm {
+                return Err(Error::InvalidRequest(
+                    "keepAdapterWarm can't be combined with targetExecPrefix".to_string(),
+                ));
+            }
+        }
+        let toolchain = match (&args.python_path, &args.go_path) {
+            (Some(path), _) => Some(toolchain::python(path).await?),
+            (_, Some(path)) => Some(toolchain::go(path).await?),
+            _ => None,
+        };
+
+        if args.program.is_empty() {
+            return Err(Error::InvalidRequest(
+                "program is required in launch mode".to_string(),
+            ));
+        }
+        let webhook_url =
+            webhook::resolve_url(args.webhook_url.as_deref(), &config::current().webhooks)?;
+        let on_uncaught = args.on_uncaught()?;
+
+        if args.keep_adapter_warm && args.language != "go" {
+            return Err(Error::InvalidRequest(format!(
+                "keepAdapterWarm is only supported for go, not {}",
+                args.language
+            )));
+        }
+
+        if args
+            .go_options
+            .substitute_path
+            .iter()
+            .any(|rule| rule.from.is_empty() || rule.to.is_empty())
+        {
+            return Err(Error::InvalidRequest(
+                "substitutePath rules need non-empty 'from' and 'to'".to_string(),
+            ));
+        }
+
+        if let Some(output) = &args.go_options.output {
+            let dir = std::path::Path::new(output)
+                .parent()
+                .filter(|dir| !dir.as_os_str().is_empty());
+            if output.is_empty() || dir.is_some_and(|dir| !dir.is_dir()) {
+                return Err(Error::InvalidRequest(format!(
+                    "goOptions.output '{}' must be a file path in an existing directory",
+                    output
+                )));
+            }
+        }
+
+        let program = if args.language == "java" && !JavaAdapter::is_jar(&args.program) {
+            // A main class, loaded from javaOptions.classPath
+            JavaAdapter::validate_class_name(&args.program)?;
+            args.program.clone()
+        } else if (args.language.as_str(), mode) == ("python", "module") {
+            // A module name (python -m), not a path
+            validate_module_name(&args.program)?;
+            args.program.clone()
+        } else if (args.language.as_str(), mode) == ("go", "exec") {
+            // A pre-built binary; Delve runs it as-is instead of building
+            security::validate_executable_path(&args.program)?
+                .to_str()
+                .ok_or_else(|| {
+                    Error::Internal("Non-UTF8 program path (invalid encoding)".to_string())
+                })?
+                .to_string()
+        } else {
+            // Validate program path to prevent path traversal attacks
+            // For Rust, validate with .rs extension; for others, allow any file
+            let extension = match (args.language.as_str(), mode) {
+                // Test packages, binaries and pytest targets may be directories
+                // or have no extension
+                ("go", "test") | ("python", "pytest") => None,
+                ("rust", _) => Some("rs"),
+                ("python", _) => Some("py"),
+                // Rails executables (bin/rails) have no extension
+                ("ruby", _) if args.ruby_options.rails => None,
+                ("ruby", _) => Some("rb"),
+                // TypeScript runs as the JavaScript compiled from it
+                ("javascript" | "nodejs", _) if source_maps::is_typescript(&args.program) => None,
+                ("javascript" | "nodejs", _) => Some("js"),
+                ("go", _) => Some("go"),
+                ("java", _) => Some("jar"),
+                _ => None,
+            };
+
+            let validated_program = security::validate_source_path(&args.program, extension)?;
+            validated_program
+                .to_str()
+                .ok_or_else(|| {
+                    Error::Internal("Non-UTF8 program path (invalid encoding)".to_string())
+                })?
+                .to_string()
+        };
+
+        // Class path entries are read by the JVM, so they get the same checks
+        args.java_options.class_path = args
+            .java_options
+            .class_path
+            .iter()
+            .map(|entry| {
+                security::validate_source_path(entry, None)
+                    .map(|path| path.to_string_lossy().into_owned())
+            })
+            .collect::<Result<_>>()?;
+
+        // Validate cwd if provided
+        let validated_cwd = if let Some(cwd_path) = &args.cwd {
+            let validated = security::validate_directory_path(cwd_path)?;
+            Some(
+                validated
+                    .to_str()
+                    .ok_or_else(|| {
+                        Error::Internal("Non-UTF8 cwd path (invalid encoding)".to_string())
+                    })?
+                    .to_string(),
+            )
+        } else {
+            None
+        };
+
+        let entry = args
+            .entry
+            .as_deref()
+            .map(|entry| resolve_entry(&args.language, mode, &program, entry))
+            .transpose()?;
+
+        let debounce_ms = args
+            .watch_debounce_ms
+            .unwrap_or(file_watch::DEFAULT_DEBOUNCE_MS);
+        let watch = if args.watch {
+            if args.language == "java" {
+                return Err(Error::InvalidRequest(
+                    "watch is not supported for java: the program is run pre-built, so a relaunch wouldn't pick up source changes".to_string(),
+                ));
+            }
+            if matches!(
+                (args.language.as_str(), mode),
+                ("python", "module") | ("go", "exec")
+            ) {
+                return Err(Error::InvalidRequest(format!(
+                    "watch needs the program's sources, which mode {} doesn't name",
+                    mode
+                )));
+            }
+            if debounce_ms > file_watch::MAX_DEBOUNCE_MS {
+                return Err(Error::InvalidRequest(format!(
+                    "watchDebounceMs must be at most {}",
+                    file_watch::MAX_DEBOUNCE_MS
+                )));
+            }
+            let files = file_watch::watched_files(&args.language, std::path::Path::new(&program))?;
+            Some(FileWatch::new(
+                files,
+                std::time::Duration::from_millis(debounce_ms),
+            ))
+        } else {
+            None
+        };
+
+        // The adapter's pid in the target, to kill it when the session ends
+        let tracked = (!args.target_exec_prefix.is_empty())
+            .then(|| exec_prefix::Tracked::new(&args.target_exec_prefix));
+        let substitute_path = args.go_options.substitute_path.clone();
+        let manager = self.session_manager.read().await;
+        let options = LaunchOptions {
+            mode: Some(mode),
+            keep_adapter_warm: args.keep_adapter_warm.then(|| {
+                std::time::Duration::from_millis(
+                    args.warm_grace_period_ms
+                        .unwrap_or(DEFAULT_WARM_GRACE_PERIOD_MS),
+                )
+            }),
+            env: args.env,
+            just_my_code: args.just_my_code,
+            go: args.go_options,
+            ruby: args.ruby_options,
+            java: args.java_options.clone(),
+            toolchain: toolchain.clone(),
+            exec_prefix: tracked
+                .as_ref()
+                .map(exec_prefix::Tracked::launcher)
+                .unwrap_or_default(),
+            launch_gate: Some(gate),
+            replaces: replaces.map(str::to_string),
+        };
+        let created = manager
+            .create_session_with_options(
+                &args.language,
+                program,
+                args.args,
+                validated_cwd,
+                args.stop_on_entry,
+                options,
+            )
+            .await;
+        let session_id = match (created, tracked) {
+            (Ok(session_id), Some(tracked)) => {
+                manager
+                    .get_session(&session_id)
+                    .await?
+                    .set_exec_prefix(tracked)
+                    .await;
+                session_id
+            }
+            (Ok(session_id), None) => session_id,
+            // The launcher is gone, the adapter it started may not be
+            (Err(e), Some(tracked)) => {
+                tracked.kill(&[]).await;
+                return Err(e);
+            }
+            (Err(e), None) => return Err(e),
+        };
+        if let Some(toolchain) = &toolchain {
+            manager
+                .get_session(&session_id)
+                .await?
+                .set_toolchain(toolchain.clone())
+                .await;
+        }
+        if !substitute_path.is_empty() {
+            manager
+                .get_session(&session_id)
+                .await?
+                .set_substitute_path(substitute_path)
+                .await;
+        }
+        if let Some(url) = webhook_url {
+            manager
+                .get_session(&session_id)
+                .await?
+                .set_webhook(url)
+                .await;
+        }
+
+        Self::start_background_checks(
+            &manager,
+            &session_id,
+            args.keep_alive_interval_ms,
+            args.stop_poll_interval_ms,
+        )
+        .await?;
+        manager
+            .get_session(&session_id)
+            .await?
+            .set_on_uncaught(on_uncaught)
+            .await;
+        if args.capture_on_exception {
+            manager
+                .get_session(&session_id)
+                .await?
+                .set_capture_on_exception(true)
+                .await;
+        }
+        if args.verify_source {
+            manager
+                .get_session(&session_id)
+                .await?
+                .set_verify_source(true)
+                .await?;
+        }
+        if let Some(entry) = entry {
+            manager
+                .get_session(&session_id)
+                .await?
+                .arm_entry_breakpoint(entry)
+                .await?;
+        }
+        if args.auto_continue_from_entry {
+            manager
+                .get_session(&session_id)
+                .await?
+                .set_auto_continue_from_entry(true)
+                .await;
+        }
+
+        let mut response = json!({
+            "sessionId": session_id,
+            "status": "started",
+            "onUncaught": on_uncaught
+        });
+        if let Some(watch) = watch {
+            let status = WatchStatus {
+                files: watch.files(),
+                debounce_ms,
+                relaunches: 0,
+                last_relaunch: None,
+                error: None,
+            };
+            response["watch"] = json!(status);
+            manager
+                .get_session(&session_id)
+                .await?
+                .set_file_watch(Some(status))
+                .await;
+            tokio::spawn(Self::watch_sources(
+                self.session_manager.clone(),
+                session_id.clone(),
+                watch,
+            ));
+        }
+        if let Some(toolchain) = toolchain {
+            response["toolchain"] = json!(toolchain);
+        }
+        if let Some(saved) = manager
+            .get_session(&session_id)
+            .await?
+            .reused_startup()
+            .await
+        {
+            response["adapterReused"] = json!(true);
+            response["savedStartupMs"] = json!(saved.as_millis() as u64);
+        }
+        if let Some(report) = manager.get_session(&session_id).await?.launch_report() {
+            response["launch"] = json!(report);
+        }
+
+        Ok(response)
+    }
+
+    /// Relaunch a watching session whenever its sources change, until
+    /// `debugger_watch_stop` or the session is removed (see `file_watch`)
+    ///
+    /// Boxed because relaunching starts a session, which spawns this loop:
+    /// the future's type would otherwise contain itself.
+    fn watch_sources(
+        session_manager: Arc<RwLock<SessionManager>>,
+        mut session_id: String,
+        mut watch: FileWatch,
+    ) -> std::pin::Pin<Box<dyn std::future::Future<Output = ()> + Send>> {
+        Box::pin(async move {
+            loop {
+                tokio::time::sleep(file_watch::POLL_INTERVAL).await;
+                let Ok(session) = session_manager.read().await.get_session(&session_id).await
+                else {
+                    return;
+                };
+                let Some(status) = session.file_watch().await else {
+                    return;
+                };
+                if !watch.poll(std::time::Instant::now())
+                    || !file_watch::may_relaunch(&session.get_state().await)
+                {
+                    continue;
+                }
+                let changed = watch.take_changes();
+                let handler = ToolsHandler::new(session_manager.clone());
+                match handler.relaunch(&session, changed, status).await {
+                    Ok(new_id) => {
+                        info!(
+                            "🔁 Sources changed, relaunched session {} as {}",
+                            session_id, new_id
+                        );
+                        session_id = new_id;
+                    }
+                    Err(e) => {
+                        warn!("⚠️  Relaunching session {} failed: {}", session_id, e);
+                        session.set_relaunch_error(e.to_string()).await;
+                    }
+                }
+            }
+        })
+    }
+
+    /// Start `session`'s program again with its start arguments and
+    /// breakpoints, replacing it; returns the new session's id
+    async fn relaunch(
+        &self,
+        session: &DebugSession,
+        changed_files: Vec<String>,
+        status: WatchStatus,
+    ) -> Result<String> {
+        let (new_id, failed) = self.start_again(session).await?;
+        if !failed.is_empty() {
+            warn!(
+                "⚠️  {} breakpoints could not be re-applied after relaunching {}",
+                failed.len(),
+                session.id
+            );
+        }
+        let new_session = self
+            .session_manager
+            .read()
+            .await
+            .get_session(&new_id)
+            .await?;
+        let relaunch = Relaunch {
+            previous_session_id: session.id.clone(),
+            changed_files,
+            timestamp_ms: std::time::SystemTime::now()
+                .duration_since(std::time::UNIX_EPOCH)
+                .map_or(0, |d| d.as_millis() as u64),
+        };
+        new_session.record_relaunch(status, relaunch).await;
+        Ok(new_id)
+    }
+
+    /// Start a new session from `session`'s start arguments carrying over
+    /// its breakpoints, settings and watch, and replace `session` with it
+    ///
+    /// Returns the new session's id and the breakpoints that couldn't be
+    /// re-applied.
+    async fn start_again(&self, session: &DebugSession) -> Result<(String, Vec<Value>)> {
+        let start = session.start_arguments().await.ok_or_else(|| {
+            Error::InvalidState(format!("Session {} has no start arguments", session.id))
+        })?;
+        let breakpoints = session.export_breakpoints().await;
+        let function_breakpoints = session.get_full_state().await.function_breakpoints;
+        let settings = session.settings().await;
+        // The watch carries over to the new session instead of starting anew
+        let mut again = start.clone();
+        again.remove("watch");
+        let (_hold, gate) = launch_gate();
+        // Taking the old session's place, so a session at the limit can relaunch
+        let response = self
+            .start_session(Value::Object(again), gate, Some(&session.id))
+            .await?;
+        let new_id = response["sessionId"]
+            .as_str()
+            .unwrap_or_default()
+            .to_string();
+
+        let manager = self.session_manager.read().await;
+        let new_session = manager.get_session(&new_id).await?;
+        let carried_over = async {
+            new_session.set_start_arguments(start).await;
+            new_session.configure(&settings.live_update()).await?;
+            let report = new_session.import_breakpoints(&breakpoints).await?;
+            let (_, failed) = import_results(&breakpoints, report);
+            if !function_breakpoints.is_empty() {
+                new_session
+                    .add_function_breakpoints(function_breakpoints)
+                    .await?;
+            }
+            Ok::<_, Error>(failed)
+        }
+        .await;
+        let failed = match carried_over {
+            Ok(failed) => failed,
+            Err(e) => {
+                manager.abandon_relaunch(&session.id, &new_id).await;
+                return Err(e);
+            }
+        };
+        if let Some(status) = session.file_watch().await {
+            new_session.set_file_watch(Some(status)).await;
+        }
+        manager.replace_session(&session.id, &new_id).await?;
+        Ok((new_id, failed))
+    }
+
+    /// Start the optional keep-alive and stop-polling loops requested at start
+    async fn start_background_checks(
+        manager: &SessionManager,
+        session_id: &str,
+        keep_alive_interval_ms: Option<u64>,
+        stop_poll_interval_ms: Option<u64>,
+    ) -> Result<()> {
+        let keep_alive = keep_alive_interval_ms.filter(|ms| *ms > 0);
+        let stop_poll = stop_poll_interval_ms.filter(|ms| *ms > 0);
+        if keep_alive.is_none() && stop_poll.is_none() {
+            return Ok(());
+        }
+
+        let session = manager.get_session(session_id).await?;
+        if let Some(interval_ms) = keep_alive {
+            session
+                .set_keep_alive(Some(std::time::Duration::from_millis(interval_ms)))
+                .await;
+        }
+        if let Some(interval_ms) = stop_poll {
+            session
+                .set_stop_polling(Some(std::time::Duration::from_millis(interval_ms)))
+                .await;
+        }
+        Ok(())
+    }
+
+    async fn debugger_attach(&self, args: DebuggerStartArgs) -> Result<Value> {
+        if !matches!(args.language.as_str(), "go" | "python" | "java") {
+            return Err(Error::InvalidRequest(format!(
+                "Attach is not supported for language: {} (supported: go, python, java)",
+                args.language
+            )));
+        }
+
+        security::validate_attach_allowed()?;
+        let webhook_url =
+            webhook::resolve_url(args.webhook_url.as_deref(), &config::current().webhooks)?;
+        let on_uncaught = args.on_uncaught()?;
+
+        let manager = self.session_manager.read().await;
+        let (session_id, attached) = if args.language == "java" {
+            // A JVM is reached through its JDWP agent, not by pid
+            if args.process_id.is_some() || args.process_name.is_some() {
+                return Err(Error::InvalidRequest(
+                    "Java attaches to a JVM's JDWP agent: pass javaOptions.port (and hostName), not processId or processName".to_string(),
+                ));
+            }
+            let host_name = args
+                .java_options
+                .host_name
+                .clone()
+                .unwrap_or_else(|| "127.0.0.1".to_string());
+            security::validate_jvm_attach_host(&host_name)?;
+            let port = args
+                .java_options
+                .port
+                .unwrap_or(JavaAdapter::DEFAULT_JDWP_PORT);
+            let label = format!("jvm {}:{}", host_name, port);
+            let session_id = manager
+                .attach_jvm_session(&host_name, port, label, args.read_only)
+                .await?;
+            (session_id, json!({"hostName": host_name, "port": port}))
+        } else {
+            self.attach_process(&manager, &args).await?
+        };
+        if let Some(url) = webhook_url {
+            manager
+                .get_session(&session_id)
+                .await?
+                .set_webhook(url)
+                .await;
+        }
+
+        Self::start_background_checks(
+            &manager,
+            &session_id,
+            args.keep_alive_interval_ms,
+            args.stop_poll_interval_ms,
+        )
+        .await?;
+        manager
+            .get_session(&session_id)
+            .await?
+            .set_on_uncaught(on_uncaught)
+            .await;
+        if args.capture_on_exception {
+            manager
+                .get_session(&session_id)
+                .await?
+                .set_capture_on_exception(true)
+                .await;
+        }
+        if args.verify_source {
+            manager
+                .get_session(&session_id)
+                .await?
+                .set_verify_source(true)
+                .await?;
+        }
+
+        let mut response = json!({
+            "sessionId": session_id,
+            "status": "attaching",
+            "onUncaught": on_uncaught,
+            "readOnly": args.read_only,
+        });
+        if let (Value::Object(response), Value::Object(attached)) = (&mut response, attached) {
+            response.extend(attached);
+        }
+        Ok(response)
+    }
+
+    /// Attach to a local process by pid or name; its session id, and the
+    /// `processId` and `cmdline` of the process for the response
+    async fn attach_process(
+        &self,
+        manager: &SessionManager,
+        args: &DebuggerStartArgs,
+    ) -> Result<(String, Value)> {
+        let target = match (args.process_id, args.process_name.as_deref()) {
+            (Some(pid), _) => {
+                let process = discovery::get_process(pid).ok_or_else(|| {
+                    Error::Process(format!("No running process with pid {}", pid))
+                })?;
+                if !security::is_process_in_workspace(pid) {
+                    return Err(Error::InvalidRequest(format!(
+                        "Security: Process {} is outside the workspace",
+                        pid
+                    )));
+                }
+                process
+            }
+            (None, Some(name)) => {
+                let candidates = discovery::find_processes(name)
+                    .into_iter()
+                    .filter(|p| security::is_process_in_workspace(p.pid))
+                    .collect();
+                select_attach_target(name, candidates)?
+            }
+            (None, None) => {
+                return Err(Error::InvalidRequest(
+                    "Attach mode requires processId or processName".to_string(),
+                ))
+            }
+        };
+
+        let session_id = manager
+            .attach_session(
+                &args.language,
+                target.pid,
+                target.cmdline.clone(),
+                args.read_only,
+            )
+            .await?;
+        Ok((
+            session_id,
+            json!({"processId": target.pid, "cmdline": target.cmdline}),
+        ))
+    }
+
+    async fn debugger_start_group(&self, arguments: Value) -> Result<Value> {
+        let args: StartGroupArgs = serde_json::from_value(arguments)?;
+
+        let mut configs = Vec::new();
+        for config in args.configs {
+            let Value::Object(mut config) = config else {
+                return Err(Error::InvalidRequest(
+                    "Each group config must be an object".to_string(),
+                ));
+            };
+            let name = match config.remove("name") {
+                Some(Value::String(name)) => name,
+                _ => String::new(),
+            };
+            configs.push((name, Value::Object(config)));
+        }
+        let names: Vec<&str> = configs.iter().map(|(name, _)| name.as_str()).collect();
+        group::validate_member_names(&names)?;
+
+        let mut members: Vec<GroupMember> = Vec::new();
+        let mut started = Vec::new();
+        for (name, config) in configs {
+            match self.debugger_start(config).await {
+                Ok(mut response) => {
+                    let session_id = response["sessionId"]
+                        .as_str()
+                        .unwrap_or_default()
+                        .to_string();
+                    response["name"] = json!(name);
+                    members.push(GroupMember { name, session_id });
+                    started.push(response);
+                }
+                Err(e) => {
+                    // All or nothing: don't leave half a group running
+                    let manager = self.session_manager.write().await;
+                    for member in &members {
+                        let _ = manager.remove_session(&member.session_id).await;
+                    }
+                    return Err(Error::Process(format!(
+                        "Group member '{}' failed to start ({} started members were disconnected): {}",
+                        name,
+                        members.len(),
+                        e
+                    )));
+                }
+            }
+        }
+
+        let manager = self.session_manager.read().await;
+        let group_id = manager.create_group(members).await?;
 
         Ok(json!({
-            "status": "disconnected"
+            "groupId": group_id,
+            "members": started
         }))
     }
 
-    pub fn list_tools() -> Vec<Value> {
-        vec![
-            json!({
-                "name": "debugger_start",
-                "title": "Start Debugging Session",
-                "description": "Starts a new debugging session for a program. RETURNS IMMEDIATELY with a sessionId while initialization happens asynchronously in the background.\n\nIMPORTANT WORKFLOW:\n1. Call this tool first to create a session\n2. Use debugger_wait_for_stop to wait for entry point (if stopOnEntry: true)\n3. Once stopped, set breakpoints with debugger_set_breakpoint\n4. Control execution with debugger_continue\n\nTIMING: Returns in <100ms. Background initialization takes 200-500ms.\n\n⭐ CRITICAL: stopOnEntry Parameter\n=================================\nFor reliable breakpoint debugging, ALWAYS use stopOnEntry: true:\n\n✅ RECOMMENDED (with stopOnEntry: true):\n  - Program pauses at first executable line\n  - Gives you time to set breakpoints before execution\n  - Prevents program from completing before breakpoints are set\n  - Required for debugging programs that execute quickly\n\n❌ NOT RECOMMENDED (stopOnEntry: false or omitted):\n  - Program runs immediately upon start\n  - May complete before breakpoints can be set\n  - Breakpoints might be missed\n  - Only use if you don't need breakpoints\n\nEXAMPLE WORKFLOW:\n  debugger_start({program: \"app.py\", stopOnEntry: true})\n  debugger_wait_for_stop()  // Wait for entry point\n  debugger_set_breakpoint({line: 20})  // Set while paused ✓\n  debugger_continue()  // Now resume to breakpoint\n\nSEE ALSO: debugger_wait_for_stop (efficient waiting), debugger_session_state (state checking), debugger://workflows (complete examples)",
+    async fn debugger_group_continue(&self, arguments: Value) -> Result<Value> {
+        let args: GroupContinueArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let group = manager.get_group(&args.group_id).await?;
+
+        let mut results = Vec::new();
+        for member in &group.members {
+            let mut result = json!(member);
+            result["status"] = match manager.get_session(&member.session_id).await {
+                Err(_) => json!("disconnected"),
+                Ok(session) => match session.get_state().await {
+                    DebugState::Stopped { .. } => match session.continue_execution().await {
+                        Ok(()) => json!("continued"),
+                        Err(e) => {
+                            result["error"] = json!(e.to_string());
+                            json!("failed")
+                        }
+                    },
+                    DebugState::Terminated | DebugState::Failed { .. } => json!("ended"),
+                    _ => json!("running"),
+                },
+            };
+            results.push(result);
+        }
+
+        let mut response = json!({
+            "groupId": group.id,
+            "members": results
+        });
+        if !args.wait_for_stop {
+            return Ok(response);
+        }
+
+        let timeout = match args.timeout_ms {
+            Some(ms) => std::time::Duration::from_millis(ms),
+            None => std::time::Duration::from_millis(config::current().timeouts.wait_for_stop_ms),
+        };
+        let start = tokio::time::Instant::now();
+        loop {
+            let mut states = Vec::new();
+            let mut stopped_at = Vec::new();
+            for member in &group.members {
+                let (state, at) = group_member_json(&manager, member).await;
+                states.push(state);
+                stopped_at.push(at);
+            }
+
+            if let Some(first) = group::first_stopped(&stopped_at) {
+                response["firstStopped"] = states[first].clone();
+                return Ok(response);
+            }
+            let all_ended = states.iter().all(|s| {
+                matches!(
+                    s["state"].as_str(),
+                    Some("Terminated" | "Failed" | "Disconnected")
+                )
+            });
+            if all_ended {
+                response["firstStopped"] = Value::Null;
+                response["allEnded"] = json!(true);
+                return Ok(response);
+            }
+            if start.elapsed() > timeout {
+                return Err(Error::InvalidState(format!(
+                    "Timeout waiting for a member of group {} to stop ({}ms)",
+                    group.id,
+                    timeout.as_millis()
+                )));
+            }
+            tokio::time::sleep(tokio::time::Duration::from_millis(50)).await;
+        }
+    }
+
+    async fn debugger_group_state(&self, arguments: Value) -> Result<Value> {
+        let args: GroupArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let group = manager.get_group(&args.group_id).await?;
+
+        let mut members = Vec::new();
+        let mut stopped_at = Vec::new();
+        for member in &group.members {
+            let (state, at) = group_member_json(&manager, member).await;
+            members.push(state);
+            stopped_at.push(at);
+        }
+        let first_stopped = group::first_stopped(&stopped_at).map(|i| members[i]["name"].clone());
+
+        Ok(json!({
+            "groupId": group.id,
+            "members": members,
+            "firstStopped": first_stopped
+        }))
+    }
+
+    async fn debugger_group_disconnect(&self, arguments: Value) -> Result<Value> {
+        let args: GroupArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.write().await;
+        let group = manager.remove_group(&args.group_id).await?;
+
+        let mut members = Vec::new();
+        for member in &group.members {
+            let mut result = json!(member);
+            result["status"] = match manager.remove_session(&member.session_id).await {
+                Ok(()) => json!("disconnected"),
+                Err(Error::SessionNotFound(_)) => json!("alreadyDisconnected"),
+                Err(e) => {
+                    result["error"] = json!(e.to_string());
+                    json!("failed")
+                }
+            };
+            members.push(result);
+        }
+
+        Ok(json!({
+            "groupId": group.id,
+            "members": members
+        }))
+    }
+
+    async fn debugger_session_state(&self, arguments: Value) -> Result<Value> {
+        let args: SessionStateArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+        drop(manager);
+
+        let mut state = session.get_state().await;
+        if let Some(block_for_ms) = args.block_for_ms {
+            let block = std::time::Duration::from_millis(block_for_ms.min(MAX_BLOCK_FOR_MS));
+            state = session.wait_for_state_change(&state, block).await;
+        }
+        let (events_seq, retry_after_ms) = session.poll_hint().await;
+
+        let (state_str, mut details) = state_json(&state);
+        if let crate::debug::state::DebugState::Stopped { thread_id, reason } = &state {
+            if let Some(exception) = exception_json(&session, *thread_id, reason).await {
+                details["exception"] = exception;
+            }
+        }
+        if let Some(capture) = exception_capture_json(&session, &state).await {
+            details["exceptionCapture"] = capture;
+        }
+        if let Some((_, stack)) = session.loaded_stack().await {
+            if let Some(top) = stack.frames.first() {
+                details["topFrame"] = json!({
+                    "id": top.id,
+                    "name": top.name,
+                    "sourcePath": top.source.as_ref().and_then(|s| s.path.as_deref()),
+                    "line": top.line
+                });
+            }
+            details["stack"] = json!({
+                "loadedFrames": stack.frames.len(),
+                "totalFrames": stack.total_frames,
+                "partial": stack.partial()
+            });
+        }
+        if let Some(report) = session.crash_report().await {
+            details["crashReport"] = json!(report);
+        }
+        let full_state = session.get_full_state().await;
+        if let Some(summary) = full_state.resource_summary() {
+            details["resourceUsage"] = json!(summary);
+        }
+        if let Some(summary) = full_state.breakpoint_summary {
+            details["breakpointSummary"] = json!(summary);
+        }
+        if let Some(diagnosis) = full_state.exit_diagnosis {
+            details["exitDiagnosis"] = json!(diagnosis);
+        }
+        if let Some(lines) = session.startup_output().await {
+            details["startupOutput"] = json!({
+                "category": crate::debug::state::STARTUP_OUTPUT_CATEGORY,
+                "lines": lines
+            });
+        }
+
+        let mut response = json!({
+            "sessionId": args.session_id,
+            "state": state_str,
+            "details": details,
+            "eventsSeq": events_seq,
+            "readOnly": session.is_read_only()
+        });
+        if let Some(member) = session.group_member().await {
+            response["member"] = json!(member);
+        }
+        let children = session.child_processes().await;
+        if !children.is_empty() {
+            response["childProcesses"] = json!(children);
+        }
+        let memory_changes = session.memory_changes().await;
+        if !memory_changes.is_empty() {
+            response["memoryChanges"] = json!(memory_changes);
+        }
+        if let Some(retry_after_ms) = retry_after_ms {
+            response["retryAfterMs"] = json!(retry_after_ms);
+        }
+        if let Some(watch) = session.file_watch().await {
+            response["watch"] = json!(watch);
+        }
+        if let Some(report) = session.launch_report() {
+            response["launch"] = json!(report);
+        }
+        Ok(response)
+    }
+
+    /// What advanced eventsSeq after the client's last one
+    async fn debugger_events(&self, arguments: Value) -> Result<Value> {
+        let args: EventsArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+        drop(manager);
+
+        let block =
+            std::time::Duration::from_millis(args.block_for_ms.unwrap_or(0).min(MAX_BLOCK_FOR_MS));
+        let (events, missed, events_seq) = session.events_since(args.since_seq, block).await;
+        Ok(json!({
+            "sessionId": args.session_id,
+            "events": events,
+            "missed": missed,
+            "eventsSeq": events_seq
+        }))
+    }
+
+    /// Run state from cached session state only; no adapter requests
+    async fn debugger_is_stopped(&self, arguments: Value) -> Result<Value> {
+        let args: IsStoppedArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+        drop(manager);
+
+        let state = session.get_state().await;
+        let (events_seq, retry_after_ms) = session.poll_hint().await;
+
+        let mut response = json!({
+            "stopped": false,
+            "terminated": matches!(
+                state,
+                crate::debug::state::DebugState::Terminated
+                    | crate::debug::state::DebugState::Failed { .. }
+            ),
+            "eventsSeq": events_seq
+        });
+        if let crate::debug::state::DebugState::Stopped { thread_id, reason } = state {
+            response["stopped"] = json!(true);
+            response["reason"] = json!(reason);
+            response["threadId"] = json!(thread_id);
+        }
+        if let Some(retry_after_ms) = retry_after_ms {
+            response["retryAfterMs"] = json!(retry_after_ms);
+        }
+        Ok(response)
+    }
+
+    async fn debugger_set_breakpoint(&self, arguments: Value) -> Result<Value> {
+        let args: SetBreakpointArgs = serde_json::from_value(arguments)?;
+
+        // The adapter reports on-disk casing; a breakpoint under any other
+        // casing (possible on case-insensitive volumes) would never match.
+        // Resolving lists directories, so only within the workspace roots
+        security::validate_within_workspace(&args.source_path)?;
+        let case = path_case::resolve(std::path::Path::new(&args.source_path))?;
+
+        // Validate source path to prevent path traversal
+        // Note: We validate without extension requirement since breakpoints
+        // can be set in any source file regardless of language
+        let validated_source = security::validate_source_path(&case.path.to_string_lossy(), None)?;
+        let source_path = validated_source
+            .to_str()
+            .ok_or_else(|| Error::Internal("Non-UTF8 source path (invalid encoding)".to_string()))?
+            .to_string();
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        if args.temporary && args.log_message.is_some() {
+            return Err(Error::InvalidRequest(
+                "A logpoint never stops, so it can't be temporary".to_string(),
+            ));
+        }
+        if args.caller.is_some() && args.log_message.is_some() {
+            return Err(Error::InvalidRequest(
+                "A logpoint never stops, so its callers can't be checked".to_string(),
+            ));
+        }
+        let caller = match &args.caller {
+            Some(pattern) => Some(CallerConstraint::new(pattern, args.caller_depth)?),
+            None if args.caller_depth.is_some() => {
+                return Err(Error::InvalidRequest(
+                    "callerDepth needs a caller".to_string(),
+                ))
+            }
+            None => None,
+        };
+        // changed(expr) is the server's to evaluate; the debugger gets the rest
+        let changed = args
+            .condition
+            .as_deref()
+            .and_then(change_watch::changed_expression);
+        if changed.is_some()
+            && (args.log_message.is_some() || args.temporary || caller.is_some() || args.additional)
+        {
+            return Err(Error::InvalidRequest(
+                "A changed() condition watches the whole line, so it can't be combined with logMessage, temporary, caller or additional".to_string(),
+            ));
+        }
+        let debugger_condition = match changed {
+            Some(_) => None,
+            None => args.condition.clone(),
+        };
+
+        let condition = match &args.goroutine_label {
+            Some(label) => {
+                let label_condition = session.goroutine_label_condition(label).await?;
+                Some(match &debugger_condition {
+                    Some(condition) => format!("({}) && ({})", label_condition, condition),
+                    None => label_condition,
+                })
+            }
+            None => debugger_condition,
+        };
+        if args.remove {
+            let removed = session
+                .remove_breakpoint(&source_path, args.line, condition.as_deref())
+                .await?;
+            let remaining = session
+                .get_full_state()
+                .await
+                .get_breakpoints(&source_path)
+                .into_iter()
+                .filter(|bp| bp.line == args.line)
+                .count();
+            return Ok(json!({
+                "removed": removed,
+                "remaining": remaining,
+                "sourcePath": source_path,
+                "line": args.line
+            }));
+        }
+        if args.additional && condition.is_none() {
+            return Err(Error::InvalidRequest(
+                "additional needs a condition: only conditional breakpoints share a line"
+                    .to_string(),
+            ));
+        }
+        let has_options = args.log_message.is_some()
+            || condition.is_some()
+            || args.hit_condition.is_some()
+            || args.temporary
+            || caller.is_some();
+        let mut change = None;
+        let verified = if let Some(expression) = changed {
+            let (watch, verified) = session
+                .set_changed_breakpoint(
+                    expression,
+                    &source_path,
+                    args.line,
+                    condition.clone(),
+                    args.hit_condition.clone(),
+                )
+                .await?;
+            change = Some(watch);
+            verified
+        } else if has_options {
+            let bp = Breakpoint {
+                source_path: source_path.clone(),
+                line: args.line,
+                id: None,
+                verified: false,
+                enabled: true,
+                condition: condition.clone(),
+                hit_condition: args.hit_condition.clone(),
+                log_message: args.log_message.clone(),
+                temporary: args.temporary,
+                verified_line: None,
+                move_explanation: None,
+                caller: caller.clone(),
+            };
+            if args.additional {
+                session.add_shared_breakpoint(bp).await?
+            } else {
+                session.set_breakpoint_with(bp).await?
+            }
+        } else {
+            session
+                .set_breakpoint(source_path.clone(), args.line)
+                .await?
+        };
+
+        let mut response = json!({
+            "verified": verified,
+            "sourcePath": source_path,
+            "line": args.line
+        });
+        for (key, field) in [
+            ("condition", &condition),
+            ("hitCondition", &args.hit_condition),
+        ] {
+            if let Some(text) = field {
+                response[key] = json!(text);
+            }
+        }
+        if let Some(label) = &args.goroutine_label {
+            response["goroutineLabel"] = json!(label);
+        }
+        if let Some(watch) = &change {
+            response["condition"] = json!(args.condition);
+            response["changeWatch"] = json!({
+                "watchId": watch.id,
+                "expression": watch.expression,
+                "strategy": watch.strategy,
+                "maxAutoContinues": watch.max_auto_continues,
+                "overhead": watch.strategy.overhead()
+            });
+        }
+        if args.temporary {
+            response["temporary"] = json!(true);
+        }
+        if let Some(caller) = &caller {
+            response["caller"] = json!(caller.pattern);
+            response["callerDepth"] = json!(caller.depth);
+        }
+        let shared = session
+            .get_full_state()
+            .await
+            .get_breakpoints(&source_path)
+            .iter()
+            .filter(|bp| bp.line == args.line)
+            .count();
+        if shared > 1 {
+            response["breakpointsOnLine"] = json!(shared);
+        }
+        let moved = session
+            .get_full_state()
+            .await
+            .get_breakpoints(&source_path)
+            .into_iter()
+            .find(|bp| bp.line == args.line && bp.verified_line.is_some());
+        if let Some(bp) = moved {
+            response["verifiedLine"] = json!(bp.verified_line);
+            response["moveExplanation"] = json!(bp.move_explanation);
+        }
+        if let Some(warning) = session.source_warning(&source_path).await {
+            response["sourceWarning"] = json!(warning);
+        }
+        if let Some(message) = &args.log_message {
+            response["logMessage"] = json!(message);
+            response["logPoint"] = json!(if session.log_points_emulated().await {
+                "emulated"
+            } else {
+                "native"
+            });
+        }
+        if case.corrected {
+            response["sourcePath"] = json!(args.source_path);
+            response["onDiskPath"] = json!(source_path);
+            if session.alias_path(&source_path, &args.source_path).await {
+                response["pathWarning"] = json!(format!(
+                    "'{}' differs in letter case from the file on disk, '{}'. The breakpoint was set on the on-disk path; results report your spelling",
+                    args.source_path, source_path
+                ));
+            }
+        }
+        Ok(response)
+    }
+
+    async fn debugger_set_instruction_breakpoint(&self, arguments: Value) -> Result<Value> {
+        let args: SetInstructionBreakpointArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        if args.remove {
+            let removed = session
+                .remove_instruction_breakpoint(&args.instruction_reference, args.offset)
+                .await?;
+            return Ok(json!({
+                "removed": removed,
+                "instructionReference": args.instruction_reference,
+                "offset": args.offset
+            }));
+        }
+
+        let bp = session
+            .set_instruction_breakpoint(
+                args.instruction_reference,
+                args.offset,
+                args.condition,
+                args.hit_condition,
+            )
+            .await?;
+        Ok(instruction_breakpoint_json(&bp))
+    }
+
+    async fn debugger_set_function_breakpoint(&self, arguments: Value) -> Result<Value> {
+        let args: SetFunctionBreakpointArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        if args.remove {
+            let removed = session.remove_function_breakpoint(&args.name).await?;
+            return Ok(json!({
+                "removed": removed,
+                "name": args.name
+            }));
+        }
+
+        let bp = session
+            .set_function_breakpoint(args.name, args.condition, args.hit_condition)
+            .await?;
+        Ok(function_breakpoint_json(&bp))
+    }
+
+    async fn debugger_disassemble(&self, arguments: Value) -> Result<Value> {
+        let args: DisassembleArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+        drop(manager);
+
+        let count = args.instruction_count.unwrap_or(DEFAULT_INSTRUCTIONS);
+        let offset = match (args.instruction_offset, &args.memory_reference) {
+            (Some(offset), _) => offset,
+            (None, Some(_)) => 0,
+            (None, None) => -count / 2,
+        };
+        let page = session
+            .disassemble(args.memory_reference, offset, count)
+            .await?;
+        Ok(json!(page))
+    }
+
+    async fn debugger_scroll_disassembly(&self, arguments: Value, forward: bool) -> Result<Value> {
+        let args: ScrollDisassemblyArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+        drop(manager);
+
+        let page = session.scroll_disassembly(forward).await?;
+        Ok(json!(page))
+    }
+
+    async fn debugger_get_capabilities(&self, arguments: Value) -> Result<Value> {
+        let args: GetCapabilitiesArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+        drop(manager);
+
+        let (adapter, capabilities) = session.capability_report().await.ok_or_else(|| {
+            Error::InvalidState(
+                "The debugger hasn't reported its capabilities yet; wait until the session is initialized"
+                    .to_string(),
+            )
+        })?;
+        let alternatives: HashMap<&str, &str> = capabilities
+            .iter()
+            .filter(|(_, supported)| !**supported)
+            .filter_map(|(capability, _)| {
+                crate::dap::capabilities::suggestion(&adapter, capability)
+                    .map(|suggestion| (*capability, suggestion))
+            })
+            .collect();
+        Ok(json!({
+            "adapter": adapter,
+            "capabilities": capabilities,
+            "functionBreakpoints": capabilities.get("supportsFunctionBreakpoints").copied().unwrap_or(false),
+            "supportedChecksumAlgorithms": session.supported_checksum_algorithms().await,
+            "adapterPositions": session.positions().await,
+            "alternatives": alternatives
+        }))
+    }
+
+    async fn debugger_set_exception_breakpoints(&self, arguments: Value) -> Result<Value> {
+        let args: SetExceptionBreakpointsArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        let filters = session
+            .set_exception_breakpoints_with_options(&args.modes, &args.exception_options)
+            .await?;
+        let mut response = json!({
+            "modes": args.modes,
+            "filters": filters
+        });
+        if !args.exception_options.is_empty() {
+            response["exceptionOptions"] = json!(args.exception_options);
+        }
+        Ok(response)
+    }
+
+    async fn debugger_configure(&self, arguments: Value) -> Result<Value> {
+        let args: ConfigureArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+        drop(manager);
+
+        let update = SettingsUpdate::parse(&session.language, &args.settings)?;
+        let requires_restart = session.configure(&update).await?;
+        Ok(json!({
+            "settings": session.settings().await,
+            "requiresRestart": requires_restart
+        }))
+    }
+
+    async fn debugger_continue(&self, arguments: Value) -> Result<Value> {
+        let args: ContinueArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        match args.thread_id {
+            Some(thread_id) => session.continue_thread(thread_id).await?,
+            None => session.continue_execution().await?,
+        }
+
+        Ok(json!({
+            "status": "continued"
+        }))
+    }
+
+    async fn debugger_continue_past(&self, arguments: Value) -> Result<Value> {
+        let args: ContinuePastArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+        drop(manager);
+
+        let timeout = match args.timeout_ms {
+            Some(ms) => std::time::Duration::from_millis(ms),
+            None => session.wait_for_stop_timeout().await,
+        };
+        let report = session
+            .continue_past(args.breakpoint_id, args.count, timeout)
+            .await?;
+        let mut response = json!(report);
+        if report.status == ContinuePastStatus::Interrupted {
+            if let Some(thread_id) = report.thread_id {
+                let reason = report.reason.as_deref().unwrap_or_default();
+                if let Some(exception) = exception_json(&session, thread_id, reason).await {
+                    response["exception"] = exception;
+                }
+            }
+        }
+        Ok(response)
+    }
+
+    async fn debugger_stack_trace(&self, arguments: Value) -> Result<Value> {
+        let args: StackTraceArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        // Validate we're in a stopped state
+        let crate::debug::state::DebugState::Stopped {
+            thread_id: stopped_thread,
+            ..
+        } = session.get_state().await
+        else {
+            return Err(Error::InvalidState(
+                "Cannot get stack trace while program is running. The program must be stopped at a breakpoint, entry point, or step. Use debugger_wait_for_stop() to wait for the program to stop.".to_string()
+            ));
+        };
+
+        let thread_id = args.thread_id.unwrap_or(stopped_thread);
+        let levels = args
+            .levels
+            .unwrap_or(DEFAULT_STACK_LEVELS)
+            .clamp(1, MAX_STACK_LEVELS);
+        let page = session
+            .stack_trace_page(thread_id, args.start_frame.max(0), levels)
+            .await?;
+
+        Ok(serde_json::to_value(StackReport::from(page))?)
+    }
+
+    async fn debugger_watch_change(&self, arguments: Value) -> Result<Value> {
+        let args: WatchChangeArgs = serde_json::from_value(arguments)?;
+        if args.expression.trim().is_empty() {
+            return Err(Error::InvalidRequest(
+                "expression must not be empty".to_string(),
+            ));
+        }
+        let max_auto_continues = args
+            .max_auto_continues
+            .unwrap_or(change_watch::DEFAULT_MAX_AUTO_CONTINUES);
+        if max_auto_continues > change_watch::MAX_AUTO_CONTINUES {
+            return Err(Error::InvalidRequest(format!(
+                "maxAutoContinues must be at most {}, got {}",
+                change_watch::MAX_AUTO_CONTINUES,
+                max_auto_continues
+            )));
+        }
+
+        // Same path handling as debugger_set_breakpoint
+        security::validate_within_workspace(&args.source_path)?;
+        let case = path_case::resolve(std::path::Path::new(&args.source_path))?;
+        let validated_source = security::validate_source_path(&case.path.to_string_lossy(), None)?;
+        let source_path = validated_source
+            .to_str()
+            .ok_or_else(|| Error::Internal("Non-UTF8 source path (invalid encoding)".to_string()))?
+            .to_string();
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+        let (watch, verified) = session
+            .watch_change(
+                args.expression.trim(),
+                &source_path,
+                args.line,
+                max_auto_continues,
+            )
+            .await?;
+
+        let mut response = json!({
+            "watchId": watch.id,
+            "expression": watch.expression,
+            "sourcePath": source_path,
+            "line": args.line,
+            "verified": verified,
+            "strategy": watch.strategy,
+            "overhead": watch.strategy.overhead()
+        });
+        if watch.strategy == WatchStrategy::ServerLoop {
+            response["maxAutoContinues"] = json!(max_auto_continues);
+        }
+        Ok(response)
+    }
+
+    async fn debugger_entry_point(&self, arguments: Value) -> Result<Value> {
+        let args: EntryPointArgs = serde_json::from_value(arguments)?;
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+        let start = session.start_arguments().await.unwrap_or_default();
+        let mode = resolve_mode(
+            &session.language,
+            start.get("mode").and_then(|mode| mode.as_str()),
+        )?;
+
+        let (function, location, resolved_by) =
+            match entry_point(&session.language, mode, &session.program)? {
+                EntryBreakpoint::Function(name) => {
+                    let location = session.locate_function(&name).await?;
+                    (Some(name), location, "debugger")
+                }
+                EntryBreakpoint::Line { path, line } => (None, Some((path, line)), "source"),
+            };
+        let (source_path, line) = location.unzip();
+        Ok(json!({
+            "sessionId": session.id,
+            "function": function,
+            "sourcePath": source_path,
+            "line": line,
+            "resolvedBy": resolved_by
+        }))
+    }
+
+    async fn debugger_find_frame(&self, arguments: Value) -> Result<Value> {
+        let args: FindFrameArgs = serde_json::from_value(arguments)?;
+        if args.function.trim().is_empty() {
+            return Err(Error::InvalidRequest(
+                "function must not be empty".to_string(),
+            ));
+        }
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+        let crate::debug::state::DebugState::Stopped {
+            thread_id: stopped_thread,
+            ..
+        } = session.get_state().await
+        else {
+            return Err(Error::InvalidState(
+                "Cannot search the stack while program is running. The program must be stopped at a breakpoint, entry point, or step. Use debugger_wait_for_stop() to wait for the program to stop.".to_string()
+            ));
+        };
+
+        let thread_id = args.thread_id.unwrap_or(stopped_thread);
+        let levels = args
+            .levels
+            .unwrap_or(MAX_STACK_LEVELS)
+            .clamp(1, MAX_STACK_LEVELS);
+        let page = session.stack_trace_page(thread_id, 0, levels).await?;
+        let matches = stack::find_frames(&page, args.function.trim());
+
+        let mut response = json!({
+            "function": args.function,
+            "threadId": thread_id,
+            "matches": matches,
+            "searchedFrames": page.frames.len(),
+            "moreFrames": page.has_more()
+        });
+        if matches.is_empty() {
+            // Help the caller spell the name the way the adapter does
+            let mut functions: Vec<&str> = Vec::new();
+            for frame in &page.frames {
+                if !functions.contains(&frame.name.as_str()) {
+                    functions.push(&frame.name);
+                }
+            }
+            response["stackFunctions"] = json!(functions);
+        }
+        Ok(response)
+    }
+
+    async fn debugger_evaluate(&self, arguments: Value) -> Result<Value> {
+        let args: EvaluateArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        // Validate we're in a stopped state
+        let state = session.get_state().await;
+        if !matches!(state, crate::debug::state::DebugState::Stopped { .. }) {
+            return Err(Error::InvalidState(
+                "Cannot evaluate expressions while program is running. The program must be stopped at a breakpoint, entry point, or step. Use debugger_wait_for_stop() to wait for the program to stop.".to_string()
+            ));
+        }
+
+        // Evaluating in a specific thread needs that thread stopped and one of its frames
+        let frame_id = match (args.frame_id, args.thread_id) {
+            (None, Some(thread_id)) => session
+                .stack_trace_for_thread(thread_id)
+                .await?
+                .first()
+                .map(|frame| frame.id),
+            (frame_id, Some(thread_id)) => {
+                session.ensure_thread_stopped(thread_id).await?;
+                frame_id
+            }
+            (frame_id, None) => frame_id,
+        };
+
+        let result = if session.side_effect_free(args.no_side_effects)? {
+            session
+                .evaluate_without_side_effects(&args.expression, frame_id)
+                .await?
+        } else {
+            session.evaluate(&args.expression, frame_id).await?
+        };
+
+        let mut response = json!({
+            "result": config::redact(&result)
+        });
+        if encoding::is_lossy(&result) {
+            response["encodingLossy"] = json!(true);
+        }
+        Ok(response)
+    }
+
+    async fn debugger_evaluate_all_threads(&self, arguments: Value) -> Result<Value> {
+        let args: EvaluateAllThreadsArgs = serde_json::from_value(arguments)?;
+
+        let max_threads = args.max_threads.unwrap_or(thread_eval::DEFAULT_MAX_THREADS);
+        if !(1..=thread_eval::MAX_THREADS).contains(&max_threads) {
+            return Err(Error::InvalidRequest(format!(
+                "maxThreads must be between 1 and {}, got {}",
+                thread_eval::MAX_THREADS,
+                max_threads
+            )));
+        }
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        let no_side_effects = session.side_effect_free(args.no_side_effects)?;
+        let mut evaluations = session
+            .evaluate_all_threads(&args.expression, max_threads, no_side_effects)
+            .await?;
+        for result in &mut evaluations.results {
+            result.error = result.error.as_deref().map(config::redact);
+        }
+        Ok(serde_json::to_value(evaluations)?)
+    }
+
+    async fn debugger_compare_sessions(&self, arguments: Value) -> Result<Value> {
+        let args: CompareSessionsArgs = serde_json::from_value(arguments)?;
+        let [first, second] = args.session_ids.as_slice() else {
+            return Err(Error::InvalidRequest(format!(
+                "sessionIds must name exactly two sessions, got {}",
+                args.session_ids.len()
+            )));
+        };
+        if first == second {
+            return Err(Error::InvalidRequest(
+                "sessionIds must name two different sessions".to_string(),
+            ));
+        }
+
+        let manager = self.session_manager.read().await;
+        let (first, second) = tokio::join!(
+            comparison_value(&manager, first, &args.expression, args.no_side_effects),
+            comparison_value(&manager, second, &args.expression, args.no_side_effects)
+        );
+        drop(manager);
+
+        let comparison = compare::compare(
+            &args.expression,
+            vec![first, second],
+            config::current().limits.max_response_bytes,
+        );
+        Ok(json!(comparison))
+    }
+
+    async fn debugger_repl_open(&self, arguments: Value) -> Result<Value> {
+        let args: ReplOpenArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+        let repl = session.open_repl(args.frame_id, args.thread_id).await?;
+
+        Ok(json!({
+            "replId": repl.id,
+            "sessionId": args.session_id,
+            "threadId": repl.thread_id,
+            "frameId": repl.frame_id
+        }))
+    }
+
+    async fn debugger_repl_eval(&self, arguments: Value) -> Result<Value> {
+        let args: ReplEvalArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_repl_session(&args.repl_id).await?;
+        let output = session.repl_eval(&args.repl_id, &args.input).await?;
+
+        Ok(json!({
+            "replId": args.repl_id,
+            "output": output
+        }))
+    }
+
+    async fn debugger_repl_history(&self, arguments: Value) -> Result<Value> {
+        let args: ReplHistoryArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_repl_session(&args.repl_id).await?;
+        let repl = session.repl(&args.repl_id).await?;
+
+        Ok(json!({
+            "replId": repl.id,
+            "open": repl.is_current(session.stopped_at().await),
+            "threadId": repl.thread_id,
+            "frameId": repl.frame_id,
+            "history": repl.history,
+            "droppedEntries": repl.dropped
+        }))
+    }
+
+    async fn debugger_wait_for_stop(&self, arguments: Value) -> Result<Value> {
+        let args: WaitForStopArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        let timeout = match args.timeout_ms {
+            Some(ms) => tokio::time::Duration::from_millis(ms),
+            None => session.wait_for_stop_timeout().await,
+        };
+        let start = tokio::time::Instant::now();
+
+        loop {
+            let state = session.get_state().await;
+
+            // Check if we're stopped
+            if let crate::debug::state::DebugState::Stopped { thread_id, reason } = state.clone() {
+                let (hit, _) = session.get_full_state().await.hit_breakpoints();
+                let mut response = json!({
+                    "state": "Stopped",
+                    "threadId": thread_id,
+                    "reason": reason,
+                    "hitBreakpoints": hit.iter().map(breakpoint_json).collect::<Vec<_>>()
+                });
+                if let Some(exception) = exception_json(&session, thread_id, &reason).await {
+                    response["exception"] = exception;
+                }
+                if let Some(capture) = exception_capture_json(&session, &state).await {
+                    response["exceptionCapture"] = capture;
+                }
+                if let Some(member) = session.group_member().await {
+                    response["member"] = json!(member);
+                }
+                if let Some(watch_stop) = session.watch_stop().await {
+                    response["changeWatch"] = json!(watch_stop);
+                }
+                if let Some(caller_stop) = session.caller_stop().await {
+                    response["callerMatch"] = json!(caller_stop);
+                }
+                return Ok(response);
+            }
+
+            // A start cancelled by debugger_disconnect never launched
+            if session.start_cancelled() {
+                return Err(Error::Cancelled(format!(
+                    "The start of session {} was cancelled",
+                    args.session_id
+                )));
+            }
+
+            // Check if program terminated
+            if matches!(state, crate::debug::state::DebugState::Terminated) {
+                let mut response = json!({
+                    "state": "Terminated",
+                    "reason": "Program exited"
+                });
+                if let Some(capture) = exception_capture_json(&session, &state).await {
+                    response["exceptionCapture"] = capture;
+                }
+                if let Some(diagnosis) = session.get_full_state().await.exit_diagnosis {
+                    response["diagnosis"] = json!(diagnosis);
+                }
+                return Ok(response);
+            }
+
+            // Check if program failed
+            if let crate::debug::state::DebugState::Failed { error } = state {
+                return Err(Error::Dap(format!("Session failed: {}", error)));
+            }
+
+            // Check timeout
+            if start.elapsed() > timeout {
+                return Err(Error::InvalidState(format!(
+                    "Timeout waiting for program to stop ({}ms). Current state: {:?}",
+                    timeout.as_millis(),
+                    state
+                )));
+            }
+
+            // Sleep briefly before checking again
+            tokio::time::sleep(tokio::time::Duration::from_millis(50)).await;
+        }
+    }
+
+    async fn debugger_wait_for_breakpoint(&self, arguments: Value) -> Result<Value> {
+        let args: WaitForBreakpointArgs = serde_json::from_value(arguments)?;
+        let max_auto_continues = args
+            .max_auto_continues
+            .unwrap_or(DEFAULT_BREAKPOINT_AUTO_CONTINUES);
+        if max_auto_continues > MAX_BREAKPOINT_AUTO_CONTINUES {
+            return Err(Error::InvalidRequest(format!(
+                "maxAutoContinues must be at most {}, got {}",
+                MAX_BREAKPOINT_AUTO_CONTINUES, max_auto_continues
+            )));
+        }
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+        let timeout = match args.timeout_ms {
+            Some(ms) => tokio::time::Duration::from_millis(ms),
+            None => session.wait_for_stop_timeout().await,
+        };
+        let outcome = session
+            .run_to_breakpoint(
+                args.breakpoint_id,
+                args.auto_continue.unwrap_or(true),
+                max_auto_continues,
+                timeout,
+            )
+            .await?;
+
+        let status = match &outcome.end {
+            BreakpointWaitEnd::Hit => "hit",
+            BreakpointWaitEnd::Interrupted { .. } => "interrupted",
+            BreakpointWaitEnd::Terminated => "terminated",
+            BreakpointWaitEnd::StillRunning => "running",
+            BreakpointWaitEnd::LimitReached => "limitReached",
+        };
+        let mut response = json!({
+            "status": status,
+            "breakpointId": args.breakpoint_id,
+            "autoContinues": outcome.auto_continues
+        });
+        if let BreakpointWaitEnd::Interrupted { reason } = &outcome.end {
+            response["reason"] = json!(reason);
+        }
+        if let Some(thread_id) = outcome.thread_id {
+            let (hit, _) = session.get_full_state().await.hit_breakpoints();
+            response["threadId"] = json!(thread_id);
+            response["hitBreakpoints"] = json!(hit.iter().map(breakpoint_json).collect::<Vec<_>>());
+        }
+        if let Some(frame) = outcome.location {
+            response["location"] = json!({
+                "function": frame.name,
+                "path": frame.source.and_then(|s| s.path),
+                "line": frame.line
+            });
+        }
+        Ok(response)
+    }
+
+    async fn debugger_list_breakpoints(&self, arguments: Value) -> Result<Value> {
+        let args: ListBreakpointsArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        let full_state = session.get_full_state().await;
+
+        // Collect all breakpoints from all source files
+        let mut all_breakpoints = Vec::new();
+        for (source_path, breakpoints) in full_state.breakpoints.iter() {
+            let source_path = full_state
+                .path_aliases
+                .get(source_path)
+                .unwrap_or(source_path);
+            for bp in breakpoints {
+                all_breakpoints.push(json!({
+                    "id": bp.id,
+                    "verified": bp.verified,
+                    "enabled": bp.enabled,
+                    "line": bp.line,
+                    "sourcePath": source_path
+                }));
+            }
+        }
+        // Hit counts and subscriptions go by id
+        let hits = |value: &mut Value| {
+            if let Some(id) = value["id"].as_i64() {
+                let id = id as i32;
+                value["hitCount"] = json!(full_state.breakpoint_hits.count(id));
+                value["subscribed"] = json!(full_state.subscription.follows(id));
+            }
+        };
+        all_breakpoints.iter_mut().for_each(hits);
+
+        let instruction_breakpoints: Vec<Value> = full_state
+            .instruction_breakpoints
+            .iter()
+            .map(instruction_breakpoint_json)
+            .collect();
+
+        let mut function_breakpoints: Vec<Value> = full_state
+            .function_breakpoints
+            .iter()
+            .map(function_breakpoint_json)
+            .collect();
+        function_breakpoints.iter_mut().for_each(hits);
+
+        Ok(json!({
+            "breakpoints": all_breakpoints,
+            "functionBreakpoints": function_breakpoints,
+            "instructionBreakpoints": instruction_breakpoints
+        }))
+    }
+
+    /// debugger_subscribe, or debugger_unsubscribe when `subscribe` is false
+    async fn debugger_subscribe(&self, arguments: Value, subscribe: bool) -> Result<Value> {
+        let args: SubscriptionArgs = serde_json::from_value(arguments)?;
+        let ids = args.ids()?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        let subscription = if subscribe {
+            session.subscribe(ids).await?
+        } else {
+            session.unsubscribe(ids).await?
+        };
+        Ok(json!({
+            "subscription": subscription.to_json(),
+            "autoContinueUnsubscribed": session.settings().await.auto_continue_unsubscribed
+        }))
+    }
+
+    async fn debugger_last_hit_breakpoints(&self, arguments: Value) -> Result<Value> {
+        let args: LastHitBreakpointsArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        let (hit, unknown_ids) = session.get_full_state().await.hit_breakpoints();
+        Ok(json!({
+            "hitBreakpoints": hit.iter().map(breakpoint_json).collect::<Vec<_>>(),
+            "unknownIds": unknown_ids
+        }))
+    }
+
+    async fn debugger_list_threads(&self, arguments: Value) -> Result<Value> {
+        let args: ListThreadsArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        if args.labels && session.language != "go" {
+            return Err(Error::InvalidRequest(format!(
+                "labels are pprof labels of Go goroutines; not available for {} sessions",
+                session.language
+            )));
+        }
+
+        let mut threads = Vec::new();
+        for (thread, run_state) in session.threads().await? {
+            let mut entry = match &run_state {
+                Some(ThreadState::Stopped { reason }) => json!({
+                    "id": thread.id,
+                    "name": thread.name,
+                    "state": "stopped",
+                    "reason": reason
+                }),
+                Some(ThreadState::Running) => json!({
+                    "id": thread.id,
+                    "name": thread.name,
+                    "state": "running"
+                }),
+                None => json!({
+                    "id": thread.id,
+                    "name": thread.name,
+                    "state": "unknown"
+                }),
+            };
+            // Labels are read per goroutine; one that can't be read doesn't
+            // fail the listing
+            if args.labels && !matches!(run_state, Some(ThreadState::Running)) {
+                match session.goroutine_labels(thread.id).await {
+                    Ok(labels) => entry["labels"] = json!(labels),
+                    Err(e) => entry["labelsError"] = json!(e.to_string()),
+                }
+            }
+            threads.push(entry);
+        }
+
+        Ok(json!({
+            "threads": threads
+        }))
+    }
+
+    async fn debugger_list_goroutines(&self, arguments: Value) -> Result<Value> {
+        let args: ListGoroutinesArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+        drop(manager);
+
+        let limit = args
+            .limit
+            .unwrap_or(DEFAULT_GOROUTINE_PAGE)
+            .clamp(1, MAX_GOROUTINE_PAGE);
+        let page = session.goroutines(args.offset, limit).await?;
+        Ok(json!(page))
+    }
+
+    async fn debugger_source_context(&self, arguments: Value) -> Result<Value> {
+        let args: SourceContextArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        let (source, frame_line) = match (args.frame_id, args.path) {
+            (Some(frame_id), _) => {
+                let frame = session
+                    .stack_trace()
+                    .await?
+                    .into_iter()
+                    .find(|f| f.id == frame_id)
+                    .ok_or_else(|| {
+                        Error::InvalidRequest(format!("Frame {} not found", frame_id))
+                    })?;
+                let source = frame.source.ok_or_else(|| {
+                    Error::InvalidRequest(format!("Frame {} has no source", frame_id))
+                })?;
+                (source, Some(frame.line))
+            }
+            (None, Some(path)) => {
+                // Frames of the stopped thread may point outside the
+                // workspace (module caches, the standard library)
+                let frames = session.stack_trace().await.unwrap_or_default();
+                (
+                    Source {
+                        name: None,
+                        path: Some(check_context_path(&path, &frames)?),
+                        source_reference: None,
+                        checksums: None,
+                    },
+                    None,
+                )
+            }
+            (None, None) => {
+                return Err(Error::InvalidRequest(
+                    "Either frameId or path is required".to_string(),
+                ))
+            }
+        };
+
+        let resolved = session.resolve_source(&source).await;
+        let mut response = json!({
+            "path": source.path,
+            "origin": resolved.origin,
+            "resolvedPath": resolved.resolved_path,
+            "module": resolved.module
+        });
+
+        if let Some(content) = &resolved.content {
+            let center = args.line.or(frame_line).unwrap_or(1).max(1) as usize;
+            let start = center.saturating_sub(args.context_lines).max(1);
+            let lines: Vec<Value> = content
+                .lines()
+                .enumerate()
+                .skip(start - 1)
+                .take(center + args.context_lines + 1 - start)
+                .map(|(i, text)| json!({ "line": i + 1, "text": text }))
+                .collect();
+            response["lines"] = json!(lines);
+        }
+
+        Ok(response)
+    }
+
+    async fn debugger_inline_values(&self, arguments: Value) -> Result<Value> {
+        let args: InlineValuesArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        let frame = session
+            .stack_trace()
+            .await?
+            .into_iter()
+            .find(|f| f.id == args.frame_id)
+            .ok_or_else(|| Error::InvalidRequest(format!("Frame {} not found", args.frame_id)))?;
+
+        let mut inline = session.inline_values(&frame).await?;
+        for value in &mut inline.values {
+            value.value = config::redact(&value.value);
+        }
+        let mut response = serde_json::to_value(inline)?;
+        response["frameId"] = json!(frame.id);
+        response["path"] = json!(frame.source.and_then(|s| s.path));
+        Ok(response)
+    }
+
+    async fn debugger_scopes(&self, arguments: Value) -> Result<Value> {
+        let args: ScopesArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        let scopes = session.scopes(args.frame_id).await?;
+        Ok(json!({
+            "frameId": args.frame_id,
+            "scopes": scopes
+        }))
+    }
+
+    async fn debugger_peek(&self, arguments: Value) -> Result<Value> {
+        let args: PeekArgs = serde_json::from_value(arguments)?;
+        crate::debug::peek::validate_names(&args.names)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        if !matches!(
+            session.get_state().await,
+            crate::debug::state::DebugState::Stopped { .. }
+        ) {
+            return Err(Error::InvalidState(
+                "Cannot peek while the program is running. Use debugger_wait_for_stop() to wait for the program to stop.".to_string(),
+            ));
+        }
+        let frame_id = match args.frame_id {
+            Some(frame_id) => frame_id,
+            None => session
+                .stack_trace()
+                .await?
+                .first()
+                .map(|frame| frame.id)
+                .ok_or_else(|| Error::InvalidState("No stack frames to peek into".to_string()))?,
+        };
+
+        let peek = session.peek(&args.names, frame_id).await?;
+        Ok(json!({
+            "frameId": frame_id,
+            "values": peek.values,
+            "missing": peek.missing,
+            "slices": peek.slices
+        }))
+    }
+
+    async fn debugger_dump_variable(&self, arguments: Value) -> Result<Value> {
+        let args: DumpVariableArgs = serde_json::from_value(arguments)?;
+        let limits = dump::Limits::new(args.max_depth, args.max_nodes)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        if !matches!(
+            session.get_state().await,
+            crate::debug::state::DebugState::Stopped { .. }
+        ) {
+            return Err(Error::InvalidState(
+                "Cannot dump a variable while the program is running. Use debugger_wait_for_stop() to wait for the program to stop.".to_string(),
+            ));
+        }
+        let frame_id = match args.frame_id {
+            Some(frame_id) => frame_id,
+            None => session
+                .stack_trace()
+                .await?
+                .first()
+                .map(|frame| frame.id)
+                .ok_or_else(|| Error::InvalidState("No stack frames to dump from".to_string()))?,
+        };
+
+        let dump = session.dump_variable(&args.path, frame_id, limits).await?;
+        Ok(json!({
+            "frameId": frame_id,
+            "path": args.path,
+            "value": dump.value,
+            "nodes": dump.nodes,
+            "truncated": dump.truncated
+        }))
+    }
+
+    async fn debugger_dereference(&self, arguments: Value) -> Result<Value> {
+        let args: DereferenceArgs = serde_json::from_value(arguments)?;
+        let limits = dump::Limits::new(args.max_depth, args.max_nodes)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        if !matches!(
+            session.get_state().await,
+            crate::debug::state::DebugState::Stopped { .. }
+        ) {
+            return Err(Error::InvalidState(
+                "Cannot dereference a pointer while the program is running. Use debugger_wait_for_stop() to wait for the program to stop.".to_string(),
+            ));
+        }
+
+        let expression = match (args.expression, args.variables_reference) {
+            (Some(expression), None) => expression,
+            (None, Some(reference)) => {
+                let dump = session.dump_reference(reference, limits).await?;
+                return Ok(json!({
+                    "variablesReference": reference,
+                    "nil": false,
+                    "value": dump.value,
+                    "nodes": dump.nodes,
+                    "truncated": dump.truncated
+                }));
+            }
+            _ => {
+                return Err(Error::InvalidRequest(
+                    "Pass either expression or variablesReference".to_string(),
+                ))
+            }
+        };
+        let frame_id = match args.frame_id {
+            Some(frame_id) => frame_id,
+            None => session
+                .stack_trace()
+                .await?
+                .first()
+                .map(|frame| frame.id)
+                .ok_or_else(|| Error::InvalidState("No stack frames to evaluate in".to_string()))?,
+        };
+
+        let (pointer, pointee) = session.dereference(&expression, frame_id, limits).await?;
+        let mut response = json!({
+            "frameId": frame_id,
+            "expression": expression,
+            "pointer": pointer.value,
+            "type": pointer.type_,
+            "nil": pointee.is_none(),
+            "value": null
+        });
+        if let Some(dump) = pointee {
+            response["value"] = dump.value;
+            response["nodes"] = json!(dump.nodes);
+            response["truncated"] = json!(dump.truncated);
+        }
+        Ok(response)
+    }
+
+    async fn debugger_analyze_hang(&self, arguments: Value) -> Result<Value> {
+        let args: AnalyzeHangArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        Ok(serde_json::to_value(session.analyze_hang().await?)?)
+    }
+
+    async fn debugger_list_async_tasks(&self, arguments: Value) -> Result<Value> {
+        let args: ListAsyncTasksArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        let mut tasks = session.list_async_tasks().await?;
+        for task in &mut tasks.tasks {
+            task.waiting_on = task.waiting_on.as_deref().map(config::redact);
+        }
+        Ok(serde_json::to_value(tasks)?)
+    }
+
+    async fn debugger_export_session(&self, arguments: Value) -> Result<Value> {
+        let args: ExportSessionArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        let crash_report = session.crash_report().await;
+        let transcript = session.transcript().await;
+        let state = session.get_full_state().await;
+
+        let mut breakpoints: Vec<&Breakpoint> = state.breakpoints.values().flatten().collect();
+        breakpoints.sort_by(|a, b| (&a.source_path, a.line).cmp(&(&b.source_path, b.line)));
+
+        Ok(json!({
+            "sessionId": session.id,
+            "language": session.language,
+            "program": session.program,
+            "startedAtMs": transcript.started_at_ms,
+            "durationMs": transcript.elapsed_ms(),
+            "state": state.state,
+            "launches": transcript.launches,
+            "breakpoints": breakpoints.into_iter().map(breakpoint_json).collect::<Vec<_>>(),
+            "instructionBreakpoints": state
+                .instruction_breakpoints
+                .iter()
+                .map(instruction_breakpoint_json)
+                .collect::<Vec<_>>(),
+            "stops": transcript.stops,
+            "evaluations": transcript.evaluations,
+            "exitCode": transcript.exit_code,
+            "crashReport": crash_report,
+            "breakpointSummary": state.breakpoint_summary,
+            "droppedRecords": transcript.dropped_records
+        }))
+    }
+
+    async fn debugger_get_config(&self) -> Result<Value> {
+        Ok(json!({
+            "source": config::source().map(|p| p.display().to_string()),
+            "config": config::current().effective(),
+        }))
+    }
+
+    async fn debugger_set_log_level(&self, arguments: Value) -> Result<Value> {
+        let args: SetLogLevelArgs = serde_json::from_value(arguments)?;
+
+        if let Some(session_id) = &args.session_id {
+            let manager = self.session_manager.read().await;
+            manager.get_session(session_id).await?;
+        }
+        let previous = log_level::set_level(args.session_id.as_deref(), &args.level)?;
+
+        Ok(json!({
+            "level": log_level::level(args.session_id.as_deref()),
+            "previousLevel": previous,
+            "sessionId": args.session_id
+        }))
+    }
+
+    async fn debugger_get_metrics(&self, arguments: Value) -> Result<Value> {
+        let args: GetMetricsArgs = serde_json::from_value(arguments)?;
+
+        let mut response = json!({
+            "aggregate": crate::dap::metrics::aggregate().report()
+        });
+        if let Some(session_id) = &args.session_id {
+            let manager = self.session_manager.read().await;
+            let session = manager.get_session(session_id).await?;
+            response["session"] = json!(session.metrics());
+            response["requestQueue"] = json!(session.request_queue().await);
+            response["sessionId"] = json!(session_id);
+        }
+        Ok(response)
+    }
+
+    async fn debugger_watch_stop(&self, arguments: Value) -> Result<Value> {
+        let args: WatchStopArgs = serde_json::from_value(arguments)?;
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+        let status = session.file_watch().await;
+        session.set_file_watch(None).await;
+        Ok(json!({
+            "sessionId": session.id,
+            "wasWatching": status.is_some(),
+            "relaunches": status.map_or(0, |status| status.relaunches)
+        }))
+    }
+
+    async fn debugger_get_output(&self, arguments: Value) -> Result<Value> {
+        let args: GetOutputArgs = serde_json::from_value(arguments)?;
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+        let log = session.output_log().await;
+        let bytes = log.tail(args.max_bytes.unwrap_or(output_log::MAX_OUTPUT_BYTES));
+        let omitted = log.dropped() + (log.bytes().len() - bytes.len()) as u64;
+        let (text, lossy) = output_log::decode(&bytes);
+        // Redacted like all output; the kept bytes are text, so redacting
+        // the text keeps them exact elsewhere
+        let text = config::redact(&text);
+        let mut response = json!({
+            "sessionId": args.session_id,
+            "omittedBytes": omitted,
+            "encodingLossy": lossy
+        });
+        if args.base64 {
+            response["outputBase64"] = json!(output_log::base64(text.as_bytes()));
+        } else {
+            response["output"] = json!(text);
+        }
+        Ok(response)
+    }
+
+    async fn debugger_get_post_mortem(&self, arguments: Value) -> Result<Value> {
+        let args: GetPostMortemArgs = serde_json::from_value(arguments)?;
+        let manager = self.session_manager.read().await;
+        let post_mortem = manager.get_post_mortem(&args.session_id).await?;
+        Ok(json!(post_mortem))
+    }
+
+    async fn debugger_resource_usage(&self, arguments: Value) -> Result<Value> {
+        let args: ResourceUsageArgs = serde_json::from_value(arguments)?;
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+        let mut response = json!({
+            "sessionId": args.session_id,
+            "intervalMs": resource_usage::SAMPLE_INTERVAL.as_millis() as u64
+        });
+        let state = session.get_full_state().await;
+        match &state.resource_usage {
+            Some(usage) => {
+                response["usage"] = json!(state.resource_summary());
+                response["samples"] = json!(usage.samples());
+            }
+            None => {
+                response["usage"] = Value::Null;
+                response["message"] =
+                    json!("The debugger hasn't reported the program's pid (yet); sampling starts when it does");
+            }
+        }
+        Ok(response)
+    }
+
+    async fn debugger_webhook_deliveries(&self, arguments: Value) -> Result<Value> {
+        let args: WebhookDeliveriesArgs = serde_json::from_value(arguments)?;
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+        Ok(match session.webhook_deliveries().await {
+            Some((url, deliveries)) => json!({
+                "sessionId": args.session_id,
+                "webhookUrl": url,
+                "deliveries": deliveries
+            }),
+            None => json!({
+                "sessionId": args.session_id,
+                "webhookUrl": null,
+                "deliveries": []
+            }),
+        })
+    }
+
+    async fn debugger_info(&self) -> Result<Value> {
+        let config = config::current();
+        Ok(json!({
+            "name": "debugger_mcp",
+            "version": crate::mcp::SERVER_VERSION,
+            "gitCommit": crate::mcp::GIT_COMMIT,
+            "transports": crate::mcp::TRANSPORTS,
+            "limits": {
+                "maxSessions": config.sessions.max_sessions,
+                "idleTimeoutSecs": config.sessions.idle_timeout_secs,
+                "maxResponseBytes": config.limits.max_response_bytes
+            },
+            "languages": crate::adapters::availability::languages().await
+        }))
+    }
+
+    async fn debugger_export_breakpoints(&self, arguments: Value) -> Result<Value> {
+        let args: ExportBreakpointsArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        Ok(serde_json::to_value(session.export_breakpoints().await)?)
+    }
+
+    async fn debugger_import_breakpoints(&self, arguments: Value) -> Result<Value> {
+        let args: ImportBreakpointsArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        let report = session.import_breakpoints(&args.document).await?;
+        let (results, failed) = import_results(&args.document, report);
+
+        // Expressions to evaluate once stopped aren't a session's to track
+        let mut ignored = Vec::new();
+        if args
+            .document
+            .watches
+            .iter()
+            .any(|watch| matches!(watch, WatchEntry::Expression(_)))
+        {
+            ignored.push("watches");
+        }
+
+        Ok(json!({
+            "results": results,
+            "failed": failed,
+            "ignored": ignored
+        }))
+    }
+
+    async fn debugger_save_config(&self, arguments: Value) -> Result<Value> {
+        let args: SaveConfigArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let (start, mut breakpoints) = match (args.session_id, args.start) {
+            (Some(session_id), None) => {
+                let session = manager.get_session(&session_id).await?;
+                let start = session.start_arguments().await.ok_or_else(|| {
+                    Error::InvalidRequest(format!(
+                        "Session {} has no start arguments to save (it wasn't started with debugger_start)",
+                        session_id
+                    ))
+                })?;
+                (start, session.export_breakpoints().await)
+            }
+            (None, Some(start)) => {
+                let language = start
+                    .get("language")
+                    .and_then(Value::as_str)
+                    .unwrap_or_default()
+                    .to_string();
+                let document = BreakpointDocument {
+                    version: BREAKPOINT_DOCUMENT_VERSION,
+                    language,
+                    breakpoints: Vec::new(),
+                    watches: Vec::new(),
+                    exception_filters: Vec::new(),
+                };
+                (start, document)
+            }
+            _ => {
+                return Err(Error::InvalidRequest(
+                    "Specify either sessionId or start".to_string(),
+                ))
+            }
+        };
+        if let Some(entries) = args.breakpoints {
+            breakpoints.breakpoints = entries;
+        }
+        breakpoints
+            .watches
+            .extend(args.watches.into_iter().map(WatchEntry::Expression));
+
+        let workspace = args
+            .workspace
+            .as_deref()
+            .map(|w| security::validate_directory_path(w).map(|p| p.display().to_string()))
+            .transpose()?;
+        let config = LaunchConfig {
+            name: args.name,
+            workspace,
+            start,
+            breakpoints,
+            saved_at_ms: std::time::SystemTime::now()
+                .duration_since(std::time::UNIX_EPOCH)
+                .map_or(0, |d| d.as_millis() as u64),
+        };
+        let replaced = manager.save_launch_config(config.clone()).await?;
+
+        Ok(json!({
+            "name": config.name,
+            "workspace": config.workspace,
+            "replaced": replaced,
+            "config": config
+        }))
+    }
+
+    async fn debugger_load_config(&self, arguments: Value) -> Result<Value> {
+        let args: LoadConfigArgs = serde_json::from_value(arguments)?;
+
+        let workspace = args
+            .workspace
+            .as_deref()
+            .map(|w| security::validate_directory_path(w).map(|p| p.display().to_string()))
+            .transpose()?;
+        let config = {
+            let manager = self.session_manager.read().await;
+            manager
+                .launch_config(workspace.as_deref(), &args.name)
+                .await?
+        };
+        // Saved configs were valid then; the program and paths are checked again now
+        config.validate()?;
+
+        // The breakpoints go out with the launch, before configurationDone
+        let (hold, gate) = launch_gate();
+        let mut response = self.start_held(Value::Object(config.start), gate).await?;
+        let session_id = response["sessionId"]
+            .as_str()
+            .unwrap_or_default()
+            .to_string();
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&session_id).await?;
+        let report = session.import_breakpoints(&config.breakpoints).await;
+        drop(hold);
+        let report = report?;
+        let (results, failed) = import_results(&config.breakpoints, report);
+        let expressions: Vec<&String> = config
+            .breakpoints
+            .watches
+            .iter()
+            .filter_map(|watch| match watch {
+                WatchEntry::Expression(expression) => Some(expression),
+                WatchEntry::Change { .. } => None,
+            })
+            .collect();
+
+        response["config"] = json!(config.name);
+        response["breakpoints"] = json!(results);
+        response["failed"] = json!(failed);
+        response["watches"] = json!(expressions);
+        Ok(response)
+    }
+
+    async fn debugger_start_from_launch_json(&self, arguments: Value) -> Result<Value> {
+        let args: StartFromLaunchJsonArgs = serde_json::from_value(arguments)?;
+
+        let path = security::validate_source_path(&args.path, Some("json"))?;
+        let text = std::fs::read_to_string(&path)?;
+        let document = launch_json::parse(&text)?;
+        let mut configuration = launch_json::configuration(&document, &args.configuration_name)?;
+        let variables = launch_json::apply_overrides(
+            &mut configuration,
+            &args.overrides,
+            launch_json::Variables::for_file(&path),
+        )?;
+        let imported = launch_json::translate(&configuration, &variables)?;
+
+        // debugger_start checks the program, paths and options as usual
+        let mut response = self
+            .debugger_start(Value::Object(imported.start.clone()))
+            .await?;
+        response["launchJson"] = json!({
+            "path": path,
+            "configuration": imported
+        });
+        Ok(response)
+    }
+
+    async fn debugger_toggle_breakpoint(&self, arguments: Value, enabled: bool) -> Result<Value> {
+        let args: ToggleBreakpointArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        let bp = session
+            .set_breakpoint_enabled(args.breakpoint_id, enabled)
+            .await?;
+
+        Ok(breakpoint_json(&bp))
+    }
+
+    async fn debugger_step_over(&self, arguments: Value) -> Result<Value> {
+        let args: StepArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        // Validate we're in a stopped state
+        let state = session.get_state().await;
+        let thread_id = if let crate::debug::state::DebugState::Stopped { thread_id, .. } = state {
+            thread_id
+        } else {
+            return Err(Error::InvalidState(
+                "Cannot step while program is running. The program must be stopped first."
+                    .to_string(),
+            ));
+        };
+
+        let thread_id = args.thread_id.unwrap_or(thread_id);
+        session.ensure_thread_stopped(thread_id).await?;
+        let since = session.events_seq().await;
+        let granularity = session.step_over(thread_id, args.granularity).await?;
+
+        let response =
+            filtered_step_json(&session, thread_id, since, StepReturns::StepOver).await?;
+        Ok(with_granularity(response, args.granularity, granularity))
+    }
+
+    async fn debugger_step_into(&self, arguments: Value) -> Result<Value> {
+        let args: StepArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        // Validate we're in a stopped state
+        let state = session.get_state().await;
+        let thread_id = if let crate::debug::state::DebugState::Stopped { thread_id, .. } = state {
+            thread_id
+        } else {
+            return Err(Error::InvalidState(
+                "Cannot step while program is running. The program must be stopped first."
+                    .to_string(),
+            ));
+        };
+
+        let thread_id = args.thread_id.unwrap_or(thread_id);
+        session.ensure_thread_stopped(thread_id).await?;
+        let since = session.events_seq().await;
+        let granularity = session.step_into(thread_id, args.granularity).await?;
+
+        let response = filtered_step_json(&session, thread_id, since, StepReturns::None).await?;
+        Ok(with_granularity(response, args.granularity, granularity))
+    }
+
+    async fn debugger_step_out(&self, arguments: Value) -> Result<Value> {
+        let args: StepArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        // Validate we're in a stopped state
+        let state = session.get_state().await;
+        let thread_id = if let crate::debug::state::DebugState::Stopped { thread_id, .. } = state {
+            thread_id
+        } else {
+            return Err(Error::InvalidState(
+                "Cannot step while program is running. The program must be stopped first."
+                    .to_string(),
+            ));
+        };
+
+        let thread_id = args.thread_id.unwrap_or(thread_id);
+        session.ensure_thread_stopped(thread_id).await?;
+        let since = session.events_seq().await;
+        let granularity = session.step_out(thread_id, args.granularity).await?;
+
+        // Wait for the step to finish so the returned values can be reported
+        let response = filtered_step_json(&session, thread_id, since, StepReturns::StepOut).await?;
+        Ok(with_granularity(response, args.granularity, granularity))
+    }
+
+    async fn debugger_step_out_of_file(&self, arguments: Value) -> Result<Value> {
+        let args: StepOutOfFileArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        let state = session.get_state().await;
+        let thread_id = if let crate::debug::state::DebugState::Stopped { thread_id, .. } = state {
+            thread_id
+        } else {
+            return Err(Error::InvalidState(
+                "Cannot step while program is running. The program must be stopped first."
+                    .to_string(),
+            ));
+        };
+
+        let thread_id = args.thread_id.unwrap_or(thread_id);
+        let max_steps = args
+            .max_steps
+            .unwrap_or(DEFAULT_FILE_STEPS)
+            .clamp(1, MAX_FILE_STEPS);
+        let outcome = session.step_out_of_file(thread_id, max_steps).await?;
+
+        let status = match &outcome.end {
+            FileStepEnd::Left => "left",
+            FileStepEnd::Interrupted { .. } => "interrupted",
+            FileStepEnd::Terminated => "terminated",
+            FileStepEnd::StillRunning => "running",
+            FileStepEnd::LimitReached => "limitReached",
+        };
+        let mut response = json!({
+            "status": status,
+            "steps": outcome.steps,
+            "fromPath": outcome.from_path,
+            "threadId": thread_id
+        });
+        if let FileStepEnd::Interrupted { reason } = &outcome.end {
+            response["reason"] = json!(reason);
+        }
+        if let Some(frame) = outcome.location {
+            response["location"] = json!({
+                "function": frame.name,
+                "path": frame.source.and_then(|s| s.path),
+                "line": frame.line
+            });
+        }
+        Ok(response)
+    }
+
+    async fn debugger_step_to_line(&self, arguments: Value) -> Result<Value> {
+        let args: StepToLineArgs = serde_json::from_value(arguments)?;
+        if args.line < 1 {
+            return Err(Error::InvalidRequest(format!(
+                "line must be 1 or greater, got {}",
+                args.line
+            )));
+        }
+
+        let manager = self.session_manager.read().await;
+        let session = manager.get_session(&args.session_id).await?;
+
+        let state = session.get_state().await;
+        let thread_id = if let crate::debug::state::DebugState::Stopped { thread_id, .. } = state {
+            thread_id
+        } else {
+            return Err(Error::InvalidState(
+                "Cannot step while program is running. The program must be stopped first."
+                    .to_string(),
+            ));
+        };
+
+        let thread_id = args.thread_id.unwrap_or(thread_id);
+        session.ensure_thread_stopped(thread_id).await?;
+        let max_steps = args
+            .max_steps
+            .unwrap_or(DEFAULT_FILE_STEPS)
+            .clamp(1, MAX_FILE_STEPS);
+        let outcome = session
+            .step_to_line(thread_id, args.line, max_steps)
+            .await?;
+
+        let status = match &outcome.end {
+            LineStepEnd::Reached => "reached",
+            LineStepEnd::Interrupted { .. } => "interrupted",
+            LineStepEnd::Returned => "returned",
+            LineStepEnd::Terminated => "terminated",
+            LineStepEnd::StillRunning => "running",
+            LineStepEnd::LimitReached => "limitReached",
+        };
+        let mut response = json!({
+            "status": status,
+            "steps": outcome.steps,
+            "line": args.line,
+            "threadId": thread_id
+        });
+        if let LineStepEnd::Interrupted { reason } = &outcome.end {
+            response["reason"] = json!(reason);
+        }
+        if let Some(frame) = outcome.location {
+            response["location"] = json!({
+                "function": frame.name,
+                "path": frame.source.and_then(|s| s.path),
+                "line": frame.line
+            });
+        }
+        Ok(response)
+    }
+
+    async fn debugger_disconnect(&self, arguments: Value) -> Result<Value> {
+        let args: DisconnectArgs = serde_json::from_value(arguments)?;
+
+        let manager = self.session_manager.write().await;
+        if args.restart {
+            if let Some(grace_period) = manager.park_session(&args.session_id).await? {
+                return Ok(json!({
+                    "status": "parked",
+                    "gracePeriodMs": grace_period.as_millis() as u64
+                }));
+            }
+        } else {
+            let session = manager.get_session(&args.session_id).await?;
+            manager.remove_session(&args.session_id).await?;
+            if session.start_cancelled() {
+                return Ok(json!({
+                    "status": "disconnected",
+                    "startCancelled": true
+                }));
+            }
+        }
+
+        Ok(json!({
+            "status": "disconnected"
+        }))
+    }
+
+    async fn debugger_restart(&self, arguments: Value) -> Result<Value> {
+        let args: RestartArgs = serde_json::from_value(arguments)?;
+        let session = self
+            .session_manager
+            .read()
+            .await
+            .get_session(&args.session_id)
+            .await?;
+        let start = session.start_arguments().await.ok_or_else(|| {
+            Error::InvalidState(format!("Session {} has no start arguments", session.id))
+        })?;
+        if start.get("mode").and_then(Value::as_str) == Some("attach") {
+            return Err(Error::InvalidRequest(
+                "restart is only supported for launched programs; an attached process can't be started again".to_string(),
+            ));
+        }
+
+        if session.supports_restart().await {
+            session.restart_in_place().await?;
+            return Ok(json!({
+                "sessionId": session.id,
+                "method": "restartRequest",
+                "state": session.get_state().await
+            }));
+        }
+
+        let (new_id, failed) = self.start_again(&session).await?;
+        let new_session = self
+            .session_manager
+            .read()
+            .await
+            .get_session(&new_id)
+            .await?;
+        let mut response = json!({
+            "sessionId": new_id,
+            "previousSessionId": session.id,
+            "method": "relaunch",
+            "state": new_session.get_state().await
+        });
+        if !failed.is_empty() {
+            response["failedBreakpoints"] = json!(failed);
+        }
+        Ok(response)
+    }
+
+    pub fn list_tools() -> Vec<Value> {
+        vec![
+            json!({
+                "name": "debugger_start",
+                "title": "Start Debugging Session",
+                "description": "Starts a new debugging session for a program. RETURNS IMMEDIATELY with a sessionId while initialization happens asynchronously in the background.\n\nIMPORTANT WORKFLOW:\n1. Call this tool first to create a session\n2. Use debugger_wait_for_stop to wait for entry point (if stopOnEntry: true)\n3. Once stopped, set breakpoints with debugger_set_breakpoint\n4. Control execution with debugger_continue\n\nTIMING: Returns in <100ms. Background initialization takes 200-500ms. \"launch\" has the phases of the start so far; debugger_session_state follows the rest.\n\n⭐ CRITICAL: stopOnEntry Parameter\n=================================\nFor reliable breakpoint debugging, ALWAYS use stopOnEntry: true:\n\n✅ RECOMMENDED (with stopOnEntry: true):\n  - Program pauses at first executable line\n  - Gives you time to set breakpoints before execution\n  - Prevents program from completing before breakpoints are set\n  - Required for debugging programs that execute quickly\n\n❌ NOT RECOMMENDED (stopOnEntry: false or omitted):\n  - Program runs immediately upon start\n  - May complete before breakpoints can be set\n  - Breakpoints might be missed\n  - Only use if you don't need breakpoints\n\nEXAMPLE WORKFLOW:\n  debugger_start({program: \"app.py\", stopOnEntry: true})\n  debugger_wait_for_stop()  // Wait for entry point\n  debugger_set_breakpoint({line: 20})  // Set while paused ✓\n  debugger_continue()  // Now resume to breakpoint\n\nSEE ALSO: debugger_wait_for_stop (efficient waiting), debugger_session_state (state checking), debugger://workflows (complete examples)",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "language": {
+                            "type": "string",
+                            "description": "Programming language (e.g., 'python', 'ruby', 'javascript', 'rust', 'go', 'java')"
+                        },
+                        "program": {
+                            "type": "string",
+                            "description": "Absolute or relative path to the program file to debug (required in launch mode). For nodejs a TypeScript file (.ts) may be given: it runs as the JavaScript compiled from it, found through its source map, and breakpoints and stack frames stay in .ts coordinates. Compile with \"sourceMap\": true first"
+                        },
+                        "args": {
+                            "type": "array",
+                            "items": { "type": "string" },
+                            "description": "Command-line arguments passed to the program (optional, defaults to empty array)"
+                        },
+                        "cwd": {
+                            "type": "string",
+                            "description": "Working directory for the program execution (optional, defaults to program's directory)"
+                        },
+                        "stopOnEntry": {
+                            "type": "boolean",
+                            "description": "If true, pauses execution at the program's first line (recommended for setting early breakpoints)"
+                        },
+                        "autoContinueFromEntry": {
+                            "type": "boolean",
+                            "description": "With stopOnEntry or entry: continue from the entry stop by itself, without a debugger_continue call. What debugger_start sets up itself (the entry breakpoint) is in place before the program runs; breakpoints set with debugger_set_breakpoint after debugger_start returns are not waited for and may arrive after the code they are on has run, so to be sure of those leave this off and call debugger_continue once they are set. A breakpoint on the entry line itself is not skipped: the stop is kept and reported as its hit (reason 'breakpoint'). Launch mode only; not supported for Node.js (default: false)"
+                        },
+                        "justMyCode": {
+                            "type": "boolean",
+                            "description": "Python only: step and stop in the program's own code only, not in the standard library or installed packages (debugpy's justMyCode). Defaults to the server's python.just_my_code, else true"
+                        },
+                        "pythonPath": {
+                            "type": "string",
+                            "description": "Python only: absolute path of the interpreter to run the program with (e.g. a virtualenv's bin/python or /usr/bin/python3.12) instead of the server's python. The response reports its version as toolchain: {path, version}"
+                        },
+                        "goPath": {
+                            "type": "string",
+                            "description": "Go only (modes debug and test): absolute path of the go binary Delve builds the program with (e.g. /usr/local/go1.21/bin/go) instead of go from PATH; it must be named go. The response reports its version as toolchain: {path, version}"
+                        },
+                        "webhookUrl": {
+                            "type": "string",
+                            "description": "POST the session's events to this http(s) URL as JSON {event, sessionId, language, program, timestampMs, body}: stopped (body: threadId, reason, allThreadsStopped, hitBreakpointIds), exited (exitCode, and diagnosis as in debugger_session_state when no breakpoint was hit) and crashed (the crash report, sent when the program stops on an unhandled exception or panic). The server must allow the URL (webhooks.allowed_urls in its configuration); otherwise the start fails. A failed delivery is retried once; see debugger_webhook_deliveries. Defaults to the server's webhooks.default_url"
+                        },
+                        "verifySource": {
+                            "type": "boolean",
+                            "description": "Check that breakpoints are set on the source the program runs: breakpoints in a file whose content changed since the launch (edited but not rebuilt or reloaded; compared by SHA256, not modification time) get a sourceWarning from debugger_set_breakpoint, and debuggers reporting supportedChecksumAlgorithms get SHA256 checksums of the file. Can be changed later with debugger_configure (default: false)"
+                        },
+                        "entry": {
+                            "type": "string",
+                            "enum": ["user_main"],
+                            "description": "Stop once at the start of the program's own code, with stop reason 'entry' like stopOnEntry. Unlike stopOnEntry, which stops in the Go runtime's bootstrap for Go, 'user_main' stops at: go: main.main (function breakpoint; not in 'test' mode); python: the program file's first executable line ('program' mode only); ruby: the program file's first executable line. The breakpoint is removed by its first stop. Not supported in attach mode or for other languages"
+                        },
+                        "keepAliveIntervalMs": {
+                            "type": "integer",
+                            "description": "Send a lightweight keep-alive request to the adapter at this interval (optional, off by default). Useful for long-lived sessions whose connection may be dropped by intermediaries; if a keep-alive fails the session moves to 'Failed' with the reason."
+                        },
+                        "stopPollIntervalMs": {
+                            "type": "integer",
+                            "description": "Poll the adapter at this interval for stops whose 'stopped' event was lost (optional, off by default). Safety net for flaky adapters: each poll costs one threads request plus one stackTrace request per running thread, so use intervals of a few seconds."
+                        },
+                        "keepAdapterWarm": {
+                            "type": "boolean",
+                            "description": "Go only. After debugger_disconnect with restart: true, keep a Delve running for warmGracePeriodMs so the next debugger_start of the same program launches on it instead of spawning a new adapter. Delve exits with its session (it has no DAP restart request), so a spare Delve is spawned at the disconnect and kept instead. The start result then includes adapterReused and savedStartupMs."
+                        },
+                        "warmGracePeriodMs": {
+                            "type": "integer",
+                            "description": "How long a kept-warm adapter waits for the next start before shutting down (default 30000)"
+                        },
+                        "onUncaught": {
+                            "type": "string",
+                            "enum": ["break", "terminate"],
+                            "description": "What happens when an exception or panic nothing handles reaches the top: 'break' stops there for post-mortem inspection (stop reason 'exception' or 'panic'); 'terminate' lets the program die as it would without a debugger, so runs that aren't watched don't hang on the stop. Stops asked for with debugger_set_exception_breakpoints still happen. Default: 'break' where the debugger can stop there (python, go), else 'terminate' (ruby, nodejs, rust). 'break' for the others fails with an unsupported capability error. The response reports the behavior in effect as onUncaught. Can't be 'terminate' with captureOnException"
+                        },
+                        "watch": {
+                            "type": "boolean",
+                            "description": "Relaunch the program whenever its sources change: the language's source files in the program's directory (for Go, the package directory) are polled, and once changes settle for watchDebounceMs the program is started again with the same arguments and breakpoints. The relaunch is a new session; the old sessionId keeps working and leads to it. Never relaunches while the program is stopped or starting. debugger_session_state reports watch (relaunches, lastRelaunch, error) and the webhook gets a 'relaunched' event. Stop with debugger_watch_stop. Not for attach, python module or go exec mode (default: false)"
+                        },
+                        "watchDebounceMs": {
+                            "type": "integer",
+                            "description": "With watch, quiet time after the last change before relaunching (default: 500, max: 60000)"
+                        },
+                        "targetExecPrefix": {
+                            "type": "array",
+                            "items": {"type": "string"},
+                            "description": "Launch mode only (python, java, go, ruby): run the adapter, and with it the program, through this command in another container or namespace, e.g. [\"docker\", \"exec\", \"-i\", \"app\"] or [\"nsenter\", \"-t\", \"4242\", \"-m\", \"-p\"]. The adapter must be installed in the target and paths are the target's (mount sources at the same path, or use goOptions.substitutePath). python and java talk DAP over the command's stdio; go and ruby listen on 127.0.0.1, so the target must share the server's network namespace. The adapter is started through sh in the target, which records its pid: when the session ends or fails to start, the adapter and the program's processes are killed through the same command. Disabled unless the server configuration sets security.allow_target_exec_prefix, since it runs arbitrary commands"
+                        },
+                        "readOnly": {
+                            "type": "boolean",
+                            "description": "Attach mode only: guarantee the session can't change the process, e.g. in production. Blocked with a ReadOnlySession error (code -32015): setting variables or expressions, writing memory, goto, restartFrame, terminate, the REPL, debugger_watch_change, and evaluations that may call functions (debugger_evaluate always runs as with noSideEffects, so it works for Node.js and Go only). Disconnecting leaves the process running. Breakpoints, stepping and inspection stay allowed, though they still perturb timing. Fixed for the session's lifetime and shown as readOnly in debugger_session_state and debugger://sessions (default: false)"
+                        },
+                        "captureOnException": {
+                            "type": "boolean",
+                            "description": "When the program stops on an exception or panic, automatically snapshot the exception, the top 50 stack frames and up to 50 top-frame locals into exceptionCapture of debugger_wait_for_stop and debugger_session_state (kept after termination as a post-mortem). Python also turns on stops for uncaught exceptions; Go stops on unrecovered panics by itself; for Ruby set exception breakpoints with debugger_set_exception_breakpoints (default: false)"
+                        },
+                        "mode": {
+                            "type": "string",
+                            "description": "How to start the debuggee; 'launch' (default) means the language's default mode.\n- go: 'debug' (default, program is a .go file), 'test' (program is a package directory or _test.go file), 'exec' (program is a pre-built executable binary; Delve runs it without rebuilding, see goOptions.substitutePath for relocated sources), 'attach'\n- python: 'program' (default, program is a .py file), 'module' (program is a module name, like python -m), 'pytest' (program is a test file or directory), 'attach'\n- java: 'launch' (default, program is a built .jar or a fully qualified main class, see javaOptions), 'attach' (to a JVM's JDWP agent at javaOptions.hostName:port)\n- other languages: 'launch' only\n'attach' attaches to a running process given processId or processName; for java, to a JDWP address."
+                        },
+                        "module": {
+                            "type": "string",
+                            "description": "Python only: module to run instead of a program file, like 'python -m package.name' (same as mode 'module' with program set to the module name). Set cwd to the directory the module is importable from; args and env apply as usual."
+                        },
+                        "env": {
+                            "type": "object",
+                            "additionalProperties": { "type": "string" },
+                            "description": "Python only: extra environment variables for the program"
+                        },
+                        "processId": {
+                            "type": "integer",
+                            "description": "Attach mode: pid of the process to attach to"
+                        },
+                        "goOptions": {
+                            "type": "object",
+                            "description": "Go only: {substitutePath: [{from, to}]} maps local source directories ('from') to the paths recorded in the binary's debug info ('to'), so breakpoints resolve in binaries built elsewhere (typically with mode 'exec'). {output} is where Delve writes the binary it builds in 'debug' and 'test' mode (e.g. '/tmp/app.debug' when the source tree is read-only); by default it goes to a temporary directory of the session that is removed when the session ends. {followChildren: true} makes Delve follow processes the program starts with fork/exec (Linux, Delve 1.24+): breakpoints hit in them too, and debugger_session_state lists them as childProcesses. The children are debugged within this session, on its connection to Delve; no separate session is created for them: their stops are this session's (with the child's threadId), and debugger_continue, stepping and inspection apply to whichever process stopped",
+                            "properties": {
+                                "substitutePath": {
+                                    "type": "array",
+                                    "items": {
+                                        "type": "object",
+                                        "properties": {
+                                            "from": { "type": "string" },
+                                            "to": { "type": "string" }
+                                        },
+                                        "required": ["from", "to"]
+                                    }
+                                },
+                                "output": {
+                                    "type": "string",
+                                    "description": "Path of the binary Delve builds"
+                                },
+                                "followChildren": { "type": "boolean" }
+                            }
+                        },
+                        "rubyOptions": {
+                            "type": "object",
+                            "description": "Ruby only: {bundler: true} runs the program via 'bundle exec' (set cwd to the directory with the Gemfile); {rails: true} treats program as the Rails executable (e.g. bin/rails) and runs 'server' unless args are given",
+                            "properties": {
+                                "bundler": { "type": "boolean" },
+                                "rails": { "type": "boolean" }
+                            }
+                        },
+                        "javaOptions": {
+                            "type": "object",
+                            "description": "Java only. The program must be built already: program is a .jar (its manifest's Main-Class is run unless mainClass is given) or a fully qualified main class loaded from classPath. Breakpoints in classes not loaded yet are unverified until the JVM loads them; the debugger then binds them and debugger_list_breakpoints shows them verified. In attach mode, hostName (default 127.0.0.1) and port (default 5005) name the JDWP agent of a JVM started with -agentlib:jdwp=transport=dt_socket,server=y,address=<port>",
+                            "properties": {
+                                "mainClass": { "type": "string" },
+                                "classPath": { "type": "array", "items": { "type": "string" } },
+                                "vmArgs": { "type": "array", "items": { "type": "string" } },
+                                "hostName": { "type": "string" },
+                                "port": { "type": "integer" }
+                            }
+                        },
+                        "processName": {
+                            "type": "string",
+                            "description": "Attach mode: substring of the process name or command line. Attaches if exactly one process matches; otherwise fails with the candidate list (pid, cmdline, user, startTime) in error.data so you can retry with processId"
+                        }
+                    },
+                    "required": ["language"]
+                },
+                "annotations": {
+                    "async": true,
+                    "returnsTiming": "< 100ms",
+                    "completionTiming": "200-500ms (background)",
+                    "workflow": "initialization",
+                    "requiredFollowUp": ["debugger_session_state"],
+                    "category": "session-management",
+                    "priority": 1.0
+                }
+            }),
+            json!({
+                "name": "debugger_start_group",
+                "title": "Start Session Group",
+                "description": "Starts several programs under one session group, e.g. a server and its client or a producer and its consumer.\n\nEach entry of configs takes the arguments of debugger_start plus a unique member name. Members are started in order; if one fails to start, the members already started are disconnected and nothing is left running.\n\nEvery member is an ordinary session: set breakpoints, step and evaluate with its own sessionId. The group tools fan out to all members: debugger_group_continue, debugger_group_state and debugger_group_disconnect. Stops of a member carry \"member\": {\"groupId\", \"name\"} in debugger_wait_for_stop and debugger_session_state.\n\nTIMING: Returns once every member is launching (about 100ms per member); wait for members with debugger_session_state or debugger_group_continue({waitForStop: true})\n\nRETURNS: {\"groupId\", \"members\": [{\"name\", \"sessionId\", \"status\", ...}]} (each member as returned by debugger_start)",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "configs": {
+                            "type": "array",
+                            "description": "One debugger_start configuration per program, each with a member name",
+                            "items": {
+                                "type": "object",
+                                "properties": {
+                                    "name": {
+                                        "type": "string",
+                                        "description": "Member name, unique in the group (e.g. \"server\")"
+                                    },
+                                    "language": {
+                                        "type": "string",
+                                        "description": "Programming language, as for debugger_start"
+                                    }
+                                },
+                                "required": ["name", "language"]
+                            },
+                            "minItems": 1
+                        }
+                    },
+                    "required": ["configs"]
+                },
+                "annotations": {
+                    "async": true,
+                    "returnsTiming": "100ms per member",
+                    "workflow": "initialization",
+                    "category": "session-management",
+                    "priority": 0.6
+                }
+            }),
+            json!({
+                "name": "debugger_group_continue",
+                "title": "Continue Session Group",
+                "description": "Resumes every stopped member of a session group. Members that are already running or have ended are left alone.\n\nWith waitForStop: true, blocks until the first member stops and returns it as firstStopped, which tells you whether the server or the client hit its breakpoint first. If every member ends without stopping, firstStopped is null and allEnded is true.\n\nTIMING: Returns in 10-100ms, or when a member stops with waitForStop\n\nRETURNS: {\"groupId\", \"members\": [{\"name\", \"sessionId\", \"status\": \"continued\" | \"running\" | \"ended\" | \"disconnected\" | \"failed\", \"error\"?}], \"firstStopped\"?: {\"name\", \"sessionId\", \"state\", \"threadId\", \"reason\"}, \"allEnded\"?}",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "groupId": {
+                            "type": "string",
+                            "description": "Group ID from debugger_start_group"
+                        },
+                        "waitForStop": {
+                            "type": "boolean",
+                            "description": "Wait for the first member to stop (default: false)"
+                        },
+                        "timeoutMs": {
+                            "type": "integer",
+                            "description": "How long to wait with waitForStop (default: the server's wait_for_stop_ms)",
+                            "minimum": 1
+                        }
+                    },
+                    "required": ["groupId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "10-100ms",
+                    "workflow": "execution",
+                    "category": "execution-control",
+                    "priority": 0.6
+                }
+            }),
+            json!({
+                "name": "debugger_group_state",
+                "title": "Get Session Group State",
+                "description": "Returns the state of every member of a session group, and which of the stopped members stopped first.\n\nMembers whose session was disconnected on its own are reported with state \"Disconnected\".\n\nTIMING: Returns immediately (<10ms)\n\nRETURNS: {\"groupId\", \"members\": [{\"name\", \"sessionId\", \"state\", \"threadId\"?, \"reason\"?, \"error\"?}], \"firstStopped\": member name or null}",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "groupId": {
+                            "type": "string",
+                            "description": "Group ID from debugger_start_group"
+                        }
+                    },
+                    "required": ["groupId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "<10ms",
+                    "workflow": "inspection",
+                    "category": "session-management",
+                    "priority": 0.6
+                }
+            }),
+            json!({
+                "name": "debugger_group_disconnect",
+                "title": "Disconnect Session Group",
+                "description": "Disconnects every member of a session group and removes the group. Running programs are terminated.\n\nTIMING: Returns in 50-200ms per member\n\nRETURNS: {\"groupId\", \"members\": [{\"name\", \"sessionId\", \"status\": \"disconnected\" | \"alreadyDisconnected\" | \"failed\", \"error\"?}]}",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "groupId": {
+                            "type": "string",
+                            "description": "Group ID from debugger_start_group"
+                        }
+                    },
+                    "required": ["groupId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "50-200ms per member",
+                    "workflow": "cleanup",
+                    "category": "session-management",
+                    "destructive": true,
+                    "priority": 0.4
+                }
+            }),
+            json!({
+                "name": "debugger_session_state",
+                "title": "Check Session State",
+                "description": "Retrieves the current state of a debugging session. Essential for tracking async initialization progress.\n\nWORKFLOW USAGE:\n- After debugger_start: Poll this until state is 'Running' or 'Stopped' (not 'Initializing')\n- Before setting breakpoints: Verify state is 'Stopped' (with stopOnEntry) or 'Running'\n- After operations: Check state to verify success or detect failures\n\nSTATES:\n- NotStarted: Session created but not yet initialized\n- Initializing: DAP adapter starting (wait for this to complete)\n- Launching: Program starting\n- Running: Program executing (can set breakpoints)\n- Stopped: Hit breakpoint or paused (details.reason shows why)\n- Terminated: Program exited normally\n- Failed: Error occurred (details.error shows message)\n\nTIMING: Returns immediately (<10ms), or after up to blockForMs when given\n\nPOLLING:\n- eventsSeq: increases with every state change (and process, memory and relaunch event); if it didn't change between two calls, nothing happened. Pass it to debugger_events as sinceSeq to get what happened in between\n- retryAfterMs (Running only): suggested delay before the next call, doubling from 100ms to 2s while nothing happens and reset by any state change\n- blockForMs: wait up to this long (max 30000) for the state to change before answering, instead of polling in a loop\n\nCRASH REPORTS: When the program stops on an exception or panic that nothing handles (Python needs uncaught exception breakpoints, e.g. captureOnException), details.crashReport is a triage report taken before the program is torn down: exception {exceptionId, description, breakMode}, the top 10 frames with source snippets and a library flag, the locals of userFrame (the innermost frame that isn't library code) and the last 50 lines of program output. It stays in details after the program terminates.\n\nBREAKPOINT SUMMARY: Once the session is Terminated or Failed, details.breakpointSummary has the hit statistics of its breakpoints: {\"atMs\", \"totalHits\", \"untrackedHits\", \"hotPath\" (ids of the 5 most hit breakpoints), \"breakpoints\": [{\"id\", \"sourcePath\", \"line\" | \"function\" | \"instructionReference\", \"condition\", \"logMessage\", \"hits\", \"firstHitMs\", \"lastHitMs\", \"conditionFailures\", \"share\"}]}, busiest first. Hits are stops the debugger attributed to the breakpoint (hitBreakpointIds), or breakpoint stops attributed to none at the breakpoint's line, including ones the server continued at once; times are milliseconds since the session was created. conditionFailures is null where the debugger evaluates the condition, as it skips false ones silently; it is counted for server-loop watches of debugger_watch_change and for breakpoints with a caller, whose mismatching hits count. Statistics are kept for up to 1000 breakpoint ids; hits of others only count in totalHits and untrackedHits, as do breakpoint stops attributed to no breakpoint at a line without one.\n\nEXIT DIAGNOSIS: When the program exited on its own (not through debugger_disconnect) without hitting any breakpoint while at least one was verified (logpoints and disabled breakpoints aside), details.exitDiagnosis is {\"exitCode\", \"runtimeMs\" (from the launch to the exit), \"verifiedBreakpoints\", \"message\"}, e.g. \"program exited with exit code 0 in 180 ms before any breakpoint was hit (1 verified); consider stopOnEntry or entry in debugger_start ...\": the program most likely ran past the breakpoints before they were set, or never reached them.\n\nRESOURCE USAGE: Once the debugger reports the program's pid, details.resourceUsage is {\"pid\", \"current\": {\"atMs\", \"rssBytes\", \"cpuMs\", \"cpuPercent\"}, \"peakRssBytes\", \"peakCpuPercent\", \"frozen\", \"ended\"}, read from /proc every 5 seconds; frozen is true while the program is stopped, when nothing is read. See debugger_resource_usage for the series.\n\nSTARTUP OUTPUT: Output the debugger sent before the launch completed (build messages, adapter diagnostics) is kept from the moment the adapter starts. While the session is starting, or after its start failed, details.startupOutput is {\"category\": \"startup\", \"lines\": [...]}; a failed start also quotes its last 10 lines in details.error.\n\nGROUPS: Members of a session group (debugger_start_group) add \"member\": {\"groupId\", \"name\"}.\n\nMEMORY CHANGES: When the debugger reports memory modified (a memory event, e.g. after setting a variable), memoryChanges lists the last 32 ranges as {memoryReference, offset, count, eventsSeq}. Each advances eventsSeq: values read before a change's eventsSeq may be stale and should be read again.\n\nLAUNCH PHASES: \"launch\" is {\"current\", \"phases\", \"totalMs\"}: the phases of the start so far ({\"phase\", \"elapsedMs\"}: SpawningAdapter, Initializing, WaitingInitializedEvent, SendingBreakpoints, ConfigurationDone, and WaitingFirstStop with stopOnEntry or an entry breakpoint) and the one it is in, null once it is done. A start that seems stuck shows where; errors of a start that failed or timed out name the phase too.\n\nSTOPPED FRAMES: While stopped, details.topFrame is {\"id\", \"name\", \"sourcePath\", \"line\"} of the stopped thread, loaded with the stop where the debugger advertises supportsDelayedStackTraceLoading, and details.stack is {\"loadedFrames\", \"totalFrames\", \"partial\"}: how much of its stack was loaded so far.\n\nTIP: When state is 'Stopped', check details.reason to understand why (e.g., 'entry', 'breakpoint', 'step')\n\nSEE ALSO: debugger://state-machine (complete state diagram), debugger-docs://guide/async-initialization",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID returned from debugger_start"
+                        },
+                        "blockForMs": {
+                            "type": "integer",
+                            "description": "Wait up to this many milliseconds (max 30000) for the state to change before returning (optional, default: return immediately)",
+                            "minimum": 0,
+                            "maximum": 30000
+                        }
+                    },
+                    "required": ["sessionId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "< 10ms",
+                    "workflow": "state-checking",
+                    "category": "session-management",
+                    "pollable": true,
+                    "priority": 0.9
+                }
+            }),
+            json!({
+                "name": "debugger_events",
+                "title": "Session Events",
+                "description": "Returns what happened in a session after a given eventsSeq, so a client that polls debugger_session_state or debugger_is_stopped can catch up on every change rather than only the latest state.\n\nEach event is {\"seq\", \"atMs\" (milliseconds since the session was created), \"kind\", \"body\"}, oldest first:\n- state: {\"state\", \"threadId\", \"reason\"} for stops, {\"state\", \"error\"} for failures\n- process: a process the debugger reported {\"name\", \"pid\", \"startMethod\"}\n- memory: a memory range reported modified {\"memoryReference\", \"offset\", \"count\", \"eventsSeq\"}\n- relaunched: watch mode started this session again (see debugger_start's watch)\n\nThe last 256 events of a session are kept; missed counts the ones after sinceSeq that were dropped.\n\nTIMING: Returns immediately (<10ms), or when the next event arrives with blockForMs\n\nRETURNS: {\"sessionId\", \"events\": [...], \"missed\", \"eventsSeq\"}; pass eventsSeq as sinceSeq next time\n\nSEE ALSO: debugger_session_state (eventsSeq and the current state)",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID returned from debugger_start"
+                        },
+                        "sinceSeq": {
+                            "type": "integer",
+                            "description": "eventsSeq last seen; only later events are returned (optional, default: 0, every event kept)",
+                            "minimum": 0
+                        },
+                        "blockForMs": {
+                            "type": "integer",
+                            "description": "Wait up to this many milliseconds (max 30000) for an event when there is none after sinceSeq (optional, default: return immediately)",
+                            "minimum": 0,
+                            "maximum": 30000
+                        }
+                    },
+                    "required": ["sessionId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "< 10ms",
+                    "workflow": "state-checking",
+                    "category": "session-management",
+                    "pollable": true,
+                    "priority": 0.7
+                }
+            }),
+            json!({
+                "name": "debugger_is_stopped",
+                "title": "Is Program Stopped",
+                "description": "Cheaply tells whether the program is currently stopped, and why. Reads cached session state only: no stack frames, variables or adapter requests.\n\nUSE FOR: Polling in a loop for a stop or termination. Use debugger_session_state for the full state (initialization progress, errors, exception details) and debugger_wait_for_stop to block until the next stop.\n\nTIMING: Returns immediately (<10ms)\n\nRETURNS:\n- stopped: true if the program is paused\n- reason, threadId: why and where it stopped (only when stopped)\n- terminated: true once the program has exited or the session failed; stop polling\n- eventsSeq: increases with every state change, same cursor as debugger_session_state (to wait for a change instead of polling, call debugger_session_state with blockForMs)\n- retryAfterMs (running only): suggested delay before the next call\n\nSEE ALSO: debugger_session_state, debugger_wait_for_stop",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID returned from debugger_start"
+                        }
+                    },
+                    "required": ["sessionId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "< 10ms",
+                    "workflow": "state-checking",
+                    "category": "session-management",
+                    "pollable": true,
+                    "priority": 0.8
+                }
+            }),
+            json!({
+                "name": "debugger_set_breakpoint",
+                "title": "Set Breakpoint",
+                "description": "Sets a breakpoint at a specific line in a source file. The debugger will pause execution when this line is about to execute.\n\nWORKFLOW:\n1. Ensure session state is 'Stopped' (recommended) or 'Running'\n2. Call this tool with the source file path and line number\n3. Check the 'verified' field in response (true = breakpoint accepted)\n4. Use debugger_continue to resume execution until breakpoint is hit\n\nTIMING: Returns in 5-20ms\n\nIMPORTANT: Use stopOnEntry: true when starting the session to pause before code execution, giving you time to set breakpoints.\n\nTIP: The sourcePath must match the path used by the debugger. For best results, use absolute paths.\n\nRETURNS:\n- verified: true if breakpoint was successfully set and recognized by the debugger\n- sourcePath: echo of the source file path\n- line: echo of the line number\n- sourceWarning: with verifySource on, when the file's content changed since the program was launched and the breakpoint may bind to a stale line; for TypeScript (.ts) files, whatever verifySource, when no source map lists the file (the breakpoint won't bind) or the file is newer than the JavaScript compiled from it\n- logMessage, logPoint: for logpoints; 'native' when the debugger logs the message itself, 'emulated' when it doesn't support logpoints (Delve, rdbg) and the server evaluates the message at a hidden stop and continues, adding it to the program output kept for crash reports. Either way the program doesn't stop\n- verifiedLine, moveExplanation: when the debugger put the breakpoint on another line than requested (e.g. line 13 is an if header; moved to 14, the first statement of the if body). The breakpoint keeps both numbers: stops on either line are attributed to it, and setting a breakpoint on either line replaces it. debugger_list_breakpoints reports them too\n- breakpointsOnLine: with additional, when the line now has several conditional breakpoints. The debugger takes one breakpoint per line, so they are sent as one whose condition ORs theirs (' or ' for Python and for Rust's default simple expressions, '||' for the others): the program stops when any holds. They share the debugger's id (enabling or disabling one does all), are listed separately by debugger_list_breakpoints, and a stop at the line reports all of them in hitBreakpoints, since the debugger can't say which condition held. Only breakpoints with just a condition share a line\n- removed, remaining: with remove, how many breakpoints were removed and how many are left on the line\n- caller, callerDepth: echoed when given. Each hit of the breakpoint stops the program while the server loads callerDepth callers (one stackTrace request); when none matches it continues at once, at most 1000 times in a row, after which the next mismatching hit stops with limitReached. Stops report callerMatch in debugger_wait_for_stop: {\"breakpointId\", \"caller\", \"function\", \"sourcePath\", \"line\", \"depth\" (1: direct caller), \"skipped\" (mismatching hits continued past), \"limitReached\"}. The condition is checked by the debugger first; only stops the debugger reports hitBreakpointIds for are checked, and never while a step or pause is on its way. Mismatches count as conditionFailures in breakpoint statistics\n- changeWatch: when condition is changed(expr), e.g. 'changed(total)': the breakpoint stops only when expr's value differs from its value at the previous hit, the first hit only taking the value. The server keeps the previous value and evaluates expr itself at every hit, whatever the language, so every hit stops the program for a few debugger round trips and the server auto-continues the hits where the value is unchanged; after 1000 unchanged hits in a row the next one stops with limitReached. A goroutineLabel or hitCondition is still checked by the debugger first, and only the hits it lets through are compared. Read-only sessions refuse it. Returned as {\"watchId\", \"expression\", \"strategy\": \"serverLoop\", \"maxAutoContinues\", \"overhead\"}; stops report changeWatch with the old and new value in debugger_wait_for_stop (see debugger_watch_change). changed() must be the whole condition and can't be combined with logMessage, temporary, caller or additional\n- condition, hitCondition, temporary: echoed when given; with goroutineLabel (echoed too), condition is the generated Delve condition. A temporary breakpoint is removed by the first stop it causes; with a condition that is the first hit where the condition holds. It is still reported in hitBreakpoints of that stop, but no longer listed by debugger_list_breakpoints\n- onDiskPath, pathWarning: when the file's on-disk letter case differs from sourcePath (case-insensitive volumes, e.g. macOS mounts), the breakpoint is set on the on-disk path; the warning appears once per file and stack traces then report your spelling\n\nERRORS: PathNotFound if the file doesn't exist, with a candidate path that differs only in letter case when there is one\n\nSEE ALSO: debugger_continue (to hit the breakpoint), debugger://workflows (breakpoint examples)",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "sourcePath": {
+                            "type": "string",
+                            "description": "Absolute or relative path to the source file (must match debugger's path resolution)"
+                        },
+                        "line": {
+                            "type": "integer",
+                            "description": "Line number where breakpoint should be set (1-indexed, i.e., first line is 1)"
+                        },
+                        "logMessage": {
+                            "type": "string",
+                            "description": "Make this a logpoint: log this message to the program output instead of stopping. {expression} is replaced by its value, {{ and }} are literal braces"
+                        },
+                        "condition": {
+                            "type": "string",
+                            "description": "Stop only when this expression is true (e.g. 'i == 5'), or, as changed(expr), only when expr's value changed since the previous hit (e.g. 'changed(total)'; evaluated by the server, see changeWatch)"
+                        },
+                        "hitCondition": {
+                            "type": "string",
+                            "description": "Stop only on hits matching this count expression (e.g. '>= 3'; syntax depends on the debugger)"
+                        },
+                        "temporary": {
+                            "type": "boolean",
+                            "description": "Remove the breakpoint after the first stop it causes (default: false). Can't be combined with logMessage"
+                        },
+                        "additional": {
+                            "type": "boolean",
+                            "description": "Keep the conditional breakpoints already on this line instead of replacing them (default: false). Needs condition; the program stops when any of the line's conditions holds. A breakpoint with the same condition is replaced"
+                        },
+                        "remove": {
+                            "type": "boolean",
+                            "description": "Remove instead of set (default: false): the breakpoint on this line with condition, or every breakpoint on the line when condition is omitted. The line's other breakpoints stay"
+                        },
+                        "caller": {
+                            "type": "string",
+                            "description": "Stop only when called from a function matching this pattern, e.g. 'main' (matches Go's main.main too) or '*Handler*'; '*' matches anything, a name without it matches whole or as the last part of a qualified name. At every hit the server loads the callers and continues at once when none matches. Can't be combined with logMessage or additional"
+                        },
+                        "callerDepth": {
+                            "type": "integer",
+                            "description": "How many callers caller is checked against, nearest first (default: 1, the direct caller; max: 3)",
+                            "minimum": 1,
+                            "maximum": 3
+                        },
+                        "goroutineLabel": {
+                            "type": "object",
+                            "description": "Go only: stop only goroutines carrying this pprof label, e.g. {\"key\": \"tenant\", \"value\": \"acme\"}. Turned into a Delve condition on the goroutine's labels (combined with condition by &&), which the response returns as condition. Needs a Go version that stores labels as a list; the first 8 labels of a goroutine are compared",
+                            "properties": {
+                                "key": {"type": "string"},
+                                "value": {"type": "string"}
+                            },
+                            "required": ["key", "value"]
+                        }
+                    },
+                    "required": ["sessionId", "sourcePath", "line"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "5-20ms",
+                    "workflow": "breakpoint-management",
+                    "category": "debugging",
+                    "requiresState": ["Running", "Stopped"],
+                    "priority": 0.8
+                }
+            }),
+            json!({
+                "name": "debugger_set_instruction_breakpoint",
+                "title": "Set Instruction Breakpoint",
+                "description": "Sets a breakpoint on a machine instruction: a memory reference plus an optional byte offset, with an optional condition or hit condition. For low-level debugging of compiled programs (Go, Rust) where a source line is too coarse.\n\nWORKFLOW:\n1. Stop the program and get an address, e.g. a frame's instructionPointerReference from debugger_stack_trace or a computed address\n2. Call this tool with instructionReference (and offset to break before/after it)\n3. Check 'verified' (false with a message if the adapter couldn't resolve the address)\n4. Use debugger_continue to run until the instruction executes\n\nSetting a breakpoint at an address that already has one replaces it; pass remove: true to delete it.\n\nRESTARTS: Instruction breakpoints are re-armed at the same addresses after a restart. If the program file changed since a breakpoint was set it is marked stale and not re-armed; set it again at the new address. Even for an unchanged program, addresses only match if it loads at the same base address.\n\nREQUIRES: An adapter with instruction breakpoint support (Delve, CodeLLDB); others fail with an unsupported-capability error\n\nTIMING: Returns in 5-20ms\n\nRETURNS: {\"id\", \"verified\", \"instructionReference\", \"offset\", \"condition\", \"hitCondition\", \"message\", \"stale\"} or {\"removed\": bool} when removing\n\nSEE ALSO: debugger_list_breakpoints (instructionBreakpoints lists them all)",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "instructionReference": {
+                            "type": "string",
+                            "description": "Memory reference of the instruction, e.g. '0x4a1f20'"
+                        },
+                        "offset": {
+                            "type": "integer",
+                            "description": "Byte offset from instructionReference (optional, may be negative)"
+                        },
+                        "condition": {
+                            "type": "string",
+                            "description": "Expression that must be true for the breakpoint to stop (optional)"
+                        },
+                        "hitCondition": {
+                            "type": "string",
+                            "description": "Hit count condition, e.g. '5' or '>= 3' (optional)"
+                        },
+                        "remove": {
+                            "type": "boolean",
+                            "description": "Remove the breakpoint at this address instead of setting one (default: false)"
+                        }
+                    },
+                    "required": ["sessionId", "instructionReference"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "5-20ms",
+                    "workflow": "breakpoint-management",
+                    "category": "debugging",
+                    "requiresState": ["Running", "Stopped"],
+                    "priority": 0.3
+                }
+            }),
+            json!({
+                "name": "debugger_set_function_breakpoint",
+                "title": "Set Function Breakpoint",
+                "description": "Sets a breakpoint on entry to a function by name, with an optional condition or hit condition. Useful when you know the function but not its file and line.\n\nWORKFLOW:\n1. Call this tool with the function name in the debugger's syntax (Python: 'module.function' or 'function'; Go: 'main.handler' or 'pkg.(*Type).Method'; Ruby: 'Class#method')\n2. Check 'verified' (false with a message if the debugger couldn't find the function)\n3. Use debugger_continue to run until the function is called\n\nSetting a breakpoint on a function that already has one replaces it; pass remove: true to delete it.\n\nGO NAMES: Qualified names survive edits that move lines. 'main.Add', '(*Calculator).Multiply', 'Calculator.Multiply', 'main.(*Calculator).Multiply', 'main.*Calculator.Multiply' and import-path names like 'example.com/calc.Add' are accepted (spaces and a trailing '()' are ignored) and sent in Delve's syntax; 'name' in the result is the name as sent. A malformed name fails before anything is sent. A name Delve can't find fails naming the function and isn't kept, as a Go program never loads code later.\n\nREQUIRES: A debugger that advertises function breakpoint support (check functionBreakpoints in debugger_get_capabilities). Others fail with an unsupported-capability error before anything is sent; set a source breakpoint on the first line of the function's body with debugger_set_breakpoint instead.\n\nTIMING: Returns in 5-20ms\n\nRETURNS: {\"id\", \"verified\", \"name\", \"condition\", \"hitCondition\", \"message\"} or {\"removed\": bool} when removing\n\nSEE ALSO: debugger_list_breakpoints (functionBreakpoints lists them all)",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "name": {
+                            "type": "string",
+                            "description": "Function name, e.g. 'main.handler' or, in Go, 'pkg.(*Type).Method'"
+                        },
+                        "condition": {
+                            "type": "string",
+                            "description": "Expression that must be true for the breakpoint to stop (optional)"
+                        },
+                        "hitCondition": {
+                            "type": "string",
+                            "description": "Hit count condition, e.g. '5' or '>= 3' (optional)"
+                        },
+                        "remove": {
+                            "type": "boolean",
+                            "description": "Remove the breakpoint on this function instead of setting one (default: false)"
+                        }
+                    },
+                    "required": ["sessionId", "name"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "5-20ms",
+                    "workflow": "breakpoint-management",
+                    "category": "debugging",
+                    "requiresState": ["Running", "Stopped"],
+                    "priority": 0.5
+                }
+            }),
+            json!({
+                "name": "debugger_disassemble",
+                "title": "Disassemble",
+                "description": "Disassembles a window of machine instructions, by default centered on the stopped thread's current instruction. For low-level debugging of Go programs (Delve only; other languages fail with an unsupported-capability error).\n\nPAGING: Responses hold at most 200 instructions. The window is remembered per session: use debugger_disassemble_next and debugger_disassemble_prev to scroll by one window, or call this tool again with another instructionOffset.\n\nTIMING: Returns in 10-50ms\n\nRETURNS: {\"memoryReference\", \"instructionOffset\", \"instructions\": [{\"address\", \"instructionBytes\", \"instruction\", \"symbol\", \"location\", \"line\"}], \"currentIndex\": index of the current instruction, if in the window}. Addresses that can't be read are padded with invalid instructions.\n\nSEE ALSO: debugger_stack_trace (instructionPointerReference of each frame), debugger_set_instruction_breakpoint",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "memoryReference": {
+                            "type": "string",
+                            "description": "Address to count from, e.g. a frame's instructionPointerReference (optional, default: the current instruction; requires a stopped program)"
+                        },
+                        "instructionOffset": {
+                            "type": "integer",
+                            "description": "Instructions from memoryReference to the first one shown, may be negative (optional, default: half the window before the current instruction, or 0 with memoryReference)"
+                        },
+                        "instructionCount": {
+                            "type": "integer",
+                            "description": "Instructions to return (optional, default: 50, max: 200)",
+                            "minimum": 1,
+                            "maximum": 200
+                        }
+                    },
+                    "required": ["sessionId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "10-50ms",
+                    "workflow": "inspection",
+                    "category": "debugging",
+                    "requiresState": ["Stopped"],
+                    "priority": 0.2
+                }
+            }),
+            json!({
+                "name": "debugger_disassemble_next",
+                "title": "Disassemble Next Window",
+                "description": "Disassembles the window of instructions right after the last one read with debugger_disassemble (same size), and remembers it for further scrolling.\n\nTIMING: Returns in 10-50ms\n\nRETURNS: Same as debugger_disassemble\n\nERRORS: InvalidState if debugger_disassemble wasn't called in this session (or since a restart)",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        }
+                    },
+                    "required": ["sessionId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "10-50ms",
+                    "workflow": "inspection",
+                    "category": "debugging",
+                    "priority": 0.2
+                }
+            }),
+            json!({
+                "name": "debugger_disassemble_prev",
+                "title": "Disassemble Previous Window",
+                "description": "Disassembles the window of instructions right before the last one read with debugger_disassemble (same size), and remembers it for further scrolling.\n\nTIMING: Returns in 10-50ms\n\nRETURNS: Same as debugger_disassemble\n\nERRORS: InvalidState if debugger_disassemble wasn't called in this session (or since a restart)",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        }
+                    },
+                    "required": ["sessionId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "10-50ms",
+                    "workflow": "inspection",
+                    "category": "debugging",
+                    "priority": 0.2
+                }
+            }),
+            json!({
+                "name": "debugger_get_capabilities",
+                "title": "Show Debugger Capabilities",
+                "description": "Shows which optional features the session's debugger supports: function, conditional, hit-count, instruction and data breakpoints, logpoints, setting variables, restart, step back and so on. Features a debugger lacks fail with an unsupported-capability error; check here first to pick an alternative.\n\ncapabilities is one snapshot of every DAP capability flag the server knows, so a whole strategy can be planned from one call: conditional and hit-count breakpoints, logpoints, function, data and instruction breakpoints, setVariable, setExpression, step back, goto, stepping granularity, restart, terminate, disassemble, read/write memory, modules, loaded sources, cancel and more. Flags the debugger didn't mention are false, and flags known to be wrong for a debugger are corrected.\n\nTIMING: Returns immediately (<10ms); the session must be initialized\n\nRETURNS: {\"adapter\": debugger id, \"capabilities\": {DAP capability name: supported}, \"functionBreakpoints\": bool, \"supportedChecksumAlgorithms\": [algorithms the debugger verifies sources with], \"adapterPositions\": {\"linesStartAt1\", \"columnsStartAt1\"}, \"alternatives\": {unsupported capability: what to do instead}}\n\nadapterPositions is the debugger's own line and column numbering; lines and columns in every tool are 1-based regardless\n\nSEE ALSO: debugger_set_function_breakpoint, debugger_get_config",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        }
+                    },
+                    "required": ["sessionId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "< 10ms",
+                    "workflow": "inspection",
+                    "category": "session-management",
+                    "priority": 0.4
+                }
+            }),
+            json!({
+                "name": "debugger_set_exception_breakpoints",
+                "title": "Set Exception Breakpoints",
+                "description": "Makes the program stop when an exception is thrown, replacing any previous setting.\n\nMODES:\n- raised: stop wherever an exception is raised, even if something handles it later\n- uncaught: stop only on exceptions nothing handles\nPass an empty modes list to turn exception breakpoints off.\n\nEXCEPTION OPTIONS: exceptionOptions narrows stops down to exception types, for debuggers that support it (Python/debugpy only; others fail with an unsupported-capability error). Each option is a path from the debugger's exception category to type names, and a breakMode ('never', 'always', 'unhandled', 'userUnhandled'). E.g. stop wherever myapp.errors.ValidationError is raised: [{\"path\": [{\"names\": [\"Python Exceptions\"]}, {\"names\": [\"myapp.errors.ValidationError\"]}], \"breakMode\": \"always\"}]. Options replace the previous ones; omitting them clears them.\n\nSUPPORT:\n- Python (debugpy): raised, uncaught\n- Ruby (rdbg): raised only (rdbg can't tell at raise time whether an exception will be rescued; a raised stop on an exception that escapes is the last chance to inspect it)\n- Other languages: not supported (Go stops on unrecovered panics by itself)\nUnsupported modes fail with an unsupported-capability error naming an alternative.\n\nSTOPS: The program stops with reason 'exception'; debugger_wait_for_stop and debugger_session_state then include exception: {\"class\", \"message\", \"breakMode\"}.\n\nTIMING: Returns in 5-20ms\n\nRETURNS: {\"modes\": [...], \"filters\": [the debugger's filter ids that were enabled], \"exceptionOptions\": [as given, if any]}",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "modes": {
+                            "type": "array",
+                            "items": {"type": "string", "enum": ["raised", "uncaught"]},
+                            "description": "When to stop on exceptions (empty = never)"
+                        },
+                        "exceptionOptions": {
+                            "type": "array",
+                            "description": "Break modes for exceptions by type path (debuggers with supportsExceptionOptions only)",
+                            "items": {
+                                "type": "object",
+                                "properties": {
+                                    "path": {
+                                        "type": "array",
+                                        "items": {
+                                            "type": "object",
+                                            "properties": {
+                                                "names": { "type": "array", "items": { "type": "string" } },
+                                                "negate": { "type": "boolean" }
+                                            },
+                                            "required": ["names"]
+                                        }
+                                    },
+                                    "breakMode": {
+                                        "type": "string",
+                                        "enum": ["never", "always", "unhandled", "userUnhandled"]
+                                    }
+                                },
+                                "required": ["breakMode"]
+                            }
+                        }
+                    },
+                    "required": ["sessionId", "modes"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "5-20ms",
+                    "workflow": "breakpoint-management",
+                    "category": "debugging",
+                    "requiresState": ["Running", "Stopped"],
+                    "priority": 0.5
+                }
+            }),
+            json!({
+                "name": "debugger_continue",
+                "title": "Continue Execution",
+                "description": "Resumes program execution after being paused (e.g., at a breakpoint or entry point). Execution continues until the next breakpoint, exception, or program termination.\n\nWORKFLOW:\n1. Session must be in 'Stopped' state (verify with debugger_session_state)\n2. Call this tool to resume execution\n3. Poll debugger_session_state to detect when execution stops again\n4. When state returns to 'Stopped', check details.reason:\n   - 'breakpoint': Hit a breakpoint (use debugger_stack_trace to inspect)\n   - 'exception': Uncaught exception occurred\n   - 'pause': Manual pause requested\n   - 'step': Completed a step operation\n\nTIMING: Returns in <10ms (but program continues running asynchronously)\n\nTIP: After calling continue, immediately poll debugger_session_state in a loop to detect when the program stops again.\n\nRETURNS: {\"status\": \"continued\"}\n\nSEE ALSO: debugger_stack_trace (inspect state when stopped), debugger://workflows (execution control patterns)",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "threadId": {
+                            "type": "integer",
+                            "description": "Thread to resume (optional, defaults to the stopped thread). Fails with a ThreadRunning error if that thread is already running"
+                        }
+                    },
+                    "required": ["sessionId"]
+                },
+                "annotations": {
+                    "async": true,
+                    "returnsTiming": "< 10ms",
+                    "completionTiming": "unknown (until next stop)",
+                    "workflow": "execution-control",
+                    "category": "debugging",
+                    "requiresState": ["Stopped"],
+                    "priority": 0.7
+                }
+            }),
+            json!({
+                "name": "debugger_continue_past",
+                "title": "Continue Past a Breakpoint N Times",
+                "description": "Continues and skips the hits of a breakpoint until its count-th hit, e.g. count 37 for the 37th iteration of a loop: the 36 hits before are continued past by the server as they happen, without a round trip each. Unlike a hitCondition, works with a breakpoint that is already set and doesn't change it.\n\nOnly stops where this breakpoint alone was hit are skipped (the debugger must report hitBreakpointIds, as Delve and debugpy do). A stop for anything else ends the wait early and is reported as interrupting it: another breakpoint, an exception, a breakpoint hit together with this one. Nothing is skipped after this returns, including after a timeout.\n\nTIMING: Waits until the count-th hit, an interruption, termination or timeoutMs (default: the session's waitForStopTimeoutMs)\n\nRETURNS: {\"status\": \"reached\" | \"interrupted\" | \"terminated\" | \"timedOut\", \"breakpointId\", \"count\", \"skipped\" (hits continued past), \"threadId\", \"reason\", \"hitBreakpointIds\" (of the stop it ended with), \"exception\" (when an exception interrupted it)}. With timedOut the program is still running.\n\nSEE ALSO: debugger_list_breakpoints (breakpoint ids and hit counts), debugger_set_breakpoint (hitCondition)",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "breakpointId": {
+                            "type": "integer",
+                            "description": "Id of the breakpoint to continue past (from debugger_set_breakpoint or debugger_list_breakpoints)"
+                        },
+                        "count": {
+                            "type": "integer",
+                            "minimum": 1,
+                            "description": "The hit to stop at; the count - 1 hits before it are skipped. 1 continues to the next hit"
+                        },
+                        "timeoutMs": {
+                            "type": "integer",
+                            "minimum": 1,
+                            "description": "How long to wait (optional, defaults to the session's waitForStopTimeoutMs)"
+                        }
+                    },
+                    "required": ["sessionId", "breakpointId", "count"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "until the count-th hit or timeoutMs",
+                    "workflow": "execution-control",
+                    "category": "debugging",
+                    "requiresState": ["Stopped"],
+                    "priority": 0.6
+                }
+            }),
+            json!({
+                "name": "debugger_stack_trace",
+                "title": "Get Stack Trace",
+                "description": "Retrieves the current call stack when execution is paused. Shows the sequence of function calls that led to the current execution point.\n\n⭐ PRIMARY PURPOSE: Get Frame IDs for debugger_evaluate\n======================================================\nThe 'id' field in each frame is CRITICAL - use it with debugger_evaluate to access variables:\n\nRETURNS: Array of stack frames, each containing:\n- id: Frame identifier → USE THIS as frameId in debugger_evaluate ⭐\n- name: Function/method name\n- source: {path: \"file path\", name: \"filename\"}\n- line: Current line number in this frame\n- column: Column number (if available)\nplus totalFrames (stack depth, null if the adapter doesn't say), startFrame and moreFrames (frames exist below this page).\n\nDEEP RECURSION: At most 200 frames are returned by default (levels, max 1000); page further down with startFrame. Debuggers advertising supportsDelayedStackTraceLoading send only the frames of the page; others send the whole stack, which is cut to the page. Runs of a repeating frame cycle (3+ times in a row) are collapsed: the first cycle is kept and the rest replaced by a marker {\"repeated\": \"frame f (app.py:12) repeated 196 times\", \"functions\", \"times\", \"frameCount\"}, which has no id.\n\nCACHING: Pages loaded at a stop are kept until the program resumes; asking for them again doesn't go to the debugger. Where the debugger pages stacks, only the top frame is loaded when the program stops; deeper frames are loaded when asked for.\n\n⚠️ Frame IDs Change Between Stops!\n================================\nFrame IDs are NOT stable across different stop events:\n- After EACH stop (breakpoint, step, continue), frame IDs change\n- ALWAYS call debugger_stack_trace fresh after each stop\n- NEVER reuse frame IDs from previous stops\n\nEXAMPLE PATTERN:\n  // Stop 1: Hit breakpoint\n  debugger_wait_for_stop()\n  stack1 = debugger_stack_trace()\n  frameId1 = stack1.stackFrames[0].id  // e.g., id = 5\n  debugger_evaluate({expression: \"x\", frameId: frameId1})  ✓\n  \n  // Stop 2: After continue and hit another breakpoint\n  debugger_continue()\n  debugger_wait_for_stop()\n  stack2 = debugger_stack_trace()  // GET FRESH TRACE!\n  frameId2 = stack2.stackFrames[0].id  // e.g., id = 8 (DIFFERENT!)\n  \n  // Using old frameId1 here would FAIL ❌\n  debugger_evaluate({expression: \"x\", frameId: frameId2})  ✓ Correct\n\nWORKFLOW:\n1. Session must be in 'Stopped' state (e.g., at a breakpoint)\n2. Call this tool to get current stack frames\n3. Extract the 'id' field from desired frame\n4. Pass that 'id' as frameId to debugger_evaluate\n5. Repeat steps 2-4 after each new stop event\n\nTIMING: Returns in 10-50ms depending on stack depth\n\nTIP: The first frame (index 0) is the current execution point. Higher indices are caller frames.\n\nCOMMON USE CASES:\n- Get frame IDs for debugger_evaluate (primary use)\n- Inspect where a breakpoint was hit\n- Understand call hierarchy\n- Diagnose unexpected execution paths\n\nSEE ALSO: debugger_evaluate (requires frame IDs from this tool), debugger://patterns (frame ID usage examples)",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "threadId": {
+                            "type": "integer",
+                            "description": "Thread to inspect (optional, defaults to the stopped thread). Fails with a ThreadRunning error if that thread is running"
+                        },
+                        "startFrame": {
+                            "type": "integer",
+                            "description": "Index of the first frame to return, 0 being the innermost (default: 0)"
+                        },
+                        "levels": {
+                            "type": "integer",
+                            "description": "Number of frames to return (default: 200, max: 1000)"
+                        }
+                    },
+                    "required": ["sessionId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "10-50ms",
+                    "workflow": "inspection",
+                    "category": "debugging",
+                    "requiresState": ["Stopped"],
+                    "priority": 0.6
+                }
+            }),
+            json!({
+                "name": "debugger_watch_change",
+                "title": "Stop When a Value Changes",
+                "description": "Stops the program when an expression's value changes, on any language. Put it on a line where the expression is in scope, e.g. inside the loop updating it: the watch is a breakpoint on that line that only stops when the value differs from the one at the previous hit. The first hit only records the value. Replaces any breakpoint on the line.\n\nSTRATEGY (reported as strategy, with its overhead):\n- adapterCondition (Python, Ruby, Node.js): the breakpoint's condition keeps the last value in a global of the program (sys._mcp_watch_N, $__mcp_watch_N, globalThis.__mcpWatchN) and is true only on a change. The program doesn't stop at unchanged hits.\n- serverLoop (Go, Rust): every hit stops; the server evaluates the expression and continues at once when it is unchanged, at most maxAutoContinues times, after which the next hit stops with limitReached. Much slower for lines hit often.\n\nValues are compared as printed (repr, inspect, JSON.stringify or the debugger's display), so a list changed in place counts as changed.\n\nON THE STOP: debugger_wait_for_stop adds changeWatch: {\"watchId\", \"expression\", \"strategy\", \"oldValue\", \"newValue\", \"error\" (the value couldn't be read), \"limitReached\", \"hits\", \"autoContinued\"}.\n\nTIMING: Returns in 10-100ms\n\nRETURNS: {\"watchId\", \"expression\", \"sourcePath\", \"line\", \"verified\", \"strategy\", \"overhead\", \"maxAutoContinues\" (serverLoop only)}\n\nSEE ALSO: debugger_wait_for_stop, debugger_set_breakpoint (condition, for a fixed value)",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "expression": {
+                            "type": "string",
+                            "description": "Expression to watch, in the program's language (e.g. 'total' or 'len(items)')"
+                        },
+                        "sourcePath": {
+                            "type": "string",
+                            "description": "File of the line the expression is read at"
+                        },
+                        "line": {
+                            "type": "integer",
+                            "description": "Line the expression is in scope at (1-indexed)"
+                        },
+                        "maxAutoContinues": {
+                            "type": "integer",
+                            "description": "serverLoop only: unchanged hits to continue past before stopping anyway (default: 1000, max: 100000)"
+                        }
+                    },
+                    "required": ["sessionId", "expression", "sourcePath", "line"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "10-100ms",
+                    "workflow": "breakpoint-management",
+                    "category": "debugging",
+                    "priority": 0.5
+                }
+            }),
+            json!({
+                "name": "debugger_find_frame",
+                "title": "Find Frame by Function",
+                "description": "Finds the frames of a function on the stack of a stopped thread and returns their frame IDs, for debugger_evaluate, debugger_peek or debugger_scopes in that function without counting stack levels.\n\nMATCHING: a frame matches when its name is the function or ends with it after '.', ':', '#', '/' or a space, so 'handler' finds main.handler (Go), app::handler (Rust), Server#handler (Ruby) and handler (Python). Qualify the name to narrow it down ('main.handler'). With no match, stackFunctions lists the functions on the stack as the debugger names them.\n\nRECURSION: every frame of the function is returned, innermost first, each with its depth (0 is the current frame).\n\nFrame IDs are only valid until the program resumes; search again after each stop.\n\nTIMING: Returns in 10-50ms depending on stack depth\n\nRETURNS: {\"function\", \"threadId\", \"matches\": [{\"frameId\", \"depth\", \"name\", \"path\", \"line\"}], \"searchedFrames\", \"moreFrames\" (frames below the search were not searched), \"stackFunctions\" (no match only)}\n\nSEE ALSO: debugger_stack_trace (the whole stack), debugger_evaluate (with the frameId)",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "function": {
+                            "type": "string",
+                            "description": "Function name, optionally qualified (e.g. 'handler' or 'main.handler')"
+                        },
+                        "threadId": {
+                            "type": "integer",
+                            "description": "Thread to search (optional, defaults to the stopped thread)"
+                        },
+                        "levels": {
+                            "type": "integer",
+                            "description": "Frames searched from the innermost (default and max: 1000)"
+                        }
+                    },
+                    "required": ["sessionId", "function"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "10-50ms",
+                    "workflow": "inspection",
+                    "category": "debugging",
+                    "requiresState": ["Stopped"],
+                    "priority": 0.5
+                }
+            }),
+            json!({
+                "name": "debugger_entry_point",
+                "title": "Find Entry Point",
+                "description": "Returns where the program starts: its entry function's file and line, to set an entry breakpoint deterministically before continuing.\n\nRESOLUTION:\n- go: main.main, resolved by Delve (not in test mode)\n- rust: the crate's main (e.g. fizzbuzz::main), resolved by CodeLLDB\n- python (program mode), ruby: the first executable line of the program file\n- nodejs and other modes: not supported\nGo and Rust resolve through a function breakpoint that is removed again, so the program must be running or stopped; stopped is safer, since a running program could hit it in between: start with stopOnEntry: true. To stop there right away instead, start with entry: 'user_main'.\n\nTIMING: Returns immediately for python and ruby, 10-100ms for go and rust\n\nRETURNS: {\"sessionId\", \"function\" (null for python and ruby), \"sourcePath\", \"line\" (both null when the function has no source), \"resolvedBy\": \"debugger\" or \"source\"}",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        }
+                    },
+                    "required": ["sessionId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "10-100ms",
+                    "workflow": "breakpoint-management",
+                    "category": "debugging",
+                    "requiresState": ["Running", "Stopped"],
+                    "priority": 0.5
+                }
+            }),
+            json!({
+                "name": "debugger_evaluate",
+                "title": "Evaluate Expression",
+                "description": "Evaluates an expression in the context of the paused program. Can access variables, call functions, and perform computations using the program's current state.\n\n⚠️ CRITICAL: frameId Requirement\n================================\nWhile technically optional, frameId is REQUIRED in practice for accessing local variables:\n\n❌ WITHOUT frameId:\n  debugger_evaluate({expression: \"local_var\"})\n  → Result: NameError: name 'local_var' is not defined\n  \n  Why: Evaluates in global/default context where local variables don't exist\n\n✅ WITH frameId (REQUIRED WORKFLOW):\n  1. Get stack trace: stack = debugger_stack_trace()\n  2. Extract frame ID: frameId = stack.stackFrames[0].id\n  3. Evaluate with frameId:\n     debugger_evaluate({expression: \"local_var\", frameId: frameId})\n  → Result: Successfully accesses local variable ✓\n\n⚠️ Frame IDs Change Between Stops!\n  - Frame IDs are NOT stable across different stop events\n  - ALWAYS get a fresh stack trace after each stop\n  - NEVER reuse frame IDs from previous stops\n\nEXAMPLE PATTERN (Correct Way):\n  // After hitting breakpoint:\n  const stack = debugger_stack_trace()\n  const frameId = stack.stackFrames[0].id  // Current frame\n  const value = debugger_evaluate({expression: \"n\", frameId: frameId})\n  \n  // After next stop, get NEW frame ID:\n  const stack2 = debugger_stack_trace()  // Fresh trace!\n  const frameId2 = stack2.stackFrames[0].id  // New frame ID\n  const value2 = debugger_evaluate({expression: \"n\", frameId: frameId2})\n\nWORKFLOW:\n1. Session must be in 'Stopped' state\n2. Call debugger_stack_trace to get current stack frames\n3. Extract frame ID from desired frame (usually frame[0] for current location)\n4. Call this tool with expression AND frameId\n5. Examine the result value\n\nTIMING: Returns in 20-200ms depending on expression complexity\n\nEXPRESSION EXAMPLES:\n- Variable access: \"x\", \"obj.property\", \"array[0]\"\n- Arithmetic: \"x + y\", \"count * 2\"\n- Comparisons: \"x > 10\", \"status == 'ready'\"\n- Function calls: \"len(array)\", \"obj.method()\"\n- Complex: \"[item for item in list if item > 0]\" (Python)\n\nRETURNS: {\"result\": \"string representation of evaluation result\"}\n\nSIDE EFFECTS: By default expressions are evaluated as the debugger does (debugpy, rdbg and CodeLLDB run any calls in them; Delve never calls functions from debugger_evaluate). With noSideEffects: true the expression is evaluated only where the debugger guarantees it can't change program state:\n- Node.js: evaluated as a hover, with V8's side-effect checks; an expression that would change state fails\n- Go: Delve's default, which reads variables and memory without calling functions\n- Python, Ruby, Rust: refused, as their debuggers can't guarantee it; use debugger_peek to read variables without evaluating\n\nCOMMON ERROR:\n  \"NameError: name 'variable' is not defined\"\n  → Solution: Add frameId parameter from debugger_stack_trace\n\nSEE ALSO: debugger_stack_trace (get frame IDs), debugger://patterns (cookbook examples)",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "expression": {
+                            "type": "string",
+                            "description": "Expression to evaluate (syntax depends on programming language being debugged)"
+                        },
+                        "frameId": {
+                            "type": "integer",
+                            "description": "Stack frame ID from debugger_stack_trace (optional, defaults to current frame)"
+                        },
+                        "threadId": {
+                            "type": "integer",
+                            "description": "Evaluate in this thread's top frame when frameId is omitted (optional). Fails with a ThreadRunning error if that thread is running"
+                        },
+                        "noSideEffects": {
+                            "type": "boolean",
+                            "description": "Only evaluate where the debugger guarantees no side effects (Node.js, Go); refused for other languages (optional, default false: the debugger's default)"
+                        }
+                    },
+                    "required": ["sessionId", "expression"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "20-200ms",
+                    "workflow": "inspection",
+                    "category": "debugging",
+                    "requiresState": ["Stopped"],
+                    "priority": 0.5
+                }
+            }),
+            json!({
+                "name": "debugger_evaluate_all_threads",
+                "title": "Evaluate In Every Thread",
+                "description": "Evaluates one expression in the top frame of every stopped thread (goroutine) and returns the values side by side. For concurrency bugs: compare how threads see a shared value, or which thread holds a lock, at a single stop.\n\nA thread the expression fails in (a name not in scope, no frames) gets an error of its own; the others are still evaluated. Running threads are listed in runningThreads and skipped. Threads are evaluated in the debugger's order, up to maxThreads; the rest are counted in omittedThreads. Each value is cut to a share of the response size limit (truncated: true).\n\nREQUIRES: Program must be stopped\n\nTIMING: One evaluation per thread, 20-200ms each\n\nRETURNS: {\"expression\", \"results\": [{\"threadId\", \"threadName\", \"function\", \"line\", \"value\", \"error\", \"truncated\"}], \"runningThreads\": [thread ids], \"omittedThreads\": count}\n\nSEE ALSO: debugger_evaluate (one frame), debugger_list_threads, debugger_analyze_hang (where goroutines are blocked)",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "expression": {
+                            "type": "string",
+                            "description": "Expression to evaluate in each thread's top frame"
+                        },
+                        "maxThreads": {
+                            "type": "integer",
+                            "minimum": 1,
+                            "maximum": 256,
+                            "description": "Most stopped threads to evaluate in (optional, default 32)"
+                        },
+                        "noSideEffects": {
+                            "type": "boolean",
+                            "description": "Only evaluate where the debugger guarantees no side effects (Node.js, Go), as in debugger_evaluate (optional, default false)"
+                        }
+                    },
+                    "required": ["sessionId", "expression"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "20-200ms per thread",
+                    "workflow": "inspection",
+                    "category": "debugging",
+                    "requiresState": ["Stopped"],
+                    "priority": 0.4
+                }
+            }),
+            json!({
+                "name": "debugger_compare_sessions",
+                "title": "Compare Sessions",
+                "description": "Evaluates one expression in two sessions at their current stops and returns the values side by side. For A/B debugging: run a passing and a failing case (or two versions) as two sessions, stop both at the same breakpoint and compare values until they diverge.\n\nEach session's value is evaluated in the top frame of its stopped thread. A session that isn't stopped, doesn't exist or fails to evaluate the expression gets a status (and error or state) of its own instead of failing the call. equal is compared on the full values, before each is cut to a share of the response size limit (truncated: true).\n\nTIMING: One evaluation per session, run concurrently, 20-200ms\n\nRETURNS: {\"expression\", \"sessions\": [{\"sessionId\", \"status\": \"evaluated\" | \"notStopped\" | \"failed\" | \"notFound\", \"state\" (when not stopped), \"threadId\", \"function\", \"line\", \"value\", \"error\", \"truncated\"}], \"equal\": bool, or null unless both were evaluated}\n\nSEE ALSO: debugger_evaluate (one session), debugger_evaluate_all_threads (threads of one session), debugger_start_group (start both sessions together)",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionIds": {
+                            "type": "array",
+                            "items": {"type": "string"},
+                            "minItems": 2,
+                            "maxItems": 2,
+                            "description": "The two sessions to compare, by ID from debugger_start"
+                        },
+                        "expression": {
+                            "type": "string",
+                            "description": "Expression to evaluate in each session's top frame"
+                        },
+                        "noSideEffects": {
+                            "type": "boolean",
+                            "description": "Only evaluate where the debugger guarantees no side effects (Node.js, Go), as in debugger_evaluate (optional, default false)"
+                        }
+                    },
+                    "required": ["sessionIds", "expression"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "20-200ms",
+                    "workflow": "inspection",
+                    "category": "debugging",
+                    "requiresState": ["Stopped"],
+                    "priority": 0.4
+                }
+            }),
+            json!({
+                "name": "debugger_repl_open",
+                "title": "Open REPL",
+                "description": "Opens a REPL bound to a frame of the current stop, for a series of inputs where debugger_evaluate would take one-off expressions. Inputs go to the debugger's REPL, which keeps state between them where it can: in Python, statements run and assignments persist (x = 41, then x + 1 gives 42) for as long as the program stays at this stop. Delve takes its commands (print x, locals, call f()); Rust (CodeLLDB) takes LLDB commands.\n\nThe REPL closes when the program resumes (continue or step): debugger_repl_eval then fails with an error saying so; open a new REPL at the next stop. Its history stays readable with debugger_repl_history. A session keeps its last 16 REPLs.\n\nREQUIRES: Program must be stopped\n\nRETURNS: {\"replId\", \"sessionId\", \"threadId\", \"frameId\"}\n\nSEE ALSO: debugger_repl_eval, debugger_repl_history, debugger_evaluate (single expressions)",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "frameId": {
+                            "type": "integer",
+                            "description": "Stack frame ID from debugger_stack_trace (optional, defaults to the top frame)"
+                        },
+                        "threadId": {
+                            "type": "integer",
+                            "description": "Use this thread's top frame when frameId is omitted (optional, defaults to the stopped thread)"
+                        }
+                    },
+                    "required": ["sessionId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "10-100ms",
+                    "workflow": "inspection",
+                    "category": "debugging",
+                    "requiresState": ["Stopped"],
+                    "priority": 0.4
+                }
+            }),
+            json!({
+                "name": "debugger_repl_eval",
+                "title": "Evaluate in REPL",
+                "description": "Evaluates one input in a REPL from debugger_repl_open, in the REPL's frame, and adds it with its output (or error) to the REPL's history. State from earlier inputs is kept where the debugger supports it (Python assignments persist). Fails with an InvalidState error once the program has resumed from the stop the REPL was opened at.\n\nRETURNS: {\"replId\", \"output\"}\n\nSEE ALSO: debugger_repl_history",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "replId": {
+                            "type": "string",
+                            "description": "REPL ID from debugger_repl_open"
+                        },
+                        "input": {
+                            "type": "string",
+                            "description": "Statement, expression or debugger command, as the language's debugger takes it"
+                        }
+                    },
+                    "required": ["replId", "input"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "20-200ms",
+                    "workflow": "inspection",
+                    "category": "debugging",
+                    "requiresState": ["Stopped"],
+                    "priority": 0.4
+                }
+            }),
+            json!({
+                "name": "debugger_repl_history",
+                "title": "REPL History",
+                "description": "Returns a REPL's inputs with their outputs or errors, oldest first (the last 200; droppedEntries counts older ones), and whether the REPL is still open. Works after the REPL closed, until its session ends.\n\nRETURNS: {\"replId\", \"open\", \"threadId\", \"frameId\", \"history\": [{\"input\", \"output\", \"error\"}], \"droppedEntries\"}",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "replId": {
+                            "type": "string",
+                            "description": "REPL ID from debugger_repl_open"
+                        }
+                    },
+                    "required": ["replId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "<10ms",
+                    "workflow": "inspection",
+                    "category": "debugging",
+                    "requiresState": [],
+                    "priority": 0.3
+                }
+            }),
+            json!({
+                "name": "debugger_disconnect",
+                "title": "Disconnect Session",
+                "description": "Terminates a debugging session and cleans up all associated resources. The debugged program will be stopped if still running.\n\nWORKFLOW:\n1. Call this when debugging is complete\n2. Session and all breakpoints are removed\n3. Debugged program is terminated gracefully\n\nTIMING: Returns in 50-200ms (includes cleanup time)\n\nIMPORTANT: Always disconnect when finished to free resources. The session cannot be resumed after disconnection; debugger_get_post_mortem still returns what was left of its program.\n\nRESTART: Pass restart: true when you are about to start the same program again. For sessions started with keepAdapterWarm, the adapter is then parked instead of shut down and the next debugger_start reuses it; the result is {\"status\": \"parked\", \"gracePeriodMs\"}.\n\nCANCELLING A START: a session whose start hangs (a Go build that doesn't finish, a debugger that never initializes) can be disconnected before it launches. The start is aborted, the debugger torn down and the session removed; the result has startCancelled: true, and a debugger_wait_for_stop waiting on the session fails with a Cancelled error.\n\nRETURNS: {\"status\": \"disconnected\", \"startCancelled\": true (only when a start was cancelled)}\n\nTIP: If the program is still running, it will be terminated. If you want to let the program finish naturally, you can skip calling this tool, but resources will not be cleaned up immediately.\n\nSEE ALSO: debugger://workflows (complete debugging workflows showing disconnect)",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "restart": {
+                            "type": "boolean",
+                            "description": "The program will be started again right away (keeps a warm adapter alive)"
+                        }
+                    },
+                    "required": ["sessionId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "50-200ms",
+                    "workflow": "cleanup",
+                    "category": "session-management",
+                    "destructive": true,
+                    "priority": 0.4
+                }
+            }),
+            json!({
+                "name": "debugger_restart",
+                "title": "Restart Program",
+                "description": "Starts the debugged program again from the beginning, keeping the configuration it was started with: the language-specific launch options (Delve's substitutePath and build flags, debugpy's justMyCode and environment, rdbg's options), breakpoints (source, function and exception), and the session's settings.\n\nMETHOD:\n- Adapters that advertise the DAP restart request (supportsRestartRequest) restart in place: the adapter stays up, the session keeps its id and breakpoints stay set. Delve, debugpy and rdbg don't, so Go, Python and Ruby sessions are always relaunched as below. Returns {\"sessionId\", \"method\": \"restartRequest\", \"state\"}.\n- Other adapters get a new session started with the same arguments and breakpoints, replacing the old one; the old id keeps resolving to it. Returns {\"sessionId\" (new), \"previousSessionId\", \"method\": \"relaunch\", \"state\", \"failedBreakpoints\" (only when some couldn't be re-applied)}.\n\nOnly launched programs can be restarted; attach sessions are rejected.\n\nTIP: With stopOnEntry the program stops at entry again; call debugger_wait_for_stop before inspecting it.",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        }
+                    },
+                    "required": ["sessionId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "100-2000ms",
+                    "workflow": "execution-control",
+                    "category": "session-management",
+                    "destructive": true,
+                    "priority": 0.5
+                }
+            }),
+            json!({
+                "name": "debugger_wait_for_stop",
+                "title": "Wait For Program To Stop",
+                "description": "Blocks until the debugger stops (at breakpoint, step, or entry point), or times out. More efficient than polling debugger_session_state.\n\n⭐ EFFICIENT ALTERNATIVE TO POLLING\n==================================\nReplaces old pattern of repeated sleep + state check with single blocking call:\n\n❌ OLD PATTERN (slow, inefficient):\n  debugger_continue()\n  sleep(200ms)  // Arbitrary delay\n  state = debugger_session_state()\n  if state != \"Stopped\":\n    sleep(500ms)  // More waiting\n    state = debugger_session_state()  // Still might be Running\n  // Takes 500-3000ms with multiple polls\n\n✅ NEW PATTERN (fast, efficient):\n  debugger_continue()\n  debugger_wait_for_stop({timeoutMs: 5000})\n  // Returns immediately when stopped (typically <100ms)\n  // No wasted polling cycles!\n\n⭐ TIMING BEHAVIOR\n=================\n- If ALREADY stopped: Returns immediately (<10ms)\n- If running: Blocks until stop event or timeout\n- If program terminated: Returns with state \"Terminated\"\n- If timeout expires: Returns error\n\nTypical return times:\n- Entry point (stopOnEntry): <100ms\n- Breakpoint hit: <100ms  \n- Step completion: <50ms\n\nCOMMON PATTERNS:\n\n1. Wait for entry after start:\n   debugger_start({stopOnEntry: true})\n   debugger_wait_for_stop()  // Immediate return when at entry\n\n2. Wait for breakpoint:\n   debugger_continue()\n   debugger_wait_for_stop()  // Blocks until breakpoint hit\n\n3. Wait for step completion:\n   debugger_step_over()\n   debugger_wait_for_stop()  // Blocks until step completes\n\n4. Loop through multiple stops:\n   for (i = 0; i < 5; i++):\n     debugger_continue()\n     result = debugger_wait_for_stop()\n     // Process each stop...\n\nWORKFLOW:\n1. Call debugger_continue(), debugger_step_*, or debugger_start()\n2. Call this tool to wait for the next stop event\n3. Returns immediately when program stops\n4. Check result.reason to understand why it stopped\n\nRETURNS:\n{\n  \"state\": \"Stopped\",\n  \"threadId\": 1,\n  \"reason\": \"breakpoint\",  // or \"entry\", \"step\", \"pause\", etc.\n  \"hitBreakpoints\": [{\"id\", \"line\", \"sourcePath\", ...}]  // breakpoints that caused the stop, if reported\n}\nMembers of a session group add \"member\": {\"groupId\", \"name\"}. Stops of debugger_watch_change watches add \"changeWatch\": {\"watchId\", \"expression\", \"oldValue\", \"newValue\", ...}. Stops of breakpoints with a caller add \"callerMatch\": {\"breakpointId\", \"caller\", \"function\", \"sourcePath\", \"line\", \"depth\", \"skipped\", \"limitReached\"}, the caller frame that matched. Sessions started with captureOnException add \"exceptionCapture\": {\"threadId\", \"exception\", \"stack\", \"locals\", \"localsTruncated\"} on exception stops, and keep the last one on Terminated as a post-mortem. A program that exited without hitting any of its verified breakpoints adds \"diagnosis\" on Terminated (see debugger_session_state).\n\nPERFORMANCE:\n~5x faster than polling approach\nNo wasted CPU cycles\nImmediate notification of state changes\n\nSEE ALSO: debugger_session_state (check current state), debugger_continue (resume execution)",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "timeoutMs": {
+                            "type": "integer",
+                            "default": 5000,
+                            "description": "Maximum time to wait in milliseconds (default: 5000, or the session's waitForStopTimeoutMs set with debugger_configure)"
+                        }
+                    },
+                    "required": ["sessionId"]
+                }
+            }),
+            json!({
+                "name": "debugger_wait_for_breakpoint",
+                "title": "Run Until A Breakpoint Is Hit",
+                "description": "Continues the program and returns only when one breakpoint, by id, is hit: e.g. the breakpoint in a request handler while others in shared code keep firing. A running program is just waited for.\n\nWith autoContinue (default), stops at other breakpoints are continued through, at most maxAutoContinues times. Any other stop (an exception, a pause, a step) ends the wait where it is, as does every stop with autoContinue: false. Conditions, hit counts and logpoints of the other breakpoints still apply; only their stops are skipped.\n\nREQUIRES: Program must be stopped or running, and breakpointId must be a breakpoint of the session (from debugger_set_breakpoint or debugger_list_breakpoints)\n\nOUTCOMES (status):\n- hit: stopped at the breakpoint (threadId, location, hitBreakpoints)\n- interrupted: stopped for something else (reason, location)\n- limitReached: stopped at another breakpoint after maxAutoContinues auto-continues (location)\n- terminated: the program ended\n- running: timeoutMs passed; the program is still running, use debugger_wait_for_stop or call this again\n\nTIMING: Until the hit or timeoutMs, plus one continue round trip (10-100ms) per auto-continue\n\nRETURNS: {\"status\", \"breakpointId\", \"autoContinues\", \"threadId\", \"reason\", \"location\": {\"function\", \"path\", \"line\"}, \"hitBreakpoints\"}\n\nSEE ALSO: debugger_wait_for_stop (any stop), debugger_continue",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "breakpointId": {
+                            "type": "integer",
+                            "description": "Id of the breakpoint to wait for"
+                        },
+                        "autoContinue": {
+                            "type": "boolean",
+                            "default": true,
+                            "description": "Continue through stops at other breakpoints (default: true)"
+                        },
+                        "maxAutoContinues": {
+                            "type": "integer",
+                            "description": "Give up after continuing through this many other stops (default: 100, max: 10000)"
+                        },
+                        "timeoutMs": {
+                            "type": "integer",
+                            "description": "Maximum time to wait in milliseconds, for the whole run (default: the session's waitForStopTimeoutMs)"
+                        }
+                    },
+                    "required": ["sessionId", "breakpointId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "until hit or timeoutMs",
+                    "workflow": "execution-control",
+                    "category": "debugging",
+                    "requiresState": ["Stopped", "Running"],
+                    "priority": 0.6
+                }
+            }),
+            json!({
+                "name": "debugger_list_threads",
+                "title": "List Threads",
+                "description": "Lists the program's threads with each thread's run state.\n\nSome debuggers stop only the thread that hit a breakpoint while others keep running. Use this to find which threads are stopped before inspecting them with threadId in debugger_stack_trace, debugger_evaluate, or the step tools.\n\nGOROUTINE LABELS (Go): with labels: true each stopped goroutine also gets its pprof labels (e.g. request or tenant ids set with pprof.Do) as labels: {key: value}, read through Delve's view of the runtime. Where this Go version's label internals can't be read, that goroutine gets labelsError (\"labels unavailable: ...\") instead; the listing itself still succeeds. Stop only the goroutines of one request with debugger_set_breakpoint's goroutineLabel.\n\nTIMING: Returns in 10-50ms; with labels, 2-4 evaluations per stopped goroutine\n\nRETURNS: {\"threads\": [{\"id\", \"name\", \"state\": \"stopped\"|\"running\"|\"unknown\", \"reason\" (when stopped), \"labels\" | \"labelsError\" (with labels)}]}",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "labels": {
+                            "type": "boolean",
+                            "description": "Go only: include the pprof labels of each stopped goroutine (default: false)"
+                        }
+                    },
+                    "required": ["sessionId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "10-50ms",
+                    "workflow": "inspection",
+                    "category": "debugging",
+                    "priority": 0.5
+                }
+            }),
+            json!({
+                "name": "debugger_list_goroutines",
+                "title": "List Goroutines",
+                "description": "Go only: lists the program's goroutines with the function each is executing, where, and whether it is blocked. The overview of a concurrent Go program: which goroutines exist, which are stuck on a channel or lock, and which one the program stopped in.\n\nFunctions come from Delve's goroutine names (each goroutine thread is named after its innermost user function) and the top 16 frames of each goroutine on the page, which also tell what it waits on (the waitReasons of debugger_analyze_hang). Where Delve's names don't have their usual form, the stacks alone are used (source: \"stackTrace\"). Goroutines made of runtime frames only (GC workers and the like) are marked system.\n\nPAGING: Programs can have thousands of goroutines; limit of them are listed per call (default 100, max 1000). Pass nextOffset as offset for the next page; it is null on the last page. Delve lists 1024 goroutines at most: with that many, truncated is true, total is a lower bound and the goroutines beyond are not listed (debugger_analyze_hang has the same limit).\n\nREQUIRES: A go session that is stopped (pause it with debugger_pause first)\n\nTIMING: One stackTrace request per goroutine listed, 100ms-2s per page\n\nRETURNS: {\"goroutines\": [{\"id\" (the threadId other tools take), \"function\", \"path\", \"line\", \"state\": \"blocked\"|\"running\"|\"unknown\", \"waitReason\", \"current\" (the goroutine the program stopped in), \"osThread\", \"system\"}], \"total\", \"offset\", \"nextOffset\", \"truncated\", \"source\": \"threadNames\"|\"stackTrace\"}\n\nSEE ALSO: debugger_analyze_hang (deadlock diagnosis), debugger_list_threads (labels), debugger_stack_trace({threadId})",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "offset": {
+                            "type": "integer",
+                            "description": "Goroutines to skip: nextOffset of the previous page (default: 0)",
+                            "minimum": 0
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Goroutines per page (default: 100, max: 1000)",
+                            "minimum": 1,
+                            "maximum": 1000
+                        }
+                    },
+                    "required": ["sessionId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "100-2000ms",
+                    "workflow": "inspection",
+                    "category": "debugging",
+                    "requiresState": ["Stopped"],
+                    "priority": 0.5
+                }
+            }),
+            json!({
+                "name": "debugger_source_context",
+                "title": "Show Source Context",
+                "description": "Shows the source lines around a stack frame's location, even when the file isn't in the workspace.\n\nResolution order: the local path, then the local Go module cache (GOMODCACHE) for module dependencies, then the debugger's own copy of the source. Results are cached per session.\n\nIf no copy is available, origin is \"source_unavailable\" and module holds the \"module@version\" string (Go) so you can fetch the dependency yourself.\n\nTIMING: Returns in 10-100ms\n\nRETURNS: {\"path\", \"origin\": \"local\"|\"module_cache\"|\"adapter\"|\"source_unavailable\", \"resolvedPath\", \"module\", \"lines\": [{\"line\", \"text\"}]}",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "frameId": {
+                            "type": "integer",
+                            "description": "Frame ID from debugger_stack_trace (the program must be stopped)"
+                        },
+                        "path": {
+                            "type": "string",
+                            "description": "Source path to show, used when frameId is omitted; it must be inside the workspace roots unless a frame of the stopped thread reported it"
+                        },
+                        "line": {
+                            "type": "integer",
+                            "description": "Line to center on (defaults to the frame's line)"
+                        },
+                        "contextLines": {
+                            "type": "integer",
+                            "description": "Lines to show before and after (default 5)",
+                            "default": 5
+                        }
+                    },
+                    "required": ["sessionId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "10-100ms",
+                    "workflow": "inspection",
+                    "category": "debugging",
+                    "priority": 0.5
+                }
+            }),
+            json!({
+                "name": "debugger_inline_values",
+                "title": "Show Inline Values",
+                "description": "Shows the current values of a frame's locals and arguments next to the lines of the current function that use them, like an IDE's inline values. Useful for explaining how the program got to its current state.\n\nThe function is found by a lexical scan backwards from the frame's line; only lines from the function start to the frame's line are included (at most 200). Each variable appears on every line where its name occurs as an identifier (not after a '.').\n\nFLAGS:\n- shadowed: the name refers to more than one variable in the frame (e.g. a local hiding a global, or a Go variable redeclared in an inner block); the innermost value is shown\n- truncated: the value was shortened (by the debugger, or to 120 characters)\n- rangeTruncated: the function is longer than the scan limit\n\nTIMING: Returns in 20-200ms\n\nRETURNS: {\"frameId\", \"path\", \"startLine\", \"endLine\", \"rangeTruncated\", \"values\": [{\"line\", \"name\", \"value\", \"type\", \"shadowed\", \"truncated\"}]}",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "frameId": {
+                            "type": "integer",
+                            "description": "Frame ID from debugger_stack_trace (the program must be stopped)"
+                        }
+                    },
+                    "required": ["sessionId", "frameId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "20-200ms",
+                    "workflow": "inspection",
+                    "category": "debugging",
+                    "priority": 0.5
+                }
+            }),
+            json!({
+                "name": "debugger_peek",
+                "title": "Peek at Values",
+                "description": "Returns the current values of up to 20 variables or fields by name, as short strings: the cheapest way to check a few values after a stop.\n\nNames are plain variable names or dotted field paths (e.g. \"total\", \"user.address.city\"), looked up in the frame's scopes, innermost first (expensive scopes such as Go globals are skipped). They may pick elements and entries too:\n- \"items[3]\", \"items[-1]\": one element, negative from the end\n- \"items[10:20]\", \"items[-10:]\": a slice, Python-style bounds, at most 100 elements, only as the last part of a name\n- \"m[\\\"key\\\"]\": a map, dict or hash entry\nOnly the elements asked for are fetched where the debugger supports paging, so the tail of a huge slice is cheap. Slice bounds out of range are clamped rather than failing, and reported with clamped: true.\n\nNothing is evaluated, so peeking can't have side effects; use debugger_evaluate for expressions or calls. Values carry no type or variablesReference and are cut at 120 characters.\n\nNames that aren't found map to null and are also listed in missing.\n\nTIMING: Returns in 20-200ms\n\nRETURNS: {\"frameId\", \"values\": {\"name\": \"value\" | null}, \"missing\": [\"name\"], \"slices\": {\"name\": {\"start\", \"end\", \"length\", \"values\", \"clamped\"}}}",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "names": {
+                            "type": "array",
+                            "items": {"type": "string"},
+                            "minItems": 1,
+                            "maxItems": 20,
+                            "description": "Variable names or dotted field paths (e.g. [\"i\", \"user.name\"])"
+                        },
+                        "frameId": {
+                            "type": "integer",
+                            "description": "Frame ID from debugger_stack_trace (optional, defaults to the stopped thread's top frame)"
+                        }
+                    },
+                    "required": ["sessionId", "names"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "20-200ms",
+                    "workflow": "inspection",
+                    "category": "debugging",
+                    "priority": 0.6
+                }
+            }),
+            json!({
+                "name": "debugger_dump_variable",
+                "title": "Dump Variable as JSON",
+                "description": "Returns a whole variable as a plain JSON value, to read or compare structs, objects, maps and lists without expanding them level by level.\n\nThe variable is named like in debugger_peek (\"order\", \"user.orders[0]\", \"m[\\\"key\\\"]\"; no slices) and its children are walked, without evaluating anything:\n- numbers, booleans and null/nil/None become JSON numbers, booleans and null; quoted strings become strings; other values (too large integers, NaN, symbols, opaque objects) stay the debugger's rendering\n- structs, objects, maps, dicts and hashes become objects; arrays, slices and lists become arrays\n- pointers are followed\n- all fields are kept, including Go's unexported fields and Python's _private and name-mangled attributes; debugger additions such as Python's len() and special variables or JavaScript's [[Prototype]] are left out\n\nA value met again inside itself is \"<cycle>\". Below maxDepth levels a value is \"<max depth>\"; after maxNodes values, the rest of each container is one \"<max nodes: N more>\" marker (as an array element, or as the value of the object key \"<truncated>\"), and truncated is true.\n\nTIMING: 20ms-2s, growing with the size of the value\n\nRETURNS: {\"frameId\", \"path\", \"value\", \"nodes\", \"truncated\"}",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "path": {
+                            "type": "string",
+                            "description": "Variable name or path (e.g. \"order\", \"user.orders[0]\")"
+                        },
+                        "frameId": {
+                            "type": "integer",
+                            "description": "Frame ID from debugger_stack_trace (optional, defaults to the stopped thread's top frame)"
+                        },
+                        "maxDepth": {
+                            "type": "integer",
+                            "minimum": 1,
+                            "maximum": 20,
+                            "description": "Levels below the variable to walk (default: 5)"
+                        },
+                        "maxNodes": {
+                            "type": "integer",
+                            "minimum": 1,
+                            "maximum": 10000,
+                            "description": "Values to write at most, containers included (default: 1000)"
+                        }
+                    },
+                    "required": ["sessionId", "path"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "20ms-2s",
+                    "workflow": "inspection",
+                    "category": "debugging",
+                    "priority": 0.55
+                }
+            }),
+            json!({
+                "name": "debugger_dereference",
+                "title": "Dereference Pointer",
+                "description": format!(
+                    "Returns what a pointer points to as a plain JSON value, without expanding the variable tree level by level. For Go and Rust.\n\nPass an expression yielding a pointer (\"c\", \"node.next\"): it is evaluated, then dereferenced (*(expr)) and the pointee's fields are walked like in debugger_dump_variable. A nil pointer is not an error: the result has nil: true and value: null. Alternatively pass the variablesReference of a pointer from debugger_variables.\n\nEXAMPLE: stopped in (*Calculator).Multiply of tests/fixtures/go/multifile, {{\"expression\": \"c\"}} returns {}\n\nTIMING: 20ms-2s, growing with the size of the value\n\nRETURNS: {{\"frameId\", \"expression\", \"pointer\", \"type\", \"nil\", \"value\", \"nodes\", \"truncated\"}} (nodes and truncated unless nil; with variablesReference: {{\"variablesReference\", \"nil\", \"value\", \"nodes\", \"truncated\"}})",
+                    DEREFERENCE_EXAMPLE
+                ),
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "expression": {
+                            "type": "string",
+                            "description": "Expression yielding a pointer (e.g. \"c\", \"node.next\")"
+                        },
+                        "variablesReference": {
+                            "type": "integer",
+                            "description": "variablesReference of a pointer from debugger_variables, instead of expression"
+                        },
+                        "frameId": {
+                            "type": "integer",
+                            "description": "Frame ID from debugger_stack_trace (optional, defaults to the stopped thread's top frame)"
+                        },
+                        "maxDepth": {
+                            "type": "integer",
+                            "minimum": 1,
+                            "maximum": 20,
+                            "description": "Levels below the pointee to walk (default: 5)"
+                        },
+                        "maxNodes": {
+                            "type": "integer",
+                            "minimum": 1,
+                            "maximum": 10000,
+                            "description": "Values to write at most, containers included (default: 1000)"
+                        }
+                    },
+                    "required": ["sessionId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "20ms-2s",
+                    "workflow": "inspection",
+                    "category": "debugging",
+                    "priority": 0.5
+                }
+            }),
+            json!({
+                "name": "debugger_scopes",
+                "title": "List Frame Scopes",
+                "description": "Lists the scopes of a stack frame (e.g. Locals, Arguments, Globals) without fetching their variables, so you can choose which to expand.\n\nEach scope has a variablesReference for fetching its variables, and an expensive flag: expensive scopes (typically globals) are slow to fetch and best skipped unless needed. Where the debugger reports it, a scope also has the source range it covers (source, line, column, endLine, endColumn) and a presentationHint (arguments, locals or registers).\n\nTIMING: Returns in 10-100ms\n\nRETURNS: {\"frameId\", \"scopes\": [{\"name\", \"variablesReference\", \"expensive\", \"presentationHint\"?, \"source\"?, \"line\"?, \"column\"?, \"endLine\"?, \"endColumn\"?}]}",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "frameId": {
+                            "type": "integer",
+                            "description": "Frame ID from debugger_stack_trace (the program must be stopped)"
+                        }
+                    },
+                    "required": ["sessionId", "frameId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "10-100ms",
+                    "workflow": "inspection",
+                    "category": "debugging",
+                    "priority": 0.5
+                }
+            }),
+            json!({
+                "name": "debugger_list_breakpoints",
+                "title": "List All Breakpoints",
+                "description": "Lists all breakpoints currently set across all source files.\n\nUSEFUL FOR:\n- Verifying which breakpoints are active\n- Checking breakpoint verification status\n- Debugging why a breakpoint might not be hit\n\nTIMING: Returns immediately (<10ms)\n\nRETURNS: breakpoints (array with id, verified status, enabled flag, line, sourcePath, hitCount: stops at the breakpoint so far, and subscribed: see debugger_subscribe) functionBreakpoints (from debugger_set_function_breakpoint) and instructionBreakpoints (from debugger_set_instruction_breakpoint)",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        }
+                    },
+                    "required": ["sessionId"]
+                }
+            }),
+            json!({
+                "name": "debugger_last_hit_breakpoints",
+                "title": "Show Breakpoints That Caused the Last Stop",
+                "description": "Returns the breakpoints that caused the most recent stop, as reported by the debugger. Use it when several breakpoints share a file or line (e.g. with different conditions) to know exactly which one fired. debugger_wait_for_stop includes the same list as hitBreakpoints.\n\nThe list is empty when the last stop wasn't a breakpoint hit (step, pause, exception) or the debugger doesn't report which breakpoint was hit. Ids the debugger reports that don't match a breakpoint set through this server are returned in unknownIds.\n\nTIMING: Returns immediately (<10ms)\n\nRETURNS: {\"hitBreakpoints\": [{\"id\", \"verified\", \"enabled\", \"line\", \"sourcePath\", \"condition\", \"hitCondition\", \"logMessage\"}], \"unknownIds\": [...]}",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        }
+                    },
+                    "required": ["sessionId"]
+                }
+            }),
+            json!({
+                "name": "debugger_enable_breakpoint",
+                "title": "Enable Breakpoint",
+                "description": "Re-enables a breakpoint previously disabled with debugger_disable_breakpoint.\n\nThe breakpoint is sent to the debugger again at its original location. Its id may change after re-enabling; the returned id is the current one.\n\nTIMING: Returns after the adapter acknowledges (<100ms)\n\nRETURNS: The breakpoint with id, verified status, enabled flag, line, and sourcePath",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "breakpointId": {
+                            "type": "integer",
+                            "description": "Breakpoint id from debugger_list_breakpoints"
+                        }
+                    },
+                    "required": ["sessionId", "breakpointId"]
+                }
+            }),
+            json!({
+                "name": "debugger_disable_breakpoint",
+                "title": "Disable Breakpoint",
+                "description": "Temporarily disables a breakpoint without removing it.\n\nThe breakpoint stays in debugger_list_breakpoints (enabled: false) and can be turned back on with debugger_enable_breakpoint.\n\nTIMING: Returns after the adapter acknowledges (<100ms)\n\nRETURNS: The breakpoint with id, verified status, enabled flag, line, and sourcePath",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "breakpointId": {
+                            "type": "integer",
+                            "description": "Breakpoint id from debugger_list_breakpoints"
+                        }
+                    },
+                    "required": ["sessionId", "breakpointId"]
+                }
+            }),
+            json!({
+                "name": "debugger_subscribe",
+                "title": "Follow Breakpoints",
+                "description": "Chooses the breakpoints whose stops you follow, for when a busy breakpoint (e.g. one in a loop) drowns out the one you care about. Every breakpoint is followed until debugger_unsubscribe is used; this follows the given breakpoints again, or all of them with all: true.\n\nStops are reported by debugger_wait_for_stop and debugger_session_state. With the session setting autoContinueUnsubscribed (debugger_configure), a stop where only unfollowed breakpoints were hit is continued at once and never reported, so those breakpoints just count: their hitCount in debugger_list_breakpoints keeps going up. Without it, a stop is a stop whichever breakpoint it is for. Stops ending a step or pause are never continued, even when the step ends on an unfollowed breakpoint. Only breakpoints the debugger reports hit ids for can be told apart; Node.js child sessions are not continued.\n\nTIMING: Returns immediately\n\nRETURNS: {\"subscription\": {\"all\": true, \"except\": [ids]} or {\"all\": false, \"breakpointIds\": [ids]}, \"autoContinueUnsubscribed\": bool}\n\nSEE ALSO: debugger_unsubscribe, debugger_list_breakpoints",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "breakpointIds": {
+                            "type": "array",
+                            "items": {"type": "integer"},
+                            "description": "Ids of the breakpoints to follow again (from debugger_set_breakpoint or debugger_list_breakpoints)"
+                        },
+                        "all": {
+                            "type": "boolean",
+                            "description": "Follow every breakpoint, instead of breakpointIds"
+                        }
+                    },
+                    "required": ["sessionId"]
+                }
+            }),
+            json!({
+                "name": "debugger_unsubscribe",
+                "title": "Stop Following Breakpoints",
+                "description": "Stops following the given breakpoints, or every breakpoint with all: true (then debugger_subscribe picks the few to follow). With the session setting autoContinueUnsubscribed (debugger_configure), stops where only unfollowed breakpoints were hit are continued at once and only counted: see hitCount in debugger_list_breakpoints. Stops ending a step or pause are always reported.\n\nTIMING: Returns immediately\n\nRETURNS: {\"subscription\": {\"all\": true, \"except\": [ids]} or {\"all\": false, \"breakpointIds\": [ids]}, \"autoContinueUnsubscribed\": bool}\n\nSEE ALSO: debugger_subscribe, debugger_configure",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "breakpointIds": {
+                            "type": "array",
+                            "items": {"type": "integer"},
+                            "description": "Ids of the breakpoints to stop following (from debugger_set_breakpoint or debugger_list_breakpoints)"
+                        },
+                        "all": {
+                            "type": "boolean",
+                            "description": "Stop following every breakpoint, instead of breakpointIds"
+                        }
+                    },
+                    "required": ["sessionId"]
+                }
+            }),
+            json!({
+                "name": "debugger_export_breakpoints",
+                "title": "Export Breakpoints",
+                "description": "Exports the session's breakpoints as a JSON document that can be saved, shared, and re-applied with debugger_import_breakpoints.\n\nEach entry has file, line, condition, hitCondition, logMessage, and enabled; a breakpoint with a changed(expr) condition keeps it as its condition. The document also records the session language, the watches of debugger_watch_change as {expression, file, line, maxAutoContinues} (their breakpoints aren't listed separately) and the exception breakpoint modes in effect as exceptionFilters (e.g. [\"uncaught\"]).\n\nTIMING: Returns immediately (<10ms)\n\nRETURNS: {\"version\": 1, \"language\", \"breakpoints\": [...], \"watches\": [...], \"exceptionFilters\": [...]}",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        }
+                    },
+                    "required": ["sessionId"]
+                }
+            }),
+            json!({
+                "name": "debugger_export_session",
+                "title": "Export Session Transcript",
+                "description": "Returns a structured transcript of the session for reproducing a problem or attaching to a bug report: the launch configuration, all breakpoints, every stop with its location, the evaluations performed and the program's exit code.\n\nEach stop has the thread, reason, hit breakpoint ids and location (function, path, line); if the program is currently stopped, the location of that stop is looked up first. Times are milliseconds since the session was created. Expressions and results are cut at 1000 characters and at most 1000 records of each kind are kept (droppedRecords counts the oldest ones dropped).\n\nREDACTION: Environment variables whose names contain SECRET, TOKEN, PASSWORD, PASSWD, CREDENTIAL, API_KEY, ACCESS_KEY, PRIVATE_KEY or AUTH are replaced by [REDACTED], and the server's redaction patterns apply to all other recorded values.\n\nExport before debugger_disconnect: the transcript is discarded with the session. Raw DAP traffic is not recorded.\n\nTIMING: Returns immediately (<10ms), or after one stack trace request when the current stop isn't located yet\n\nRETURNS: {\"sessionId\", \"language\", \"program\", \"startedAtMs\", \"durationMs\", \"state\", \"launches\": [{\"atMs\", \"adapterId\", \"arguments\"}], \"breakpoints\", \"instructionBreakpoints\", \"stops\": [{\"atMs\", \"threadId\", \"reason\", \"hitBreakpointIds\", \"function\", \"path\", \"line\"}], \"evaluations\": [{\"atMs\", \"expression\", \"frameId\", \"result\" | \"error\"}], \"exitCode\", \"crashReport\" (see debugger_session_state; null unless the program died of an unhandled exception or panic), \"breakpointSummary\" (see debugger_session_state; null until the session is Terminated or Failed), \"droppedRecords\"}",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        }
+                    },
+                    "required": ["sessionId"]
+                }
+            }),
+            json!({
+                "name": "debugger_import_breakpoints",
+                "title": "Import Breakpoints",
+                "description": "Applies a document from debugger_export_breakpoints to a session. Breakpoints on lines that already have one replace it.\n\nThe document's language must match the session's language, otherwise nothing is imported. Entries whose file doesn't exist (or is outside the workspace) fail individually. In a session started with goOptions.substitutePath, files that don't exist here but are under a rule's 'to' (paths exported from a remote or container session) are imported at the matching local path under 'from'.\n\nWatches are set like debugger_watch_change sets them, after the breakpoints, and exceptionFilters replace the session's exception breakpoint modes like debugger_set_exception_breakpoints; both fail individually where those tools would (e.g. watches in a read-only session, exception breakpoints before the session is initialized). Watches that are plain expressions (from debugger_save_config) aren't applied and are reported in ignored.\n\nTIMING: Returns after the adapter acknowledges (<100ms per file)\n\nRETURNS: {\"results\": [{\"file\", \"line\", \"status\": \"verified\"|\"unverified\"|\"disabled\"|\"pending\"|\"failed\", \"reason\"}, then {\"watch\", \"file\", \"line\", \"status\", ...} per watch and {\"exceptionFilters\", \"status\", ...}], \"failed\": [results that failed or were not verified], \"ignored\": [document sections not applied]}",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "document": {
+                            "type": "object",
+                            "description": "Document returned by debugger_export_breakpoints"
+                        }
+                    },
+                    "required": ["sessionId", "document"]
+                }
+            }),
+            json!({
+                "name": "debugger_save_config",
+                "title": "Save Launch Config",
+                "description": "Saves a debugging setup under a name so it can be started again in one call with debugger_load_config: the debugger_start arguments (program, args, env, cwd, options), breakpoints and watches.\n\nCAPTURE a session: pass sessionId to save the arguments it was started with and its current breakpoints.\nDESCRIBE one: pass start (debugger_start arguments) and optionally breakpoints.\nEither way, breakpoints replaces the breakpoints saved and watches lists expressions to evaluate after loading (sessions don't evaluate them by themselves).\n\nConfigs are kept for the life of the server (at most 100); saving under an existing name replaces it. With workspace, the config is only found when loading with the same workspace, so projects can reuse names. processId can't be saved, since the process won't exist later; attach by processName instead.\n\nTIMING: Returns immediately\n\nRETURNS: {\"name\", \"workspace\", \"replaced\": bool, \"config\": {\"name\", \"workspace\", \"start\", \"breakpoints\": breakpoint document, \"savedAtMs\"}}\n\nSEE ALSO: debugger_load_config, debugger_export_breakpoints",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "name": {
+                            "type": "string",
+                            "description": "Config name: 1-64 letters, digits, '-', '_' or '.'"
+                        },
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session to capture (either this or start)"
+                        },
+                        "start": {
+                            "type": "object",
+                            "description": "debugger_start arguments, including language (either this or sessionId)"
+                        },
+                        "breakpoints": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "properties": {
+                                    "file": { "type": "string" },
+                                    "line": { "type": "integer" },
+                                    "condition": { "type": "string" },
+                                    "hitCondition": { "type": "string" },
+                                    "logMessage": { "type": "string" },
+                                    "enabled": { "type": "boolean" }
+                                },
+                                "required": ["file", "line"]
+                            },
+                            "description": "Breakpoints to save (optional, default: the session's, or none)"
+                        },
+                        "watches": {
+                            "type": "array",
+                            "items": { "type": "string" },
+                            "description": "Expressions to evaluate after loading (optional)"
+                        },
+                        "workspace": {
+                            "type": "string",
+                            "description": "Workspace directory to scope the config to (optional, default: server-wide)"
+                        }
+                    },
+                    "required": ["name"]
+                }
+            }),
+            json!({
+                "name": "debugger_load_config",
+                "title": "Load Launch Config",
+                "description": "Starts a new session from a config saved with debugger_save_config and applies its breakpoints, replacing a debugger_start followed by setting every breakpoint.\n\nThe config is validated again when loaded: the program, cwd and breakpoint files must still exist and be inside the workspace roots. Breakpoints whose file is gone are listed in failed; the session starts regardless.\n\nTIMING: Returns like debugger_start; the breakpoints are applied when the session finishes initializing\n\nRETURNS: the debugger_start result ({\"sessionId\", \"status\", ...}) plus {\"config\": name, \"breakpoints\": [{\"status\", \"file\", \"line\"}], \"failed\": [...], \"watches\": [expressions to evaluate once stopped]}\n\nSEE ALSO: debugger_save_config, debugger_wait_for_stop",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "name": {
+                            "type": "string",
+                            "description": "Name the config was saved under"
+                        },
+                        "workspace": {
+                            "type": "string",
+                            "description": "Workspace the config was saved with (optional, default: server-wide configs)"
+                        }
+                    },
+                    "required": ["name"]
+                }
+            }),
+            json!({
+                "name": "debugger_start_from_launch_json",
+                "title": "Start From launch.json",
+                "description": "Starts a session from a configuration of a VS Code launch.json, so the IDE and the server debug the same way without keeping two setups. The file may have comments and trailing commas.\n\nTYPES: go (Delve), debugpy or python (Python), rdbg (Ruby). Translated attributes:\n- all: request (launch, or attach with processId), program, args, cwd, stopOnEntry\n- go: mode (debug, test, exec; auto is test for a _test.go program, else debug; local for attach), substitutePath and output (goOptions)\n- debugpy: module, env, justMyCode, python (pythonPath)\n- rdbg: script (program), useBundler and command 'bundle exec ruby' (rubyOptions.bundler)\nAttributes only VS Code's UI uses (console, presentation, internalConsoleOptions, showLog, trace, ...) are ignored and listed. Any other attribute is rejected with an error naming it (e.g. preLaunchTask, envFile, env for go and rdbg, buildFlags, rdbg attach), rather than silently debugging something else than VS Code would.\n\nVARIABLES: ${workspaceFolder} (the directory holding .vscode, or the file's directory), ${workspaceFolderBasename}, ${file}, ${fileBasename} and ${fileDirname}. No editor has a file open, so the file ones need overrides.file. ${env:...}, ${command:...} and ${input:...} are rejected; replace the attribute through overrides.\n\nOVERRIDES: workspaceFolder and file set the variables; any other key replaces that attribute of the configuration before it is translated (e.g. {\"stopOnEntry\": true}), and null removes it (e.g. {\"preLaunchTask\": null} after running the task yourself).\n\nTIMING: Returns like debugger_start\n\nRETURNS: the debugger_start result plus \"launchJson\": {\"path\", \"configuration\": {\"name\", \"type\", \"request\", \"start\" (the debugger_start arguments used), \"ignored\"}}\n\nSEE ALSO: debugger_start, debugger_save_config (keep the session's setup on the server)",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "path": {
+                            "type": "string",
+                            "description": "Path of the launch.json (e.g. /workspace/.vscode/launch.json)"
+                        },
+                        "configurationName": {
+                            "type": "string",
+                            "description": "name of the configuration to start"
+                        },
+                        "overrides": {
+                            "type": "object",
+                            "description": "workspaceFolder and file: values of those variables; other keys: attributes replacing the configuration's, null to remove one"
+                        }
+                    },
+                    "required": ["path", "configurationName"]
+                }
+            }),
+            json!({
+                "name": "debugger_step_over",
+                "title": "Step Over (Next Line)",
+                "description": "Executes the current line and stops at the next line. Does NOT step into function calls.\n\nREQUIRES: Program must be stopped (at breakpoint, entry, or previous step)\n\nWORKFLOW:\n1. Ensure program is stopped\n2. Call this tool to execute one line; it returns once the step stops\n3. Only if the response says \"stepping\", use debugger_wait_for_stop\n4. Inspect state with debugger_stack_trace and debugger_evaluate\n\nWAITS for the step to complete (up to the server's wait-for-stop timeout, 5s by default):\n- Stopped: {\"status\": \"stopped\", \"threadId\", \"reason\", \"returnValue\", \"returnValues\"}\n- Program ended: {\"status\": \"terminated\"}\n- Still running: {\"status\": \"stepping\"}; use debugger_wait_for_stop\nEvery step response has granularity: the granularity the step went by (statement by default, line for debuggers without stepping granularity such as Delve, debugpy and rdbg). A granularity the debugger can't step by falls back to line stepping instead of failing, flagged with granularityDowngraded: true and requestedGranularity. The same applies to debugger_step_into and debugger_step_out.\n\nRETURN VALUES: debugpy (Python), rdbg (Ruby) and js-debug (Node.js) report the value of a call stepped over (e.g. result = fizzbuzz(i)): returnValue is that value and returnValues lists all of them, as for debugger_step_out. Delve (Go) reports return values only after debugger_step_out and CodeLLDB (Rust) never does; for them, and when no call returned, returnValue is null and returnValueUnavailable says why.\n\nSTEP FILTERS: Go and Ruby sessions start with step filters (standard library packages such as 'fmt.*' and 'runtime.*'; '*/gems/*'), changeable with debugger_configure. A stop inside filtered code is stepped out of automatically (at most 20 steps): {\"status\": \"stopped\", \"threadId\", \"reason\", \"skippedFrames\": steps taken automatically}; \"stepFilterLimitReached\": true if still in filtered code\n\nSEE ALSO: debugger_step_into (to step into functions), debugger_step_out (to step out)",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "threadId": {
+                            "type": "integer",
+                            "description": "Thread ID (optional, uses stopped thread if not specified)"
+                        },
+                        "granularity": {
+                            "type": "string",
+                            "enum": ["statement", "line", "instruction"],
+                            "description": "How far to step (e.g. instruction, to step through assembly with debugger_disassemble). Debuggers without stepping granularity step by line instead; the response's granularity is what the step went by, with granularityDowngraded: true when that isn't what was asked"
+                        }
+                    },
+                    "required": ["sessionId"]
+                }
+            }),
+            json!({
+                "name": "debugger_step_into",
+                "title": "Step Into (Enter Function)",
+                "description": "Steps into function calls on the current line. If no function call, behaves like step_over.\n\nREQUIRES: Program must be stopped\n\nUSEFUL FOR: Debugging function implementations line by line\n\nWORKFLOW: Returns {\"status\": \"stepping\"} at once; use debugger_wait_for_stop to wait for the step to complete. Unlike debugger_step_over and debugger_step_out it doesn't wait, except while step filters are set (Go and Ruby by default), when it waits and responds as debugger_step_over does.\n\nSTEP FILTERS: As for debugger_step_over; stepping into a filtered function (e.g. fmt.Printf) comes back out of it\n\nSEE ALSO: debugger_step_over (to skip functions), debugger_step_out (to exit function)",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start"
+                        },
+                        "threadId": {
+                            "type": "integer",
+                            "description": "Thread ID (optional)"
+                        },
+                        "granularity": {
+                            "type": "string",
+                            "enum": ["statement", "line", "instruction"],
+                            "description": "How far to step (e.g. instruction, to step through assembly with debugger_disassemble). Debuggers without stepping granularity step by line instead; the response's granularity is what the step went by, with granularityDowngraded: true when that isn't what was asked"
+                        }
+                    },
+                    "required": ["sessionId"]
+                }
+            }),
+            json!({
+                "name": "debugger_step_out",
+                "title": "Step Out (Exit Function)",
+                "description": "Continues execution until the current function returns, then stops at the caller.\n\nREQUIRES: Program must be stopped inside a function\n\nUSEFUL FOR: Quickly exiting from deep call stacks, and seeing what a function returned\n\nWAITS for the step to complete, as debugger_step_over does, so debugger_wait_for_stop is only needed when the response says \"stepping\" (the server's wait-for-stop timeout, 5s by default, ran out):\n- Stopped in the caller: {\"status\": \"stopped\", \"threadId\", \"reason\", \"returnValue\", \"returnValues\": [{\"name\", \"value\", \"type\", \"variablesReference\"}]}\n- Program ended: {\"status\": \"terminated\"}\n- Still running (e.g. the function hit a long loop): {\"status\": \"stepping\"}; use debugger_wait_for_stop\n\nreturnValue is the first value returned. When the debugger doesn't report any (CodeLLDB never does), returnValue is null and returnValueUnavailable says why. Names follow the debugger: '(return) fizzbuzz' (Python), '~r0' or '(ret) err' (Go), '%return' (Ruby), 'Return value' (Node.js).\n\nSEE ALSO: debugger_step_into (to enter function), debugger_step_over (to skip line)",
                 "inputSchema": {
                     "type": "object",
                     "properties": {
-                        "language": {
-                            "type": "string",
-                            "description": "Programming language (e.g., 'python', 'ruby', 'javascript', 'rust', 'go')"
-                        },
-                        "program": {
+                        "sessionId": {
                             "type": "string",
-                            "description": "Absolute or relative path to the program file to debug"
+                            "description": "Session ID from debugger_start"
                         },
-                        "args": {
-                            "type": "array",
-                            "items": { "type": "string" },
-                            "description": "Command-line arguments passed to the program (optional, defaults to empty array)"
+                        "threadId": {
+                            "type": "integer",
+                            "description": "Thread ID (optional)"
                         },
-                        "cwd": {
+                        "granularity": {
                             "type": "string",
-                            "description": "Working directory for the program execution (optional, defaults to program's directory)"
-                        },
-                        "stopOnEntry": {
-                            "type": "boolean",
-                            "description": "If true, pauses execution at the program's first line (recommended for setting early breakpoints)"
+                            "enum": ["statement", "line", "instruction"],
+                            "description": "How far to step (e.g. instruction, to step through assembly with debugger_disassemble). Debuggers without stepping granularity step by line instead; the response's granularity is what the step went by, with granularityDowngraded: true when that isn't what was asked"
                         }
                     },
-                    "required": ["language", "program"]
-                },
-                "annotations": {
-                    "async": true,
-                    "returnsTiming": "< 100ms",
-                    "completionTiming": "200-500ms (background)",
-                    "workflow": "initialization",
-                    "requiredFollowUp": ["debugger_session_state"],
-                    "category": "session-management",
-                    "priority": 1.0
+                    "required": ["sessionId"]
                 }
             }),
             json!({
-                "name": "debugger_session_state",
-                "title": "Check Session State",
-                "description": "Retrieves the current state of a debugging session. Essential for tracking async initialization progress.\n\nWORKFLOW USAGE:\n- After debugger_start: Poll this until state is 'Running' or 'Stopped' (not 'Initializing')\n- Before setting breakpoints: Verify state is 'Stopped' (with stopOnEntry) or 'Running'\n- After operations: Check state to verify success or detect failures\n\nSTATES:\n- NotStarted: Session created but not yet initialized\n- Initializing: DAP adapter starting (wait for this to complete)\n- Launching: Program starting\n- Running: Program executing (can set breakpoints)\n- Stopped: Hit breakpoint or paused (details.reason shows why)\n- Terminated: Program exited normally\n- Failed: Error occurred (details.error shows message)\n\nTIMING: Returns immediately (<10ms)\n\nTIP: When state is 'Stopped', check details.reason to understand why (e.g., 'entry', 'breakpoint', 'step')\n\nSEE ALSO: debugger://state-machine (complete state diagram), debugger-docs://guide/async-initialization",
+                "name": "debugger_step_out_of_file",
+                "title": "Step Out of File",
+                "description": "Steps until execution leaves the current source file, e.g. to escape a library or helper file back to the calling code without stepping through it line by line.\n\nSteps out while a caller further down the stack is in another file, otherwise steps over line by line. Stops early when a breakpoint or exception interrupts a step.\n\nREQUIRES: Program must be stopped in a frame with a source file\n\nOUTCOMES (status):\n- left: now stopped in another file (location)\n- interrupted: a breakpoint, exception or pause stopped the program first (reason, location)\n- limitReached: still in the file after maxSteps steps (location)\n- terminated: the program ended\n- running: a step didn't complete within the wait-for-stop timeout; use debugger_wait_for_stop\n\nTIMING: One step round trip per step (typically 10-100ms each)\n\nRETURNS: {\"status\", \"steps\", \"fromPath\", \"threadId\", \"reason\", \"location\": {\"function\", \"path\", \"line\"}}\n\nSEE ALSO: debugger_step_out (one function at a time)",
                 "inputSchema": {
                     "type": "object",
                     "properties": {
                         "sessionId": {
                             "type": "string",
-                            "description": "Session ID returned from debugger_start"
+                            "description": "Session ID from debugger_start"
+                        },
+                        "threadId": {
+                            "type": "integer",
+                            "description": "Thread ID (optional, uses stopped thread if not specified)"
+                        },
+                        "maxSteps": {
+                            "type": "integer",
+                            "description": "Give up after this many steps (default: 100, max: 1000)"
                         }
                     },
                     "required": ["sessionId"]
                 },
                 "annotations": {
                     "async": false,
-                    "returnsTiming": "< 10ms",
-                    "workflow": "state-checking",
-                    "category": "session-management",
-                    "pollable": true,
-                    "priority": 0.9
+                    "returnsTiming": "10ms-10s",
+                    "workflow": "execution-control",
+                    "category": "debugging",
+                    "requiresState": ["Stopped"],
+                    "priority": 0.5
                 }
             }),
             json!({
-                "name": "debugger_set_breakpoint",
-                "title": "Set Breakpoint",
-                "description": "Sets a breakpoint at a specific line in a source file. The debugger will pause execution when this line is about to execute.\n\nWORKFLOW:\n1. Ensure session state is 'Stopped' (recommended) or 'Running'\n2. Call this tool with the source file path and line number\n3. Check the 'verified' field in response (true = breakpoint accepted)\n4. Use debugger_continue to resume execution until breakpoint is hit\n\nTIMING: Returns in 5-20ms\n\nIMPORTANT: Use stopOnEntry: true when starting the session to pause before code execution, giving you time to set breakpoints.\n\nTIP: The sourcePath must match the path used by the debugger. For best results, use absolute paths.\n\nRETURNS:\n- verified: true if breakpoint was successfully set and recognized by the debugger\n- sourcePath: echo of the source file path\n- line: echo of the line number\n\nSEE ALSO: debugger_continue (to hit the breakpoint), debugger://workflows (breakpoint examples)",
+                "name": "debugger_step_to_line",
+                "title": "Step To Line (Current Function)",
+                "description": "Runs to a line of the current function by stepping over line by line, e.g. to jump to a branch such as fizzbuzz's 'return \"Buzz\"'. Like run-to-cursor, but never leaves the function: calls on the way are stepped over, and it ends if the function returns first. Stops early when a breakpoint or exception interrupts a step, reporting where.\n\nAt least one step is taken, so from the target line itself it runs to the line's next execution (e.g. the next loop iteration).\n\nREQUIRES: Program must be stopped\n\nOUTCOMES (status):\n- reached: stopped on the line (location)\n- interrupted: a breakpoint, exception or pause stopped the program first (reason, location)\n- returned: the function returned without reaching the line, e.g. the branch wasn't taken (location in the caller)\n- limitReached: the line wasn't reached within maxSteps steps (location)\n- terminated: the program ended\n- running: a step didn't complete within the wait-for-stop timeout; use debugger_wait_for_stop\n\nTIMING: One step round trip per line executed (typically 10-100ms each)\n\nRETURNS: {\"status\", \"steps\", \"line\", \"threadId\", \"reason\", \"location\": {\"function\", \"path\", \"line\"}}\n\nSEE ALSO: debugger_step_over (one line), debugger_set_breakpoint with debugger_continue (to run to a line anywhere)",
                 "inputSchema": {
                     "type": "object",
                     "properties": {
@@ -518,77 +5627,79 @@ impl ToolsHandler {
                             "type": "string",
                             "description": "Session ID from debugger_start"
                         },
-                        "sourcePath": {
-                            "type": "string",
-                            "description": "Absolute or relative path to the source file (must match debugger's path resolution)"
-                        },
                         "line": {
                             "type": "integer",
-                            "description": "Line number where breakpoint should be set (1-indexed, i.e., first line is 1)"
+                            "description": "Line of the current function to stop on (1-based)"
+                        },
+                        "threadId": {
+                            "type": "integer",
+                            "description": "Thread ID (optional, uses stopped thread if not specified)"
+                        },
+                        "maxSteps": {
+                            "type": "integer",
+                            "description": "Give up after this many steps (default: 100, max: 1000)"
                         }
                     },
-                    "required": ["sessionId", "sourcePath", "line"]
+                    "required": ["sessionId", "line"]
                 },
                 "annotations": {
                     "async": false,
-                    "returnsTiming": "5-20ms",
-                    "workflow": "breakpoint-management",
+                    "returnsTiming": "10ms-10s",
+                    "workflow": "execution-control",
                     "category": "debugging",
-                    "requiresState": ["Running", "Stopped"],
-                    "priority": 0.8
+                    "requiresState": ["Stopped"],
+                    "priority": 0.5
                 }
             }),
             json!({
-                "name": "debugger_continue",
-                "title": "Continue Execution",
-                "description": "Resumes program execution after being paused (e.g., at a breakpoint or entry point). Execution continues until the next breakpoint, exception, or program termination.\n\nWORKFLOW:\n1. Session must be in 'Stopped' state (verify with debugger_session_state)\n2. Call this tool to resume execution\n3. Poll debugger_session_state to detect when execution stops again\n4. When state returns to 'Stopped', check details.reason:\n   - 'breakpoint': Hit a breakpoint (use debugger_stack_trace to inspect)\n   - 'exception': Uncaught exception occurred\n   - 'pause': Manual pause requested\n   - 'step': Completed a step operation\n\nTIMING: Returns in <10ms (but program continues running asynchronously)\n\nTIP: After calling continue, immediately poll debugger_session_state in a loop to detect when the program stops again.\n\nRETURNS: {\"status\": \"continued\"}\n\nSEE ALSO: debugger_stack_trace (inspect state when stopped), debugger://workflows (execution control patterns)",
+                "name": "debugger_analyze_hang",
+                "title": "Analyze Hang (Go)",
+                "description": "Diagnoses why a Go program appears to hang. Pauses the program if it is running, lists every goroutine with its innermost user function and location, groups them by what they wait on (chan receive, chan send, select, mutex, waitgroup, cond, sleep, io wait, other, or running) and flags likely deadlocks.\n\nFINDINGS:\n- allBlocked: every goroutine is blocked and none waits on a timer or I/O, so nothing can wake them\n- channelDeadlock: goroutines waiting on channel operations no other goroutine can complete (e.g. unbuffered sends/receives waiting for each other)\n- lockDeadlock: goroutines waiting on mutexes held by blocked goroutines\n\nRuntime-internal goroutines (GC workers, the goroutine signal.Notify starts, etc.) are counted in systemGoroutines but not analyzed. At most 256 goroutines are inspected (truncated: true when there were more).\n\nREQUIRES: A go session that is running or stopped. The program stays paused afterwards; inspect goroutines with debugger_stack_trace({threadId}) and resume with debugger_continue.\n\nTIMING: 100ms-2s depending on the number of goroutines\n\nRETURNS: {\"deadlock\", \"summary\", \"findings\": [{\"kind\", \"goroutines\", \"message\"}], \"groups\": {reason: [goroutine ids]}, \"goroutines\": [{\"id\", \"function\", \"path\", \"line\", \"state\", \"waitReason\"}], \"systemGoroutines\", \"truncated\"}",
                 "inputSchema": {
                     "type": "object",
                     "properties": {
                         "sessionId": {
                             "type": "string",
-                            "description": "Session ID from debugger_start"
+                            "description": "Session ID of a go session"
                         }
                     },
                     "required": ["sessionId"]
                 },
                 "annotations": {
-                    "async": true,
-                    "returnsTiming": "< 10ms",
-                    "completionTiming": "unknown (until next stop)",
-                    "workflow": "execution-control",
+                    "async": false,
+                    "returnsTiming": "100ms-2s",
+                    "workflow": "inspection",
                     "category": "debugging",
-                    "requiresState": ["Stopped"],
-                    "priority": 0.7
+                    "priority": 0.4
                 }
             }),
             json!({
-                "name": "debugger_stack_trace",
-                "title": "Get Stack Trace",
-                "description": "Retrieves the current call stack when execution is paused. Shows the sequence of function calls that led to the current execution point.\n\n⭐ PRIMARY PURPOSE: Get Frame IDs for debugger_evaluate\n======================================================\nThe 'id' field in each frame is CRITICAL - use it with debugger_evaluate to access variables:\n\nRETURNS: Array of stack frames, each containing:\n- id: Frame identifier → USE THIS as frameId in debugger_evaluate ⭐\n- name: Function/method name\n- source: {path: \"file path\", name: \"filename\"}\n- line: Current line number in this frame\n- column: Column number (if available)\n\n⚠️ Frame IDs Change Between Stops!\n================================\nFrame IDs are NOT stable across different stop events:\n- After EACH stop (breakpoint, step, continue), frame IDs change\n- ALWAYS call debugger_stack_trace fresh after each stop\n- NEVER reuse frame IDs from previous stops\n\nEXAMPLE PATTERN:\n  // Stop 1: Hit breakpoint\n  debugger_wait_for_stop()\n  stack1 = debugger_stack_trace()\n  frameId1 = stack1.stackFrames[0].id  // e.g., id = 5\n  debugger_evaluate({expression: \"x\", frameId: frameId1})  ✓\n  \n  // Stop 2: After continue and hit another breakpoint\n  debugger_continue()\n  debugger_wait_for_stop()\n  stack2 = debugger_stack_trace()  // GET FRESH TRACE!\n  frameId2 = stack2.stackFrames[0].id  // e.g., id = 8 (DIFFERENT!)\n  \n  // Using old frameId1 here would FAIL ❌\n  debugger_evaluate({expression: \"x\", frameId: frameId2})  ✓ Correct\n\nWORKFLOW:\n1. Session must be in 'Stopped' state (e.g., at a breakpoint)\n2. Call this tool to get current stack frames\n3. Extract the 'id' field from desired frame\n4. Pass that 'id' as frameId to debugger_evaluate\n5. Repeat steps 2-4 after each new stop event\n\nTIMING: Returns in 10-50ms depending on stack depth\n\nTIP: The first frame (index 0) is the current execution point. Higher indices are caller frames.\n\nCOMMON USE CASES:\n- Get frame IDs for debugger_evaluate (primary use)\n- Inspect where a breakpoint was hit\n- Understand call hierarchy\n- Diagnose unexpected execution paths\n\nSEE ALSO: debugger_evaluate (requires frame IDs from this tool), debugger://patterns (frame ID usage examples)",
+                "name": "debugger_list_async_tasks",
+                "title": "List Asyncio Tasks (Python)",
+                "description": "Lists the asyncio tasks of the event loop a Python program is stopped in. debugger_list_threads only shows OS threads, so this is how to see what the other coroutines are doing.\n\nFor each task: name, coroutine, state (pending, running = the task the program stopped in, done, cancelled), where it is suspended (function, path, line) and the future it waits on (waitingOn). Tasks are sorted by name; at most 50 are returned (truncated: true when there are more, total gives the count).\n\nREQUIRES: A python session stopped inside async code (e.g. at a breakpoint in a coroutine). Stopped elsewhere, the result has eventLoop: false and a message saying there is no running event loop.\n\nTIMING: 10-200ms\n\nRETURNS: {\"eventLoop\", \"total\", \"tasks\": [{\"name\", \"coroutine\", \"state\", \"function\", \"path\", \"line\", \"waitingOn\"}], \"truncated\", \"message\"}",
                 "inputSchema": {
                     "type": "object",
                     "properties": {
                         "sessionId": {
                             "type": "string",
-                            "description": "Session ID from debugger_start"
+                            "description": "Session ID of a python session"
                         }
                     },
                     "required": ["sessionId"]
                 },
                 "annotations": {
                     "async": false,
-                    "returnsTiming": "10-50ms",
+                    "returnsTiming": "10-200ms",
                     "workflow": "inspection",
                     "category": "debugging",
                     "requiresState": ["Stopped"],
-                    "priority": 0.6
+                    "priority": 0.4
                 }
             }),
             json!({
-                "name": "debugger_evaluate",
-                "title": "Evaluate Expression",
-                "description": "Evaluates an expression in the context of the paused program. Can access variables, call functions, and perform computations using the program's current state.\n\n⚠️ CRITICAL: frameId Requirement\n================================\nWhile technically optional, frameId is REQUIRED in practice for accessing local variables:\n\n❌ WITHOUT frameId:\n  debugger_evaluate({expression: \"local_var\"})\n  → Result: NameError: name 'local_var' is not defined\n  \n  Why: Evaluates in global/default context where local variables don't exist\n\n✅ WITH frameId (REQUIRED WORKFLOW):\n  1. Get stack trace: stack = debugger_stack_trace()\n  2. Extract frame ID: frameId = stack.stackFrames[0].id\n  3. Evaluate with frameId:\n     debugger_evaluate({expression: \"local_var\", frameId: frameId})\n  → Result: Successfully accesses local variable ✓\n\n⚠️ Frame IDs Change Between Stops!\n  - Frame IDs are NOT stable across different stop events\n  - ALWAYS get a fresh stack trace after each stop\n  - NEVER reuse frame IDs from previous stops\n\nEXAMPLE PATTERN (Correct Way):\n  // After hitting breakpoint:\n  const stack = debugger_stack_trace()\n  const frameId = stack.stackFrames[0].id  // Current frame\n  const value = debugger_evaluate({expression: \"n\", frameId: frameId})\n  \n  // After next stop, get NEW frame ID:\n  const stack2 = debugger_stack_trace()  // Fresh trace!\n  const frameId2 = stack2.stackFrames[0].id  // New frame ID\n  const value2 = debugger_evaluate({expression: \"n\", frameId: frameId2})\n\nWORKFLOW:\n1. Session must be in 'Stopped' state\n2. Call debugger_stack_trace to get current stack frames\n3. Extract frame ID from desired frame (usually frame[0] for current location)\n4. Call this tool with expression AND frameId\n5. Examine the result value\n\nTIMING: Returns in 20-200ms depending on expression complexity\n\nEXPRESSION EXAMPLES:\n- Variable access: \"x\", \"obj.property\", \"array[0]\"\n- Arithmetic: \"x + y\", \"count * 2\"\n- Comparisons: \"x > 10\", \"status == 'ready'\"\n- Function calls: \"len(array)\", \"obj.method()\"\n- Complex: \"[item for item in list if item > 0]\" (Python)\n\nRETURNS: {\"result\": \"string representation of evaluation result\"}\n\nCOMMON ERROR:\n  \"NameError: name 'variable' is not defined\"\n  → Solution: Add frameId parameter from debugger_stack_trace\n\nSEE ALSO: debugger_stack_trace (get frame IDs), debugger://patterns (cookbook examples)",
+                "name": "debugger_configure",
+                "title": "Configure Session",
+                "description": "Changes session options after debugger_start and returns the settings in effect. Only the settings given are changed; all are validated before any is applied, and unknown settings are refused with the list of valid ones.\n\nLIVE SETTINGS (take effect immediately):\n- autoContinueUnsubscribed (boolean): continue at once from stops where only breakpoints unsubscribed with debugger_unsubscribe were hit; they count hits without stopping\n- captureOnException (boolean): snapshot exception, stack and locals on exception stops\n- exceptionBreakpoints (array of 'raised' | 'uncaught'): re-sent to the debugger, replacing the current modes; empty turns them off\n- keepAliveIntervalMs (integer, 0 = off): adapter keep-alive pings\n- stepFilters (array of patterns): code steps never stop in, matched against function names and source paths; '*' matches anything, a pattern without '*' is a prefix (e.g. 'fmt.*', '*/gems/*'); [] turns filtering off\n- stopPollIntervalMs (integer, 0 = off): polling for missed stop events\n- verifySource (boolean): check breakpoint sources against the running program. A file whose content changed since the program was launched (edited but not rebuilt; compared by SHA256, not modification time) gets a sourceWarning with its breakpoints, since they may bind to stale lines; debuggers reporting supportedChecksumAlgorithms (see debugger_get_capabilities) also get SHA256 checksums to check themselves\n- waitForStopTimeoutMs (integer, 1-300000): default timeout of debugger_wait_for_stop and of the waits after steps\n\nRESTART SETTINGS (read by the debugger at launch only):\n- justMyCode (boolean, python only)\n- stopOnEntry (boolean)\nThese are validated and listed in requiresRestart when they differ from the launch; pass them to debugger_start to apply them.\n\nTIMING: Returns in < 50ms\n\nRETURNS: {\"settings\": {all settings in effect}, \"requiresRestart\": [restart settings that were not applied]}\n\nSEE ALSO: debugger_set_exception_breakpoints, debugger_get_config (server-wide defaults)",
                 "inputSchema": {
                     "type": "object",
                     "properties": {
@@ -596,53 +5707,84 @@ impl ToolsHandler {
                             "type": "string",
                             "description": "Session ID from debugger_start"
                         },
-                        "expression": {
-                            "type": "string",
-                            "description": "Expression to evaluate (syntax depends on programming language being debugged)"
-                        },
-                        "frameId": {
-                            "type": "integer",
-                            "description": "Stack frame ID from debugger_stack_trace (optional, defaults to current frame)"
+                        "settings": {
+                            "type": "object",
+                            "description": "Settings to change; omit or pass {} to just read the current settings",
+                            "properties": {
+                                "autoContinueUnsubscribed": {"type": "boolean"},
+                                "captureOnException": {"type": "boolean"},
+                                "exceptionBreakpoints": {
+                                    "type": "array",
+                                    "items": {"type": "string", "enum": ["raised", "uncaught"]}
+                                },
+                                "keepAliveIntervalMs": {"type": "integer", "minimum": 0},
+                                "stepFilters": {"type": "array", "items": {"type": "string"}},
+                                "stopPollIntervalMs": {"type": "integer", "minimum": 0},
+                                "verifySource": {"type": "boolean"},
+                                "waitForStopTimeoutMs": {"type": "integer", "minimum": 1, "maximum": 300000},
+                                "justMyCode": {"type": "boolean"},
+                                "stopOnEntry": {"type": "boolean"}
+                            },
+                            "additionalProperties": false
                         }
                     },
-                    "required": ["sessionId", "expression"]
+                    "required": ["sessionId"]
                 },
                 "annotations": {
                     "async": false,
-                    "returnsTiming": "20-200ms",
+                    "returnsTiming": "< 50ms",
+                    "workflow": "configuration",
+                    "category": "configuration",
+                    "priority": 0.4
+                }
+            }),
+            json!({
+                "name": "debugger_get_config",
+                "title": "Show Server Configuration",
+                "description": "Shows the server configuration in effect: timeouts, session limits, workspace roots, adapter paths, Python defaults, logging and redaction patterns. Values come from the file given by --config or DEBUGGER_MCP_CONFIG, with built-in defaults for everything it doesn't set; per-call arguments (e.g. timeoutMs) still override them.\n\nUSEFUL FOR: Understanding why a session was refused (session limit), a path was rejected (workspace roots) or an operation timed out\n\nTIMING: Returns immediately\n\nRETURNS: {\"source\": \"config file path\" | null, \"config\": {\"timeouts\", \"sessions\", \"security\", \"adapters\", \"python\", \"logging\", \"redaction\"}}",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {}
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "immediate",
                     "workflow": "inspection",
-                    "category": "debugging",
-                    "requiresState": ["Stopped"],
-                    "priority": 0.5
+                    "category": "configuration",
+                    "priority": 0.2
                 }
             }),
             json!({
-                "name": "debugger_disconnect",
-                "title": "Disconnect Session",
-                "description": "Terminates a debugging session and cleans up all associated resources. The debugged program will be stopped if still running.\n\nWORKFLOW:\n1. Call this when debugging is complete\n2. Session and all breakpoints are removed\n3. Debugged program is terminated gracefully\n\nTIMING: Returns in 50-200ms (includes cleanup time)\n\nIMPORTANT: Always disconnect when finished to free resources. The session cannot be resumed after disconnection.\n\nRETURNS: {\"status\": \"disconnected\"}\n\nTIP: If the program is still running, it will be terminated. If you want to let the program finish naturally, you can skip calling this tool, but resources will not be cleaned up immediately.\n\nSEE ALSO: debugger://workflows (complete debugging workflows showing disconnect)",
+                "name": "debugger_set_log_level",
+                "title": "Set Log Level",
+                "description": "Changes the server's log verbosity while it runs, globally or for one session, and returns the previous level. Use it to capture a verbose DAP trace around a tricky step and turn it back down afterwards, without restarting the server.\n\nGLOBAL (no sessionId): replaces the level set at startup by --log-level, the config file or RUST_LOG (including any RUST_LOG per-module directives).\n\nPER SESSION (sessionId): raises or lowers logging for one session only: tool calls for the session, its launch and every message from its debug adapter. The session level is dropped when the session is disconnected.\n\nLogs go to the server's stderr, not to the MCP client.\n\nTIMING: Returns immediately\n\nRETURNS: {\"level\", \"previousLevel\", \"sessionId\" | null}",
                 "inputSchema": {
                     "type": "object",
                     "properties": {
+                        "level": {
+                            "type": "string",
+                            "enum": ["trace", "debug", "info", "warn", "error"],
+                            "description": "New log level"
+                        },
                         "sessionId": {
                             "type": "string",
-                            "description": "Session ID from debugger_start"
+                            "description": "Session ID from debugger_start (optional, sets the global level when omitted)"
                         }
                     },
-                    "required": ["sessionId"]
+                    "required": ["level"]
                 },
                 "annotations": {
                     "async": false,
-                    "returnsTiming": "50-200ms",
-                    "workflow": "cleanup",
-                    "category": "session-management",
-                    "destructive": true,
-                    "priority": 0.4
+                    "returnsTiming": "immediate",
+                    "workflow": "inspection",
+                    "category": "configuration",
+                    "priority": 0.2
                 }
             }),
             json!({
-                "name": "debugger_wait_for_stop",
-                "title": "Wait For Program To Stop",
-                "description": "Blocks until the debugger stops (at breakpoint, step, or entry point), or times out. More efficient than polling debugger_session_state.\n\n⭐ EFFICIENT ALTERNATIVE TO POLLING\n==================================\nReplaces old pattern of repeated sleep + state check with single blocking call:\n\n❌ OLD PATTERN (slow, inefficient):\n  debugger_continue()\n  sleep(200ms)  // Arbitrary delay\n  state = debugger_session_state()\n  if state != \"Stopped\":\n    sleep(500ms)  // More waiting\n    state = debugger_session_state()  // Still might be Running\n  // Takes 500-3000ms with multiple polls\n\n✅ NEW PATTERN (fast, efficient):\n  debugger_continue()\n  debugger_wait_for_stop({timeoutMs: 5000})\n  // Returns immediately when stopped (typically <100ms)\n  // No wasted polling cycles!\n\n⭐ TIMING BEHAVIOR\n=================\n- If ALREADY stopped: Returns immediately (<10ms)\n- If running: Blocks until stop event or timeout\n- If program terminated: Returns with state \"Terminated\"\n- If timeout expires: Returns error\n\nTypical return times:\n- Entry point (stopOnEntry): <100ms\n- Breakpoint hit: <100ms  \n- Step completion: <50ms\n\nCOMMON PATTERNS:\n\n1. Wait for entry after start:\n   debugger_start({stopOnEntry: true})\n   debugger_wait_for_stop()  // Immediate return when at entry\n\n2. Wait for breakpoint:\n   debugger_continue()\n   debugger_wait_for_stop()  // Blocks until breakpoint hit\n\n3. Wait for step completion:\n   debugger_step_over()\n   debugger_wait_for_stop()  // Blocks until step completes\n\n4. Loop through multiple stops:\n   for (i = 0; i < 5; i++):\n     debugger_continue()\n     result = debugger_wait_for_stop()\n     // Process each stop...\n\nWORKFLOW:\n1. Call debugger_continue(), debugger_step_*, or debugger_start()\n2. Call this tool to wait for the next stop event\n3. Returns immediately when program stops\n4. Check result.reason to understand why it stopped\n\nRETURNS:\n{\n  \"state\": \"Stopped\",\n  \"threadId\": 1,\n  \"reason\": \"breakpoint\"  // or \"entry\", \"step\", \"pause\", etc.\n}\n\nPERFORMANCE:\n~5x faster than polling approach\nNo wasted CPU cycles\nImmediate notification of state changes\n\nSEE ALSO: debugger_session_state (check current state), debugger_continue (resume execution)",
+                "name": "debugger_get_output",
+                "title": "Get Program Output",
+                "description": "Returns what the program wrote to stdout and stderr, up to the last 1 MiB, oldest first.\n\nENCODING: Output is kept as bytes. By default it is returned as UTF-8 text, with U+FFFD for anything that isn't valid UTF-8 and encodingLossy: true when that happened. base64: true returns the bytes kept instead. Debuggers send output as JSON text, so the bytes kept are the UTF-8 of that text, not the program's raw bytes: anything that wasn't UTF-8 arrives already replaced by U+FFFD, and base64 can't bring it back. Configured redactions apply either way.\n\nTIMING: Returns immediately\n\nRETURNS: {\"sessionId\", \"output\" (or \"outputBase64\"), \"omittedBytes\" (earlier output not returned), \"encodingLossy\"}",
                 "inputSchema": {
                     "type": "object",
                     "properties": {
@@ -650,91 +5792,520 @@ impl ToolsHandler {
                             "type": "string",
                             "description": "Session ID from debugger_start"
                         },
-                        "timeoutMs": {
+                        "base64": {
+                            "type": "boolean",
+                            "description": "Return the bytes kept (the UTF-8 of the text the debugger sent) base64-encoded as outputBase64 instead of text (default: false)"
+                        },
+                        "maxBytes": {
                             "type": "integer",
-                            "default": 5000,
-                            "description": "Maximum time to wait in milliseconds (default: 5000)"
+                            "description": "Return only the last this many bytes (default: all kept)"
                         }
                     },
                     "required": ["sessionId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "immediate",
+                    "workflow": "inspection",
+                    "category": "debugging",
+                    "priority": 0.5
                 }
             }),
             json!({
-                "name": "debugger_list_breakpoints",
-                "title": "List All Breakpoints",
-                "description": "Lists all breakpoints currently set across all source files.\n\nUSEFUL FOR:\n- Verifying which breakpoints are active\n- Checking breakpoint verification status\n- Debugging why a breakpoint might not be hit\n\nTIMING: Returns immediately (<10ms)\n\nRETURNS: Array of breakpoints with id, verified status, line, and sourcePath",
+                "name": "debugger_get_post_mortem",
+                "title": "Get Post-Mortem",
+                "description": "Returns the snapshot taken when a session's program ended, to analyze a crash after the fact. It is there even after debugger_disconnect, or after the idle reaper (sessions.idle_timeout_secs) removed the session, when the debugger and the program are gone.\n\nCONTENTS: The snapshot is taken when the session becomes Terminated or Failed: the exit code (filled in when the debugger reports it, which may be after the termination), runtimeMs from the launch to the end, and the last 50 lines of stdout and stderr. When the program stopped on the exception or panic it died of (Python needs uncaught exception breakpoints, e.g. captureOnException), it adds the exception {exceptionId, description, breakMode}, the crashing threadId, its top 10 frames with source snippets and a library flag, userFrame (the innermost frame that isn't library code) and its locals. A program that exited without hitting any verified breakpoint adds exitDiagnosis (see debugger_session_state).\n\nRETENTION: While the session exists its snapshot is read from it (removed: false), and the call fails until the program ends. Snapshots of removed sessions (removed: true) are kept until read, up to the 32 most recent; once read, they are dropped by the idle reaper an idle timeout later, so they can be read again until then. The id of a session relaunched by watch mode gives its own snapshot.\n\nTIMING: Returns immediately\n\nRETURNS: {\"sessionId\", \"language\", \"program\", \"removed\", \"takenAtMs\" (Unix time), \"error\" (Failed only), \"exitCode\", \"runtimeMs\", \"exception\", \"threadId\", \"stack\": [{\"id\", \"name\", \"path\", \"line\", \"library\", \"source\"}], \"userFrame\", \"locals\", \"output\": [lines], \"exitDiagnosis\"}",
                 "inputSchema": {
                     "type": "object",
                     "properties": {
                         "sessionId": {
                             "type": "string",
-                            "description": "Session ID from debugger_start"
+                            "description": "Session ID from debugger_start; the session may have been disconnected"
                         }
                     },
                     "required": ["sessionId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "immediate",
+                    "workflow": "inspection",
+                    "category": "debugging",
+                    "priority": 0.5
                 }
             }),
             json!({
-                "name": "debugger_step_over",
-                "title": "Step Over (Next Line)",
-                "description": "Executes the current line and stops at the next line. Does NOT step into function calls.\n\nREQUIRES: Program must be stopped (at breakpoint, entry, or previous step)\n\nWORKFLOW:\n1. Ensure program is stopped\n2. Call this tool to execute one line\n3. Use debugger_wait_for_stop to wait for the step to complete\n4. Inspect state with debugger_stack_trace and debugger_evaluate\n\nTIMING: Returns quickly; use debugger_wait_for_stop to detect completion\n\nSEE ALSO: debugger_step_into (to step into functions), debugger_step_out (to step out)",
+                "name": "debugger_resource_usage",
+                "title": "Show Program Resource Usage",
+                "description": "Reports the memory and CPU use of the debugged program over time, e.g. to see it balloon in memory without shelling into its container.\n\nSAMPLING: From the moment the debugger reports the program's pid (its process event), the program's resident memory (VmRSS) and CPU time are read from /proc every 5 seconds while the session lives; the last 120 readings are kept, and the peaks of all of them. Reading costs no debugger request, so it is always on. cpuPercent is CPU time over wall time since the previous reading (100: one core busy).\n\nSTOPPED: While the program is stopped nothing is read: usage.frozen is true and usage.current is the last reading before the stop. Reading resumes when the program runs again; the first reading after that has a null cpuPercent, as CPU time is measured afresh rather than averaged over the stop.\n\nLIMITS: Linux only. A program /proc can't show (it exited, runs under a remote debugger or in another pid namespace) ends sampling; usage.ended says why. Debuggers that don't report a pid leave usage null with a message.\n\nTIMING: Returns immediately\n\nRETURNS: {\"sessionId\", \"intervalMs\", \"usage\": {\"pid\", \"current\", \"peakRssBytes\", \"peakCpuPercent\", \"frozen\", \"ended\"}, \"samples\": [{\"atMs\" (since the session was created), \"rssBytes\", \"cpuMs\", \"cpuPercent\"}]}",
                 "inputSchema": {
                     "type": "object",
                     "properties": {
                         "sessionId": {
                             "type": "string",
                             "description": "Session ID from debugger_start"
-                        },
-                        "threadId": {
-                            "type": "integer",
-                            "description": "Thread ID (optional, uses stopped thread if not specified)"
                         }
                     },
                     "required": ["sessionId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "immediate",
+                    "workflow": "inspection",
+                    "category": "debugging",
+                    "priority": 0.4
                 }
             }),
             json!({
-                "name": "debugger_step_into",
-                "title": "Step Into (Enter Function)",
-                "description": "Steps into function calls on the current line. If no function call, behaves like step_over.\n\nREQUIRES: Program must be stopped\n\nUSEFUL FOR: Debugging function implementations line by line\n\nWORKFLOW: Same as debugger_step_over\n\nSEE ALSO: debugger_step_over (to skip functions), debugger_step_out (to exit function)",
+                "name": "debugger_watch_stop",
+                "title": "Stop Watching Sources",
+                "description": "Stops relaunching a session started with watch: true when its sources change. The session itself keeps running.\n\nTIMING: Returns immediately\n\nRETURNS: {\"sessionId\" (the current session, after any relaunches), \"wasWatching\", \"relaunches\"}",
                 "inputSchema": {
                     "type": "object",
                     "properties": {
                         "sessionId": {
                             "type": "string",
-                            "description": "Session ID from debugger_start"
-                        },
-                        "threadId": {
-                            "type": "integer",
-                            "description": "Thread ID (optional)"
+                            "description": "Session ID from debugger_start (an id replaced by a relaunch works too)"
                         }
                     },
                     "required": ["sessionId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "immediate",
+                    "workflow": "configuration",
+                    "category": "session-management",
+                    "priority": 0.3
                 }
             }),
             json!({
-                "name": "debugger_step_out",
-                "title": "Step Out (Exit Function)",
-                "description": "Continues execution until the current function returns, then stops at the caller.\n\nREQUIRES: Program must be stopped inside a function\n\nUSEFUL FOR: Quickly exiting from deep call stacks\n\nWORKFLOW: Same as debugger_step_over\n\nSEE ALSO: debugger_step_into (to enter function), debugger_step_over (to skip line)",
+                "name": "debugger_webhook_deliveries",
+                "title": "Show Webhook Deliveries",
+                "description": "Lists the events a session POSTed to its webhook (webhookUrl of debugger_start) and whether each arrived. Use it when a CI pipeline missed a stop or crash notification.\n\nEach event is attempted twice at most: a transport error or a non-2xx status is retried once after a second. Delivery failures never affect the session. The last 100 deliveries are kept.\n\nTIMING: Returns immediately\n\nRETURNS: {\"sessionId\", \"webhookUrl\" (null without a webhook), \"deliveries\": [{\"event\", \"timestampMs\", \"delivered\", \"attempts\", \"status\", \"error\"}]}, oldest first",
                 "inputSchema": {
                     "type": "object",
                     "properties": {
                         "sessionId": {
                             "type": "string",
                             "description": "Session ID from debugger_start"
-                        },
-                        "threadId": {
-                            "type": "integer",
-                            "description": "Thread ID (optional)"
                         }
                     },
                     "required": ["sessionId"]
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "immediate",
+                    "workflow": "inspection",
+                    "category": "configuration",
+                    "priority": 0.2
+                }
+            }),
+            json!({
+                "name": "debugger_get_metrics",
+                "title": "Show Debugger Metrics",
+                "description": "Reports how busy and how fast the debuggers are: DAP requests by command with their latencies, stops, breakpoint hits and adapter startup time. Use it to find out why a session feels slow, e.g. which request a debugger takes seconds to answer.\n\nAGGREGATE: always included; every session since the server started, including ended ones.\nSESSION (sessionId): the same for one session, including Node.js child sessions.\n\nLatencies are in milliseconds, measured from sending a request to its response. The percentiles are estimates: the upper bound of the histogram bucket (0.1ms up to 10s) the percentile falls in. failures counts requests the debugger rejected or didn't answer in time. adapterStartup is the time from a session's creation, right after its debugger was started, until its program was launched. stopNotification is the time from a stopped event's arrival until the stop was recorded, with its top frame where one is loaded.\n\nREQUEST QUEUE (sessionId): requests waiting for the debugger's response right now: depth, oldestPendingMs and oldestPendingCommand (a request stuck there explains a slow session), and timedOut, the inspections (variables, evaluate, stackTrace, ...) that failed at the server's timeouts.inspection_ms deadline without affecting other requests. pause and disconnect are sent ahead of queued requests.\n\nTIMING: Returns immediately\n\nRETURNS: {\"aggregate\": metrics, \"session\": metrics (with sessionId), \"requestQueue\": {\"depth\", \"oldestPendingMs\", \"oldestPendingCommand\", \"timedOut\"} (with sessionId), \"sessionId\"}, where metrics is {\"uptimeMs\", \"totalRequests\", \"requests\": {command: {\"count\", \"failures\", \"avgMs\", \"p50Ms\", \"p95Ms\", \"p99Ms\", \"maxMs\"}}, \"stops\", \"breakpointHits\", \"adapterStartup\": latencies, \"stopNotification\": latencies}\n\nSEE ALSO: debugger_set_log_level (to see the slow requests themselves)",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {
+                        "sessionId": {
+                            "type": "string",
+                            "description": "Session ID from debugger_start (optional, only the aggregate when omitted)"
+                        }
+                    }
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "immediate",
+                    "workflow": "inspection",
+                    "category": "configuration",
+                    "priority": 0.2
+                }
+            }),
+            json!({
+                "name": "debugger_info",
+                "title": "Show Server Info",
+                "description": "Describes this server build: its version and commit, the transports it speaks, its session and response limits, and which languages it can debug. Call it first to decide whether a session can work at all, e.g. before starting a Ruby session on a server built for Go, instead of failing at debugger_start.\n\nLANGUAGES: each language's debugger is probed once when the server starts (debuggers installed later need a server restart). found says whether the debugger ran; version is the one it reported (else the configured adapters.<language>.version); modes are the debugger_start modes, with launch, attach and test (Go test, Python pytest) broken out; error says why a debugger wasn't found.\n\nTIMING: Returns immediately once the startup probe has finished (up to 10s after startup)\n\nRETURNS: {\"name\", \"version\", \"gitCommit\" | null, \"transports\": [\"stdio\"], \"limits\": {\"maxSessions\", \"idleTimeoutSecs\", \"maxResponseBytes\"}, \"languages\": [{\"language\", \"adapter\", \"found\", \"version\", \"modes\", \"launch\", \"attach\", \"test\", \"error\"}]}\n\nSEE ALSO: debugger_get_config (full configuration)",
+                "inputSchema": {
+                    "type": "object",
+                    "properties": {}
+                },
+                "annotations": {
+                    "async": false,
+                    "returnsTiming": "immediate",
+                    "workflow": "configuration",
+                    "category": "configuration",
+                    "priority": 0.3
                 }
             }),
         ]
     }
 }
 
+/// A step response with the granularity the step went by, flagged when the
+/// debugger couldn't step by the one asked for
+fn with_granularity(
+    mut response: Value,
+    requested: Option<SteppingGranularity>,
+    effective: SteppingGranularity,
+) -> Value {
+    response["granularity"] = json!(effective);
+    if let Some(requested) = requested.filter(|g| *g != effective) {
+        response["granularityDowngraded"] = json!(true);
+        response["requestedGranularity"] = json!(requested);
+    }
+    response
+}
+
+/// Result of a step over or into
+///
+/// Without step filters the step is only started. With filters, the step is
+/// waited for and any filtered code it stops in is stepped out of; the
+/// response then says where execution stopped and how many steps were taken
+/// automatically.
+async fn filtered_step_json(
+    session: &crate::debug::DebugSession,
+    thread_id: i32,
+    since: u64,
+    returns: StepReturns,
+) -> Result<Value> {
+    let stepping = json!({
+        "status": "stepping",
+        "threadId": thread_id
+    });
+    if returns == StepReturns::None && session.step_filters().await.is_empty() {
+        return Ok(stepping);
+    }
+    let wait = session.wait_for_stop_timeout().await;
+    let Some(stop) = session.wait_for_stop_since(since, wait).await else {
+        return Ok(stepping);
+    };
+    let outcome = session.leave_filtered_code(stop).await?;
+    let mut response = match outcome.state {
+        Some(crate::debug::state::DebugState::Stopped { thread_id, reason }) => json!({
+            "status": "stopped",
+            "threadId": thread_id,
+            "reason": reason
+        }),
+        Some(_) => json!({
+            "status": "terminated",
+            "threadId": thread_id
+        }),
+        None => stepping,
+    };
+    if outcome.skipped > 0 {
+        response["skippedFrames"] = json!(outcome.skipped);
+    }
+    if outcome.limit_reached {
+        response["stepFilterLimitReached"] = json!(true);
+    }
+    if returns != StepReturns::None && response["status"] == "stopped" {
+        let step_out = returns == StepReturns::StepOut;
+        let unavailable = if outcome.skipped > 0 {
+            // Values now in the frame would be those of the last filtered function
+            Some("step filters stepped on out of filtered code".to_string())
+        } else {
+            let stopped_thread = response["threadId"].as_i64().unwrap_or(thread_id as i64) as i32;
+            match session.return_values(stopped_thread).await {
+                Ok(values) if !values.is_empty() => {
+                    let values: Vec<Value> = values
+                        .into_iter()
+                        .map(|mut v| {
+                            v.value = config::redact(&v.value);
+                            json!(v)
+                        })
+                        .collect();
+                    response["returnValue"] = values[0]["value"].clone();
+                    response["returnValues"] = json!(values);
+                    None
+                }
+                Ok(_) => {
+                    Some(return_values::unavailable_reason(&session.language, step_out).to_string())
+                }
+                Err(e) => {
+                    tracing::debug!("Return values unavailable after step: {}", e);
+                    Some(format!("could not read the frame's variables: {}", e))
+                }
+            }
+        };
+        if let Some(reason) = unavailable {
+            response["returnValue"] = Value::Null;
+            response["returnValueUnavailable"] = json!(reason);
+        }
+    }
+    Ok(response)
+}
+
+/// Which return values a step reports once stopped
+#[derive(Debug, Clone, Copy, PartialEq)]
+enum StepReturns {
+    None,
+    StepOver,
+    StepOut,
+}
+
+/// `expression`'s value in session `session_id`, for debugger_compare_sessions
+async fn comparison_value(
+    manager: &SessionManager,
+    session_id: &str,
+    expression: &str,
+    no_side_effects: bool,
+) -> compare::SessionValue {
+    match manager.get_session(session_id).await {
+        Ok(session) => {
+            session
+                .value_for_comparison(expression, no_side_effects)
+                .await
+        }
+        Err(e) => compare::SessionValue::not_found(session_id, e.to_string()),
+    }
+}
+
+/// State name and its details (thread and reason of a stop, error of a failure)
+fn state_json(state: &DebugState) -> (&'static str, Value) {
+    match state.clone() {
+        DebugState::NotStarted => ("NotStarted", json!({})),
+        DebugState::Initializing => ("Initializing", json!({})),
+        DebugState::Initialized => ("Initialized", json!({})),
+        DebugState::Launching => ("Launching", json!({})),
+        DebugState::Running => ("Running", json!({})),
+        DebugState::Stopped { thread_id, reason } => (
+            "Stopped",
+            json!({
+                "threadId": thread_id,
+                "reason": reason
+            }),
+        ),
+        DebugState::Terminated => ("Terminated", json!({})),
+        DebugState::Failed { error } => (
+            "Failed",
+            json!({
+                "error": error
+            }),
+        ),
+    }
+}
+
+/// A group member's state, for the group tools
+///
+/// Also returns when the member stopped, if it is stopped. A member whose
+/// session was disconnected on its own is reported as "Disconnected".
+async fn group_member_json(
+    manager: &SessionManager,
+    member: &GroupMember,
+) -> (Value, Option<std::time::Instant>) {
+    let mut response = json!(member);
+    let Ok(session) = manager.get_session(&member.session_id).await else {
+        response["state"] = json!("Disconnected");
+        return (response, None);
+    };
+    let (state_str, details) = state_json(&session.get_state().await);
+    response["state"] = json!(state_str);
+    if let Value::Object(details) = details {
+        response.as_object_mut().unwrap().extend(details);
+    }
+    (response, session.stopped_at().await)
+}
+
+/// Class and message of the exception behind an exception stop
+async fn exception_json(
+    session: &crate::debug::DebugSession,
+    thread_id: i32,
+    reason: &str,
+) -> Option<Value> {
+    if reason != "exception" {
+        return None;
+    }
+    let info = session.exception_info(thread_id).await?;
+    Some(json!({
+        "class": info.exception_id,
+        "message": info.description.as_deref().map(config::redact),
+        "breakMode": info.break_mode
+    }))
+}
+
+/// Post-mortem snapshot for sessions started with captureOnException: the
+/// current exception stop's, or after termination the last one taken
+async fn exception_capture_json(
+    session: &crate::debug::DebugSession,
+    state: &crate::debug::state::DebugState,
+) -> Option<Value> {
+    let capture = match state {
+        crate::debug::state::DebugState::Stopped { .. } => session.capture_exception().await,
+        crate::debug::state::DebugState::Terminated => {
+            session.get_full_state().await.exception_capture
+        }
+        _ => None,
+    }?;
+    serde_json::to_value(capture).ok()
+}
+
+/// Import status of each breakpoint entry with its file and line, then of
+/// each change watch and of the exception filters, and those of the ones
+/// that failed or weren't verified
+fn import_results(doc: &BreakpointDocument, report: ImportReport) -> (Vec<Value>, Vec<Value>) {
+    let breakpoints = doc
+        .breakpoints
+        .iter()
+        .zip(report.breakpoints)
+        .map(|(entry, status)| (status, json!({"file": entry.file, "line": entry.line})));
+    let watches = doc
+        .watches
+        .iter()
+        .filter_map(|watch| match watch {
+            WatchEntry::Change {
+                expression,
+                file,
+                line,
+                ..
+            } => Some(json!({"watch": expression, "file": file, "line": line})),
+            WatchEntry::Expression(_) => None,
+        })
+        .zip(report.watches)
+        .map(|(entry, status)| (status, entry));
+    let exception_filters = report
+        .exception_filters
+        .map(|status| (status, json!({"exceptionFilters": doc.exception_filters})));
+
+    let mut failed = Vec::new();
+    let results = breakpoints
+        .chain(watches)
+        .chain(exception_filters)
+        .map(|(status, entry)| {
+            let mut result = serde_json::to_value(&status).unwrap_or(Value::Null);
+            if let (Some(result), Some(entry)) = (result.as_object_mut(), entry.as_object()) {
+                result.extend(entry.clone());
+            }
+            if matches!(
+                status,
+                ImportStatus::Failed { .. } | ImportStatus::Unverified
+            ) {
+                failed.push(result.clone());
+            }
+            result
+        })
+        .collect();
+    (results, failed)
+}
+
+/// Tool result form of a tracked breakpoint; conditions only when set
+fn breakpoint_json(bp: &Breakpoint) -> Value {
+    let mut value = json!({
+        "id": bp.id,
+        "verified": bp.verified,
+        "enabled": bp.enabled,
+        "line": bp.line,
+        "sourcePath": bp.source_path
+    });
+    for (key, field) in [
+        ("condition", &bp.condition),
+        ("hitCondition", &bp.hit_condition),
+        ("logMessage", &bp.log_message),
+    ] {
+        if let Some(text) = field {
+            value[key] = json!(text);
+        }
+    }
+    if bp.temporary {
+        value["temporary"] = json!(true);
+    }
+    if let Some(caller) = &bp.caller {
+        value["caller"] = json!(caller.pattern);
+        value["callerDepth"] = json!(caller.depth);
+    }
+    if let Some(verified_line) = bp.verified_line {
+        value["verifiedLine"] = json!(verified_line);
+        value["moveExplanation"] = json!(bp.move_explanation);
+    }
+    value
+}
+
+/// Tool result form of a tracked function breakpoint; optional fields only when set
+fn function_breakpoint_json(bp: &FunctionBreakpoint) -> Value {
+    let mut value = json!({
+        "id": bp.id,
+        "verified": bp.verified,
+        "name": bp.name
+    });
+    for (key, field) in [
+        ("condition", &bp.condition),
+        ("hitCondition", &bp.hit_condition),
+        ("message", &bp.message),
+    ] {
+        if let Some(text) = field {
+            value[key] = json!(text);
+        }
+    }
+    value
+}
+
+/// Tool result form of a tracked instruction breakpoint; optional fields only when set
+fn instruction_breakpoint_json(bp: &InstructionBreakpoint) -> Value {
+    let mut value = json!({
+        "id": bp.id,
+        "verified": bp.verified,
+        "instructionReference": bp.instruction_reference,
+        "stale": bp.stale
+    });
+    if let Some(offset) = bp.offset {
+        value["offset"] = json!(offset);
+    }
+    for (key, field) in [
+        ("condition", &bp.condition),
+        ("hitCondition", &bp.hit_condition),
+        ("message", &bp.message),
+    ] {
+        if let Some(text) = field {
+            value[key] = json!(text);
+        }
+    }
+    value
+}
+
+/// The path `debugger_source_context` may read for a caller-supplied `path`:
+/// one of `frames`' source paths as reported, or else a path that passes
+/// `validate_source_path` (inside the workspace roots, if any)
+fn check_context_path(path: &str, frames: &[crate::dap::types::StackFrame]) -> Result<String> {
+    let reported = frames
+        .iter()
+        .any(|frame| frame.source.as_ref().and_then(|s| s.path.as_deref()) == Some(path));
+    if reported {
+        return Ok(path.to_string());
+    }
+    let canonical = security::validate_source_path(path, None)?;
+    Ok(canonical.to_string_lossy().into_owned())
+}
+
+/// Check that a Python module name is a dotted identifier (`pkg.module`)
+fn validate_module_name(module: &str) -> Result<()> {
+    let valid = module.split('.').all(|part| {
+        !part.is_empty()
+            && !part.starts_with(|c: char| c.is_ascii_digit())
+            && part.chars().all(|c| c.is_alphanumeric() || c == '_')
+    });
+    if valid {
+        Ok(())
+    } else {
+        Err(Error::InvalidRequest(format!(
+            "Invalid Python module name '{}' (expected e.g. 'package.module')",
+            module
+        )))
+    }
+}
+
+/// Pick the single process to attach to, or report all candidates
+fn select_attach_target(query: &str, mut candidates: Vec<ProcessInfo>) -> Result<ProcessInfo> {
+    match candidates.len() {
+        0 => Err(Error::Process(format!(
+            "No running process matches '{}'",
+            query
+        ))),
+        1 => Ok(candidates.remove(0)),
+        _ => Err(Error::AmbiguousProcess {
+            query: query.to_string(),
+            candidates,
+        }),
+    }
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -756,102 +6327,946 @@ mod tests {
         assert_eq!(args.cwd, Some("/working/dir".to_string()));
     }
 
-    #[test]
-    fn test_debugger_start_args_without_cwd() {
-        let json = json!({
-            "language": "python",
-            "program": "test.py",
-            "args": []
-        });
+    #[test]
+    fn test_debugger_start_args_keep_alive() {
+        let json = json!({
+            "language": "go",
+            "program": "main.go",
+            "keepAliveIntervalMs": 30000
+        });
+
+        let args: DebuggerStartArgs = serde_json::from_value(json).unwrap();
+        assert_eq!(args.keep_alive_interval_ms, Some(30000));
+
+        let json = json!({
+            "language": "go",
+            "program": "main.go"
+        });
+        let args: DebuggerStartArgs = serde_json::from_value(json).unwrap();
+        assert!(args.keep_alive_interval_ms.is_none());
+    }
+
+    #[test]
+    fn test_debugger_start_args_stop_poll() {
+        let json = json!({
+            "language": "python",
+            "program": "app.py",
+            "stopPollIntervalMs": 2000
+        });
+
+        let args: DebuggerStartArgs = serde_json::from_value(json).unwrap();
+        assert_eq!(args.stop_poll_interval_ms, Some(2000));
+        assert!(args.keep_alive_interval_ms.is_none());
+    }
+
+    #[test]
+    fn test_debugger_start_args_without_cwd() {
+        let json = json!({
+            "language": "python",
+            "program": "test.py",
+            "args": []
+        });
+
+        let args: DebuggerStartArgs = serde_json::from_value(json).unwrap();
+        assert!(args.cwd.is_none());
+        assert!(args.args.is_empty());
+    }
+
+    #[test]
+    fn test_set_breakpoint_args_deserialization() {
+        let json = json!({
+            "sessionId": "session-123",
+            "sourcePath": "/path/to/file.py",
+            "line": 42
+        });
+
+        let args: SetBreakpointArgs = serde_json::from_value(json).unwrap();
+        assert_eq!(args.session_id, "session-123");
+        assert_eq!(args.source_path, "/path/to/file.py");
+        assert_eq!(args.line, 42);
+        assert_eq!(args.log_message, None);
+
+        let args: SetBreakpointArgs = serde_json::from_value(json!({
+            "sessionId": "session-123",
+            "sourcePath": "/path/to/file.go",
+            "line": 12,
+            "logMessage": "n={n}"
+        }))
+        .unwrap();
+        assert_eq!(args.log_message.as_deref(), Some("n={n}"));
+    }
+
+    #[test]
+    fn test_continue_args_deserialization() {
+        let json = json!({"sessionId": "test-session"});
+        let args: ContinueArgs = serde_json::from_value(json).unwrap();
+        assert_eq!(args.session_id, "test-session");
+    }
+
+    #[test]
+    fn test_stack_trace_args_deserialization() {
+        let json = json!({"sessionId": "trace-session"});
+        let args: StackTraceArgs = serde_json::from_value(json).unwrap();
+        assert_eq!(args.session_id, "trace-session");
+    }
+
+    #[test]
+    fn test_evaluate_args_deserialization() {
+        let json = json!({
+            "sessionId": "eval-session",
+            "expression": "x + y",
+            "frameId": 5
+        });
+
+        let args: EvaluateArgs = serde_json::from_value(json).unwrap();
+        assert_eq!(args.session_id, "eval-session");
+        assert_eq!(args.expression, "x + y");
+        assert_eq!(args.frame_id, Some(5));
+    }
+
+    #[test]
+    fn test_evaluate_args_without_frame_id() {
+        let json = json!({
+            "sessionId": "eval-session",
+            "expression": "x + y"
+        });
+
+        let args: EvaluateArgs = serde_json::from_value(json).unwrap();
+        assert!(args.frame_id.is_none());
+    }
+
+    #[test]
+    fn test_session_state_args_block_for_ms() {
+        let args: SessionStateArgs =
+            serde_json::from_value(json!({"sessionId": "s", "blockForMs": 1500})).unwrap();
+        assert_eq!(args.block_for_ms, Some(1500));
+
+        let args: SessionStateArgs = serde_json::from_value(json!({"sessionId": "s"})).unwrap();
+        assert!(args.block_for_ms.is_none());
+    }
+
+    #[test]
+    fn test_disconnect_args_deserialization() {
+        let json = json!({"sessionId": "disconnect-session"});
+        let args: DisconnectArgs = serde_json::from_value(json).unwrap();
+        assert_eq!(args.session_id, "disconnect-session");
+    }
+
+    #[test]
+    fn test_step_responses_report_granularity() {
+        let stepping = json!({"status": "stepping", "threadId": 1});
+        let response = with_granularity(
+            stepping.clone(),
+            Some(SteppingGranularity::Instruction),
+            SteppingGranularity::Line,
+        );
+        assert_eq!(response["granularity"], "line");
+        assert_eq!(response["granularityDowngraded"], true);
+        assert_eq!(response["requestedGranularity"], "instruction");
+
+        let response = with_granularity(stepping, None, SteppingGranularity::Statement);
+        assert_eq!(response["granularity"], "statement");
+        assert!(response.get("granularityDowngraded").is_none());
+    }
+
+    #[test]
+    fn test_set_breakpoint_description_lines_unindented() {
+        // A line break in the string literal would carry the code's indentation
+        let tools = ToolsHandler::list_tools();
+        let tool = tools
+            .iter()
+            .find(|t| t["name"] == "debugger_set_breakpoint")
+            .unwrap();
+        let description = tool["description"].as_str().unwrap();
+        assert!(description.contains("\n- verifiedLine, moveExplanation:"));
+        assert!(description.lines().all(|line| !line.starts_with(' ')));
+    }
+
+    #[test]
+    fn test_list_tools() {
+        let tools = ToolsHandler::list_tools();
+        assert_eq!(tools.len(), 69);
+
+        // Verify tool names
+        let tool_names: Vec<&str> = tools.iter().filter_map(|t| t["name"].as_str()).collect();
+
+        // Original tools
+        assert!(tool_names.contains(&"debugger_start"));
+        assert!(tool_names.contains(&"debugger_session_state"));
+        assert!(tool_names.contains(&"debugger_set_breakpoint"));
+        assert!(tool_names.contains(&"debugger_continue"));
+        assert!(tool_names.contains(&"debugger_stack_trace"));
+        assert!(tool_names.contains(&"debugger_evaluate"));
+        assert!(tool_names.contains(&"debugger_disconnect"));
+
+        // New tools
+        assert!(tool_names.contains(&"debugger_wait_for_stop"));
+        assert!(tool_names.contains(&"debugger_wait_for_breakpoint"));
+        assert!(tool_names.contains(&"debugger_list_breakpoints"));
+        assert!(tool_names.contains(&"debugger_step_over"));
+        assert!(tool_names.contains(&"debugger_step_into"));
+        assert!(tool_names.contains(&"debugger_step_out"));
+        assert!(tool_names.contains(&"debugger_enable_breakpoint"));
+        assert!(tool_names.contains(&"debugger_disable_breakpoint"));
+        assert!(tool_names.contains(&"debugger_list_threads"));
+        assert!(tool_names.contains(&"debugger_list_goroutines"));
+        assert!(tool_names.contains(&"debugger_source_context"));
+        assert!(tool_names.contains(&"debugger_inline_values"));
+        assert!(tool_names.contains(&"debugger_last_hit_breakpoints"));
+        assert!(tool_names.contains(&"debugger_export_breakpoints"));
+        assert!(tool_names.contains(&"debugger_start_from_launch_json"));
+        assert!(tool_names.contains(&"debugger_import_breakpoints"));
+        assert!(tool_names.contains(&"debugger_get_config"));
+        assert!(tool_names.contains(&"debugger_analyze_hang"));
+        assert!(tool_names.contains(&"debugger_set_instruction_breakpoint"));
+        assert!(tool_names.contains(&"debugger_list_async_tasks"));
+        assert!(tool_names.contains(&"debugger_export_session"));
+        assert!(tool_names.contains(&"debugger_set_exception_breakpoints"));
+        assert!(tool_names.contains(&"debugger_step_out_of_file"));
+        assert!(tool_names.contains(&"debugger_step_to_line"));
+        assert!(tool_names.contains(&"debugger_repl_open"));
+        assert!(tool_names.contains(&"debugger_repl_eval"));
+        assert!(tool_names.contains(&"debugger_repl_history"));
+        assert!(tool_names.contains(&"debugger_is_stopped"));
+        assert!(tool_names.contains(&"debugger_events"));
+        assert!(tool_names.contains(&"debugger_configure"));
+        assert!(tool_names.contains(&"debugger_set_function_breakpoint"));
+        assert!(tool_names.contains(&"debugger_get_capabilities"));
+        assert!(tool_names.contains(&"debugger_disassemble"));
+        assert!(tool_names.contains(&"debugger_disassemble_next"));
+        assert!(tool_names.contains(&"debugger_disassemble_prev"));
+        assert!(tool_names.contains(&"debugger_webhook_deliveries"));
+        assert!(tool_names.contains(&"debugger_get_output"));
+        assert!(tool_names.contains(&"debugger_get_post_mortem"));
+        assert!(tool_names.contains(&"debugger_resource_usage"));
+        assert!(tool_names.contains(&"debugger_watch_stop"));
+        assert!(tool_names.contains(&"debugger_entry_point"));
+        assert!(tool_names.contains(&"debugger_find_frame"));
+        assert!(tool_names.contains(&"debugger_watch_change"));
+    }
+
+    #[test]
+    fn test_configure_schema_lists_every_setting() {
+        use crate::debug::settings::{LIVE_SETTINGS, RESTART_SETTINGS};
+
+        let tools = ToolsHandler::list_tools();
+        let configure = tools
+            .iter()
+            .find(|t| t["name"] == "debugger_configure")
+            .unwrap();
+        let mut documented: Vec<&str> = configure["inputSchema"]["properties"]["settings"]
+            ["properties"]
+            .as_object()
+            .unwrap()
+            .keys()
+            .map(String::as_str)
+            .collect();
+        let mut valid: Vec<&str> = LIVE_SETTINGS
+            .iter()
+            .chain(RESTART_SETTINGS)
+            .copied()
+            .collect();
+        documented.sort_unstable();
+        valid.sort_unstable();
+        assert_eq!(documented, valid);
+
+        let args: ConfigureArgs = serde_json::from_value(json!({"sessionId": "s"})).unwrap();
+        assert!(args.settings.is_empty());
+    }
+
+    #[test]
+    fn test_set_instruction_breakpoint_args() {
+        let args: SetInstructionBreakpointArgs = serde_json::from_value(json!({
+            "sessionId": "s",
+            "instructionReference": "0x4a1f20",
+            "offset": -4,
+            "hitCondition": ">= 3"
+        }))
+        .unwrap();
+        assert_eq!(args.offset, Some(-4));
+        assert_eq!(args.hit_condition.as_deref(), Some(">= 3"));
+        assert!(args.condition.is_none());
+        assert!(!args.remove);
+    }
+
+    #[tokio::test]
+    async fn test_get_config_reports_defaults() {
+        let handler = ToolsHandler::new(Arc::new(RwLock::new(SessionManager::new())));
+        let response = handler
+            .handle_tool("debugger_get_config", json!({}))
+            .await
+            .unwrap();
+
+        assert!(response["source"].is_null());
+        assert_eq!(response["config"]["timeouts"]["launch_ms"], 5000);
+        assert!(response["config"]["sessions"]["max_sessions"].is_null());
+    }
+
+    #[tokio::test]
+    async fn test_set_log_level_validation() {
+        let handler = ToolsHandler::new(Arc::new(RwLock::new(SessionManager::new())));
+
+        let result = handler
+            .handle_tool(
+                "debugger_set_log_level",
+                json!({"level": "debug", "sessionId": "missing"}),
+            )
+            .await;
+        assert!(matches!(result, Err(Error::SessionNotFound(_))));
+
+        let result = handler
+            .handle_tool("debugger_set_log_level", json!({"level": "loud"}))
+            .await;
+        assert!(matches!(result, Err(Error::InvalidRequest(_))));
+    }
+
+    #[tokio::test]
+    async fn test_get_metrics() {
+        let handler = ToolsHandler::new(Arc::new(RwLock::new(SessionManager::new())));
+
+        let result = handler
+            .handle_tool("debugger_get_metrics", json!({}))
+            .await
+            .unwrap();
+        assert!(result["aggregate"]["requests"].is_object());
+        assert!(result.get("session").is_none());
+
+        let result = handler
+            .handle_tool("debugger_get_metrics", json!({"sessionId": "missing"}))
+            .await;
+        assert!(matches!(result, Err(Error::SessionNotFound(_))));
+    }
+
+    #[tokio::test]
+    async fn test_evaluate_all_threads_validation() {
+        let handler = ToolsHandler::new(Arc::new(RwLock::new(SessionManager::new())));
+
+        let result = handler
+            .handle_tool(
+                "debugger_evaluate_all_threads",
+                json!({"sessionId": "s", "expression": "x", "maxThreads": 0}),
+            )
+            .await;
+        assert!(matches!(result, Err(Error::InvalidRequest(_))));
+        let result = handler
+            .handle_tool(
+                "debugger_evaluate_all_threads",
+                json!({"sessionId": "missing", "expression": "x"}),
+            )
+            .await;
+        assert!(matches!(result, Err(Error::SessionNotFound(_))));
+    }
+
+    #[tokio::test]
+    async fn test_compare_sessions_reports_each_side() {
+        let handler = ToolsHandler::new(Arc::new(RwLock::new(SessionManager::new())));
+
+        for ids in [json!(["a"]), json!(["a", "a"]), json!(["a", "b", "c"])] {
+            let result = handler
+                .handle_tool(
+                    "debugger_compare_sessions",
+                    json!({"sessionIds": ids, "expression": "x"}),
+                )
+                .await;
+            assert!(matches!(result, Err(Error::InvalidRequest(_))));
+        }
+
+        let result = handler
+            .handle_tool(
+                "debugger_compare_sessions",
+                json!({"sessionIds": ["passing", "failing"], "expression": "x"}),
+            )
+            .await
+            .unwrap();
+        assert_eq!(result["equal"], Value::Null);
+        for (i, id) in ["passing", "failing"].iter().enumerate() {
+            assert_eq!(result["sessions"][i]["sessionId"], *id);
+            assert_eq!(result["sessions"][i]["status"], "notFound");
+        }
+    }
+
+    /// Requests a fake group member's adapter received
+    type Received = Arc<tokio::sync::Mutex<Vec<String>>>;
+
+    /// A session stopped at a breakpoint, on a fake adapter that records
+    /// the requests it gets and answers `continue` as `continues` says
+    async fn stopped_member(continues: bool) -> (crate::debug::DebugSession, Received) {
+        use crate::dap::client::DapClient;
+        use crate::dap::transport::DapTransport;
+        use crate::dap::types::{Message, Response};
+
+        let listener = tokio::net::TcpListener::bind("127.0.0.1:0").await.unwrap();
+        let addr = listener.local_addr().unwrap();
+        let received: Received = Arc::default();
+        let recorded = received.clone();
+        tokio::spawn(async move {
+            let (stream, _) = listener.accept().await.unwrap();
+            let mut transport = DapTransport::new_socket(stream);
+            while let Ok(Message::Request(req)) = transport.read_message().await {
+                recorded.lock().await.push(req.command.clone());
+                let success = match req.command.as_str() {
+                    "continue" => continues,
+                    "disconnect" => true,
+                    _ => false,
+                };
+                let response = Message::Response(Response {
+                    seq: req.seq + 1000,
+                    request_seq: req.seq,
+                    command: req.command,
+                    success,
+                    message: (!success).then(|| "refused".to_string()),
+                    body: Some(json!({"allThreadsContinued": true})),
+                });
+                if transport.write_message(&response).await.is_err() {
+                    break;
+                }
+            }
+        });
+        let socket = tokio::net::TcpStream::connect(addr).await.unwrap();
+        let client = DapClient::from_socket(socket).await.unwrap();
+        let session =
+            crate::debug::DebugSession::new("go".to_string(), "main.go".to_string(), client)
+                .await
+                .unwrap();
+        {
+            let mut state = session.state.write().await;
+            state.add_thread(1);
+            state.apply_stopped(1, "breakpoint".to_string(), true);
+        }
+        (session, received)
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_dereference_example_is_a_real_response() {
+        use crate::dap::client::DapClient;
+        use crate::dap::transport::DapTransport;
+        use crate::dap::types::{Message, Response};
+
+        // Answers as Delve does stopped in Multiply of the multifile fixture
+        let listener = tokio::net::TcpListener::bind("127.0.0.1:0").await.unwrap();
+        let addr = listener.local_addr().unwrap();
+        tokio::spawn(async move {
+            let (stream, _) = listener.accept().await.unwrap();
+            stream.set_nodelay(true).unwrap();
+            let mut transport = DapTransport::new_socket(stream);
+            while let Ok(Message::Request(req)) = transport.read_message().await {
+                let args = req.arguments.clone().unwrap_or_default();
+                let body = match (req.command.as_str(), args["expression"].as_str()) {
+                    ("stackTrace", _) => json!({"stackFrames": [
+                        {"id": 1000, "name": "main.(*Calculator).Multiply", "line": 11, "column": 0,
+                         "source": {"path": "/w/multifile/types.go"}}
+                    ], "totalFrames": 2}),
+                    ("evaluate", Some("c")) => json!({
+                        "result": "(*main.Calculator)(0xc000014070)",
+                        "type": "*main.Calculator",
+                        "variablesReference": 3
+                    }),
+                    ("evaluate", Some("*(c)")) => json!({
+                        "result": "main.Calculator {Name: \"TestCalc\", Version: \"1.0\"}",
+                        "type": "main.Calculator",
+                        "variablesReference": 4
+                    }),
+                    ("variables", _) if args["variablesReference"] == 4 => json!({"variables": [
+                        {"name": "Name", "value": "\"TestCalc\"", "type": "string", "variablesReference": 0},
+                        {"name": "Version", "value": "\"1.0\"", "type": "string", "variablesReference": 0}
+                    ]}),
+                    _ => json!({}),
+                };
+                let response = Message::Response(Response {
+                    seq: req.seq + 1000,
+                    request_seq: req.seq,
+                    command: req.command,
+                    success: true,
+                    message: None,
+                    body: Some(body),
+                });
+                if transport.write_message(&response).await.is_err() {
+                    break;
+                }
+            }
+        });
+        let socket = tokio::net::TcpStream::connect(addr).await.unwrap();
+        let client = DapClient::from_socket(socket).await.unwrap();
+        let session =
+            crate::debug::DebugSession::new("go".to_string(), "types.go".to_string(), client)
+                .await
+                .unwrap();
+        {
+            let mut state = session.state.write().await;
+            state.add_thread(1);
+            state.apply_stopped(1, "breakpoint".to_string(), true);
+        }
+        let manager = Arc::new(RwLock::new(SessionManager::new()));
+        let handler = ToolsHandler::new(Arc::clone(&manager));
+        let session_id = manager.read().await.insert_session(session).await.unwrap();
+
+        let response = handler
+            .handle_tool(
+                "debugger_dereference",
+                json!({"sessionId": session_id, "expression": "c"}),
+            )
+            .await
+            .unwrap();
+        let example: Value = serde_json::from_str(DEREFERENCE_EXAMPLE).unwrap();
+        assert_eq!(response, example);
+
+        let tools = ToolsHandler::list_tools();
+        let tool = tools
+            .iter()
+            .find(|tool| tool["name"] == "debugger_dereference")
+            .unwrap();
+        assert!(tool["description"]
+            .as_str()
+            .unwrap()
+            .contains(DEREFERENCE_EXAMPLE));
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_read_only_session_refuses_mutating_tools() {
+        let manager = Arc::new(RwLock::new(SessionManager::new()));
+        let handler = ToolsHandler::new(Arc::clone(&manager));
+        let (session, received) = stopped_member(true).await;
+        session.make_read_only().await;
+        let session_id = manager.read().await.insert_session(session).await.unwrap();
+        let dir = tempfile::tempdir().unwrap();
+        let source = dir.path().join("main.go");
+        std::fs::write(&source, "package main").unwrap();
+
+        let state = handler
+            .handle_tool("debugger_session_state", json!({"sessionId": session_id}))
+            .await
+            .unwrap();
+        assert_eq!(state["readOnly"], true, "{}", state);
+
+        for (tool, arguments) in [
+            ("debugger_repl_open", json!({"sessionId": session_id})),
+            (
+                "debugger_watch_change",
+                json!({"sessionId": session_id, "expression": "total", "sourcePath": source, "line": 1}),
+            ),
+        ] {
+            let err = handler.handle_tool(tool, arguments).await.unwrap_err();
+            assert!(
+                matches!(err, Error::ReadOnlySession(_)),
+                "{}: {}",
+                tool,
+                err
+            );
+            assert_eq!(err.error_code(), -32015);
+        }
+
+        // Continuing stays allowed; nothing refused reached the debugger
+        handler
+            .handle_tool("debugger_continue", json!({"sessionId": session_id}))
+            .await
+            .unwrap();
+        assert_eq!(*received.lock().await, vec!["continue"]);
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_group_tools_reach_every_member() {
+        let manager = Arc::new(RwLock::new(SessionManager::new()));
+        let handler = ToolsHandler::new(Arc::clone(&manager));
+
+        // The client refuses to continue; the worker is gone before the
+        // group continues
+        let mut members = Vec::new();
+        let mut received = Vec::new();
+        for (name, continues) in [("server", true), ("client", false), ("worker", true)] {
+            let (session, requests) = stopped_member(continues).await;
+            let session_id = manager.read().await.insert_session(session).await.unwrap();
+            members.push(GroupMember {
+                name: name.to_string(),
+                session_id,
+            });
+            received.push(requests);
+        }
+        let worker = members[2].session_id.clone();
+        let group_id = manager
+            .read()
+            .await
+            .create_group(members.clone())
+            .await
+            .unwrap();
+        manager.read().await.remove_session(&worker).await.unwrap();
+
+        let result = handler
+            .handle_tool("debugger_group_continue", json!({"groupId": group_id}))
+            .await
+            .unwrap();
+        let statuses: Vec<&str> = result["members"]
+            .as_array()
+            .unwrap()
+            .iter()
+            .map(|m| m["status"].as_str().unwrap())
+            .collect();
+        assert_eq!(statuses, vec!["continued", "failed", "disconnected"]);
+        assert!(result["members"][1]["error"]
+            .as_str()
+            .unwrap()
+            .contains("refused"));
+        for requests in &received[..2] {
+            assert!(requests.lock().await.contains(&"continue".to_string()));
+        }
+        assert!(!received[2].lock().await.contains(&"continue".to_string()));
+
+        let state = handler
+            .handle_tool("debugger_group_state", json!({"groupId": group_id}))
+            .await
+            .unwrap();
+        let states: Vec<(&str, &str)> = state["members"]
+            .as_array()
+            .unwrap()
+            .iter()
+            .map(|m| (m["name"].as_str().unwrap(), m["state"].as_str().unwrap()))
+            .collect();
+        assert_eq!(
+            states,
+            vec![
+                ("server", "Running"),
+                ("client", "Stopped"),
+                ("worker", "Disconnected")
+            ]
+        );
+        assert_eq!(state["firstStopped"], "client");
+
+        let result = handler
+            .handle_tool("debugger_group_disconnect", json!({"groupId": group_id}))
+            .await
+            .unwrap();
+        let statuses: Vec<&str> = result["members"]
+            .as_array()
+            .unwrap()
+            .iter()
+            .map(|m| m["status"].as_str().unwrap())
+            .collect();
+        assert_eq!(
+            statuses,
+            vec!["disconnected", "disconnected", "alreadyDisconnected"]
+        );
+        for requests in &received[..2] {
+            assert!(requests.lock().await.contains(&"disconnect".to_string()));
+        }
+        for member in &members {
+            assert!(manager
+                .read()
+                .await
+                .get_session(&member.session_id)
+                .await
+                .is_err());
+        }
+        assert!(handler
+            .handle_tool("debugger_group_state", json!({"groupId": group_id}))
+            .await
+            .is_err());
+    }
+
+    #[tokio::test]
+    async fn test_start_group_reports_the_failed_member() {
+        let handler = ToolsHandler::new(Arc::new(RwLock::new(SessionManager::new())));
+        let err = handler
+            .handle_tool(
+                "debugger_start_group",
+                json!({"configs": [
+                    {"name": "server", "language": "cobol", "program": "/tmp/server.cbl"},
+                    {"name": "client", "language": "cobol", "program": "/tmp/client.cbl"}
+                ]}),
+            )
+            .await
+            .unwrap_err();
+        assert!(
+            err.to_string()
+                .contains("Group member 'server' failed to start (0 started members"),
+            "{}",
+            err
+        );
+        assert!(handler
+            .session_manager
+            .read()
+            .await
+            .list_sessions()
+            .await
+            .is_empty());
+    }
+
+    #[test]
+    fn test_launch_json_configurations_are_start_arguments() {
+        let fixtures =
+            std::path::Path::new(env!("CARGO_MANIFEST_DIR")).join("tests/fixtures/launch_json");
+        for (language, name, overrides) in [
+            ("go", "Launch fizzbuzz", json!({})),
+            ("go", "Debug CI binary", json!({})),
+            ("go", "Attach to server", json!({})),
+            ("python", "fizzbuzz", json!({})),
+            ("python", "Module", json!({"preLaunchTask": null})),
+            ("ruby", "fizzbuzz", json!({})),
+        ] {
+            let path = fixtures.join(language).join("launch.json");
+            let document = launch_json::parse(&std::fs::read_to_string(&path).unwrap()).unwrap();
+            let mut configuration = launch_json::configuration(&document, name).unwrap();
+            let variables = launch_json::apply_overrides(
+                &mut configuration,
+                overrides.as_object().unwrap(),
+                launch_json::Variables::for_file(&path),
+            )
+            .unwrap();
+            let imported = launch_json::translate(&configuration, &variables).unwrap();
+            let args: DebuggerStartArgs = serde_json::from_value(Value::Object(imported.start))
+                .unwrap_or_else(|e| panic!("{}: {}", name, e));
+            assert_eq!(args.language, language);
+
+            match (language, name) {
+                ("go", "Debug CI binary") => {
+                    assert_eq!(args.mode.as_deref(), Some("exec"));
+                    assert_eq!(args.go_options.substitute_path[0].to, "/build/src");
+                }
+                ("go", "Attach to server") => assert_eq!(args.process_id, Some(4242)),
+                ("python", "fizzbuzz") => {
+                    assert_eq!(args.just_my_code, Some(false));
+                    assert_eq!(args.env["FIZZ"], "3");
+                    assert!(args.stop_on_entry);
+                }
+                ("python", "Module") => assert_eq!(args.module.as_deref(), Some("http.server")),
+                ("ruby", _) => {
+                    assert!(args.ruby_options.bundler);
+                    assert_eq!(args.args, vec!["15"]);
+                }
+                _ => assert!(args.program.ends_with("launch_json/go/fizzbuzz.go")),
+            }
+        }
+    }
+
+    #[tokio::test]
+    async fn test_start_from_launch_json() {
+        let handler = ToolsHandler::new(Arc::new(RwLock::new(SessionManager::new())));
+        let dir = tempfile::tempdir().unwrap();
+        let vscode = dir.path().join(".vscode");
+        std::fs::create_dir(&vscode).unwrap();
+        let path = vscode.join("launch.json");
+        std::fs::copy(
+            std::path::Path::new(env!("CARGO_MANIFEST_DIR"))
+                .join("tests/fixtures/launch_json/python/launch.json"),
+            &path,
+        )
+        .unwrap();
+
+        let result = handler
+            .handle_tool(
+                "debugger_start_from_launch_json",
+                json!({"path": path, "configurationName": "Module"}),
+            )
+            .await;
+        let err = result.unwrap_err().to_string();
+        assert!(err.contains("attribute 'preLaunchTask'"), "{}", err);
+
+        // Translated with the workspace folder holding .vscode, then checked
+        // by debugger_start: the program isn't there
+        let result = handler
+            .handle_tool(
+                "debugger_start_from_launch_json",
+                json!({
+                    "path": path,
+                    "configurationName": "fizzbuzz",
+                    "overrides": {"python": null}
+                }),
+            )
+            .await;
+        let err = result.unwrap_err().to_string();
+        let program = dir.path().canonicalize().unwrap().join("fizzbuzz.py");
+        assert!(err.contains(&program.display().to_string()), "{}", err);
+    }
+
+    #[tokio::test]
+    async fn test_save_and_load_config() {
+        let handler = ToolsHandler::new(Arc::new(RwLock::new(SessionManager::new())));
+        let dir = tempfile::tempdir().unwrap();
+        let program = dir.path().join("gone.py");
+
+        let result = handler
+            .handle_tool("debugger_save_config", json!({"name": "repro"}))
+            .await;
+        assert!(matches!(result, Err(Error::InvalidRequest(_))));
+
+        let saved = handler
+            .handle_tool(
+                "debugger_save_config",
+                json!({
+                    "name": "repro",
+                    "start": {"language": "python", "program": program, "args": ["--fast"]},
+                    "breakpoints": [{"file": program, "line": 3}],
+                    "watches": ["total"]
+                }),
+            )
+            .await
+            .unwrap();
+        assert_eq!(saved["replaced"], false);
+        assert_eq!(saved["config"]["breakpoints"]["language"], "python");
+        assert_eq!(saved["config"]["breakpoints"]["watches"], json!(["total"]));
 
-        let args: DebuggerStartArgs = serde_json::from_value(json).unwrap();
-        assert!(args.cwd.is_none());
-        assert!(args.args.is_empty());
+        let result = handler
+            .handle_tool("debugger_load_config", json!({"name": "other"}))
+            .await;
+        let err = result.unwrap_err().to_string();
+        assert!(err.contains("saved: repro"), "{}", err);
+
+        // Validated again on load: the program no longer exists
+        let result = handler
+            .handle_tool("debugger_load_config", json!({"name": "repro"}))
+            .await;
+        assert!(result.is_err());
+        assert!(handler
+            .session_manager
+            .read()
+            .await
+            .list_sessions()
+            .await
+            .is_empty());
     }
 
-    #[test]
-    fn test_set_breakpoint_args_deserialization() {
-        let json = json!({
-            "sessionId": "session-123",
-            "sourcePath": "/path/to/file.py",
-            "line": 42
-        });
+    #[tokio::test]
+    async fn test_info_reports_languages() {
+        let handler = ToolsHandler::new(Arc::new(RwLock::new(SessionManager::new())));
 
-        let args: SetBreakpointArgs = serde_json::from_value(json).unwrap();
-        assert_eq!(args.session_id, "session-123");
-        assert_eq!(args.source_path, "/path/to/file.py");
-        assert_eq!(args.line, 42);
+        let result = handler
+            .handle_tool("debugger_info", json!({}))
+            .await
+            .unwrap();
+        assert_eq!(result["version"], env!("CARGO_PKG_VERSION"));
+        assert_eq!(result["transports"], json!(["stdio"]));
+        assert_eq!(result["limits"]["maxResponseBytes"], 256 * 1024);
+        let languages: Vec<&str> = result["languages"]
+            .as_array()
+            .unwrap()
+            .iter()
+            .map(|l| l["language"].as_str().unwrap())
+            .collect();
+        assert_eq!(
+            languages,
+            ["go", "python", "ruby", "nodejs", "rust", "java"]
+        );
+        assert_eq!(result["languages"][1]["modes"][2], "pytest");
     }
 
     #[test]
-    fn test_continue_args_deserialization() {
-        let json = json!({"sessionId": "test-session"});
-        let args: ContinueArgs = serde_json::from_value(json).unwrap();
-        assert_eq!(args.session_id, "test-session");
+    fn test_thread_id_args() {
+        let args: StackTraceArgs =
+            serde_json::from_value(json!({"sessionId": "s", "threadId": 2})).unwrap();
+        assert_eq!(args.thread_id, Some(2));
+
+        let args: ContinueArgs = serde_json::from_value(json!({"sessionId": "s"})).unwrap();
+        assert!(args.thread_id.is_none());
+
+        let args: EvaluateArgs =
+            serde_json::from_value(json!({"sessionId": "s", "expression": "x", "threadId": 3}))
+                .unwrap();
+        assert_eq!(args.thread_id, Some(3));
+        assert!(args.frame_id.is_none());
     }
 
     #[test]
-    fn test_stack_trace_args_deserialization() {
-        let json = json!({"sessionId": "trace-session"});
-        let args: StackTraceArgs = serde_json::from_value(json).unwrap();
-        assert_eq!(args.session_id, "trace-session");
+    fn test_toggle_breakpoint_args() {
+        let json = json!({"sessionId": "s1", "breakpointId": 3});
+        let args: ToggleBreakpointArgs = serde_json::from_value(json).unwrap();
+        assert_eq!(args.session_id, "s1");
+        assert_eq!(args.breakpoint_id, 3);
     }
 
-    #[test]
-    fn test_evaluate_args_deserialization() {
-        let json = json!({
-            "sessionId": "eval-session",
-            "expression": "x + y",
-            "frameId": 5
-        });
+    #[tokio::test]
+    async fn test_disable_breakpoint_session_not_found() {
+        let manager = Arc::new(RwLock::new(SessionManager::new()));
+        let handler = ToolsHandler::new(manager);
 
-        let args: EvaluateArgs = serde_json::from_value(json).unwrap();
-        assert_eq!(args.session_id, "eval-session");
-        assert_eq!(args.expression, "x + y");
-        assert_eq!(args.frame_id, Some(5));
+        let result = handler
+            .handle_tool(
+                "debugger_disable_breakpoint",
+                json!({"sessionId": "missing", "breakpointId": 1}),
+            )
+            .await;
+        assert!(matches!(result, Err(Error::SessionNotFound(_))));
     }
 
     #[test]
-    fn test_evaluate_args_without_frame_id() {
-        let json = json!({
-            "sessionId": "eval-session",
-            "expression": "x + y"
-        });
-
-        let args: EvaluateArgs = serde_json::from_value(json).unwrap();
-        assert!(args.frame_id.is_none());
+    fn test_source_context_args_defaults() {
+        let args: SourceContextArgs =
+            serde_json::from_value(json!({"sessionId": "s", "frameId": 7})).unwrap();
+        assert_eq!(args.frame_id, Some(7));
+        assert!(args.path.is_none());
+        assert_eq!(args.context_lines, 5);
     }
 
     #[test]
-    fn test_disconnect_args_deserialization() {
-        let json = json!({"sessionId": "disconnect-session"});
-        let args: DisconnectArgs = serde_json::from_value(json).unwrap();
-        assert_eq!(args.session_id, "disconnect-session");
+    fn test_source_context_path_checked_unless_reported() {
+        let frame = crate::dap::types::StackFrame {
+            id: 1,
+            name: "main".to_string(),
+            source: Some(Source {
+                name: None,
+                path: Some("/build/pkg/mod/x@v1/x.go".to_string()),
+                source_reference: None,
+                checksums: None,
+            }),
+            line: 3,
+            column: 1,
+            end_line: None,
+            end_column: None,
+            instruction_pointer_reference: None,
+        };
+        let frames = [frame];
+        assert_eq!(
+            check_context_path("/build/pkg/mod/x@v1/x.go", &frames).unwrap(),
+            "/build/pkg/mod/x@v1/x.go"
+        );
+        assert!(check_context_path("/nonexistent/secret.txt", &frames).is_err());
+        let err = check_context_path("/w/../../etc/passwd", &frames).unwrap_err();
+        assert!(err.to_string().contains("'..'"), "{}", err);
     }
 
-    #[test]
-    fn test_list_tools() {
-        let tools = ToolsHandler::list_tools();
-        assert_eq!(tools.len(), 12); // Updated from 7 to 12
+    #[tokio::test]
+    async fn test_source_context_session_not_found() {
+        let manager = Arc::new(RwLock::new(SessionManager::new()));
+        let handler = ToolsHandler::new(manager);
 
-        // Verify tool names
-        let tool_names: Vec<&str> = tools.iter().filter_map(|t| t["name"].as_str()).collect();
+        let result = handler
+            .handle_tool(
+                "debugger_source_context",
+                json!({"sessionId": "missing", "path": "/tmp/main.go"}),
+            )
+            .await;
+        assert!(matches!(result, Err(Error::SessionNotFound(_))));
+    }
 
-        // Original tools
-        assert!(tool_names.contains(&"debugger_start"));
-        assert!(tool_names.contains(&"debugger_session_state"));
-        assert!(tool_names.contains(&"debugger_set_breakpoint"));
-        assert!(tool_names.contains(&"debugger_continue"));
-        assert!(tool_names.contains(&"debugger_stack_trace"));
-        assert!(tool_names.contains(&"debugger_evaluate"));
-        assert!(tool_names.contains(&"debugger_disconnect"));
+    #[tokio::test]
+    async fn test_inline_values_requires_frame_id() {
+        let manager = Arc::new(RwLock::new(SessionManager::new()));
+        let handler = ToolsHandler::new(manager);
 
-        // New tools
-        assert!(tool_names.contains(&"debugger_wait_for_stop"));
-        assert!(tool_names.contains(&"debugger_list_breakpoints"));
-        assert!(tool_names.contains(&"debugger_step_over"));
-        assert!(tool_names.contains(&"debugger_step_into"));
-        assert!(tool_names.contains(&"debugger_step_out"));
+        let result = handler
+            .handle_tool("debugger_inline_values", json!({"sessionId": "s"}))
+            .await;
+        assert!(matches!(result, Err(Error::Json(_))));
+
+        let result = handler
+            .handle_tool(
+                "debugger_inline_values",
+                json!({"sessionId": "missing", "frameId": 1}),
+            )
+            .await;
+        assert!(matches!(result, Err(Error::SessionNotFound(_))));
+    }
+
+    #[test]
+    fn test_import_breakpoints_args() {
+        let args: ImportBreakpointsArgs = serde_json::from_value(json!({
+            "sessionId": "s",
+            "document": {
+                "version": 1,
+                "language": "python",
+                "breakpoints": [{"file": "/w/app.py", "line": 3, "enabled": false}]
+            }
+        }))
+        .unwrap();
+        assert_eq!(args.document.language, "python");
+        assert!(!args.document.breakpoints[0].enabled);
     }
 
     #[test]
@@ -916,14 +7331,327 @@ mod tests {
         assert!(result.is_err());
     }
 
+    #[tokio::test]
+    async fn test_debugger_start_missing_program() {
+        let manager = Arc::new(RwLock::new(SessionManager::new()));
+        let handler = ToolsHandler::new(manager);
+
+        let result = handler
+            .handle_tool("debugger_start", json!({ "language": "python" }))
+            .await;
+        match result {
+            Err(Error::InvalidRequest(msg)) => assert!(msg.contains("program is required")),
+            other => panic!("Expected InvalidRequest, got {:?}", other),
+        }
+    }
+
     #[test]
-    fn test_debugger_start_missing_program() {
+    fn test_debugger_start_args_ruby_options() {
         let json = json!({
-            "language": "python"
+            "language": "ruby",
+            "program": "bin/rails",
+            "rubyOptions": { "bundler": true, "rails": true }
         });
 
-        let result = serde_json::from_value::<DebuggerStartArgs>(json);
-        assert!(result.is_err());
+        let args: DebuggerStartArgs = serde_json::from_value(json).unwrap();
+        assert!(args.ruby_options.bundler);
+        assert!(args.ruby_options.rails);
+
+        let args: DebuggerStartArgs =
+            serde_json::from_value(json!({"language": "ruby", "program": "app.rb"})).unwrap();
+        assert_eq!(args.ruby_options, RubyLaunchOptions::default());
+    }
+
+    #[tokio::test]
+    async fn test_debugger_start_java_options() {
+        let args: DebuggerStartArgs = serde_json::from_value(json!({
+            "language": "java",
+            "program": "com.example.FizzBuzz",
+            "javaOptions": { "classPath": ["out"], "vmArgs": ["-ea"] }
+        }))
+        .unwrap();
+        assert_eq!(args.java_options.class_path, ["out"]);
+        assert_eq!(args.java_options.vm_args, ["-ea"]);
+
+        let handler = ToolsHandler::new(Arc::new(RwLock::new(SessionManager::new())));
+        let result = handler
+            .handle_tool(
+                "debugger_start",
+                json!({
+                    "language": "python",
+                    "program": "app.py",
+                    "javaOptions": { "mainClass": "App" }
+                }),
+            )
+            .await;
+        match result {
+            Err(Error::InvalidRequest(msg)) => assert!(msg.contains("only supported for java")),
+            other => panic!("Expected InvalidRequest, got {:?}", other),
+        }
+        let result = handler
+            .handle_tool(
+                "debugger_start",
+                json!({ "language": "java", "program": "com/example/FizzBuzz" }),
+            )
+            .await;
+        match result {
+            Err(Error::InvalidRequest(msg)) => assert!(msg.contains("Invalid Java class name")),
+            other => panic!("Expected InvalidRequest, got {:?}", other),
+        }
+    }
+
+    #[test]
+    fn test_debugger_start_args_attach_mode() {
+        let json = json!({
+            "language": "go",
+            "mode": "attach",
+            "processName": "myservice"
+        });
+
+        let args: DebuggerStartArgs = serde_json::from_value(json).unwrap();
+        assert_eq!(args.mode.as_deref(), Some("attach"));
+        assert_eq!(args.process_name.as_deref(), Some("myservice"));
+        assert!(args.process_id.is_none());
+        assert!(args.program.is_empty());
+    }
+
+    #[tokio::test]
+    async fn test_debugger_attach_unsupported_language() {
+        let manager = Arc::new(RwLock::new(SessionManager::new()));
+        let handler = ToolsHandler::new(manager);
+
+        let result = handler
+            .handle_tool(
+                "debugger_start",
+                json!({ "language": "ruby", "mode": "attach", "processId": 1 }),
+            )
+            .await;
+        assert!(matches!(result, Err(Error::InvalidRequest(_))));
+    }
+
+    #[tokio::test]
+    async fn test_debugger_start_unsupported_mode() {
+        let manager = Arc::new(RwLock::new(SessionManager::new()));
+        let handler = ToolsHandler::new(manager);
+
+        let result = handler
+            .handle_tool(
+                "debugger_start",
+                json!({ "language": "go", "program": "main.go", "mode": "pytest" }),
+            )
+            .await;
+        match result {
+            Err(Error::InvalidRequest(msg)) => assert!(msg.contains("debug, test, exec, attach")),
+            other => panic!("expected InvalidRequest, got {:?}", other),
+        }
+    }
+
+    #[tokio::test]
+    async fn test_debugger_start_python_module_name_validated() {
+        let manager = Arc::new(RwLock::new(SessionManager::new()));
+        let handler = ToolsHandler::new(manager);
+
+        let result = handler
+            .handle_tool(
+                "debugger_start",
+                json!({ "language": "python", "program": "../etc/passwd", "mode": "module" }),
+            )
+            .await;
+        assert!(matches!(result, Err(Error::InvalidRequest(_))));
+    }
+
+    #[tokio::test]
+    async fn test_debugger_start_module_argument() {
+        let manager = Arc::new(RwLock::new(SessionManager::new()));
+        let handler = ToolsHandler::new(manager);
+
+        for arguments in [
+            json!({ "language": "go", "module": "app.server" }),
+            json!({ "language": "python", "module": "app.server", "program": "app.py" }),
+            json!({ "language": "python", "module": "app.server", "mode": "pytest" }),
+            json!({ "language": "python", "module": "../etc/passwd" }),
+            json!({ "language": "ruby", "program": "app.rb", "env": { "DEBUG": "1" } }),
+        ] {
+            let result = handler.handle_tool("debugger_start", arguments).await;
+            assert!(matches!(result, Err(Error::InvalidRequest(_))));
+        }
+
+        let args: DebuggerStartArgs = serde_json::from_value(json!({
+            "language": "python",
+            "module": "app.server",
+            "env": { "APP_ENV": "test" }
+        }))
+        .unwrap();
+        assert_eq!(args.module.as_deref(), Some("app.server"));
+        assert_eq!(args.env["APP_ENV"], "test");
+    }
+
+    #[tokio::test]
+    async fn test_webhooks_need_an_allowed_url() {
+        let handler = ToolsHandler::new(Arc::new(RwLock::new(SessionManager::new())));
+        let err = handler
+            .handle_tool(
+                "debugger_start",
+                json!({
+                    "language": "python",
+                    "program": "app.py",
+                    "webhookUrl": "http://169.254.169.254/latest/meta-data"
+                }),
+            )
+            .await
+            .unwrap_err();
+        assert!(err.to_string().contains("Webhooks are disabled"), "{}", err);
+
+        let err = handler
+            .handle_tool("debugger_webhook_deliveries", json!({"sessionId": "nope"}))
+            .await
+            .unwrap_err();
+        assert!(matches!(err, Error::SessionNotFound(_)), "{:?}", err);
+    }
+
+    #[tokio::test]
+    async fn test_on_uncaught_is_checked_before_starting() {
+        let handler = ToolsHandler::new(Arc::new(RwLock::new(SessionManager::new())));
+        for (arguments, expected) in [
+            (
+                json!({"language": "ruby", "program": "app.rb", "onUncaught": "break"}),
+                "exceptionBreakpointFilters",
+            ),
+            (
+                json!({
+                    "language": "python",
+                    "program": "app.py",
+                    "onUncaught": "terminate",
+                    "captureOnException": true
+                }),
+                "captureOnException",
+            ),
+        ] {
+            let err = handler
+                .handle_tool("debugger_start", arguments)
+                .await
+                .unwrap_err();
+            assert!(err.to_string().contains(expected), "{}", err);
+        }
+    }
+
+    #[tokio::test]
+    async fn test_watch_is_checked_before_starting() {
+        let handler = ToolsHandler::new(Arc::new(RwLock::new(SessionManager::new())));
+        let dir = tempfile::tempdir().unwrap();
+        let program = dir.path().join("app.py");
+        std::fs::write(&program, "print(1)\n").unwrap();
+        for (arguments, expected) in [
+            (
+                json!({"language": "python", "program": program, "mode": "attach", "processId": 1, "watch": true}),
+                "only supported when launching",
+            ),
+            (
+                json!({"language": "python", "program": "http.server", "mode": "module", "watch": true}),
+                "mode module",
+            ),
+            (
+                json!({"language": "python", "program": program, "watch": true, "watchDebounceMs": 120_000}),
+                "at most 60000",
+            ),
+        ] {
+            let err = handler
+                .handle_tool("debugger_start", arguments)
+                .await
+                .unwrap_err();
+            assert!(err.to_string().contains(expected), "{}", err);
+        }
+
+        let err = handler
+            .handle_tool("debugger_watch_stop", json!({"sessionId": "missing"}))
+            .await
+            .unwrap_err();
+        assert!(matches!(err, Error::SessionNotFound(_)), "{}", err);
+    }
+
+    #[tokio::test]
+    async fn test_target_exec_prefix_needs_server_opt_in() {
+        let handler = ToolsHandler::new(Arc::new(RwLock::new(SessionManager::new())));
+        let prefix = json!(["docker", "exec", "-i", "app"]);
+        for (arguments, expected) in [
+            (
+                json!({"language": "python", "program": "app.py", "targetExecPrefix": prefix}),
+                "security.allow_target_exec_prefix",
+            ),
+            (
+                json!({"language": "go", "mode": "attach", "processId": 1, "targetExecPrefix": prefix}),
+                "only supported when launching",
+            ),
+        ] {
+            let err = handler
+                .handle_tool("debugger_start", arguments)
+                .await
+                .unwrap_err();
+            assert!(err.to_string().contains(expected), "{}", err);
+        }
+    }
+
+    #[tokio::test]
+    async fn test_debugger_start_go_exec_requires_executable() {
+        let manager = Arc::new(RwLock::new(SessionManager::new()));
+        let handler = ToolsHandler::new(manager);
+        let dir = tempfile::tempdir().unwrap();
+        let source = dir.path().join("main.go");
+        std::fs::write(&source, "package main\n").unwrap();
+
+        let result = handler
+            .handle_tool(
+                "debugger_start",
+                json!({ "language": "go", "program": source, "mode": "exec" }),
+            )
+            .await;
+        assert!(result.unwrap_err().to_string().contains("Not executable"));
+
+        let result = handler
+            .handle_tool(
+                "debugger_start",
+                json!({
+                    "language": "go",
+                    "program": source,
+                    "goOptions": { "substitutePath": [{ "from": "", "to": "/build" }] }
+                }),
+            )
+            .await;
+        assert!(matches!(result, Err(Error::InvalidRequest(_))));
+    }
+
+    #[test]
+    fn test_validate_module_name() {
+        assert!(validate_module_name("app").is_ok());
+        assert!(validate_module_name("my_pkg.server").is_ok());
+        assert!(validate_module_name("pkg..mod").is_err());
+        assert!(validate_module_name("1pkg").is_err());
+        assert!(validate_module_name("pkg/mod").is_err());
+        assert!(validate_module_name("").is_err());
+    }
+
+    #[test]
+    fn test_select_attach_target() {
+        let process = |pid| ProcessInfo {
+            pid,
+            name: "myservice".to_string(),
+            cmdline: format!("myservice --id {}", pid),
+            user: None,
+            start_time: None,
+        };
+
+        assert!(matches!(
+            select_attach_target("myservice", vec![]),
+            Err(Error::Process(_))
+        ));
+
+        let target = select_attach_target("myservice", vec![process(10)]).unwrap();
+        assert_eq!(target.pid, 10);
+
+        match select_attach_target("myservice", vec![process(10), process(11)]) {
+            Err(Error::AmbiguousProcess { candidates, .. }) => assert_eq!(candidates.len(), 2),
+            other => panic!("Expected AmbiguousProcess, got {:?}", other),
+        }
     }
 
     #[test]
@@ -1083,6 +7811,30 @@ mod tests {
         assert!(result.is_err());
     }
 
+    #[tokio::test]
+    async fn test_find_frame_validation() {
+        let handler = ToolsHandler::new(Arc::new(RwLock::new(SessionManager::new())));
+
+        let result = handler
+            .handle_tool("debugger_find_frame", json!({"sessionId": "test"}))
+            .await;
+        assert!(result.is_err());
+        let result = handler
+            .handle_tool(
+                "debugger_find_frame",
+                json!({"sessionId": "test", "function": " "}),
+            )
+            .await;
+        assert!(matches!(result, Err(Error::InvalidRequest(_))));
+        let result = handler
+            .handle_tool(
+                "debugger_find_frame",
+                json!({"sessionId": "test", "function": "handler"}),
+            )
+            .await;
+        assert!(matches!(result, Err(Error::SessionNotFound(_))));
+    }
+
     #[tokio::test]
     async fn test_handle_tool_evaluate_invalid_json() {
         let manager = Arc::new(RwLock::new(SessionManager::new()));
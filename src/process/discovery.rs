@@ -0,0 +1,261 @@
+//! Process discovery via procfs
+//!
+//! Used by attach-by-name so the user doesn't have to look up a pid before
+//! attaching. Everything is read straight from `/proc`, so this only works on
+//! Linux (which is where the debugger containers run).
+
+use serde::Serialize;
+use std::fs;
+use std::path::Path;
+
+/// Kernel clock ticks per second used by `/proc/<pid>/stat` (USER_HZ).
+/// This is 100 on every mainstream Linux architecture.
+pub(crate) const CLOCK_TICKS_PER_SEC: u64 = 100;
+
+/// A running process that can be offered as an attach candidate
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ProcessInfo {
+    pub pid: u32,
+    /// Short process name from `/proc/<pid>/comm`
+    pub name: String,
+    /// Full command line, arguments separated by spaces
+    pub cmdline: String,
+    /// Owning user name (or numeric uid if it can't be resolved)
+    pub user: Option<String>,
+    /// Process start time in seconds since the Unix epoch
+    pub start_time: Option<u64>,
+}
+
+/// List all user-space processes visible in `/proc`
+pub fn list_processes() -> Vec<ProcessInfo> {
+    list_processes_in(Path::new("/proc"), Path::new("/etc/passwd"))
+}
+
+/// Find processes whose name or command line contains `query`
+///
+/// The MCP server itself is never returned as a candidate.
+pub fn find_processes(query: &str) -> Vec<ProcessInfo> {
+    filter_processes(list_processes(), query, std::process::id())
+}
+
+/// Look up a single process by pid
+pub fn get_process(pid: u32) -> Option<ProcessInfo> {
+    read_process(Path::new("/proc"), Path::new("/etc/passwd"), pid)
+}
+
+fn filter_processes(processes: Vec<ProcessInfo>, query: &str, own_pid: u32) -> Vec<ProcessInfo> {
+    processes
+        .into_iter()
+        .filter(|p| p.pid != own_pid)
+        .filter(|p| p.name.contains(query) || p.cmdline.contains(query))
+        .collect()
+}
+
+fn list_processes_in(proc_root: &Path, passwd: &Path) -> Vec<ProcessInfo> {
+    let entries = match fs::read_dir(proc_root) {
+        Ok(entries) => entries,
+        Err(_) => return Vec::new(),
+    };
+
+    let mut processes: Vec<ProcessInfo> = entries
+        .filter_map(|entry| entry.ok())
+        .filter_map(|entry| entry.file_name().to_str()?.parse::<u32>().ok())
+        .filter_map(|pid| read_process(proc_root, passwd, pid))
+        .collect();
+
+    processes.sort_by_key(|p| p.pid);
+    processes
+}
+
+pub(crate) fn read_process(proc_root: &Path, passwd: &Path, pid: u32) -> Option<ProcessInfo> {
+    let dir = proc_root.join(pid.to_string());
+
+    // Kernel threads have an empty cmdline and can't be attached to
+    let raw_cmdline = fs::read(dir.join("cmdline")).ok()?;
+    let cmdline = raw_cmdline
+        .split(|b| *b == 0)
+        .filter(|arg| !arg.is_empty())
+        .map(|arg| String::from_utf8_lossy(arg).into_owned())
+        .collect::<Vec<_>>()
+        .join(" ");
+    if cmdline.is_empty() {
+        return None;
+    }
+
+    let name = fs::read_to_string(dir.join("comm"))
+        .map(|s| s.trim().to_string())
+        .unwrap_or_default();
+
+    let user = fs::read_to_string(dir.join("status"))
+        .ok()
+        .and_then(|status| parse_uid(&status))
+        .map(|uid| user_name(passwd, uid).unwrap_or_else(|| uid.to_string()));
+
+    let start_time = fs::read_to_string(dir.join("stat"))
+        .ok()
+        .and_then(|stat| parse_start_ticks(&stat))
+        .and_then(|ticks| {
+            let boot_time = parse_boot_time(&fs::read_to_string(proc_root.join("stat")).ok()?)?;
+            Some(boot_time + ticks / CLOCK_TICKS_PER_SEC)
+        });
+
+    Some(ProcessInfo {
+        pid,
+        name,
+        cmdline,
+        user,
+        start_time,
+    })
+}
+
+/// Real uid from the `Uid:` line of `/proc/<pid>/status`
+fn parse_uid(status: &str) -> Option<u32> {
+    status
+        .lines()
+        .find_map(|line| line.strip_prefix("Uid:"))
+        .and_then(|rest| rest.split_whitespace().next())
+        .and_then(|uid| uid.parse().ok())
+}
+
+/// Start time (field 22, in clock ticks since boot) from `/proc/<pid>/stat`
+///
+/// The command name (field 2) may contain spaces and parentheses, so fields
+/// are counted from the last `)`.
+fn parse_start_ticks(stat: &str) -> Option<u64> {
+    let after_comm = &stat[stat.rfind(')')? + 1..];
+    after_comm.split_whitespace().nth(19)?.parse().ok()
+}
+
+/// Boot time in seconds since the Unix epoch from the `btime` line of `/proc/stat`
+fn parse_boot_time(stat: &str) -> Option<u64> {
+    stat.lines()
+        .find_map(|line| line.strip_prefix("btime "))
+        .and_then(|btime| btime.trim().parse().ok())
+}
+
+fn user_name(passwd: &Path, uid: u32) -> Option<String> {
+    let content = fs::read_to_string(passwd).ok()?;
+    content.lines().find_map(|line| {
+        let mut fields = line.split(':');
+        let name = fields.next()?;
+        let entry_uid: u32 = fields.nth(1)?.parse().ok()?;
+        (entry_uid == uid).then(|| name.to_string())
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::path::PathBuf;
+
+    fn write_process(root: &Path, pid: u32, comm: &str, cmdline: &[&str], uid: u32) {
+        let dir = root.join(pid.to_string());
+        fs::create_dir_all(&dir).unwrap();
+        fs::write(dir.join("comm"), format!("{}\n", comm)).unwrap();
+        let mut raw = cmdline.join("\0");
+        raw.push('\0');
+        fs::write(dir.join("cmdline"), raw).unwrap();
+        fs::write(
+            dir.join("status"),
+            format!(
+                "Name:\t{}\nUid:\t{}\t{}\t{}\t{}\n",
+                comm, uid, uid, uid, uid
+            ),
+        )
+        .unwrap();
+        fs::write(
+            dir.join("stat"),
+            format!(
+                "{} ({}) S 1 1 1 0 -1 4194560 0 0 0 0 0 0 0 0 20 0 1 0 12345 0 0",
+                pid, comm
+            ),
+        )
+        .unwrap();
+    }
+
+    fn fake_proc() -> (tempfile::TempDir, PathBuf, PathBuf) {
+        let dir = tempfile::tempdir().unwrap();
+        let proc_root = dir.path().join("proc");
+        fs::create_dir_all(&proc_root).unwrap();
+        fs::write(proc_root.join("stat"), "cpu 1 2 3\nbtime 1700000000\n").unwrap();
+
+        let passwd = dir.path().join("passwd");
+        fs::write(
+            &passwd,
+            "root:x:0:0:root:/root:/bin/bash\napp:x:1000:1000::/home/app:/bin/sh\n",
+        )
+        .unwrap();
+
+        write_process(
+            &proc_root,
+            42,
+            "myservice",
+            &["/usr/bin/myservice", "--port", "80"],
+            1000,
+        );
+        write_process(&proc_root, 7, "python3", &["python3", "worker.py"], 0);
+
+        // Kernel thread: empty cmdline
+        let kthread = proc_root.join("2");
+        fs::create_dir_all(&kthread).unwrap();
+        fs::write(kthread.join("cmdline"), "").unwrap();
+        fs::write(kthread.join("comm"), "kthreadd\n").unwrap();
+
+        (dir, proc_root, passwd)
+    }
+
+    #[test]
+    fn test_list_processes_skips_kernel_threads() {
+        let (_dir, proc_root, passwd) = fake_proc();
+        let processes = list_processes_in(&proc_root, &passwd);
+
+        let pids: Vec<u32> = processes.iter().map(|p| p.pid).collect();
+        assert_eq!(pids, vec![7, 42]);
+    }
+
+    #[test]
+    fn test_read_process_details() {
+        let (_dir, proc_root, passwd) = fake_proc();
+        let process = read_process(&proc_root, &passwd, 42).unwrap();
+
+        assert_eq!(process.name, "myservice");
+        assert_eq!(process.cmdline, "/usr/bin/myservice --port 80");
+        assert_eq!(process.user.as_deref(), Some("app"));
+        // btime + 12345 ticks / 100
+        assert_eq!(process.start_time, Some(1_700_000_123));
+    }
+
+    #[test]
+    fn test_filter_processes_by_name_and_cmdline() {
+        let (_dir, proc_root, passwd) = fake_proc();
+        let processes = list_processes_in(&proc_root, &passwd);
+
+        let by_name = filter_processes(processes.clone(), "myservice", 1);
+        assert_eq!(by_name.len(), 1);
+        assert_eq!(by_name[0].pid, 42);
+
+        let by_cmdline = filter_processes(processes.clone(), "worker.py", 1);
+        assert_eq!(by_cmdline.len(), 1);
+        assert_eq!(by_cmdline[0].pid, 7);
+
+        // Own pid is never a candidate
+        let own = filter_processes(processes, "myservice", 42);
+        assert!(own.is_empty());
+    }
+
+    #[test]
+    fn test_parse_start_ticks_with_spaces_in_comm() {
+        let stat = "99 (my (odd) proc) S 1 1 1 0 -1 0 0 0 0 0 0 0 0 0 20 0 1 0 500 0 0";
+        assert_eq!(parse_start_ticks(stat), Some(500));
+    }
+
+    #[test]
+    fn test_unknown_uid_falls_back_to_number() {
+        let (_dir, proc_root, passwd) = fake_proc();
+        write_process(&proc_root, 50, "ghost", &["ghost"], 4242);
+
+        let process = read_process(&proc_root, &passwd, 50).unwrap();
+        assert_eq!(process.user.as_deref(), Some("4242"));
+    }
+}
@@ -1 +1,6 @@
-// Process management will be implemented here
+pub mod discovery;
+pub mod orphans;
+pub mod private_dir;
+pub mod usage;
+
+pub use discovery::ProcessInfo;
@@ -0,0 +1,253 @@
+//! Adapter processes left behind by a server that died
+//!
+//! When `serve` crashes or is killed, the `dlv`, `debugpy`, `rdbg`, ... it
+//! spawned keep running and holding their ports. Every adapter spawned is
+//! recorded as a file `<server pid>-<adapter pid>` in
+//! `debugger-mcp-adapters-<uid>` under the temp dir, holding the adapter's
+//! start time and command line so a reused pid isn't mistaken for it. The
+//! directory is private to the user (see `private_dir`), and a record the
+//! user doesn't own is never acted on, so nobody else can get a process
+//! killed by planting one.
+//!
+//! On startup the records of servers that are no longer running are checked:
+//! adapters still running are orphans. They are logged, and killed when
+//! `sessions.reap_orphaned_adapters` is set. Records of adapters that have
+//! exited are removed; those of unreaped orphans are kept, so they are
+//! reported again by the next start.
+
+use super::discovery::{read_process, ProcessInfo};
+use super::private_dir;
+use std::fs;
+use std::path::{Path, PathBuf};
+use tracing::{info, warn};
+
+/// Directory of adapter records in the temp dir, suffixed with the uid
+const RECORDS_DIR: &str = "debugger-mcp-adapters";
+
+/// An adapter still running after the server that spawned it exited
+#[derive(Debug, Clone, PartialEq)]
+pub struct Orphan {
+    pub pid: u32,
+    pub server_pid: u32,
+    pub cmdline: String,
+    /// Whether it was killed
+    pub reaped: bool,
+}
+
+/// The user's records directory, with its uid
+fn records_dir() -> std::io::Result<(PathBuf, u32)> {
+    let dir = private_dir::private_temp_dir(RECORDS_DIR)?;
+    let uid = private_dir::current_uid().unwrap_or_default();
+    Ok((dir, uid))
+}
+
+/// Record an adapter spawned by this server
+pub fn track(adapter_pid: Option<u32>) {
+    let Some(pid) = adapter_pid else {
+        return;
+    };
+    let tracked = records_dir()
+        .and_then(|(dir, _)| track_in(&dir, Path::new("/proc"), std::process::id(), pid));
+    if let Err(e) = tracked {
+        warn!("⚠️  Could not record adapter process {}: {}", pid, e);
+    }
+}
+
+/// Check the records of exited servers for orphans, killing them if `reap`
+pub fn sweep(reap: bool) -> Vec<Orphan> {
+    let (dir, uid) = match records_dir() {
+        Ok(records) => records,
+        Err(e) => {
+            warn!("⚠️  Not checking for orphaned adapters: {}", e);
+            return Vec::new();
+        }
+    };
+    let orphans = sweep_in(&dir, uid, Path::new("/proc"), reap, kill);
+    for orphan in &orphans {
+        if orphan.reaped {
+            info!(
+                "🧹 Killed orphaned adapter {} ({}) of exited server {}",
+                orphan.pid, orphan.cmdline, orphan.server_pid
+            );
+        } else {
+            warn!(
+                "⚠️  Orphaned adapter {} ({}) of exited server {} is still running; set sessions.reap_orphaned_adapters to kill such adapters on startup",
+                orphan.pid, orphan.cmdline, orphan.server_pid
+            );
+        }
+    }
+    orphans
+}
+
+/// Send SIGTERM
+#[cfg(unix)]
+fn kill(pid: u32) -> bool {
+    let Ok(pid) = libc::pid_t::try_from(pid) else {
+        return false;
+    };
+    // SAFETY: kill(2) only takes plain integers
+    unsafe { libc::kill(pid, libc::SIGTERM) == 0 }
+}
+
+/// Without procfs no orphan is found, so nothing is ever killed
+#[cfg(not(unix))]
+fn kill(_pid: u32) -> bool {
+    false
+}
+
+/// Start time and command line identifying a process across pid reuse
+fn identity(process: &ProcessInfo) -> String {
+    format!(
+        "{}\n{}",
+        process.start_time.unwrap_or_default(),
+        process.cmdline
+    )
+}
+
+fn read_identity(proc_root: &Path, pid: u32) -> Option<(String, String)> {
+    let process = read_process(proc_root, Path::new("/etc/passwd"), pid)?;
+    Some((identity(&process), process.cmdline))
+}
+
+fn track_in(dir: &Path, proc_root: &Path, server_pid: u32, pid: u32) -> std::io::Result<()> {
+    let Some((identity, _)) = read_identity(proc_root, pid) else {
+        return Ok(());
+    };
+    fs::write(dir.join(format!("{}-{}", server_pid, pid)), identity)
+}
+
+/// Check the records in `dir`; records not owned by `uid` are left alone
+fn sweep_in(
+    dir: &Path,
+    uid: u32,
+    proc_root: &Path,
+    reap: bool,
+    mut kill: impl FnMut(u32) -> bool,
+) -> Vec<Orphan> {
+    // Without procfs nothing can be told apart, so nothing is touched
+    if !proc_root.join("self").exists() && !proc_root.join("1").exists() {
+        return Vec::new();
+    }
+    let Ok(entries) = fs::read_dir(dir) else {
+        return Vec::new();
+    };
+    let mut entries: Vec<_> = entries.filter_map(|e| e.ok()).collect();
+    entries.sort_by_key(|e| e.file_name());
+
+    let mut orphans = Vec::new();
+    for entry in entries {
+        let name = entry.file_name();
+        let Some((server_pid, pid)) = name.to_str().and_then(|n| {
+            let (server, adapter) = n.split_once('-')?;
+            Some((server.parse::<u32>().ok()?, adapter.parse::<u32>().ok()?))
+        }) else {
+            continue;
+        };
+        if proc_root.join(server_pid.to_string()).exists()
+            || !private_dir::owned_by(&entry.path(), uid)
+        {
+            continue;
+        }
+
+        let recorded = fs::read_to_string(entry.path()).unwrap_or_default();
+        let running = read_identity(proc_root, pid).filter(|(identity, _)| *identity == recorded);
+        let keep = match running {
+            Some((_, cmdline)) => {
+                let reaped = reap && kill(pid);
+                orphans.push(Orphan {
+                    pid,
+                    server_pid,
+                    cmdline,
+                    reaped,
+                });
+                !reaped
+            }
+            None => false,
+        };
+        if !keep {
+            let _ = fs::remove_file(entry.path());
+        }
+    }
+    orphans
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn write_process(root: &Path, pid: u32, cmdline: &str, start_ticks: u64) {
+        let dir = root.join(pid.to_string());
+        fs::create_dir_all(&dir).unwrap();
+        fs::write(dir.join("cmdline"), cmdline.replace(' ', "\0")).unwrap();
+        fs::write(
+            dir.join("stat"),
+            format!(
+                "{} (x) S 1 1 1 0 -1 0 0 0 0 0 0 0 0 0 20 0 1 0 {} 0 0",
+                pid, start_ticks
+            ),
+        )
+        .unwrap();
+    }
+
+    #[test]
+    fn test_orphans_of_exited_servers() {
+        let dir = tempfile::tempdir().unwrap();
+        let proc_root = dir.path().join("proc");
+        let records = dir.path().join("records");
+        let uid = private_dir::current_uid().unwrap();
+        private_dir::ensure_private(&records, uid).unwrap();
+        fs::create_dir_all(&proc_root).unwrap();
+        fs::write(proc_root.join("stat"), "btime 1700000000\n").unwrap();
+
+        // Server 10 died leaving dlv 11 and debugpy 12; server 20 still runs
+        write_process(&proc_root, 1, "init", 1);
+        write_process(&proc_root, 11, "dlv dap --listen 127.0.0.1:4000", 500);
+        write_process(&proc_root, 12, "python -m debugpy.adapter", 600);
+        write_process(&proc_root, 13, "python worker.py", 700);
+        write_process(&proc_root, 20, "debugger_mcp serve", 800);
+        write_process(&proc_root, 21, "rdbg --open", 900);
+        for (server, adapter) in [(10, 11), (10, 12), (10, 13), (20, 21)] {
+            track_in(&records, &proc_root, server, adapter).unwrap();
+        }
+        // Adapter 13 exited and its pid went to another process
+        write_process(&proc_root, 13, "python other.py", 950);
+
+        // Records of another user are never acted on, nor removed
+        let orphans = sweep_in(&records, uid + 1, &proc_root, true, |_| unreachable!());
+        assert!(orphans.is_empty());
+        assert!(records.join("10-13").exists());
+
+        let orphans = sweep_in(&records, uid, &proc_root, false, |_| unreachable!());
+        let pids: Vec<u32> = orphans.iter().map(|o| o.pid).collect();
+        assert_eq!(pids, vec![11, 12]);
+        assert_eq!(orphans[0].cmdline, "dlv dap --listen 127.0.0.1:4000");
+        assert!(!orphans[0].reaped);
+        assert!(!records.join("10-13").exists());
+        assert!(records.join("10-11").exists());
+
+        // Opted in, orphans are killed and forgotten; live servers' adapters stay
+        let mut killed = Vec::new();
+        let orphans = sweep_in(&records, uid, &proc_root, true, |pid| {
+            killed.push(pid);
+            pid != 12
+        });
+        assert_eq!(killed, vec![11, 12]);
+        assert!(orphans[0].reaped && !orphans[1].reaped);
+        assert!(!records.join("10-11").exists());
+        assert!(records.join("10-12").exists());
+        assert!(records.join("20-21").exists());
+    }
+
+    #[cfg(unix)]
+    #[test]
+    fn test_kill_terminates_process() {
+        let mut child = std::process::Command::new("sleep")
+            .arg("30")
+            .spawn()
+            .unwrap();
+        assert!(kill(child.id()));
+        let status = child.wait().unwrap();
+        use std::os::unix::process::ExitStatusExt;
+        assert_eq!(status.signal(), Some(libc::SIGTERM));
+    }
+}
@@ -0,0 +1,134 @@
+//! Directories of this server's state that only its user can reach
+//!
+//! The temp dir is shared by every user of the machine: a fixed name in it
+//! can be taken first by someone else, filled with planted files or made a
+//! symlink to somewhere else. State kept there goes in `<name>-<uid>`
+//! instead, created 0700, and is only used if it is a real directory owned
+//! by the current user that nobody else can read or write.
+
+use std::fs;
+use std::io;
+use std::path::{Path, PathBuf};
+use std::sync::OnceLock;
+
+/// Uid of this server, from the owner of `/proc/self`, else of a file
+/// created for the purpose (None off Unix, or if neither works)
+pub fn current_uid() -> Option<u32> {
+    static UID: OnceLock<Option<u32>> = OnceLock::new();
+    *UID.get_or_init(|| {
+        #[cfg(unix)]
+        {
+            use std::os::unix::fs::MetadataExt;
+            if let Ok(metadata) = fs::metadata("/proc/self") {
+                return Some(metadata.uid());
+            }
+            let probe = std::env::temp_dir().join(format!(
+                "debugger-mcp-uid-{}-{}",
+                std::process::id(),
+                uuid::Uuid::new_v4()
+            ));
+            let uid = fs::OpenOptions::new()
+                .write(true)
+                .create_new(true)
+                .open(&probe)
+                .and_then(|file| file.metadata())
+                .map(|metadata| metadata.uid())
+                .ok();
+            let _ = fs::remove_file(&probe);
+            uid
+        }
+        #[cfg(not(unix))]
+        None
+    })
+}
+
+/// `<name>-<uid>` in the temp dir, created or checked by `ensure_private`
+pub fn private_temp_dir(name: &str) -> io::Result<PathBuf> {
+    let uid = current_uid().ok_or_else(|| io::Error::other("the current uid is unknown"))?;
+    let dir = std::env::temp_dir().join(format!("{}-{}", name, uid));
+    ensure_private(&dir, uid)?;
+    Ok(dir)
+}
+
+/// Create `dir` (and missing parents) with mode 0700, and check that it is
+/// a directory, not a symlink, owned by `uid` and closed to everyone else
+pub fn ensure_private(dir: &Path, uid: u32) -> io::Result<()> {
+    #[cfg(unix)]
+    {
+        use std::os::unix::fs::{DirBuilderExt, MetadataExt};
+        fs::DirBuilder::new()
+            .recursive(true)
+            .mode(0o700)
+            .create(dir)?;
+        let metadata = fs::symlink_metadata(dir)?;
+        let refuse = |why: String| {
+            Err(io::Error::new(
+                io::ErrorKind::PermissionDenied,
+                format!("{} {}", dir.display(), why),
+            ))
+        };
+        if !metadata.is_dir() {
+            return refuse("is not a directory".to_string());
+        }
+        if metadata.uid() != uid {
+            return refuse(format!("is owned by uid {}, not {}", metadata.uid(), uid));
+        }
+        if metadata.mode() & 0o077 != 0 {
+            return refuse(format!(
+                "has mode {:o}; only its owner may have access",
+                metadata.mode() & 0o777
+            ));
+        }
+        Ok(())
+    }
+    #[cfg(not(unix))]
+    {
+        let _ = uid;
+        fs::create_dir_all(dir)
+    }
+}
+
+/// Whether `path` itself (a symlink is not followed) is owned by `uid`
+pub fn owned_by(path: &Path, uid: u32) -> bool {
+    #[cfg(unix)]
+    {
+        use std::os::unix::fs::MetadataExt;
+        fs::symlink_metadata(path).is_ok_and(|metadata| metadata.uid() == uid)
+    }
+    #[cfg(not(unix))]
+    {
+        let _ = (path, uid);
+        true
+    }
+}
+
+#[cfg(all(test, unix))]
+mod tests {
+    use super::*;
+    use std::os::unix::fs::PermissionsExt;
+
+    #[test]
+    fn test_only_private_dirs_of_the_user_pass() {
+        let root = tempfile::tempdir().unwrap();
+        let uid = current_uid().unwrap();
+
+        let dir = root.path().join("state");
+        ensure_private(&dir, uid).unwrap();
+        assert_eq!(
+            fs::metadata(&dir).unwrap().permissions().mode() & 0o777,
+            0o700
+        );
+        // Existing and still private: fine again
+        ensure_private(&dir, uid).unwrap();
+        assert!(owned_by(&dir, uid));
+
+        // Someone else's, or open to others, or not a directory: refused
+        assert!(ensure_private(&dir, uid + 1).is_err());
+        assert!(!owned_by(&dir, uid + 1));
+        fs::set_permissions(&dir, fs::Permissions::from_mode(0o777)).unwrap();
+        assert!(ensure_private(&dir, uid).is_err());
+        let link = root.path().join("link");
+        std::os::unix::fs::symlink(root.path(), &link).unwrap();
+        assert!(ensure_private(&link, uid).is_err());
+    }
+}
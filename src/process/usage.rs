@@ -0,0 +1,94 @@
+//! Memory and CPU time of a running process via procfs
+//!
+//! Two small files per reading: the resident set size from the `VmRSS:` line
+//! of `/proc/<pid>/status`, and the user and system CPU time from
+//! `/proc/<pid>/stat`. Nothing is spawned, so reading every few seconds is
+//! cheap. Like discovery, this only works on Linux, and only for processes
+//! in the server's pid namespace.
+
+use super::discovery::CLOCK_TICKS_PER_SEC;
+use std::fs;
+use std::path::Path;
+
+/// What a process uses at the moment it is read
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct ProcessUsage {
+    pub rss_bytes: u64,
+    /// User and system CPU time since the process started
+    pub cpu_ms: u64,
+}
+
+/// Read the usage of process `pid`; None if it isn't running (gone, a
+/// zombie, or not visible from here)
+pub fn read_usage(pid: u32) -> Option<ProcessUsage> {
+    read_usage_in(Path::new("/proc"), pid)
+}
+
+fn read_usage_in(proc_root: &Path, pid: u32) -> Option<ProcessUsage> {
+    let dir = proc_root.join(pid.to_string());
+    let cpu_ms = parse_cpu_ms(&fs::read_to_string(dir.join("stat")).ok()?)?;
+    let rss_bytes = parse_rss_bytes(&fs::read_to_string(dir.join("status")).ok()?)?;
+    Some(ProcessUsage { rss_bytes, cpu_ms })
+}
+
+/// Resident set size from the `VmRSS:` line (in kB) of `/proc/<pid>/status`;
+/// zombies have none
+fn parse_rss_bytes(status: &str) -> Option<u64> {
+    let kb: u64 = status
+        .lines()
+        .find_map(|line| line.strip_prefix("VmRSS:"))?
+        .split_whitespace()
+        .next()?
+        .parse()
+        .ok()?;
+    Some(kb * 1024)
+}
+
+/// utime + stime (fields 14 and 15, in clock ticks) from `/proc/<pid>/stat`,
+/// in milliseconds; None for zombies and dead processes (field 3)
+///
+/// As with the start time, fields are counted from the last `)`.
+fn parse_cpu_ms(stat: &str) -> Option<u64> {
+    let mut fields = stat[stat.rfind(')')? + 1..].split_whitespace();
+    if matches!(fields.next()?, "Z" | "X") {
+        return None;
+    }
+    let mut fields = fields.skip(10);
+    let utime: u64 = fields.next()?.parse().ok()?;
+    let stime: u64 = fields.next()?.parse().ok()?;
+    Some((utime + stime) * 1000 / CLOCK_TICKS_PER_SEC)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_read_usage_from_procfs() {
+        let dir = tempfile::tempdir().unwrap();
+        let process = dir.path().join("42");
+        fs::create_dir_all(&process).unwrap();
+        fs::write(
+            process.join("stat"),
+            "42 (my (odd) app) R 1 1 1 0 -1 0 0 0 0 0 250 50 0 0 20 0 1 0 500 0 0",
+        )
+        .unwrap();
+        fs::write(
+            process.join("status"),
+            "Name:\tapp\nVmPeak:\t  9000 kB\nVmRSS:\t    2048 kB\n",
+        )
+        .unwrap();
+
+        let usage = read_usage_in(dir.path(), 42).unwrap();
+        assert_eq!(usage.rss_bytes, 2048 * 1024);
+        assert_eq!(usage.cpu_ms, 3000);
+        assert_eq!(read_usage_in(dir.path(), 43), None);
+
+        fs::write(
+            process.join("stat"),
+            "42 (app) Z 1 1 1 0 -1 0 0 0 0 0 250 50 0 0 20 0 1 0 500 0 0",
+        )
+        .unwrap();
+        assert_eq!(read_usage_in(dir.path(), 42), None);
+    }
+}
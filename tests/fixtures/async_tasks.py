@@ -0,0 +1,36 @@
+"""Three asyncio tasks for inspecting tasks on stop.
+
+Break on the marked line in worker(): sleeper is awaiting asyncio.sleep,
+waiter is awaiting an event and worker itself is running (main awaits gather).
+"""
+import asyncio
+
+
+async def sleeper():
+    await asyncio.sleep(3600)
+
+
+async def waiter(event):
+    await event.wait()
+
+
+async def worker(event):
+    total = sum(range(10))  # breakpoint: line 18
+    event.set()
+    return total
+
+
+async def main():
+    event = asyncio.Event()
+    tasks = [
+        asyncio.create_task(sleeper(), name="sleeper"),
+        asyncio.create_task(waiter(event), name="waiter"),
+        asyncio.create_task(worker(event), name="worker"),
+    ]
+    await asyncio.sleep(0)
+    await asyncio.gather(*tasks[1:])
+    tasks[0].cancel()
+
+
+if __name__ == "__main__":
+    asyncio.run(main())
@@ -0,0 +1,27 @@
+"""Unbounded mutual recursion for bounded stack reports.
+
+descend() and visit() call each other until Python raises RecursionError,
+roughly sys.getrecursionlimit() frames deep. Stop on raised exceptions to
+inspect the overflowing stack: it repeats the descend (line 12) / visit
+(line 16) pair hundreds of times above main (line 21).
+"""
+
+
+def descend(depth):
+    # Each call adds a descend frame and a visit frame
+    return visit(depth + 1)
+
+
+def visit(depth):
+    return descend(depth)
+
+
+def main():
+    try:
+        descend(0)
+    except RecursionError as e:
+        print(f"overflowed: {e}")
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,25 @@
+# Exception breakpoints: one StandardError is rescued by its caller, the
+# other escapes to the top level and ends the program.
+#
+# With mode "raised" rdbg stops on both raise lines (10 and 21); exceptionInfo
+# reports the class and message at each stop.
+
+class LookupFailed < StandardError; end
+
+def lookup(key)
+  raise LookupFailed, "no entry for #{key}" # line 10
+end
+
+def lookup_or_default(key)
+  lookup(key)
+rescue LookupFailed => e
+  puts "rescued: #{e.message}"
+  :default
+end
+
+def parse_port(text)
+  Integer(text) > 0 or raise ArgumentError, "port must be positive: #{text}" # line 21
+end
+
+puts lookup_or_default(:timeout)
+puts parse_port("0")
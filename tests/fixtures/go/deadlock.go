@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+)
+
+// ping waits for a value on in before passing it on to out. Two pings wired
+// to each other on unbuffered channels both wait to receive first, so
+// neither ever sends: a deadlock.
+func ping(in <-chan int, out chan<- int) {
+	v := <-in
+	out <- v + 1
+}
+
+func main() {
+	// The signal goroutine stays alive in a system call, so the runtime
+	// doesn't abort with "all goroutines are asleep" and the hang can be
+	// inspected, as in a real server.
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	a := make(chan int)
+	b := make(chan int)
+
+	go ping(a, b)
+	go ping(b, a)
+
+	result := <-a
+	fmt.Println("unreachable:", result)
+}
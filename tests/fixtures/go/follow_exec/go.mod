@@ -0,0 +1,3 @@
+module example.com/followexec
+
+go 1.21.0
@@ -0,0 +1,36 @@
+// Parent that runs a helper process, for debugging with goOptions.followChildren
+//
+// The helper is this binary run again with the "helper" argument, so the
+// fixture builds to a single binary; Delve follows the exec.
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+func helper() int {
+	sum := 0
+	for i := 1; i <= 10; i++ {
+		sum += i // Breakpoint target: line 16 (in the child process)
+	}
+	return sum
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "helper" {
+		fmt.Println("helper sum:", helper())
+		return
+	}
+
+	fmt.Println("parent: starting helper")
+	cmd := exec.Command(os.Args[0], "helper")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Println("helper failed:", err)
+		os.Exit(1)
+	}
+	fmt.Println("parent: helper done") // Breakpoint target: line 35 (in the parent)
+}
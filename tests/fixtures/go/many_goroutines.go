@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// More goroutines than Delve lists in one threads response (1024), all
+// blocked on release until main has been stopped at the breakpoint.
+const workers = 2000
+
+func main() {
+	release := make(chan struct{})
+	var started sync.WaitGroup
+	started.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			started.Done()
+			<-release
+		}()
+	}
+	started.Wait()
+
+	fmt.Println("all started") // breakpoint
+	close(release)
+}
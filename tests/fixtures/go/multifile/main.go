@@ -21,5 +21,9 @@ func main() {
 	product := calc.Multiply(3, 4)
 	fmt.Printf("%s says: 3 * 4 = %d\n", calc.Name, product)
 
+	// Multiply again, called from elsewhere
+	squared := Square(&calc, 5)
+	fmt.Printf("5 squared = %d\n", squared)
+
 	fmt.Println("All tests passed!")
 }
@@ -9,3 +9,8 @@ func Double(n int) int {
 func Triple(n int) int {
 	return n * 3
 }
+
+// Square squares a number with a calculator
+func Square(c *Calculator, n int) int {
+	return c.Multiply(n, n)
+}
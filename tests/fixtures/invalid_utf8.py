@@ -0,0 +1,15 @@
+# Output and variables that aren't valid UTF-8.
+#
+# Writes bytes that aren't UTF-8 to stdout, then stops at the breakpoint
+# line with a bytes value and a str holding a lone surrogate (what
+# surrogateescape decoding produces). debugger_get_output reports
+# encodingLossy; base64: true returns the bytes kept.
+import sys
+
+raw = b"caf\xe9 \xff\xfe binary\n"
+sys.stdout.buffer.write(raw)
+sys.stdout.flush()
+
+escaped = raw.decode("utf-8", errors="surrogateescape")
+latin1 = "café".encode("latin-1")
+print("done")  # line 15: breakpoint here
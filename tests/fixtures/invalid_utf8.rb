@@ -0,0 +1,14 @@
+# Output and variables that aren't valid UTF-8.
+#
+# Writes bytes that aren't UTF-8 to stdout, then stops at the breakpoint
+# line with a binary string and a string whose bytes don't match its
+# encoding. debugger_get_output reports encodingLossy; base64: true returns
+# the bytes kept.
+
+raw = "caf\xE9 \xFF\xFE binary\n".b
+$stdout.write(raw)
+$stdout.flush
+
+invalid = raw.dup.force_encoding(Encoding::UTF_8)
+latin1 = "café".encode(Encoding::ISO_8859_1)
+puts "done" # line 14: breakpoint here
@@ -92,7 +92,8 @@ async fn test_go_single_file_debug() {
         PathBuf::from(env!("CARGO_MANIFEST_DIR")).join("tests/fixtures/go/fizzbuzz.go");
 
     // Spawn Delve
-    let result = golang::GoAdapter::spawn(fixture_path.to_str().unwrap(), &[], true).await;
+    let result =
+        golang::GoAdapter::spawn(fixture_path.to_str().unwrap(), &[], true, None, &[]).await;
 
     assert!(
         result.is_ok(),
@@ -120,7 +121,8 @@ async fn test_go_multifile_package_debug() {
         PathBuf::from(env!("CARGO_MANIFEST_DIR")).join("tests/fixtures/go/multifile");
 
     // Spawn Delve with package directory
-    let result = golang::GoAdapter::spawn(fixture_path.to_str().unwrap(), &[], false).await;
+    let result =
+        golang::GoAdapter::spawn(fixture_path.to_str().unwrap(), &[], false, None, &[]).await;
 
     assert!(
         result.is_ok(),
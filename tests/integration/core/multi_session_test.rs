@@ -512,7 +512,7 @@ async fn test_nodejs_multi_session_full_workflow() {
     // Step over
     let thread_id = 1;
     session
-        .step_over(thread_id)
+        .step_over(thread_id, None)
         .await
         .expect("Failed to step over");
     println!("✅ Step over completed");
@@ -501,3 +501,1053 @@ Create mcp_protocol_log.md documenting all interactions."#,
 
     println!("\n🎉 Go Claude Code integration test completed!");
 }
+
+/// Debugging fizzbuzz.go from a read-only workspace: Delve must build its
+/// binary into the session's temp directory, not next to the source
+#[tokio::test]
+#[ignore]
+async fn test_go_fizzbuzz_read_only_workspace() {
+    use std::os::unix::fs::PermissionsExt;
+    use tokio::time::{timeout, Duration};
+
+    let go_ok = Command::new("go")
+        .arg("version")
+        .output()
+        .is_ok_and(|o| o.status.success());
+    let dlv_ok = Command::new("dlv")
+        .arg("version")
+        .output()
+        .is_ok_and(|o| o.status.success());
+    if !go_ok || !dlv_ok {
+        println!("⚠️  Skipping read-only workspace test: go or dlv not installed");
+        return;
+    }
+
+    // A read-only copy of the fixture directory
+    let workspace = TempDir::new().unwrap();
+    let fizzbuzz_path = workspace.path().join("fizzbuzz.go");
+    fs::write(&fizzbuzz_path, include_str!("../../fixtures/fizzbuzz.go")).unwrap();
+    fs::set_permissions(workspace.path(), fs::Permissions::from_mode(0o555)).unwrap();
+    let fizzbuzz_str = fizzbuzz_path.to_string_lossy().to_string();
+    if fs::write(workspace.path().join("probe"), "").is_ok() {
+        // Root ignores permissions; the build location is still checked below
+        println!("⚠️  Workspace is writable despite mode 0555 (running as root?)");
+        let _ = fs::remove_file(workspace.path().join("probe"));
+    }
+
+    let session_manager = Arc::new(RwLock::new(SessionManager::new()));
+    let tools_handler = ToolsHandler::new(Arc::clone(&session_manager));
+
+    let start = tools_handler
+        .handle_tool(
+            "debugger_start",
+            json!({
+                "language": "go",
+                "program": fizzbuzz_str,
+                "cwd": workspace.path().to_string_lossy(),
+                "stopOnEntry": false
+            }),
+        )
+        .await
+        .expect("debugger_start should succeed in a read-only workspace");
+    let session_id = start["sessionId"].as_str().unwrap().to_string();
+
+    // Set while initializing, applied before configurationDone
+    tokio::time::sleep(Duration::from_millis(100)).await;
+    tools_handler
+        .handle_tool(
+            "debugger_set_breakpoint",
+            json!({"sessionId": session_id, "sourcePath": fizzbuzz_str, "line": 13}),
+        )
+        .await
+        .expect("breakpoint should be accepted");
+
+    let stop = timeout(
+        Duration::from_secs(30),
+        tools_handler.handle_tool(
+            "debugger_wait_for_stop",
+            json!({"sessionId": session_id, "timeoutMs": 25000}),
+        ),
+    )
+    .await
+    .expect("wait_for_stop timed out")
+    .expect("program should build and stop at the breakpoint");
+    assert_eq!(stop["state"], "Stopped");
+    assert_eq!(stop["reason"], "breakpoint");
+
+    assert!(
+        !workspace.path().join("__debug_bin").exists(),
+        "Delve must not build into the workspace"
+    );
+
+    tools_handler
+        .handle_tool("debugger_disconnect", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+    fs::set_permissions(workspace.path(), fs::Permissions::from_mode(0o755)).unwrap();
+}
+
+/// Stepping into `fmt.Printf` in the multifile fixture: the default step
+/// filters step straight back out to main.main; without filters the step
+/// stops inside the fmt package
+#[tokio::test]
+#[ignore]
+async fn test_go_step_filters_skip_fmt() {
+    use tokio::time::Duration;
+
+    let dlv_ok = Command::new("dlv")
+        .arg("version")
+        .output()
+        .is_ok_and(|o| o.status.success());
+    if !dlv_ok {
+        println!("⚠️  Skipping step filter test: dlv not installed");
+        return;
+    }
+
+    let package = PathBuf::from(env!("CARGO_MANIFEST_DIR")).join("tests/fixtures/go/multifile");
+    let main_go = package.join("main.go").to_string_lossy().to_string();
+
+    let session_manager = Arc::new(RwLock::new(SessionManager::new()));
+    let tools_handler = ToolsHandler::new(Arc::clone(&session_manager));
+
+    let start = tools_handler
+        .handle_tool(
+            "debugger_start",
+            json!({
+                "language": "go",
+                "program": package.to_string_lossy(),
+                "cwd": package.to_string_lossy(),
+                "stopOnEntry": false
+            }),
+        )
+        .await
+        .expect("debugger_start should succeed");
+    let session_id = start["sessionId"].as_str().unwrap().to_string();
+
+    tokio::time::sleep(Duration::from_millis(100)).await;
+    for line in [10, 13] {
+        tools_handler
+            .handle_tool(
+                "debugger_set_breakpoint",
+                json!({"sessionId": session_id, "sourcePath": main_go, "line": line}),
+            )
+            .await
+            .expect("breakpoint should be accepted");
+    }
+
+    async fn top_frame(tools_handler: &ToolsHandler, session_id: &str) -> String {
+        let stack = tools_handler
+            .handle_tool("debugger_stack_trace", json!({"sessionId": session_id}))
+            .await
+            .unwrap();
+        stack["stackFrames"][0]["name"]
+            .as_str()
+            .unwrap_or_default()
+            .to_string()
+    }
+
+    let stop = tools_handler
+        .handle_tool(
+            "debugger_wait_for_stop",
+            json!({"sessionId": session_id, "timeoutMs": 25000}),
+        )
+        .await
+        .expect("should stop at line 10");
+    assert_eq!(stop["reason"], "breakpoint");
+
+    let step = tools_handler
+        .handle_tool("debugger_step_into", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+    assert_eq!(step["status"], "stopped", "{}", step);
+    assert!(step["skippedFrames"].as_u64().unwrap_or(0) >= 1, "{}", step);
+    assert_eq!(top_frame(&tools_handler, &session_id).await, "main.main");
+
+    // Without filters, stepping into line 13's call stops inside fmt
+    tools_handler
+        .handle_tool(
+            "debugger_configure",
+            json!({"sessionId": session_id, "settings": {"stepFilters": []}}),
+        )
+        .await
+        .unwrap();
+    tools_handler
+        .handle_tool("debugger_continue", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+    tools_handler
+        .handle_tool(
+            "debugger_wait_for_stop",
+            json!({"sessionId": session_id, "timeoutMs": 10000}),
+        )
+        .await
+        .expect("should stop at line 13");
+    let step = tools_handler
+        .handle_tool("debugger_step_into", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+    assert_eq!(step["status"], "stepping");
+    tools_handler
+        .handle_tool(
+            "debugger_wait_for_stop",
+            json!({"sessionId": session_id, "timeoutMs": 10000}),
+        )
+        .await
+        .expect("step should complete");
+    assert!(top_frame(&tools_handler, &session_id)
+        .await
+        .starts_with("fmt."));
+
+    tools_handler
+        .handle_tool("debugger_disconnect", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+}
+
+/// Dereferencing the `*Calculator` receiver of `Multiply` in the multifile
+/// fixture shows the struct's fields
+#[tokio::test]
+#[ignore]
+async fn test_go_dereference_receiver() {
+    use tokio::time::Duration;
+
+    let dlv_ok = Command::new("dlv")
+        .arg("version")
+        .output()
+        .is_ok_and(|o| o.status.success());
+    if !dlv_ok {
+        println!("⚠️  Skipping dereference test: dlv not installed");
+        return;
+    }
+
+    let package = PathBuf::from(env!("CARGO_MANIFEST_DIR")).join("tests/fixtures/go/multifile");
+    let types_go = package.join("types.go").to_string_lossy().to_string();
+
+    let session_manager = Arc::new(RwLock::new(SessionManager::new()));
+    let tools_handler = ToolsHandler::new(Arc::clone(&session_manager));
+
+    let start = tools_handler
+        .handle_tool(
+            "debugger_start",
+            json!({
+                "language": "go",
+                "program": package.to_string_lossy(),
+                "cwd": package.to_string_lossy(),
+                "stopOnEntry": false
+            }),
+        )
+        .await
+        .expect("debugger_start should succeed");
+    let session_id = start["sessionId"].as_str().unwrap().to_string();
+
+    tokio::time::sleep(Duration::from_millis(100)).await;
+    tools_handler
+        .handle_tool(
+            "debugger_set_breakpoint",
+            json!({"sessionId": session_id, "sourcePath": types_go, "line": 11}),
+        )
+        .await
+        .expect("breakpoint should be accepted");
+    tools_handler
+        .handle_tool(
+            "debugger_wait_for_stop",
+            json!({"sessionId": session_id, "timeoutMs": 25000}),
+        )
+        .await
+        .expect("should stop in Multiply");
+
+    let pointee = tools_handler
+        .handle_tool(
+            "debugger_dereference",
+            json!({"sessionId": session_id, "expression": "c"}),
+        )
+        .await
+        .expect("dereference should succeed");
+    assert_eq!(pointee["nil"], false, "{}", pointee);
+    assert_eq!(
+        pointee["value"],
+        json!({"Name": "TestCalc", "Version": "1.0"}),
+        "{}",
+        pointee
+    );
+
+    // The description's example is this response; only the frame id and
+    // the address may differ
+    let example: serde_json::Value =
+        serde_json::from_str(debugger_mcp::mcp::tools::DEREFERENCE_EXAMPLE).unwrap();
+    let (response, example) = (pointee.as_object().unwrap(), example.as_object().unwrap());
+    let mut keys: Vec<&String> = response.keys().collect();
+    let mut example_keys: Vec<&String> = example.keys().collect();
+    keys.sort();
+    example_keys.sort();
+    assert_eq!(keys, example_keys, "{}", pointee);
+    for (key, value) in example {
+        match key.as_str() {
+            "frameId" => assert!(response[key].is_i64(), "{}", pointee),
+            "pointer" => assert!(
+                response[key]
+                    .as_str()
+                    .is_some_and(|p| p.starts_with("(*main.Calculator)(0x")),
+                "{}",
+                pointee
+            ),
+            _ => assert_eq!(&response[key], value, "{}: {}", key, pointee),
+        }
+    }
+
+    tools_handler
+        .handle_tool("debugger_disconnect", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+}
+
+/// Function breakpoints by qualified name in the multifile fixture: a free
+/// function and a method with a pointer receiver resolve and are hit; a
+/// misspelled name fails naming the function
+#[tokio::test]
+#[ignore]
+async fn test_go_function_breakpoints_by_qualified_name() {
+    use tokio::time::Duration;
+
+    let dlv_ok = Command::new("dlv")
+        .arg("version")
+        .output()
+        .is_ok_and(|o| o.status.success());
+    if !dlv_ok {
+        println!("⚠️  Skipping function breakpoint test: dlv not installed");
+        return;
+    }
+
+    let package = PathBuf::from(env!("CARGO_MANIFEST_DIR")).join("tests/fixtures/go/multifile");
+
+    let session_manager = Arc::new(RwLock::new(SessionManager::new()));
+    let tools_handler = ToolsHandler::new(Arc::clone(&session_manager));
+
+    let start = tools_handler
+        .handle_tool(
+            "debugger_start",
+            json!({
+                "language": "go",
+                "program": package.to_string_lossy(),
+                "cwd": package.to_string_lossy(),
+                "stopOnEntry": true
+            }),
+        )
+        .await
+        .expect("debugger_start should succeed");
+    let session_id = start["sessionId"].as_str().unwrap().to_string();
+    tools_handler
+        .handle_tool(
+            "debugger_wait_for_stop",
+            json!({"sessionId": session_id, "timeoutMs": 25000}),
+        )
+        .await
+        .expect("should stop on entry");
+
+    for (name, delve_name) in [
+        ("main.Add", "main.Add"),
+        ("(*Calculator).Multiply", "(*Calculator).Multiply"),
+        ("main.*Calculator.Multiply()", "main.(*Calculator).Multiply"),
+    ] {
+        let bp = tools_handler
+            .handle_tool(
+                "debugger_set_function_breakpoint",
+                json!({"sessionId": session_id, "name": name}),
+            )
+            .await
+            .unwrap_or_else(|e| panic!("{} should resolve: {}", name, e));
+        assert_eq!(bp["verified"], true, "{}", bp);
+        assert_eq!(bp["name"], delve_name, "{}", bp);
+    }
+    tools_handler
+        .handle_tool(
+            "debugger_set_function_breakpoint",
+            json!({"sessionId": session_id, "name": "(*Calculator).Multiply", "remove": true}),
+        )
+        .await
+        .unwrap();
+
+    let err = tools_handler
+        .handle_tool(
+            "debugger_set_function_breakpoint",
+            json!({"sessionId": session_id, "name": "main.Ad"}),
+        )
+        .await
+        .unwrap_err();
+    assert!(
+        err.to_string().contains("couldn't find function main.Ad"),
+        "{}",
+        err
+    );
+    let listed = tools_handler
+        .handle_tool(
+            "debugger_list_breakpoints",
+            json!({"sessionId": session_id}),
+        )
+        .await
+        .unwrap();
+    assert_eq!(
+        listed["functionBreakpoints"].as_array().map(Vec::len),
+        Some(2),
+        "{}",
+        listed
+    );
+
+    for function in ["main.Add", "main.(*Calculator).Multiply"] {
+        tools_handler
+            .handle_tool("debugger_continue", json!({"sessionId": session_id}))
+            .await
+            .unwrap();
+        tools_handler
+            .handle_tool(
+                "debugger_wait_for_stop",
+                json!({"sessionId": session_id, "timeoutMs": 10000}),
+            )
+            .await
+            .unwrap_or_else(|e| panic!("should stop in {}: {}", function, e));
+        tokio::time::sleep(Duration::from_millis(100)).await;
+        let stack = tools_handler
+            .handle_tool("debugger_stack_trace", json!({"sessionId": session_id}))
+            .await
+            .unwrap();
+        assert_eq!(stack["stackFrames"][0]["name"], function, "{}", stack);
+    }
+
+    tools_handler
+        .handle_tool("debugger_disconnect", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+}
+
+/// A breakpoint in `Multiply` with a caller constraint: the multifile
+/// fixture calls it from `main` and then from `Square`, and only the call
+/// from `Square` stops
+#[tokio::test]
+#[ignore]
+async fn test_go_breakpoint_caller_constraint() {
+    let dlv_ok = Command::new("dlv")
+        .arg("version")
+        .output()
+        .is_ok_and(|o| o.status.success());
+    if !dlv_ok {
+        println!("⚠️  Skipping caller constraint test: dlv not installed");
+        return;
+    }
+
+    let package = PathBuf::from(env!("CARGO_MANIFEST_DIR")).join("tests/fixtures/go/multifile");
+    let types_go = package.join("types.go").to_string_lossy().to_string();
+
+    let session_manager = Arc::new(RwLock::new(SessionManager::new()));
+    let tools_handler = ToolsHandler::new(Arc::clone(&session_manager));
+
+    let start = tools_handler
+        .handle_tool(
+            "debugger_start",
+            json!({
+                "language": "go",
+                "program": package.to_string_lossy(),
+                "cwd": package.to_string_lossy(),
+                "stopOnEntry": true
+            }),
+        )
+        .await
+        .expect("debugger_start should succeed");
+    let session_id = start["sessionId"].as_str().unwrap().to_string();
+    tools_handler
+        .handle_tool(
+            "debugger_wait_for_stop",
+            json!({"sessionId": session_id, "timeoutMs": 25000}),
+        )
+        .await
+        .expect("should stop on entry");
+
+    let bp = tools_handler
+        .handle_tool(
+            "debugger_set_breakpoint",
+            json!({
+                "sessionId": session_id,
+                "sourcePath": types_go,
+                "line": 11,
+                "caller": "Square"
+            }),
+        )
+        .await
+        .expect("breakpoint should be accepted");
+    assert_eq!(bp["caller"], "Square", "{}", bp);
+    assert_eq!(bp["callerDepth"], 1, "{}", bp);
+
+    tools_handler
+        .handle_tool("debugger_continue", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+    let stop = tools_handler
+        .handle_tool(
+            "debugger_wait_for_stop",
+            json!({"sessionId": session_id, "timeoutMs": 10000}),
+        )
+        .await
+        .expect("should stop in Multiply called from Square");
+    let found = &stop["callerMatch"];
+    assert_eq!(found["function"], "main.Square", "{}", stop);
+    assert_eq!(found["depth"], 1, "{}", stop);
+    // The call from main was continued past
+    assert_eq!(found["skipped"], 1, "{}", stop);
+
+    tools_handler
+        .handle_tool("debugger_disconnect", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+}
+
+/// Stepping out of fizzbuzz waits for Delve's stop in main and reports the
+/// returned string, without a debugger_wait_for_stop
+#[tokio::test(flavor = "multi_thread")]
+#[ignore]
+async fn test_go_step_out_waits_for_the_stop() {
+    use tokio::time::{timeout, Duration};
+
+    let go_ok = Command::new("go")
+        .arg("version")
+        .output()
+        .is_ok_and(|o| o.status.success());
+    let dlv_ok = Command::new("dlv")
+        .arg("version")
+        .output()
+        .is_ok_and(|o| o.status.success());
+    if !go_ok || !dlv_ok {
+        println!("⚠️  Skipping step out test: go or dlv not installed");
+        return;
+    }
+
+    let fizzbuzz = PathBuf::from(env!("CARGO_MANIFEST_DIR")).join("tests/fixtures/fizzbuzz.go");
+    let session_manager = Arc::new(RwLock::new(SessionManager::new()));
+    let tools_handler = ToolsHandler::new(Arc::clone(&session_manager));
+
+    let start = tools_handler
+        .handle_tool(
+            "debugger_start",
+            json!({
+                "language": "go",
+                "program": fizzbuzz.to_string_lossy(),
+                "stopOnEntry": true
+            }),
+        )
+        .await
+        .expect("debugger_start should succeed");
+    let session_id = start["sessionId"].as_str().unwrap().to_string();
+    tools_handler
+        .handle_tool(
+            "debugger_wait_for_stop",
+            json!({"sessionId": session_id, "timeoutMs": 25000}),
+        )
+        .await
+        .expect("should stop on entry");
+
+    tools_handler
+        .handle_tool(
+            "debugger_set_breakpoint",
+            json!({"sessionId": session_id, "sourcePath": fizzbuzz.to_string_lossy(), "line": 13}),
+        )
+        .await
+        .expect("breakpoint should be accepted");
+    tools_handler
+        .handle_tool("debugger_continue", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+    tools_handler
+        .handle_tool(
+            "debugger_wait_for_stop",
+            json!({"sessionId": session_id, "timeoutMs": 10000}),
+        )
+        .await
+        .expect("should stop inside fizzbuzz(1)");
+
+    let out = timeout(
+        Duration::from_secs(10),
+        tools_handler.handle_tool("debugger_step_out", json!({"sessionId": session_id})),
+    )
+    .await
+    .expect("debugger_step_out hung")
+    .expect("debugger_step_out failed");
+    assert_eq!(out["status"], "stopped", "{}", out);
+    assert_eq!(out["returnValues"][0]["name"], "~r0", "{}", out);
+    assert_eq!(out["returnValue"], "\"1\"", "{}", out);
+
+    // Already stopped in main, so the stack is readable right away
+    let stack = tools_handler
+        .handle_tool("debugger_stack_trace", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+    assert_eq!(stack["stackFrames"][0]["name"], "main.main", "{}", stack);
+    assert_eq!(stack["stackFrames"][0]["line"], 28, "{}", stack);
+
+    tools_handler
+        .handle_tool("debugger_disconnect", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+}
+
+/// The deadlock fixture hangs without the runtime aborting it, and
+/// debugger_analyze_hang finds the pings and main waiting on each other
+#[tokio::test(flavor = "multi_thread")]
+#[ignore]
+async fn test_go_analyze_hang_finds_channel_deadlock() {
+    use tokio::time::{sleep, Duration};
+
+    let go_ok = Command::new("go")
+        .arg("version")
+        .output()
+        .is_ok_and(|o| o.status.success());
+    let dlv_ok = Command::new("dlv")
+        .arg("version")
+        .output()
+        .is_ok_and(|o| o.status.success());
+    if !go_ok || !dlv_ok {
+        println!("⚠️  Skipping hang analysis test: go or dlv not installed");
+        return;
+    }
+
+    let deadlock = PathBuf::from(env!("CARGO_MANIFEST_DIR")).join("tests/fixtures/go/deadlock.go");
+    let session_manager = Arc::new(RwLock::new(SessionManager::new()));
+    let tools_handler = ToolsHandler::new(Arc::clone(&session_manager));
+
+    let start = tools_handler
+        .handle_tool(
+            "debugger_start",
+            json!({
+                "language": "go",
+                "program": deadlock.to_string_lossy(),
+                "stopOnEntry": false
+            }),
+        )
+        .await
+        .expect("debugger_start should succeed");
+    let session_id = start["sessionId"].as_str().unwrap().to_string();
+
+    // Building and reaching the hang; the program must still be running then
+    sleep(Duration::from_secs(5)).await;
+    let state = tools_handler
+        .handle_tool("debugger_session_state", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+    assert_eq!(state["state"], "Running", "{}", state);
+
+    let analysis = tools_handler
+        .handle_tool("debugger_analyze_hang", json!({"sessionId": session_id}))
+        .await
+        .expect("debugger_analyze_hang failed");
+    assert_eq!(analysis["deadlock"], true, "{}", analysis);
+    let kinds: Vec<&str> = analysis["findings"]
+        .as_array()
+        .unwrap()
+        .iter()
+        .filter_map(|f| f["kind"].as_str())
+        .collect();
+    assert_eq!(kinds, vec!["allBlocked", "channelDeadlock"], "{}", analysis);
+    assert_eq!(
+        analysis["groups"]["chan receive"].as_array().map(Vec::len),
+        Some(3),
+        "{}",
+        analysis
+    );
+    let functions: Vec<&str> = analysis["goroutines"]
+        .as_array()
+        .unwrap()
+        .iter()
+        .filter_map(|g| g["function"].as_str())
+        .collect();
+    assert_eq!(functions.iter().filter(|f| **f == "main.ping").count(), 2);
+    assert!(functions.contains(&"main.main"), "{}", analysis);
+
+    tools_handler
+        .handle_tool("debugger_disconnect", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+}
+
+/// Delve lists 1024 goroutines at most: a program with 2000 gets a
+/// listing flagged truncated rather than one that looks complete
+#[tokio::test(flavor = "multi_thread")]
+#[ignore]
+async fn test_go_list_goroutines_flags_delves_limit() {
+    use tokio::time::{timeout, Duration};
+
+    let go_ok = Command::new("go")
+        .arg("version")
+        .output()
+        .is_ok_and(|o| o.status.success());
+    let dlv_ok = Command::new("dlv")
+        .arg("version")
+        .output()
+        .is_ok_and(|o| o.status.success());
+    if !go_ok || !dlv_ok {
+        println!("⚠️  Skipping goroutine limit test: go or dlv not installed");
+        return;
+    }
+
+    let program =
+        PathBuf::from(env!("CARGO_MANIFEST_DIR")).join("tests/fixtures/go/many_goroutines.go");
+    let program_str = program.to_string_lossy().to_string();
+    let session_manager = Arc::new(RwLock::new(SessionManager::new()));
+    let tools_handler = ToolsHandler::new(Arc::clone(&session_manager));
+
+    let start = tools_handler
+        .handle_tool(
+            "debugger_start",
+            json!({"language": "go", "program": program_str, "stopOnEntry": false}),
+        )
+        .await
+        .expect("debugger_start should succeed");
+    let session_id = start["sessionId"].as_str().unwrap().to_string();
+
+    tokio::time::sleep(Duration::from_millis(100)).await;
+    tools_handler
+        .handle_tool(
+            "debugger_set_breakpoint",
+            json!({"sessionId": session_id, "sourcePath": program_str, "line": 24}),
+        )
+        .await
+        .expect("breakpoint should be accepted");
+
+    let stop = timeout(
+        Duration::from_secs(30),
+        tools_handler.handle_tool(
+            "debugger_wait_for_stop",
+            json!({"sessionId": session_id, "timeoutMs": 25000}),
+        ),
+    )
+    .await
+    .expect("wait_for_stop timed out")
+    .expect("program should stop at the breakpoint");
+    assert_eq!(stop["reason"], "breakpoint", "{}", stop);
+
+    let page = tools_handler
+        .handle_tool(
+            "debugger_list_goroutines",
+            json!({"sessionId": session_id, "limit": 10}),
+        )
+        .await
+        .expect("debugger_list_goroutines failed");
+    assert_eq!(page["truncated"], true, "{}", page);
+    assert_eq!(page["total"], 1024, "{}", page);
+    assert_eq!(page["nextOffset"], 10, "{}", page);
+
+    tools_handler
+        .handle_tool("debugger_disconnect", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+}
+
+/// Delve doesn't advertise supportsRestartRequest: debugger_restart
+/// relaunches, and the new session keeps the breakpoints and goOptions
+/// (Delve builds to the same output again)
+#[tokio::test(flavor = "multi_thread")]
+#[ignore]
+async fn test_go_restart_keeps_breakpoints_and_options() {
+    use tokio::time::{timeout, Duration};
+
+    let go_ok = Command::new("go")
+        .arg("version")
+        .output()
+        .is_ok_and(|o| o.status.success());
+    let dlv_ok = Command::new("dlv")
+        .arg("version")
+        .output()
+        .is_ok_and(|o| o.status.success());
+    if !go_ok || !dlv_ok {
+        println!("⚠️  Skipping restart test: go or dlv not installed");
+        return;
+    }
+
+    let fizzbuzz = PathBuf::from(env!("CARGO_MANIFEST_DIR")).join("tests/fixtures/fizzbuzz.go");
+    let fizzbuzz_str = fizzbuzz.to_string_lossy().to_string();
+    let build_dir = TempDir::new().unwrap();
+    let output = build_dir.path().join("fizzbuzz.debug");
+    let session_manager = Arc::new(RwLock::new(SessionManager::new()));
+    let tools_handler = ToolsHandler::new(Arc::clone(&session_manager));
+    let wait = |session_id: String| {
+        let tools_handler = &tools_handler;
+        async move {
+            timeout(
+                Duration::from_secs(30),
+                tools_handler.handle_tool(
+                    "debugger_wait_for_stop",
+                    json!({"sessionId": session_id, "timeoutMs": 25000}),
+                ),
+            )
+            .await
+            .expect("debugger_wait_for_stop hung")
+            .expect("debugger_wait_for_stop failed")
+        }
+    };
+
+    let start = tools_handler
+        .handle_tool(
+            "debugger_start",
+            json!({
+                "language": "go",
+                "program": fizzbuzz_str,
+                "stopOnEntry": false,
+                "goOptions": {"output": output.to_string_lossy()}
+            }),
+        )
+        .await
+        .expect("debugger_start should succeed");
+    let session_id = start["sessionId"].as_str().unwrap().to_string();
+    tokio::time::sleep(Duration::from_millis(100)).await;
+    tools_handler
+        .handle_tool(
+            "debugger_set_breakpoint",
+            json!({"sessionId": session_id, "sourcePath": fizzbuzz_str, "line": 13}),
+        )
+        .await
+        .expect("breakpoint should be accepted");
+    let stop = wait(session_id.clone()).await;
+    assert_eq!(stop["reason"], "breakpoint", "{}", stop);
+
+    let restart = tools_handler
+        .handle_tool("debugger_restart", json!({"sessionId": session_id}))
+        .await
+        .expect("debugger_restart failed");
+    assert_eq!(restart["method"], "relaunch", "{}", restart);
+    assert_eq!(restart["previousSessionId"], session_id.as_str());
+    assert!(restart.get("failedBreakpoints").is_none(), "{}", restart);
+    let new_id = restart["sessionId"].as_str().unwrap().to_string();
+
+    let stop = wait(new_id.clone()).await;
+    assert_eq!(stop["reason"], "breakpoint", "{}", stop);
+    assert_eq!(stop["hitBreakpoints"][0]["line"], 13, "{}", stop);
+    assert!(
+        output.exists(),
+        "the relaunch should build to goOptions.output"
+    );
+
+    tools_handler
+        .handle_tool("debugger_disconnect", json!({"sessionId": new_id}))
+        .await
+        .unwrap();
+}
+
+/// With followChildren, a breakpoint in the helper process the fixture
+/// execs is hit, and reported by the same session as the parent's
+#[tokio::test(flavor = "multi_thread")]
+#[ignore]
+async fn test_go_follow_children_stops_in_the_child() {
+    use tokio::time::{timeout, Duration};
+
+    let go_ok = Command::new("go")
+        .arg("version")
+        .output()
+        .is_ok_and(|o| o.status.success());
+    let dlv_ok = Command::new("dlv")
+        .arg("version")
+        .output()
+        .is_ok_and(|o| o.status.success());
+    if !go_ok || !dlv_ok || !cfg!(target_os = "linux") {
+        println!("⚠️  Skipping followChildren test: go or dlv not installed, or not Linux");
+        return;
+    }
+
+    let fixture = PathBuf::from(env!("CARGO_MANIFEST_DIR")).join("tests/fixtures/go/follow_exec");
+    let main_go = fixture.join("main.go").to_string_lossy().to_string();
+    let session_manager = Arc::new(RwLock::new(SessionManager::new()));
+    let tools_handler = ToolsHandler::new(Arc::clone(&session_manager));
+    let wait = |session_id: String| {
+        let tools_handler = &tools_handler;
+        async move {
+            timeout(
+                Duration::from_secs(40),
+                tools_handler.handle_tool(
+                    "debugger_wait_for_stop",
+                    json!({"sessionId": session_id, "timeoutMs": 35000}),
+                ),
+            )
+            .await
+            .expect("debugger_wait_for_stop hung")
+            .expect("debugger_wait_for_stop failed")
+        }
+    };
+
+    let start = match tools_handler
+        .handle_tool(
+            "debugger_start",
+            json!({
+                "language": "go",
+                "program": fixture.to_string_lossy(),
+                "stopOnEntry": false,
+                "goOptions": {"followChildren": true}
+            }),
+        )
+        .await
+    {
+        Ok(start) => start,
+        Err(e) if e.to_string().contains("needs Delve") => {
+            println!("⚠️  Skipping followChildren test: {}", e);
+            return;
+        }
+        Err(e) => panic!("debugger_start failed: {}", e),
+    };
+    let session_id = start["sessionId"].as_str().unwrap().to_string();
+
+    tokio::time::sleep(Duration::from_millis(100)).await;
+    for line in [16, 35] {
+        tools_handler
+            .handle_tool(
+                "debugger_set_breakpoint",
+                json!({"sessionId": session_id, "sourcePath": main_go, "line": line}),
+            )
+            .await
+            .expect("breakpoint should be accepted");
+    }
+
+    // In the helper process first
+    let stop = wait(session_id.clone()).await;
+    assert_eq!(stop["reason"], "breakpoint", "{}", stop);
+    assert_eq!(stop["hitBreakpoints"][0]["line"], 16, "{}", stop);
+    let state = tools_handler
+        .handle_tool("debugger_session_state", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+    assert!(
+        state["childProcesses"]
+            .as_array()
+            .is_some_and(|children| !children.is_empty()),
+        "{}",
+        state
+    );
+
+    // Clearing the child's breakpoint lets it finish; the parent then stops
+    tools_handler
+        .handle_tool(
+            "debugger_set_breakpoint",
+            json!({"sessionId": session_id, "sourcePath": main_go, "line": 16, "remove": true}),
+        )
+        .await
+        .expect("breakpoint should be removed");
+    tools_handler
+        .handle_tool("debugger_continue", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+    let stop = wait(session_id.clone()).await;
+    assert_eq!(stop["hitBreakpoints"][0]["line"], 35, "{}", stop);
+
+    tools_handler
+        .handle_tool("debugger_disconnect", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+}
+
+/// Breakpoints on a declaration and on the end of a block: the stop is
+/// attributed to the requested line, a move is explained, and the
+/// breakpoint can be removed by the line it is bound to
+#[tokio::test(flavor = "multi_thread")]
+#[ignore]
+async fn test_go_moved_breakpoint_explained() {
+    use tokio::time::{timeout, Duration};
+
+    // The declaration of fizzbuzz, and the closing brace of main's loop
+    let lines = [12, 31];
+    let go_ok = Command::new("go")
+        .arg("version")
+        .output()
+        .is_ok_and(|o| o.status.success());
+    let dlv_ok = Command::new("dlv")
+        .arg("version")
+        .output()
+        .is_ok_and(|o| o.status.success());
+    if !go_ok || !dlv_ok {
+        println!("⚠️  Skipping moved breakpoint test: go or dlv not installed");
+        return;
+    }
+
+    let source = PathBuf::from(env!("CARGO_MANIFEST_DIR"))
+        .join("tests/fixtures/fizzbuzz.go")
+        .to_string_lossy()
+        .to_string();
+
+    let session_manager = Arc::new(RwLock::new(SessionManager::new()));
+    let tools_handler = ToolsHandler::new(Arc::clone(&session_manager));
+    let wait = |session_id: String| {
+        let tools_handler = &tools_handler;
+        async move {
+            timeout(
+                Duration::from_secs(40),
+                tools_handler.handle_tool(
+                    "debugger_wait_for_stop",
+                    json!({"sessionId": session_id, "timeoutMs": 35000}),
+                ),
+            )
+            .await
+            .expect("debugger_wait_for_stop hung")
+            .expect("debugger_wait_for_stop failed")
+        }
+    };
+
+    let start = tools_handler
+        .handle_tool(
+            "debugger_start",
+            json!({
+                "language": "go",
+                "program": source,
+                "stopOnEntry": false
+            }),
+        )
+        .await
+        .expect("debugger_start failed");
+    let session_id = start["sessionId"].as_str().unwrap().to_string();
+    // Set while the launch is pending, so they go out before configurationDone
+    tokio::time::sleep(Duration::from_millis(100)).await;
+
+    for line in lines {
+        let result = tools_handler
+            .handle_tool(
+                "debugger_set_breakpoint",
+                json!({"sessionId": session_id, "sourcePath": source, "line": line}),
+            )
+            .await
+            .expect("debugger_set_breakpoint failed");
+        // Where the response already knows the line moved, it says why
+        if let Some(verified_line) = result["verifiedLine"].as_i64() {
+            assert_ne!(verified_line, line, "{}", result);
+            assert!(result["moveExplanation"].is_string(), "{}", result);
+        }
+    }
+
+    // Stopped on one of them, attributed to the line it was requested on
+    let stop = wait(session_id.clone()).await;
+    assert_eq!(stop["reason"], "breakpoint", "{}", stop);
+    let hit = &stop["hitBreakpoints"][0];
+    let requested = hit["line"]
+        .as_i64()
+        .expect("the stop should be attributed to a breakpoint");
+    assert!(lines.contains(&requested), "{}", stop);
+    // Where the debugger moved it, the hit says where to and why
+    let bound = match hit["verifiedLine"].as_i64() {
+        Some(verified_line) => {
+            assert_ne!(verified_line, requested, "{}", stop);
+            let explanation = hit["moveExplanation"].as_str().unwrap_or_default();
+            assert!(
+                explanation.contains(&format!("line {}", requested)),
+                "{}",
+                stop
+            );
+            verified_line
+        }
+        None => requested,
+    };
+
+    // Removable by the line it is bound to too
+    let removed = tools_handler
+        .handle_tool(
+            "debugger_set_breakpoint",
+            json!({"sessionId": session_id, "sourcePath": source, "line": bound, "remove": true}),
+        )
+        .await
+        .expect("the breakpoint should be removable by its bound line");
+    assert_eq!(removed["removed"], 1, "{}", removed);
+
+    tools_handler
+        .handle_tool("debugger_disconnect", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+}
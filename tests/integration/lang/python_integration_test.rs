@@ -552,3 +552,794 @@ Create mcp_protocol_log.md documenting all interactions."#,
 
     println!("\n🎉 Python Claude Code integration test completed!");
 }
+
+/// Assignments made in a REPL persist across its inputs, and the REPL closes
+/// when the program resumes
+#[tokio::test(flavor = "multi_thread")]
+#[ignore] // Needs debugpy: cargo test --test python_integration_test -- --ignored
+async fn test_repl_assignments_persist() {
+    use tokio::time::{timeout, Duration};
+
+    let debugpy = Command::new("python3")
+        .args(["-c", "import debugpy"])
+        .output();
+    if !debugpy.is_ok_and(|output| output.status.success()) {
+        println!("⚠️  Skipping REPL test: debugpy not installed");
+        return;
+    }
+
+    let session_manager = Arc::new(RwLock::new(SessionManager::new()));
+    let tools_handler = ToolsHandler::new(Arc::clone(&session_manager));
+    let fizzbuzz = PathBuf::from(env!("CARGO_MANIFEST_DIR")).join("tests/fixtures/fizzbuzz.py");
+
+    let start = tools_handler
+        .handle_tool(
+            "debugger_start",
+            json!({
+                "language": "python",
+                "program": fizzbuzz.to_string_lossy(),
+                "stopOnEntry": true
+            }),
+        )
+        .await
+        .expect("debugger_start failed");
+    let session_id = start["sessionId"].as_str().unwrap().to_string();
+
+    timeout(
+        Duration::from_secs(20),
+        tools_handler.handle_tool(
+            "debugger_wait_for_stop",
+            json!({"sessionId": session_id, "timeoutMs": 15000}),
+        ),
+    )
+    .await
+    .expect("no entry stop")
+    .expect("debugger_wait_for_stop failed");
+
+    let repl = tools_handler
+        .handle_tool("debugger_repl_open", json!({"sessionId": session_id}))
+        .await
+        .expect("debugger_repl_open failed");
+    let repl_id = repl["replId"].as_str().unwrap().to_string();
+
+    let eval = |input: &str| {
+        tools_handler.handle_tool(
+            "debugger_repl_eval",
+            json!({"replId": repl_id, "input": input}),
+        )
+    };
+    eval("x = 41").await.expect("assignment failed");
+    let sum = eval("x + 1").await.expect("reading x failed");
+    assert_eq!(sum["output"], "42");
+
+    let history = tools_handler
+        .handle_tool("debugger_repl_history", json!({"replId": repl_id}))
+        .await
+        .unwrap();
+    assert_eq!(history["open"], true);
+    assert_eq!(history["history"].as_array().unwrap().len(), 2);
+    assert_eq!(history["history"][1]["input"], "x + 1");
+
+    tools_handler
+        .handle_tool("debugger_continue", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+    let closed = eval("x").await.unwrap_err();
+    assert!(
+        closed.to_string().contains("debugger_repl_open"),
+        "{}",
+        closed
+    );
+
+    let _ = tools_handler
+        .handle_tool("debugger_disconnect", json!({"sessionId": session_id}))
+        .await;
+}
+
+/// Continuing past the loop's breakpoint 36 times stops in the 37th iteration
+#[tokio::test(flavor = "multi_thread")]
+#[ignore] // Needs debugpy: cargo test --test python_integration_test -- --ignored
+async fn test_continue_past_fizzbuzz_loop() {
+    use tokio::time::{timeout, Duration};
+
+    let debugpy = Command::new("python3")
+        .args(["-c", "import debugpy"])
+        .output();
+    if !debugpy.is_ok_and(|output| output.status.success()) {
+        println!("⚠️  Skipping continue-past test: debugpy not installed");
+        return;
+    }
+
+    let session_manager = Arc::new(RwLock::new(SessionManager::new()));
+    let tools_handler = ToolsHandler::new(Arc::clone(&session_manager));
+    let fizzbuzz = PathBuf::from(env!("CARGO_MANIFEST_DIR")).join("tests/fixtures/fizzbuzz.py");
+
+    let start = tools_handler
+        .handle_tool(
+            "debugger_start",
+            json!({
+                "language": "python",
+                "program": fizzbuzz.to_string_lossy(),
+                "stopOnEntry": true
+            }),
+        )
+        .await
+        .expect("debugger_start failed");
+    let session_id = start["sessionId"].as_str().unwrap().to_string();
+
+    timeout(
+        Duration::from_secs(20),
+        tools_handler.handle_tool(
+            "debugger_wait_for_stop",
+            json!({"sessionId": session_id, "timeoutMs": 15000}),
+        ),
+    )
+    .await
+    .expect("no entry stop")
+    .expect("debugger_wait_for_stop failed");
+
+    // `result = fizzbuzz(i)`, once per iteration
+    tools_handler
+        .handle_tool(
+            "debugger_set_breakpoint",
+            json!({"sessionId": session_id, "sourcePath": fizzbuzz.to_string_lossy(), "line": 32}),
+        )
+        .await
+        .expect("debugger_set_breakpoint failed");
+    let list = tools_handler
+        .handle_tool(
+            "debugger_list_breakpoints",
+            json!({"sessionId": session_id}),
+        )
+        .await
+        .unwrap();
+    let breakpoint_id = list["breakpoints"][0]["id"]
+        .as_i64()
+        .expect("no breakpoint id");
+
+    let past = timeout(
+        Duration::from_secs(40),
+        tools_handler.handle_tool(
+            "debugger_continue_past",
+            json!({
+                "sessionId": session_id,
+                "breakpointId": breakpoint_id,
+                "count": 37,
+                "timeoutMs": 30000
+            }),
+        ),
+    )
+    .await
+    .expect("debugger_continue_past hung")
+    .expect("debugger_continue_past failed");
+    assert_eq!(past["status"], "reached", "{}", past);
+    assert_eq!(past["skipped"], 36);
+
+    let stack = tools_handler
+        .handle_tool("debugger_stack_trace", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+    let frame_id = stack["stackFrames"][0]["id"].as_i64().unwrap();
+    let i = tools_handler
+        .handle_tool(
+            "debugger_evaluate",
+            json!({"sessionId": session_id, "expression": "i", "frameId": frame_id}),
+        )
+        .await
+        .unwrap();
+    assert_eq!(i["result"], "37");
+
+    let _ = tools_handler
+        .handle_tool("debugger_disconnect", json!({"sessionId": session_id}))
+        .await;
+}
+
+/// debugpy reports a call's return value both when stepping over the call
+/// and when stepping out of the called function
+#[tokio::test(flavor = "multi_thread")]
+#[ignore] // Needs debugpy: cargo test --test python_integration_test -- --ignored
+async fn test_step_return_values() {
+    use tokio::time::{timeout, Duration};
+
+    let debugpy = Command::new("python3")
+        .args(["-c", "import debugpy"])
+        .output();
+    if !debugpy.is_ok_and(|output| output.status.success()) {
+        println!("⚠️  Skipping return value test: debugpy not installed");
+        return;
+    }
+
+    let session_manager = Arc::new(RwLock::new(SessionManager::new()));
+    let tools_handler = ToolsHandler::new(Arc::clone(&session_manager));
+    let fizzbuzz = PathBuf::from(env!("CARGO_MANIFEST_DIR")).join("tests/fixtures/fizzbuzz.py");
+
+    let start = tools_handler
+        .handle_tool(
+            "debugger_start",
+            json!({
+                "language": "python",
+                "program": fizzbuzz.to_string_lossy(),
+                "stopOnEntry": true
+            }),
+        )
+        .await
+        .expect("debugger_start failed");
+    let session_id = start["sessionId"].as_str().unwrap().to_string();
+    let wait = |session_id: String| {
+        let tools_handler = &tools_handler;
+        async move {
+            timeout(
+                Duration::from_secs(20),
+                tools_handler.handle_tool(
+                    "debugger_wait_for_stop",
+                    json!({"sessionId": session_id, "timeoutMs": 15000}),
+                ),
+            )
+            .await
+            .expect("debugger_wait_for_stop hung")
+            .expect("debugger_wait_for_stop failed")
+        }
+    };
+    wait(session_id.clone()).await;
+
+    // `result = fizzbuzz(i)`: stepping over it reports fizzbuzz(1)
+    tools_handler
+        .handle_tool(
+            "debugger_set_breakpoint",
+            json!({"sessionId": session_id, "sourcePath": fizzbuzz.to_string_lossy(), "line": 32}),
+        )
+        .await
+        .expect("debugger_set_breakpoint failed");
+    tools_handler
+        .handle_tool("debugger_continue", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+    wait(session_id.clone()).await;
+    let over = tools_handler
+        .handle_tool("debugger_step_over", json!({"sessionId": session_id}))
+        .await
+        .expect("debugger_step_over failed");
+    assert_eq!(over["status"], "stopped", "{}", over);
+    assert_eq!(over["returnValue"], "'1'", "{}", over);
+    assert_eq!(
+        over["returnValues"][0]["name"], "(return) fizzbuzz",
+        "{}",
+        over
+    );
+
+    // Inside fizzbuzz(2): stepping out reports its value
+    tools_handler
+        .handle_tool(
+            "debugger_set_breakpoint",
+            json!({"sessionId": session_id, "sourcePath": fizzbuzz.to_string_lossy(), "line": 18}),
+        )
+        .await
+        .expect("debugger_set_breakpoint failed");
+    for _ in 0..2 {
+        // Line 32 of the next iteration, then line 18 inside the call
+        tools_handler
+            .handle_tool("debugger_continue", json!({"sessionId": session_id}))
+            .await
+            .unwrap();
+        wait(session_id.clone()).await;
+    }
+    let out = tools_handler
+        .handle_tool("debugger_step_out", json!({"sessionId": session_id}))
+        .await
+        .expect("debugger_step_out failed");
+    assert_eq!(out["status"], "stopped", "{}", out);
+    assert_eq!(out["returnValue"], "'2'", "{}", out);
+    assert_eq!(
+        out["returnValues"][0]["name"], "(return) fizzbuzz",
+        "{}",
+        out
+    );
+
+    let _ = tools_handler
+        .handle_tool("debugger_disconnect", json!({"sessionId": session_id}))
+        .await;
+}
+
+/// debugpy doesn't advertise supportsRestartRequest: debugger_restart
+/// relaunches, and the new session keeps the breakpoints and the
+/// launch's environment
+#[tokio::test(flavor = "multi_thread")]
+#[ignore]
+async fn test_python_restart_keeps_breakpoints_and_env() {
+    use tokio::time::{timeout, Duration};
+
+    let debugpy = Command::new("python3")
+        .args(["-c", "import debugpy"])
+        .output();
+    if !debugpy.is_ok_and(|output| output.status.success()) {
+        println!("⚠️  Skipping restart test: debugpy not installed");
+        return;
+    }
+
+    let session_manager = Arc::new(RwLock::new(SessionManager::new()));
+    let tools_handler = ToolsHandler::new(Arc::clone(&session_manager));
+    let fizzbuzz = PathBuf::from(env!("CARGO_MANIFEST_DIR")).join("tests/fixtures/fizzbuzz.py");
+    let wait = |session_id: String| {
+        let tools_handler = &tools_handler;
+        async move {
+            timeout(
+                Duration::from_secs(20),
+                tools_handler.handle_tool(
+                    "debugger_wait_for_stop",
+                    json!({"sessionId": session_id, "timeoutMs": 15000}),
+                ),
+            )
+            .await
+            .expect("debugger_wait_for_stop hung")
+            .expect("debugger_wait_for_stop failed")
+        }
+    };
+
+    let start = tools_handler
+        .handle_tool(
+            "debugger_start",
+            json!({
+                "language": "python",
+                "program": fizzbuzz.to_string_lossy(),
+                "stopOnEntry": true,
+                "env": {"RESTART_MARK": "kept"}
+            }),
+        )
+        .await
+        .expect("debugger_start failed");
+    let session_id = start["sessionId"].as_str().unwrap().to_string();
+    wait(session_id.clone()).await;
+    tools_handler
+        .handle_tool(
+            "debugger_set_breakpoint",
+            json!({"sessionId": session_id, "sourcePath": fizzbuzz.to_string_lossy(), "line": 32}),
+        )
+        .await
+        .expect("debugger_set_breakpoint failed");
+
+    let restart = tools_handler
+        .handle_tool("debugger_restart", json!({"sessionId": session_id}))
+        .await
+        .expect("debugger_restart failed");
+    assert_eq!(restart["method"], "relaunch", "{}", restart);
+    assert_eq!(restart["previousSessionId"], session_id.as_str());
+    assert!(restart.get("failedBreakpoints").is_none(), "{}", restart);
+    let new_id = restart["sessionId"].as_str().unwrap().to_string();
+
+    // Stopped at entry again, then at the carried over breakpoint
+    wait(new_id.clone()).await;
+    tools_handler
+        .handle_tool("debugger_continue", json!({"sessionId": new_id}))
+        .await
+        .unwrap();
+    let stop = wait(new_id.clone()).await;
+    assert_eq!(stop["reason"], "breakpoint", "{}", stop);
+    assert_eq!(stop["hitBreakpoints"][0]["line"], 32, "{}", stop);
+
+    let mark = tools_handler
+        .handle_tool(
+            "debugger_evaluate",
+            json!({"sessionId": new_id, "expression": "__import__('os').environ.get('RESTART_MARK')"}),
+        )
+        .await
+        .expect("debugger_evaluate failed");
+    assert_eq!(mark["result"], "'kept'", "{}", mark);
+
+    tools_handler
+        .handle_tool("debugger_disconnect", json!({"sessionId": new_id}))
+        .await
+        .unwrap();
+}
+
+/// Output that isn't UTF-8 reaches the server already replaced: it is
+/// reported as lossy, and base64 returns the UTF-8 of that text rather
+/// than the bytes the program wrote
+#[tokio::test(flavor = "multi_thread")]
+#[ignore]
+async fn test_python_invalid_utf8_output() {
+    use debugger_mcp::debug::output_log;
+    use tokio::time::{timeout, Duration};
+
+    let debugpy = Command::new("python3")
+        .args(["-c", "import debugpy"])
+        .output();
+    if !debugpy.is_ok_and(|output| output.status.success()) {
+        println!("⚠️  Skipping invalid UTF-8 test: debugpy not installed");
+        return;
+    }
+
+    let session_manager = Arc::new(RwLock::new(SessionManager::new()));
+    let tools_handler = ToolsHandler::new(Arc::clone(&session_manager));
+    let fixture = PathBuf::from(env!("CARGO_MANIFEST_DIR")).join("tests/fixtures/invalid_utf8.py");
+    let wait = |session_id: String| {
+        let tools_handler = &tools_handler;
+        async move {
+            timeout(
+                Duration::from_secs(20),
+                tools_handler.handle_tool(
+                    "debugger_wait_for_stop",
+                    json!({"sessionId": session_id, "timeoutMs": 15000}),
+                ),
+            )
+            .await
+            .expect("debugger_wait_for_stop hung")
+            .expect("debugger_wait_for_stop failed")
+        }
+    };
+
+    let start = tools_handler
+        .handle_tool(
+            "debugger_start",
+            json!({
+                "language": "python",
+                "program": fixture.to_string_lossy(),
+                "stopOnEntry": true
+            }),
+        )
+        .await
+        .expect("debugger_start failed");
+    let session_id = start["sessionId"].as_str().unwrap().to_string();
+    wait(session_id.clone()).await;
+    tools_handler
+        .handle_tool(
+            "debugger_set_breakpoint",
+            json!({"sessionId": session_id, "sourcePath": fixture.to_string_lossy(), "line": 15}),
+        )
+        .await
+        .expect("debugger_set_breakpoint failed");
+    tools_handler
+        .handle_tool("debugger_continue", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+    let stop = wait(session_id.clone()).await;
+    assert_eq!(stop["hitBreakpoints"][0]["line"], 15, "{}", stop);
+
+    let text = tools_handler
+        .handle_tool("debugger_get_output", json!({"sessionId": session_id}))
+        .await
+        .expect("debugger_get_output failed");
+    let output = text["output"].as_str().unwrap();
+    assert!(output.contains(" binary"), "{}", text);
+    assert!(output.contains('\u{FFFD}'), "{}", text);
+    assert_eq!(text["encodingLossy"], true, "{}", text);
+
+    let encoded = tools_handler
+        .handle_tool(
+            "debugger_get_output",
+            json!({"sessionId": session_id, "base64": true}),
+        )
+        .await
+        .expect("debugger_get_output failed");
+    assert_eq!(
+        encoded["outputBase64"],
+        output_log::base64(output.as_bytes()),
+        "{}",
+        encoded
+    );
+    assert_ne!(
+        encoded["outputBase64"],
+        output_log::base64(b"caf\xe9 \xff\xfe binary\n")
+    );
+
+    tools_handler
+        .handle_tool("debugger_disconnect", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+}
+
+/// Stopped in one coroutine, the other asyncio tasks are listed with where
+/// they are suspended
+#[tokio::test(flavor = "multi_thread")]
+#[ignore]
+async fn test_python_list_async_tasks() {
+    use tokio::time::{timeout, Duration};
+
+    let debugpy = Command::new("python3")
+        .args(["-c", "import debugpy"])
+        .output();
+    if !debugpy.is_ok_and(|output| output.status.success()) {
+        println!("⚠️  Skipping async tasks test: debugpy not installed");
+        return;
+    }
+
+    let session_manager = Arc::new(RwLock::new(SessionManager::new()));
+    let tools_handler = ToolsHandler::new(Arc::clone(&session_manager));
+    let fixture = PathBuf::from(env!("CARGO_MANIFEST_DIR")).join("tests/fixtures/async_tasks.py");
+    let wait = |session_id: String| {
+        let tools_handler = &tools_handler;
+        async move {
+            timeout(
+                Duration::from_secs(20),
+                tools_handler.handle_tool(
+                    "debugger_wait_for_stop",
+                    json!({"sessionId": session_id, "timeoutMs": 15000}),
+                ),
+            )
+            .await
+            .expect("debugger_wait_for_stop hung")
+            .expect("debugger_wait_for_stop failed")
+        }
+    };
+
+    let start = tools_handler
+        .handle_tool(
+            "debugger_start",
+            json!({
+                "language": "python",
+                "program": fixture.to_string_lossy(),
+                "stopOnEntry": true
+            }),
+        )
+        .await
+        .expect("debugger_start failed");
+    let session_id = start["sessionId"].as_str().unwrap().to_string();
+    wait(session_id.clone()).await;
+
+    // Not in async code yet
+    let none = tools_handler
+        .handle_tool(
+            "debugger_list_async_tasks",
+            json!({"sessionId": session_id}),
+        )
+        .await
+        .expect("debugger_list_async_tasks failed");
+    assert_eq!(none["eventLoop"], false, "{}", none);
+
+    tools_handler
+        .handle_tool(
+            "debugger_set_breakpoint",
+            json!({"sessionId": session_id, "sourcePath": fixture.to_string_lossy(), "line": 18}),
+        )
+        .await
+        .expect("debugger_set_breakpoint failed");
+    tools_handler
+        .handle_tool("debugger_continue", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+    let stop = wait(session_id.clone()).await;
+    assert_eq!(stop["hitBreakpoints"][0]["line"], 18, "{}", stop);
+
+    let listed = tools_handler
+        .handle_tool(
+            "debugger_list_async_tasks",
+            json!({"sessionId": session_id}),
+        )
+        .await
+        .expect("debugger_list_async_tasks failed");
+    assert_eq!(listed["eventLoop"], true, "{}", listed);
+    let tasks = listed["tasks"].as_array().unwrap();
+    let task = |name: &str| {
+        tasks
+            .iter()
+            .find(|task| task["name"] == name)
+            .unwrap_or_else(|| panic!("no task {}: {}", name, listed))
+    };
+    assert_eq!(task("worker")["state"], "running", "{}", listed);
+    assert_eq!(task("worker")["line"], 18, "{}", listed);
+    assert_eq!(task("sleeper")["state"], "pending", "{}", listed);
+    assert_eq!(task("sleeper")["function"], "sleeper", "{}", listed);
+    assert_eq!(task("sleeper")["line"], 10, "{}", listed);
+    assert_eq!(task("waiter")["line"], 14, "{}", listed);
+    assert!(task("waiter")["waitingOn"].is_string(), "{}", listed);
+
+    tools_handler
+        .handle_tool("debugger_disconnect", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+}
+
+/// Stopped on RecursionError, the stack is paged and the descend/visit
+/// cycle is collapsed into a marker
+#[tokio::test(flavor = "multi_thread")]
+#[ignore]
+async fn test_python_deep_recursion_stack() {
+    use tokio::time::{timeout, Duration};
+
+    let debugpy = Command::new("python3")
+        .args(["-c", "import debugpy"])
+        .output();
+    if !debugpy.is_ok_and(|output| output.status.success()) {
+        println!("⚠️  Skipping deep recursion test: debugpy not installed");
+        return;
+    }
+
+    let session_manager = Arc::new(RwLock::new(SessionManager::new()));
+    let tools_handler = ToolsHandler::new(Arc::clone(&session_manager));
+    let fixture =
+        PathBuf::from(env!("CARGO_MANIFEST_DIR")).join("tests/fixtures/deep_recursion.py");
+    let wait = |session_id: String| {
+        let tools_handler = &tools_handler;
+        async move {
+            timeout(
+                Duration::from_secs(30),
+                tools_handler.handle_tool(
+                    "debugger_wait_for_stop",
+                    json!({"sessionId": session_id, "timeoutMs": 25000}),
+                ),
+            )
+            .await
+            .expect("debugger_wait_for_stop hung")
+            .expect("debugger_wait_for_stop failed")
+        }
+    };
+
+    let start = tools_handler
+        .handle_tool(
+            "debugger_start",
+            json!({
+                "language": "python",
+                "program": fixture.to_string_lossy(),
+                "stopOnEntry": true
+            }),
+        )
+        .await
+        .expect("debugger_start failed");
+    let session_id = start["sessionId"].as_str().unwrap().to_string();
+    wait(session_id.clone()).await;
+    tools_handler
+        .handle_tool(
+            "debugger_set_exception_breakpoints",
+            json!({"sessionId": session_id, "modes": ["raised"]}),
+        )
+        .await
+        .expect("debugger_set_exception_breakpoints failed");
+    tools_handler
+        .handle_tool("debugger_continue", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+    let stop = wait(session_id.clone()).await;
+    assert_eq!(stop["reason"], "exception", "{}", stop);
+
+    let first = tools_handler
+        .handle_tool("debugger_stack_trace", json!({"sessionId": session_id}))
+        .await
+        .expect("debugger_stack_trace failed");
+    assert_eq!(first["moreFrames"], true, "{}", first);
+    let entries = first["stackFrames"].as_array().unwrap();
+    let marker = entries
+        .iter()
+        .find(|entry| entry.get("repeated").is_some())
+        .unwrap_or_else(|| panic!("no repeated marker: {}", first));
+    let functions = marker["functions"].as_array().unwrap();
+    assert_eq!(functions.len(), 2, "{}", marker);
+    assert!(functions.contains(&json!("descend")), "{}", marker);
+    assert!(functions.contains(&json!("visit")), "{}", marker);
+    assert!(marker.get("id").is_none(), "{}", marker);
+
+    // The bottom of the stack, paged down to, ends in main
+    let total = first["totalFrames"].as_i64().expect("totalFrames");
+    let last = tools_handler
+        .handle_tool(
+            "debugger_stack_trace",
+            json!({"sessionId": session_id, "startFrame": total - 5, "levels": 5}),
+        )
+        .await
+        .expect("debugger_stack_trace failed");
+    assert_eq!(last["startFrame"], total - 5, "{}", last);
+    assert_eq!(last["moreFrames"], false, "{}", last);
+    let bottom: Vec<&str> = last["stackFrames"]
+        .as_array()
+        .unwrap()
+        .iter()
+        .filter_map(|frame| frame["name"].as_str())
+        .collect();
+    assert!(bottom.contains(&"main"), "{}", last);
+
+    tools_handler
+        .handle_tool("debugger_disconnect", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+}
+
+/// Breakpoints on a declaration and on the end of a block: the stop is
+/// attributed to the requested line, a move is explained, and the
+/// breakpoint can be removed by the line it is bound to
+#[tokio::test(flavor = "multi_thread")]
+#[ignore]
+async fn test_python_moved_breakpoint_explained() {
+    use tokio::time::{timeout, Duration};
+
+    // The closing quotes of fizzbuzz's docstring, and main's docstring
+    let lines = [17, 29];
+    let debugpy = Command::new("python3")
+        .args(["-c", "import debugpy"])
+        .output();
+    if !debugpy.is_ok_and(|output| output.status.success()) {
+        println!("⚠️  Skipping moved breakpoint test: debugpy not installed");
+        return;
+    }
+
+    let source = PathBuf::from(env!("CARGO_MANIFEST_DIR"))
+        .join("tests/fixtures/fizzbuzz.py")
+        .to_string_lossy()
+        .to_string();
+
+    let session_manager = Arc::new(RwLock::new(SessionManager::new()));
+    let tools_handler = ToolsHandler::new(Arc::clone(&session_manager));
+    let wait = |session_id: String| {
+        let tools_handler = &tools_handler;
+        async move {
+            timeout(
+                Duration::from_secs(40),
+                tools_handler.handle_tool(
+                    "debugger_wait_for_stop",
+                    json!({"sessionId": session_id, "timeoutMs": 35000}),
+                ),
+            )
+            .await
+            .expect("debugger_wait_for_stop hung")
+            .expect("debugger_wait_for_stop failed")
+        }
+    };
+
+    let start = tools_handler
+        .handle_tool(
+            "debugger_start",
+            json!({
+                "language": "python",
+                "program": source,
+                "stopOnEntry": true
+            }),
+        )
+        .await
+        .expect("debugger_start failed");
+    let session_id = start["sessionId"].as_str().unwrap().to_string();
+    wait(session_id.clone()).await;
+
+    for line in lines {
+        let result = tools_handler
+            .handle_tool(
+                "debugger_set_breakpoint",
+                json!({"sessionId": session_id, "sourcePath": source, "line": line}),
+            )
+            .await
+            .expect("debugger_set_breakpoint failed");
+        // Where the response already knows the line moved, it says why
+        if let Some(verified_line) = result["verifiedLine"].as_i64() {
+            assert_ne!(verified_line, line, "{}", result);
+            assert!(result["moveExplanation"].is_string(), "{}", result);
+        }
+    }
+    tools_handler
+        .handle_tool("debugger_continue", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+
+    // Stopped on one of them, attributed to the line it was requested on
+    let stop = wait(session_id.clone()).await;
+    assert_eq!(stop["reason"], "breakpoint", "{}", stop);
+    let hit = &stop["hitBreakpoints"][0];
+    let requested = hit["line"]
+        .as_i64()
+        .expect("the stop should be attributed to a breakpoint");
+    assert!(lines.contains(&requested), "{}", stop);
+    // Where the debugger moved it, the hit says where to and why
+    let bound = match hit["verifiedLine"].as_i64() {
+        Some(verified_line) => {
+            assert_ne!(verified_line, requested, "{}", stop);
+            let explanation = hit["moveExplanation"].as_str().unwrap_or_default();
+            assert!(
+                explanation.contains(&format!("line {}", requested)),
+                "{}",
+                stop
+            );
+            verified_line
+        }
+        None => requested,
+    };
+
+    // Removable by the line it is bound to too
+    let removed = tools_handler
+        .handle_tool(
+            "debugger_set_breakpoint",
+            json!({"sessionId": session_id, "sourcePath": source, "line": bound, "remove": true}),
+        )
+        .await
+        .expect("the breakpoint should be removable by its bound line");
+    assert_eq!(removed["removed"], 1, "{}", removed);
+
+    tools_handler
+        .handle_tool("debugger_disconnect", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+}
@@ -433,3 +433,408 @@ Create mcp_protocol_log.md documenting all interactions."#,
 
     println!("\n🎉 Ruby Claude Code integration test completed!");
 }
+
+/// rdbg doesn't advertise supportsRestartRequest: debugger_restart
+/// relaunches, and the new session keeps the breakpoints and the
+/// launch's environment
+#[tokio::test(flavor = "multi_thread")]
+#[ignore]
+async fn test_ruby_restart_keeps_breakpoints_and_env() {
+    use tokio::time::{timeout, Duration};
+
+    let rdbg_ok = Command::new("rdbg")
+        .arg("--version")
+        .output()
+        .is_ok_and(|o| o.status.success());
+    if !rdbg_ok {
+        println!("⚠️  Skipping restart test: rdbg not installed");
+        return;
+    }
+
+    let fizzbuzz = PathBuf::from(env!("CARGO_MANIFEST_DIR")).join("tests/fixtures/fizzbuzz.rb");
+    let fizzbuzz_str = fizzbuzz.to_string_lossy().to_string();
+    let session_manager = Arc::new(RwLock::new(SessionManager::new()));
+    let tools_handler = ToolsHandler::new(Arc::clone(&session_manager));
+    let wait = |session_id: String| {
+        let tools_handler = &tools_handler;
+        async move {
+            timeout(
+                Duration::from_secs(20),
+                tools_handler.handle_tool(
+                    "debugger_wait_for_stop",
+                    json!({"sessionId": session_id, "timeoutMs": 15000}),
+                ),
+            )
+            .await
+            .expect("debugger_wait_for_stop hung")
+            .expect("debugger_wait_for_stop failed")
+        }
+    };
+
+    let start = tools_handler
+        .handle_tool(
+            "debugger_start",
+            json!({
+                "language": "ruby",
+                "program": fizzbuzz_str,
+                "stopOnEntry": true,
+                "env": {"RESTART_MARK": "kept"}
+            }),
+        )
+        .await
+        .expect("debugger_start failed");
+    let session_id = start["sessionId"].as_str().unwrap().to_string();
+    wait(session_id.clone()).await;
+    tools_handler
+        .handle_tool(
+            "debugger_set_breakpoint",
+            json!({"sessionId": session_id, "sourcePath": fizzbuzz_str, "line": 18}),
+        )
+        .await
+        .expect("debugger_set_breakpoint failed");
+
+    let restart = tools_handler
+        .handle_tool("debugger_restart", json!({"sessionId": session_id}))
+        .await
+        .expect("debugger_restart failed");
+    assert_eq!(restart["method"], "relaunch", "{}", restart);
+    assert_eq!(restart["previousSessionId"], session_id.as_str());
+    assert!(restart.get("failedBreakpoints").is_none(), "{}", restart);
+    let new_id = restart["sessionId"].as_str().unwrap().to_string();
+
+    wait(new_id.clone()).await;
+    tools_handler
+        .handle_tool("debugger_continue", json!({"sessionId": new_id}))
+        .await
+        .unwrap();
+    let stop = wait(new_id.clone()).await;
+    assert_eq!(stop["reason"], "breakpoint", "{}", stop);
+
+    let mark = tools_handler
+        .handle_tool(
+            "debugger_evaluate",
+            json!({"sessionId": new_id, "expression": "ENV['RESTART_MARK']"}),
+        )
+        .await
+        .expect("debugger_evaluate failed");
+    assert_eq!(mark["result"], "\"kept\"", "{}", mark);
+
+    tools_handler
+        .handle_tool("debugger_disconnect", json!({"sessionId": new_id}))
+        .await
+        .unwrap();
+}
+
+/// Output that isn't UTF-8 reaches the server already replaced: it is
+/// reported as lossy, and base64 returns the UTF-8 of that text rather
+/// than the bytes the program wrote
+#[tokio::test(flavor = "multi_thread")]
+#[ignore]
+async fn test_ruby_invalid_utf8_output() {
+    use debugger_mcp::debug::output_log;
+    use tokio::time::{timeout, Duration};
+
+    let rdbg_ok = Command::new("rdbg")
+        .arg("--version")
+        .output()
+        .is_ok_and(|o| o.status.success());
+    if !rdbg_ok {
+        println!("⚠️  Skipping invalid UTF-8 test: rdbg not installed");
+        return;
+    }
+
+    let fixture = PathBuf::from(env!("CARGO_MANIFEST_DIR")).join("tests/fixtures/invalid_utf8.rb");
+    let fixture_str = fixture.to_string_lossy().to_string();
+    let session_manager = Arc::new(RwLock::new(SessionManager::new()));
+    let tools_handler = ToolsHandler::new(Arc::clone(&session_manager));
+    let wait = |session_id: String| {
+        let tools_handler = &tools_handler;
+        async move {
+            timeout(
+                Duration::from_secs(20),
+                tools_handler.handle_tool(
+                    "debugger_wait_for_stop",
+                    json!({"sessionId": session_id, "timeoutMs": 15000}),
+                ),
+            )
+            .await
+            .expect("debugger_wait_for_stop hung")
+            .expect("debugger_wait_for_stop failed")
+        }
+    };
+
+    let start = tools_handler
+        .handle_tool(
+            "debugger_start",
+            json!({
+                "language": "ruby",
+                "program": fixture_str,
+                "stopOnEntry": true
+            }),
+        )
+        .await
+        .expect("debugger_start failed");
+    let session_id = start["sessionId"].as_str().unwrap().to_string();
+    wait(session_id.clone()).await;
+    tools_handler
+        .handle_tool(
+            "debugger_set_breakpoint",
+            json!({"sessionId": session_id, "sourcePath": fixture_str, "line": 14}),
+        )
+        .await
+        .expect("debugger_set_breakpoint failed");
+    tools_handler
+        .handle_tool("debugger_continue", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+    let stop = wait(session_id.clone()).await;
+    assert_eq!(stop["hitBreakpoints"][0]["line"], 14, "{}", stop);
+
+    let text = tools_handler
+        .handle_tool("debugger_get_output", json!({"sessionId": session_id}))
+        .await
+        .expect("debugger_get_output failed");
+    let output = text["output"].as_str().unwrap();
+    assert!(output.contains(" binary"), "{}", text);
+    assert!(output.contains('\u{FFFD}'), "{}", text);
+    assert_eq!(text["encodingLossy"], true, "{}", text);
+
+    let encoded = tools_handler
+        .handle_tool(
+            "debugger_get_output",
+            json!({"sessionId": session_id, "base64": true}),
+        )
+        .await
+        .expect("debugger_get_output failed");
+    assert_eq!(
+        encoded["outputBase64"],
+        output_log::base64(output.as_bytes()),
+        "{}",
+        encoded
+    );
+    assert_ne!(
+        encoded["outputBase64"],
+        output_log::base64(b"caf\xe9 \xff\xfe binary\n")
+    );
+
+    tools_handler
+        .handle_tool("debugger_disconnect", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+}
+
+/// Exception breakpoints in raised mode stop on both raises: the rescued
+/// LookupFailed and the ArgumentError that ends the program
+#[tokio::test(flavor = "multi_thread")]
+#[ignore]
+async fn test_ruby_exception_breakpoints_stop_on_raise() {
+    use tokio::time::{timeout, Duration};
+
+    let rdbg_ok = Command::new("rdbg")
+        .arg("--version")
+        .output()
+        .is_ok_and(|o| o.status.success());
+    if !rdbg_ok {
+        println!("⚠️  Skipping exception breakpoints test: rdbg not installed");
+        return;
+    }
+
+    let fixture = PathBuf::from(env!("CARGO_MANIFEST_DIR")).join("tests/fixtures/exceptions.rb");
+    let session_manager = Arc::new(RwLock::new(SessionManager::new()));
+    let tools_handler = ToolsHandler::new(Arc::clone(&session_manager));
+    let wait = |session_id: String| {
+        let tools_handler = &tools_handler;
+        async move {
+            timeout(
+                Duration::from_secs(20),
+                tools_handler.handle_tool(
+                    "debugger_wait_for_stop",
+                    json!({"sessionId": session_id, "timeoutMs": 15000}),
+                ),
+            )
+            .await
+            .expect("debugger_wait_for_stop hung")
+            .expect("debugger_wait_for_stop failed")
+        }
+    };
+
+    let start = tools_handler
+        .handle_tool(
+            "debugger_start",
+            json!({
+                "language": "ruby",
+                "program": fixture.to_string_lossy(),
+                "stopOnEntry": true
+            }),
+        )
+        .await
+        .expect("debugger_start failed");
+    let session_id = start["sessionId"].as_str().unwrap().to_string();
+    wait(session_id.clone()).await;
+
+    // rdbg can't stop only on exceptions nothing rescues
+    let uncaught = tools_handler
+        .handle_tool(
+            "debugger_set_exception_breakpoints",
+            json!({"sessionId": session_id, "modes": ["uncaught"]}),
+        )
+        .await;
+    assert!(uncaught.is_err(), "{:?}", uncaught);
+    tools_handler
+        .handle_tool(
+            "debugger_set_exception_breakpoints",
+            json!({"sessionId": session_id, "modes": ["raised"]}),
+        )
+        .await
+        .expect("debugger_set_exception_breakpoints failed");
+
+    for (line, class, message) in [
+        (10, "LookupFailed", "no entry for timeout"),
+        (21, "ArgumentError", "port must be positive: 0"),
+    ] {
+        tools_handler
+            .handle_tool("debugger_continue", json!({"sessionId": session_id}))
+            .await
+            .unwrap();
+        let stop = wait(session_id.clone()).await;
+        assert_eq!(stop["reason"], "exception", "{}", stop);
+        assert!(
+            stop["exception"]["class"]
+                .as_str()
+                .is_some_and(|c| c.contains(class)),
+            "{}",
+            stop
+        );
+        assert!(
+            stop["exception"]["message"]
+                .as_str()
+                .is_some_and(|m| m.contains(message)),
+            "{}",
+            stop
+        );
+        let stack = tools_handler
+            .handle_tool("debugger_stack_trace", json!({"sessionId": session_id}))
+            .await
+            .expect("debugger_stack_trace failed");
+        assert_eq!(stack["stackFrames"][0]["line"], line, "{}", stack);
+    }
+
+    tools_handler
+        .handle_tool("debugger_disconnect", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+}
+
+/// Breakpoints on a declaration and on the end of a block: the stop is
+/// attributed to the requested line, a move is explained, and the
+/// breakpoint can be removed by the line it is bound to
+#[tokio::test(flavor = "multi_thread")]
+#[ignore]
+async fn test_ruby_moved_breakpoint_explained() {
+    use tokio::time::{timeout, Duration};
+
+    // The `end` of the if in fizzbuzz, and of the block in main
+    let lines = [13, 19];
+    let rdbg_ok = Command::new("rdbg")
+        .arg("--version")
+        .output()
+        .is_ok_and(|o| o.status.success());
+    if !rdbg_ok {
+        println!("⚠️  Skipping moved breakpoint test: rdbg not installed");
+        return;
+    }
+
+    let source = PathBuf::from(env!("CARGO_MANIFEST_DIR"))
+        .join("tests/fixtures/fizzbuzz.rb")
+        .to_string_lossy()
+        .to_string();
+
+    let session_manager = Arc::new(RwLock::new(SessionManager::new()));
+    let tools_handler = ToolsHandler::new(Arc::clone(&session_manager));
+    let wait = |session_id: String| {
+        let tools_handler = &tools_handler;
+        async move {
+            timeout(
+                Duration::from_secs(40),
+                tools_handler.handle_tool(
+                    "debugger_wait_for_stop",
+                    json!({"sessionId": session_id, "timeoutMs": 35000}),
+                ),
+            )
+            .await
+            .expect("debugger_wait_for_stop hung")
+            .expect("debugger_wait_for_stop failed")
+        }
+    };
+
+    let start = tools_handler
+        .handle_tool(
+            "debugger_start",
+            json!({
+                "language": "ruby",
+                "program": source,
+                "stopOnEntry": true
+            }),
+        )
+        .await
+        .expect("debugger_start failed");
+    let session_id = start["sessionId"].as_str().unwrap().to_string();
+    wait(session_id.clone()).await;
+
+    for line in lines {
+        let result = tools_handler
+            .handle_tool(
+                "debugger_set_breakpoint",
+                json!({"sessionId": session_id, "sourcePath": source, "line": line}),
+            )
+            .await
+            .expect("debugger_set_breakpoint failed");
+        // Where the response already knows the line moved, it says why
+        if let Some(verified_line) = result["verifiedLine"].as_i64() {
+            assert_ne!(verified_line, line, "{}", result);
+            assert!(result["moveExplanation"].is_string(), "{}", result);
+        }
+    }
+    tools_handler
+        .handle_tool("debugger_continue", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+
+    // Stopped on one of them, attributed to the line it was requested on
+    let stop = wait(session_id.clone()).await;
+    assert_eq!(stop["reason"], "breakpoint", "{}", stop);
+    let hit = &stop["hitBreakpoints"][0];
+    let requested = hit["line"]
+        .as_i64()
+        .expect("the stop should be attributed to a breakpoint");
+    assert!(lines.contains(&requested), "{}", stop);
+    // Where the debugger moved it, the hit says where to and why
+    let bound = match hit["verifiedLine"].as_i64() {
+        Some(verified_line) => {
+            assert_ne!(verified_line, requested, "{}", stop);
+            let explanation = hit["moveExplanation"].as_str().unwrap_or_default();
+            assert!(
+                explanation.contains(&format!("line {}", requested)),
+                "{}",
+                stop
+            );
+            verified_line
+        }
+        None => requested,
+    };
+
+    // Removable by the line it is bound to too
+    let removed = tools_handler
+        .handle_tool(
+            "debugger_set_breakpoint",
+            json!({"sessionId": session_id, "sourcePath": source, "line": bound, "remove": true}),
+        )
+        .await
+        .expect("the breakpoint should be removable by its bound line");
+    assert_eq!(removed["removed"], 1, "{}", removed);
+
+    tools_handler
+        .handle_tool("debugger_disconnect", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+}
@@ -581,3 +581,124 @@ Create mcp_protocol_log.md documenting all interactions."#,
 
     println!("\n🎉 Rust Claude Code integration test completed!");
 }
+
+/// Breakpoints on a declaration and on the end of a block: the stop is
+/// attributed to the requested line, a move is explained, and the
+/// breakpoint can be removed by the line it is bound to
+#[tokio::test(flavor = "multi_thread")]
+#[ignore]
+async fn test_rust_moved_breakpoint_explained() {
+    use tokio::time::{timeout, Duration};
+
+    // The declaration of fizzbuzz, and its closing brace
+    let lines = [4, 14];
+    let lldb_ok = Command::new("lldb")
+        .arg("--version")
+        .output()
+        .is_ok_and(|o| o.status.success());
+    if !lldb_ok {
+        println!("⚠️  Skipping moved breakpoint test: lldb not installed");
+        return;
+    }
+
+    let source = PathBuf::from(env!("CARGO_MANIFEST_DIR"))
+        .join("tests/fixtures/fizzbuzz.rs")
+        .to_string_lossy()
+        .to_string();
+    let binary = match compile_rust_fixture(&PathBuf::from(&source)) {
+        Ok(binary) => binary.to_string_lossy().to_string(),
+        Err(e) => {
+            println!("⚠️  Skipping moved breakpoint test: {}", e);
+            return;
+        }
+    };
+
+    let session_manager = Arc::new(RwLock::new(SessionManager::new()));
+    let tools_handler = ToolsHandler::new(Arc::clone(&session_manager));
+    let wait = |session_id: String| {
+        let tools_handler = &tools_handler;
+        async move {
+            timeout(
+                Duration::from_secs(40),
+                tools_handler.handle_tool(
+                    "debugger_wait_for_stop",
+                    json!({"sessionId": session_id, "timeoutMs": 35000}),
+                ),
+            )
+            .await
+            .expect("debugger_wait_for_stop hung")
+            .expect("debugger_wait_for_stop failed")
+        }
+    };
+
+    let start = tools_handler
+        .handle_tool(
+            "debugger_start",
+            json!({
+                "language": "rust",
+                "program": binary,
+                "stopOnEntry": true
+            }),
+        )
+        .await
+        .expect("debugger_start failed");
+    let session_id = start["sessionId"].as_str().unwrap().to_string();
+    wait(session_id.clone()).await;
+
+    for line in lines {
+        let result = tools_handler
+            .handle_tool(
+                "debugger_set_breakpoint",
+                json!({"sessionId": session_id, "sourcePath": source, "line": line}),
+            )
+            .await
+            .expect("debugger_set_breakpoint failed");
+        // Where the response already knows the line moved, it says why
+        if let Some(verified_line) = result["verifiedLine"].as_i64() {
+            assert_ne!(verified_line, line, "{}", result);
+            assert!(result["moveExplanation"].is_string(), "{}", result);
+        }
+    }
+    tools_handler
+        .handle_tool("debugger_continue", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+
+    // Stopped on one of them, attributed to the line it was requested on
+    let stop = wait(session_id.clone()).await;
+    assert_eq!(stop["reason"], "breakpoint", "{}", stop);
+    let hit = &stop["hitBreakpoints"][0];
+    let requested = hit["line"]
+        .as_i64()
+        .expect("the stop should be attributed to a breakpoint");
+    assert!(lines.contains(&requested), "{}", stop);
+    // Where the debugger moved it, the hit says where to and why
+    let bound = match hit["verifiedLine"].as_i64() {
+        Some(verified_line) => {
+            assert_ne!(verified_line, requested, "{}", stop);
+            let explanation = hit["moveExplanation"].as_str().unwrap_or_default();
+            assert!(
+                explanation.contains(&format!("line {}", requested)),
+                "{}",
+                stop
+            );
+            verified_line
+        }
+        None => requested,
+    };
+
+    // Removable by the line it is bound to too
+    let removed = tools_handler
+        .handle_tool(
+            "debugger_set_breakpoint",
+            json!({"sessionId": session_id, "sourcePath": source, "line": bound, "remove": true}),
+        )
+        .await
+        .expect("the breakpoint should be removable by its bound line");
+    assert_eq!(removed["removed"], 1, "{}", removed);
+
+    tools_handler
+        .handle_tool("debugger_disconnect", json!({"sessionId": session_id}))
+        .await
+        .unwrap();
+}